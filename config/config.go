@@ -5,16 +5,21 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
-// Config 应用程序配置结构
-var Config struct {
+// AppConfig 应用程序配置结构
+type AppConfig struct {
 	// 服务器配置
 	Server struct {
 		Port       int
 		InstanceID string // 实例标识，用于多实例部署
+		// 优雅关闭总超时时间（秒）
+		ShutdownTimeoutSeconds int
+		// 收到退出信号后，就绪检查先失败并等待该时间再停止接收请求，便于负载均衡摘除实例（秒）
+		DrainDelaySeconds int
 	}
 
 	// 数据库配置
@@ -26,6 +31,22 @@ var Config struct {
 		Password string
 		DBName   string
 		Charset  string
+		// SQLite数据库文件路径（driver为sqlite时使用）
+		Path string
+		// SQLite写锁等待时间（毫秒）
+		BusyTimeoutMs int
+		// 主库完整连接字符串，设置后忽略Host/Port等字段
+		DSN string
+		// 只读副本，配置后读请求路由到副本，写请求和事务使用主库
+		Replicas []DatabaseReplica
+		// 副本负载均衡策略：random（默认）或 round_robin
+		ReplicaPolicy string
+		// 副本最大复制延迟（秒），超过后健康检查将副本标记为不健康，0表示不检查
+		MaxReplicaLagSeconds int
+		// 连接池配置
+		Pool DatabasePool
+		// 慢查询阈值（毫秒），超过后记录脱敏SQL指纹，0表示不记录
+		SlowQueryThresholdMs int
 	}
 
 	// 日志配置
@@ -34,6 +55,11 @@ var Config struct {
 		OutputPath  string
 		ErrorPath   string
 		Development bool
+
+		// 访问日志配置
+		AccessLogSampleRate      float64  // 成功请求的采样率（0~1）
+		AccessLogSlowThresholdMs int      // 慢请求阈值（毫秒），慢请求总是记录
+		AccessLogSkipPaths       []string // 不记录访问日志的路径
 	}
 
 	// JWT配置
@@ -60,6 +86,14 @@ var Config struct {
 	// 数据库迁移配置
 	AutoMigrate bool
 
+	// 启动迁移策略
+	Migration struct {
+		// 策略：block（默认，同步执行）、verify（只校验）、async（后台执行）、skip
+		Policy string
+		// 启动时检查数据库结构与GORM模型是否一致，不一致时输出警告
+		DriftCheck bool
+	}
+
 	// 插件配置
 	Plugins struct {
 		Dir            string
@@ -84,153 +118,310 @@ var Config struct {
 		Password   string
 		From       string
 	}
+
+	// Redis配置（分布式限流等共享状态使用）
+	Redis struct {
+		Addr     string
+		Password string
+		DB       int
+	}
+
+	// 限流配置
+	RateLimit struct {
+		Store     string            // 存储：memory 或 redis
+		KeyPrefix string            // Redis键前缀
+		Policies  []RateLimitPolicy // 按路由组或插件配置的限流策略
+	}
+
+	// SLO配置
+	SLO struct {
+		RulesFile  string         // 生成的Prometheus规则文件路径，为空则不写文件
+		Objectives []SLOObjective // SLO定义
+	}
+
+	// 外部依赖容错配置（重试、熔断、并发隔离）
+	Resilience struct {
+		Dependencies []DependencyPolicy // 按依赖名称覆盖默认策略
+	}
+
+	// 路由超时和重试配置
+	Routes struct {
+		Policies []RoutePolicy // 按路由模板或插件配置的超时和重试策略
+	}
+
+	// 配置热加载
+	Reload struct {
+		Watch      bool // 监听配置文件变更并自动重新加载
+		DebounceMs int  // 文件变更防抖时间（毫秒）
+	}
+
+	// 运维接口访问控制，未配置时 /admin 接口全部拒绝
+	Admin struct {
+		UserIDs []uint // 允许访问运维接口的用户ID
+		Token   string // 运维服务令牌，通过 X-Admin-Token 请求头传递
+	}
+
+	// secretValues 从密钥引用解析出的值（按字段路径），Sanitize 时统一脱敏
+	secretValues map[string][]string
 }
 
-// 重置默认配置到初始值
-func resetDefaults() {
+// Config 当前生效的应用程序配置
+var Config AppConfig
+
+// setDefaults 重置默认配置到初始值
+func (c *AppConfig) setDefaults() {
 	// 服务器配置
-	Config.Server.Port = 8081
-	Config.Server.InstanceID = "weave-default"
+	c.Server.Port = 8081
+	c.Server.InstanceID = "weave-default"
+	c.Server.ShutdownTimeoutSeconds = 30
+	c.Server.DrainDelaySeconds = 3
 
 	// 数据库配置（非敏感字段默认值）
-	Config.Database.Driver = "mysql"
-	Config.Database.Host = "localhost"
-	Config.Database.Port = 3306
-	Config.Database.DBName = "weave"
-	Config.Database.Charset = "utf8mb4"
+	c.Database.Driver = "mysql"
+	c.Database.Host = "localhost"
+	c.Database.Port = 3306
+	c.Database.DBName = "weave"
+	c.Database.Charset = "utf8mb4"
+	c.Database.Path = "./data/weave.db"
+	c.Database.BusyTimeoutMs = 5000
+	c.Database.DSN = ""
+	c.Database.Replicas = nil
+	c.Database.ReplicaPolicy = ReplicaPolicyRandom
+	c.Database.MaxReplicaLagSeconds = 30
+	c.Database.Pool = DatabasePool{
+		MaxOpenConns:           50,
+		MaxIdleConns:           5,
+		ConnMaxLifetimeSeconds: 3600,
+		ConnMaxIdleTimeSeconds: 900,
+	}
+	c.Database.SlowQueryThresholdMs = 800
 	// 敏感字段（数据库用户名和密码）将通过环境变量或配置文件设置
-	Config.Database.Username = ""
-	Config.Database.Password = ""
+	c.Database.Username = ""
+	c.Database.Password = ""
 
 	// 日志配置
-	Config.Logger.Level = "info"
-	Config.Logger.OutputPath = "stdout"
-	Config.Logger.ErrorPath = "stderr"
-	Config.Logger.Development = false
+	c.Logger.Level = "info"
+	c.Logger.OutputPath = "stdout"
+	c.Logger.ErrorPath = "stderr"
+	c.Logger.Development = false
+	c.Logger.AccessLogSampleRate = 1.0
+	c.Logger.AccessLogSlowThresholdMs = 1000
+	c.Logger.AccessLogSkipPaths = []string{"/metrics"}
 
 	// JWT配置
-	Config.JWT.Secret = ""                 // 敏感信息，将通过环境变量或配置文件设置
-	Config.JWT.AccessTokenExpiry = 60      // 60分钟
-	Config.JWT.RefreshTokenExpiry = 24 * 7 // 7天
+	c.JWT.Secret = ""                 // 敏感信息，将通过环境变量或配置文件设置
+	c.JWT.AccessTokenExpiry = 60      // 60分钟
+	c.JWT.RefreshTokenExpiry = 24 * 7 // 7天
 
 	// CSRF配置
-	Config.CSRF.Enabled = true
-	Config.CSRF.CookieName = "XSRF-TOKEN"
-	Config.CSRF.HeaderName = "X-CSRF-Token"
-	Config.CSRF.TokenLength = 32
-	Config.CSRF.CookieMaxAge = 3600 * 24 * 7 // 7天
-	Config.CSRF.CookiePath = "/"
-	Config.CSRF.CookieDomain = ""
-	Config.CSRF.CookieSecure = false   // 开发环境下为false
-	Config.CSRF.CookieHttpOnly = false // 必须为false以便前端可以读取
-	Config.CSRF.CookieSameSite = "Lax"
+	c.CSRF.Enabled = true
+	c.CSRF.CookieName = "XSRF-TOKEN"
+	c.CSRF.HeaderName = "X-CSRF-Token"
+	c.CSRF.TokenLength = 32
+	c.CSRF.CookieMaxAge = 3600 * 24 * 7 // 7天
+	c.CSRF.CookiePath = "/"
+	c.CSRF.CookieDomain = ""
+	c.CSRF.CookieSecure = false   // 开发环境下为false
+	c.CSRF.CookieHttpOnly = false // 必须为false以便前端可以读取
+	c.CSRF.CookieSameSite = "Lax"
 
 	// 数据库迁移配置
-	Config.AutoMigrate = true
+	c.AutoMigrate = true
+	c.Migration.Policy = MigrationPolicyBlock
+	c.Migration.DriftCheck = false
 
 	// 插件配置
-	Config.Plugins.Dir = "./plugins"
-	Config.Plugins.WatcherEnabled = true
-	Config.Plugins.ScanInterval = 5 // 5秒
-	Config.Plugins.HotReload = true
+	c.Plugins.Dir = "./plugins"
+	c.Plugins.WatcherEnabled = true
+	c.Plugins.ScanInterval = 5 // 5秒
+	c.Plugins.HotReload = true
 
 	// Prometheus配置
-	Config.Prometheus.Enabled = true
-	Config.Prometheus.MetricsPath = "/metrics"
-	Config.Prometheus.EnableGoMetrics = true
-	Config.Prometheus.EnableHTTPMetrics = true
+	c.Prometheus.Enabled = true
+	c.Prometheus.MetricsPath = "/metrics"
+	c.Prometheus.EnableGoMetrics = true
+	c.Prometheus.EnableHTTPMetrics = true
 
 	// 邮件服务配置默认值
-	Config.Email.SMTPServer = "smtp.qq.com"
-	Config.Email.SMTPPort = 587
-	Config.Email.Username = ""
-	Config.Email.Password = ""
-	Config.Email.From = ""
+	c.Email.SMTPServer = "smtp.qq.com"
+	c.Email.SMTPPort = 587
+	c.Email.Username = ""
+	c.Email.Password = ""
+	c.Email.From = ""
+
+	// Redis配置
+	c.Redis.Addr = ""
+	c.Redis.Password = ""
+	c.Redis.DB = 0
+
+	// 限流配置
+	c.RateLimit.Store = RateLimitStoreMemory
+	c.RateLimit.KeyPrefix = "weave:ratelimit"
+	c.RateLimit.Policies = nil
+
+	// SLO配置
+	c.SLO.RulesFile = ""
+	c.SLO.Objectives = nil
+
+	// 外部依赖容错配置
+	c.Resilience.Dependencies = nil
+
+	// 路由超时和重试配置
+	c.Routes.Policies = nil
+
+	// 配置热加载
+	c.Reload.Watch = true
+	c.Reload.DebounceMs = 500
+
+	// 运维接口访问控制
+	c.Admin.UserIDs = nil
+	c.Admin.Token = ""
 }
 
 func init() {
-	resetDefaults()
+	Config.setDefaults()
 }
 
-// ValidateConfig 验证配置的有效性
+// ValidateConfig 验证当前配置的有效性
 func ValidateConfig() error {
-	// 1. 检查必要的敏感配置项
-	if Config.Database.Username == "" {
-		return fmt.Errorf("数据库用户名未配置，请设置DB_USERNAME环境变量或在配置文件中指定")
-	}
+	return Config.Validate()
+}
 
-	if Config.Database.Password == "" {
-		return fmt.Errorf("数据库密码未配置，请设置DB_PASSWORD环境变量或在配置文件中指定")
+// Validate 验证配置的有效性
+func (c *AppConfig) Validate() error {
+	// 1. 检查必要的敏感配置项（SQLite为嵌入式数据库，不需要账号密码；配置了完整DSN时由DSN提供）
+	if c.Database.Driver != "sqlite" && c.Database.DSN == "" {
+		if c.Database.Username == "" {
+			return fmt.Errorf("数据库用户名未配置，请设置DB_USERNAME环境变量或在配置文件中指定")
+		}
+
+		if c.Database.Password == "" {
+			return fmt.Errorf("数据库密码未配置，请设置DB_PASSWORD环境变量或在配置文件中指定")
+		}
 	}
 
-	if Config.JWT.Secret == "" {
+	if c.JWT.Secret == "" {
 		return fmt.Errorf("JWT密钥未配置，请设置JWT_SECRET环境变量或在配置文件中指定")
 	}
 
 	// 2. 验证服务器配置
-	if Config.Server.Port <= 0 || Config.Server.Port > 65535 {
-		return fmt.Errorf("无效的服务器端口: %d，端口必须在1-65535之间", Config.Server.Port)
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		return fmt.Errorf("无效的服务器端口: %d，端口必须在1-65535之间", c.Server.Port)
+	}
+	if c.Server.ShutdownTimeoutSeconds <= 0 {
+		return fmt.Errorf("无效的优雅关闭超时时间: %d，必须大于0秒", c.Server.ShutdownTimeoutSeconds)
+	}
+	if c.Server.DrainDelaySeconds < 0 || c.Server.DrainDelaySeconds >= c.Server.ShutdownTimeoutSeconds {
+		return fmt.Errorf("无效的排空等待时间: %d，不能为负数且必须小于优雅关闭超时时间", c.Server.DrainDelaySeconds)
 	}
 
 	// 3. 验证数据库配置
-	supportedDrivers := map[string]bool{"mysql": true, "postgres": true, "postgresql": true}
-	if !supportedDrivers[Config.Database.Driver] {
-		return fmt.Errorf("不支持的数据库驱动: %s，支持的驱动有: mysql, postgres, postgresql", Config.Database.Driver)
+	supportedDrivers := map[string]bool{"mysql": true, "postgres": true, "postgresql": true, "sqlite": true}
+	if !supportedDrivers[c.Database.Driver] {
+		return fmt.Errorf("不支持的数据库驱动: %s，支持的驱动有: mysql, postgres, postgresql, sqlite", c.Database.Driver)
 	}
 
-	if Config.Database.Port <= 0 || Config.Database.Port > 65535 {
-		return fmt.Errorf("无效的数据库端口: %d，端口必须在1-65535之间", Config.Database.Port)
-	}
+	if c.Database.Driver == "sqlite" {
+		if c.Database.Path == "" {
+			return fmt.Errorf("SQLite数据库文件路径未配置")
+		}
+		if c.Database.BusyTimeoutMs < 0 {
+			return fmt.Errorf("无效的SQLite busy_timeout: %d，不能为负数", c.Database.BusyTimeoutMs)
+		}
+	} else if c.Database.DSN == "" {
+		if c.Database.Port <= 0 || c.Database.Port > 65535 {
+			return fmt.Errorf("无效的数据库端口: %d，端口必须在1-65535之间", c.Database.Port)
+		}
 
-	if Config.Database.DBName == "" {
-		return fmt.Errorf("数据库名称未配置")
+		if c.Database.DBName == "" {
+			return fmt.Errorf("数据库名称未配置")
+		}
 	}
 
 	// 4. 验证日志配置
 	validLogLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true, "fatal": true}
-	if !validLogLevels[Config.Logger.Level] {
-		return fmt.Errorf("无效的日志级别: %s，有效级别为: debug, info, warn, error, fatal", Config.Logger.Level)
+	if !validLogLevels[c.Logger.Level] {
+		return fmt.Errorf("无效的日志级别: %s，有效级别为: debug, info, warn, error, fatal", c.Logger.Level)
+	}
+
+	if c.Logger.AccessLogSampleRate < 0 || c.Logger.AccessLogSampleRate > 1 {
+		return fmt.Errorf("无效的访问日志采样率: %v，必须在0到1之间", c.Logger.AccessLogSampleRate)
 	}
 
 	// 5. 验证JWT配置
-	if Config.JWT.AccessTokenExpiry <= 0 {
-		return fmt.Errorf("无效的访问令牌过期时间: %d，必须大于0分钟", Config.JWT.AccessTokenExpiry)
+	if c.JWT.AccessTokenExpiry <= 0 {
+		return fmt.Errorf("无效的访问令牌过期时间: %d，必须大于0分钟", c.JWT.AccessTokenExpiry)
 	}
 
-	if Config.JWT.RefreshTokenExpiry <= 0 {
-		return fmt.Errorf("无效的刷新令牌过期时间: %d，必须大于0小时", Config.JWT.RefreshTokenExpiry)
+	if c.JWT.RefreshTokenExpiry <= 0 {
+		return fmt.Errorf("无效的刷新令牌过期时间: %d，必须大于0小时", c.JWT.RefreshTokenExpiry)
 	}
 
 	// 6. 验证CSRF配置
-	if Config.CSRF.TokenLength < 16 {
-		return fmt.Errorf("CSRF令牌长度过小: %d，建议至少16个字符", Config.CSRF.TokenLength)
+	if c.CSRF.TokenLength < 16 {
+		return fmt.Errorf("CSRF令牌长度过小: %d，建议至少16个字符", c.CSRF.TokenLength)
 	}
 
 	validSameSiteValues := map[string]bool{"Strict": true, "Lax": true, "None": true}
-	if !validSameSiteValues[Config.CSRF.CookieSameSite] {
-		return fmt.Errorf("无效的Cookie SameSite值: %s，有效值为: Strict, Lax, None", Config.CSRF.CookieSameSite)
+	if !validSameSiteValues[c.CSRF.CookieSameSite] {
+		return fmt.Errorf("无效的Cookie SameSite值: %s，有效值为: Strict, Lax, None", c.CSRF.CookieSameSite)
 	}
 
 	// 7. 验证插件配置
-	if Config.Plugins.Dir == "" {
+	if c.Plugins.Dir == "" {
 		return fmt.Errorf("插件目录未配置")
 	}
 
 	// 检查插件目录是否存在
-	if _, err := os.Stat(Config.Plugins.Dir); os.IsNotExist(err) {
+	if _, err := os.Stat(c.Plugins.Dir); os.IsNotExist(err) {
 		// 创建插件目录
-		if err := os.MkdirAll(Config.Plugins.Dir, 0755); err != nil {
+		if err := os.MkdirAll(c.Plugins.Dir, 0755); err != nil {
 			return fmt.Errorf("创建插件目录失败: %w", err)
 		}
 	}
 
-	if Config.Plugins.ScanInterval <= 0 {
-		return fmt.Errorf("无效的插件扫描间隔: %d，必须大于0秒", Config.Plugins.ScanInterval)
+	if c.Plugins.ScanInterval <= 0 {
+		return fmt.Errorf("无效的插件扫描间隔: %d，必须大于0秒", c.Plugins.ScanInterval)
 	}
 
 	// 8. 验证Prometheus配置
-	if Config.Prometheus.MetricsPath != "" && Config.Prometheus.MetricsPath[0] != '/' {
-		return fmt.Errorf("Prometheus指标路径必须以斜杠开头: %s", Config.Prometheus.MetricsPath)
+	if c.Prometheus.MetricsPath != "" && c.Prometheus.MetricsPath[0] != '/' {
+		return fmt.Errorf("Prometheus指标路径必须以斜杠开头: %s", c.Prometheus.MetricsPath)
+	}
+
+	// 9. 验证限流配置
+	if err := c.validateRateLimit(); err != nil {
+		return err
+	}
+
+	// 10. 验证SLO配置
+	if err := validateSLOs(c.SLO.Objectives); err != nil {
+		return err
+	}
+
+	// 11. 验证外部依赖容错配置
+	if err := c.validateResilience(); err != nil {
+		return err
+	}
+
+	// 12. 验证数据库连接池、读写分离和慢查询配置
+	if err := c.validateDatabaseRouting(); err != nil {
+		return err
+	}
+
+	// 13. 验证迁移策略
+	if err := c.validateMigration(); err != nil {
+		return err
+	}
+
+	// 14. 验证配置热加载
+	if c.Reload.DebounceMs < 0 {
+		return fmt.Errorf("无效的配置热加载防抖时间: %d，不能为负数", c.Reload.DebounceMs)
+	}
+
+	// 15. 验证路由超时和重试策略
+	if err := c.validateRoutePolicies(); err != nil {
+		return err
 	}
 
 	return nil
@@ -269,57 +460,119 @@ func mapToPrometheusConfig(configMap map[string]interface{}) {
 	}
 }
 
-// SanitizeConfig 清理配置中的敏感信息，用于日志输出
+// SanitizeConfig 清理当前配置中的敏感信息，用于日志输出
 func SanitizeConfig() map[string]interface{} {
+	return Current().Sanitize()
+}
+
+// Sanitize 清理配置中的敏感信息，用于日志输出和配置差异比较
+func (c *AppConfig) Sanitize() map[string]interface{} {
+	// 通过 ${provider:ref} 解析出的密钥无论出现在哪个字段都需要隐藏
+	if len(c.secretValues) > 0 {
+		c = c.maskedCopy()
+	}
+
 	// 创建配置的安全副本用于日志输出
 	sanitized := map[string]interface{}{
 		"Server": map[string]interface{}{
-			"Port": Config.Server.Port,
+			"Port":                   c.Server.Port,
+			"ShutdownTimeoutSeconds": c.Server.ShutdownTimeoutSeconds,
+			"DrainDelaySeconds":      c.Server.DrainDelaySeconds,
 		},
 		"Database": map[string]interface{}{
-			"Driver":   Config.Database.Driver,
-			"Host":     Config.Database.Host,
-			"Port":     Config.Database.Port,
-			"Username": Config.Database.Username,
-			"Password": "***", // 隐藏密码
-			"DBName":   Config.Database.DBName,
-			"Charset":  Config.Database.Charset,
+			"Driver":               c.Database.Driver,
+			"Host":                 c.Database.Host,
+			"Port":                 c.Database.Port,
+			"Username":             c.Database.Username,
+			"Password":             "***", // 隐藏密码
+			"DBName":               c.Database.DBName,
+			"Charset":              c.Database.Charset,
+			"Path":                 c.Database.Path,
+			"DSN":                  sanitizeDSN(c.Database.DSN),
+			"Replicas":             sanitizeReplicas(c.Database.Replicas),
+			"ReplicaPolicy":        c.Database.ReplicaPolicy,
+			"MaxReplicaLagSeconds": c.Database.MaxReplicaLagSeconds,
+			"Pool":                 c.Database.Pool,
+			"SlowQueryThresholdMs": c.Database.SlowQueryThresholdMs,
 		},
 		"Logger": map[string]interface{}{
-			"Level":       Config.Logger.Level,
-			"OutputPath":  Config.Logger.OutputPath,
-			"ErrorPath":   Config.Logger.ErrorPath,
-			"Development": Config.Logger.Development,
+			"Level":       c.Logger.Level,
+			"OutputPath":  c.Logger.OutputPath,
+			"ErrorPath":   c.Logger.ErrorPath,
+			"Development": c.Logger.Development,
+
+			"AccessLogSampleRate":      c.Logger.AccessLogSampleRate,
+			"AccessLogSlowThresholdMs": c.Logger.AccessLogSlowThresholdMs,
+			"AccessLogSkipPaths":       c.Logger.AccessLogSkipPaths,
 		},
 		"JWT": map[string]interface{}{
 			"Secret":             "***", // 隐藏密钥
-			"AccessTokenExpiry":  Config.JWT.AccessTokenExpiry,
-			"RefreshTokenExpiry": Config.JWT.RefreshTokenExpiry,
+			"AccessTokenExpiry":  c.JWT.AccessTokenExpiry,
+			"RefreshTokenExpiry": c.JWT.RefreshTokenExpiry,
 		},
 		"CSRF": map[string]interface{}{
-			"Enabled":        Config.CSRF.Enabled,
-			"CookieName":     Config.CSRF.CookieName,
-			"HeaderName":     Config.CSRF.HeaderName,
-			"TokenLength":    Config.CSRF.TokenLength,
-			"CookieMaxAge":   Config.CSRF.CookieMaxAge,
-			"CookiePath":     Config.CSRF.CookiePath,
-			"CookieDomain":   Config.CSRF.CookieDomain,
-			"CookieSecure":   Config.CSRF.CookieSecure,
-			"CookieHttpOnly": Config.CSRF.CookieHttpOnly,
-			"CookieSameSite": Config.CSRF.CookieSameSite,
+			"Enabled":        c.CSRF.Enabled,
+			"CookieName":     c.CSRF.CookieName,
+			"HeaderName":     c.CSRF.HeaderName,
+			"TokenLength":    c.CSRF.TokenLength,
+			"CookieMaxAge":   c.CSRF.CookieMaxAge,
+			"CookiePath":     c.CSRF.CookiePath,
+			"CookieDomain":   c.CSRF.CookieDomain,
+			"CookieSecure":   c.CSRF.CookieSecure,
+			"CookieHttpOnly": c.CSRF.CookieHttpOnly,
+			"CookieSameSite": c.CSRF.CookieSameSite,
+		},
+		"AutoMigrate": c.AutoMigrate,
+		"Migration": map[string]interface{}{
+			"Policy":     c.Migration.Policy,
+			"DriftCheck": c.Migration.DriftCheck,
 		},
-		"AutoMigrate": Config.AutoMigrate,
 		"Plugins": map[string]interface{}{
-			"Dir":            Config.Plugins.Dir,
-			"WatcherEnabled": Config.Plugins.WatcherEnabled,
-			"ScanInterval":   Config.Plugins.ScanInterval,
-			"HotReload":      Config.Plugins.HotReload,
+			"Dir":            c.Plugins.Dir,
+			"WatcherEnabled": c.Plugins.WatcherEnabled,
+			"ScanInterval":   c.Plugins.ScanInterval,
+			"HotReload":      c.Plugins.HotReload,
 		},
 		"Prometheus": map[string]interface{}{
-			"Enabled":           Config.Prometheus.Enabled,
-			"MetricsPath":       Config.Prometheus.MetricsPath,
-			"EnableGoMetrics":   Config.Prometheus.EnableGoMetrics,
-			"EnableHTTPMetrics": Config.Prometheus.EnableHTTPMetrics,
+			"Enabled":           c.Prometheus.Enabled,
+			"MetricsPath":       c.Prometheus.MetricsPath,
+			"EnableGoMetrics":   c.Prometheus.EnableGoMetrics,
+			"EnableHTTPMetrics": c.Prometheus.EnableHTTPMetrics,
+		},
+		"Email": map[string]interface{}{
+			"SMTPServer": c.Email.SMTPServer,
+			"SMTPPort":   c.Email.SMTPPort,
+			"Username":   c.Email.Username,
+			"Password":   "***", // 隐藏密码
+			"From":       c.Email.From,
+		},
+		"Redis": map[string]interface{}{
+			"Addr":     c.Redis.Addr,
+			"Password": "***", // 隐藏密码
+			"DB":       c.Redis.DB,
+		},
+		"RateLimit": map[string]interface{}{
+			"Store":     c.RateLimit.Store,
+			"KeyPrefix": c.RateLimit.KeyPrefix,
+			"Policies":  c.RateLimit.Policies,
+		},
+		"SLO": map[string]interface{}{
+			"RulesFile":  c.SLO.RulesFile,
+			"Objectives": c.SLO.Objectives,
+		},
+		"Resilience": map[string]interface{}{
+			"Dependencies": c.Resilience.Dependencies,
+		},
+		"Routes": map[string]interface{}{
+			"Policies": c.Routes.Policies,
+		},
+		"Reload": map[string]interface{}{
+			"Watch":      c.Reload.Watch,
+			"DebounceMs": c.Reload.DebounceMs,
+		},
+		"Admin": map[string]interface{}{
+			"UserIDs": c.Admin.UserIDs,
+			"Token":   "***",
 		},
 	}
 
@@ -343,115 +596,201 @@ func GetAbsConfigFilePath() (string, error) {
 
 // LoadConfigWithViper 使用Viper从配置文件和环境变量加载配置
 func LoadConfigWithViper() error {
+	var c AppConfig
+	err := c.load()
+	// 校验失败时仍应用加载结果，调用方决定是否退出
+	Config = c
+	if err != nil {
+		return err
+	}
+	if err := Config.Validate(); err != nil {
+		return err
+	}
+	storeSnapshot(&c)
+	return nil
+}
+
+// load 依次加载默认值、环境变量和配置文件并解析密钥引用，不做校验
+func (c *AppConfig) load() error {
 	// 在每次加载前重置默认值，避免跨测试用例状态污染
-	resetDefaults()
+	c.setDefaults()
 
 	// 从环境变量加载配置，优先级最高
 	// 服务器配置
 	if val := os.Getenv("SERVER_PORT"); val != "" {
 		if port, err := strconv.Atoi(val); err == nil {
-			Config.Server.Port = port
+			c.Server.Port = port
 		}
 	}
 
 	// 数据库配置
 	if val := os.Getenv("DB_DRIVER"); val != "" {
-		Config.Database.Driver = val
+		c.Database.Driver = val
 	}
 	if val := os.Getenv("DB_HOST"); val != "" {
-		Config.Database.Host = val
+		c.Database.Host = val
 	}
 	if val := os.Getenv("DB_PORT"); val != "" {
 		if port, err := strconv.Atoi(val); err == nil {
-			Config.Database.Port = port
+			c.Database.Port = port
 		}
 	}
 	if val := os.Getenv("DB_USERNAME"); val != "" {
-		Config.Database.Username = val
+		c.Database.Username = val
 	}
 	if val := os.Getenv("DB_PASSWORD"); val != "" {
-		Config.Database.Password = val
+		c.Database.Password = val
 	}
 	if val := os.Getenv("DB_NAME"); val != "" {
-		Config.Database.DBName = val
+		c.Database.DBName = val
 	}
 	if val := os.Getenv("DB_CHARSET"); val != "" {
-		Config.Database.Charset = val
+		c.Database.Charset = val
+	}
+	if val := os.Getenv("DB_PATH"); val != "" {
+		c.Database.Path = val
+	}
+	if val := os.Getenv("DB_DSN"); val != "" {
+		c.Database.DSN = val
+	}
+	if val := os.Getenv("DB_REPLICA_DSNS"); val != "" {
+		c.Database.Replicas = nil
+		for _, dsn := range strings.Split(val, ",") {
+			if dsn = strings.TrimSpace(dsn); dsn != "" {
+				c.Database.Replicas = append(c.Database.Replicas, DatabaseReplica{DSN: dsn})
+			}
+		}
+	}
+	if val := os.Getenv("DB_MAX_OPEN_CONNS"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			c.Database.Pool.MaxOpenConns = n
+		}
+	}
+	if val := os.Getenv("DB_MAX_IDLE_CONNS"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			c.Database.Pool.MaxIdleConns = n
+		}
+	}
+	if val := os.Getenv("DB_SLOW_QUERY_THRESHOLD_MS"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			c.Database.SlowQueryThresholdMs = n
+		}
 	}
 
 	// JWT配置
 	if val := os.Getenv("JWT_SECRET"); val != "" {
-		Config.JWT.Secret = val
+		c.JWT.Secret = val
 	}
 	if val := os.Getenv("JWT_ACCESS_TOKEN_EXPIRY"); val != "" {
 		if expiry, err := strconv.Atoi(val); err == nil {
-			Config.JWT.AccessTokenExpiry = expiry
+			c.JWT.AccessTokenExpiry = expiry
 		}
 	}
 	if val := os.Getenv("JWT_REFRESH_TOKEN_EXPIRY"); val != "" {
 		if expiry, err := strconv.Atoi(val); err == nil {
-			Config.JWT.RefreshTokenExpiry = expiry
+			c.JWT.RefreshTokenExpiry = expiry
 		}
 	}
 
 	// 邮件服务配置
 	if val := os.Getenv("EMAIL_SMTP_SERVER"); val != "" {
-		Config.Email.SMTPServer = val
+		c.Email.SMTPServer = val
 	}
 	if val := os.Getenv("EMAIL_SMTP_PORT"); val != "" {
 		if port, err := strconv.Atoi(val); err == nil {
-			Config.Email.SMTPPort = port
+			c.Email.SMTPPort = port
 		}
 	}
 	if val := os.Getenv("EMAIL_USERNAME"); val != "" {
-		Config.Email.Username = val
+		c.Email.Username = val
 	}
 	if val := os.Getenv("EMAIL_PASSWORD"); val != "" {
-		Config.Email.Password = val
+		c.Email.Password = val
 	}
 	if val := os.Getenv("EMAIL_FROM"); val != "" {
-		Config.Email.From = val
+		c.Email.From = val
 	}
 
 	// CSRF配置
 	if val := os.Getenv("CSRF_ENABLED"); val != "" {
-		Config.CSRF.Enabled = convertToBool(val)
+		c.CSRF.Enabled = convertToBool(val)
 	}
 	if val := os.Getenv("CSRF_COOKIE_NAME"); val != "" {
-		Config.CSRF.CookieName = val
+		c.CSRF.CookieName = val
 	}
 	if val := os.Getenv("CSRF_HEADER_NAME"); val != "" {
-		Config.CSRF.HeaderName = val
+		c.CSRF.HeaderName = val
 	}
 	if val := os.Getenv("CSRF_TOKEN_LENGTH"); val != "" {
 		if length, err := strconv.Atoi(val); err == nil {
-			Config.CSRF.TokenLength = length
+			c.CSRF.TokenLength = length
 		}
 	}
 	if val := os.Getenv("CSRF_COOKIE_MAX_AGE"); val != "" {
 		if maxAge, err := strconv.Atoi(val); err == nil {
-			Config.CSRF.CookieMaxAge = maxAge
+			c.CSRF.CookieMaxAge = maxAge
 		}
 	}
 	if val := os.Getenv("CSRF_COOKIE_PATH"); val != "" {
-		Config.CSRF.CookiePath = val
+		c.CSRF.CookiePath = val
 	}
 	if val := os.Getenv("CSRF_COOKIE_DOMAIN"); val != "" {
-		Config.CSRF.CookieDomain = val
+		c.CSRF.CookieDomain = val
 	}
 	if val := os.Getenv("CSRF_COOKIE_SECURE"); val != "" {
-		Config.CSRF.CookieSecure = convertToBool(val)
+		c.CSRF.CookieSecure = convertToBool(val)
 	}
 	if val := os.Getenv("CSRF_COOKIE_HTTP_ONLY"); val != "" {
-		Config.CSRF.CookieHttpOnly = convertToBool(val)
+		c.CSRF.CookieHttpOnly = convertToBool(val)
 	}
 	if val := os.Getenv("CSRF_COOKIE_SAME_SITE"); val != "" {
-		Config.CSRF.CookieSameSite = val
+		c.CSRF.CookieSameSite = val
+	}
+
+	// Redis配置
+	if val := os.Getenv("REDIS_ADDR"); val != "" {
+		c.Redis.Addr = val
+	}
+	if val := os.Getenv("REDIS_PASSWORD"); val != "" {
+		c.Redis.Password = val
+	}
+	if val := os.Getenv("REDIS_DB"); val != "" {
+		if db, err := strconv.Atoi(val); err == nil {
+			c.Redis.DB = db
+		}
+	}
+
+	// 限流配置
+	if val := os.Getenv("RATE_LIMIT_STORE"); val != "" {
+		c.RateLimit.Store = val
+	}
+
+	// 访问日志配置
+	if val := os.Getenv("LOG_ACCESS_SAMPLE_RATE"); val != "" {
+		if rate, err := strconv.ParseFloat(val, 64); err == nil {
+			c.Logger.AccessLogSampleRate = rate
+		}
 	}
 
 	// 自动迁移配置
 	if val := os.Getenv("AUTO_MIGRATE"); val != "" {
-		Config.AutoMigrate = convertToBool(val)
+		c.AutoMigrate = convertToBool(val)
+	}
+	if val := os.Getenv("MIGRATION_POLICY"); val != "" {
+		c.Migration.Policy = val
+	}
+	if val := os.Getenv("MIGRATION_DRIFT_CHECK"); val != "" {
+		c.Migration.DriftCheck = convertToBool(val)
+	}
+
+	// 配置热加载
+	if val := os.Getenv("CONFIG_WATCH"); val != "" {
+		c.Reload.Watch = convertToBool(val)
+	}
+
+	// 运维接口访问控制
+	if val := os.Getenv("ADMIN_TOKEN"); val != "" {
+		c.Admin.Token = val
 	}
 
 	// 创建Viper实例用于加载配置文件
@@ -470,129 +809,230 @@ func LoadConfigWithViper() error {
 	if err := v.ReadInConfig(); err == nil {
 		// 从配置文件加载配置，优先级低于环境变量
 		if v.IsSet("server.port") {
-			Config.Server.Port = v.GetInt("server.port")
+			c.Server.Port = v.GetInt("server.port")
 		}
 		if v.IsSet("server.instanceID") {
-			Config.Server.InstanceID = v.GetString("server.instanceID")
+			c.Server.InstanceID = v.GetString("server.instanceID")
+		}
+		if v.IsSet("server.shutdownTimeoutSeconds") {
+			c.Server.ShutdownTimeoutSeconds = v.GetInt("server.shutdownTimeoutSeconds")
+		}
+		if v.IsSet("server.drainDelaySeconds") {
+			c.Server.DrainDelaySeconds = v.GetInt("server.drainDelaySeconds")
 		}
 		if v.IsSet("database.driver") {
-			Config.Database.Driver = v.GetString("database.driver")
+			c.Database.Driver = v.GetString("database.driver")
 		}
 		if v.IsSet("database.host") {
-			Config.Database.Host = v.GetString("database.host")
+			c.Database.Host = v.GetString("database.host")
 		}
 		if v.IsSet("database.port") {
-			Config.Database.Port = v.GetInt("database.port")
+			c.Database.Port = v.GetInt("database.port")
 		}
 		if v.IsSet("database.username") {
-			Config.Database.Username = v.GetString("database.username")
+			c.Database.Username = v.GetString("database.username")
 		}
 		if v.IsSet("database.password") {
-			Config.Database.Password = v.GetString("database.password")
+			c.Database.Password = v.GetString("database.password")
 		}
 		if v.IsSet("database.dbname") {
-			Config.Database.DBName = v.GetString("database.dbname")
+			c.Database.DBName = v.GetString("database.dbname")
 		}
 		if v.IsSet("database.charset") {
-			Config.Database.Charset = v.GetString("database.charset")
+			c.Database.Charset = v.GetString("database.charset")
+		}
+		if v.IsSet("database.path") {
+			c.Database.Path = v.GetString("database.path")
+		}
+		if v.IsSet("database.busyTimeoutMs") {
+			c.Database.BusyTimeoutMs = v.GetInt("database.busyTimeoutMs")
+		}
+		if v.IsSet("database.dsn") {
+			c.Database.DSN = v.GetString("database.dsn")
+		}
+		if v.IsSet("database.replicas") {
+			if err := v.UnmarshalKey("database.replicas", &c.Database.Replicas); err != nil {
+				return fmt.Errorf("解析只读副本配置失败: %w", err)
+			}
+		}
+		if v.IsSet("database.replicaPolicy") {
+			c.Database.ReplicaPolicy = v.GetString("database.replicaPolicy")
+		}
+		if v.IsSet("database.maxReplicaLagSeconds") {
+			c.Database.MaxReplicaLagSeconds = v.GetInt("database.maxReplicaLagSeconds")
+		}
+		if v.IsSet("database.slowQueryThresholdMs") {
+			c.Database.SlowQueryThresholdMs = v.GetInt("database.slowQueryThresholdMs")
+		}
+		if v.IsSet("database.pool") {
+			if err := v.UnmarshalKey("database.pool", &c.Database.Pool); err != nil {
+				return fmt.Errorf("解析数据库连接池配置失败: %w", err)
+			}
 		}
 		if v.IsSet("logger.level") {
-			Config.Logger.Level = v.GetString("logger.level")
+			c.Logger.Level = v.GetString("logger.level")
 		}
 		if v.IsSet("logger.outputPath") {
-			Config.Logger.OutputPath = v.GetString("logger.outputPath")
+			c.Logger.OutputPath = v.GetString("logger.outputPath")
 		}
 		if v.IsSet("logger.errorPath") {
-			Config.Logger.ErrorPath = v.GetString("logger.errorPath")
+			c.Logger.ErrorPath = v.GetString("logger.errorPath")
 		}
 		if v.IsSet("logger.development") {
-			Config.Logger.Development = convertToBool(v.Get("logger.development"))
+			c.Logger.Development = convertToBool(v.Get("logger.development"))
+		}
+		if v.IsSet("logger.accessLog.sampleRate") {
+			c.Logger.AccessLogSampleRate = v.GetFloat64("logger.accessLog.sampleRate")
+		}
+		if v.IsSet("logger.accessLog.slowThresholdMs") {
+			c.Logger.AccessLogSlowThresholdMs = v.GetInt("logger.accessLog.slowThresholdMs")
+		}
+		if v.IsSet("logger.accessLog.skipPaths") {
+			c.Logger.AccessLogSkipPaths = v.GetStringSlice("logger.accessLog.skipPaths")
 		}
 		if v.IsSet("jwt.secret") {
-			Config.JWT.Secret = v.GetString("jwt.secret")
+			c.JWT.Secret = v.GetString("jwt.secret")
 		}
 		if v.IsSet("jwt.accessTokenExpiry") {
-			Config.JWT.AccessTokenExpiry = v.GetInt("jwt.accessTokenExpiry")
+			c.JWT.AccessTokenExpiry = v.GetInt("jwt.accessTokenExpiry")
 		}
 		if v.IsSet("jwt.refreshTokenExpiry") {
-			Config.JWT.RefreshTokenExpiry = v.GetInt("jwt.refreshTokenExpiry")
+			c.JWT.RefreshTokenExpiry = v.GetInt("jwt.refreshTokenExpiry")
 		}
 		if v.IsSet("csrf.enabled") {
-			Config.CSRF.Enabled = convertToBool(v.Get("csrf.enabled"))
+			c.CSRF.Enabled = convertToBool(v.Get("csrf.enabled"))
 		}
 		if v.IsSet("csrf.cookieName") {
-			Config.CSRF.CookieName = v.GetString("csrf.cookieName")
+			c.CSRF.CookieName = v.GetString("csrf.cookieName")
 		}
 		if v.IsSet("csrf.headerName") {
-			Config.CSRF.HeaderName = v.GetString("csrf.headerName")
+			c.CSRF.HeaderName = v.GetString("csrf.headerName")
 		}
 		if v.IsSet("csrf.tokenLength") {
-			Config.CSRF.TokenLength = v.GetInt("csrf.tokenLength")
+			c.CSRF.TokenLength = v.GetInt("csrf.tokenLength")
 		}
 		if v.IsSet("csrf.cookieMaxAge") {
-			Config.CSRF.CookieMaxAge = v.GetInt("csrf.cookieMaxAge")
+			c.CSRF.CookieMaxAge = v.GetInt("csrf.cookieMaxAge")
 		}
 		if v.IsSet("csrf.cookiePath") {
-			Config.CSRF.CookiePath = v.GetString("csrf.cookiePath")
+			c.CSRF.CookiePath = v.GetString("csrf.cookiePath")
 		}
 		if v.IsSet("csrf.cookieDomain") {
-			Config.CSRF.CookieDomain = v.GetString("csrf.cookieDomain")
+			c.CSRF.CookieDomain = v.GetString("csrf.cookieDomain")
 		}
 		if v.IsSet("csrf.cookieSecure") {
-			Config.CSRF.CookieSecure = convertToBool(v.Get("csrf.cookieSecure"))
+			c.CSRF.CookieSecure = convertToBool(v.Get("csrf.cookieSecure"))
 		}
 		if v.IsSet("csrf.cookieHttpOnly") {
-			Config.CSRF.CookieHttpOnly = convertToBool(v.Get("csrf.cookieHttpOnly"))
+			c.CSRF.CookieHttpOnly = convertToBool(v.Get("csrf.cookieHttpOnly"))
 		}
 		if v.IsSet("csrf.cookieSameSite") {
-			Config.CSRF.CookieSameSite = v.GetString("csrf.cookieSameSite")
+			c.CSRF.CookieSameSite = v.GetString("csrf.cookieSameSite")
 		}
 		if v.IsSet("autoMigrate") {
-			Config.AutoMigrate = convertToBool(v.Get("autoMigrate"))
+			c.AutoMigrate = convertToBool(v.Get("autoMigrate"))
+		}
+		if v.IsSet("migration.policy") {
+			c.Migration.Policy = v.GetString("migration.policy")
+		}
+		if v.IsSet("migration.driftCheck") {
+			c.Migration.DriftCheck = convertToBool(v.Get("migration.driftCheck"))
 		}
 		if v.IsSet("plugins.dir") {
-			Config.Plugins.Dir = v.GetString("plugins.dir")
+			c.Plugins.Dir = v.GetString("plugins.dir")
 		}
 		if v.IsSet("plugins.watcherEnabled") {
-			Config.Plugins.WatcherEnabled = convertToBool(v.Get("plugins.watcherEnabled"))
+			c.Plugins.WatcherEnabled = convertToBool(v.Get("plugins.watcherEnabled"))
 		}
 		if v.IsSet("plugins.scanInterval") {
-			Config.Plugins.ScanInterval = v.GetInt("plugins.scanInterval")
+			c.Plugins.ScanInterval = v.GetInt("plugins.scanInterval")
 		}
 		if v.IsSet("plugins.hotReload") {
-			Config.Plugins.HotReload = convertToBool(v.Get("plugins.hotReload"))
+			c.Plugins.HotReload = convertToBool(v.Get("plugins.hotReload"))
 		}
 		if v.IsSet("prometheus.enabled") {
-			Config.Prometheus.Enabled = convertToBool(v.Get("prometheus.enabled"))
+			c.Prometheus.Enabled = convertToBool(v.Get("prometheus.enabled"))
 		}
 		if v.IsSet("prometheus.metricsPath") {
-			Config.Prometheus.MetricsPath = v.GetString("prometheus.metricsPath")
+			c.Prometheus.MetricsPath = v.GetString("prometheus.metricsPath")
 		}
 		if v.IsSet("prometheus.enableGoMetrics") {
-			Config.Prometheus.EnableGoMetrics = convertToBool(v.Get("prometheus.enableGoMetrics"))
+			c.Prometheus.EnableGoMetrics = convertToBool(v.Get("prometheus.enableGoMetrics"))
 		}
 		if v.IsSet("prometheus.enableHTTPMetrics") {
-			Config.Prometheus.EnableHTTPMetrics = convertToBool(v.Get("prometheus.enableHTTPMetrics"))
+			c.Prometheus.EnableHTTPMetrics = convertToBool(v.Get("prometheus.enableHTTPMetrics"))
 		}
 		if v.IsSet("email.smtpServer") {
-			Config.Email.SMTPServer = v.GetString("email.smtpServer")
+			c.Email.SMTPServer = v.GetString("email.smtpServer")
 		}
 		if v.IsSet("email.smtpPort") {
-			Config.Email.SMTPPort = v.GetInt("email.smtpPort")
+			c.Email.SMTPPort = v.GetInt("email.smtpPort")
 		}
 		if v.IsSet("email.username") {
-			Config.Email.Username = v.GetString("email.username")
+			c.Email.Username = v.GetString("email.username")
 		}
 		if v.IsSet("email.password") {
-			Config.Email.Password = v.GetString("email.password")
+			c.Email.Password = v.GetString("email.password")
 		}
 		if v.IsSet("email.from") {
-			Config.Email.From = v.GetString("email.from")
+			c.Email.From = v.GetString("email.from")
+		}
+		if v.IsSet("redis.addr") {
+			c.Redis.Addr = v.GetString("redis.addr")
+		}
+		if v.IsSet("redis.password") {
+			c.Redis.Password = v.GetString("redis.password")
+		}
+		if v.IsSet("redis.db") {
+			c.Redis.DB = v.GetInt("redis.db")
+		}
+		if v.IsSet("rateLimit.store") {
+			c.RateLimit.Store = v.GetString("rateLimit.store")
+		}
+		if v.IsSet("rateLimit.keyPrefix") {
+			c.RateLimit.KeyPrefix = v.GetString("rateLimit.keyPrefix")
+		}
+		if v.IsSet("rateLimit.policies") {
+			if err := v.UnmarshalKey("rateLimit.policies", &c.RateLimit.Policies); err != nil {
+				return fmt.Errorf("解析限流策略配置失败: %w", err)
+			}
+		}
+		if v.IsSet("slo.rulesFile") {
+			c.SLO.RulesFile = v.GetString("slo.rulesFile")
+		}
+		if v.IsSet("slo.objectives") {
+			if err := v.UnmarshalKey("slo.objectives", &c.SLO.Objectives); err != nil {
+				return fmt.Errorf("解析SLO配置失败: %w", err)
+			}
+		}
+		if v.IsSet("resilience.dependencies") {
+			if err := v.UnmarshalKey("resilience.dependencies", &c.Resilience.Dependencies); err != nil {
+				return fmt.Errorf("解析依赖容错配置失败: %w", err)
+			}
+		}
+		if v.IsSet("routes.policies") {
+			if err := v.UnmarshalKey("routes.policies", &c.Routes.Policies); err != nil {
+				return fmt.Errorf("解析路由策略配置失败: %w", err)
+			}
+		}
+		if v.IsSet("reload.watch") {
+			c.Reload.Watch = convertToBool(v.Get("reload.watch"))
+		}
+		if v.IsSet("reload.debounceMs") {
+			c.Reload.DebounceMs = v.GetInt("reload.debounceMs")
+		}
+		if v.IsSet("admin.userIDs") {
+			if err := v.UnmarshalKey("admin.userIDs", &c.Admin.UserIDs); err != nil {
+				return fmt.Errorf("解析运维用户配置失败: %w", err)
+			}
+		}
+		if v.IsSet("admin.token") {
+			c.Admin.Token = v.GetString("admin.token")
 		}
 	}
 
-	// 验证配置
-	return ValidateConfig()
+	// 解析 ${env:X}、${file:path}、${enc:...} 等密钥引用
+	return c.resolveSecrets()
 }
 
 // LoadConfig 从配置文件和环境变量加载配置
@@ -0,0 +1,25 @@
+package config
+
+import "fmt"
+
+// 启动迁移策略
+const (
+	// MigrationPolicyBlock 启动时同步执行迁移，失败则退出
+	MigrationPolicyBlock = "block"
+	// MigrationPolicyVerify 启动时不执行迁移，存在待执行迁移、dirty状态或模型结构漂移时退出
+	MigrationPolicyVerify = "verify"
+	// MigrationPolicyAsync 启动后在后台执行迁移，失败只记录日志
+	MigrationPolicyAsync = "async"
+	// MigrationPolicySkip 启动时不做任何迁移
+	MigrationPolicySkip = "skip"
+)
+
+// validateMigration 校验迁移配置
+func (c *AppConfig) validateMigration() error {
+	switch c.Migration.Policy {
+	case MigrationPolicyBlock, MigrationPolicyVerify, MigrationPolicyAsync, MigrationPolicySkip:
+		return nil
+	default:
+		return fmt.Errorf("无效的迁移策略: %s，有效值为: block, verify, async, skip", c.Migration.Policy)
+	}
+}
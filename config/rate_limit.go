@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 限流键类型
+const (
+	RateLimitKeyIP     = "ip"      // 按客户端IP
+	RateLimitKeyUser   = "user"    // 按用户ID（未认证时回退到IP）
+	RateLimitKeyTenant = "tenant"  // 按租户ID（未认证时回退到IP）
+	RateLimitKeyAPIKey = "api_key" // 按API Key（X-API-Key头，缺失时回退到IP）
+)
+
+// 限流算法
+const (
+	RateLimitTokenBucket   = "token_bucket"
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// 限流存储类型
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+// RateLimitPolicy 限流策略
+// 通过RouteGroup（路由模板前缀）或Plugin匹配请求，也可以通过Name覆盖代码中的同名默认策略
+type RateLimitPolicy struct {
+	// 策略名称
+	Name string
+	// 路由组前缀，如 /api/v1/tools
+	RouteGroup string
+	// 插件名称
+	Plugin string
+	// 限流键类型：ip、user、tenant、api_key
+	KeyBy string
+	// 算法：token_bucket（默认）或 sliding_window
+	Algorithm string
+	// 令牌桶：每秒生成的令牌数
+	Rate float64
+	// 令牌桶容量；滑动窗口：窗口内允许的请求数
+	Burst int
+	// 滑动窗口长度（秒），仅sliding_window使用
+	WindowSeconds int
+}
+
+// validateRateLimit 校验限流配置
+func (c *AppConfig) validateRateLimit() error {
+	switch c.RateLimit.Store {
+	case RateLimitStoreMemory:
+	case RateLimitStoreRedis:
+		if c.Redis.Addr == "" {
+			return fmt.Errorf("限流存储为redis时必须配置Redis地址")
+		}
+	default:
+		return fmt.Errorf("无效的限流存储: %s，有效值为: memory, redis", c.RateLimit.Store)
+	}
+
+	for _, p := range c.RateLimit.Policies {
+		if err := ValidateRateLimitPolicy(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateRateLimitPolicy 校验单个限流策略
+func ValidateRateLimitPolicy(p RateLimitPolicy) error {
+	if p.Name == "" && p.RouteGroup == "" && p.Plugin == "" {
+		return fmt.Errorf("限流策略必须指定 name、routeGroup 或 plugin")
+	}
+	if p.RouteGroup != "" && !strings.HasPrefix(p.RouteGroup, "/") {
+		return fmt.Errorf("限流策略的路由组必须以斜杠开头: %s", p.RouteGroup)
+	}
+	switch p.KeyBy {
+	case "", RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyTenant, RateLimitKeyAPIKey:
+	default:
+		return fmt.Errorf("无效的限流键类型: %s，有效值为: ip, user, tenant, api_key", p.KeyBy)
+	}
+	if p.Burst <= 0 {
+		return fmt.Errorf("限流策略 '%s' 的容量必须大于0", p.Name)
+	}
+	switch p.Algorithm {
+	case "", RateLimitTokenBucket:
+		if p.Rate <= 0 {
+			return fmt.Errorf("限流策略 '%s' 的速率必须大于0", p.Name)
+		}
+	case RateLimitSlidingWindow:
+		if p.WindowSeconds <= 0 {
+			return fmt.Errorf("限流策略 '%s' 的窗口长度必须大于0秒", p.Name)
+		}
+	default:
+		return fmt.Errorf("无效的限流算法: %s，有效值为: token_bucket, sliding_window", p.Algorithm)
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// ConfigChange 一项配置变更，值取自 Sanitize 的结果，敏感字段已脱敏
+type ConfigChange struct {
+	Key string      `json:"key"`
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// ReloadReport 配置重新加载（或预览）的结果
+type ReloadReport struct {
+	Changes         []ConfigChange `json:"changes"`
+	RestartRequired []string       `json:"restartRequired,omitempty"` // 已更新但需要重启才能生效的配置项
+	Applied         bool           `json:"applied"`
+	ValidationError string         `json:"validationError,omitempty"`
+}
+
+// ChangeHandler 配置变更回调，oldCfg和newCfg为变更前后的配置快照，不应修改
+type ChangeHandler func(oldCfg, newCfg *AppConfig)
+
+type subscription struct {
+	name    string
+	keys    []string
+	handler ChangeHandler
+}
+
+// restartRequiredKeys 运行中无法生效的配置项前缀，变更后需要重启服务
+var restartRequiredKeys = []string{
+	"Server", "Database", "Redis", "Prometheus", "Email", "AutoMigrate", "Migration", "Reload",
+	"Plugins.Dir", "Plugins.WatcherEnabled",
+	"Logger.OutputPath", "Logger.ErrorPath", "Logger.Development",
+	"RateLimit.Store", "RateLimit.KeyPrefix", "SLO.RulesFile",
+}
+
+// secretFields Sanitize 中脱敏的字段，只报告是否变更，不输出值
+var secretFields = map[string]func(c *AppConfig) string{
+	"Admin.Token":       func(c *AppConfig) string { return c.Admin.Token },
+	"Database.Password": func(c *AppConfig) string { return c.Database.Password },
+	"Email.Password":    func(c *AppConfig) string { return c.Email.Password },
+	"JWT.Secret":        func(c *AppConfig) string { return c.JWT.Secret },
+	"Redis.Password":    func(c *AppConfig) string { return c.Redis.Password },
+}
+
+var (
+	reloadMu      sync.Mutex
+	snapshot      atomic.Pointer[AppConfig]
+	subscribersMu sync.RWMutex
+	subscribers   []subscription
+)
+
+// storeSnapshot 保存校验通过的配置快照
+func storeSnapshot(c *AppConfig) {
+	cp := *c
+	snapshot.Store(&cp)
+}
+
+// Current 返回最近一次校验通过的配置快照，调用方不应修改
+// 启动后 Config 不再变更，请求路径上需要感知热加载的读取方应使用 Current
+func Current() *AppConfig {
+	if c := snapshot.Load(); c != nil {
+		return c
+	}
+	cp := Config
+	return &cp
+}
+
+// ResetSnapshot 丢弃已发布的配置快照，之后 Current 回退为 Config 的副本
+// 用于测试在直接修改 Config 后恢复默认行为
+func ResetSnapshot() {
+	snapshot.Store(nil)
+}
+
+// Subscribe 订阅配置项变更，keys为 SanitizeConfig 中的路径（如 "Logger.Level"、"RateLimit.Policies"），
+// 按前缀匹配；同名订阅会被替换
+func Subscribe(name string, handler ChangeHandler, keys ...string) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	sub := subscription{name: name, keys: keys, handler: handler}
+	for i, s := range subscribers {
+		if s.name == name {
+			subscribers[i] = sub
+			return
+		}
+	}
+	subscribers = append(subscribers, sub)
+}
+
+// Unsubscribe 取消订阅
+func Unsubscribe(name string) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for i, s := range subscribers {
+		if s.name == name {
+			subscribers = append(subscribers[:i], subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Reload 重新加载配置文件和环境变量
+// 新配置在独立的快照上加载并校验，通过后以原子方式发布为 Current 并通知订阅者；校验失败时当前配置保持不变
+// Config 保留启动时的配置，不在此处修改，避免与并发读取方产生数据竞争
+func Reload() (*ReloadReport, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	next := &AppConfig{}
+	if err := next.load(); err != nil {
+		return nil, err
+	}
+	if err := next.Validate(); err != nil {
+		return nil, fmt.Errorf("配置校验失败，未应用新配置: %w", err)
+	}
+
+	prev := Current()
+	report := newReloadReport(prev, next)
+	storeSnapshot(next)
+	report.Applied = true
+
+	notifySubscribers(prev, next, report.Changes)
+	return report, nil
+}
+
+// PreviewReload 预览重新加载后的配置变更，不应用新配置
+func PreviewReload() (*ReloadReport, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	next := &AppConfig{}
+	if err := next.load(); err != nil {
+		return nil, err
+	}
+	report := newReloadReport(Current(), next)
+	if err := next.Validate(); err != nil {
+		report.ValidationError = err.Error()
+	}
+	return report, nil
+}
+
+// newReloadReport 比较两份配置并标记需要重启的变更
+func newReloadReport(oldCfg, newCfg *AppConfig) *ReloadReport {
+	report := &ReloadReport{Changes: DiffConfig(oldCfg, newCfg)}
+	for _, ch := range report.Changes {
+		if matchesAnyKey(ch.Key, restartRequiredKeys) {
+			report.RestartRequired = append(report.RestartRequired, ch.Key)
+		}
+	}
+	return report
+}
+
+// DiffConfig 比较两份配置，返回按键排序的变更列表，敏感字段只报告变更不输出值
+func DiffConfig(oldCfg, newCfg *AppConfig) []ConfigChange {
+	oldFlat := make(map[string]interface{})
+	newFlat := make(map[string]interface{})
+	flattenConfig("", oldCfg.Sanitize(), oldFlat)
+	flattenConfig("", newCfg.Sanitize(), newFlat)
+
+	keys := make(map[string]struct{}, len(oldFlat))
+	for k := range oldFlat {
+		keys[k] = struct{}{}
+	}
+	for k := range newFlat {
+		keys[k] = struct{}{}
+	}
+
+	var changes []ConfigChange
+	for k := range keys {
+		if get, ok := secretFields[k]; ok {
+			if get(oldCfg) != get(newCfg) {
+				changes = append(changes, ConfigChange{Key: k, Old: "***", New: "***"})
+			}
+			continue
+		}
+		if !reflect.DeepEqual(oldFlat[k], newFlat[k]) {
+			changes = append(changes, ConfigChange{Key: k, Old: oldFlat[k], New: newFlat[k]})
+		}
+	}
+	// 引用不变但密钥内容变化（如轮换了挂载的secret文件）时，脱敏后的值相同，按字段单独报告
+	reported := make(map[string]bool, len(changes))
+	for _, ch := range changes {
+		reported[ch.Key] = true
+	}
+	for path := range mergeKeys(oldCfg.secretValues, newCfg.secretValues) {
+		if !reported[path] && !reflect.DeepEqual(oldCfg.secretValues[path], newCfg.secretValues[path]) {
+			changes = append(changes, ConfigChange{Key: path, Old: "***", New: "***"})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// mergeKeys 返回两个map的键的并集
+func mergeKeys(a, b map[string][]string) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// flattenConfig 将嵌套的配置map展开为点分隔的键
+func flattenConfig(prefix string, m map[string]interface{}, out map[string]interface{}) {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			flattenConfig(key, nested, out)
+			continue
+		}
+		out[key] = v
+	}
+}
+
+// matchesAnyKey 判断配置键是否与任一订阅键匹配（相等或互为前缀路径）
+func matchesAnyKey(key string, patterns []string) bool {
+	for _, p := range patterns {
+		if key == p || strings.HasPrefix(key, p+".") || strings.HasPrefix(p, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// notifySubscribers 通知关注了变更配置项的订阅者
+func notifySubscribers(oldCfg, newCfg *AppConfig, changes []ConfigChange) {
+	if len(changes) == 0 {
+		return
+	}
+	subscribersMu.RLock()
+	subs := append([]subscription(nil), subscribers...)
+	subscribersMu.RUnlock()
+
+	for _, s := range subs {
+		for _, ch := range changes {
+			if len(s.keys) == 0 || matchesAnyKey(ch.Key, s.keys) {
+				s.handler(oldCfg, newCfg)
+				break
+			}
+		}
+	}
+}
+
+// Watch 监听配置文件变更并自动重新加载，onReload 接收每次重新加载的结果
+// 返回的函数用于停止处理后续变更
+func Watch(onReload func(*ReloadReport, error)) (func(), error) {
+	path, err := GetAbsConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("配置文件不可用: %w", err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	debounce := time.Duration(Current().Reload.DebounceMs) * time.Millisecond
+	var (
+		mu      sync.Mutex
+		timer   *time.Timer
+		stopped bool
+	)
+	// 编辑器保存文件时可能触发多次事件，合并为一次重新加载
+	v.OnConfigChange(func(fsnotify.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(debounce, func() {
+			report, err := Reload()
+			if onReload != nil {
+				onReload(report, err)
+			}
+		})
+	})
+	v.WatchConfig()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		if timer != nil {
+			timer.Stop()
+		}
+	}, nil
+}
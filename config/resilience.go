@@ -0,0 +1,65 @@
+package config
+
+import "fmt"
+
+// 内置的外部依赖名称
+const (
+	DependencySMTP      = "smtp"
+	DependencyLLM       = "llm"
+	DependencyEmbedding = "embedding"
+	DependencyMCP       = "mcp"
+	DependencyRedis     = "redis"
+)
+
+// DependencyPolicy 外部依赖的容错策略（重试、熔断、并发隔离）
+// 数值字段为0时使用默认值
+type DependencyPolicy struct {
+	// 依赖名称，如 smtp、llm、embedding、mcp、redis
+	Name string
+	// 最大重试次数，-1 表示不重试
+	MaxRetries int
+	// 首次重试前的等待时间（毫秒）
+	InitialDelayMs int
+	// 重试等待时间上限（毫秒）
+	MaxDelayMs int
+	// 连续失败多少次后熔断
+	FailureThreshold int
+	// 熔断后多久进入半开状态（秒）
+	OpenTimeoutSeconds int
+	// 半开状态下允许同时进行的探测请求数
+	HalfOpenProbes int
+	// 半开状态下连续成功多少次后恢复
+	SuccessThreshold int
+	// 最大并发调用数，0 表示不限制
+	MaxConcurrent int
+	// 并发已满时最长等待时间（毫秒），0 表示立即拒绝
+	MaxWaitMs int
+}
+
+// validateResilience 校验外部依赖容错配置
+func (c *AppConfig) validateResilience() error {
+	seen := make(map[string]bool)
+	for _, p := range c.Resilience.Dependencies {
+		if p.Name == "" {
+			return fmt.Errorf("依赖容错策略必须指定名称")
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("依赖容错策略名称重复: %s", p.Name)
+		}
+		seen[p.Name] = true
+
+		if p.MaxRetries < -1 {
+			return fmt.Errorf("依赖 '%s' 的最大重试次数无效: %d", p.Name, p.MaxRetries)
+		}
+		if p.InitialDelayMs < 0 || p.MaxDelayMs < 0 || p.MaxWaitMs < 0 || p.OpenTimeoutSeconds < 0 {
+			return fmt.Errorf("依赖 '%s' 的时间配置不能为负数", p.Name)
+		}
+		if p.MaxDelayMs > 0 && p.InitialDelayMs > p.MaxDelayMs {
+			return fmt.Errorf("依赖 '%s' 的初始重试间隔不能大于最大重试间隔", p.Name)
+		}
+		if p.FailureThreshold < 0 || p.HalfOpenProbes < 0 || p.SuccessThreshold < 0 || p.MaxConcurrent < 0 {
+			return fmt.Errorf("依赖 '%s' 的熔断和并发配置不能为负数", p.Name)
+		}
+	}
+	return nil
+}
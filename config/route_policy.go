@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RoutePolicy 路由的超时和重试策略
+// 通过Route（Gin路由模板或模板前缀）或Plugin匹配请求，数值字段为0时使用代码中的默认值
+type RoutePolicy struct {
+	// 策略名称，用于日志
+	Name string
+	// Gin路由模板，如 /api/v1/tools/:id/execute；也可以是模板前缀，如 /api/v1/tools，"/" 匹配所有路由
+	Route string
+	// HTTP方法，为空时匹配所有方法
+	Method string
+	// 插件名称，匹配该插件的所有路由
+	Plugin string
+	// 请求超时（毫秒），-1 表示不限制
+	TimeoutMs int
+	// 请求内出站依赖调用的最大重试次数，-1 表示不重试
+	MaxRetries int
+	// 首次重试前的等待时间（毫秒）
+	InitialDelayMs int
+	// 重试等待时间上限（毫秒）
+	MaxDelayMs int
+}
+
+// HasRetry 判断策略是否配置了重试参数
+func (p RoutePolicy) HasRetry() bool {
+	return p.MaxRetries != 0 || p.InitialDelayMs != 0 || p.MaxDelayMs != 0
+}
+
+// validateRoutePolicies 校验路由超时和重试策略
+func (c *AppConfig) validateRoutePolicies() error {
+	for _, p := range c.Routes.Policies {
+		if err := ValidateRoutePolicy(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateRoutePolicy 校验单个路由策略
+func ValidateRoutePolicy(p RoutePolicy) error {
+	name := p.Name
+	if name == "" {
+		name = p.Route + p.Plugin
+	}
+	if p.Route == "" && p.Plugin == "" {
+		return fmt.Errorf("路由策略必须指定 route 或 plugin")
+	}
+	if p.Route != "" && !strings.HasPrefix(p.Route, "/") {
+		return fmt.Errorf("路由策略的路由模板必须以斜杠开头: %s", p.Route)
+	}
+	switch strings.ToUpper(p.Method) {
+	case "", http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodHead, http.MethodOptions:
+	default:
+		return fmt.Errorf("路由策略 '%s' 的HTTP方法无效: %s", name, p.Method)
+	}
+	if p.TimeoutMs < -1 {
+		return fmt.Errorf("路由策略 '%s' 的超时时间无效: %d", name, p.TimeoutMs)
+	}
+	if p.MaxRetries < -1 {
+		return fmt.Errorf("路由策略 '%s' 的最大重试次数无效: %d", name, p.MaxRetries)
+	}
+	if p.InitialDelayMs < 0 || p.MaxDelayMs < 0 {
+		return fmt.Errorf("路由策略 '%s' 的重试间隔不能为负数", name)
+	}
+	if p.MaxDelayMs > 0 && p.InitialDelayMs > p.MaxDelayMs {
+		return fmt.Errorf("路由策略 '%s' 的初始重试间隔不能大于最大重试间隔", name)
+	}
+	return nil
+}
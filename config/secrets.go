@@ -0,0 +1,293 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// SecretKeyEnv 加密配置值使用的密钥（base64编码的32字节AES-256密钥）所在的环境变量
+const SecretKeyEnv = "WEAVE_SECRET_KEY"
+
+// SecretProvider 密钥提供者，解析配置值中 ${name:ref} 形式的引用
+// 新的后端（如Vault）实现该接口并通过 RegisterSecretProvider 注册
+type SecretProvider interface {
+	// Name 引用中使用的提供者名称
+	Name() string
+	// Resolve 返回引用对应的密钥值
+	Resolve(ref string) (string, error)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{}
+
+	// secretRefPattern 匹配 ${provider:ref}
+	secretRefPattern = regexp.MustCompile(`\$\{([A-Za-z][A-Za-z0-9_-]*):([^}]*)\}`)
+)
+
+func init() {
+	RegisterSecretProvider(envSecretProvider{})
+	RegisterSecretProvider(fileSecretProvider{})
+	RegisterSecretProvider(encryptedSecretProvider{})
+}
+
+// RegisterSecretProvider 注册密钥提供者，同名提供者会被替换
+func RegisterSecretProvider(p SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[p.Name()] = p
+}
+
+// HasSecretRef 判断配置值是否包含密钥引用
+func HasSecretRef(value string) bool {
+	return secretRefPattern.MatchString(value)
+}
+
+// ResolveSecretRefs 解析配置值中的所有密钥引用，不包含引用时原样返回
+func ResolveSecretRefs(value string) (string, error) {
+	resolved, _, err := resolveSecretRefs(value)
+	return resolved, err
+}
+
+// resolveSecretRefs 解析配置值中的所有密钥引用，同时返回解析出的密钥值
+func resolveSecretRefs(value string) (string, []string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil, nil
+	}
+	var (
+		secrets  []string
+		firstErr error
+	)
+	resolved := secretRefPattern.ReplaceAllStringFunc(value, func(match string) string {
+		m := secretRefPattern.FindStringSubmatch(match)
+		secretProvidersMu.RLock()
+		p, ok := secretProviders[m[1]]
+		secretProvidersMu.RUnlock()
+		if !ok {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("未知的密钥提供者: %s", m[1])
+			}
+			return match
+		}
+		secret, err := p.Resolve(m[2])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s 密钥引用解析失败: %w", m[1], err)
+			}
+			return match
+		}
+		secrets = append(secrets, secret)
+		return secret
+	})
+	if firstErr != nil {
+		return "", nil, firstErr
+	}
+	return resolved, secrets, nil
+}
+
+// resolveSecrets 解析配置中所有字符串字段的密钥引用，并记录解析出的密钥用于脱敏
+func (c *AppConfig) resolveSecrets() error {
+	c.secretValues = nil
+	return c.walkStrings(reflect.ValueOf(c).Elem(), "", func(path string, v reflect.Value) error {
+		resolved, secrets, err := resolveSecretRefs(v.String())
+		if err != nil {
+			return fmt.Errorf("解析配置项 %s 失败: %w", path, err)
+		}
+		if len(secrets) > 0 {
+			v.SetString(resolved)
+			if c.secretValues == nil {
+				c.secretValues = make(map[string][]string)
+			}
+			c.secretValues[path] = secrets
+		}
+		return nil
+	})
+}
+
+// allSecretValues 返回所有解析出的密钥值
+func (c *AppConfig) allSecretValues() []string {
+	var all []string
+	for _, secrets := range c.secretValues {
+		all = append(all, secrets...)
+	}
+	return all
+}
+
+// walkStrings 遍历结构体、切片中所有可设置的字符串字段
+func (c *AppConfig) walkStrings(v reflect.Value, path string, fn func(path string, v reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Field(i).CanSet() {
+				continue
+			}
+			name := t.Field(i).Name
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := c.walkStrings(v.Field(i), name, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := c.walkStrings(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if v.CanSet() {
+			return fn(path, v)
+		}
+	}
+	return nil
+}
+
+// maskedCopy 返回将所有解析出的密钥替换为 *** 的配置副本，切片会被复制，不影响原配置
+func (c *AppConfig) maskedCopy() *AppConfig {
+	cp := *c
+	secrets := c.allSecretValues()
+	cloneSlices(reflect.ValueOf(&cp).Elem())
+	_ = cp.walkStrings(reflect.ValueOf(&cp).Elem(), "", func(_ string, v reflect.Value) error {
+		v.SetString(maskSecrets(v.String(), secrets))
+		return nil
+	})
+	return &cp
+}
+
+// cloneSlices 复制结构体中的切片，避免修改副本时影响原值
+func cloneSlices(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				cloneSlices(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(cp, v)
+		v.Set(cp)
+		for i := 0; i < v.Len(); i++ {
+			cloneSlices(v.Index(i))
+		}
+	}
+}
+
+// maskSecrets 将值中出现的密钥替换为 ***；过短的密钥只在整个值相等时替换，避免误伤普通字符
+func maskSecrets(value string, secrets []string) string {
+	for _, s := range secrets {
+		if s == "" {
+			continue
+		}
+		if value == s {
+			return "***"
+		}
+		if len(s) >= 4 {
+			value = strings.ReplaceAll(value, s, "***")
+		}
+	}
+	return value
+}
+
+// envSecretProvider 从环境变量读取：${env:NAME}
+type envSecretProvider struct{}
+
+func (envSecretProvider) Name() string { return "env" }
+
+func (envSecretProvider) Resolve(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("环境变量 %s 未设置", ref)
+	}
+	return value, nil
+}
+
+// fileSecretProvider 从文件读取（如Docker/Kubernetes挂载的secret）：${file:/run/secrets/db}
+type fileSecretProvider struct{}
+
+func (fileSecretProvider) Name() string { return "file" }
+
+func (fileSecretProvider) Resolve(ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// encryptedSecretProvider 解密静态加密的值：${enc:base64(nonce+密文)}，密钥来自 WEAVE_SECRET_KEY
+type encryptedSecretProvider struct{}
+
+func (encryptedSecretProvider) Name() string { return "enc" }
+
+func (encryptedSecretProvider) Resolve(ref string) (string, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(ref)
+	if err != nil {
+		return "", fmt.Errorf("密文不是有效的base64: %w", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("密文长度无效")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败，请检查 %s: %w", SecretKeyEnv, err)
+	}
+	return string(plain), nil
+}
+
+// EncryptSecret 使用 WEAVE_SECRET_KEY 加密配置值，返回可直接写入配置文件的 ${enc:...} 引用
+func EncryptSecret(plaintext string) (string, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return "${enc:" + base64.StdEncoding.EncodeToString(sealed) + "}", nil
+}
+
+// secretCipher 根据 WEAVE_SECRET_KEY 创建AES-GCM
+func secretCipher() (cipher.AEAD, error) {
+	encoded := os.Getenv(SecretKeyEnv)
+	if encoded == "" {
+		return nil, fmt.Errorf("%s 未设置", SecretKeyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s 必须是base64编码的32字节密钥", SecretKeyEnv)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GenerateSecretKey 生成可用于 WEAVE_SECRET_KEY 的随机密钥
+func GenerateSecretKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SLO类型
+const (
+	SLOTypeAvailability = "availability" // 可用性：5xx视为失败
+	SLOTypeLatency      = "latency"      // 延迟：超过阈值视为失败
+)
+
+// DefaultSLOWindow 默认的错误预算窗口
+const DefaultSLOWindow = "30d"
+
+// latencyBuckets 延迟阈值必须与直方图桶边界一致（pkg/metrics 使用 prometheus.DefBuckets）
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// SLOObjective 服务等级目标定义，作用于一个路由组或一个插件
+type SLOObjective struct {
+	// 名称（唯一，用作规则和指标标签）
+	Name string
+	// 描述
+	Description string
+	// 类型：availability 或 latency
+	Type string
+	// 路由组前缀，如 /api/v1/users（与Plugin二选一）
+	RouteGroup string
+	// 插件名称（与RouteGroup二选一）
+	Plugin string
+	// 目标百分比，如 99.9
+	Objective float64
+	// 延迟阈值（秒），仅latency类型使用，必须是直方图桶边界
+	LatencyThreshold float64
+	// 错误预算窗口，如 30d、7d、24h
+	Window string
+}
+
+// WindowDuration 解析错误预算窗口，支持 d（天）后缀
+func (o SLOObjective) WindowDuration() (time.Duration, error) {
+	window := o.Window
+	if window == "" {
+		window = DefaultSLOWindow
+	}
+	if strings.HasSuffix(window, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(window, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("无效的SLO窗口: %s", window)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("无效的SLO窗口: %s", window)
+	}
+	return d, nil
+}
+
+// ErrorBudget 返回允许的失败比例，如目标99.9%对应0.001
+func (o SLOObjective) ErrorBudget() float64 {
+	// 四舍五入以消除浮点误差（如 1-0.999 = 0.0010000000000000009）
+	return math.Round((1-o.Objective/100)*1e9) / 1e9
+}
+
+// validateSLOs 校验SLO定义
+func validateSLOs(objectives []SLOObjective) error {
+	names := make(map[string]bool, len(objectives))
+	for _, o := range objectives {
+		if o.Name == "" {
+			return fmt.Errorf("SLO名称不能为空")
+		}
+		if names[o.Name] {
+			return fmt.Errorf("SLO名称重复: %s", o.Name)
+		}
+		names[o.Name] = true
+
+		if (o.RouteGroup == "") == (o.Plugin == "") {
+			return fmt.Errorf("SLO '%s' 必须且只能指定 routeGroup 或 plugin 之一", o.Name)
+		}
+		if o.RouteGroup != "" && !strings.HasPrefix(o.RouteGroup, "/") {
+			return fmt.Errorf("SLO '%s' 的路由组必须以斜杠开头: %s", o.Name, o.RouteGroup)
+		}
+		if o.Objective <= 0 || o.Objective >= 100 {
+			return fmt.Errorf("SLO '%s' 的目标无效: %v，必须在0到100之间（不含）", o.Name, o.Objective)
+		}
+		if _, err := o.WindowDuration(); err != nil {
+			return fmt.Errorf("SLO '%s': %w", o.Name, err)
+		}
+
+		switch o.Type {
+		case SLOTypeAvailability:
+		case SLOTypeLatency:
+			if !isLatencyBucket(o.LatencyThreshold) {
+				return fmt.Errorf("SLO '%s' 的延迟阈值无效: %v，必须是直方图桶边界之一: %v", o.Name, o.LatencyThreshold, latencyBuckets)
+			}
+		default:
+			return fmt.Errorf("SLO '%s' 的类型无效: %s，有效值为: availability, latency", o.Name, o.Type)
+		}
+	}
+	return nil
+}
+
+// isLatencyBucket 判断阈值是否为直方图桶边界
+func isLatencyBucket(threshold float64) bool {
+	for _, b := range latencyBuckets {
+		if b == threshold {
+			return true
+		}
+	}
+	return false
+}
@@ -47,8 +47,8 @@ func (ac *AuditController) GetAuditLogs(c *gin.Context) {
 	tenantID := c.GetUint("tenant_id")
 	result, err := ac.auditService.GetAuditLogs(c.Request.Context(), tenantID, filter)
 	if err != nil {
-		dbErr := pkg.NewDatabaseError("Failed to fetch audit logs", err)
-		c.JSON(pkg.GetHTTPStatus(dbErr), gin.H{"code": string(dbErr.Code), "message": dbErr.Message})
+		dbErr := pkg.NewDatabaseError("获取审计日志失败", err)
+		pkg.RespondError(c, dbErr)
 		return
 	}
 
@@ -68,8 +68,8 @@ func (ac *AuditController) GetAuditLog(c *gin.Context) {
 
 	auditLog, err := ac.auditService.GetAuditLog(c.Request.Context(), id, tenantID)
 	if err != nil {
-		appErr := pkg.NewNotFoundError("Audit log not found", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewNotFoundError("审计日志不存在", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -82,8 +82,8 @@ func (ac *AuditController) GetAuditStats(c *gin.Context) {
 
 	stats, err := ac.auditService.GetAuditStats(c.Request.Context(), tenantID)
 	if err != nil {
-		dbErr := pkg.NewDatabaseError("Failed to get audit stats", err)
-		c.JSON(pkg.GetHTTPStatus(dbErr), gin.H{"code": string(dbErr.Code), "message": dbErr.Message})
+		dbErr := pkg.NewDatabaseError("获取审计统计失败", err)
+		pkg.RespondError(c, dbErr)
 		return
 	}
 
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"net/http"
+
+	"weave/config"
+	"weave/pkg"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ConfigController 配置运维控制器
+type ConfigController struct{}
+
+// NewConfigController 创建配置运维控制器实例
+func NewConfigController() *ConfigController {
+	return &ConfigController{}
+}
+
+// ReloadConfig 重新加载配置
+// @Summary 重新加载配置
+// @Description 重新读取配置文件和环境变量，校验通过后替换当前配置并通知订阅的子系统；校验失败时保持当前配置不变
+// @Tags 运维
+// @Security BearerAuth
+// @Success 200 {object} config.ReloadReport
+// @Failure 403 {object} pkg.Problem
+// @Failure 422 {object} pkg.Problem
+// @Router /api/v1/admin/config/reload [post]
+func (cc *ConfigController) ReloadConfig(c *gin.Context) {
+	report, err := config.Reload()
+	if err != nil {
+		pkg.LoggerFromContext(c.Request.Context()).Warn("Configuration reload rejected", zap.Error(err))
+		// 校验错误可能包含配置路径和文件内容，只记录到日志，不返回给客户端
+		pkg.RespondError(c, pkg.NewUnprocessableEntity("配置未通过校验，未重新加载，详情见服务日志", err))
+		return
+	}
+	pkg.LoggerFromContext(c.Request.Context()).Info("Configuration reloaded",
+		zap.Int("changes", len(report.Changes)),
+		zap.Strings("restart_required", report.RestartRequired))
+	c.JSON(http.StatusOK, report)
+}
+
+// DiffConfig 预览配置变更
+// @Summary 预览配置变更
+// @Description 比较配置文件和环境变量中的配置与当前生效配置的差异，不应用变更；敏感字段已脱敏
+// @Tags 运维
+// @Security BearerAuth
+// @Success 200 {object} config.ReloadReport
+// @Failure 403 {object} pkg.Problem
+// @Failure 422 {object} pkg.Problem
+// @Router /api/v1/admin/config/diff [get]
+func (cc *ConfigController) DiffConfig(c *gin.Context) {
+	report, err := config.PreviewReload()
+	if err != nil {
+		pkg.LoggerFromContext(c.Request.Context()).Warn("Configuration preview failed", zap.Error(err))
+		pkg.RespondError(c, pkg.NewUnprocessableEntity("配置未通过校验，详情见服务日志", err))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"weave/config"
+	"weave/pkg"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 慢查询列表默认和最大返回条数
+const (
+	defaultSlowQueryLimit = 10
+	maxSlowQueryLimit     = 100
+)
+
+// DatabaseController 数据库运维控制器
+type DatabaseController struct{}
+
+// NewDatabaseController 创建数据库运维控制器实例
+func NewDatabaseController() *DatabaseController {
+	return &DatabaseController{}
+}
+
+// GetSlowQueries 获取累计耗时最高的慢查询指纹
+// @Summary 获取慢查询排行
+// @Description 按累计耗时返回前N个慢查询指纹（SQL已去除参数），limit默认10，最大100
+// @Tags 监控
+// @Security BearerAuth
+// @Param limit query int false "返回条数"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} pkg.Problem
+// @Router /api/v1/admin/slow-queries [get]
+func (dc *DatabaseController) GetSlowQueries(c *gin.Context) {
+	limit := defaultSlowQueryLimit
+	if val := c.Query("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			pkg.RespondError(c, pkg.NewValidationRangeError("limit必须为正整数", err).
+				WithFields(pkg.FieldError{Field: "limit", Rule: "gt", Param: "0"}))
+			return
+		}
+		limit = min(n, maxSlowQueryLimit)
+	}
+
+	queries := pkg.TopSlowQueries(limit)
+	c.JSON(http.StatusOK, gin.H{
+		"thresholdMs": config.Current().Database.SlowQueryThresholdMs,
+		"queries":     queries,
+		"total":       len(queries),
+	})
+}
+
+// ResetSlowQueries 清空慢查询统计
+// @Summary 清空慢查询统计
+// @Tags 监控
+// @Security BearerAuth
+// @Success 204
+// @Failure 403 {object} pkg.Problem
+// @Router /api/v1/admin/slow-queries [delete]
+func (dc *DatabaseController) ResetSlowQueries(c *gin.Context) {
+	pkg.ResetSlowQueries()
+	c.Status(http.StatusNoContent)
+}
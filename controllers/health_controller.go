@@ -2,11 +2,15 @@ package controllers
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	"weave/config"
 	"weave/pkg"
+	"weave/pkg/healthcheck"
+	"weave/pkg/lifecycle"
 	"weave/pkg/metrics"
+	"weave/pkg/resilience"
 	"weave/plugins"
 	"weave/plugins/core"
 	healthsvc "weave/services/health"
@@ -34,7 +38,7 @@ func (hc *HealthController) GetHealth(c *gin.Context) {
 	result := gin.H{
 		"status":      "ok",
 		"timestamp":   time.Now().Unix(),
-		"instance_id": config.Config.Server.InstanceID,
+		"instance_id": config.Current().Server.InstanceID,
 	}
 
 	// 检查数据库连接健康状态
@@ -45,6 +49,9 @@ func (hc *HealthController) GetHealth(c *gin.Context) {
 	pluginHealth := checkPluginHealth()
 	result["plugins"] = pluginHealth
 
+	// 外部依赖熔断状态：熔断只影响部分功能，仅在结果中体现，不改变整体状态码
+	result["dependencies"] = checkDependencyHealth()
+
 	// 检查整体系统健康状态
 	overallStatus := "ok"
 	if !dbHealth["healthy"].(bool) {
@@ -58,6 +65,11 @@ func (hc *HealthController) GetHealth(c *gin.Context) {
 		}
 	}
 
+	// 优雅关闭期间返回失败，便于负载均衡在停止接收请求前摘除实例
+	if lifecycle.Default.Draining() {
+		overallStatus = "draining"
+	}
+
 	result["status"] = overallStatus
 
 	// 根据整体状态设置HTTP状态码
@@ -65,7 +77,7 @@ func (hc *HealthController) GetHealth(c *gin.Context) {
 	if overallStatus != "ok" {
 		statusCode = 503
 		// 使用统一错误码系统返回服务不可用错误
-		serviceErr := pkg.NewServiceUnavailableError("System health is degraded", nil)
+		serviceErr := pkg.NewServiceUnavailableError("系统健康状态降级", nil)
 		serviceErr.WithDetails(map[string]interface{}{
 			"database_healthy": dbHealth["healthy"].(bool),
 			"plugin_count":     pluginHealth["pluginCount"].(int),
@@ -98,13 +110,64 @@ func (hc *HealthController) GetHealth(c *gin.Context) {
 	c.JSON(statusCode, result)
 }
 
+// Livez 存活探针：只执行参与存活探针的检查，不检查外部依赖，优雅关闭期间仍然返回成功
+func (hc *HealthController) Livez(c *gin.Context) {
+	hc.respondProbe(c, hc.healthService.RunProbe(c.Request.Context(), healthcheck.Liveness))
+}
+
+// Readyz 就绪探针：启动完成前、优雅关闭期间或关键检查失败时返回503，非关键检查失败时返回degraded
+func (hc *HealthController) Readyz(c *gin.Context) {
+	report := hc.healthService.RunProbe(c.Request.Context(), healthcheck.Readiness)
+	switch {
+	case lifecycle.Default.Draining():
+		report.Fail("draining")
+	case !lifecycle.Default.Ready():
+		report.Fail("starting")
+	}
+	hc.respondProbe(c, report)
+}
+
+// Startupz 启动探针：组件全部启动且启动检查（如后台迁移）通过后返回成功
+func (hc *HealthController) Startupz(c *gin.Context) {
+	report := hc.healthService.RunProbe(c.Request.Context(), healthcheck.Startup)
+	if !lifecycle.Default.Started() {
+		report.Fail("starting")
+	}
+	hc.respondProbe(c, report)
+}
+
+// respondProbe 返回探针结果，带 ?verbose 参数时列出每项检查的结果
+func (hc *HealthController) respondProbe(c *gin.Context, report *healthcheck.Report) {
+	for _, failed := range report.Failed() {
+		// 缓存的结果已在首次失败时记录
+		if failed.Cached {
+			continue
+		}
+		pkg.Warn("Health check failed",
+			zap.String("probe", string(report.Probe)),
+			zap.String("check", failed.Name),
+			zap.Bool("critical", failed.Critical),
+			zap.String("error", failed.Error))
+	}
+
+	if v, ok := c.GetQuery("verbose"); !ok || v == "false" || v == "0" {
+		report.Checks = nil
+	}
+
+	statusCode := http.StatusOK
+	if !report.Healthy() {
+		statusCode = http.StatusServiceUnavailable
+	}
+	c.JSON(statusCode, report)
+}
+
 // checkDatabaseHealth 检查数据库连接健康状态
 func (hc *HealthController) checkDatabaseHealth(c *gin.Context) gin.H {
 	dbResult := hc.healthService.CheckDatabase(c.Request.Context())
 
 	if !dbResult.Healthy {
 		// 使用统一错误码系统创建数据库错误
-		dbErr := pkg.NewDatabaseError("Database health check failed", nil)
+		dbErr := pkg.NewDatabaseError("数据库健康检查失败", nil)
 		dbErr.WithDetails(map[string]interface{}{
 			"query": "SELECT 1",
 		})
@@ -116,10 +179,24 @@ func (hc *HealthController) checkDatabaseHealth(c *gin.Context) gin.H {
 		}
 	}
 
-	return gin.H{
+	result := gin.H{
 		"healthy":      true,
 		"responseTime": dbResult.ResponseTime,
 	}
+
+	// 只读副本状态：副本故障时读请求会切换到其他副本，仅在结果中体现，不影响整体状态
+	if replicas := hc.healthService.CheckReplicas(c.Request.Context()); len(replicas) > 0 {
+		replicasHealthy := true
+		for _, r := range replicas {
+			if !r.Healthy {
+				replicasHealthy = false
+				break
+			}
+		}
+		result["replicas"] = replicas
+		result["replicasHealthy"] = replicasHealthy
+	}
+	return result
 }
 
 // PluginHealthCheck 检查指定插件的健康状态
@@ -140,10 +217,7 @@ func (hc *HealthController) PluginHealthCheck(c *gin.Context) {
 
 	if targetPluginInfo == nil {
 		metrics.RecordPluginError(pluginName, "health_check_not_found")
-		c.JSON(404, gin.H{
-			"status":  "error",
-			"message": "插件不存在",
-		})
+		pkg.RespondError(c, pluginNotFoundError(pluginName))
 		return
 	}
 
@@ -175,6 +249,23 @@ func (hc *HealthController) PluginHealthCheck(c *gin.Context) {
 	})
 }
 
+// checkDependencyHealth 汇总外部依赖（SMTP、LLM、MCP、Redis等）的熔断器状态
+func checkDependencyHealth() gin.H {
+	snapshots := resilience.Snapshots()
+	open := []string{}
+	for _, s := range snapshots {
+		if s.State != resilience.StateClosed.String() {
+			open = append(open, s.Name)
+		}
+	}
+
+	return gin.H{
+		"healthy":      len(open) == 0,
+		"openBreakers": open,
+		"breakers":     snapshots,
+	}
+}
+
 // checkPluginHealth 检查插件系统健康状态
 func checkPluginHealth() gin.H {
 	pluginStatuses := []gin.H{}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"net/http"
+
+	"weave/pkg"
+	"weave/pkg/slo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SLOController SLO控制器
+type SLOController struct {
+	tracker *slo.Tracker
+}
+
+// NewSLOController 创建SLO控制器实例
+func NewSLOController(tracker *slo.Tracker) *SLOController {
+	return &SLOController{tracker: tracker}
+}
+
+// GetSLOStatus 获取SLO错误预算状态
+// @Summary 获取SLO错误预算状态
+// @Description 根据进程内指标计算各SLO在窗口内的SLI、错误预算剩余和燃烧率
+// @Tags 监控
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/slo [get]
+func (sc *SLOController) GetSLOStatus(c *gin.Context) {
+	statuses, err := sc.tracker.Status()
+	if err != nil {
+		pkg.RespondError(c, pkg.NewInternalError("计算SLO状态失败", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"slos": statuses, "total": len(statuses)})
+}
+
+// GetSLORules 获取根据SLO生成的Prometheus规则
+// @Summary 获取SLO告警规则
+// @Description 返回根据SLO定义生成的多窗口燃烧率记录规则和告警规则（YAML）
+// @Tags 监控
+// @Security BearerAuth
+// @Success 200 {string} string
+// @Router /api/v1/slo/rules [get]
+func (sc *SLOController) GetSLORules(c *gin.Context) {
+	data, err := slo.RenderRules(sc.tracker.Objectives())
+	if err != nil {
+		pkg.RespondError(c, pkg.NewInternalError("生成SLO规则失败", err))
+		return
+	}
+
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
+}
@@ -28,16 +28,15 @@ func (tc *TeamController) UpdateTeam(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		err := pkg.NewValidationError("Invalid team data", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
 	teamIDStr := c.Param("id")
 	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
 	if err != nil {
-		err := pkg.NewValidationError("Team ID is required", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("缺少团队ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
@@ -46,8 +45,8 @@ func (tc *TeamController) UpdateTeam(c *gin.Context) {
 
 	team, err := tc.teamService.UpdateTeam(c.Request.Context(), uint(teamID), req.Name, req.Description, userID, tenantID)
 	if err != nil {
-		appErr := pkg.NewDatabaseError("Failed to update team", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("更新团队失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -69,8 +68,7 @@ func (tc *TeamController) CreateTeam(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		err := pkg.NewValidationError("Invalid team data", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
@@ -79,8 +77,8 @@ func (tc *TeamController) CreateTeam(c *gin.Context) {
 
 	team, err := tc.teamService.CreateTeam(c.Request.Context(), req.Name, req.Description, ownerID, tenantID)
 	if err != nil {
-		appErr := pkg.NewDatabaseError("Failed to create team", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("创建团队失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -99,8 +97,8 @@ func (tc *TeamController) GetTeamMembers(c *gin.Context) {
 	teamIDStr := c.Param("id")
 	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
 	if err != nil {
-		err := pkg.NewValidationError("Invalid team ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的团队ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
@@ -109,8 +107,8 @@ func (tc *TeamController) GetTeamMembers(c *gin.Context) {
 
 	members, err := tc.teamService.GetTeamMembers(c.Request.Context(), uint(teamID), userID, tenantID)
 	if err != nil {
-		appErr := pkg.NewDatabaseError("Failed to query team members", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("查询团队成员失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -122,8 +120,8 @@ func (tc *TeamController) AddTeamMember(c *gin.Context) {
 	teamIDStr := c.Param("id")
 	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
 	if err != nil {
-		err := pkg.NewValidationError("Invalid team ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的团队ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
@@ -133,8 +131,7 @@ func (tc *TeamController) AddTeamMember(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		err := pkg.NewValidationError("Invalid member data", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
@@ -143,8 +140,8 @@ func (tc *TeamController) AddTeamMember(c *gin.Context) {
 
 	newMember, err := tc.teamService.AddTeamMember(c.Request.Context(), uint(teamID), req.UserID, req.Role, userID, tenantID)
 	if err != nil {
-		appErr := pkg.NewDatabaseError("Failed to add team member", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("添加团队成员失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -165,15 +162,15 @@ func (tc *TeamController) RemoveTeamMember(c *gin.Context) {
 
 	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
 	if err != nil {
-		err := pkg.NewValidationError("Invalid team ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的团队ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	memberID, err := strconv.ParseUint(memberIDStr, 10, 32)
 	if err != nil {
-		err := pkg.NewValidationError("Invalid member ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的成员ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
@@ -181,8 +178,8 @@ func (tc *TeamController) RemoveTeamMember(c *gin.Context) {
 	tenantID := c.GetUint("tenant_id")
 
 	if err := tc.teamService.RemoveTeamMember(c.Request.Context(), uint(teamID), uint(memberID), userID, tenantID); err != nil {
-		appErr := pkg.NewDatabaseError("Failed to remove team member", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("移除团队成员失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -203,8 +200,8 @@ func (tc *TeamController) GetTeams(c *gin.Context) {
 
 	teams, err := tc.teamService.GetTeams(c.Request.Context(), userID, tenantID)
 	if err != nil {
-		appErr := pkg.NewDatabaseError("Failed to query teams", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("查询团队失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -216,8 +213,8 @@ func (tc *TeamController) TransferTeamOwner(c *gin.Context) {
 	teamIDStr := c.Param("id")
 	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
 	if err != nil {
-		err := pkg.NewValidationError("Invalid team ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的团队ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
@@ -226,8 +223,7 @@ func (tc *TeamController) TransferTeamOwner(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		err := pkg.NewValidationError("Invalid owner data", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
@@ -236,8 +232,8 @@ func (tc *TeamController) TransferTeamOwner(c *gin.Context) {
 
 	result, err := tc.teamService.TransferTeamOwner(c.Request.Context(), uint(teamID), req.NewOwnerID, userID, tenantID)
 	if err != nil {
-		appErr := pkg.NewDatabaseError("Failed to transfer team ownership", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("转移团队所有权失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -262,8 +258,8 @@ func (tc *TeamController) SearchTeamMembers(c *gin.Context) {
 	teamIDStr := c.Param("id")
 	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
 	if err != nil {
-		err := pkg.NewValidationError("Invalid team ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的团队ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
@@ -272,15 +268,15 @@ func (tc *TeamController) SearchTeamMembers(c *gin.Context) {
 
 	keyword := c.Query("keyword")
 	if keyword == "" {
-		err := pkg.NewValidationError("Search keyword is required", nil)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("缺少搜索关键字", nil)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	members, err := tc.teamService.SearchTeamMembers(c.Request.Context(), uint(teamID), userID, tenantID, keyword)
 	if err != nil {
-		appErr := pkg.NewDatabaseError("Failed to search team members", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("搜索团队成员失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -294,15 +290,15 @@ func (tc *TeamController) UpdateMemberRole(c *gin.Context) {
 
 	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
 	if err != nil {
-		err := pkg.NewValidationError("Invalid team ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的团队ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	memberID, err := strconv.ParseUint(memberIDStr, 10, 32)
 	if err != nil {
-		err := pkg.NewValidationError("Invalid member ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的成员ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
@@ -311,8 +307,7 @@ func (tc *TeamController) UpdateMemberRole(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		err := pkg.NewValidationError("Invalid role data", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
@@ -321,8 +316,8 @@ func (tc *TeamController) UpdateMemberRole(c *gin.Context) {
 
 	member, err := tc.teamService.UpdateMemberRole(c.Request.Context(), uint(teamID), uint(memberID), req.Role, userID, tenantID)
 	if err != nil {
-		appErr := pkg.NewDatabaseError("Failed to update member role", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("更新成员角色失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -25,8 +25,8 @@ func (tc *ToolController) GetTools(c *gin.Context) {
 	tenantID := c.GetUint("tenant_id")
 	tools, err := tc.toolService.GetTools(c.Request.Context(), tenantID)
 	if err != nil {
-		dbErr := pkg.NewDatabaseError("Failed to fetch tools", err)
-		c.JSON(pkg.GetHTTPStatus(dbErr), gin.H{"code": string(dbErr.Code), "message": dbErr.Message})
+		dbErr := pkg.NewDatabaseError("获取工具列表失败", err)
+		pkg.RespondError(c, dbErr)
 		return
 	}
 	c.JSON(http.StatusOK, tools)
@@ -39,8 +39,8 @@ func (tc *ToolController) GetTool(c *gin.Context) {
 
 	tool, err := tc.toolService.GetTool(c.Request.Context(), id, tenantID)
 	if err != nil {
-		appErr := pkg.NewNotFoundError("Tool not found", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewNotFoundError("工具不存在", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -51,16 +51,15 @@ func (tc *ToolController) GetTool(c *gin.Context) {
 func (tc *ToolController) CreateTool(c *gin.Context) {
 	var tool models.Tool
 	if err := c.ShouldBindJSON(&tool); err != nil {
-		appErr := pkg.NewValidationError("Invalid tool data", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
 	tool.TenantID = c.GetUint("tenant_id")
 
 	if err := tc.toolService.CreateTool(c.Request.Context(), &tool); err != nil {
-		dbErr := pkg.NewDatabaseError("Failed to create tool", err)
-		c.JSON(pkg.GetHTTPStatus(dbErr), gin.H{"code": string(dbErr.Code), "message": dbErr.Message})
+		dbErr := pkg.NewDatabaseError("创建工具失败", err)
+		pkg.RespondError(c, dbErr)
 		return
 	}
 
@@ -74,15 +73,14 @@ func (tc *ToolController) UpdateTool(c *gin.Context) {
 
 	var newTool models.Tool
 	if err := c.ShouldBindJSON(&newTool); err != nil {
-		appErr := pkg.NewValidationError("Invalid tool data", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
 	updated, err := tc.toolService.UpdateTool(c.Request.Context(), id, tenantID, &newTool)
 	if err != nil {
-		appErr := pkg.NewNotFoundError("Tool not found", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewNotFoundError("工具不存在", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -95,8 +93,8 @@ func (tc *ToolController) DeleteTool(c *gin.Context) {
 	tenantID := c.GetUint("tenant_id")
 
 	if err := tc.toolService.DeleteTool(c.Request.Context(), id, tenantID); err != nil {
-		appErr := pkg.NewNotFoundError("Tool not found", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewNotFoundError("工具不存在", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -110,8 +108,8 @@ func (tc *ToolController) ExecuteTool(c *gin.Context) {
 
 	tool, err := tc.toolService.GetTool(c.Request.Context(), id, tenantID)
 	if err != nil {
-		appErr := pkg.NewNotFoundError("Tool not found", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewNotFoundError("工具不存在", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
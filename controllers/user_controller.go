@@ -29,21 +29,20 @@ func NewUserController(userSvc usersvc.UserService) *UserController {
 func (uc *UserController) Register(c *gin.Context) {
 	var registerRequest usersvc.RegisterRequest
 	if err := c.ShouldBindJSON(&registerRequest); err != nil {
-		err := pkg.NewValidationError("Invalid registration data", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
 	if registerRequest.Password != registerRequest.ConfirmPassword {
-		err := pkg.NewValidationError("Passwords do not match", nil)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("两次输入的密码不一致", nil)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	newUser, err := uc.userService.Register(c.Request.Context(), registerRequest)
 	if err != nil {
 		appErr := pkg.NewConflictError(err.Error(), nil)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -66,8 +65,7 @@ type LoginWithCodeRequest struct {
 func (uc *UserController) SendVerificationCode(c *gin.Context) {
 	var req SendVerificationCodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		err := pkg.NewValidationError("请输入有效的用户名", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
@@ -76,7 +74,7 @@ func (uc *UserController) SendVerificationCode(c *gin.Context) {
 	user, err := uc.userService.SendVerificationCode(c.Request.Context(), req.Username, tenantID)
 	if err != nil {
 		appErr := pkg.NewValidationError(err.Error(), nil)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -99,8 +97,7 @@ func (uc *UserController) LoginWithVerificationCode(c *gin.Context) {
 	var req LoginWithCodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		uc.userService.RecordLoginHistory(c.Request.Context(), req.Email, c.ClientIP(), c.Request.UserAgent(), "请求参数验证失败: "+err.Error(), false, 0)
-		err := pkg.NewValidationError("Invalid request data", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
@@ -110,23 +107,23 @@ func (uc *UserController) LoginWithVerificationCode(c *gin.Context) {
 	if err != nil {
 		uc.userService.RecordLoginHistory(c.Request.Context(), req.Email, c.ClientIP(), c.Request.UserAgent(), "验证码验证失败: "+err.Error(), false, tenantID)
 		appErr := pkg.NewAuthError("验证码错误或已过期", nil)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		pkg.RespondError(c, appErr)
 		return
 	}
 
 	accessToken, err := utils.GenerateToken(user.ID, user.TenantID)
 	if err != nil {
 		uc.userService.RecordLoginHistory(c.Request.Context(), req.Email, c.ClientIP(), c.Request.UserAgent(), "生成访问令牌失败: "+err.Error(), false, user.TenantID)
-		err := pkg.NewInternalError("Failed to generate access token", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewInternalError("生成访问令牌失败", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.TenantID)
 	if err != nil {
 		uc.userService.RecordLoginHistory(c.Request.Context(), req.Email, c.ClientIP(), c.Request.UserAgent(), "生成刷新令牌失败: "+err.Error(), false, user.TenantID)
-		err := pkg.NewInternalError("Failed to generate refresh token", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewInternalError("生成刷新令牌失败", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
@@ -158,8 +155,7 @@ func (uc *UserController) Login(c *gin.Context) {
 
 	if err := c.ShouldBindJSON(&loginRequest); err != nil {
 		uc.userService.RecordLoginHistory(c.Request.Context(), loginRequest.Username, c.ClientIP(), c.Request.UserAgent(), "请求参数验证失败: "+err.Error(), false, 0)
-		err := pkg.NewValidationError("请输入用户名、密码和验证码", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
@@ -173,23 +169,23 @@ func (uc *UserController) Login(c *gin.Context) {
 	if err != nil {
 		uc.userService.RecordLoginHistory(c.Request.Context(), loginRequest.Username, c.ClientIP(), c.Request.UserAgent(), err.Error(), false, tenantID)
 		appErr := pkg.NewAuthError(err.Error(), nil)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		pkg.RespondError(c, appErr)
 		return
 	}
 
 	accessToken, err := utils.GenerateToken(user.ID, user.TenantID)
 	if err != nil {
 		uc.userService.RecordLoginHistory(c.Request.Context(), loginRequest.Username, c.ClientIP(), c.Request.UserAgent(), "生成访问令牌失败: "+err.Error(), false, user.TenantID)
-		err := pkg.NewInternalError("Failed to generate access token", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewInternalError("生成访问令牌失败", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.TenantID)
 	if err != nil {
 		uc.userService.RecordLoginHistory(c.Request.Context(), loginRequest.Username, c.ClientIP(), c.Request.UserAgent(), "生成刷新令牌失败: "+err.Error(), false, user.TenantID)
-		err := pkg.NewInternalError("Failed to generate refresh token", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewInternalError("生成刷新令牌失败", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
@@ -219,36 +215,35 @@ func (uc *UserController) RefreshToken(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&refreshRequest); err != nil {
-		err := pkg.NewValidationError("Refresh token is required", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
 	userID, tenantID, err := utils.VerifyRefreshToken(refreshRequest.RefreshToken)
 	if err != nil {
-		err := pkg.NewAuthError("Invalid refresh token", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewAuthError("无效的刷新令牌", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	user, err := uc.userService.RefreshToken(c.Request.Context(), refreshRequest.RefreshToken)
 	if err != nil {
-		appErr := pkg.NewNotFoundError("User not found", nil)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewNotFoundError("用户不存在", nil)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
 	accessToken, err := utils.GenerateToken(userID, tenantID)
 	if err != nil {
-		err := pkg.NewInternalError("Failed to generate access token", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewInternalError("生成访问令牌失败", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	newRefreshToken, err := utils.GenerateRefreshToken(userID, tenantID)
 	if err != nil {
-		err := pkg.NewInternalError("Failed to generate refresh token", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewInternalError("生成刷新令牌失败", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
@@ -262,8 +257,8 @@ func (uc *UserController) GetUsers(c *gin.Context) {
 
 	users, err := uc.userService.GetUsers(c.Request.Context(), tenantID)
 	if err != nil {
-		appErr := pkg.NewDatabaseError("Failed to fetch users", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("获取用户列表失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -277,15 +272,15 @@ func (uc *UserController) GetUser(c *gin.Context) {
 
 	var id uint
 	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
-		err := pkg.NewValidationError("Invalid user ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的用户ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	user, err := uc.userService.GetUser(c.Request.Context(), id, tenantID)
 	if err != nil {
-		appErr := pkg.NewNotFoundError("User not found", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewNotFoundError("用户不存在", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -296,8 +291,7 @@ func (uc *UserController) GetUser(c *gin.Context) {
 func (uc *UserController) CreateUser(c *gin.Context) {
 	var user models.User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		err := pkg.NewValidationError("Invalid user data", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
@@ -307,8 +301,8 @@ func (uc *UserController) CreateUser(c *gin.Context) {
 	logUser.Password = "[REDACTED]"
 
 	if err := uc.userService.CreateUser(c.Request.Context(), &user); err != nil {
-		appErr := pkg.NewDatabaseError("Failed to create user", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewDatabaseError("创建用户失败", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -331,22 +325,21 @@ func (uc *UserController) UpdateUser(c *gin.Context) {
 
 	var id uint
 	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
-		err := pkg.NewValidationError("Invalid user ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的用户ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	var newUser models.User
 	if err := c.ShouldBindJSON(&newUser); err != nil {
-		err := pkg.NewValidationError("Invalid user data", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
 	updated, err := uc.userService.UpdateUser(c.Request.Context(), id, tenantID, &newUser)
 	if err != nil {
-		appErr := pkg.NewNotFoundError("User not found", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewNotFoundError("用户不存在", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -370,15 +363,15 @@ func (uc *UserController) DeleteUser(c *gin.Context) {
 
 	var id uint
 	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
-		err := pkg.NewValidationError("Invalid user ID", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		err := pkg.NewValidationError("无效的用户ID", err)
+		pkg.RespondError(c, err)
 		return
 	}
 
 	deleted, err := uc.userService.DeleteUser(c.Request.Context(), id, tenantID)
 	if err != nil {
-		appErr := pkg.NewNotFoundError("User not found", err)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		appErr := pkg.NewNotFoundError("用户不存在", err)
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -408,14 +401,13 @@ func (uc *UserController) ChangePassword(c *gin.Context) {
 
 	var req ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		err := pkg.NewValidationError("Invalid request data", err)
-		c.JSON(pkg.GetHTTPStatus(err), gin.H{"code": string(err.Code), "message": err.Message})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
 	if err := uc.userService.ChangePassword(c.Request.Context(), currentUserID, tenantID, req.CurrentPassword, req.NewPassword); err != nil {
 		appErr := pkg.NewValidationError(err.Error(), nil)
-		c.JSON(pkg.GetHTTPStatus(appErr), gin.H{"code": string(appErr.Code), "message": appErr.Message})
+		pkg.RespondError(c, appErr)
 		return
 	}
 
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,9 +16,11 @@ import (
 	"weave/config"
 	"weave/controllers"
 	"weave/middleware"
-	"weave/models"
 	"weave/pkg"
+	"weave/pkg/lifecycle"
 	"weave/pkg/migrate/migration"
+	"weave/pkg/resilience"
+	"weave/pkg/slo"
 	"weave/plugins"
 	"weave/plugins/examples"
 	fc "weave/plugins/features/FormatConverter"
@@ -30,6 +34,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"golang.org/x/term"
 )
 
 func loadEnvFile(filePath string) {
@@ -78,6 +83,11 @@ func main() {
 	// 加载.env 配置文件
 	loadEnvFile(".env")
 
+	// weave secret <genkey|encrypt VALUE>：生成密钥或加密配置值后退出
+	if len(os.Args) > 1 && os.Args[1] == "secret" {
+		os.Exit(runSecretCommand(os.Args[2:]))
+	}
+
 	// 初始化日志系统
 	if err := pkg.InitLogger(pkg.Options{
 		Level:       config.Config.Logger.Level,
@@ -106,36 +116,52 @@ func main() {
 	}
 	pkg.Info("Configuration validation passed successfully")
 
+	// 日志系统先于配置初始化，按配置调整日志级别
+	if err := pkg.SetLevel(config.Config.Logger.Level); err != nil {
+		pkg.Warn("Failed to apply log level", zap.Error(err))
+	}
+
+	// 外部依赖的重试、熔断和并发隔离策略
+	resilience.Configure(config.Config.Resilience.Dependencies)
+
+	// 加载SLO定义，并按需生成Prometheus燃烧率规则文件
+	slo.DefaultTracker.SetObjectives(config.Config.SLO.Objectives)
+	if path := config.Config.SLO.RulesFile; path != "" && len(config.Config.SLO.Objectives) > 0 {
+		if err := slo.WriteRulesFile(path, config.Config.SLO.Objectives); err != nil {
+			pkg.Warn("Failed to write SLO rules file", zap.Error(err))
+		} else {
+			pkg.Info("SLO rules file generated", zap.String("path", path), zap.Int("objectives", len(config.Config.SLO.Objectives)))
+		}
+	}
+
 	// 初始化数据库（优化连接参数）
 	if err := pkg.InitDatabase(); err != nil {
 		pkg.Fatal("Failed to initialize database", zap.Error(err))
 	}
 	pkg.Info("Database initialized successfully")
 
-	// 数据库迁移（异步）
-	go func() {
-		if !config.Config.AutoMigrate {
-			pkg.Info("Starting SQL migrations...")
-			mm := migration.NewMigrationManager()
-			if err := mm.Init(); err != nil {
-				pkg.Warn("Failed to initialize migration manager", zap.Error(err))
-			} else {
-				if err := mm.Up(); err != nil {
-					pkg.Warn("Migration errors", zap.Error(err))
-				} else {
-					pkg.Info("SQL migrations completed successfully")
-				}
-			}
-		} else {
-			// 仅当启用自动迁移时才使用GORM自动迁移
-			pkg.Info("Starting GORM auto-migration...")
-			if err := models.MigrateTables(pkg.DB); err != nil {
-				pkg.Warn("Failed to migrate database tables", zap.Error(err))
-			} else {
-				pkg.Info("GORM auto-migration completed successfully")
-			}
+	// weave migrate <command>：执行迁移命令后退出
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migration.RunCLI(os.Args[2:], os.Stdout)
+		pkg.CloseDatabase()
+		if err != nil {
+			pkg.Fatal("Migration command failed", zap.Error(err))
 		}
-	}()
+		return
+	}
+
+	// 数据库迁移，按迁移策略阻塞、校验、后台执行或跳过
+	if err := migration.RunStartup(config.Config.Migration.Policy); err != nil {
+		pkg.Fatal("Database migration failed", zap.Error(err))
+	}
+
+	// 初始化限流存储和策略，Redis不可用时回退到进程内存储
+	if err := middleware.InitRateLimiting(); err != nil {
+		pkg.Warn("Failed to initialize distributed rate limiting, falling back to in-memory store", zap.Error(err))
+		middleware.SetRateLimitStore(middleware.NewMemoryRateLimitStore())
+	}
+	// 初始化配置文件中的路由超时和重试策略
+	middleware.InitRoutePolicies()
 
 	// 创建Service实例
 	userSvc := user.NewUserService(pkg.DB, user.EmailConfig{
@@ -160,10 +186,6 @@ func main() {
 	// 初始化路由
 	router := routers.SetupRouter(userCtrl, teamCtrl, auditCtrl, toolCtrl, healthCtrl, pluginCtrl)
 
-	// 添加错误处理中间件
-	errHandler := middleware.NewErrorHandler()
-	router.Use(errHandler.HandlerFunc())
-
 	// 注册插件
 	registerPlugins(router)
 
@@ -172,9 +194,13 @@ func main() {
 		pkg.Error("Failed to initialize plugin system", zap.Error(err))
 	}
 
+	// 配置热加载
+	stopConfigWatch := setupConfigReload()
+
 	// 启动服务器
 	port := config.Config.Server.Port
 	instanceID := config.Config.Server.InstanceID
+	shutdownTimeout := time.Duration(config.Config.Server.ShutdownTimeoutSeconds) * time.Second
 
 	// 创建HTTP服务器并配置连接复用参数
 	srv := &http.Server{
@@ -186,40 +212,211 @@ func main() {
 		MaxHeaderBytes: 1 << 20,          // 最大请求头大小（1MB）
 	}
 
-	go func() {
-		pkg.Info("Weave 服务启动成功",
-			zap.String("instance_id", instanceID),
-			zap.String("address", fmt.Sprintf("http://localhost:%d", port)))
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			pkg.Fatal("Failed to start server", zap.Error(err))
-		}
-	}()
-
-	// 等待中断信号优雅退出
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	pkg.Info("Shutting down server...")
 
-	// 停止插件监控器
-	plugins.PluginManager.StopPluginWatcher()
+	// 注册各组件的停止钩子并启动HTTP服务器
+	registerLifecycleHooks(srv, shutdownTimeout, stopConfigWatch, quit)
+	lifecycle.Default.SetDrainDelay(time.Duration(config.Config.Server.DrainDelaySeconds) * time.Second)
+	startCtx, cancelStart := context.WithTimeout(context.Background(), 30*time.Second)
+	err := lifecycle.Default.Start(startCtx)
+	cancelStart()
+	if err != nil {
+		pkg.Fatal("Failed to start server", zap.Error(err))
+	}
+	pkg.Info("Weave 服务启动成功",
+		zap.String("instance_id", instanceID),
+		zap.String("address", fmt.Sprintf("http://localhost:%d", port)))
+
+	// 等待中断信号优雅退出，关闭期间再次收到信号时立即退出
+	<-quit
+	pkg.Info("Shutting down server...",
+		zap.Duration("timeout", shutdownTimeout),
+		zap.Int("drain_delay_seconds", config.Config.Server.DrainDelaySeconds))
+	go func() {
+		<-quit
+		pkg.Fatal("Received second signal, forcing exit")
+	}()
 
-	// 创建超时上下文，用于优雅关闭服务器和数据库
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
+	logShutdownReport(lifecycle.Default.Shutdown(ctx))
 
-	// 先关闭HTTP服务器
-	if err := srv.Shutdown(ctx); err != nil {
-		pkg.Fatal("Server forced to shutdown", zap.Error(err))
+	pkg.Info("Server exiting")
+}
+
+// registerLifecycleHooks 注册各组件的生命周期钩子
+// 关闭顺序：HTTP服务器停止接收请求并等待进行中的请求 > 插件 > 定时任务和监控 > 刷新异步写入 > 数据库
+func registerLifecycleHooks(srv *http.Server, shutdownTimeout time.Duration, stopConfigWatch func(), quit chan<- os.Signal) {
+	lifecycle.Register(lifecycle.Hook{
+		Name:  "database",
+		Order: lifecycle.OrderStorage,
+		Stop:  pkg.CloseDatabaseWithContext,
+	})
+	lifecycle.Register(lifecycle.Hook{
+		Name:  "background_tasks",
+		Order: lifecycle.OrderWriters,
+		Stop: func(ctx context.Context) error {
+			if err := pkg.FlushBackground(ctx); err != nil {
+				return fmt.Errorf("%d 个后台任务未完成: %w", pkg.PendingBackground(), err)
+			}
+			return nil
+		},
+		StopTimeout: shutdownTimeout,
+	})
+	lifecycle.Register(lifecycle.Hook{
+		Name:  "rate_limiting",
+		Order: lifecycle.OrderBackground,
+		Stop:  func(context.Context) error { return middleware.CloseRateLimiting() },
+	})
+	lifecycle.Register(lifecycle.Hook{
+		Name:  "config_watch",
+		Order: lifecycle.OrderBackground,
+		Stop: func(context.Context) error {
+			stopConfigWatch()
+			return nil
+		},
+	})
+	lifecycle.Register(lifecycle.Hook{
+		Name:  "plugins",
+		Order: lifecycle.OrderPlugins,
+		Stop: func(ctx context.Context) error {
+			// 先停止监控器，避免关闭过程中触发插件重载
+			plugins.PluginManager.StopPluginWatcher()
+			return plugins.PluginManager.ShutdownAll(ctx)
+		},
+	})
+	lifecycle.Register(lifecycle.Hook{
+		Name:  "http_server",
+		Order: lifecycle.OrderServer,
+		Start: func(context.Context) error {
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+					pkg.Error("HTTP server stopped unexpectedly", zap.Error(err))
+					select {
+					case quit <- syscall.SIGTERM:
+					default:
+					}
+				}
+			}()
+			return nil
+		},
+		Stop:        srv.Shutdown,
+		StopTimeout: shutdownTimeout,
+	})
+}
+
+// logShutdownReport 输出优雅关闭报告
+func logShutdownReport(report *lifecycle.ShutdownReport) {
+	for _, h := range report.Hooks {
+		if h.Error != "" {
+			pkg.Warn("Component shutdown failed",
+				zap.String("component", h.Name),
+				zap.Duration("duration", h.Duration),
+				zap.Bool("timed_out", h.TimedOut),
+				zap.String("error", h.Error))
+			continue
+		}
+		pkg.Info("Component stopped", zap.String("component", h.Name), zap.Duration("duration", h.Duration))
 	}
+	pkg.Info("Shutdown report",
+		zap.Duration("drain_delay", report.DrainDelay),
+		zap.Duration("duration", report.Duration),
+		zap.Int("components", len(report.Hooks)),
+		zap.Int("failed", len(report.Failed())))
+}
 
-	// 然后使用相同上下文优雅关闭数据库连接
-	// 确保数据库连接在服务器停止接收新请求后有足够时间完成正在进行的操作
-	if err := pkg.CloseDatabaseWithContext(ctx); err != nil {
-		pkg.Error("Database shutdown error", zap.Error(err))
+// setupConfigReload 订阅可在运行时生效的配置项，并按配置监听配置文件变更
+// 限流策略和插件扫描间隔由对应子系统初始化时订阅
+func setupConfigReload() func() {
+	config.Subscribe("logger", func(_, c *config.AppConfig) {
+		if err := pkg.SetLevel(c.Logger.Level); err != nil {
+			pkg.Warn("Failed to apply log level", zap.Error(err))
+			return
+		}
+		pkg.Info("Log level updated", zap.String("level", c.Logger.Level))
+	}, "Logger.Level")
+	config.Subscribe("resilience", func(_, c *config.AppConfig) {
+		resilience.Configure(c.Resilience.Dependencies)
+		pkg.Info("Dependency policies updated", zap.Int("dependencies", len(c.Resilience.Dependencies)))
+	}, "Resilience")
+
+	if !config.Config.Reload.Watch {
+		return func() {}
 	}
+	stop, err := config.Watch(func(report *config.ReloadReport, err error) {
+		if err != nil {
+			pkg.Error("Configuration reload rejected", zap.Error(err))
+			return
+		}
+		pkg.Info("Configuration reloaded",
+			zap.Any("changes", report.Changes),
+			zap.Strings("restart_required", report.RestartRequired))
+	})
+	if err != nil {
+		pkg.Warn("Configuration file watching disabled", zap.Error(err))
+		return func() {}
+	}
+	pkg.Info("Watching configuration file for changes")
+	return stop
+}
 
-	pkg.Info("Server exiting")
+// runSecretCommand 处理 weave secret 子命令，返回进程退出码
+func runSecretCommand(args []string) int {
+	switch {
+	case len(args) == 1 && args[0] == "genkey":
+		key, err := config.GenerateSecretKey()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(key)
+	case len(args) == 1 && args[0] == "encrypt":
+		// 明文不通过命令行参数传入，避免出现在 shell 历史和进程列表中
+		plaintext, err := readSecretInput(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		ref, err := config.EncryptSecret(plaintext)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(ref)
+	default:
+		fmt.Fprintf(os.Stderr, "Usage: weave secret genkey | weave secret encrypt < secret-file (requires %s; prompts without echo on a terminal)\n", config.SecretKeyEnv)
+		return 2
+	}
+	return 0
+}
+
+// readSecretInput 读取要加密的明文：标准输入为终端时提示输入且不回显，否则读取全部输入并去掉末尾换行
+func readSecretInput(in *os.File) (string, error) {
+	var plaintext string
+	if fd := int(in.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Secret value: ")
+		data, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("读取密钥失败: %w", err)
+		}
+		plaintext = string(data)
+	} else {
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return "", fmt.Errorf("读取密钥失败: %w", err)
+		}
+		plaintext = strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	}
+	if plaintext == "" {
+		return "", fmt.Errorf("密钥不能为空")
+	}
+	return plaintext, nil
 }
 
 // 注册插件
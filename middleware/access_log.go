@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"weave/config"
+	"weave/pkg"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AccessLogConfig 访问日志配置
+type AccessLogConfig struct {
+	// SampleRate 成功请求（状态码<400且非慢请求）的采样率，取值0~1
+	SampleRate float64
+	// SlowThreshold 慢请求阈值，超过阈值的请求总是记录
+	SlowThreshold time.Duration
+	// SkipPaths 不记录访问日志的路径（如 /metrics）
+	SkipPaths []string
+}
+
+// DefaultAccessLogConfig 从全局配置生成访问日志配置
+func DefaultAccessLogConfig() AccessLogConfig {
+	return AccessLogConfig{
+		SampleRate:    config.Config.Logger.AccessLogSampleRate,
+		SlowThreshold: time.Duration(config.Config.Logger.AccessLogSlowThresholdMs) * time.Millisecond,
+		SkipPaths:     config.Config.Logger.AccessLogSkipPaths,
+	}
+}
+
+// AccessLogMiddleware 结构化访问日志中间件，替代 gin.Logger()
+// 错误请求和慢请求总是记录，其余请求按采样率记录
+func AccessLogMiddleware(cfg AccessLogConfig) gin.HandlerFunc {
+	skip := make(map[string]struct{}, len(cfg.SkipPaths))
+	for _, p := range cfg.SkipPaths {
+		skip[p] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		rawQuery := c.Request.URL.RawQuery
+
+		c.Next()
+
+		if _, ok := skip[path]; ok {
+			return
+		}
+
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		slow := cfg.SlowThreshold > 0 && latency >= cfg.SlowThreshold
+
+		if status < 400 && !slow && !sampled(cfg.SampleRate) {
+			return
+		}
+
+		fields := []zap.Field{
+			zap.String("path", path),
+			zap.Int("status", status),
+			zap.Duration("latency", latency),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Int("size", c.Writer.Size()),
+		}
+		if rawQuery != "" {
+			fields = append(fields, zap.String("query", rawQuery))
+		}
+		if ua := c.Request.UserAgent(); ua != "" {
+			fields = append(fields, zap.String("user_agent", ua))
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		logger := pkg.LoggerFromGin(c)
+		switch {
+		case status >= 500:
+			logger.Error("access", fields...)
+		case status >= 400 || slow:
+			logger.Warn("access", fields...)
+		default:
+			logger.Info("access", fields...)
+		}
+	}
+}
+
+// sampled 按采样率决定是否记录
+func sampled(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	return rand.Float64() < rate
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"slices"
+
+	"weave/config"
+	"weave/pkg"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminTokenHeader 运维服务令牌请求头
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminMiddleware 运维接口访问控制中间件，需放在 AuthMiddleware 之后
+// 当前用户在 Admin.UserIDs 中，或请求头携带的令牌与 Admin.Token 一致时放行；两者都未配置时拒绝所有请求
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 每个请求读取一次配置快照，热加载后的运维用户和令牌立即生效
+		cfg := config.Current()
+
+		if userID := c.GetUint("user_id"); userID != 0 && slices.Contains(cfg.Admin.UserIDs, userID) {
+			c.Next()
+			return
+		}
+		if token := c.GetHeader(AdminTokenHeader); cfg.Admin.Token != "" && token != "" &&
+			subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Admin.Token)) == 1 {
+			c.Next()
+			return
+		}
+
+		pkg.LoggerFromContext(c.Request.Context()).Warn("Admin access denied",
+			zap.String("path", c.Request.URL.Path), zap.Uint("user_id", c.GetUint("user_id")))
+		pkg.RespondError(c, pkg.NewForbidden("需要运维权限", nil))
+	}
+}
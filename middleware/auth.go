@@ -1,11 +1,12 @@
 package middleware
 
 import (
-	"net/http"
 	"strings"
+	"weave/pkg"
 	"weave/utils"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // AuthMiddleware 认证中间件
@@ -14,16 +15,14 @@ func AuthMiddleware() gin.HandlerFunc {
 		// 获取Authorization头
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+			pkg.RespondError(c, pkg.NewUnauthorized("缺少 Authorization 请求头", nil))
 			return
 		}
 
 		// 检查token格式
 		parts := strings.SplitN(authHeader, " ", 2)
 		if !(len(parts) == 2 && parts[0] == "Bearer") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
-			c.Abort()
+			pkg.RespondError(c, pkg.NewUnauthorized("Authorization 请求头格式必须为 Bearer {token}", nil))
 			return
 		}
 
@@ -31,8 +30,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		tokenString := parts[1]
 		userID, _, tenantID, err := utils.VerifyToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			pkg.RespondError(c, pkg.NewAuthInvalidTokenError("令牌无效或已过期", err))
 			return
 		}
 
@@ -43,6 +41,9 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("userID", userID)
 		c.Set("tenantID", tenantID)
 
+		// 为请求级日志记录器追加用户和租户信息
+		pkg.AppendLoggerFields(c, zap.Uint("user_id", userID), zap.Uint("tenant_id", tenantID))
+
 		// 继续处理请求
 		c.Next()
 	}
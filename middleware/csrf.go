@@ -3,7 +3,6 @@ package middleware
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"net/http"
 
 	"weave/config"
 	"weave/pkg"
@@ -15,8 +14,10 @@ import (
 // CSRFMiddleware 跨站请求伪造防护中间件
 func CSRFMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 每个请求读取一次配置快照，热加载时同一请求内的配置保持一致
+		cfg := config.Current()
 		// 如果CSRF防护未启用，直接跳过
-		if !config.Config.CSRF.Enabled {
+		if !cfg.CSRF.Enabled {
 			c.Next()
 			return
 		}
@@ -24,15 +25,15 @@ func CSRFMiddleware() gin.HandlerFunc {
 		// 对于GET、HEAD、OPTIONS、TRACE请求，不做CSRF验证
 		if c.Request.Method == "GET" || c.Request.Method == "HEAD" || c.Request.Method == "OPTIONS" || c.Request.Method == "TRACE" {
 			// 确保CSRF令牌已设置
-			ensureCSRFToken(c)
+			ensureCSRFToken(c, cfg)
 			c.Next()
 			return
 		}
 
 		// 验证CSRF令牌
-		if !validateCSRFToken(c) {
+		if !validateCSRFToken(c, cfg) {
 			pkg.Error("CSRF token validation failed", zap.String("path", c.Request.URL.Path))
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "CSRF token validation failed"})
+			pkg.RespondError(c, pkg.NewForbidden("CSRF 令牌校验失败", nil))
 			return
 		}
 
@@ -41,27 +42,27 @@ func CSRFMiddleware() gin.HandlerFunc {
 }
 
 // ensureCSRFToken 确保CSRF令牌已设置
-func ensureCSRFToken(c *gin.Context) {
+func ensureCSRFToken(c *gin.Context, cfg *config.AppConfig) {
 	// 检查Cookie中是否已有CSRF令牌
-	token, err := c.Cookie(config.Config.CSRF.CookieName)
+	token, err := c.Cookie(cfg.CSRF.CookieName)
 	if err != nil || token == "" {
 		// 生成新的CSRF令牌
-		token = generateCSRFToken(config.Config.CSRF.TokenLength)
+		token = generateCSRFToken(cfg.CSRF.TokenLength)
 		// 设置Cookie
 		// 注意：当前Go版本不支持SameSite参数，在生产环境中应使用支持SameSite的较新版本
 		c.SetCookie(
-			config.Config.CSRF.CookieName,
+			cfg.CSRF.CookieName,
 			token,
-			config.Config.CSRF.CookieMaxAge,
-			config.Config.CSRF.CookiePath,
-			config.Config.CSRF.CookieDomain,
-			config.Config.CSRF.CookieSecure,
-			config.Config.CSRF.CookieHttpOnly,
+			cfg.CSRF.CookieMaxAge,
+			cfg.CSRF.CookiePath,
+			cfg.CSRF.CookieDomain,
+			cfg.CSRF.CookieSecure,
+			cfg.CSRF.CookieHttpOnly,
 		)
 	}
 
 	// 将CSRF令牌添加到响应头中，以便前端可以获取
-	c.Header(config.Config.CSRF.HeaderName, token)
+	c.Header(cfg.CSRF.HeaderName, token)
 }
 
 // generateCSRFToken 生成随机的CSRF令牌
@@ -76,18 +77,18 @@ func generateCSRFToken(length int) string {
 }
 
 // validateCSRFToken 验证CSRF令牌
-func validateCSRFToken(c *gin.Context) bool {
+func validateCSRFToken(c *gin.Context, cfg *config.AppConfig) bool {
 	// 从Cookie中获取CSRF令牌
-	cookieToken, err := c.Cookie(config.Config.CSRF.CookieName)
+	cookieToken, err := c.Cookie(cfg.CSRF.CookieName)
 	if err != nil || cookieToken == "" {
 		return false
 	}
 
 	// 从请求头中获取CSRF令牌
-	headerToken := c.GetHeader(config.Config.CSRF.HeaderName)
+	headerToken := c.GetHeader(cfg.CSRF.HeaderName)
 	if headerToken == "" {
 		// 尝试从表单中获取CSRF令牌
-		headerToken = c.PostForm(config.Config.CSRF.HeaderName)
+		headerToken = c.PostForm(cfg.CSRF.HeaderName)
 	}
 
 	// 验证令牌是否匹配
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"errors"
 	"net/http"
 	"time"
 
@@ -24,36 +23,23 @@ func (eh *ErrorHandler) HandlerFunc() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 记录请求开始时间
 		reqStart := time.Now()
-		requestID := c.GetString("X-Request-ID")
-		if requestID == "" {
-			requestID = generateRequestID()
-			c.Set("X-Request-ID", requestID)
-		}
+		// 使用 RequestIDMiddleware 放入context的请求ID，日志和错误响应中的ID与响应头一致
+		requestID := pkg.RequestIDFromContext(c.Request.Context())
 
 		// 处理请求
 		c.Next()
 
-		// 检查是否有错误
+		// 检查是否有错误；处理函数已经写出响应（如健康检查报告）时只记录日志
 		if len(c.Errors) > 0 {
-			// 获取最后一个错误作为主要错误
-			err := c.Errors.Last().Err
-			var appErr *pkg.AppError
-			var statusCode int
-
-			// 检查是否为AppError类型
-			if errors.As(err, &appErr) {
-				// 设置请求ID和路径
-				appErr.WithRequestID(requestID).WithPath(c.Request.URL.Path)
-				// 获取对应的HTTP状态码
-				statusCode = pkg.GetHTTPStatus(appErr)
-				// 以统一格式返回错误
-				c.JSON(statusCode, appErr)
+			// 获取最后一个错误作为主要错误，非AppError类型的错误转换为内部错误
+			appErr := pkg.ToAppError(c.Errors.Last().Err)
+			appErr.WithRequestID(requestID).WithPath(c.Request.URL.Path)
+			statusCode := pkg.GetHTTPStatus(appErr)
+			if c.Writer.Written() {
+				statusCode = c.Writer.Status()
 			} else {
-				// 对于非AppError类型的错误，创建一个内部错误
-				appErr = pkg.NewInternalError("Internal server error", err)
-				appErr.WithRequestID(requestID).WithPath(c.Request.URL.Path)
-				statusCode = http.StatusInternalServerError
-				c.JSON(statusCode, appErr)
+				// 以 application/problem+json 格式返回错误
+				pkg.RespondError(c, appErr)
 			}
 
 			// 记录错误日志
@@ -71,9 +57,9 @@ func (eh *ErrorHandler) HandlerFunc() gin.HandlerFunc {
 
 			// 根据错误类型设置不同的日志级别
 			if statusCode >= 500 {
-				pkg.With(logFields...).Error("Request failed with server error")
+				pkg.LoggerFromGin(c).With(logFields...).Error("Request failed with server error")
 			} else {
-				pkg.With(logFields...).Warn("Request failed with client error")
+				pkg.LoggerFromGin(c).With(logFields...).Warn("Request failed with client error")
 			}
 
 			// 确保响应已写入
@@ -85,7 +71,7 @@ func (eh *ErrorHandler) HandlerFunc() gin.HandlerFunc {
 		duration := time.Since(reqStart)
 		if c.Writer.Status() >= 400 {
 			// 没有捕获到错误但状态码是4xx，记录警告日志
-			pkg.With(
+			pkg.LoggerFromGin(c).With(
 				zap.String("method", c.Request.Method),
 				zap.String("path", c.Request.URL.Path),
 				zap.String("request_id", requestID),
@@ -95,7 +81,7 @@ func (eh *ErrorHandler) HandlerFunc() gin.HandlerFunc {
 			).Warn("Request completed with non-success status")
 		} else {
 			// 记录成功请求的信息（调试级别）
-			pkg.Debug("Request processed successfully",
+			pkg.LoggerFromGin(c).Debug("Request processed successfully",
 				zap.String("method", c.Request.Method),
 				zap.String("path", c.Request.URL.Path),
 				zap.String("request_id", requestID),
@@ -106,13 +92,6 @@ func (eh *ErrorHandler) HandlerFunc() gin.HandlerFunc {
 	}
 }
 
-// generateRequestID 生成一个简单的请求ID
-func generateRequestID() string {
-	// 在实际项目中，应该使用更安全的方式生成请求ID
-	// 这里为了简化，使用时间戳和随机数的组合
-	return time.Now().Format("20060102150405") + "-" + pkg.RandomString(8)
-}
-
 // responseWriterWrapper 用于包装http.ResponseWriter，捕获状态码
 // 这个结构体用于内部跟踪响应状态码，以便在中间件中记录日志
 type responseWriterWrapper struct {
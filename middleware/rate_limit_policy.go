@@ -0,0 +1,305 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"weave/config"
+	"weave/pkg"
+	"weave/pkg/healthcheck"
+	"weave/pkg/resilience"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// APIKeyHeader 按API Key限流时读取的请求头
+const APIKeyHeader = "X-API-Key"
+
+var (
+	rateLimitMu       sync.RWMutex
+	rateLimitStore    RateLimitStore
+	rateLimitPolicies []config.RateLimitPolicy
+	rateLimitClose    func() error
+)
+
+// InitRateLimiting 根据配置初始化限流存储和策略
+// 存储为redis时会先检查连接，失败返回错误（调用方可以回退到进程内存储）
+func InitRateLimiting() error {
+	SetRateLimitPolicies(config.Config.RateLimit.Policies)
+	// 配置热加载时替换限流策略，存储类型变更需要重启
+	config.Subscribe("rate_limit", func(_, c *config.AppConfig) {
+		SetRateLimitPolicies(c.RateLimit.Policies)
+	}, "RateLimit.Policies")
+
+	if config.Config.RateLimit.Store != config.RateLimitStoreRedis {
+		SetRateLimitStore(NewMemoryRateLimitStore())
+		return nil
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:       config.Config.Redis.Addr,
+		Password:   config.Config.Redis.Password,
+		DB:         config.Config.Redis.DB,
+		MaxRetries: -1, // 由Redis依赖策略统一重试
+	})
+	// 命令经过Redis依赖的熔断：Redis故障时快速失败，限流随即放行
+	client.AddHook(resilience.NewRedisHook(config.DependencyRedis))
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return fmt.Errorf("连接Redis失败: %w", err)
+	}
+
+	SetRateLimitStore(NewRedisRateLimitStore(client, config.Config.RateLimit.KeyPrefix))
+	rateLimitMu.Lock()
+	rateLimitClose = client.Close
+	rateLimitMu.Unlock()
+
+	// Redis不可用时限流放行，不影响就绪状态
+	healthcheck.Register(healthcheck.Check{
+		Name:  "redis",
+		Check: func(ctx context.Context) error { return client.Ping(ctx).Err() },
+	})
+	return nil
+}
+
+// SetRateLimitStore 设置限流存储，替换时会停止原进程内存储的清理任务
+func SetRateLimitStore(store RateLimitStore) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	if old, ok := rateLimitStore.(*MemoryRateLimitStore); ok && old != store {
+		old.Stop()
+	}
+	rateLimitStore = store
+	rateLimitClose = nil
+}
+
+// SetRateLimitPolicies 设置配置中的限流策略（支持运行时替换）
+func SetRateLimitPolicies(policies []config.RateLimitPolicy) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	rateLimitPolicies = append([]config.RateLimitPolicy(nil), policies...)
+}
+
+// CloseRateLimiting 释放限流存储占用的资源，并停止进程内限流器的清理协程
+func CloseRateLimiting() error {
+	stopBucketManagers()
+	healthcheck.Unregister("redis")
+
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	if store, ok := rateLimitStore.(*MemoryRateLimitStore); ok {
+		store.Stop()
+	}
+	if rateLimitClose != nil {
+		err := rateLimitClose()
+		rateLimitClose = nil
+		return err
+	}
+	return nil
+}
+
+// currentRateLimitStore 获取当前限流存储，未初始化时使用进程内存储
+func currentRateLimitStore() RateLimitStore {
+	rateLimitMu.RLock()
+	store := rateLimitStore
+	rateLimitMu.RUnlock()
+	if store != nil {
+		return store
+	}
+
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	if rateLimitStore == nil {
+		rateLimitStore = NewMemoryRateLimitStore()
+	}
+	return rateLimitStore
+}
+
+// RateLimitMiddleware 按策略限流的中间件
+// 策略解析顺序：配置中最长匹配的routeGroup策略 > 配置中与默认策略同名的策略 > 默认策略
+func RateLimitMiddleware(defaultPolicy config.RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := resolveRoutePolicy(c.FullPath(), defaultPolicy)
+		applyRateLimit(c, policy)
+	}
+}
+
+// PluginRateLimitMiddleware 插件路由限流中间件，仅在配置了该插件的策略时生效
+func PluginRateLimitMiddleware(pluginName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy, ok := resolvePluginPolicy(pluginName)
+		if !ok {
+			c.Next()
+			return
+		}
+		applyRateLimit(c, policy)
+	}
+}
+
+// resolveRoutePolicy 解析路由对应的限流策略
+func resolveRoutePolicy(route string, defaultPolicy config.RateLimitPolicy) config.RateLimitPolicy {
+	rateLimitMu.RLock()
+	defer rateLimitMu.RUnlock()
+
+	var matched *config.RateLimitPolicy
+	for i := range rateLimitPolicies {
+		p := &rateLimitPolicies[i]
+		if p.RouteGroup == "" || !strings.HasPrefix(route, p.RouteGroup) {
+			continue
+		}
+		if matched == nil || len(p.RouteGroup) > len(matched.RouteGroup) {
+			matched = p
+		}
+	}
+	if matched != nil {
+		return *matched
+	}
+
+	for _, p := range rateLimitPolicies {
+		if p.Name != "" && p.Name == defaultPolicy.Name && p.RouteGroup == "" && p.Plugin == "" {
+			return p
+		}
+	}
+	return defaultPolicy
+}
+
+// resolvePluginPolicy 解析插件对应的限流策略
+func resolvePluginPolicy(pluginName string) (config.RateLimitPolicy, bool) {
+	rateLimitMu.RLock()
+	defer rateLimitMu.RUnlock()
+
+	for _, p := range rateLimitPolicies {
+		if p.Plugin == pluginName {
+			return p, true
+		}
+	}
+	return config.RateLimitPolicy{}, false
+}
+
+// applyRateLimit 执行限流判断并写入 RateLimit-* 响应头
+func applyRateLimit(c *gin.Context, policy config.RateLimitPolicy) {
+	key := rateLimitKey(c, policy)
+	result, err := currentRateLimitStore().Allow(c.Request.Context(), key, policy)
+	if err != nil {
+		// 限流存储不可用时放行，避免限流组件故障导致服务不可用
+		pkg.LoggerFromGin(c).Warn("Rate limit store unavailable, allowing request",
+			zap.String("policy", policyName(policy)),
+			zap.Error(err),
+		)
+		c.Next()
+		return
+	}
+
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(max(result.Remaining, 0)))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", result.Limit, policyWindowSeconds(policy)))
+
+	if !result.Allowed {
+		retryAfter := max(ceilSeconds(result.RetryAfter), 1)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		pkg.RespondError(c, rateLimitExceeded(c, policy, retryAfter))
+		return
+	}
+
+	c.Next()
+}
+
+// RateLimitCheck 返回按路由策略再次检查同一请求的函数，与 RateLimitMiddleware 使用相同的策略和限流键
+// 用于 WebSocket 等长连接对连接上的每条消息限流，只能在处理该请求期间调用；限流存储不可用时放行
+func RateLimitCheck(c *gin.Context, defaultPolicy config.RateLimitPolicy) func(ctx context.Context) *pkg.AppError {
+	route := c.FullPath()
+	return func(ctx context.Context) *pkg.AppError {
+		policy := resolveRoutePolicy(route, defaultPolicy)
+		result, err := currentRateLimitStore().Allow(ctx, rateLimitKey(c, policy), policy)
+		if err != nil {
+			pkg.LoggerFromGin(c).Warn("Rate limit store unavailable, allowing request",
+				zap.String("policy", policyName(policy)),
+				zap.Error(err),
+			)
+			return nil
+		}
+		if !result.Allowed {
+			return rateLimitExceeded(c, policy, max(ceilSeconds(result.RetryAfter), 1))
+		}
+		return nil
+	}
+}
+
+// rateLimitExceeded 记录超出限流的请求并返回 429 错误
+func rateLimitExceeded(c *gin.Context, policy config.RateLimitPolicy, retryAfter int) *pkg.AppError {
+	pkg.LoggerFromGin(c).Info("Rate limit exceeded",
+		zap.String("policy", policyName(policy)),
+		zap.String("key_by", policy.KeyBy),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	return pkg.NewTooManyRequests("请求过于频繁，请稍后再试", nil).
+		WithDetails(gin.H{"retry_after": retryAfter})
+}
+
+// rateLimitKey 根据策略的键类型生成限流键，缺少用户/租户/API Key时回退到客户端IP
+func rateLimitKey(c *gin.Context, policy config.RateLimitPolicy) string {
+	keyType := policy.KeyBy
+	value := ""
+
+	switch policy.KeyBy {
+	case config.RateLimitKeyUser:
+		if userID, ok := c.Get("user_id"); ok {
+			value = fmt.Sprint(userID)
+		}
+	case config.RateLimitKeyTenant:
+		if tenantID, ok := c.Get("tenant_id"); ok {
+			value = fmt.Sprint(tenantID)
+		}
+	case config.RateLimitKeyAPIKey:
+		if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" {
+			// 只保存摘要，避免API Key明文写入存储
+			sum := sha256.Sum256([]byte(apiKey))
+			value = hex.EncodeToString(sum[:16])
+		}
+	}
+
+	if value == "" {
+		keyType = config.RateLimitKeyIP
+		value = c.ClientIP()
+	}
+	return policyName(policy) + ":" + keyType + ":" + value
+}
+
+// policyName 返回策略的标识，用于限流键和日志
+func policyName(policy config.RateLimitPolicy) string {
+	switch {
+	case policy.Name != "":
+		return policy.Name
+	case policy.Plugin != "":
+		return "plugin:" + policy.Plugin
+	default:
+		return policy.RouteGroup
+	}
+}
+
+// policyWindowSeconds 策略的时间窗口（令牌桶为从空到满所需时间）
+func policyWindowSeconds(policy config.RateLimitPolicy) int {
+	if policy.Algorithm == config.RateLimitSlidingWindow {
+		return policy.WindowSeconds
+	}
+	return max(int(math.Ceil(float64(policy.Burst)/policy.Rate)), 1)
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
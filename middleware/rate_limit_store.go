@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"weave/config"
+	"weave/pkg"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitResult 一次限流判断的结果
+type RateLimitResult struct {
+	Allowed    bool          // 是否放行
+	Limit      int           // 配额上限（令牌桶容量或窗口内请求数）
+	Remaining  int           // 剩余配额
+	ResetAfter time.Duration // 配额完全恢复所需时间
+	RetryAfter time.Duration // 被拒绝时建议的重试等待时间
+}
+
+// RateLimitStore 限流存储接口
+// 实现必须保证同一个key的判断是原子的
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string, policy config.RateLimitPolicy) (RateLimitResult, error)
+}
+
+// MemoryRateLimitStore 进程内限流存储，仅适用于单实例部署
+type MemoryRateLimitStore struct {
+	mtx         sync.Mutex
+	buckets     map[string]*TokenBucket
+	windows     map[string]*slidingWindow
+	stopCleanup chan struct{}
+	stopOnce    sync.Once
+}
+
+// slidingWindow 进程内滑动窗口，记录窗口内每个请求的时间
+type slidingWindow struct {
+	hits       []time.Time
+	lastAccess time.Time
+}
+
+// NewMemoryRateLimitStore 创建进程内限流存储，并启动过期数据清理
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{
+		buckets:     make(map[string]*TokenBucket),
+		windows:     make(map[string]*slidingWindow),
+		stopCleanup: make(chan struct{}),
+	}
+	go s.cleanupLoop()
+	return s
+}
+
+// Allow 判断key是否可以继续请求
+func (s *MemoryRateLimitStore) Allow(_ context.Context, key string, policy config.RateLimitPolicy) (RateLimitResult, error) {
+	if policy.Algorithm == config.RateLimitSlidingWindow {
+		return s.allowSlidingWindow(key, policy), nil
+	}
+
+	s.mtx.Lock()
+	bucket, exists := s.buckets[key]
+	if !exists {
+		bucket = NewTokenBucket(policy.Rate, policy.Burst)
+		s.buckets[key] = bucket
+	}
+	s.mtx.Unlock()
+	// 策略热加载后已有的桶按新的速率和容量调整
+	bucket.resize(policy.Rate, policy.Burst)
+
+	ok, tokens, wait := bucket.reserve(1)
+	return RateLimitResult{
+		Allowed:    ok,
+		Limit:      policy.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(policy.Burst) - tokens) / policy.Rate * float64(time.Second)),
+		RetryAfter: wait,
+	}, nil
+}
+
+// allowSlidingWindow 滑动窗口判断
+func (s *MemoryRateLimitStore) allowSlidingWindow(key string, policy config.RateLimitPolicy) RateLimitResult {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := time.Now()
+	window := time.Duration(policy.WindowSeconds) * time.Second
+	sw, exists := s.windows[key]
+	if !exists {
+		sw = &slidingWindow{}
+		s.windows[key] = sw
+	}
+	sw.lastAccess = now
+
+	// 移除窗口外的请求
+	cut := 0
+	for cut < len(sw.hits) && now.Sub(sw.hits[cut]) >= window {
+		cut++
+	}
+	sw.hits = sw.hits[cut:]
+
+	result := RateLimitResult{Limit: policy.Burst}
+	if len(sw.hits) < policy.Burst {
+		sw.hits = append(sw.hits, now)
+		result.Allowed = true
+	}
+	result.Remaining = policy.Burst - len(sw.hits)
+	result.ResetAfter = window - now.Sub(sw.hits[0])
+	if !result.Allowed {
+		result.RetryAfter = result.ResetAfter
+	}
+	return result
+}
+
+// cleanupLoop 定期清理超过5分钟未使用的限流数据
+func (s *MemoryRateLimitStore) cleanupLoop() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mtx.Lock()
+			for key, bucket := range s.buckets {
+				bucket.mtx.Lock()
+				if time.Since(bucket.lastAccess) > 5*time.Minute {
+					delete(s.buckets, key)
+				}
+				bucket.mtx.Unlock()
+			}
+			for key, sw := range s.windows {
+				if time.Since(sw.lastAccess) > 5*time.Minute {
+					delete(s.windows, key)
+				}
+			}
+			s.mtx.Unlock()
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+// Stop 停止后台清理
+func (s *MemoryRateLimitStore) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCleanup) })
+}
+
+// tokenBucketScript Redis令牌桶脚本，在Redis内原子地完成补充和扣减
+// KEYS[1]=桶键 ARGV: 速率(每秒)、容量、当前时间(毫秒)、过期时间(毫秒)
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+
+local elapsed = math.max(0, now - ts)
+tokens = math.min(capacity, tokens + elapsed / 1000 * rate)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+// slidingWindowScript Redis滑动窗口脚本，使用有序集合记录窗口内的请求
+// KEYS[1]=窗口键 ARGV: 当前时间(毫秒)、窗口长度(毫秒)、上限、成员ID
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+if count < limit then
+  redis.call('ZADD', KEYS[1], now, ARGV[4])
+  count = count + 1
+  allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+
+local reset = window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] ~= nil then
+  reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// RedisRateLimitStore 基于Redis的分布式限流存储，多实例共享配额
+type RedisRateLimitStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisRateLimitStore 创建Redis限流存储
+func NewRedisRateLimitStore(client redis.UniversalClient, prefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client, prefix: prefix}
+}
+
+// Allow 判断key是否可以继续请求
+func (s *RedisRateLimitStore) Allow(ctx context.Context, key string, policy config.RateLimitPolicy) (RateLimitResult, error) {
+	fullKey := key
+	if s.prefix != "" {
+		fullKey = s.prefix + ":" + key
+	}
+	now := time.Now().UnixMilli()
+
+	if policy.Algorithm == config.RateLimitSlidingWindow {
+		window := int64(policy.WindowSeconds) * 1000
+		member := strconv.FormatInt(now, 10) + "-" + pkg.RandomString(8)
+		res, err := slidingWindowScript.Run(ctx, s.client, []string{fullKey}, now, window, policy.Burst, member).Int64Slice()
+		if err != nil {
+			return RateLimitResult{}, fmt.Errorf("执行滑动窗口限流脚本失败: %w", err)
+		}
+		result := RateLimitResult{
+			Allowed:    res[0] == 1,
+			Limit:      policy.Burst,
+			Remaining:  int(res[1]),
+			ResetAfter: time.Duration(res[2]) * time.Millisecond,
+		}
+		if !result.Allowed {
+			result.RetryAfter = result.ResetAfter
+		}
+		return result, nil
+	}
+
+	// 桶在完全补满所需时间的两倍后过期
+	ttl := int64(float64(policy.Burst)/policy.Rate*2000) + 1000
+	res, err := tokenBucketScript.Run(ctx, s.client, []string{fullKey}, policy.Rate, policy.Burst, now, ttl).Slice()
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("执行令牌桶限流脚本失败: %w", err)
+	}
+	if len(res) != 2 {
+		return RateLimitResult{}, fmt.Errorf("令牌桶限流脚本返回值异常: %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("解析令牌数失败: %w", err)
+	}
+
+	result := RateLimitResult{
+		Allowed:    allowed == 1,
+		Limit:      policy.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(policy.Burst) - tokens) / policy.Rate * float64(time.Second)),
+	}
+	if !result.Allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / policy.Rate * float64(time.Second))
+	}
+	return result, nil
+}
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"net/http"
 	"sync"
 	"time"
 
@@ -17,6 +16,7 @@ import (
 func RateLimiter(rate float64, burst int) gin.HandlerFunc {
 	// 创建一个令牌桶管理器，按IP地址区分不同客户端
 	bucketManager := NewTokenBucketManager(rate, burst)
+	trackBucketManager(bucketManager)
 
 	return func(c *gin.Context) {
 		// 获取客户端IP
@@ -32,10 +32,7 @@ func RateLimiter(rate float64, burst int) gin.HandlerFunc {
 			).Info("Rate limit exceeded")
 
 			// 返回429 Too Many Requests状态码
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded. Please try again later.",
-			})
-			c.Abort()
+			pkg.RespondError(c, pkg.NewTooManyRequests("请求过于频繁，请稍后再试", nil))
 			return
 		}
 
@@ -74,6 +71,29 @@ func (tb *TokenBucket) Allow() bool {
 
 // Take 尝试从令牌桶中获取指定数量的令牌
 func (tb *TokenBucket) Take(count int) bool {
+	ok, _, _ := tb.reserve(count)
+	return ok
+}
+
+// resize 按新的速率和容量调整令牌桶，用于限流策略变更后调整已有的桶
+// 先按原速率补充到当前时间；容量增加的部分立即可用，令牌数不超过新容量
+func (tb *TokenBucket) resize(rate float64, capacity int) {
+	tb.mtx.Lock()
+	defer tb.mtx.Unlock()
+	if tb.rate == rate && tb.capacity == capacity {
+		return
+	}
+
+	now := time.Now()
+	tb.tokens = min(float64(tb.capacity), tb.tokens+now.Sub(tb.lastRefill).Seconds()*tb.rate)
+	tb.lastRefill = now
+	tb.tokens = min(float64(capacity), tb.tokens+float64(max(capacity-tb.capacity, 0)))
+	tb.rate = rate
+	tb.capacity = capacity
+}
+
+// reserve 尝试获取令牌，返回是否成功、剩余令牌数，以及令牌不足时需要等待的时间
+func (tb *TokenBucket) reserve(count int) (bool, float64, time.Duration) {
 	tb.mtx.Lock()
 	defer tb.mtx.Unlock()
 
@@ -94,10 +114,11 @@ func (tb *TokenBucket) Take(count int) bool {
 	// 检查是否有足够的令牌
 	if tb.tokens >= float64(count) {
 		tb.tokens -= float64(count)
-		return true
+		return true, tb.tokens, 0
 	}
 
-	return false
+	wait := time.Duration((float64(count) - tb.tokens) / tb.rate * float64(time.Second))
+	return false, tb.tokens, wait
 }
 
 // TokenBucketManager 管理多个客户端的令牌桶
@@ -107,6 +128,29 @@ type TokenBucketManager struct {
 	buckets     map[string]*TokenBucket
 	mtx         sync.RWMutex
 	stopCleanup chan struct{}
+	stopOnce    sync.Once
+}
+
+var (
+	bucketManagersMu sync.Mutex
+	bucketManagers   []*TokenBucketManager
+)
+
+// trackBucketManager 记录中间件创建的令牌桶管理器，关闭限流时统一停止清理协程
+func trackBucketManager(tbm *TokenBucketManager) {
+	bucketManagersMu.Lock()
+	defer bucketManagersMu.Unlock()
+	bucketManagers = append(bucketManagers, tbm)
+}
+
+// stopBucketManagers 停止所有中间件创建的令牌桶管理器的清理协程
+func stopBucketManagers() {
+	bucketManagersMu.Lock()
+	defer bucketManagersMu.Unlock()
+	for _, tbm := range bucketManagers {
+		tbm.StopCleanup()
+	}
+	bucketManagers = nil
 }
 
 // NewTokenBucketManager 创建一个新的令牌桶管理器
@@ -145,9 +189,9 @@ func (tbm *TokenBucketManager) cleanupExpiredBuckets() {
 	}
 }
 
-// StopCleanup 停止清理 goroutine
+// StopCleanup 停止清理 goroutine，可重复调用
 func (tbm *TokenBucketManager) StopCleanup() {
-	close(tbm.stopCleanup)
+	tbm.stopOnce.Do(func() { close(tbm.stopCleanup) })
 }
 
 // Allow 检查指定客户端是否可以继续请求
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"weave/pkg"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxRequestIDLength 客户端传入请求ID的最大长度
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware 请求ID中间件
+// 接收客户端传入的 X-Request-ID（格式非法时重新生成），写回响应头，
+// 并在请求context中放入带有 request_id、method、route 字段的日志记录器
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(pkg.RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = pkg.GenerateRequestID()
+		}
+
+		c.Set(pkg.RequestIDHeader, requestID)
+		c.Header(pkg.RequestIDHeader, requestID)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unknown"
+		}
+		logger := pkg.GetLogger().With(
+			zap.String("request_id", requestID),
+			zap.String("method", c.Request.Method),
+			zap.String("route", route),
+		)
+
+		ctx := pkg.ContextWithRequestID(c.Request.Context(), requestID)
+		c.Request = c.Request.WithContext(pkg.ContextWithLogger(ctx, logger))
+
+		c.Next()
+	}
+}
+
+// PluginContextMiddleware 为插件路由的请求日志记录器追加插件名称
+func PluginContextMiddleware(pluginName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("plugin_name", pluginName)
+		pkg.AppendLoggerFields(c, zap.String("plugin", pluginName))
+		c.Next()
+	}
+}
+
+// isValidRequestID 校验请求ID，只允许有限长度的字母、数字和 - _ . :
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
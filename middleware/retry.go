@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"weave/pkg"
+	"weave/pkg/resilience"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -57,6 +58,11 @@ func DefaultRetryableFunc(err error) bool {
 		return false
 	}
 
+	// 依赖容错层识别的可重试错误（网络错误、5xx/429等）
+	if resilience.IsRetryable(err) {
+		return true
+	}
+
 	// 网络相关错误
 	if netErr, ok := err.(net.Error); ok {
 		if netErr.Timeout() {
@@ -110,6 +116,18 @@ func (e *HTTPError) Error() string {
 	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
 }
 
+// RetryPolicy 转换为出站依赖调用使用的重试策略
+func (c RetryConfig) RetryPolicy() resilience.RetryPolicy {
+	return resilience.RetryPolicy{
+		MaxRetries:   c.MaxRetries,
+		InitialDelay: c.InitialDelay,
+		MaxDelay:     c.MaxDelay,
+		Multiplier:   c.Multiplier,
+		Jitter:       c.RandomizationFactor,
+		Retryable:    c.RetryableFunc,
+	}
+}
+
 // Retryer 重试器
 type Retryer struct {
 	config RetryConfig
@@ -259,25 +277,25 @@ func NewHTTPRetryer(config RetryConfig, client *http.Client) *HTTPRetryer {
 
 // Do 执行HTTP请求并重试
 func (h *HTTPRetryer) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	// 先读出请求体，每次尝试使用新的副本
+	var bodyCopy []byte
+	if req.Body != nil {
+		var err error
+		bodyCopy, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return DoWithResult(h.retryer, ctx, func() (*http.Response, error) {
-		// 为每次重试创建新的请求体
-		var bodyCopy []byte
+		attempt := req.WithContext(ctx)
 		if req.Body != nil {
-			var err error
-			bodyCopy, err = io.ReadAll(req.Body)
-			if err != nil {
-				return nil, err
-			}
-			req.Body.Close()
+			attempt.Body = io.NopCloser(bytes.NewReader(bodyCopy))
 		}
 
 		// 执行请求
-		resp, err := h.client.Do(req.WithContext(ctx))
-
-		// 恢复请求体以供下次重试使用
-		if len(bodyCopy) > 0 {
-			req.Body = io.NopCloser(bytes.NewReader(bodyCopy))
-		}
+		resp, err := h.client.Do(attempt)
 
 		// 检查HTTP状态码
 		if resp != nil && (resp.StatusCode >= 500 || resp.StatusCode == 429) {
@@ -292,16 +310,29 @@ func (h *HTTPRetryer) Do(ctx context.Context, req *http.Request) (*http.Response
 }
 
 // RetryMiddleware 重试中间件
+// 将路由的重试策略写入请求上下文，服务层通过 pkg/resilience 调用外部依赖（LLM、SMTP、Redis等）时
+// 按该策略重试；配置文件中 routes.policies 匹配的重试参数覆盖 config，未匹配的路径使用各依赖自身的默认策略
 func RetryMiddleware(config RetryConfig) gin.HandlerFunc {
+	policy := config.RetryPolicy()
 	return func(c *gin.Context) {
-		// API 路径记录重试信息
+		// 外层 RoutePolicyMiddleware 已写入策略中的重试参数
+		if routePolicy, ok := appliedRoutePolicy(c); ok && routePolicy.HasRetry() {
+			c.Next()
+			return
+		}
+		if routePolicy, ok := matchRoutePolicy(c.FullPath(), c.Request.Method, ""); ok && routePolicy.HasRetry() {
+			retry := mergeRetryPolicy(policy, routePolicy)
+			c.Request = c.Request.WithContext(resilience.WithRetryPolicy(c.Request.Context(), retry))
+			c.Next()
+			return
+		}
+
 		if !shouldRetry(c.Request.URL.Path) {
 			c.Next()
 			return
 		}
 
-		// 注入重试配置到上下文，供服务层使用
-		c.Set("retry_config", config)
+		c.Request = c.Request.WithContext(resilience.WithRetryPolicy(c.Request.Context(), policy))
 		c.Next()
 	}
 }
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"weave/config"
+	"weave/pkg/resilience"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	routePolicyMu sync.RWMutex
+	routePolicies []config.RoutePolicy
+)
+
+// InitRoutePolicies 根据配置初始化路由超时和重试策略
+func InitRoutePolicies() {
+	SetRoutePolicies(config.Config.Routes.Policies)
+	// 配置热加载时替换路由策略，对之后的请求生效
+	config.Subscribe("route_policy", func(_, c *config.AppConfig) {
+		SetRoutePolicies(c.Routes.Policies)
+	}, "Routes.Policies")
+}
+
+// SetRoutePolicies 设置配置中的路由超时和重试策略（支持运行时替换）
+func SetRoutePolicies(policies []config.RoutePolicy) {
+	routePolicyMu.Lock()
+	defer routePolicyMu.Unlock()
+	routePolicies = append([]config.RoutePolicy(nil), policies...)
+}
+
+// appliedRoutePolicyKey 已生效的路由策略在gin上下文中的键，避免内层中间件重复应用同一策略
+const appliedRoutePolicyKey = "weave.route_policy"
+
+// RoutePolicyMiddleware 应用路由组的超时和重试中间件，使配置文件中的路由策略可以覆盖组内的所有路由
+// 仅在配置了匹配的策略时生效；组内的 TimeoutMiddleware/RetryMiddleware 不再重复应用已生效的策略
+func RoutePolicyMiddleware() gin.HandlerFunc {
+	return routePolicyHandler("")
+}
+
+// PluginRoutePolicyMiddleware 插件路由的超时和重试中间件，仅在配置了匹配该插件或其路由模板的策略时生效
+func PluginRoutePolicyMiddleware(pluginName string) gin.HandlerFunc {
+	return routePolicyHandler(pluginName)
+}
+
+// routePolicyHandler 为匹配策略的请求设置重试策略并在超时上下文中执行后续处理链
+func routePolicyHandler(pluginName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, applied := appliedRoutePolicy(c); applied {
+			c.Next()
+			return
+		}
+		policy, ok := matchRoutePolicy(c.FullPath(), c.Request.Method, pluginName)
+		if !ok {
+			c.Next()
+			return
+		}
+		c.Set(appliedRoutePolicyKey, policy)
+		if policy.HasRetry() {
+			retry := mergeRetryPolicy(DefaultRetryConfig().RetryPolicy(), policy)
+			c.Request = c.Request.WithContext(resilience.WithRetryPolicy(c.Request.Context(), retry))
+		}
+		runWithTimeout(c, time.Duration(policy.TimeoutMs)*time.Millisecond, DefaultOnTimeout, DefaultTimeoutHandler)
+	}
+}
+
+// appliedRoutePolicy 返回外层中间件已应用的路由策略
+func appliedRoutePolicy(c *gin.Context) (config.RoutePolicy, bool) {
+	v, ok := c.Get(appliedRoutePolicyKey)
+	if !ok {
+		return config.RoutePolicy{}, false
+	}
+	policy, ok := v.(config.RoutePolicy)
+	return policy, ok
+}
+
+// matchRoutePolicy 解析请求对应的路由策略
+// 匹配顺序：最长匹配的路由模板（同长度时指定了方法的优先）> 插件策略
+func matchRoutePolicy(route, method, plugin string) (config.RoutePolicy, bool) {
+	routePolicyMu.RLock()
+	defer routePolicyMu.RUnlock()
+
+	var matched *config.RoutePolicy
+	for i := range routePolicies {
+		p := &routePolicies[i]
+		if p.Route == "" || !routeMatches(p.Route, route) {
+			continue
+		}
+		if p.Method != "" && !strings.EqualFold(p.Method, method) {
+			continue
+		}
+		if matched == nil || len(p.Route) > len(matched.Route) ||
+			(len(p.Route) == len(matched.Route) && p.Method != "" && matched.Method == "") {
+			matched = p
+		}
+	}
+	if matched != nil {
+		return *matched, true
+	}
+
+	if plugin != "" {
+		for _, p := range routePolicies {
+			if p.Plugin == plugin && p.Route == "" && (p.Method == "" || strings.EqualFold(p.Method, method)) {
+				return p, true
+			}
+		}
+	}
+	return config.RoutePolicy{}, false
+}
+
+// routeMatches 判断路由模板是否匹配策略：完全相同，或策略为模板的路径前缀
+func routeMatches(pattern, route string) bool {
+	if route == "" {
+		return false
+	}
+	if pattern == route || pattern == "/" {
+		return true
+	}
+	return strings.HasPrefix(route, strings.TrimSuffix(pattern, "/")+"/")
+}
+
+// mergeRetryPolicy 用路由策略中配置的字段覆盖基础重试策略
+func mergeRetryPolicy(base resilience.RetryPolicy, p config.RoutePolicy) resilience.RetryPolicy {
+	switch {
+	case p.MaxRetries < 0:
+		base.MaxRetries = 0
+	case p.MaxRetries > 0:
+		base.MaxRetries = p.MaxRetries
+	}
+	if p.InitialDelayMs > 0 {
+		base.InitialDelay = time.Duration(p.InitialDelayMs) * time.Millisecond
+	}
+	if p.MaxDelayMs > 0 {
+		base.MaxDelay = time.Duration(p.MaxDelayMs) * time.Millisecond
+	}
+	return base
+}
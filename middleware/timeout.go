@@ -14,10 +14,11 @@ import (
 )
 
 // TimeoutConfig 超时配置
+// 配置文件中 routes.policies 匹配的策略优先于这里的代码默认值
 type TimeoutConfig struct {
-	// DefaultTimeout 默认超时时间
+	// DefaultTimeout 默认超时时间，不大于0时不限制
 	DefaultTimeout time.Duration
-	// PathTimeouts 特定路径的超时时间
+	// PathTimeouts 特定路由的超时时间，键为Gin路由模板（如 /api/v1/tools/:id/execute），以斜杠结尾时按前缀匹配
 	PathTimeouts map[string]time.Duration
 	// OnTimeout 超时回调函数
 	OnTimeout func(c *gin.Context, timeout time.Duration)
@@ -30,12 +31,8 @@ func DefaultTimeoutConfig() TimeoutConfig {
 	return TimeoutConfig{
 		DefaultTimeout: 30 * time.Second,
 		PathTimeouts: map[string]time.Duration{
-			"/api/v1/llm/chat":   60 * time.Second,  // LLM聊天接口需要更长时间
-			"/api/v1/llm/stream": 120 * time.Second, // 流式接口需要更长时间
-			"/api/v1/health":     5 * time.Second,   // 健康检查快速响应
-			"/api/v1/metrics":    10 * time.Second,  // 监控指标
-			"/auth/login":        10 * time.Second,  // 登录接口
-			"/auth/register":     15 * time.Second,  // 注册接口
+			"/auth/login":    10 * time.Second, // 登录接口
+			"/auth/register": 15 * time.Second, // 注册接口
 		},
 		OnTimeout:      DefaultOnTimeout,
 		TimeoutHandler: DefaultTimeoutHandler,
@@ -53,60 +50,112 @@ func DefaultOnTimeout(c *gin.Context, timeout time.Duration) {
 
 // DefaultTimeoutHandler 默认超时处理函数
 func DefaultTimeoutHandler(c *gin.Context) {
-	c.JSON(http.StatusRequestTimeout, gin.H{
-		"error": "Request timeout",
-		"code":  "REQUEST_TIMEOUT",
-	})
-	c.Abort()
+	pkg.RespondError(c, pkg.NewRequestTimeout("请求超时", nil))
 }
 
 // TimeoutMiddleware 超时控制中间件
+// 处理链在当前goroutine中执行，超时通过请求上下文通知处理函数（协作式取消）：
+// 处理函数应在 c.Request.Context() 结束后尽快返回；超时前未写出响应时，之后的写入会被丢弃并返回超时响应
 func TimeoutMiddleware(config TimeoutConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取当前路径的超时配置
-		timeout := getTimeoutForPath(c.Request.URL.Path, config)
+		runWithTimeout(c, config.timeoutFor(c), config.OnTimeout, config.TimeoutHandler)
+	}
+}
 
-		// 创建带超时的上下文
-		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
-		defer cancel()
+// timeoutFor 解析请求的超时时间：配置文件中的路由策略 > PathTimeouts > DefaultTimeout
+// 外层 RoutePolicyMiddleware 已按策略限制超时时返回0，不再叠加超时
+func (config TimeoutConfig) timeoutFor(c *gin.Context) time.Duration {
+	if policy, ok := appliedRoutePolicy(c); ok && policy.TimeoutMs != 0 {
+		return 0
+	}
+	route := c.FullPath()
+	if policy, ok := matchRoutePolicy(route, c.Request.Method, ""); ok && policy.TimeoutMs != 0 {
+		return time.Duration(policy.TimeoutMs) * time.Millisecond
+	}
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	return getTimeoutForPath(route, config)
+}
 
-		// 替换请求的上下文
-		c.Request = c.Request.WithContext(ctx)
+// runWithTimeout 在超时上下文中执行后续处理链，timeout不大于0时不限制
+func runWithTimeout(c *gin.Context, timeout time.Duration, onTimeout func(*gin.Context, time.Duration), handler gin.HandlerFunc) {
+	if timeout <= 0 {
+		c.Next()
+		return
+	}
 
-		// 创建通道用于检测请求完成
-		finished := make(chan struct{})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	c.Request = c.Request.WithContext(ctx)
 
-		// 启动goroutine执行请求处理
-		go func() {
-			defer close(finished)
-			c.Next()
-		}()
+	tw := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+	c.Writer = tw
+	c.Next()
+	c.Writer = tw.ResponseWriter
 
-		// 等待请求完成或超时
-		select {
-		case <-finished:
-			// 请求正常完成
-			return
-		case <-ctx.Done():
-			// 请求超时
-			if ctx.Err() == context.DeadlineExceeded {
-				// 执行超时回调
-				if config.OnTimeout != nil {
-					config.OnTimeout(c, timeout)
-				}
-
-				// 执行超时处理
-				if config.TimeoutHandler != nil {
-					config.TimeoutHandler(c)
-				} else {
-					DefaultTimeoutHandler(c)
-				}
-			}
-		}
+	if !tw.timedOut && (c.Writer.Written() || ctx.Err() != context.DeadlineExceeded) {
+		return
+	}
+
+	if onTimeout != nil {
+		onTimeout(c, timeout)
+	}
+	if handler == nil {
+		handler = DefaultTimeoutHandler
+	}
+	handler(c)
+}
+
+// timeoutWriter 超时前未写出响应时，丢弃处理函数在超时后的写入，由超时中间件统一返回超时响应
+// 已开始写出的响应（如流式输出）不受影响
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	timedOut bool
+}
+
+// discard 判断是否丢弃本次写入
+func (w *timeoutWriter) discard() bool {
+	if !w.timedOut && !w.ResponseWriter.Written() && w.ctx.Err() == context.DeadlineExceeded {
+		w.timedOut = true
+	}
+	return w.timedOut
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if !w.discard() {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if !w.discard() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if w.discard() {
+		return 0, context.DeadlineExceeded
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.discard() {
+		return 0, context.DeadlineExceeded
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	if !w.discard() {
+		w.ResponseWriter.Flush()
 	}
 }
 
-// getTimeoutForPath 获取特定路径的超时时间
+// getTimeoutForPath 获取特定路由的超时时间
 func getTimeoutForPath(path string, config TimeoutConfig) time.Duration {
 	// 精确匹配
 	if timeout, exists := config.PathTimeouts[path]; exists {
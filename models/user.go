@@ -41,19 +41,22 @@ type ToolHistory struct {
 	Result   string    `gorm:"type:text" json:"result"`
 }
 
+// AllModels 返回所有持久化模型(依赖顺序)，用于迁移和结构漂移检查
+func AllModels() []interface{} {
+	return []interface{}{
+		&User{}, &Tool{}, &EmailVerificationCode{},
+		&Team{},
+		&Note{}, &LoginHistory{}, &AuditLog{}, &ToolHistory{},
+		&TeamMember{},
+	}
+}
+
 // 迁移数据表(依赖顺序)
 func MigrateTables(db *gorm.DB) error {
-	if err := db.AutoMigrate(&User{}, &Tool{}, &EmailVerificationCode{}); err != nil {
-		return err
-	}
-	if err := db.AutoMigrate(&Team{}); err != nil {
-		return err
-	}
-	if err := db.AutoMigrate(&Note{}, &LoginHistory{}, &AuditLog{}, &ToolHistory{}); err != nil {
-		return err
-	}
-	if err := db.AutoMigrate(&TeamMember{}); err != nil {
-		return err
+	for _, model := range AllModels() {
+		if err := db.AutoMigrate(model); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -69,8 +69,8 @@ func (al *AuditLogger) Log(options AuditLogOptions) error {
 		CreatedAt:    time.Now(),
 	}
 
-	// 保存到数据库（异步保存，不阻塞主流程）
-	go func() {
+	// 保存到数据库（异步保存，不阻塞主流程；优雅关闭时等待写入完成）
+	RunBackground(func() {
 		if err := DB.Create(&auditLog).Error; err != nil {
 			Error("Failed to save audit log",
 				zap.Error(err),
@@ -78,7 +78,7 @@ func (al *AuditLogger) Log(options AuditLogOptions) error {
 				zap.String("resource_type", options.ResourceType),
 			)
 		}
-	}()
+	})
 
 	return nil
 }
@@ -151,19 +151,17 @@ func AuditLogMiddleware() gin.HandlerFunc {
 				tenantID, _ = tid.(uint)
 			}
 
-			// 异步记录审计日志，避免影响响应时间
-			go func() {
-				_ = auditLogger.Log(AuditLogOptions{
-					Action:       action,
-					ResourceType: resourceType,
-					ResourceID:   resourceID,
-					IPAddress:    ipAddress,
-					UserAgent:    userAgent,
-					UserID:       userID,
-					Username:     username,
-					TenantID:     tenantID,
-				})
-			}()
+			// Log 异步写入数据库，不影响响应时间
+			_ = auditLogger.Log(AuditLogOptions{
+				Action:       action,
+				ResourceType: resourceType,
+				ResourceID:   resourceID,
+				IPAddress:    ipAddress,
+				UserAgent:    userAgent,
+				UserID:       userID,
+				Username:     username,
+				TenantID:     tenantID,
+			})
 		}
 
 		// 记录处理时间（调试用）
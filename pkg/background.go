@@ -0,0 +1,25 @@
+package pkg
+
+import (
+	"context"
+
+	"weave/pkg/lifecycle"
+)
+
+// backgroundTasks 进行中的后台写入任务（审计日志、登录历史、邮件发送、后台迁移等）
+var backgroundTasks lifecycle.Tracker
+
+// RunBackground 异步执行后台任务，优雅关闭时 FlushBackground 会等待其完成
+func RunBackground(fn func()) {
+	backgroundTasks.Go(fn)
+}
+
+// FlushBackground 等待进行中的后台任务完成，ctx结束时返回其错误
+func FlushBackground(ctx context.Context) error {
+	return backgroundTasks.Wait(ctx)
+}
+
+// PendingBackground 返回进行中的后台任务数
+func PendingBackground() int {
+	return backgroundTasks.Active()
+}
@@ -2,15 +2,20 @@ package pkg
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"weave/config"
 	"weave/pkg/metrics"
 
+	"github.com/glebarez/sqlite"
 	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -28,42 +33,28 @@ var (
 
 // InitDatabase 初始化数据库连接
 func InitDatabase() error {
-	// 加载配置
-	if config.Config.Database.Host == "" || config.Config.Database.Username == "" {
+	// 加载配置（SQLite和直接配置DSN时不需要主机和账号）
+	if config.Config.Database.Driver != "sqlite" && config.Config.Database.DSN == "" && (config.Config.Database.Host == "" || config.Config.Database.Username == "") {
 		if err := config.LoadConfig(); err != nil {
 			Error("Failed to load config in InitDatabase", zap.Error(err))
 			return err
 		}
 	}
 
-	var dsn string
 	var dialector gorm.Dialector
 
 	// 根据数据库驱动类型构建连接字符串
-	switch config.Config.Database.Driver {
-	case "postgres":
-		// PostgreSQL连接字符串
-		dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable TimeZone=Asia/Shanghai",
-			config.Config.Database.Host,
-			config.Config.Database.Port,
-			config.Config.Database.Username,
-			config.Config.Database.Password,
-			config.Config.Database.DBName,
-		)
-		dialector = postgres.Open(dsn)
-	case "mysql":
-		fallthrough
+	switch {
+	case config.Config.Database.Driver == "sqlite":
+		// SQLite嵌入式数据库，确保数据文件所在目录存在
+		if err := ensureSQLiteDir(config.Config.Database.Path); err != nil {
+			return err
+		}
+		dialector = sqlite.Open(SQLiteDSN(config.Config.Database.Path, config.Config.Database.BusyTimeoutMs))
+	case isPostgres():
+		dialector = postgres.Open(primaryDSN())
 	default:
-		// MySQL连接字符串
-		dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local&timeout=10s&readTimeout=10s&writeTimeout=10s&collation=utf8mb4_unicode_ci&tls=false",
-			config.Config.Database.Username,
-			config.Config.Database.Password,
-			config.Config.Database.Host,
-			config.Config.Database.Port,
-			config.Config.Database.DBName,
-			config.Config.Database.Charset,
-		)
-		dialector = mysql.Open(dsn)
+		dialector = mysql.Open(primaryDSN())
 	}
 
 	// 根据环境设置日志级别
@@ -76,7 +67,7 @@ func InitDatabase() error {
 	customLogger := logger.New(
 		log.New(os.Stdout, "[gorm] ", log.LstdFlags),
 		logger.Config{
-			SlowThreshold:             800 * time.Millisecond,
+			SlowThreshold:             0, // 慢查询由QueryMetricsPlugin按指纹脱敏记录，避免日志输出查询参数
 			LogLevel:                  logLevel,
 			IgnoreRecordNotFoundError: true,
 			Colorful:                  config.Config.Logger.Development,
@@ -109,23 +100,37 @@ func InitDatabase() error {
 		return fmt.Errorf("failed to connect database after %d retries: %w", maxRetries, lastErr)
 	}
 
+	// 注册查询指标和慢查询采集插件
+	slowThreshold := time.Duration(config.Config.Database.SlowQueryThresholdMs) * time.Millisecond
+	if err := DB.Use(NewQueryMetricsPlugin(slowThreshold)); err != nil {
+		return fmt.Errorf("failed to register query metrics plugin: %w", err)
+	}
+
 	// 获取底层数据库连接池
 	sqlDB, err := DB.DB()
 	if err != nil {
 		return fmt.Errorf("failed to get database instance: %w", err)
 	}
 
-	// 设置优化连接池参数
-	sqlDB.SetMaxIdleConns(5)                   // 减少空闲连接数，降低启动资源消耗
-	sqlDB.SetMaxOpenConns(50)                  // 最大打开连接数
-	sqlDB.SetConnMaxLifetime(time.Hour)        // 连接最大生命周期
-	sqlDB.SetConnMaxIdleTime(15 * time.Minute) // 添加连接最大空闲时间
+	// 设置连接池参数
+	applyPoolConfig(sqlDB)
+	if config.Config.Database.Driver == "sqlite" && isSQLiteMemory(config.Config.Database.Path) {
+		// 内存数据库每个连接都是独立的库，只能使用单个连接
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
 
 	// 快速连接检查
 	if err := sqlDB.Ping(); err != nil {
 		return fmt.Errorf("database ping failed: %w", err)
 	}
 
+	// 注册只读副本，读写分离
+	if err := setupReplicas(DB); err != nil {
+		return err
+	}
+
 	// 启动数据库连接监控（异步）
 	monitorCtx, cancel := context.WithCancel(context.Background())
 	dbMonitor = cancel
@@ -142,6 +147,8 @@ func InitDatabase() error {
 			case <-ticker.C:
 				stats := sqlDB.Stats()
 				metrics.UpdateDatabaseConnections(stats.OpenConnections)
+				// 定期检查副本，不健康的副本退出读路由
+				CheckReplicas(monitorCtx)
 			case <-monitorCtx.Done():
 				return
 			}
@@ -149,11 +156,92 @@ func InitDatabase() error {
 	}()
 
 	// 输出数据库连接成功日志
-	dbType := "MySQL"
-	if config.Config.Database.Driver == "postgres" {
-		dbType = "PostgreSQL"
+	switch {
+	case config.Config.Database.Driver == "sqlite":
+		Info("Database connection established successfully", zap.String("type", "SQLite"), zap.String("path", config.Config.Database.Path))
+	default:
+		dbType := "MySQL"
+		if isPostgres() {
+			dbType = "PostgreSQL"
+		}
+		Info("Database connection established successfully", zap.String("type", dbType), zap.String("host", config.Config.Database.Host), zap.Int("port", config.Config.Database.Port), zap.String("database", config.Config.Database.DBName))
+	}
+	return nil
+}
+
+// isPostgres 判断当前驱动是否为PostgreSQL
+func isPostgres() bool {
+	return config.Config.Database.Driver == "postgres" || config.Config.Database.Driver == "postgresql"
+}
+
+// primaryDSN 返回主库连接字符串，优先使用配置的完整DSN
+func primaryDSN() string {
+	db := config.Config.Database
+	if db.DSN != "" {
+		return db.DSN
+	}
+	return buildDSN(db.Host, db.Port, db.Username, db.Password, db.DBName)
+}
+
+// buildDSN 根据驱动类型构建MySQL或PostgreSQL连接字符串
+func buildDSN(host string, port int, username, password, dbName string) string {
+	if isPostgres() {
+		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable TimeZone=Asia/Shanghai",
+			host, port, username, password, dbName)
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local&timeout=10s&readTimeout=10s&writeTimeout=10s&collation=utf8mb4_unicode_ci&tls=false",
+		username, password, host, port, dbName, config.Config.Database.Charset)
+}
+
+// applyPoolConfig 按配置设置连接池参数
+func applyPoolConfig(sqlDB *sql.DB) {
+	pool := config.Config.Database.Pool
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(pool.ConnMaxLifetimeSeconds) * time.Second)
+	sqlDB.SetConnMaxIdleTime(time.Duration(pool.ConnMaxIdleTimeSeconds) * time.Second)
+}
+
+// SQLiteDSN 构建SQLite连接字符串
+// 开启WAL以允许读写并发，busy_timeout让写锁冲突时等待而不是立即返回SQLITE_BUSY，
+// _txlock=immediate 让事务开始即获取写锁，避免读事务升级为写事务时死锁
+func SQLiteDSN(path string, busyTimeoutMs int) string {
+	pragmas := []string{
+		fmt.Sprintf("busy_timeout(%d)", busyTimeoutMs),
+		"foreign_keys(1)",
+	}
+	// 内存数据库不支持WAL
+	if !isSQLiteMemory(path) {
+		pragmas = append(pragmas, "journal_mode(WAL)", "synchronous(NORMAL)")
+	}
+
+	query := url.Values{}
+	for _, p := range pragmas {
+		query.Add("_pragma", p)
+	}
+	query.Set("_txlock", "immediate")
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + query.Encode()
+}
+
+// isSQLiteMemory 判断是否为SQLite内存数据库
+func isSQLiteMemory(path string) bool {
+	return path == ":memory:" || strings.Contains(path, "mode=memory")
+}
+
+// ensureSQLiteDir 创建SQLite数据文件所在目录
+func ensureSQLiteDir(path string) error {
+	if isSQLiteMemory(path) || strings.HasPrefix(path, "file:") {
+		return nil
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create sqlite data directory %s: %w", dir, err)
 	}
-	Info("Database connection established successfully", zap.String("type", dbType), zap.String("host", config.Config.Database.Host), zap.Int("port", config.Config.Database.Port), zap.String("database", config.Config.Database.DBName))
 	return nil
 }
 
@@ -189,7 +277,8 @@ func CloseDatabaseWithContext(ctx context.Context) error {
 	// 设置最大打开连接数为当前活跃连接数的估计值，允许现有连接完成但不接受新连接
 	sqlDB.SetMaxOpenConns(stats.OpenConnections)
 
-	// 关闭数据库连接
+	// 关闭只读副本和主库连接
+	closeReplicas()
 	err = sqlDB.Close()
 
 	elapsed := time.Since(startTime)
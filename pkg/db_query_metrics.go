@@ -0,0 +1,224 @@
+package pkg
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"weave/pkg/metrics"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// queryStartKey 记录查询开始时间的实例键
+const queryStartKey = "weave:query_start"
+
+// maxSlowQueryFingerprints 最多保留的慢查询指纹数量
+const maxSlowQueryFingerprints = 500
+
+// SlowQueryStat 慢查询指纹统计
+type SlowQueryStat struct {
+	Fingerprint   string    `json:"fingerprint"`
+	Operation     string    `json:"operation"`
+	Table         string    `json:"table"`
+	Count         int64     `json:"count"`
+	TotalMs       float64   `json:"totalMs"`
+	AvgMs         float64   `json:"avgMs"`
+	MaxMs         float64   `json:"maxMs"`
+	LastSeen      time.Time `json:"lastSeen"`
+	LastRequestID string    `json:"lastRequestId,omitempty"`
+}
+
+// slowQueryLog 按指纹聚合的慢查询记录
+type slowQueryLog struct {
+	mu    sync.Mutex
+	stats map[string]*SlowQueryStat
+}
+
+var slowQueries = &slowQueryLog{stats: make(map[string]*SlowQueryStat)}
+
+// record 记录一次慢查询，超过容量时淘汰出现次数最少的指纹
+func (l *slowQueryLog) record(fingerprint, operation, table, requestID string, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stat, ok := l.stats[fingerprint]
+	if !ok {
+		if len(l.stats) >= maxSlowQueryFingerprints {
+			l.evictLocked()
+		}
+		stat = &SlowQueryStat{Fingerprint: fingerprint, Operation: operation, Table: table}
+		l.stats[fingerprint] = stat
+	}
+	stat.Count++
+	stat.TotalMs += ms
+	stat.AvgMs = stat.TotalMs / float64(stat.Count)
+	if ms > stat.MaxMs {
+		stat.MaxMs = ms
+	}
+	stat.LastSeen = time.Now()
+	stat.LastRequestID = requestID
+}
+
+// evictLocked 淘汰出现次数最少（次数相同时最久未出现）的指纹
+func (l *slowQueryLog) evictLocked() {
+	var victim *SlowQueryStat
+	for _, s := range l.stats {
+		if victim == nil || s.Count < victim.Count || (s.Count == victim.Count && s.LastSeen.Before(victim.LastSeen)) {
+			victim = s
+		}
+	}
+	if victim != nil {
+		delete(l.stats, victim.Fingerprint)
+	}
+}
+
+// TopSlowQueries 返回累计耗时最高的前n个慢查询指纹
+func TopSlowQueries(n int) []SlowQueryStat {
+	slowQueries.mu.Lock()
+	result := make([]SlowQueryStat, 0, len(slowQueries.stats))
+	for _, s := range slowQueries.stats {
+		result = append(result, *s)
+	}
+	slowQueries.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalMs != result[j].TotalMs {
+			return result[i].TotalMs > result[j].TotalMs
+		}
+		return result[i].Fingerprint < result[j].Fingerprint
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// ResetSlowQueries 清空慢查询统计
+func ResetSlowQueries() {
+	slowQueries.mu.Lock()
+	slowQueries.stats = make(map[string]*SlowQueryStat)
+	slowQueries.mu.Unlock()
+}
+
+var (
+	sqlStringLiteral  = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+	sqlNumberLiteral  = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	sqlPGPlaceholder  = regexp.MustCompile(`\$\d+`)
+	sqlPlaceholderSet = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	sqlWhitespace     = regexp.MustCompile(`\s+`)
+)
+
+// FingerprintSQL 将SQL归一化为指纹：去掉字面量参数、合并IN列表和空白，
+// 日志中只输出指纹，避免泄露查询参数
+func FingerprintSQL(sql string) string {
+	fp := sqlStringLiteral.ReplaceAllString(sql, "?")
+	fp = sqlPGPlaceholder.ReplaceAllString(fp, "?")
+	fp = sqlNumberLiteral.ReplaceAllString(fp, "?")
+	fp = sqlPlaceholderSet.ReplaceAllString(fp, "(?)")
+	fp = sqlWhitespace.ReplaceAllString(fp, " ")
+	return strings.TrimSpace(fp)
+}
+
+// QueryMetricsPlugin GORM插件：记录每次create/query/update/delete/row/raw的耗时指标，
+// 超过阈值的查询按指纹记录并输出脱敏后的慢查询日志
+type QueryMetricsPlugin struct {
+	// SlowThreshold 慢查询阈值，0表示不记录慢查询
+	SlowThreshold time.Duration
+}
+
+// NewQueryMetricsPlugin 创建查询指标插件
+func NewQueryMetricsPlugin(slowThreshold time.Duration) *QueryMetricsPlugin {
+	return &QueryMetricsPlugin{SlowThreshold: slowThreshold}
+}
+
+// Name 实现 gorm.Plugin
+func (p *QueryMetricsPlugin) Name() string {
+	return "weave:query_metrics"
+}
+
+// Initialize 实现 gorm.Plugin，为各类操作注册计时回调
+func (p *QueryMetricsPlugin) Initialize(db *gorm.DB) error {
+	type register func(name string, fn func(*gorm.DB)) error
+	cb := db.Callback()
+	hooks := []struct {
+		operation     string
+		before, after register
+	}{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before("weave:query_metrics:before_"+h.operation, p.before); err != nil {
+			return err
+		}
+		if err := h.after("weave:query_metrics:after_"+h.operation, p.after(h.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// before 记录开始时间
+func (p *QueryMetricsPlugin) before(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+// after 记录耗时指标和慢查询
+func (p *QueryMetricsPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+		elapsed := time.Since(start)
+
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		metrics.RecordDatabaseQuery(operation, table, elapsed.Seconds())
+
+		if p.SlowThreshold <= 0 || elapsed < p.SlowThreshold {
+			return
+		}
+		fingerprint := FingerprintSQL(db.Statement.SQL.String())
+		if fingerprint == "" {
+			return
+		}
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		requestID := RequestIDFromContext(ctx)
+		slowQueries.record(fingerprint, operation, table, requestID, elapsed)
+
+		// 请求级日志记录器已带有request_id，非HTTP请求的上下文才需要单独附加
+		logger := LoggerFromContext(ctx)
+		if _, ok := ctx.Value(loggerCtxKey{}).(*zap.Logger); !ok && requestID != "" {
+			logger = logger.With(zap.String("request_id", requestID))
+		}
+		logger.Warn("Slow database query",
+			zap.String("operation", operation),
+			zap.String("table", table),
+			zap.Duration("elapsed", elapsed),
+			zap.Duration("threshold", p.SlowThreshold),
+			zap.Int64("rows", db.Statement.RowsAffected),
+			zap.String("sql", fingerprint),
+		)
+	}
+}
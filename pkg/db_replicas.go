@@ -0,0 +1,323 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"weave/config"
+	"weave/pkg/metrics"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// replicaCheckTimeout 单个副本健康检查的超时时间
+const replicaCheckTimeout = 3 * time.Second
+
+// errReplicationStopped 副本复制线程未运行
+var errReplicationStopped = errors.New("replication is not running")
+
+// ReplicaStatus 只读副本健康状态
+type ReplicaStatus struct {
+	Name         string  `json:"name"`
+	Healthy      bool    `json:"healthy"`
+	LagSeconds   float64 `json:"lagSeconds"` // 复制延迟，-1表示未知
+	ResponseTime int64   `json:"responseTime"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// replicaConn 只读副本连接
+type replicaConn struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+var (
+	replicasMu sync.RWMutex
+	replicas   []*replicaConn
+)
+
+// UsePrimary 强制后续查询走主库
+// 用于写后读场景（如注册后立即登录、读取刚创建的验证码），避免副本复制延迟导致读不到数据
+func UsePrimary(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write)
+}
+
+// setupReplicas 为主库连接注册只读副本，读请求按策略路由到健康的副本
+// 启动时无法连接的副本不参与路由，只在健康检查中体现
+func setupReplicas(db *gorm.DB) error {
+	cfgs := config.Config.Database.Replicas
+	if len(cfgs) == 0 {
+		return nil
+	}
+
+	var conns []*replicaConn
+	var dialectors []gorm.Dialector
+	for i, rc := range cfgs {
+		name := config.ReplicaName(i, rc)
+		sqlDB, dialector, err := openReplica(replicaDSN(rc))
+		if err != nil {
+			closeReplicaConns(conns)
+			return fmt.Errorf("failed to open database replica %s: %w", name, err)
+		}
+		applyPoolConfig(sqlDB)
+
+		conn := &replicaConn{name: name, db: sqlDB}
+		ctx, cancel := context.WithTimeout(context.Background(), replicaCheckTimeout)
+		err = sqlDB.PingContext(ctx)
+		cancel()
+		if err != nil {
+			Warn("Database replica unreachable, excluded from read routing", zap.String("replica", name), zap.Error(err))
+			metrics.SetDatabaseReplicaStatus(name, false, -1)
+		} else {
+			conn.healthy.Store(true)
+			dialectors = append(dialectors, dialector)
+		}
+		conns = append(conns, conn)
+	}
+
+	replicasMu.Lock()
+	replicas = conns
+	replicasMu.Unlock()
+
+	if len(dialectors) == 0 {
+		Warn("No database replica reachable, all queries use the primary")
+		return nil
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   &healthyReplicaPolicy{base: replicaPolicy(config.Config.Database.ReplicaPolicy)},
+	})
+	if err := db.Use(resolver); err != nil {
+		closeReplicas()
+		return fmt.Errorf("failed to register database replicas: %w", err)
+	}
+
+	Info("Database read replicas registered", zap.Int("replicas", len(dialectors)), zap.String("policy", config.Config.Database.ReplicaPolicy))
+	return nil
+}
+
+// replicaDSN 构建副本连接字符串，未配置的账号和库名沿用主库
+func replicaDSN(rc config.DatabaseReplica) string {
+	if rc.DSN != "" {
+		return rc.DSN
+	}
+	db := config.Config.Database
+	port := rc.Port
+	if port == 0 {
+		port = db.Port
+	}
+	username, password, dbName := rc.Username, rc.Password, rc.DBName
+	if username == "" {
+		username, password = db.Username, db.Password
+	}
+	if dbName == "" {
+		dbName = db.DBName
+	}
+	return buildDSN(rc.Host, port, username, password, dbName)
+}
+
+// openReplica 打开副本连接并创建复用该连接的GORM方言
+func openReplica(dsn string) (*sql.DB, gorm.Dialector, error) {
+	if isPostgres() {
+		sqlDB, err := sql.Open("pgx", dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sqlDB, postgres.New(postgres.Config{Conn: sqlDB}), nil
+	}
+	sqlDB, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sqlDB, mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), nil
+}
+
+// replicaPolicy 根据配置返回负载均衡策略
+func replicaPolicy(name string) dbresolver.Policy {
+	if name == config.ReplicaPolicyRoundRobin {
+		return dbresolver.StrictRoundRobinPolicy()
+	}
+	return dbresolver.RandomPolicy{}
+}
+
+// healthyReplicaPolicy 只在健康的副本之间负载均衡，全部不健康时退化为在所有副本间选择
+type healthyReplicaPolicy struct {
+	base dbresolver.Policy
+}
+
+// Resolve 实现 dbresolver.Policy
+func (p *healthyReplicaPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	healthy := make([]gorm.ConnPool, 0, len(connPools))
+	for _, cp := range connPools {
+		if replicaHealthy(cp) {
+			healthy = append(healthy, cp)
+		}
+	}
+	if len(healthy) == 0 {
+		return p.base.Resolve(connPools)
+	}
+	return p.base.Resolve(healthy)
+}
+
+// replicaHealthy 判断连接池对应的副本最近一次检查是否健康
+func replicaHealthy(cp gorm.ConnPool) bool {
+	sqlDB, ok := cp.(*sql.DB)
+	if !ok {
+		return true
+	}
+	replicasMu.RLock()
+	defer replicasMu.RUnlock()
+	for _, r := range replicas {
+		if r.db == sqlDB {
+			return r.healthy.Load()
+		}
+	}
+	return true
+}
+
+// CheckReplicas 检查所有只读副本的连通性和复制延迟，并更新路由使用的健康状态
+func CheckReplicas(ctx context.Context) []ReplicaStatus {
+	replicasMu.RLock()
+	conns := append([]*replicaConn(nil), replicas...)
+	replicasMu.RUnlock()
+
+	statuses := make([]ReplicaStatus, len(conns))
+	var wg sync.WaitGroup
+	for i, r := range conns {
+		wg.Add(1)
+		go func(i int, r *replicaConn) {
+			defer wg.Done()
+			statuses[i] = checkReplica(ctx, r)
+		}(i, r)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// checkReplica 检查单个副本
+func checkReplica(ctx context.Context, r *replicaConn) ReplicaStatus {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	status := ReplicaStatus{Name: r.name, LagSeconds: -1}
+	pingErr := r.db.PingContext(ctx)
+	var lag float64
+	var lagErr error
+	if pingErr == nil {
+		lag, lagErr = replicationLag(ctx, r.db)
+	}
+	status.ResponseTime = time.Since(start).Milliseconds()
+
+	maxLag := config.Current().Database.MaxReplicaLagSeconds
+	switch {
+	case pingErr != nil:
+		status.Error = pingErr.Error()
+	case errors.Is(lagErr, errReplicationStopped):
+		status.Error = lagErr.Error()
+	case lagErr != nil:
+		// 连通但无法读取复制状态（如账号缺少REPLICATION CLIENT权限），延迟记为未知
+		status.Healthy = true
+		status.Error = "replication lag unavailable: " + lagErr.Error()
+	case maxLag > 0 && lag > float64(maxLag):
+		status.LagSeconds = lag
+		status.Error = fmt.Sprintf("replication lag %.0fs exceeds %ds", lag, maxLag)
+	default:
+		status.LagSeconds = lag
+		status.Healthy = true
+	}
+
+	if r.healthy.Swap(status.Healthy) != status.Healthy {
+		if status.Healthy {
+			Info("Database replica recovered", zap.String("replica", r.name))
+		} else {
+			Warn("Database replica unhealthy, excluded from read routing", zap.String("replica", r.name), zap.String("error", status.Error))
+		}
+	}
+	metrics.SetDatabaseReplicaStatus(r.name, status.Healthy, status.LagSeconds)
+	return status
+}
+
+// replicationLag 查询副本的复制延迟（秒），非复制节点返回0
+func replicationLag(ctx context.Context, db *sql.DB) (float64, error) {
+	if isPostgres() {
+		var lag float64
+		err := db.QueryRowContext(ctx, `SELECT CASE WHEN pg_is_in_recovery()
+	THEN COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	ELSE 0 END`).Scan(&lag)
+		return lag, err
+	}
+
+	// MySQL 8.0.22 起使用 SHOW REPLICA STATUS，旧版本回退到 SHOW SLAVE STATUS
+	lag, err := mysqlReplicationLag(ctx, db, "SHOW REPLICA STATUS", "Seconds_Behind_Source")
+	if err != nil && ctx.Err() == nil {
+		lag, err = mysqlReplicationLag(ctx, db, "SHOW SLAVE STATUS", "Seconds_Behind_Master")
+	}
+	return lag, err
+}
+
+// mysqlReplicationLag 从复制状态中读取延迟列
+func mysqlReplicationLag(ctx context.Context, db *sql.DB, query, column string) (float64, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		// 没有复制状态说明不是复制节点，也能连通
+		return 0, rows.Err()
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, c := range columns {
+		if c != column {
+			continue
+		}
+		if !values[i].Valid {
+			return 0, errReplicationStopped
+		}
+		return strconv.ParseFloat(values[i].String, 64)
+	}
+	return 0, fmt.Errorf("column %s not found in replication status", column)
+}
+
+// closeReplicas 关闭所有副本连接
+func closeReplicas() {
+	replicasMu.Lock()
+	conns := replicas
+	replicas = nil
+	replicasMu.Unlock()
+	closeReplicaConns(conns)
+}
+
+// closeReplicaConns 关闭给定的副本连接
+func closeReplicaConns(conns []*replicaConn) {
+	for _, r := range conns {
+		if err := r.db.Close(); err != nil {
+			Warn("Failed to close database replica", zap.String("replica", r.name), zap.Error(err))
+		}
+	}
+}
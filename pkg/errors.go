@@ -35,6 +35,9 @@ const (
 	ErrConflict             ErrorCode = "CONFLICT"
 	ErrTooManyRequests      ErrorCode = "TOO_MANY_REQUESTS"
 	ErrUnsupportedMediaType ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	ErrUnprocessableEntity  ErrorCode = "UNPROCESSABLE_ENTITY"
+	ErrRequestTimeout       ErrorCode = "REQUEST_TIMEOUT"
+	ErrPayloadTooLarge      ErrorCode = "PAYLOAD_TOO_LARGE"
 
 	// 服务器错误
 	ErrInternalError      ErrorCode = "INTERNAL_ERROR"
@@ -69,6 +72,7 @@ const (
 	ErrValidationRange    ErrorCode = "VALIDATION_RANGE_ERROR"
 	ErrValidationUnique   ErrorCode = "VALIDATION_UNIQUE_ERROR"
 	ErrValidationLength   ErrorCode = "VALIDATION_LENGTH_ERROR"
+	ErrValidationFailed   ErrorCode = "VALIDATION_FAILED"
 )
 
 // 错误码对应的默认错误信息
@@ -80,6 +84,9 @@ var DefaultErrorMessages = map[ErrorCode]string{
 	ErrConflict:             "请求冲突",
 	ErrTooManyRequests:      "请求过于频繁",
 	ErrUnsupportedMediaType: "不支持的媒体类型",
+	ErrUnprocessableEntity:  "请求无法处理",
+	ErrRequestTimeout:       "请求超时",
+	ErrPayloadTooLarge:      "请求体过大",
 	ErrInternalError:        "服务器内部错误",
 	ErrNotImplemented:       "功能尚未实现",
 	ErrServiceUnavailable:   "服务不可用",
@@ -104,6 +111,7 @@ var DefaultErrorMessages = map[ErrorCode]string{
 	ErrValidationRange:      "参数值超出范围",
 	ErrValidationUnique:     "值必须唯一",
 	ErrValidationLength:     "参数长度不符合要求",
+	ErrValidationFailed:     "参数校验失败",
 }
 
 // HTTPStatusMap 错误码对应的HTTP状态码
@@ -116,6 +124,9 @@ var HTTPStatusMap = map[ErrorCode]int{
 	ErrConflict:             409,
 	ErrTooManyRequests:      429,
 	ErrUnsupportedMediaType: 415,
+	ErrUnprocessableEntity:  422,
+	ErrRequestTimeout:       408,
+	ErrPayloadTooLarge:      413,
 
 	// 服务器错误 (5xx)
 	ErrInternalError:      500,
@@ -150,17 +161,23 @@ var HTTPStatusMap = map[ErrorCode]int{
 	ErrValidationRange:    400,
 	ErrValidationUnique:   409,
 	ErrValidationLength:   400,
+	ErrValidationFailed:   400,
 }
 
 // AppError 应用错误结构
 type AppError struct {
-	Code      ErrorCode   `json:"code"`
-	Message   string      `json:"message"`
-	Err       error       `json:"-"`                   // 不序列化到JSON
-	Details   interface{} `json:"details,omitempty"`   // 可选的错误详情
-	RequestID string      `json:"requestId,omitempty"` // 请求ID，用于追踪
-	Timestamp int64       `json:"timestamp"`           // 错误发生时间戳
-	Path      string      `json:"path,omitempty"`      // 请求路径
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	Err       error        `json:"-"`                   // 不序列化到JSON
+	Details   interface{}  `json:"details,omitempty"`   // 可选的错误详情
+	RequestID string       `json:"requestId,omitempty"` // 请求ID，用于追踪
+	Timestamp int64        `json:"timestamp"`           // 错误发生时间戳
+	Path      string       `json:"path,omitempty"`      // 请求路径
+	Fields    []FieldError `json:"fields,omitempty"`    // 字段级的参数校验错误
+
+	// WithArgs 设置的消息格式串和参数，用于本地化
+	format string
+	args   []interface{}
 }
 
 // Error 实现error接口
@@ -206,6 +223,23 @@ func (e *AppError) WithPath(path string) *AppError {
 	return e
 }
 
+// WithArgs 将错误信息作为格式串填入参数，本地化时以格式串为键查找消息目录
+// 例如 New(ErrNotFound, "插件 '%s' 不存在", nil).WithArgs(name)
+func (e *AppError) WithArgs(args ...interface{}) *AppError {
+	if e.format == "" {
+		e.format = e.Message
+	}
+	e.args = args
+	e.Message = fmt.Sprintf(e.format, args...)
+	return e
+}
+
+// WithFields 添加字段级的参数校验错误
+func (e *AppError) WithFields(fields ...FieldError) *AppError {
+	e.Fields = append(e.Fields, fields...)
+	return e
+}
+
 // New 创建一个新的AppError
 func New(code ErrorCode, message string, err error) *AppError {
 	// 如果消息为空，使用默认消息
@@ -277,6 +311,18 @@ func NewUnsupportedMediaType(message string, err error) *AppError {
 	return New(ErrUnsupportedMediaType, message, err)
 }
 
+func NewUnprocessableEntity(message string, err error) *AppError {
+	return New(ErrUnprocessableEntity, message, err)
+}
+
+func NewRequestTimeout(message string, err error) *AppError {
+	return New(ErrRequestTimeout, message, err)
+}
+
+func NewPayloadTooLarge(message string, err error) *AppError {
+	return New(ErrPayloadTooLarge, message, err)
+}
+
 // 服务器错误辅助函数
 func NewInternalError(message string, err error) *AppError {
 	return New(ErrInternalError, message, err)
@@ -501,7 +547,8 @@ func IsValidationError(err error) bool {
 			appErr.Code == ErrValidationFormat ||
 			appErr.Code == ErrValidationRange ||
 			appErr.Code == ErrValidationUnique ||
-			appErr.Code == ErrValidationLength
+			appErr.Code == ErrValidationLength ||
+			appErr.Code == ErrValidationFailed
 	}
 	return false
 }
@@ -0,0 +1,328 @@
+// Package healthcheck 提供存活、就绪和启动探针使用的健康检查注册表
+//
+// 组件通过 Register 注册命名检查，并声明检查是否关键以及参与哪些探针：关键检查失败时探针失败，
+// 非关键检查失败只将探针状态标记为 degraded。检查结果按 CacheTTL 缓存，避免探针频繁访问外部依赖。
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Probe 探针类型
+type Probe string
+
+const (
+	Liveness  Probe = "liveness"  // 存活探针：失败时进程应被重启，只检查进程自身
+	Readiness Probe = "readiness" // 就绪探针：失败时实例从负载均衡中摘除
+	Startup   Probe = "startup"   // 启动探针：启动完成前屏蔽存活和就绪探针
+)
+
+// 检查和探针状态
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFail     = "fail"
+)
+
+const (
+	// DefaultTimeout 检查未指定超时时间时使用的默认值
+	DefaultTimeout = 3 * time.Second
+	// DefaultCacheTTL 检查未指定缓存时间时使用的默认值
+	DefaultCacheTTL = 5 * time.Second
+)
+
+// Check 命名的健康检查
+type Check struct {
+	// 检查名称，同名检查重复注册时替换
+	Name string
+	// 检查函数，返回错误表示检查失败
+	Check func(ctx context.Context) error
+	// 是否关键：关键检查失败时探针失败，非关键检查失败时探针状态为 degraded
+	Critical bool
+	// 参与的探针，为空时只参与就绪探针
+	Probes []Probe
+	// 单次检查超时时间，0 使用 DefaultTimeout
+	Timeout time.Duration
+	// 检查结果缓存时间，0 使用 DefaultCacheTTL，负数表示不缓存
+	CacheTTL time.Duration
+}
+
+// appliesTo 判断检查是否参与指定探针
+func (c Check) appliesTo(p Probe) bool {
+	if len(c.Probes) == 0 {
+		return p == Readiness
+	}
+	for _, probe := range c.Probes {
+		if probe == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Result 单个检查的结果
+type Result struct {
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	Critical   bool      `json:"critical"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	CheckedAt  time.Time `json:"checkedAt"`
+	Cached     bool      `json:"cached,omitempty"`
+}
+
+// Report 探针报告
+type Report struct {
+	Probe  Probe    `json:"probe"`
+	Status string   `json:"status"`
+	Reason string   `json:"reason,omitempty"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Healthy 探针是否通过（degraded 视为通过）
+func (r *Report) Healthy() bool {
+	return r.Status != StatusFail
+}
+
+// Fail 将探针标记为失败并记录原因，用于检查之外的条件（如正在关闭）
+func (r *Report) Fail(reason string) {
+	r.Status = StatusFail
+	r.Reason = reason
+}
+
+// Failed 返回失败的检查
+func (r *Report) Failed() []Result {
+	var failed []Result
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Source 动态提供检查的来源（如插件），每次执行探针时重新获取
+type Source func() []Check
+
+type entry struct {
+	mu      sync.Mutex
+	check   Check
+	result  Result
+	expires time.Time
+}
+
+// Registry 健康检查注册表
+type Registry struct {
+	mu      sync.Mutex
+	checks  map[string]*entry
+	sources map[string]Source
+	// 来源提供的检查的缓存，按检查名称索引
+	sourced map[string]*entry
+}
+
+// NewRegistry 创建健康检查注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		checks:  make(map[string]*entry),
+		sources: make(map[string]Source),
+		sourced: make(map[string]*entry),
+	}
+}
+
+// Default 进程级的健康检查注册表
+var Default = NewRegistry()
+
+// Register 在默认注册表上注册检查
+func Register(c Check) {
+	Default.Register(c)
+}
+
+// Unregister 从默认注册表移除检查
+func Unregister(name string) {
+	Default.Unregister(name)
+}
+
+// Register 注册检查，同名检查被替换并清除缓存结果
+func (r *Registry) Register(c Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[c.Name] = &entry{check: c}
+}
+
+// Unregister 移除检查
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.checks, name)
+}
+
+// AddSource 注册动态检查来源，同名来源被替换
+func (r *Registry) AddSource(name string, source Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources[name] = source
+}
+
+// RemoveSource 移除动态检查来源
+func (r *Registry) RemoveSource(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sources, name)
+}
+
+// Run 并发执行参与指定探针的检查并汇总结果，检查按名称排序
+func (r *Registry) Run(ctx context.Context, probe Probe) *Report {
+	entries := r.collect(probe)
+
+	results := make([]Result, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e *entry) {
+			defer wg.Done()
+			results[i] = e.run(ctx)
+		}(i, e)
+	}
+	wg.Wait()
+
+	report := &Report{Probe: probe, Status: StatusOK, Checks: results}
+	for _, res := range results {
+		if res.Status != StatusFail {
+			continue
+		}
+		if res.Critical {
+			report.Status = StatusFail
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+// collect 返回参与指定探针的检查，同时刷新来源提供的检查并清理已消失检查的缓存
+func (r *Registry) collect(probe Probe) []*entry {
+	r.mu.Lock()
+	sources := make([]Source, 0, len(r.sources))
+	for _, s := range r.sources {
+		sources = append(sources, s)
+	}
+	r.mu.Unlock()
+
+	// 来源可能访问其他组件的锁，在注册表锁之外获取
+	var dynamic []Check
+	for _, s := range sources {
+		dynamic = append(dynamic, s()...)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]bool, len(dynamic))
+	var entries []*entry
+	for _, c := range dynamic {
+		if _, static := r.checks[c.Name]; static || seen[c.Name] {
+			continue
+		}
+		seen[c.Name] = true
+		e, ok := r.sourced[c.Name]
+		if !ok {
+			e = &entry{}
+			r.sourced[c.Name] = e
+		}
+		e.setCheck(c)
+		if c.appliesTo(probe) {
+			entries = append(entries, e)
+		}
+	}
+	for name := range r.sourced {
+		if !seen[name] {
+			delete(r.sourced, name)
+		}
+	}
+	for _, e := range r.checks {
+		if e.check.appliesTo(probe) {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].check.Name < entries[j].check.Name })
+	return entries
+}
+
+// setCheck 更新来源提供的检查定义，缓存结果保留
+func (e *entry) setCheck(c Check) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.check = c
+}
+
+// run 执行检查，缓存未过期时返回缓存结果
+// 同一检查的并发调用会等待正在进行的检查，共享其结果
+func (e *entry) run(ctx context.Context) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	if !e.result.CheckedAt.IsZero() && now.Before(e.expires) {
+		res := e.result
+		res.Cached = true
+		return res
+	}
+
+	c := e.check
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	err := runCheck(ctx, c.Check, timeout)
+
+	res := Result{
+		Name:       c.Name,
+		Status:     StatusOK,
+		Critical:   c.Critical,
+		DurationMs: time.Since(now).Milliseconds(),
+		CheckedAt:  now,
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+
+	ttl := c.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+	e.result = res
+	e.expires = now.Add(ttl)
+	return res
+}
+
+// runCheck 在超时时间内执行检查，超时后不再等待检查函数返回
+func runCheck(parent context.Context, fn func(context.Context) error, timeout time.Duration) error {
+	if fn == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("检查超时: %w", ctx.Err())
+	}
+}
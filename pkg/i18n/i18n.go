@@ -0,0 +1,186 @@
+// Package i18n 提供按 Accept-Language 选择语言的消息目录
+//
+// 消息以源语言（简体中文）文本或约定的键（如 "title.NOT_FOUND"、"validation.required"）为键，
+// 各语言的目录从 locales 目录加载，插件也可以通过 Register 追加自己的消息。
+// 目录中找不到的消息由调用方回退到源语言文本。
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage 源语言，未声明 Accept-Language 或没有匹配的目录时使用
+const DefaultLanguage = "zh-CN"
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+var (
+	mu         sync.RWMutex
+	catalogues = map[string]map[string]string{}
+)
+
+func init() {
+	entries, err := localeFS.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: 读取消息目录失败: %v", err))
+	}
+	for _, e := range entries {
+		data, err := localeFS.ReadFile(path.Join("locales", e.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: 读取消息目录 %s 失败: %v", e.Name(), err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: 解析消息目录 %s 失败: %v", e.Name(), err))
+		}
+		Register(strings.TrimSuffix(e.Name(), ".json"), messages)
+	}
+}
+
+// Register 向指定语言的目录追加消息，已存在的键被覆盖
+func Register(lang string, messages map[string]string) {
+	lang = canonical(lang)
+	mu.Lock()
+	defer mu.Unlock()
+	catalogue, ok := catalogues[lang]
+	if !ok {
+		catalogue = make(map[string]string, len(messages))
+		catalogues[lang] = catalogue
+	}
+	for k, v := range messages {
+		catalogue[k] = v
+	}
+}
+
+// Languages 返回已有目录的语言，按名称排序
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	langs := make([]string, 0, len(catalogues))
+	for lang := range catalogues {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Lookup 查找指定语言的消息，找不到时依次尝试基础语言（如 en-US → en）
+func Lookup(lang, key string) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, candidate := range fallbacks(canonical(lang)) {
+		if msg, ok := catalogues[candidate][key]; ok {
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+// Translate 查找指定语言的消息，找不到时返回 fallback
+func Translate(lang, key, fallback string) string {
+	if msg, ok := Lookup(lang, key); ok {
+		return msg
+	}
+	return fallback
+}
+
+// Negotiate 根据 Accept-Language 请求头选择有目录的语言，按q值从高到低匹配
+// 区域语言没有目录时匹配其基础语言（如 en-GB → en）或同一基础语言的其他区域（如 zh → zh-CN），
+// 没有匹配时返回 DefaultLanguage
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.TrimSpace(fields[0])
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{lang: lang, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, c := range candidates {
+		if c.lang == "*" {
+			return DefaultLanguage
+		}
+		langs := fallbacks(canonical(c.lang))
+		for _, lang := range langs {
+			if _, ok := catalogues[lang]; ok {
+				return lang
+			}
+		}
+		if lang := sameBaseLocked(langs[len(langs)-1]); lang != "" {
+			return lang
+		}
+	}
+	return DefaultLanguage
+}
+
+// sameBaseLocked 返回与基础语言相同的目录语言，源语言优先，调用方需持有锁
+func sameBaseLocked(base string) string {
+	if strings.HasPrefix(DefaultLanguage, base+"-") {
+		return DefaultLanguage
+	}
+	langs := make([]string, 0, len(catalogues))
+	for lang := range catalogues {
+		if strings.HasPrefix(lang, base+"-") {
+			langs = append(langs, lang)
+		}
+	}
+	if len(langs) == 0 {
+		return ""
+	}
+	sort.Strings(langs)
+	return langs[0]
+}
+
+// canonical 规范化语言标签的大小写：语言小写，区域大写（zh-cn → zh-CN）
+func canonical(lang string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"), "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		} else if len(parts[i]) == 4 {
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// fallbacks 返回语言标签及其逐级截断的基础语言（zh-Hans-CN → zh-Hans → zh）
+func fallbacks(lang string) []string {
+	result := []string{lang}
+	for {
+		i := strings.LastIndex(lang, "-")
+		if i <= 0 {
+			return result
+		}
+		lang = lang[:i]
+		result = append(result, lang)
+	}
+}
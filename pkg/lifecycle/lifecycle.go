@@ -0,0 +1,259 @@
+// Package lifecycle 管理组件的启动和优雅关闭顺序
+//
+// 组件通过 Register 注册启动和停止钩子：启动时按 Order 升序执行，关闭时按 Order 降序执行，
+// 每个钩子有独立的超时时间。关闭开始时就绪状态先置为失败，等待排空时间后再依次停止组件。
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 常用的组件顺序，启动时从小到大，关闭时从大到小
+const (
+	OrderStorage    = 100 // 数据库、缓存等存储连接：最先启动，最后关闭
+	OrderWriters    = 200 // 异步写入（审计日志、后台任务），在存储关闭前刷新
+	OrderBackground = 300 // 定时任务、清理协程、文件监控
+	OrderPlugins    = 400 // 插件
+	OrderServer     = 500 // HTTP服务器：最后启动，最先停止接收请求
+)
+
+// DefaultHookTimeout 钩子未指定超时时间时使用的默认值
+const DefaultHookTimeout = 10 * time.Second
+
+// Hook 组件的生命周期钩子
+type Hook struct {
+	// 组件名称，同名钩子重复注册时替换
+	Name string
+	// 启动和关闭顺序
+	Order int
+	// 启动函数，为空表示组件已在注册前启动
+	Start func(ctx context.Context) error
+	// 停止函数
+	Stop func(ctx context.Context) error
+	// 启动超时时间，0 使用 DefaultHookTimeout
+	StartTimeout time.Duration
+	// 停止超时时间，0 使用 DefaultHookTimeout
+	StopTimeout time.Duration
+}
+
+// HookResult 单个钩子的执行结果
+type HookResult struct {
+	Name     string        `json:"name"`
+	Order    int           `json:"order"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	TimedOut bool          `json:"timedOut,omitempty"`
+}
+
+// ShutdownReport 优雅关闭报告
+type ShutdownReport struct {
+	DrainDelay time.Duration `json:"drainDelay"`
+	Duration   time.Duration `json:"duration"`
+	Hooks      []HookResult  `json:"hooks"`
+}
+
+// Failed 返回执行失败或超时的钩子
+func (r *ShutdownReport) Failed() []HookResult {
+	var failed []HookResult
+	for _, h := range r.Hooks {
+		if h.Error != "" {
+			failed = append(failed, h)
+		}
+	}
+	return failed
+}
+
+type entry struct {
+	hook    Hook
+	seq     int
+	started bool
+}
+
+// Manager 生命周期管理器
+type Manager struct {
+	mu         sync.Mutex
+	entries    []*entry
+	seq        int
+	drainDelay time.Duration
+	ready      atomic.Bool
+	started    atomic.Bool
+	draining   atomic.Bool
+}
+
+// NewManager 创建生命周期管理器
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// Default 进程级的生命周期管理器
+var Default = NewManager()
+
+// Register 在默认管理器上注册钩子
+func Register(h Hook) {
+	Default.Register(h)
+}
+
+// SetDrainDelay 设置关闭时就绪检查失败后、停止组件前的等待时间
+func (m *Manager) SetDrainDelay(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.drainDelay = d
+}
+
+// Register 注册钩子；没有启动函数的钩子视为已启动，关闭时会被停止
+func (m *Manager) Register(h Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := &entry{hook: h, seq: m.seq, started: h.Start == nil}
+	m.seq++
+	for i, old := range m.entries {
+		if old.hook.Name == h.Name {
+			m.entries[i] = e
+			return
+		}
+	}
+	m.entries = append(m.entries, e)
+}
+
+// Start 按顺序执行尚未启动的钩子，全部成功后标记为就绪
+// 任一钩子失败时，停止已经启动的组件并返回错误
+func (m *Manager) Start(ctx context.Context) error {
+	for _, e := range m.ordered(false) {
+		m.mu.Lock()
+		started := e.started
+		m.mu.Unlock()
+		if started {
+			continue
+		}
+		if _, err := runHook(ctx, e.hook.Start, e.hook.StartTimeout); err != nil {
+			m.stopStarted(ctx)
+			return fmt.Errorf("启动组件 %s 失败: %w", e.hook.Name, err)
+		}
+		m.mu.Lock()
+		e.started = true
+		m.mu.Unlock()
+	}
+	m.draining.Store(false)
+	m.started.Store(true)
+	m.ready.Store(true)
+	return nil
+}
+
+// MarkReady 标记为就绪，用于不通过 Start 启动组件的场景
+func (m *Manager) MarkReady() {
+	m.started.Store(true)
+	m.ready.Store(true)
+}
+
+// Started 是否已完成启动，开始关闭后仍然返回 true（用于启动探针）
+func (m *Manager) Started() bool {
+	return m.started.Load()
+}
+
+// Ready 是否就绪（启动完成且未开始关闭）
+func (m *Manager) Ready() bool {
+	return m.ready.Load() && !m.draining.Load()
+}
+
+// Draining 是否正在关闭
+func (m *Manager) Draining() bool {
+	return m.draining.Load()
+}
+
+// Shutdown 优雅关闭：先将就绪状态置为失败并等待排空时间，再按顺序倒序停止所有已启动的组件
+// 每个钩子在自身超时时间和ctx中较早的截止时间内执行，超时的钩子记录在报告中并继续停止后续组件
+func (m *Manager) Shutdown(ctx context.Context) *ShutdownReport {
+	start := time.Now()
+	m.draining.Store(true)
+	m.ready.Store(false)
+
+	m.mu.Lock()
+	delay := m.drainDelay
+	m.mu.Unlock()
+	report := &ShutdownReport{DrainDelay: delay}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	report.Hooks = m.stopStarted(ctx)
+	report.Duration = time.Since(start)
+	return report
+}
+
+// stopStarted 倒序停止已启动的组件
+func (m *Manager) stopStarted(ctx context.Context) []HookResult {
+	var results []HookResult
+	for _, e := range m.ordered(true) {
+		m.mu.Lock()
+		started := e.started
+		e.started = false
+		m.mu.Unlock()
+		if !started || e.hook.Stop == nil {
+			continue
+		}
+
+		hookStart := time.Now()
+		timedOut, err := runHook(ctx, e.hook.Stop, e.hook.StopTimeout)
+		result := HookResult{Name: e.hook.Name, Order: e.hook.Order, Duration: time.Since(hookStart), TimedOut: timedOut}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// ordered 返回按顺序排列的钩子，reverse为true时倒序
+func (m *Manager) ordered(reverse bool) []*entry {
+	m.mu.Lock()
+	entries := append([]*entry(nil), m.entries...)
+	m.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if reverse {
+			a, b = b, a
+		}
+		if a.hook.Order != b.hook.Order {
+			return a.hook.Order < b.hook.Order
+		}
+		return a.seq < b.seq
+	})
+	return entries
+}
+
+// runHook 在超时时间内执行钩子，超时后不再等待钩子返回
+func runHook(parent context.Context, fn func(context.Context) error, timeout time.Duration) (bool, error) {
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return false, err
+	case <-ctx.Done():
+		return errors.Is(ctx.Err(), context.DeadlineExceeded), fmt.Errorf("未在截止时间内完成: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,64 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+)
+
+// Tracker 跟踪进行中的异步任务（如审计日志写入），关闭时等待其完成
+// 与 sync.WaitGroup 不同，Wait 期间仍可以提交新任务
+type Tracker struct {
+	mu     sync.Mutex
+	active int
+	idle   chan struct{}
+}
+
+// Go 在新的goroutine中执行任务并跟踪其完成
+func (t *Tracker) Go(fn func()) {
+	t.mu.Lock()
+	t.active++
+	t.mu.Unlock()
+
+	go func() {
+		defer t.done()
+		fn()
+	}()
+}
+
+// Active 返回进行中的任务数
+func (t *Tracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active
+}
+
+// Wait 等待所有进行中的任务完成，ctx结束时返回其错误
+func (t *Tracker) Wait(ctx context.Context) error {
+	t.mu.Lock()
+	if t.active == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active--
+	if t.active == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
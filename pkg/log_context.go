@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader 请求ID使用的HTTP头（同时作为gin上下文键名）
+const RequestIDHeader = "X-Request-ID"
+
+type loggerCtxKey struct{}
+type requestIDCtxKey struct{}
+
+// ContextWithLogger 将请求级日志记录器写入context
+func ContextWithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, logger)
+}
+
+// LoggerFromContext 从context中获取请求级日志记录器，不存在时返回全局日志记录器
+func LoggerFromContext(ctx context.Context) *zap.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerCtxKey{}).(*zap.Logger); ok && logger != nil {
+			return logger
+		}
+	}
+	return GetLogger()
+}
+
+// ContextWithRequestID 将请求ID写入context
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, requestID)
+}
+
+// RequestIDFromContext 从context中获取请求ID
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return requestID
+}
+
+// LoggerFromGin 获取当前gin请求的日志记录器
+func LoggerFromGin(c *gin.Context) *zap.Logger {
+	if c == nil || c.Request == nil {
+		return GetLogger()
+	}
+	return LoggerFromContext(c.Request.Context())
+}
+
+// AppendLoggerFields 为当前请求的日志记录器追加字段（如用户、租户、插件），
+// 后续的中间件、服务和插件通过 c.Request.Context() 即可取得带有这些字段的日志记录器
+func AppendLoggerFields(c *gin.Context, fields ...zap.Field) {
+	if c == nil || c.Request == nil || len(fields) == 0 {
+		return
+	}
+	ctx := c.Request.Context()
+	c.Request = c.Request.WithContext(ContextWithLogger(ctx, LoggerFromContext(ctx).With(fields...)))
+}
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"fmt"
 	"os"
 	"sync"
 	"time"
@@ -12,6 +13,8 @@ import (
 var (
 	globalLogger *zap.Logger
 	once         sync.Once
+	// logLevel 全局日志级别，支持运行时调整
+	logLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
 )
 
 const (
@@ -46,18 +49,41 @@ func InitLogger(options Options) error {
 	return err
 }
 
-func buildLogger(options Options) (*zap.Logger, error) {
-	level := zap.InfoLevel
-	switch options.Level {
+// parseLevel 解析日志级别，无法识别时返回false
+func parseLevel(level string) (zapcore.Level, bool) {
+	switch level {
 	case DebugLevel:
-		level = zap.DebugLevel
+		return zap.DebugLevel, true
+	case InfoLevel:
+		return zap.InfoLevel, true
 	case WarnLevel:
-		level = zap.WarnLevel
+		return zap.WarnLevel, true
 	case ErrorLevel:
-		level = zap.ErrorLevel
+		return zap.ErrorLevel, true
 	case FatalLevel:
-		level = zap.FatalLevel
+		return zap.FatalLevel, true
 	}
+	return zap.InfoLevel, false
+}
+
+// SetLevel 运行时调整全局日志级别
+func SetLevel(level string) error {
+	l, ok := parseLevel(level)
+	if !ok {
+		return fmt.Errorf("invalid log level: %s", level)
+	}
+	logLevel.SetLevel(l)
+	return nil
+}
+
+// Level 返回当前全局日志级别
+func Level() string {
+	return logLevel.Level().String()
+}
+
+func buildLogger(options Options) (*zap.Logger, error) {
+	level, _ := parseLevel(options.Level)
+	logLevel.SetLevel(level)
 
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.TimeKey = "time"
@@ -81,7 +107,7 @@ func buildLogger(options Options) (*zap.Logger, error) {
 		writers = append(writers, errWriter)
 	}
 
-	core := zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(writers...), level)
+	core := zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(writers...), logLevel)
 	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel)), nil
 }
 
@@ -2,16 +2,26 @@ package metrics
 
 import (
 	"fmt"
+	"net/http"
 	"runtime"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 )
 
+// pluginNameLabel 插件指标使用的标签名
+const pluginNameLabel = "plugin_name"
+
+// unmatchedRoute 未匹配到路由模板时使用的端点标签，避免原始路径导致标签基数膨胀
+const unmatchedRoute = "unmatched"
+
 // 全局指标注册表
 var (
 	// HTTP请求指标
@@ -57,6 +67,22 @@ var (
 		},
 	)
 
+	dbReplicaUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_replica_up",
+			Help: "Whether a database read replica is healthy (1) or not (0)",
+		},
+		[]string{"replica"},
+	)
+
+	dbReplicaLag = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_replica_lag_seconds",
+			Help: "Replication lag of a database read replica in seconds",
+		},
+		[]string{"replica"},
+	)
+
 	// 插件指标
 	pluginsTotal = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -104,8 +130,82 @@ var (
 		[]string{"type", "component"},
 	)
 
+	// 外部依赖容错指标
+	dependencyCalls = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dependency_calls_total",
+			Help: "Total number of outbound dependency calls by result (success, failure, rejected, short_circuited)",
+		},
+		[]string{"dependency", "result"},
+	)
+
+	dependencyCallDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "dependency_call_duration_seconds",
+			Help:    "Outbound dependency call duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"dependency"},
+	)
+
+	dependencyRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dependency_retries_total",
+			Help: "Total number of outbound dependency call retries",
+		},
+		[]string{"dependency"},
+	)
+
+	dependencyCircuitState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dependency_circuit_state",
+			Help: "Circuit breaker state per dependency (0=closed, 1=open, 2=half-open)",
+		},
+		[]string{"dependency"},
+	)
+
+	dependencyInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dependency_inflight_calls",
+			Help: "Number of in-flight outbound dependency calls",
+		},
+		[]string{"dependency"},
+	)
+
+	// 大模型用量指标，按租户和模型统计
+	llmRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_requests_total",
+			Help: "Total number of chat requests served by an LLM",
+		},
+		[]string{"tenant", "model"},
+	)
+
+	llmTokens = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_tokens_total",
+			Help: "Total number of LLM tokens by type (prompt or completion)",
+		},
+		[]string{"tenant", "model", "type"},
+	)
+
+	llmCost = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_cost_total",
+			Help: "Total LLM cost computed from the model price table",
+		},
+		[]string{"tenant", "model"},
+	)
+
 	// 初始启动时间
 	startTime = time.Now()
+
+	// pluginRegistry 只包含插件指标的注册表，在init中一次性注册
+	pluginRegistry = prometheus.NewRegistry()
+
+	// 周期性指标更新函数（由其他模块注册，如插件管理器）
+	updaters   []func()
+	updatersMu sync.RWMutex
 )
 
 // init 初始化所有指标
@@ -159,14 +259,27 @@ func init() {
 		},
 		[]string{"plugin_name", "success"},
 	)
+
+	// 插件指标同时注册到插件专用注册表，供按插件过滤导出
+	pluginRegistry.MustRegister(
+		PluginExecutionCount,
+		PluginExecutionDuration,
+		PluginMethodCalls,
+		PluginErrors,
+		PluginMemoryUsage,
+		PluginReloads,
+	)
 }
 
 // MetricsManager 指标管理器
-type MetricsManager struct{}
+type MetricsManager struct {
+	stopUpdater chan struct{}
+	stopOnce    sync.Once
+}
 
 // NewMetricsManager 创建指标管理器实例
 func NewMetricsManager() *MetricsManager {
-	return &MetricsManager{}
+	return &MetricsManager{stopUpdater: make(chan struct{})}
 }
 
 // RegisterMetricsRouter 注册Prometheus指标导出路由
@@ -180,7 +293,7 @@ func (mm *MetricsManager) HTTPMonitoringMiddleware() gin.HandlerFunc {
 		start := time.Now()
 		path := c.FullPath()
 		if path == "" {
-			path = c.Request.URL.Path
+			path = unmatchedRoute
 		}
 		method := c.Request.Method
 
@@ -213,6 +326,18 @@ func UpdateDatabaseConnections(count int) {
 	dbConnections.Set(float64(count))
 }
 
+// SetDatabaseReplicaStatus 更新只读副本的健康状态和复制延迟，延迟未知时传入负数
+func SetDatabaseReplicaStatus(replica string, healthy bool, lagSeconds float64) {
+	up := 0.0
+	if healthy {
+		up = 1
+	}
+	dbReplicaUp.WithLabelValues(replica).Set(up)
+	if lagSeconds >= 0 {
+		dbReplicaLag.WithLabelValues(replica).Set(lagSeconds)
+	}
+}
+
 // UpdatePluginStats 更新插件统计信息
 func UpdatePluginStats(total, enabled int) {
 	pluginsTotal.Set(float64(total))
@@ -242,6 +367,17 @@ func UpdatePluginMemoryUsage(pluginName string, memoryBytes int64) {
 	PluginMemoryUsage.WithLabelValues(pluginName).Set(float64(memoryBytes))
 }
 
+// DeletePluginMetrics 删除插件的所有指标序列（插件注销时调用）
+func DeletePluginMetrics(pluginName string) {
+	labels := prometheus.Labels{pluginNameLabel: pluginName}
+	PluginExecutionCount.DeletePartialMatch(labels)
+	PluginExecutionDuration.DeletePartialMatch(labels)
+	PluginMethodCalls.DeletePartialMatch(labels)
+	PluginErrors.DeletePartialMatch(labels)
+	PluginMemoryUsage.DeletePartialMatch(labels)
+	PluginReloads.DeletePartialMatch(labels)
+}
+
 // RecordPluginReload 记录插件重载
 func RecordPluginReload(pluginName string, success bool) {
 	successStr := strconv.FormatBool(success)
@@ -258,6 +394,54 @@ func UpdateSystemMetrics() {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 	memoryUsage.Set(float64(memStats.Alloc))
+
+	// 执行其他模块注册的更新函数
+	updatersMu.RLock()
+	fns := append([]func(){}, updaters...)
+	updatersMu.RUnlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// RegisterUpdater 注册周期性指标更新函数，随系统指标一起执行
+func RegisterUpdater(fn func()) {
+	updatersMu.Lock()
+	defer updatersMu.Unlock()
+	updaters = append(updaters, fn)
+}
+
+// RecordDependencyCall 记录一次外部依赖调用
+func RecordDependencyCall(dependency, result string, duration time.Duration) {
+	dependencyCalls.WithLabelValues(dependency, result).Inc()
+	if duration > 0 {
+		dependencyCallDuration.WithLabelValues(dependency).Observe(duration.Seconds())
+	}
+}
+
+// RecordDependencyRetry 记录一次外部依赖调用重试
+func RecordDependencyRetry(dependency string) {
+	dependencyRetries.WithLabelValues(dependency).Inc()
+}
+
+// SetDependencyCircuitState 更新外部依赖的熔断器状态
+func SetDependencyCircuitState(dependency string, state int) {
+	dependencyCircuitState.WithLabelValues(dependency).Set(float64(state))
+}
+
+// SetDependencyInFlight 更新外部依赖的并发调用数
+func SetDependencyInFlight(dependency string, count int) {
+	dependencyInFlight.WithLabelValues(dependency).Set(float64(count))
+}
+
+// RecordLLMUsage 记录一次大模型请求的令牌用量和费用
+func RecordLLMUsage(tenant, model string, promptTokens, completionTokens int, cost float64) {
+	llmRequests.WithLabelValues(tenant, model).Inc()
+	llmTokens.WithLabelValues(tenant, model, "prompt").Add(float64(promptTokens))
+	llmTokens.WithLabelValues(tenant, model, "completion").Add(float64(completionTokens))
+	if cost > 0 {
+		llmCost.WithLabelValues(tenant, model).Add(cost)
+	}
 }
 
 // RecordError 记录错误
@@ -270,13 +454,17 @@ func PluginMonitoringMiddleware(pluginName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		method := c.Request.Method
-		path := c.Request.URL.Path
+		// 使用路由模板而非原始路径，避免路径参数导致标签基数膨胀
+		path := c.FullPath()
+		if path == "" {
+			path = unmatchedRoute
+		}
 		success := true
 
 		c.Next()
 
-		// 检查是否有错误
-		if len(c.Errors) > 0 {
+		// 检查是否有错误（包括服务端错误状态码）
+		if len(c.Errors) > 0 || c.Writer.Status() >= http.StatusInternalServerError {
 			success = false
 		}
 
@@ -295,12 +483,78 @@ func PluginMonitoringMiddleware(pluginName string) gin.HandlerFunc {
 	}
 }
 
-// StartMetricsUpdater 启动指标更新器
+// StartMetricsUpdater 启动指标更新器，通过 StopMetricsUpdater 停止
 func (mm *MetricsManager) StartMetricsUpdater(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	go func() {
-		for range ticker.C {
-			UpdateSystemMetrics()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				UpdateSystemMetrics()
+			case <-mm.stopUpdater:
+				return
+			}
 		}
 	}()
 }
+
+// StopMetricsUpdater 停止指标更新器，可重复调用
+func (mm *MetricsManager) StopMetricsUpdater() {
+	mm.stopOnce.Do(func() { close(mm.stopUpdater) })
+}
+
+// GatherPluginMetrics 收集指定插件的指标族，只保留 plugin_name 标签匹配的序列
+func GatherPluginMetrics(pluginName string) ([]*dto.MetricFamily, error) {
+	families, err := pluginRegistry.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]*dto.MetricFamily, 0, len(families))
+	for _, mf := range families {
+		var metrics []*dto.Metric
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == pluginNameLabel && lp.GetValue() == pluginName {
+					metrics = append(metrics, m)
+					break
+				}
+			}
+		}
+		if len(metrics) == 0 {
+			continue
+		}
+		filtered = append(filtered, &dto.MetricFamily{
+			Name:   mf.Name,
+			Help:   mf.Help,
+			Type:   mf.Type,
+			Unit:   mf.Unit,
+			Metric: metrics,
+		})
+	}
+	return filtered, nil
+}
+
+// WritePluginMetrics 按客户端协商的格式输出指定插件的指标
+func WritePluginMetrics(c *gin.Context, pluginName string) {
+	families, err := GatherPluginMetrics(pluginName)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "收集插件指标失败: %v", err)
+		return
+	}
+
+	format := expfmt.NegotiateIncludingOpenMetrics(c.Request.Header)
+	c.Header("Content-Type", string(format))
+	c.Status(http.StatusOK)
+
+	enc := expfmt.NewEncoder(c.Writer, format)
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			return
+		}
+	}
+	if closer, ok := enc.(expfmt.Closer); ok {
+		_ = closer.Close()
+	}
+}
@@ -0,0 +1,149 @@
+-- Initial schema creation (SQLite)
+
+-- Users table
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) NOT NULL,
+    password VARCHAR(100) NOT NULL,
+    email VARCHAR(100) DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email ON users (email);
+CREATE TRIGGER IF NOT EXISTS update_users_timestamp
+AFTER UPDATE ON users
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- Tools table
+CREATE TABLE IF NOT EXISTS tools (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    icon VARCHAR(255) DEFAULT NULL,
+    plugin_name VARCHAR(100) NOT NULL,
+    is_enabled BOOLEAN DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_name ON tools (name);
+CREATE TRIGGER IF NOT EXISTS update_tools_timestamp
+AFTER UPDATE ON tools
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE tools SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- Tool histories table
+CREATE TABLE IF NOT EXISTS tool_histories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER DEFAULT NULL,
+    tool_id INTEGER DEFAULT NULL,
+    used_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    params TEXT,
+    result TEXT,
+    CONSTRAINT fk_tool_history_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT fk_tool_history_tool FOREIGN KEY (tool_id) REFERENCES tools (id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_tool_histories_user_id ON tool_histories (user_id);
+CREATE INDEX IF NOT EXISTS idx_tool_histories_tool_id ON tool_histories (tool_id);
+
+-- Notes table
+CREATE TABLE IF NOT EXISTS notes (
+    id VARCHAR(100) NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_time DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_note_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_notes_user_id ON notes (user_id);
+CREATE INDEX IF NOT EXISTS idx_title ON notes (title);
+CREATE INDEX IF NOT EXISTS idx_created_time ON notes (created_time);
+CREATE TRIGGER IF NOT EXISTS update_notes_timestamp
+AFTER UPDATE ON notes
+FOR EACH ROW WHEN NEW.updated_time = OLD.updated_time
+BEGIN
+    UPDATE notes SET updated_time = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- Login histories table
+CREATE TABLE IF NOT EXISTS login_histories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(50) DEFAULT NULL,
+    success BOOLEAN NOT NULL DEFAULT 0,
+    message VARCHAR(255) DEFAULT NULL,
+    user_agent TEXT,
+    login_time DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_login_histories_username ON login_histories (username);
+CREATE INDEX IF NOT EXISTS idx_login_time ON login_histories (login_time);
+
+-- Note histories table
+CREATE TABLE IF NOT EXISTS note_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id VARCHAR(100) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_note_id ON note_history (note_id);
+CREATE INDEX IF NOT EXISTS idx_note_history_created_at ON note_history (created_at);
+
+-- Teams table
+CREATE TABLE IF NOT EXISTS team (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    owner_id INTEGER NOT NULL,
+    tenant_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_team_name ON team (tenant_id, name);
+CREATE INDEX IF NOT EXISTS idx_owner_id ON team (owner_id);
+CREATE TRIGGER IF NOT EXISTS update_team_timestamp
+AFTER UPDATE ON team
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE team SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- Team members table
+CREATE TABLE IF NOT EXISTS team_member (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    team_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role VARCHAR(50) NOT NULL DEFAULT 'member',
+    tenant_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_team_id ON team_member (team_id);
+CREATE INDEX IF NOT EXISTS idx_team_member_user_id ON team_member (user_id);
+CREATE INDEX IF NOT EXISTS idx_team_member_tenant_id ON team_member (tenant_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_team_user ON team_member (team_id, user_id);
+
+-- Audit logs table
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER DEFAULT NULL,
+    username VARCHAR(50) DEFAULT NULL,
+    action VARCHAR(100) DEFAULT NULL,
+    resource_type VARCHAR(100) DEFAULT NULL,
+    resource_id VARCHAR(100) DEFAULT NULL,
+    old_value TEXT,
+    new_value TEXT,
+    ip_address VARCHAR(50) DEFAULT NULL,
+    user_agent TEXT,
+    tenant_id INTEGER DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_tenant_id ON audit_logs (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_action ON audit_logs (action);
+CREATE INDEX IF NOT EXISTS idx_resource_type ON audit_logs (resource_type);
@@ -0,0 +1,238 @@
+package migration
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"weave/config"
+	"weave/models"
+	"weave/pkg"
+)
+
+// CLIUsage 迁移命令用法
+const CLIUsage = `Usage: migrate [-dir DIR] [--dry-run] <command> [args]
+
+Commands:
+  up [N]         apply all or the next N pending migrations
+  down [N]       roll back the last N migrations (default 1)
+  goto V         migrate up or down to version V
+  force V        set version V and clear the dirty flag without running migrations
+  status [-json] show applied and pending migrations with checksums
+  baseline [V]   mark an existing database as migrated to V (default latest)
+  create NAME    create a new pair of migration files
+  init           generate initial migrations from the models
+  drift          compare the live schema with the GORM models
+
+Flags:
+  -dir DIR       migrations directory (default depends on database driver)
+  --dry-run      print the SQL of up/down/goto without executing it
+`
+
+// RunCLI 执行迁移命令，调用前需已加载配置并初始化数据库
+func RunCLI(args []string, out io.Writer) error {
+	// --dry-run 可以出现在任意位置
+	dryRun := false
+	rest := make([]string, 0, len(args))
+	for _, a := range args {
+		if a == "--dry-run" || a == "-dry-run" {
+			dryRun = true
+			continue
+		}
+		rest = append(rest, a)
+	}
+
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() { fmt.Fprint(out, CLIUsage) }
+	dir := fs.String("dir", "", "Migrations directory")
+	if err := fs.Parse(rest); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing migrate command")
+	}
+	command, cmdArgs := fs.Arg(0), fs.Args()[1:]
+
+	if command == "drift" {
+		report, err := DetectDrift(pkg.DB, models.AllModels())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, report)
+		if report.HasDrift() {
+			return fmt.Errorf("schema drift detected")
+		}
+		return nil
+	}
+
+	mm := NewMigrationManager()
+	if *dir != "" {
+		mm = NewMigrationManagerWithDir(*dir, config.Config.Database.Driver)
+	}
+	if err := mm.Init(); err != nil {
+		return fmt.Errorf("failed to initialize migration manager: %w", err)
+	}
+
+	switch command {
+	case "up", "down":
+		n, err := optionalInt(cmdArgs, 0)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			return printPlan(mm, out, command, n)
+		}
+		if command == "up" {
+			err = mm.UpSteps(n)
+		} else {
+			err = mm.DownSteps(n)
+		}
+		if err != nil {
+			return err
+		}
+		return printVersion(mm, out)
+
+	case "goto":
+		v, err := requiredInt(cmdArgs, "goto")
+		if err != nil {
+			return err
+		}
+		if v < 0 {
+			return fmt.Errorf("version must not be negative")
+		}
+		if dryRun {
+			return printPlan(mm, out, "goto", v)
+		}
+		if err := mm.Goto(uint(v)); err != nil {
+			return err
+		}
+		return printVersion(mm, out)
+
+	case "force":
+		v, err := requiredInt(cmdArgs, "force")
+		if err != nil {
+			return err
+		}
+		if err := mm.Force(v); err != nil {
+			return err
+		}
+		return printVersion(mm, out)
+
+	case "status":
+		if len(cmdArgs) > 0 && (cmdArgs[0] == "-json" || cmdArgs[0] == "--json") {
+			report, err := mm.Status()
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+		status, err := mm.GetStatus()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, status)
+		return nil
+
+	case "baseline":
+		v, err := optionalInt(cmdArgs, 0)
+		if err != nil {
+			return err
+		}
+		version, err := mm.Baseline(uint(v))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Baselined database at version %d\n", version)
+		return nil
+
+	case "create":
+		createCmd := flag.NewFlagSet("create", flag.ContinueOnError)
+		createCmd.SetOutput(out)
+		name := createCmd.String("name", "", "Migration name")
+		if err := createCmd.Parse(cmdArgs); err != nil {
+			return err
+		}
+		if *name == "" && createCmd.NArg() > 0 {
+			*name = createCmd.Arg(0)
+		}
+		if *name == "" {
+			return fmt.Errorf("migration name is required")
+		}
+		path, err := mm.CreateMigration(*name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s\n", path)
+		return nil
+
+	case "init":
+		if err := mm.GenerateInitialMigrations(); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Initial migrations generated")
+		return nil
+
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate command: %s", command)
+	}
+}
+
+// printPlan 输出dry-run计划执行的SQL
+func printPlan(mm *MigrationManager, out io.Writer, command string, n int) error {
+	steps, err := mm.Plan(command, n)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Fprintln(out, "-- no migrations to run")
+		return nil
+	}
+	for _, s := range steps {
+		fmt.Fprintf(out, "-- %s %d_%s (%s)\n%s\n", s.Direction, s.Version, s.Name, s.Path, s.SQL)
+	}
+	return nil
+}
+
+// printVersion 输出当前版本
+func printVersion(mm *MigrationManager, out io.Writer) error {
+	version, ok, dirty, err := mm.currentVersion()
+	if err != nil {
+		return err
+	}
+	switch {
+	case !ok:
+		fmt.Fprintln(out, "Current version: none")
+	case dirty:
+		fmt.Fprintf(out, "Current version: %d (dirty)\n", version)
+	default:
+		fmt.Fprintf(out, "Current version: %d\n", version)
+	}
+	return nil
+}
+
+// optionalInt 解析可选的整数参数
+func optionalInt(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", args[0])
+	}
+	return n, nil
+}
+
+// requiredInt 解析必填的整数参数
+func requiredInt(args []string, command string) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("%s requires a version", command)
+	}
+	return optionalInt(args, 0)
+}
@@ -0,0 +1,413 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"weave/pkg"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// 迁移方向
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// MigrationFile 一个版本的迁移文件
+type MigrationFile struct {
+	Version  uint
+	Name     string
+	UpPath   string
+	DownPath string
+}
+
+// MigrationStatus 单个迁移的状态
+type MigrationStatus struct {
+	Version         uint       `json:"version"`
+	Name            string     `json:"name"`
+	Applied         bool       `json:"applied"`
+	Checksum        string     `json:"checksum"`                  // 当前up文件的SHA-256
+	AppliedChecksum string     `json:"appliedChecksum,omitempty"` // 应用时记录的SHA-256
+	Modified        bool       `json:"modified"`                  // 已应用的迁移文件在应用后被修改
+	AppliedAt       *time.Time `json:"appliedAt,omitempty"`
+}
+
+// StatusReport 迁移状态报告
+type StatusReport struct {
+	Version    uint              `json:"version"`
+	HasVersion bool              `json:"hasVersion"`
+	Dirty      bool              `json:"dirty"`
+	Migrations []MigrationStatus `json:"migrations"`
+}
+
+// Pending 返回待执行的迁移数量
+func (r *StatusReport) Pending() int {
+	n := 0
+	for _, m := range r.Migrations {
+		if !m.Applied {
+			n++
+		}
+	}
+	return n
+}
+
+// PlannedStep dry-run中计划执行的一步迁移
+type PlannedStep struct {
+	Version   uint
+	Name      string
+	Direction string
+	Path      string
+	SQL       string
+}
+
+// migrationRecord 迁移历史记录，保存应用时迁移文件的校验和
+type migrationRecord struct {
+	Version   uint   `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	Checksum  string `gorm:"size:64"`
+	AppliedAt time.Time
+}
+
+// TableName 迁移历史表名
+func (migrationRecord) TableName() string {
+	return "schema_migration_history"
+}
+
+// Files 返回迁移目录中的所有迁移文件，按版本升序
+func (mm *MigrationManager) Files() ([]MigrationFile, error) {
+	entries, err := os.ReadDir(mm.migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[uint]*MigrationFile)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m, err := source.DefaultParse(entry.Name())
+		if err != nil {
+			continue
+		}
+		f, ok := byVersion[m.Version]
+		if !ok {
+			f = &MigrationFile{Version: m.Version, Name: m.Identifier}
+			byVersion[m.Version] = f
+		}
+		path := filepath.Join(mm.migrationsDir, entry.Name())
+		if m.Direction == source.Up {
+			f.UpPath = path
+		} else {
+			f.DownPath = path
+		}
+	}
+
+	files := make([]MigrationFile, 0, len(byVersion))
+	for _, f := range byVersion {
+		files = append(files, *f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Version < files[j].Version })
+	return files, nil
+}
+
+// currentVersion 返回当前版本，未执行过迁移时 ok 为false
+func (mm *MigrationManager) currentVersion() (version uint, ok, dirty bool, err error) {
+	version, dirty, err = mm.migrate.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, false, nil
+	}
+	if err != nil {
+		return 0, false, false, fmt.Errorf("failed to get migration version: %w", err)
+	}
+	return version, true, dirty, nil
+}
+
+// UpSteps 执行n个待执行的迁移，n为0时执行全部，n为负数时返回错误
+func (mm *MigrationManager) UpSteps(n int) error {
+	if n < 0 {
+		return fmt.Errorf("steps must not be negative, got %d", n)
+	}
+	var err error
+	if n == 0 {
+		err = mm.migrate.Up()
+	} else {
+		err = mm.migrate.Steps(n)
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	return mm.syncHistory()
+}
+
+// DownSteps 回滚最近的n个迁移，n为0时回滚一个，n为负数时返回错误
+func (mm *MigrationManager) DownSteps(n int) error {
+	if n < 0 {
+		return fmt.Errorf("steps must not be negative, got %d", n)
+	}
+	if n == 0 {
+		n = 1
+	}
+	if err := mm.migrate.Steps(-n); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to rollback migrations: %w", err)
+	}
+	return mm.syncHistory()
+}
+
+// Goto 迁移到指定版本（向上或向下）
+func (mm *MigrationManager) Goto(version uint) error {
+	if err := mm.migrate.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate to version %d: %w", version, err)
+	}
+	return mm.syncHistory()
+}
+
+// Force 强制设置版本并清除dirty状态，不执行任何迁移，用于修复失败的迁移
+// version为-1时表示清空版本
+func (mm *MigrationManager) Force(version int) error {
+	if err := mm.migrate.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+	return mm.syncHistory()
+}
+
+// Baseline 将已有数据库标记为已迁移到指定版本，不执行迁移
+// 用于在已有表结构（如GORM自动迁移创建）的数据库上开始使用SQL迁移；version为0时使用最新版本
+func (mm *MigrationManager) Baseline(version uint) (uint, error) {
+	current, ok, _, err := mm.currentVersion()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return 0, fmt.Errorf("database is already at version %d, use force to change it", current)
+	}
+
+	files, err := mm.Files()
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no migrations found in %s", mm.migrationsDir)
+	}
+	if version == 0 {
+		version = files[len(files)-1].Version
+	} else if !containsVersion(files, version) {
+		return 0, fmt.Errorf("migration version %d not found", version)
+	}
+
+	return version, mm.Force(int(version))
+}
+
+// Plan 计算up/down/goto将要执行的迁移，用于dry-run
+// up：n为0时全部；down：n为0时一个；goto：n为目标版本；n为负数时返回错误
+func (mm *MigrationManager) Plan(command string, n int) ([]PlannedStep, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("%s argument must not be negative, got %d", command, n)
+	}
+	files, err := mm.Files()
+	if err != nil {
+		return nil, err
+	}
+	current, ok, dirty, err := mm.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, fmt.Errorf("database is dirty at version %d, fix it and use force first", current)
+	}
+
+	// 已应用迁移的下标（最后一个版本不大于current的文件）
+	appliedIdx := -1
+	if ok {
+		for i, f := range files {
+			if f.Version <= current {
+				appliedIdx = i
+			}
+		}
+	}
+
+	var steps []PlannedStep
+	up := func(from, to int) {
+		for i := from; i <= to && i < len(files); i++ {
+			steps = append(steps, plannedStep(files[i], DirectionUp))
+		}
+	}
+	down := func(from, to int) {
+		for i := from; i >= to && i >= 0; i-- {
+			steps = append(steps, plannedStep(files[i], DirectionDown))
+		}
+	}
+
+	switch command {
+	case DirectionUp:
+		last := len(files) - 1
+		if n > 0 {
+			last = appliedIdx + n
+		}
+		up(appliedIdx+1, last)
+	case DirectionDown:
+		if n == 0 {
+			n = 1
+		}
+		down(appliedIdx, appliedIdx-n+1)
+	case "goto":
+		target := -1
+		for i, f := range files {
+			if f.Version == uint(n) {
+				target = i
+			}
+		}
+		if target < 0 {
+			return nil, fmt.Errorf("migration version %d not found", n)
+		}
+		if target > appliedIdx {
+			up(appliedIdx+1, target)
+		} else {
+			down(appliedIdx, target+1)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported dry-run command: %s", command)
+	}
+
+	for i := range steps {
+		if steps[i].Path == "" {
+			return nil, fmt.Errorf("migration %d has no %s file", steps[i].Version, steps[i].Direction)
+		}
+		data, err := os.ReadFile(steps[i].Path)
+		if err != nil {
+			return nil, err
+		}
+		steps[i].SQL = string(data)
+	}
+	return steps, nil
+}
+
+// plannedStep 创建计划步骤
+func plannedStep(f MigrationFile, direction string) PlannedStep {
+	path := f.UpPath
+	if direction == DirectionDown {
+		path = f.DownPath
+	}
+	return PlannedStep{Version: f.Version, Name: f.Name, Direction: direction, Path: path}
+}
+
+// Status 返回已应用和待执行的迁移及其校验和
+func (mm *MigrationManager) Status() (*StatusReport, error) {
+	current, ok, dirty, err := mm.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+	files, err := mm.Files()
+	if err != nil {
+		return nil, err
+	}
+	records, err := mm.loadHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StatusReport{Version: current, HasVersion: ok, Dirty: dirty}
+	for _, f := range files {
+		st := MigrationStatus{Version: f.Version, Name: f.Name, Applied: ok && f.Version <= current}
+		if f.UpPath != "" {
+			if st.Checksum, err = fileChecksum(f.UpPath); err != nil {
+				return nil, err
+			}
+		}
+		if rec, found := records[f.Version]; found && st.Applied {
+			appliedAt := rec.AppliedAt
+			st.AppliedAt = &appliedAt
+			st.AppliedChecksum = rec.Checksum
+			st.Modified = rec.Checksum != "" && rec.Checksum != st.Checksum
+		}
+		report.Migrations = append(report.Migrations, st)
+	}
+	return report, nil
+}
+
+// syncHistory 根据当前版本同步迁移历史：为已应用的版本记录校验和，删除已回滚版本的记录
+func (mm *MigrationManager) syncHistory() error {
+	current, ok, dirty, err := mm.currentVersion()
+	if err != nil || dirty {
+		return err
+	}
+	files, err := mm.Files()
+	if err != nil {
+		return err
+	}
+	db := pkg.DB
+	if err := db.AutoMigrate(&migrationRecord{}); err != nil {
+		return fmt.Errorf("failed to create migration history table: %w", err)
+	}
+	records, err := mm.loadHistory()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		_, recorded := records[f.Version]
+		applied := ok && f.Version <= current
+		switch {
+		case applied && !recorded:
+			checksum := ""
+			if f.UpPath != "" {
+				if checksum, err = fileChecksum(f.UpPath); err != nil {
+					return err
+				}
+			}
+			rec := migrationRecord{Version: f.Version, Name: f.Name, Checksum: checksum, AppliedAt: time.Now()}
+			if err := db.Create(&rec).Error; err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", f.Version, err)
+			}
+		case !applied && recorded:
+			if err := db.Delete(&migrationRecord{}, f.Version).Error; err != nil {
+				return fmt.Errorf("failed to remove migration record %d: %w", f.Version, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadHistory 读取迁移历史记录，表不存在时返回空
+func (mm *MigrationManager) loadHistory() (map[uint]migrationRecord, error) {
+	records := make(map[uint]migrationRecord)
+	if !pkg.DB.Migrator().HasTable(&migrationRecord{}) {
+		return records, nil
+	}
+	var list []migrationRecord
+	if err := pkg.DB.Find(&list).Error; err != nil {
+		return nil, fmt.Errorf("failed to load migration history: %w", err)
+	}
+	for _, r := range list {
+		records[r.Version] = r
+	}
+	return records, nil
+}
+
+// fileChecksum 计算文件的SHA-256
+func fileChecksum(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// containsVersion 判断迁移文件中是否存在指定版本
+func containsVersion(files []MigrationFile, version uint) bool {
+	for _, f := range files {
+		if f.Version == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package migration
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ColumnDrift 表中的列差异
+type ColumnDrift struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+}
+
+// DriftReport 数据库结构与GORM模型的差异
+type DriftReport struct {
+	MissingTables  []string      `json:"missingTables"`  // 模型存在但数据库中没有的表
+	MissingColumns []ColumnDrift `json:"missingColumns"` // 模型字段在数据库中没有对应列
+	ExtraColumns   []ColumnDrift `json:"extraColumns"`   // 数据库中存在但模型没有的列
+}
+
+// HasDrift 是否存在会导致运行时错误的差异；多余的列不影响读写，不视为漂移
+func (r *DriftReport) HasDrift() bool {
+	return len(r.MissingTables) > 0 || len(r.MissingColumns) > 0
+}
+
+// String 返回可读的差异描述
+func (r *DriftReport) String() string {
+	if !r.HasDrift() && len(r.ExtraColumns) == 0 {
+		return "No schema drift detected"
+	}
+	var b strings.Builder
+	for _, t := range r.MissingTables {
+		fmt.Fprintf(&b, "missing table: %s\n", t)
+	}
+	for _, c := range r.MissingColumns {
+		fmt.Fprintf(&b, "missing column: %s.%s\n", c.Table, c.Column)
+	}
+	for _, c := range r.ExtraColumns {
+		fmt.Fprintf(&b, "extra column: %s.%s\n", c.Table, c.Column)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// DetectDrift 比较数据库中的实际表结构与GORM模型定义
+func DetectDrift(db *gorm.DB, models []interface{}) (*DriftReport, error) {
+	report := &DriftReport{}
+	migrator := db.Migrator()
+
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+		table := stmt.Schema.Table
+
+		if !migrator.HasTable(model) {
+			report.MissingTables = append(report.MissingTables, table)
+			continue
+		}
+
+		columnTypes, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+		actual := make(map[string]bool, len(columnTypes))
+		for _, ct := range columnTypes {
+			actual[strings.ToLower(ct.Name())] = true
+		}
+
+		expected := make(map[string]bool)
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			name := strings.ToLower(field.DBName)
+			if expected[name] {
+				continue
+			}
+			expected[name] = true
+			if !actual[name] {
+				report.MissingColumns = append(report.MissingColumns, ColumnDrift{Table: table, Column: field.DBName})
+			}
+		}
+
+		var extra []string
+		for name := range actual {
+			if !expected[name] {
+				extra = append(extra, name)
+			}
+		}
+		sort.Strings(extra)
+		for _, name := range extra {
+			report.ExtraColumns = append(report.ExtraColumns, ColumnDrift{Table: table, Column: name})
+		}
+	}
+	return report, nil
+}
@@ -32,12 +32,25 @@ func NewMigrationManager() *MigrationManager {
 		driverName = "mysql" // 默认MySQL
 	}
 
+	return NewMigrationManagerWithDir(defaultMigrationsDir(driverName), driverName)
+}
+
+// NewMigrationManagerWithDir 使用指定迁移目录创建迁移管理器
+func NewMigrationManagerWithDir(migrationsDir, driverName string) *MigrationManager {
 	return &MigrationManager{
-		migrationsDir: filepath.Join("pkg", "migrate", "data_sql"),
+		migrationsDir: migrationsDir,
 		driverName:    driverName,
 	}
 }
 
+// defaultMigrationsDir 返回驱动对应的默认迁移目录，SQLite方言差异较大，使用单独的迁移文件
+func defaultMigrationsDir(driverName string) string {
+	if driverName == "sqlite" {
+		return filepath.Join("pkg", "migrate", "data_sql", "sqlite")
+	}
+	return filepath.Join("pkg", "migrate", "data_sql")
+}
+
 // Init 初始化迁移管理器
 func (mm *MigrationManager) Init() error {
 	// 确保迁移目录存在
@@ -65,6 +78,8 @@ func (mm *MigrationManager) Init() error {
 	switch mm.driverName {
 	case "postgres":
 		driver, err = postgres.WithInstance(sqlDB, &postgres.Config{})
+	case "sqlite":
+		driver, err = newSQLiteDriver(sqlDB)
 	case "mysql":
 		fallthrough
 	default:
@@ -93,15 +108,12 @@ func (mm *MigrationManager) Init() error {
 
 // Up 执行所有未应用的迁移
 func (mm *MigrationManager) Up() error {
-	if err := mm.migrate.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to apply migrations: %w", err)
-	}
-	return nil
+	return mm.UpSteps(0)
 }
 
 // Down 回滚一个迁移
 func (mm *MigrationManager) Down() error {
-	return mm.migrate.Down()
+	return mm.DownSteps(1)
 }
 
 // CreateMigration 创建新的迁移文件
@@ -202,28 +214,47 @@ func (mm *MigrationManager) getAvailableVersions() ([]int, error) {
 
 // GetStatus 获取迁移状态
 func (mm *MigrationManager) GetStatus() (string, error) {
-	version, dirty, err := mm.migrate.Version()
-	if err != nil && err != migrate.ErrNilVersion {
+	report, err := mm.Status()
+	if err != nil {
 		return "", fmt.Errorf("failed to get migration status: %w", err)
 	}
 
-	status := fmt.Sprintf("Current version: %d\n", version)
-	if dirty {
-		status += "Database is dirty, last migration failed\n"
+	var b strings.Builder
+	if report.HasVersion {
+		fmt.Fprintf(&b, "Current version: %d\n", report.Version)
+	} else {
+		b.WriteString("Current version: none\n")
 	}
-
-	// 从文件系统获取可用的迁移版本
-	availableVersions, err := mm.getAvailableVersions()
-	if err != nil {
-		return status, err
+	if report.Dirty {
+		b.WriteString("Database is dirty, last migration failed\n")
 	}
 
-	status += "Available versions: "
-	for _, v := range availableVersions {
-		status += fmt.Sprintf("%d ", v)
+	fmt.Fprintf(&b, "%-8s %-10s %-12s %-30s %s\n", "VERSION", "STATE", "CHECKSUM", "NAME", "APPLIED AT")
+	for _, m := range report.Migrations {
+		state := "pending"
+		if m.Applied {
+			state = "applied"
+		}
+		if m.Modified {
+			state = "modified"
+		}
+		appliedAt := "-"
+		if m.AppliedAt != nil {
+			appliedAt = m.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(&b, "%-8d %-10s %-12s %-30s %s\n", m.Version, state, shortChecksum(m.Checksum), m.Name, appliedAt)
 	}
+	fmt.Fprintf(&b, "%d applied, %d pending\n", len(report.Migrations)-report.Pending(), report.Pending())
+
+	return b.String(), nil
+}
 
-	return status, nil
+// shortChecksum 截取校验和前12位用于展示
+func shortChecksum(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
 }
 
 // GenerateInitialMigrations 生成初始迁移文件（基于当前模型）
@@ -270,6 +301,67 @@ func (mm *MigrationManager) generateCreateTablesSQL(db *sql.DB) (string, error)
 		driverName = mm.driverName
 	}
 
+	if driverName == "sqlite" {
+		// SQLite版本的SQL
+		return `-- Initial schema creation (SQLite)
+
+-- Users table
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (username),
+    UNIQUE (email)
+);
+
+-- 为users表添加更新时间触发器
+CREATE TRIGGER IF NOT EXISTS update_users_timestamp
+AFTER UPDATE ON users
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- Notes table
+CREATE TABLE IF NOT EXISTS notes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(255) NOT NULL,
+    content TEXT,
+    user_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_note_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- 为notes表添加更新时间触发器
+CREATE TRIGGER IF NOT EXISTS update_notes_timestamp
+AFTER UPDATE ON notes
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE notes SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- Login history table
+CREATE TABLE IF NOT EXISTS login_histories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    user_agent TEXT,
+    success BOOLEAN NOT NULL DEFAULT 0,
+    error_message TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 创建索引
+CREATE INDEX IF NOT EXISTS idx_notes_user_id ON notes(user_id);
+CREATE INDEX IF NOT EXISTS idx_login_histories_username ON login_histories(username);
+CREATE INDEX IF NOT EXISTS idx_login_histories_created_at ON login_histories(created_at);
+`, nil
+	}
+
 	if driverName == "postgres" {
 		// PostgreSQL版本的SQL
 		return `-- Initial schema creation (PostgreSQL)
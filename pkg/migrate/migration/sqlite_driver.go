@@ -0,0 +1,166 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/golang-migrate/migrate/v4/database"
+)
+
+// sqliteMigrationsTable SQLite迁移版本表，与其他驱动保持一致
+const sqliteMigrationsTable = "schema_migrations"
+
+// sqliteDriver 基于已有连接的golang-migrate SQLite驱动
+// golang-migrate自带的sqlite驱动会注册modernc.org/sqlite，与GORM使用的glebarez/sqlite
+// 注册的同名驱动冲突，因此直接复用pkg.DB的连接实现迁移驱动接口
+type sqliteDriver struct {
+	db       *sql.DB
+	isLocked atomic.Bool
+}
+
+// newSQLiteDriver 使用已有的SQLite连接创建迁移驱动
+func newSQLiteDriver(db *sql.DB) (database.Driver, error) {
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	d := &sqliteDriver{db: db}
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (version uint64, dirty bool);
+CREATE UNIQUE INDEX IF NOT EXISTS version_unique ON %s (version);`, sqliteMigrationsTable, sqliteMigrationsTable)
+	if _, err := db.Exec(query); err != nil {
+		return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	return d, nil
+}
+
+// Open 不支持通过URL打开，只能使用已有连接
+func (d *sqliteDriver) Open(url string) (database.Driver, error) {
+	return nil, errors.New("sqlite migration driver must be created from an existing connection")
+}
+
+// Close 连接由pkg.DB统一管理，这里不关闭
+func (d *sqliteDriver) Close() error {
+	return nil
+}
+
+// Lock SQLite为单进程嵌入式数据库，使用进程内锁防止并发迁移
+func (d *sqliteDriver) Lock() error {
+	if !d.isLocked.CompareAndSwap(false, true) {
+		return database.ErrLocked
+	}
+	return nil
+}
+
+// Unlock 释放迁移锁
+func (d *sqliteDriver) Unlock() error {
+	if !d.isLocked.CompareAndSwap(true, false) {
+		return database.ErrNotLocked
+	}
+	return nil
+}
+
+// Run 在事务中执行一个迁移文件
+func (d *sqliteDriver) Run(migration io.Reader) error {
+	data, err := io.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+	query := string(data)
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return &database.Error{OrigErr: err, Err: "transaction start failed"}
+	}
+	if _, err := tx.Exec(query); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			err = errors.Join(err, rbErr)
+		}
+		return &database.Error{OrigErr: err, Query: data}
+	}
+	if err := tx.Commit(); err != nil {
+		return &database.Error{OrigErr: err, Err: "transaction commit failed"}
+	}
+	return nil
+}
+
+// SetVersion 记录当前版本和dirty状态
+func (d *sqliteDriver) SetVersion(version int, dirty bool) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return &database.Error{OrigErr: err, Err: "transaction start failed"}
+	}
+
+	query := "DELETE FROM " + sqliteMigrationsTable
+	if _, err := tx.Exec(query); err != nil {
+		tx.Rollback()
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	// 与其他驱动一致：NilVersion只有在dirty时才需要记录
+	if version >= 0 || (version == database.NilVersion && dirty) {
+		query = fmt.Sprintf("INSERT INTO %s (version, dirty) VALUES (?, ?)", sqliteMigrationsTable)
+		if _, err := tx.Exec(query, version, dirty); err != nil {
+			tx.Rollback()
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return &database.Error{OrigErr: err, Err: "transaction commit failed"}
+	}
+	return nil
+}
+
+// Version 返回当前版本，未执行过迁移时返回 database.NilVersion
+func (d *sqliteDriver) Version() (int, bool, error) {
+	query := "SELECT version, dirty FROM " + sqliteMigrationsTable + " LIMIT 1"
+	var version int
+	var dirty bool
+	err := d.db.QueryRow(query).Scan(&version, &dirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return database.NilVersion, false, nil
+	case err != nil:
+		return 0, false, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	return version, dirty, nil
+}
+
+// Drop 删除数据库中的所有表
+func (d *sqliteDriver) Drop() error {
+	query := "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+
+	for _, table := range tables {
+		query := fmt.Sprintf("DROP TABLE IF EXISTS %q", table)
+		if _, err := d.db.Exec(query); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte(query)}
+		}
+	}
+	if len(tables) > 0 {
+		if _, err := d.db.Exec("VACUUM"); err != nil {
+			return &database.Error{OrigErr: err, Query: []byte("VACUUM")}
+		}
+	}
+	return nil
+}
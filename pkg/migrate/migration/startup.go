@@ -0,0 +1,159 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"weave/config"
+	"weave/models"
+	"weave/pkg"
+	"weave/pkg/healthcheck"
+
+	"go.uber.org/zap"
+)
+
+// errMigrationsRunning 后台迁移尚未完成
+var errMigrationsRunning = errors.New("startup migrations are still running")
+
+var (
+	startupMu  sync.Mutex
+	startupErr error
+)
+
+// setStartupResult 记录启动迁移的结果，供 migrations 健康检查使用
+func setStartupResult(err error) {
+	startupMu.Lock()
+	defer startupMu.Unlock()
+	startupErr = err
+}
+
+// checkStartupMigrations migrations 健康检查：启动迁移完成且成功时通过
+func checkStartupMigrations(context.Context) error {
+	startupMu.Lock()
+	defer startupMu.Unlock()
+	return startupErr
+}
+
+// RunStartup 按迁移策略执行启动时的数据库迁移
+//   - block：同步执行迁移，失败返回错误，调用方应退出
+//   - verify：不执行迁移，存在待执行迁移、dirty状态或（GORM模式下）模型结构漂移时返回错误
+//   - async：在后台执行迁移，失败只记录日志
+//   - skip：不做任何处理
+//
+// 迁移结果注册为关键的 migrations 检查：async策略下迁移完成前启动和就绪探针失败
+func RunStartup(policy string) error {
+	healthcheck.Register(healthcheck.Check{
+		Name:     "migrations",
+		Check:    checkStartupMigrations,
+		Critical: true,
+		Probes:   []healthcheck.Probe{healthcheck.Readiness, healthcheck.Startup},
+		CacheTTL: -1,
+	})
+
+	switch policy {
+	case config.MigrationPolicySkip:
+		pkg.Info("Startup migrations skipped by policy")
+		setStartupResult(nil)
+		return nil
+	case config.MigrationPolicyAsync:
+		setStartupResult(errMigrationsRunning)
+		// 作为后台任务执行，优雅关闭时等待迁移完成后再关闭数据库
+		pkg.RunBackground(func() {
+			err := migrateOnStartup()
+			if err != nil {
+				pkg.Warn("Migration errors", zap.Error(err))
+			}
+			setStartupResult(err)
+		})
+		return nil
+	case config.MigrationPolicyVerify:
+		err := verifyOnStartup()
+		setStartupResult(err)
+		return err
+	case config.MigrationPolicyBlock, "":
+		err := migrateOnStartup()
+		setStartupResult(err)
+		return err
+	default:
+		return fmt.Errorf("unknown migration policy: %s", policy)
+	}
+}
+
+// migrateOnStartup 执行GORM自动迁移或SQL迁移
+func migrateOnStartup() error {
+	if config.Config.AutoMigrate {
+		pkg.Info("Starting GORM auto-migration...")
+		if err := models.MigrateTables(pkg.DB); err != nil {
+			return fmt.Errorf("failed to migrate database tables: %w", err)
+		}
+		pkg.Info("GORM auto-migration completed successfully")
+	} else {
+		pkg.Info("Starting SQL migrations...")
+		mm := NewMigrationManager()
+		if err := mm.Init(); err != nil {
+			return fmt.Errorf("failed to initialize migration manager: %w", err)
+		}
+		if err := mm.Up(); err != nil {
+			return err
+		}
+		pkg.Info("SQL migrations completed successfully")
+	}
+	return checkDriftOnStartup()
+}
+
+// verifyOnStartup 校验数据库已是最新结构，不做任何修改
+func verifyOnStartup() error {
+	if config.Config.AutoMigrate {
+		report, err := DetectDrift(pkg.DB, models.AllModels())
+		if err != nil {
+			return err
+		}
+		if report.HasDrift() {
+			return fmt.Errorf("database schema does not match models:\n%s", report)
+		}
+		return nil
+	}
+
+	mm := NewMigrationManager()
+	if err := mm.Init(); err != nil {
+		return fmt.Errorf("failed to initialize migration manager: %w", err)
+	}
+	status, err := mm.Status()
+	if err != nil {
+		return err
+	}
+	if status.Dirty {
+		return fmt.Errorf("database is dirty at version %d, fix it and run 'migrate force'", status.Version)
+	}
+	if pending := status.Pending(); pending > 0 {
+		return fmt.Errorf("%d pending migrations, run 'migrate up' before starting", pending)
+	}
+	for _, m := range status.Migrations {
+		if m.Modified {
+			pkg.Warn("Applied migration file has been modified",
+				zap.Uint("version", m.Version), zap.String("name", m.Name))
+		}
+	}
+	return checkDriftOnStartup()
+}
+
+// checkDriftOnStartup 启用漂移检查时比较数据库结构与模型，差异只记录警告
+func checkDriftOnStartup() error {
+	if !config.Config.Migration.DriftCheck {
+		return nil
+	}
+	report, err := DetectDrift(pkg.DB, models.AllModels())
+	if err != nil {
+		pkg.Warn("Failed to check schema drift", zap.Error(err))
+		return nil
+	}
+	for _, t := range report.MissingTables {
+		pkg.Warn("Schema drift: missing table", zap.String("table", t))
+	}
+	for _, c := range report.MissingColumns {
+		pkg.Warn("Schema drift: missing column", zap.String("table", c.Table), zap.String("column", c.Column))
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"weave/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ProblemContentType RFC 7807 错误响应的媒体类型
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase 错误类型URI的前缀，错误类型URI为前缀加上小写、以连字符分隔的错误码
+// 默认为相对URI（如 /problems/not-found），可在启动时设置为文档站点的绝对地址
+var ProblemTypeBase = "/problems/"
+
+// FieldError 字段级的参数校验错误
+type FieldError struct {
+	// 字段名称，使用JSON标签中的名称，嵌套字段以点分隔
+	Field string `json:"field"`
+	// 未通过的校验规则，如 required、email、min
+	Rule string `json:"rule"`
+	// 校验规则参数，如 min=6 中的 6
+	Param string `json:"param,omitempty"`
+	// 本地化的错误信息
+	Message string `json:"message"`
+}
+
+// Problem RFC 7807 错误响应
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      ErrorCode    `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	Details   interface{}  `json:"details,omitempty"`
+	Timestamp int64        `json:"timestamp"`
+}
+
+func init() {
+	// 源语言的错误标题直接使用错误码的默认信息
+	titles := make(map[string]string, len(DefaultErrorMessages))
+	for code, msg := range DefaultErrorMessages {
+		titles[titleKey(code)] = msg
+	}
+	i18n.Register(i18n.DefaultLanguage, titles)
+
+	// 字段级校验错误使用JSON标签中的字段名称
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// jsonFieldName 返回结构体字段在JSON中的名称，忽略的字段返回空
+func jsonFieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name := strings.SplitN(f.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// titleKey 错误码标题在消息目录中的键
+func titleKey(code ErrorCode) string {
+	return "title." + string(code)
+}
+
+// ProblemType 返回错误码对应的错误类型URI
+func ProblemType(code ErrorCode) string {
+	return ProblemTypeBase + strings.ReplaceAll(strings.ToLower(string(code)), "_", "-")
+}
+
+// ToAppError 将任意错误转换为AppError，非AppError的错误转换为内部错误，原始错误信息不会返回给客户端
+func ToAppError(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return NewInternalError("", err)
+}
+
+// Problem 按指定语言生成RFC 7807错误响应
+func (e *AppError) Problem(lang string) *Problem {
+	status := GetHTTPStatus(e)
+	title := i18n.Translate(lang, titleKey(e.Code), DefaultErrorMessages[e.Code])
+	if title == "" {
+		title = http.StatusText(status)
+	}
+
+	p := &Problem{
+		Type:      ProblemType(e.Code),
+		Title:     title,
+		Status:    status,
+		Detail:    e.LocalizedMessage(lang),
+		Instance:  e.Path,
+		Code:      e.Code,
+		RequestID: e.RequestID,
+		Details:   e.Details,
+		Timestamp: e.Timestamp,
+	}
+	if p.Timestamp == 0 {
+		p.Timestamp = time.Now().Unix()
+	}
+	for _, f := range e.Fields {
+		f.Message = localizeFieldError(lang, f)
+		p.Errors = append(p.Errors, f)
+	}
+	return p
+}
+
+// LocalizedMessage 返回指定语言的错误信息：以源语言信息（或 WithArgs 的格式串）为键查找消息目录，
+// 找不到时返回源语言信息
+func (e *AppError) LocalizedMessage(lang string) string {
+	if e.format == "" {
+		return i18n.Translate(lang, e.Message, e.Message)
+	}
+	return fmt.Sprintf(i18n.Translate(lang, e.format, e.format), e.args...)
+}
+
+// localizeFieldError 返回字段校验错误的本地化信息，规则没有对应消息时使用通用消息
+func localizeFieldError(lang string, f FieldError) string {
+	msg, ok := i18n.Lookup(lang, "validation."+f.Rule)
+	if !ok {
+		msg = i18n.Translate(lang, "validation.default", "{field} 校验失败")
+	}
+	return strings.NewReplacer("{field}", f.Field, "{param}", f.Param).Replace(msg)
+}
+
+// NewBindingError 将Gin参数绑定错误转换为AppError
+// 校验规则错误转换为带字段级错误的 VALIDATION_FAILED，JSON类型错误转换为 VALIDATION_FORMAT_ERROR，
+// 其他绑定错误（如请求体不是合法JSON）转换为 BAD_REQUEST
+func NewBindingError(err error) *AppError {
+	var (
+		validationErrs validator.ValidationErrors
+		typeErr        *json.UnmarshalTypeError
+		syntaxErr      *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &validationErrs):
+		appErr := New(ErrValidationFailed, "", err)
+		for _, fe := range validationErrs {
+			appErr.Fields = append(appErr.Fields, FieldError{
+				Field: fieldPath(fe.Namespace()),
+				Rule:  fe.Tag(),
+				Param: fe.Param(),
+			})
+		}
+		return appErr
+	case errors.As(err, &typeErr):
+		appErr := New(ErrValidationFormat, "", err)
+		appErr.Fields = []FieldError{{Field: typeErr.Field, Rule: "type", Param: typeErr.Type.String()}}
+		return appErr
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return New(ErrBadRequest, "请求体不是合法的JSON", err)
+	default:
+		return New(ErrBadRequest, "", err)
+	}
+}
+
+// fieldPath 去掉校验错误命名空间中的顶层结构体名称（CreateUserRequest.email → email）
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+// RespondError 以 application/problem+json 格式返回错误并中止后续处理
+// 语言由 Accept-Language 请求头决定，响应中包含请求ID和请求路径；
+// 错误同时附加到 c.Errors，由错误处理中间件统一记录日志
+func RespondError(c *gin.Context, err error) {
+	appErr := ToAppError(err)
+	if appErr.RequestID == "" {
+		appErr.RequestID = c.GetString(RequestIDHeader)
+	}
+	if appErr.Path == "" {
+		appErr.Path = c.Request.URL.Path
+	}
+
+	lang := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	problem := appErr.Problem(lang)
+	_ = c.Error(appErr)
+
+	c.Header("Content-Language", lang)
+	c.Render(problem.Status, problemRender{problem})
+	c.Abort()
+}
+
+// WriteProblem 在net/http处理函数中以 application/problem+json 格式返回错误
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := ToAppError(err)
+	if appErr.RequestID == "" {
+		appErr.RequestID = RequestIDFromContext(r.Context())
+	}
+	if appErr.Path == "" {
+		appErr.Path = r.URL.Path
+	}
+
+	lang := i18n.Negotiate(r.Header.Get("Accept-Language"))
+	problem := appErr.Problem(lang)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// problemRender 以 application/problem+json 媒体类型输出错误响应
+type problemRender struct {
+	problem *Problem
+}
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	data, err := json.Marshal(r.problem)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ProblemContentType)
+}
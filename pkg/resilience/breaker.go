@@ -0,0 +1,213 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器处于打开状态，调用被直接拒绝
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State 熔断器状态
+type State int
+
+// 熔断器状态取值与 dependency_circuit_state 指标一致
+const (
+	StateClosed   State = 0
+	StateOpen     State = 1
+	StateHalfOpen State = 2
+)
+
+// String 返回状态名称
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerConfig 熔断器配置
+type BreakerConfig struct {
+	// FailureThreshold 连续失败多少次后打开熔断器
+	FailureThreshold int
+	// OpenTimeout 打开后经过多久进入半开状态
+	OpenTimeout time.Duration
+	// HalfOpenProbes 半开状态下允许同时进行的探测调用数
+	HalfOpenProbes int
+	// SuccessThreshold 半开状态下连续成功多少次后关闭熔断器
+	SuccessThreshold int
+}
+
+// DefaultBreakerConfig 默认熔断器配置
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenProbes:   1,
+		SuccessThreshold: 1,
+	}
+}
+
+// CircuitBreaker 基于连续失败计数的熔断器
+// 关闭状态下连续失败达到阈值后打开；打开超过OpenTimeout后进入半开，
+// 只放行有限的探测调用，探测成功则关闭，失败则重新打开
+type CircuitBreaker struct {
+	config   BreakerConfig
+	onChange func(from, to State)
+
+	mu         sync.Mutex
+	state      State
+	generation uint64 // 每次状态切换递增，用于忽略旧状态下发起的调用结果
+	failures   int
+	successes  int
+	probes     int
+	openedAt   time.Time
+	lastError  string
+}
+
+// NewCircuitBreaker 创建熔断器，onChange在状态切换时调用（可为空）
+func NewCircuitBreaker(config BreakerConfig, onChange func(from, to State)) *CircuitBreaker {
+	defaults := DefaultBreakerConfig()
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = defaults.OpenTimeout
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = defaults.HalfOpenProbes
+	}
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = defaults.SuccessThreshold
+	}
+	return &CircuitBreaker{config: config, onChange: onChange}
+}
+
+// Allow 申请一次调用。允许时返回的done必须在调用结束后执行一次，传入调用结果
+func (b *CircuitBreaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen {
+		if time.Since(b.openedAt) < b.config.OpenTimeout {
+			return nil, ErrCircuitOpen
+		}
+		b.setStateLocked(StateHalfOpen)
+	}
+
+	if b.state == StateHalfOpen {
+		if b.probes >= b.config.HalfOpenProbes {
+			return nil, ErrCircuitOpen
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { b.record(generation, err) })
+	}, nil
+}
+
+// record 记录调用结果，err为nil表示成功
+func (b *CircuitBreaker) record(generation uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 状态已经切换，旧状态下发起的调用不再影响当前状态
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case StateClosed:
+		if err == nil {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		b.lastError = err.Error()
+		if b.failures >= b.config.FailureThreshold {
+			b.setStateLocked(StateOpen)
+		}
+	case StateHalfOpen:
+		b.probes--
+		if err != nil {
+			b.lastError = err.Error()
+			b.setStateLocked(StateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.SuccessThreshold {
+			b.setStateLocked(StateClosed)
+		}
+	}
+}
+
+// setStateLocked 切换状态并重置计数，调用方必须持有锁
+func (b *CircuitBreaker) setStateLocked(to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	b.generation++
+	b.failures = 0
+	b.successes = 0
+	b.probes = 0
+	if to == StateOpen {
+		b.openedAt = time.Now()
+	}
+	if b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
+
+// State 返回当前状态（打开且已超时的熔断器报告为半开）
+func (b *CircuitBreaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.config.OpenTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Reset 强制关闭熔断器
+func (b *CircuitBreaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.setStateLocked(StateClosed)
+	b.lastError = ""
+}
+
+// BreakerSnapshot 熔断器状态快照
+type BreakerSnapshot struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// Snapshot 返回熔断器状态快照
+func (b *CircuitBreaker) Snapshot() BreakerSnapshot {
+	state := b.State()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	snap := BreakerSnapshot{
+		State:               state.String(),
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if state != StateClosed {
+		openedAt := b.openedAt
+		snap.OpenedAt = &openedAt
+	}
+	return snap
+}
@@ -0,0 +1,74 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrBulkheadFull 并发调用数已达上限
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+// Bulkhead 并发隔离：限制对同一依赖的并发调用数，防止慢依赖耗尽调用方资源
+type Bulkhead struct {
+	slots   chan struct{}
+	maxWait time.Duration
+}
+
+// NewBulkhead 创建并发隔离，maxConcurrent<=0 时不限制（返回nil，nil可以安全使用）
+func NewBulkhead(maxConcurrent int, maxWait time.Duration) *Bulkhead {
+	if maxConcurrent <= 0 {
+		return nil
+	}
+	return &Bulkhead{
+		slots:   make(chan struct{}, maxConcurrent),
+		maxWait: maxWait,
+	}
+}
+
+// Acquire 申请一个并发槽位，最多等待maxWait；成功时返回的release必须调用一次
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	if b == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	default:
+	}
+	if b.maxWait <= 0 {
+		return nil, ErrBulkheadFull
+	}
+
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	case <-timer.C:
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Bulkhead) release() {
+	<-b.slots
+}
+
+// InFlight 当前占用的槽位数
+func (b *Bulkhead) InFlight() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.slots)
+}
+
+// Capacity 最大并发数，0 表示不限制
+func (b *Bulkhead) Capacity() int {
+	if b == nil {
+		return 0
+	}
+	return cap(b.slots)
+}
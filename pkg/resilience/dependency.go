@@ -0,0 +1,328 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"weave/config"
+	"weave/pkg"
+	"weave/pkg/metrics"
+
+	"go.uber.org/zap"
+)
+
+// 调用结果，对应 dependency_calls_total 的 result 标签
+const (
+	resultSuccess        = "success"
+	resultFailure        = "failure"
+	resultRejected       = "rejected"
+	resultShortCircuited = "short_circuited"
+)
+
+// Policy 外部依赖的容错策略
+type Policy struct {
+	Retry         RetryPolicy
+	Breaker       BreakerConfig
+	MaxConcurrent int           // 最大并发调用数，0 表示不限制
+	MaxWait       time.Duration // 并发已满时最长等待时间
+}
+
+// DefaultPolicy 默认容错策略
+func DefaultPolicy() Policy {
+	return Policy{
+		Retry:   DefaultRetryPolicy(),
+		Breaker: DefaultBreakerConfig(),
+	}
+}
+
+// PolicyFromConfig 将配置中的依赖策略转换为容错策略，未配置的字段使用默认值
+func PolicyFromConfig(p config.DependencyPolicy) Policy {
+	policy := DefaultPolicy()
+	switch {
+	case p.MaxRetries < 0:
+		policy.Retry.MaxRetries = 0
+	case p.MaxRetries > 0:
+		policy.Retry.MaxRetries = p.MaxRetries
+	}
+	if p.InitialDelayMs > 0 {
+		policy.Retry.InitialDelay = time.Duration(p.InitialDelayMs) * time.Millisecond
+	}
+	if p.MaxDelayMs > 0 {
+		policy.Retry.MaxDelay = time.Duration(p.MaxDelayMs) * time.Millisecond
+	}
+	if p.FailureThreshold > 0 {
+		policy.Breaker.FailureThreshold = p.FailureThreshold
+	}
+	if p.OpenTimeoutSeconds > 0 {
+		policy.Breaker.OpenTimeout = time.Duration(p.OpenTimeoutSeconds) * time.Second
+	}
+	if p.HalfOpenProbes > 0 {
+		policy.Breaker.HalfOpenProbes = p.HalfOpenProbes
+	}
+	if p.SuccessThreshold > 0 {
+		policy.Breaker.SuccessThreshold = p.SuccessThreshold
+	}
+	policy.MaxConcurrent = p.MaxConcurrent
+	policy.MaxWait = time.Duration(p.MaxWaitMs) * time.Millisecond
+	return policy
+}
+
+// Dependency 外部依赖：组合重试、熔断和并发隔离
+// 每次尝试依次经过并发隔离和熔断器，失败后按重试策略退避重试
+type Dependency struct {
+	name     string
+	retry    RetryPolicy
+	breaker  *CircuitBreaker
+	bulkhead *Bulkhead
+}
+
+// NewDependency 创建外部依赖
+func NewDependency(name string, policy Policy) *Dependency {
+	d := &Dependency{
+		name:     name,
+		retry:    policy.Retry,
+		bulkhead: NewBulkhead(policy.MaxConcurrent, policy.MaxWait),
+	}
+	d.breaker = NewCircuitBreaker(policy.Breaker, d.onStateChange)
+	metrics.SetDependencyCircuitState(name, int(StateClosed))
+	return d
+}
+
+// Name 依赖名称
+func (d *Dependency) Name() string {
+	return d.name
+}
+
+// Breaker 依赖的熔断器
+func (d *Dependency) Breaker() *CircuitBreaker {
+	return d.breaker
+}
+
+// Execute 通过容错策略执行调用
+// 上下文中通过WithRetryPolicy覆盖的策略优先于依赖的默认重试策略
+func (d *Dependency) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, err := d.execute(ctx, fn, false)
+	return err
+}
+
+// ExecuteHeld 与 Execute 相同，但调用成功时继续占用并发槽位，直到调用方调用返回的release（只能调用一次）
+// 用于调用返回后结果仍在读取的场景，如流式HTTP响应体；失败时槽位已经释放
+func (d *Dependency) ExecuteHeld(ctx context.Context, fn func(ctx context.Context) error) (release func(), err error) {
+	return d.execute(ctx, fn, true)
+}
+
+// execute 按重试策略执行调用，hold 为 true 时成功调用的并发槽位交给调用方释放
+func (d *Dependency) execute(ctx context.Context, fn func(ctx context.Context) error, hold bool) (func(), error) {
+	policy := d.retry
+	if override, ok := RetryPolicyFromContext(ctx); ok {
+		policy = override
+	}
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			metrics.RecordDependencyRetry(d.name)
+			timer := time.NewTimer(policy.Backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, lastErr
+			case <-timer.C:
+			}
+		}
+
+		release, err := d.attempt(ctx, fn, hold)
+		if err == nil {
+			return release, nil
+		}
+		lastErr = err
+		if attempt >= policy.MaxRetries || !policy.retryable(lastErr) || ctx.Err() != nil {
+			return nil, lastErr
+		}
+	}
+}
+
+// attempt 执行一次调用，hold 为 true 且调用成功时不释放并发槽位，返回释放槽位的函数
+func (d *Dependency) attempt(ctx context.Context, fn func(ctx context.Context) error, hold bool) (func(), error) {
+	acquired, err := d.bulkhead.Acquire(ctx)
+	if err != nil {
+		metrics.RecordDependencyCall(d.name, resultRejected, 0)
+		return nil, err
+	}
+	release := func() {
+		acquired()
+		metrics.SetDependencyInFlight(d.name, d.bulkhead.InFlight())
+	}
+	held := false
+	defer func() {
+		if !held {
+			release()
+		}
+	}()
+	metrics.SetDependencyInFlight(d.name, d.bulkhead.InFlight())
+
+	done, err := d.breaker.Allow()
+	if err != nil {
+		metrics.RecordDependencyCall(d.name, resultShortCircuited, 0)
+		return nil, err
+	}
+
+	start := time.Now()
+	err = fn(ctx)
+	duration := time.Since(start)
+
+	// 永久错误和调用方主动取消不代表依赖故障，不计入熔断
+	if err == nil || IsPermanent(err) || errors.Is(err, context.Canceled) {
+		done(nil)
+	} else {
+		done(err)
+	}
+
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	metrics.RecordDependencyCall(d.name, result, duration)
+	if err != nil {
+		return nil, err
+	}
+	if hold {
+		held = true
+		return release, nil
+	}
+	return func() {}, nil
+}
+
+// onStateChange 熔断器状态切换时更新指标并记录日志
+func (d *Dependency) onStateChange(from, to State) {
+	metrics.SetDependencyCircuitState(d.name, int(to))
+	fields := []zap.Field{
+		zap.String("dependency", d.name),
+		zap.String("from", from.String()),
+		zap.String("to", to.String()),
+	}
+	if to == StateOpen {
+		pkg.Warn("Circuit breaker opened", fields...)
+	} else {
+		pkg.Info("Circuit breaker state changed", fields...)
+	}
+}
+
+// Snapshot 依赖的容错状态快照，用于健康检查
+type Snapshot struct {
+	Name string `json:"name"`
+	BreakerSnapshot
+	InFlight      int `json:"in_flight"`
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+}
+
+// Snapshot 返回依赖的容错状态快照
+func (d *Dependency) Snapshot() Snapshot {
+	return Snapshot{
+		Name:            d.name,
+		BreakerSnapshot: d.breaker.Snapshot(),
+		InFlight:        d.bulkhead.InFlight(),
+		MaxConcurrent:   d.bulkhead.Capacity(),
+	}
+}
+
+// Call 通过依赖的容错策略执行有返回值的调用
+func Call[T any](ctx context.Context, d *Dependency, fn func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := d.Execute(ctx, func(ctx context.Context) error {
+		res, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+		result = res
+		return nil
+	})
+	return result, err
+}
+
+var (
+	registryMu   sync.RWMutex
+	dependencies = make(map[string]*Dependency)
+	policies     = make(map[string]Policy)
+)
+
+// Configure 按配置替换依赖的容错策略，已创建的依赖按新策略重建（熔断状态重置），配置热加载时再次调用
+func Configure(configs []config.DependencyPolicy) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	policies = make(map[string]Policy, len(configs))
+	for _, c := range configs {
+		policies[c.Name] = PolicyFromConfig(c)
+	}
+	for name := range dependencies {
+		dependencies[name] = NewDependency(name, resolvePolicyLocked(name))
+	}
+}
+
+// resolvePolicyLocked 查找依赖的策略：精确名称、冒号前缀、默认策略，调用方需持有registryMu
+func resolvePolicyLocked(name string) Policy {
+	policy, ok := policies[name]
+	if i := strings.IndexByte(name, ':'); !ok && i > 0 {
+		policy, ok = policies[name[:i]]
+	}
+	if !ok {
+		policy = DefaultPolicy()
+	}
+	return policy
+}
+
+// Get 获取指定名称的依赖，不存在时按配置的策略（或默认策略）创建
+// 形如 "mcp:weather" 的名称在没有单独配置时继承前缀 "mcp" 的策略
+func Get(name string) *Dependency {
+	registryMu.RLock()
+	d, ok := dependencies[name]
+	registryMu.RUnlock()
+	if ok {
+		return d
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if d, ok := dependencies[name]; ok {
+		return d
+	}
+	d = NewDependency(name, resolvePolicyLocked(name))
+	dependencies[name] = d
+	return d
+}
+
+// BreakerCheck 返回依赖熔断状态的健康检查函数：熔断器打开时检查失败
+// 依赖尚未被调用过（未注册）时视为正常，检查本身不会访问依赖
+func BreakerCheck(name string) func(ctx context.Context) error {
+	return func(context.Context) error {
+		registryMu.RLock()
+		d, ok := dependencies[name]
+		registryMu.RUnlock()
+		if ok && d.breaker.State() == StateOpen {
+			return fmt.Errorf("%s: %w", name, ErrCircuitOpen)
+		}
+		return nil
+	}
+}
+
+// Snapshots 返回所有已注册依赖的状态快照，按名称排序
+func Snapshots() []Snapshot {
+	registryMu.RLock()
+	deps := make([]*Dependency, 0, len(dependencies))
+	for _, d := range dependencies {
+		deps = append(deps, d)
+	}
+	registryMu.RUnlock()
+
+	sort.Slice(deps, func(i, j int) bool { return deps[i].name < deps[j].name })
+	snapshots := make([]Snapshot, 0, len(deps))
+	for _, d := range deps {
+		snapshots = append(snapshots, d.Snapshot())
+	}
+	return snapshots
+}
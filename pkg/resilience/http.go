@@ -0,0 +1,110 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Transport 经过依赖容错策略的HTTP传输层
+// 网络错误和5xx/429响应计为依赖故障；只有请求体可重放（设置了GetBody）时才会重试。
+// 重试耗尽后返回最后一次的响应，调用方仍按状态码处理。
+// 成功响应的并发槽位保持到响应体读完或关闭，流式响应在整个读取期间都计入并发上限
+type Transport struct {
+	dependency string
+	base       http.RoundTripper
+}
+
+// NewTransport 创建指定依赖的HTTP传输层，base为空时使用 http.DefaultTransport
+func NewTransport(dependency string, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{dependency: dependency, base: base}
+}
+
+// NewHTTPClient 创建经过依赖容错策略的HTTP客户端
+// 不设置整体超时，以免截断流式响应；超时由请求上下文控制
+func NewHTTPClient(dependency string) *http.Client {
+	return &http.Client{Transport: NewTransport(dependency, nil)}
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		ctx = WithoutRetry(ctx)
+	}
+
+	var resp *http.Response
+	attempts := 0
+	release, err := Get(t.dependency).ExecuteHeld(ctx, func(_ context.Context) error {
+		r := req
+		if attempts > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return Permanent(err)
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+		attempts++
+
+		// 丢弃上一次需要重试的响应
+		if resp != nil {
+			drainAndClose(resp.Body)
+			resp = nil
+		}
+
+		res, err := t.base.RoundTrip(r)
+		if err != nil {
+			return err
+		}
+		resp = res
+		if res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests {
+			return &StatusError{StatusCode: res.StatusCode, Status: res.Status}
+		}
+		return nil
+	})
+
+	var statusErr *StatusError
+	if err != nil && !errors.As(err, &statusErr) {
+		if resp != nil {
+			drainAndClose(resp.Body)
+		}
+		return nil, err
+	}
+	if release != nil {
+		resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	}
+	return resp, nil
+}
+
+// releasingBody 读完或关闭时释放并发槽位的响应体
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// drainAndClose 读取并关闭响应体，以便复用连接
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	_ = body.Close()
+}
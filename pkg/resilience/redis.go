@@ -0,0 +1,112 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisHook 让go-redis客户端的命令经过依赖容错策略
+type redisHook struct {
+	dependency string
+}
+
+// NewRedisHook 创建Redis容错钩子，通过 client.AddHook 注册
+// 客户端应设置 MaxRetries: -1 关闭go-redis自身的重试，由依赖策略统一重试
+func NewRedisHook(dependency string) redis.Hook {
+	return &redisHook{dependency: dependency}
+}
+
+// DialHook 建立连接不单独处理，连接失败会体现在命令执行结果中
+func (h *redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// readOnlyCommands 可以安全重试的只读命令
+// 写命令、脚本和事务超时后可能已经执行，重放会重复扣减令牌或重复写入，只经过熔断和并发隔离，不重试
+var readOnlyCommands = map[string]bool{
+	"get": true, "mget": true, "getrange": true, "strlen": true, "exists": true, "type": true,
+	"ttl": true, "pttl": true, "keys": true, "scan": true, "dbsize": true, "ping": true,
+	"hget": true, "hmget": true, "hgetall": true, "hexists": true, "hlen": true, "hkeys": true, "hvals": true, "hscan": true,
+	"lrange": true, "llen": true, "lindex": true,
+	"smembers": true, "sismember": true, "scard": true, "sscan": true,
+	"zrange": true, "zrangebyscore": true, "zrevrange": true, "zrevrangebyscore": true, "zscore": true, "zcard": true, "zcount": true, "zrank": true, "zscan": true,
+	"xrange": true, "xrevrange": true, "xlen": true, "xread": true, "xinfo": true,
+}
+
+// isReadOnly 判断命令是否为只读命令
+func isReadOnly(cmd redis.Cmder) bool {
+	return readOnlyCommands[strings.ToLower(cmd.Name())]
+}
+
+// withRetryFor 只读命令沿用依赖的重试策略，其他命令禁止重试
+func withRetryFor(ctx context.Context, cmds ...redis.Cmder) context.Context {
+	for _, cmd := range cmds {
+		if !isReadOnly(cmd) {
+			return WithoutRetry(ctx)
+		}
+	}
+	return ctx
+}
+
+// ProcessHook 单条命令经过容错策略执行，只有只读命令会重试
+func (h *redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx = withRetryFor(ctx, cmd)
+		var cmdErr error
+		err := Get(h.dependency).Execute(ctx, func(ctx context.Context) error {
+			cmdErr = next(ctx, cmd)
+			return classifyRedisError(cmdErr)
+		})
+		if rejected(err, cmdErr) {
+			cmd.SetErr(err)
+			return err
+		}
+		return cmdErr
+	}
+}
+
+// ProcessPipelineHook 整个管道作为一次调用经过容错策略执行，只有全部为只读命令的管道会重试（事务管道包含 MULTI/EXEC，不重试）
+func (h *redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx = withRetryFor(ctx, cmds...)
+		var cmdErr error
+		err := Get(h.dependency).Execute(ctx, func(ctx context.Context) error {
+			cmdErr = next(ctx, cmds)
+			return classifyRedisError(cmdErr)
+		})
+		if rejected(err, cmdErr) {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		return cmdErr
+	}
+}
+
+// rejected 判断命令是否被熔断或并发隔离拒绝（未执行或最后一次尝试未执行）
+func rejected(err, cmdErr error) bool {
+	if err == nil {
+		return false
+	}
+	return cmdErr == nil || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrBulkheadFull)
+}
+
+// classifyRedisError 区分Redis的业务错误和故障：
+// redis.Nil 不是错误，服务端返回的错误回复（如WRONGTYPE）是调用方错误，不重试也不计入熔断
+func classifyRedisError(err error) error {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return nil
+	}
+	var replyErr redis.Error
+	if errors.As(err, &replyErr) {
+		return Permanent(err)
+	}
+	return err
+}
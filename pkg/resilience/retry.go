@@ -0,0 +1,156 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/textproto"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy 重试策略
+type RetryPolicy struct {
+	// MaxRetries 最大重试次数（不含首次调用），0 表示不重试
+	MaxRetries int
+	// InitialDelay 首次重试前的等待时间
+	InitialDelay time.Duration
+	// MaxDelay 重试等待时间上限
+	MaxDelay time.Duration
+	// Multiplier 指数退避倍数
+	Multiplier float64
+	// Jitter 抖动比例（0~1），等待时间在 [delay*(1-Jitter), delay] 内随机，避免雷群效应
+	Jitter float64
+	// Retryable 判断错误是否可重试，为空时使用 IsRetryable
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy 默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:   3,
+		InitialDelay: 100 * time.Millisecond,
+		MaxDelay:     2 * time.Second,
+		Multiplier:   2.0,
+		Jitter:       0.5,
+		Retryable:    IsRetryable,
+	}
+}
+
+// Backoff 计算第attempt次重试（从1开始）前的等待时间
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 || p.InitialDelay <= 0 {
+		return 0
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay -= delay * jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// retryable 判断错误是否可以按该策略重试
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// retryPolicyKey 请求上下文中重试策略的键
+type retryPolicyKey struct{}
+
+// WithRetryPolicy 在上下文中覆盖依赖的默认重试策略，用于按路由控制出站调用的重试
+func WithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// WithoutRetry 返回禁止重试的上下文，用于非幂等或请求体不可重放的调用
+func WithoutRetry(ctx context.Context) context.Context {
+	return WithRetryPolicy(ctx, RetryPolicy{})
+}
+
+// RetryPolicyFromContext 获取上下文中覆盖的重试策略
+func RetryPolicyFromContext(ctx context.Context) (RetryPolicy, bool) {
+	policy, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy)
+	return policy, ok
+}
+
+// StatusError 依赖返回的可重试状态码（HTTP 5xx/429）
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("dependency returned status %d: %s", e.StatusCode, e.Status)
+}
+
+// permanentError 不可重试、也不计入熔断的错误（调用方错误，而非依赖故障）
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 将错误标记为永久错误：不重试，也不计为依赖故障
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断错误是否为永久错误
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// IsRetryable 默认的可重试判断：网络错误、连接中断、5xx/429 以及SMTP 4xx临时错误
+func IsRetryable(err error) bool {
+	if err == nil || IsPermanent(err) {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrBulkheadFull) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == 429
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return false
+}
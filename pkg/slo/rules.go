@@ -0,0 +1,163 @@
+package slo
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"weave/config"
+
+	"gopkg.in/yaml.v2"
+)
+
+// 记录规则使用的时间窗口
+var ruleWindows = []string{"5m", "30m", "1h", "2h", "6h", "1d", "3d"}
+
+// burnRateAlert 多窗口燃烧率告警定义（参考 Google SRE Workbook）
+type burnRateAlert struct {
+	longWindow  string
+	shortWindow string
+	burnRate    float64
+	forDuration string
+	severity    string
+}
+
+var burnRateAlerts = []burnRateAlert{
+	{longWindow: "1h", shortWindow: "5m", burnRate: 14.4, forDuration: "2m", severity: "page"},
+	{longWindow: "6h", shortWindow: "30m", burnRate: 6, forDuration: "15m", severity: "page"},
+	{longWindow: "1d", shortWindow: "2h", burnRate: 3, forDuration: "1h", severity: "ticket"},
+	{longWindow: "3d", shortWindow: "6h", burnRate: 1, forDuration: "3h", severity: "ticket"},
+}
+
+// RuleFile Prometheus规则文件
+type RuleFile struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+// RuleGroup Prometheus规则组
+type RuleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule Prometheus记录规则或告警规则
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// errorRatioRecord 错误率记录规则名称
+func errorRatioRecord(window string) string {
+	return "slo:sli_error:ratio_rate" + window
+}
+
+// GenerateRules 根据SLO定义生成记录规则和多窗口燃烧率告警规则
+func GenerateRules(objectives []config.SLOObjective) RuleFile {
+	file := RuleFile{Groups: make([]RuleGroup, 0, len(objectives))}
+
+	for _, o := range objectives {
+		group := RuleGroup{Name: "weave-slo-" + o.Name}
+		labels := map[string]string{"slo": o.Name, "slo_type": o.Type}
+
+		for _, w := range ruleWindows {
+			group.Rules = append(group.Rules, Rule{
+				Record: errorRatioRecord(w),
+				Expr:   errorRatioExpr(o, w),
+				Labels: labels,
+			})
+		}
+
+		budget := formatFloat(o.ErrorBudget())
+		for _, a := range burnRateAlerts {
+			threshold := fmt.Sprintf("(%s * %s)", formatFloat(a.burnRate), budget)
+			group.Rules = append(group.Rules, Rule{
+				Alert: "WeaveSLOErrorBudgetBurn",
+				Expr: fmt.Sprintf("%s{slo=%q} > %s\nand\n%s{slo=%q} > %s",
+					errorRatioRecord(a.longWindow), o.Name, threshold,
+					errorRatioRecord(a.shortWindow), o.Name, threshold),
+				For: a.forDuration,
+				Labels: map[string]string{
+					"slo":          o.Name,
+					"severity":     a.severity,
+					"long_window":  a.longWindow,
+					"short_window": a.shortWindow,
+				},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("SLO %s 错误预算消耗过快（燃烧率 > %s）", o.Name, formatFloat(a.burnRate)),
+					"description": fmt.Sprintf("%s 在 %s 和 %s 窗口内的错误率均超过目标 %s%% 允许值的 %s 倍",
+						target(o), a.longWindow, a.shortWindow, formatFloat(o.Objective), formatFloat(a.burnRate)),
+				},
+			})
+		}
+
+		file.Groups = append(file.Groups, group)
+	}
+
+	return file
+}
+
+// RenderRules 将规则渲染为YAML
+func RenderRules(objectives []config.SLOObjective) ([]byte, error) {
+	return yaml.Marshal(GenerateRules(objectives))
+}
+
+// WriteRulesFile 生成规则并写入文件
+func WriteRulesFile(path string, objectives []config.SLOObjective) error {
+	data, err := RenderRules(objectives)
+	if err != nil {
+		return fmt.Errorf("生成SLO规则失败: %w", err)
+	}
+	header := []byte("# 由Weave根据config.yaml中的SLO定义自动生成，请勿手动修改\n")
+	if err := os.WriteFile(path, append(header, data...), 0644); err != nil {
+		return fmt.Errorf("写入SLO规则文件失败: %w", err)
+	}
+	return nil
+}
+
+// errorRatioExpr 生成指定窗口内错误率的PromQL表达式
+func errorRatioExpr(o config.SLOObjective, window string) string {
+	switch {
+	case o.Plugin != "" && o.Type == config.SLOTypeLatency:
+		sel := fmt.Sprintf("plugin_name=%q", o.Plugin)
+		return latencyErrorExpr("plugin_execution_duration_seconds", sel, o.LatencyThreshold, window)
+	case o.Plugin != "":
+		sel := fmt.Sprintf("plugin_name=%q", o.Plugin)
+		return fmt.Sprintf("sum(rate(plugin_execution_total{%s,success=\"false\"}[%s]))\n/\nsum(rate(plugin_execution_total{%s}[%s]))",
+			sel, window, sel, window)
+	case o.Type == config.SLOTypeLatency:
+		sel := routeSelector(o.RouteGroup)
+		return latencyErrorExpr("http_request_duration_seconds", sel, o.LatencyThreshold, window)
+	default:
+		sel := routeSelector(o.RouteGroup)
+		return fmt.Sprintf("sum(rate(http_requests_total{%s,status=~\"5..\"}[%s]))\n/\nsum(rate(http_requests_total{%s}[%s]))",
+			sel, window, sel, window)
+	}
+}
+
+// latencyErrorExpr 延迟SLO的错误率：1 - 阈值桶内请求数 / 总请求数
+func latencyErrorExpr(metric, selector string, threshold float64, window string) string {
+	return fmt.Sprintf("1 - (\n  sum(rate(%s_bucket{%s,le=%q}[%s]))\n  /\n  sum(rate(%s_count{%s}[%s]))\n)",
+		metric, selector, formatFloat(threshold), window, metric, selector, window)
+}
+
+// routeSelector 路由组前缀对应的标签选择器
+func routeSelector(routeGroup string) string {
+	return fmt.Sprintf("endpoint=~%q", regexp.QuoteMeta(routeGroup)+".*")
+}
+
+// target 返回SLO作用对象的描述
+func target(o config.SLOObjective) string {
+	if o.Plugin != "" {
+		return "插件 " + o.Plugin
+	}
+	return "路由组 " + o.RouteGroup
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
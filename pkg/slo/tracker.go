@@ -0,0 +1,319 @@
+package slo
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"weave/config"
+	"weave/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sampleInterval 两次采样的最小间隔，控制长窗口下的内存占用
+const sampleInterval = time.Minute
+
+// 状态中报告的燃烧率窗口
+var statusBurnWindows = map[string]time.Duration{
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"6h": 6 * time.Hour,
+}
+
+// 错误预算状态
+const (
+	BudgetOK        = "ok"
+	BudgetWarning   = "warning"   // 剩余预算不足25%
+	BudgetExhausted = "exhausted" // 预算已耗尽
+)
+
+// sample 某一时刻的累计事件数
+type sample struct {
+	at    time.Time
+	total float64
+	bad   float64
+}
+
+// Status 单个SLO的错误预算状态
+type Status struct {
+	Name             string             `json:"name"`
+	Description      string             `json:"description,omitempty"`
+	Type             string             `json:"type"`
+	RouteGroup       string             `json:"route_group,omitempty"`
+	Plugin           string             `json:"plugin,omitempty"`
+	Objective        float64            `json:"objective"`
+	Window           string             `json:"window"`
+	Coverage         string             `json:"coverage"` // 实际覆盖的时长（进程启动不足一个窗口时小于Window）
+	TotalEvents      float64            `json:"total_events"`
+	BadEvents        float64            `json:"bad_events"`
+	SLI              float64            `json:"sli"`
+	ErrorBudget      float64            `json:"error_budget"`
+	BudgetConsumed   float64            `json:"budget_consumed"`
+	BudgetRemaining  float64            `json:"budget_remaining"`
+	BurnRates        map[string]float64 `json:"burn_rates"`
+	Status           string             `json:"status"`
+	LatencyThreshold float64            `json:"latency_threshold,omitempty"`
+}
+
+// Tracker 基于进程内Prometheus指标计算SLO错误预算
+// 指标是进程内累计计数器，Tracker定期采样以支持按窗口计算
+type Tracker struct {
+	mu         sync.Mutex
+	gatherer   prometheus.Gatherer
+	objectives []config.SLOObjective
+	samples    map[string][]sample
+	startedAt  time.Time
+	now        func() time.Time
+}
+
+// NewTracker 创建SLO跟踪器
+func NewTracker(gatherer prometheus.Gatherer, objectives []config.SLOObjective) *Tracker {
+	return &Tracker{
+		gatherer:   gatherer,
+		objectives: objectives,
+		samples:    make(map[string][]sample),
+		startedAt:  time.Now(),
+		now:        time.Now,
+	}
+}
+
+// DefaultTracker 使用默认注册表的全局跟踪器，随系统指标定期采样
+var DefaultTracker = NewTracker(prometheus.DefaultGatherer, nil)
+
+func init() {
+	metrics.RegisterUpdater(DefaultTracker.Sample)
+}
+
+// SetObjectives 替换SLO定义，已不存在的SLO的采样会被清理
+func (t *Tracker) SetObjectives(objectives []config.SLOObjective) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.objectives = objectives
+	keep := make(map[string]bool, len(objectives))
+	for _, o := range objectives {
+		keep[o.Name] = true
+	}
+	for name := range t.samples {
+		if !keep[name] {
+			delete(t.samples, name)
+		}
+	}
+}
+
+// Objectives 返回当前的SLO定义
+func (t *Tracker) Objectives() []config.SLOObjective {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]config.SLOObjective(nil), t.objectives...)
+}
+
+// Sample 采样所有SLO的累计事件数
+func (t *Tracker) Sample() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.objectives) == 0 {
+		return
+	}
+	families, err := t.gatherer.Gather()
+	if err != nil {
+		return
+	}
+
+	now := t.now()
+	for _, o := range t.objectives {
+		history := t.samples[o.Name]
+		if n := len(history); n > 0 && now.Sub(history[n-1].at) < sampleInterval {
+			continue
+		}
+		total, bad := countEvents(families, o)
+		history = append(history, sample{at: now, total: total, bad: bad})
+
+		// 清理超出窗口的采样（保留一个窗口起点之前的采样作为基线）
+		window, _ := o.WindowDuration()
+		cut := 0
+		for cut+1 < len(history) && now.Sub(history[cut+1].at) >= window {
+			cut++
+		}
+		t.samples[o.Name] = history[cut:]
+	}
+}
+
+// Status 计算所有SLO的当前错误预算状态
+func (t *Tracker) Status() ([]Status, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	families, err := t.gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	now := t.now()
+	result := make([]Status, 0, len(t.objectives))
+	for _, o := range t.objectives {
+		total, bad := countEvents(families, o)
+		current := sample{at: now, total: total, bad: bad}
+		history := t.samples[o.Name]
+
+		window, _ := o.WindowDuration()
+		base := baseline(history, now.Add(-window), t.startedAt)
+
+		st := Status{
+			Name:             o.Name,
+			Description:      o.Description,
+			Type:             o.Type,
+			RouteGroup:       o.RouteGroup,
+			Plugin:           o.Plugin,
+			Objective:        o.Objective,
+			Window:           o.Window,
+			Coverage:         now.Sub(base.at).Truncate(time.Second).String(),
+			TotalEvents:      current.total - base.total,
+			BadEvents:        current.bad - base.bad,
+			ErrorBudget:      o.ErrorBudget(),
+			BurnRates:        make(map[string]float64, len(statusBurnWindows)),
+			LatencyThreshold: o.LatencyThreshold,
+		}
+		if st.Window == "" {
+			st.Window = config.DefaultSLOWindow
+		}
+
+		st.SLI = 1
+		if st.TotalEvents > 0 {
+			st.SLI = 1 - st.BadEvents/st.TotalEvents
+		}
+		if st.ErrorBudget > 0 {
+			st.BudgetConsumed = (1 - st.SLI) / st.ErrorBudget
+		}
+		st.BudgetRemaining = 1 - st.BudgetConsumed
+
+		for name, d := range statusBurnWindows {
+			b := baseline(history, now.Add(-d), t.startedAt)
+			st.BurnRates[name] = burnRate(current.total-b.total, current.bad-b.bad, st.ErrorBudget)
+		}
+
+		switch {
+		case st.BudgetRemaining <= 0:
+			st.Status = BudgetExhausted
+		case st.BudgetRemaining < 0.25:
+			st.Status = BudgetWarning
+		default:
+			st.Status = BudgetOK
+		}
+
+		st.SLI = round(st.SLI)
+		st.BudgetConsumed = round(st.BudgetConsumed)
+		st.BudgetRemaining = round(st.BudgetRemaining)
+		result = append(result, st)
+	}
+	return result, nil
+}
+
+// baseline 返回时间点since之前最近的一次采样；没有则视为进程启动时计数为0
+func baseline(history []sample, since, startedAt time.Time) sample {
+	base := sample{at: startedAt}
+	for _, s := range history {
+		if s.at.After(since) {
+			break
+		}
+		base = s
+	}
+	return base
+}
+
+// burnRate 燃烧率 = 实际错误率 / 允许错误率
+func burnRate(total, bad, budget float64) float64 {
+	if total <= 0 || budget <= 0 {
+		return 0
+	}
+	return round((bad / total) / budget)
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+// countEvents 从指标中统计SLO对应的总事件数和失败事件数
+func countEvents(families []*dto.MetricFamily, o config.SLOObjective) (total, bad float64) {
+	switch {
+	case o.Plugin != "" && o.Type == config.SLOTypeLatency:
+		return countLatency(families, "plugin_execution_duration_seconds", o.LatencyThreshold, pluginMatcher(o.Plugin))
+	case o.Plugin != "":
+		for _, m := range metricsOf(families, "plugin_execution_total") {
+			if !pluginMatcher(o.Plugin)(m) {
+				continue
+			}
+			v := m.GetCounter().GetValue()
+			total += v
+			if labelValue(m, "success") == "false" {
+				bad += v
+			}
+		}
+		return total, bad
+	case o.Type == config.SLOTypeLatency:
+		return countLatency(families, "http_request_duration_seconds", o.LatencyThreshold, routeMatcher(o.RouteGroup))
+	default:
+		for _, m := range metricsOf(families, "http_requests_total") {
+			if !routeMatcher(o.RouteGroup)(m) {
+				continue
+			}
+			v := m.GetCounter().GetValue()
+			total += v
+			if strings.HasPrefix(labelValue(m, "status"), "5") {
+				bad += v
+			}
+		}
+		return total, bad
+	}
+}
+
+// countLatency 统计直方图中超过阈值的请求数
+func countLatency(families []*dto.MetricFamily, name string, threshold float64, match func(*dto.Metric) bool) (total, bad float64) {
+	for _, m := range metricsOf(families, name) {
+		if !match(m) {
+			continue
+		}
+		h := m.GetHistogram()
+		count := float64(h.GetSampleCount())
+		good := count
+		for _, b := range h.GetBucket() {
+			if b.GetUpperBound() == threshold {
+				good = float64(b.GetCumulativeCount())
+				break
+			}
+		}
+		total += count
+		bad += count - good
+	}
+	return total, bad
+}
+
+func metricsOf(families []*dto.MetricFamily, name string) []*dto.Metric {
+	for _, mf := range families {
+		if mf.GetName() == name {
+			return mf.GetMetric()
+		}
+	}
+	return nil
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, lp := range m.GetLabel() {
+		if lp.GetName() == name {
+			return lp.GetValue()
+		}
+	}
+	return ""
+}
+
+func pluginMatcher(plugin string) func(*dto.Metric) bool {
+	return func(m *dto.Metric) bool { return labelValue(m, "plugin_name") == plugin }
+}
+
+func routeMatcher(routeGroup string) func(*dto.Metric) bool {
+	return func(m *dto.Metric) bool { return strings.HasPrefix(labelValue(m, "endpoint"), routeGroup) }
+}
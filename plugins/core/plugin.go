@@ -1,11 +1,15 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"weave/middleware"
+	"weave/pkg/healthcheck"
 	"weave/pkg/metrics"
 
 	"github.com/gin-gonic/gin"
@@ -53,6 +57,17 @@ type Plugin interface {
 	SetPluginManager(manager *PluginManager)  // 设置插件管理器引用
 }
 
+// MemoryReporter 可选接口，插件实现后其内存占用会被导出到 plugin_memory_usage_bytes 指标
+type MemoryReporter interface {
+	MemoryUsage() int64 // 返回插件当前占用的内存（字节）
+}
+
+// HealthChecker 可选接口，插件实现后其检查会加入 /livez、/readyz、/startupz 探针
+// 检查名称会加上 "plugin:<插件名>:" 前缀，仅在插件启用时执行
+type HealthChecker interface {
+	HealthChecks() []healthcheck.Check
+}
+
 // PluginInfo 存储插件信息和路由元数据
 type PluginInfo struct {
 	Plugin       Plugin   // 插件实例
@@ -97,6 +112,11 @@ var GlobalPluginManager = &PluginManager{
 	pluginDir: "plugins", // 默认插件目录
 }
 
+func init() {
+	// 插件指标随系统指标定期刷新
+	metrics.RegisterUpdater(GlobalPluginManager.RefreshMetrics)
+}
+
 // SetRouter 设置路由引擎
 func (pm *PluginManager) SetRouter(router *gin.Engine) {
 	pm.mutex.Lock()
@@ -149,6 +169,7 @@ func (pm *PluginManager) Register(plugin Plugin) error {
 	}
 
 	pm.plugins[name] = info
+	pm.updatePluginMetricsLocked()
 
 	// 如果路由引擎已设置，自动注册路由
 	if pm.router != nil {
@@ -195,6 +216,7 @@ func (pm *PluginManager) EnablePlugin(name string) error {
 	// 启用插件
 	info.IsEnabled = true
 	pm.plugins[name] = info
+	pm.updatePluginMetricsLocked()
 
 	// 如果路由引擎已设置，注册路由
 	if pm.router != nil && !info.IsRegistered {
@@ -251,6 +273,7 @@ func (pm *PluginManager) DisablePlugin(name string) error {
 	// 禁用插件
 	info.IsEnabled = false
 	pm.plugins[name] = info
+	pm.updatePluginMetricsLocked()
 
 	// 注意：Gin不支持动态删除路由，这里只能标记为禁用
 	// 在ExecutePlugin等方法中会检查IsEnabled状态
@@ -319,6 +342,7 @@ func (pm *PluginManager) ReloadPlugin(name string) error {
 	}
 
 	pm.plugins[name] = newInfo
+	pm.updatePluginMetricsLocked()
 
 	// 如果路由引擎已设置且插件被启用，重新注册路由
 	if pm.router != nil && isEnabled {
@@ -389,6 +413,9 @@ func (pm *PluginManager) registerPluginRoutes(name string) error {
 
 	// 创建插件路由组
 	pluginGroup := pm.router.Group(fmt.Sprintf("/plugins/%s", pluginName))
+	pluginGroup.Use(middleware.PluginContextMiddleware(pluginName))
+	// 自动为所有插件路由添加监控中间件
+	pluginGroup.Use(metrics.PluginMonitoringMiddleware(pluginName))
 
 	// 添加插件默认中间件
 	if defaultMiddlewares := plugin.GetDefaultMiddlewares(); len(defaultMiddlewares) > 0 {
@@ -408,8 +435,12 @@ func (pm *PluginManager) registerPluginRoutes(name string) error {
 
 	// 注册每个路由
 	for _, route := range routes {
-		// 创建路由处理函数链
-		handlers := append(route.Middlewares, route.Handler)
+		// 创建路由处理函数链（限流放在认证之后，以便按用户/租户限流；超时和重试按配置的路由策略生效）
+		handlers := append([]gin.HandlerFunc{
+			middleware.PluginRateLimitMiddleware(pluginName),
+			middleware.PluginRoutePolicyMiddleware(pluginName),
+		}, route.Middlewares...)
+		handlers = append(handlers, route.Handler)
 
 		// 如果需要认证，则在处理链前添加认证中间件
 		if route.AuthRequired {
@@ -464,9 +495,141 @@ func (pm *PluginManager) Unregister(name string) error {
 
 	// 从管理器中删除插件
 	delete(pm.plugins, name)
+	metrics.DeletePluginMetrics(name)
+	pm.updatePluginMetricsLocked()
 	return nil
 }
 
+// ShutdownAll 关闭所有插件，被依赖的插件最后关闭
+// 关闭期间不持有锁，其他请求仍可读取插件状态；ctx结束后不再等待正在关闭的插件，也不再关闭剩余插件
+// 返回所有关闭失败或未关闭的错误
+func (pm *PluginManager) ShutdownAll(ctx context.Context) error {
+	// 在锁内确定关闭顺序并记录插件实例
+	pm.mutex.RLock()
+	graph := make(map[string][]string, len(pm.plugins))
+	instances := make(map[string]Plugin, len(pm.plugins))
+	for name, info := range pm.plugins {
+		graph[name] = info.Dependencies
+		instances[name] = info.Plugin
+	}
+	pm.mutex.RUnlock()
+
+	order, err := topologicalSort(graph)
+	if err != nil {
+		// 存在循环依赖时按名称顺序关闭
+		order = make([]string, 0, len(graph))
+		for name := range graph {
+			order = append(order, name)
+		}
+		sort.Strings(order)
+	}
+
+	var errs []error
+	for i := len(order) - 1; i >= 0; i-- {
+		name := order[i]
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("插件 '%s' 未关闭: %w", name, err))
+			continue
+		}
+
+		// 插件的 Shutdown 不接收 ctx，在单独的 goroutine 中执行，超时后不再等待
+		done := make(chan error, 1)
+		plugin := instances[name]
+		go func() { done <- plugin.Shutdown() }()
+		select {
+		case err := <-done:
+			if err != nil {
+				metrics.RecordPluginError(name, "shutdown_failed")
+				errs = append(errs, fmt.Errorf("插件 '%s' 关闭失败: %w", name, err))
+			}
+		case <-ctx.Done():
+			metrics.RecordPluginError(name, "shutdown_timeout")
+			errs = append(errs, fmt.Errorf("插件 '%s' 关闭超时: %w", name, ctx.Err()))
+			continue
+		}
+
+		// 只在更新状态时加锁，插件可能已在关闭期间被卸载
+		pm.mutex.Lock()
+		if info, ok := pm.plugins[name]; ok {
+			info.IsEnabled = false
+			pm.plugins[name] = info
+		}
+		pm.mutex.Unlock()
+	}
+
+	pm.mutex.RLock()
+	pm.updatePluginMetricsLocked()
+	pm.mutex.RUnlock()
+	return errors.Join(errs...)
+}
+
+// RefreshMetrics 刷新插件数量、启用数量及内存占用指标
+func (pm *PluginManager) RefreshMetrics() {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+	pm.updatePluginMetricsLocked()
+}
+
+// updatePluginMetricsLocked 根据当前插件状态更新指标，调用方需持有锁
+func (pm *PluginManager) updatePluginMetricsLocked() {
+	enabled := 0
+	for name, info := range pm.plugins {
+		if info.IsEnabled {
+			enabled++
+		}
+		if reporter, ok := info.Plugin.(MemoryReporter); ok {
+			metrics.UpdatePluginMemoryUsage(name, reporter.MemoryUsage())
+		}
+	}
+	metrics.UpdatePluginStats(len(pm.plugins), enabled)
+}
+
+// HealthChecks 返回插件系统的健康检查：插件管理器自身的检查和已启用插件通过 HealthChecker 提供的检查
+// 作为 healthcheck 注册表的动态来源，插件启用、禁用或重新加载后自动生效
+func (pm *PluginManager) HealthChecks() []healthcheck.Check {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+
+	checks := []healthcheck.Check{{
+		Name:  "plugin_manager",
+		Check: func(context.Context) error { return pm.checkPlugins() },
+	}}
+	for name, info := range pm.plugins {
+		checker, ok := info.Plugin.(HealthChecker)
+		if !ok || !info.IsEnabled {
+			continue
+		}
+		for _, c := range checker.HealthChecks() {
+			c.Name = "plugin:" + name + ":" + c.Name
+			checks = append(checks, c)
+		}
+	}
+	return checks
+}
+
+// checkPlugins 检查已启用插件的依赖是否均已启用、路由是否已注册
+func (pm *PluginManager) checkPlugins() error {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+
+	var errs []error
+	for name, info := range pm.plugins {
+		if !info.IsEnabled {
+			continue
+		}
+		for _, dep := range info.Dependencies {
+			if depInfo, ok := pm.plugins[dep]; !ok || !depInfo.IsEnabled {
+				errs = append(errs, fmt.Errorf("插件 '%s' 的依赖 '%s' 未启用", name, dep))
+			}
+		}
+		if pm.router != nil && len(info.Routes) > 0 && !info.IsRegistered {
+			errs = append(errs, fmt.Errorf("插件 '%s' 的路由未注册", name))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
 // GetPlugin 获取插件
 func (pm *PluginManager) GetPlugin(name string) (Plugin, bool) {
 	pm.mutex.RLock()
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"weave/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type memoryPlugin struct {
+	*testPlugin
+	bytes int64
+}
+
+func (p *memoryPlugin) MemoryUsage() int64 { return p.bytes }
+
+func TestPluginRoutesAreMonitoredByTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pm := &PluginManager{plugins: make(map[string]PluginInfo), mutex: &sync.RWMutex{}}
+	router := gin.New()
+	pm.SetRouter(router)
+
+	tp := newTestPlugin("metricsP", false)
+	tp.routes = []Route{{
+		Path:    "/items/:id",
+		Method:  "GET",
+		Handler: func(c *gin.Context) { c.String(http.StatusOK, c.Param("id")) },
+	}}
+	if err := pm.Register(tp); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		req, _ := http.NewRequest("GET", "/plugins/metricsP/items/"+id, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// 三次不同ID的请求应归入同一个路由模板标签
+	got := testutil.ToFloat64(metrics.PluginMethodCalls.WithLabelValues("metricsP", "GET /plugins/metricsP/items/:id", "true"))
+	if got != 3 {
+		t.Fatalf("expected 3 calls labelled by route template, got %v", got)
+	}
+
+	families, err := metrics.GatherPluginMetrics("metricsP")
+	if err != nil {
+		t.Fatalf("gather error: %v", err)
+	}
+	if len(families) == 0 {
+		t.Fatalf("expected plugin metric families")
+	}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "plugin_name" && lp.GetValue() != "metricsP" {
+					t.Fatalf("unexpected series for plugin %s in %s", lp.GetValue(), mf.GetName())
+				}
+			}
+		}
+	}
+}
+
+func TestPluginLifecycleUpdatesMetrics(t *testing.T) {
+	pm := &PluginManager{plugins: make(map[string]PluginInfo), mutex: &sync.RWMutex{}}
+
+	mp := &memoryPlugin{testPlugin: newTestPlugin("memP", false), bytes: 2048}
+	if err := pm.Register(mp); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.PluginMemoryUsage.WithLabelValues("memP")); got != 2048 {
+		t.Fatalf("expected memory usage 2048, got %v", got)
+	}
+
+	if err := pm.DisablePlugin("memP"); err != nil {
+		t.Fatalf("disable error: %v", err)
+	}
+	families, _ := metrics.GatherPluginMetrics("memP")
+	if len(families) == 0 {
+		t.Fatalf("expected metrics for memP before unregister")
+	}
+
+	if err := pm.Unregister("memP"); err != nil {
+		t.Fatalf("unregister error: %v", err)
+	}
+	families, _ = metrics.GatherPluginMetrics("memP")
+	if len(families) != 0 {
+		t.Fatalf("expected metrics for memP removed after unregister, got %d families", len(families))
+	}
+}
@@ -1,10 +1,12 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"weave/pkg"
 
@@ -1070,3 +1072,99 @@ func TestTopologicalSortWithIsolatedNodes(t *testing.T) {
 		t.Fatalf("B should come before C, got B at %d and C at %d", bIndex, cIndex)
 	}
 }
+
+// orderedShutdownPlugin 记录插件关闭顺序
+type orderedShutdownPlugin struct {
+	*testPlugin
+	log *[]string
+}
+
+func (p *orderedShutdownPlugin) Shutdown() error {
+	*p.log = append(*p.log, p.name)
+	return p.testPlugin.Shutdown()
+}
+
+func TestShutdownAllClosesDependentsFirst(t *testing.T) {
+	pm := &PluginManager{plugins: make(map[string]PluginInfo), mutex: &sync.RWMutex{}}
+	var order []string
+	base := &orderedShutdownPlugin{testPlugin: newTestPlugin("base", false), log: &order}
+	mid := &orderedShutdownPlugin{testPlugin: newTestPlugin("mid", false), log: &order}
+	mid.deps = []string{"base"}
+	top := &orderedShutdownPlugin{testPlugin: newTestPlugin("top", false), log: &order}
+	top.deps = []string{"mid"}
+	top.shutdownError = errors.New("boom")
+	for _, p := range []Plugin{base, mid, top} {
+		if err := pm.Register(p); err != nil {
+			t.Fatalf("register %s error: %v", p.Name(), err)
+		}
+	}
+
+	err := pm.ShutdownAll(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "top") {
+		t.Fatalf("expected shutdown error from top, got %v", err)
+	}
+	if strings.Join(order, ",") != "top,mid,base" {
+		t.Fatalf("expected dependents to shut down first, got %v", order)
+	}
+	if info, _ := pm.GetPluginInfo("base"); info.IsEnabled {
+		t.Fatal("expected plugins to be disabled after shutdown")
+	}
+}
+
+// blockingShutdownPlugin 关闭时一直阻塞，直到 release 被关闭
+type blockingShutdownPlugin struct {
+	*testPlugin
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingShutdownPlugin) Shutdown() error {
+	close(p.started)
+	<-p.release
+	return nil
+}
+
+func TestShutdownAllDoesNotHoldLockAndStopsOnContextDone(t *testing.T) {
+	pm := &PluginManager{plugins: make(map[string]PluginInfo), mutex: &sync.RWMutex{}}
+	base := newTestPlugin("base", false)
+	top := &blockingShutdownPlugin{testPlugin: newTestPlugin("top", false), started: make(chan struct{}), release: make(chan struct{})}
+	top.deps = []string{"base"}
+	defer close(top.release)
+	for _, p := range []Plugin{base, top} {
+		if err := pm.Register(p); err != nil {
+			t.Fatalf("register %s error: %v", p.Name(), err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- pm.ShutdownAll(ctx) }()
+
+	// 插件关闭期间仍可读取和修改插件状态
+	<-top.started
+	locked := make(chan struct{})
+	go func() {
+		pm.GetPluginInfo("top")
+		pm.mutex.Lock()
+		pm.mutex.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("plugin manager lock is held while a plugin shuts down")
+	}
+
+	select {
+	case err := <-done:
+		if err == nil || !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "base") {
+			t.Fatalf("expected timeout and base left running, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ShutdownAll did not return after ctx was done")
+	}
+	if base.shutdownCalled != 0 {
+		t.Fatal("expected remaining plugins to be skipped after ctx was done")
+	}
+}
@@ -2,6 +2,7 @@ package examples
 
 import (
 	"fmt"
+	"weave/pkg"
 	"weave/plugins/core"
 
 	"github.com/gin-gonic/gin"
@@ -146,7 +147,7 @@ func (p *SampleDependentPlugin) handleUseDependency(c *gin.Context) {
 
 	result, err := p.pluginManager.ExecutePlugin("sample_optimized", params)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		pkg.RespondError(c, pkg.NewPluginExecutionError("调用依赖插件失败", err))
 		return
 	}
 
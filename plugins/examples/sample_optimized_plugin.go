@@ -192,7 +192,7 @@ func (p *SampleOptimizedPlugin) handleEcho(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
@@ -241,8 +241,7 @@ func (p *SampleOptimizedPlugin) validateEchoRequest(c *gin.Context) {
 		}
 
 		if err := c.ShouldBindJSON(&request); err != nil {
-			c.JSON(400, gin.H{"error": "消息不能为空"})
-			c.Abort()
+			pkg.RespondError(c, pkg.NewBindingError(err))
 			return
 		}
 
@@ -1,8 +1,6 @@
 package features
 
 import (
-	"errors"
-	"fmt"
 	"math"
 	"strconv"
 	"sync"
@@ -136,16 +134,16 @@ func (p *NotePlugin) Execute(params map[string]interface{}) (interface{}, error)
 		if noteID, ok := params["id"].(string); ok {
 			return p.getNote(userID, tenantID, noteID)
 		}
-		return nil, errors.New("缺少笔记ID参数")
+		return nil, pkg.NewValidationRequiredError("缺少笔记ID参数", nil)
 
 	case "create":
 		if title, ok := params["title"].(string); ok && title != "" {
 			if content, ok := params["content"].(string); ok && content != "" {
 				return p.createNote(userID, tenantID, title, content)
 			}
-			return nil, errors.New("内容不能为空")
+			return nil, pkg.NewValidationRequiredError("内容不能为空", nil)
 		}
-		return nil, errors.New("标题不能为空")
+		return nil, pkg.NewValidationRequiredError("标题不能为空", nil)
 
 	case "update":
 		if noteID, ok := params["id"].(string); ok {
@@ -153,17 +151,17 @@ func (p *NotePlugin) Execute(params map[string]interface{}) (interface{}, error)
 				if content, ok := params["content"].(string); ok && content != "" {
 					return p.updateNote(userID, tenantID, noteID, title, content)
 				}
-				return nil, errors.New("内容不能为空")
+				return nil, pkg.NewValidationRequiredError("内容不能为空", nil)
 			}
-			return nil, errors.New("标题不能为空")
+			return nil, pkg.NewValidationRequiredError("标题不能为空", nil)
 		}
-		return nil, errors.New("缺少笔记ID参数")
+		return nil, pkg.NewValidationRequiredError("缺少笔记ID参数", nil)
 
 	case "delete":
 		if noteID, ok := params["id"].(string); ok {
 			return p.deleteNoteHandler(userID, tenantID, noteID)
 		}
-		return nil, errors.New("缺少笔记ID参数")
+		return nil, pkg.NewValidationRequiredError("缺少笔记ID参数", nil)
 
 	case "search":
 		keyword := ""
@@ -220,12 +218,12 @@ func (p *NotePlugin) listNotes(userID uint, tenantID uint, page, pageSize int) (
 
 	if err := db.Model(&models.Note{}).Count(&total).Error; err != nil {
 		pkg.Error("Database error when counting notes", zap.Error(err))
-		return nil, fmt.Errorf("获取笔记列表失败，请稍后重试")
+		return nil, pkg.NewDatabaseError("获取笔记列表失败，请稍后重试", err)
 	}
 
 	if err := db.Offset(offset).Limit(pageSize).Order("created_time DESC").Find(&notes).Error; err != nil {
 		pkg.Error("Database error when fetching notes", zap.Error(err))
-		return nil, fmt.Errorf("获取笔记列表失败，请稍后重试")
+		return nil, pkg.NewDatabaseError("获取笔记列表失败，请稍后重试", err)
 	}
 
 	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
@@ -248,13 +246,13 @@ func (p *NotePlugin) getNote(userID uint, tenantID uint, noteID string) (interfa
 	// 将string类型的noteID转换为uint类型
 	id, err := strconv.ParseUint(noteID, 10, 32)
 	if err != nil {
-		return nil, fmt.Errorf("无效的笔记ID")
+		return nil, pkg.NewBadRequest("无效的笔记ID", err)
 	}
 
 	var note models.Note
 	db := pkg.DB.Where("id = ? AND user_id = ? AND tenant_id = ?", uint(id), userID, tenantID)
 	if err := db.First(&note).Error; err != nil {
-		return nil, fmt.Errorf("笔记不存在或无权访问")
+		return nil, pkg.NewNotFound("笔记不存在或无权访问", err)
 	}
 	return note, nil
 }
@@ -275,7 +273,7 @@ func (p *NotePlugin) createNote(userID uint, tenantID uint, title, content strin
 
 	if err := pkg.DB.Create(&note).Error; err != nil {
 		pkg.Error("Database error when creating note", zap.Error(err))
-		return nil, fmt.Errorf("创建笔记失败，请稍后重试")
+		return nil, pkg.NewDatabaseError("创建笔记失败，请稍后重试", err)
 	}
 	return note, nil
 }
@@ -288,13 +286,13 @@ func (p *NotePlugin) updateNote(userID uint, tenantID uint, noteID, title, conte
 	// 将string类型的noteID转换为uint类型
 	id, err := strconv.ParseUint(noteID, 10, 32)
 	if err != nil {
-		return nil, fmt.Errorf("无效的笔记ID")
+		return nil, pkg.NewBadRequest("无效的笔记ID", err)
 	}
 
 	var note models.Note
 	db := pkg.DB.Where("id = ? AND user_id = ? AND tenant_id = ?", uint(id), userID, tenantID)
 	if err := db.First(&note).Error; err != nil {
-		return nil, fmt.Errorf("笔记不存在或无权访问")
+		return nil, pkg.NewNotFound("笔记不存在或无权访问", err)
 	}
 
 	note.Title = title
@@ -303,7 +301,7 @@ func (p *NotePlugin) updateNote(userID uint, tenantID uint, noteID, title, conte
 
 	if err := pkg.DB.Save(&note).Error; err != nil {
 		pkg.Error("Database error when updating note", zap.Error(err))
-		return nil, fmt.Errorf("更新笔记失败，请稍后重试")
+		return nil, pkg.NewDatabaseError("更新笔记失败，请稍后重试", err)
 	}
 	return note, nil
 }
@@ -316,18 +314,18 @@ func (p *NotePlugin) deleteNoteHandler(userID uint, tenantID uint, noteID string
 	// 将string类型的noteID转换为uint类型
 	id, err := strconv.ParseUint(noteID, 10, 32)
 	if err != nil {
-		return nil, fmt.Errorf("无效的笔记ID")
+		return nil, pkg.NewBadRequest("无效的笔记ID", err)
 	}
 
 	var note models.Note
 	db := pkg.DB.Where("id = ? AND user_id = ? AND tenant_id = ?", uint(id), userID, tenantID)
 	if err := db.First(&note).Error; err != nil {
-		return nil, fmt.Errorf("笔记不存在或无权访问")
+		return nil, pkg.NewNotFound("笔记不存在或无权访问", err)
 	}
 
 	if err := pkg.DB.Delete(&note).Error; err != nil {
 		pkg.Error("Database error when deleting note", zap.Error(err))
-		return nil, fmt.Errorf("删除笔记失败，请稍后重试")
+		return nil, pkg.NewDatabaseError("删除笔记失败，请稍后重试", err)
 	}
 
 	return gin.H{"message": "删除成功"}, nil
@@ -341,13 +339,13 @@ func (p *NotePlugin) deleteNote(userID uint, tenantID uint, noteID string) error
 	// 将string类型的noteID转换为uint类型
 	id, err := strconv.ParseUint(noteID, 10, 32)
 	if err != nil {
-		return fmt.Errorf("无效的笔记ID")
+		return pkg.NewBadRequest("无效的笔记ID", err)
 	}
 
 	var note models.Note
 	db := pkg.DB
 	if err := db.Where("id = ? AND user_id = ? AND tenant_id = ?", uint(id), userID, tenantID).First(&note).Error; err != nil {
-		return errors.New("笔记不存在或无权访问")
+		return pkg.NewNotFound("笔记不存在或无权访问", err)
 	}
 
 	if err := db.Delete(&note).Error; err != nil {
@@ -378,12 +376,12 @@ func (p *NotePlugin) searchNotes(userID uint, tenantID uint, keyword string, pag
 
 	if err := db.Model(&models.Note{}).Count(&total).Error; err != nil {
 		pkg.Error("Database error when counting search results", zap.Error(err))
-		return nil, fmt.Errorf("搜索笔记失败，请稍后重试")
+		return nil, pkg.NewDatabaseError("搜索笔记失败，请稍后重试", err)
 	}
 
 	if err := db.Offset(offset).Limit(pageSize).Order("created_time DESC").Find(&notes).Error; err != nil {
 		pkg.Error("Database error when searching notes", zap.Error(err))
-		return nil, fmt.Errorf("搜索笔记失败，请稍后重试")
+		return nil, pkg.NewDatabaseError("搜索笔记失败，请稍后重试", err)
 	}
 
 	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
@@ -443,7 +441,7 @@ func (p *NotePlugin) GetRoutes() []core.Route {
 
 				result, err := p.listNotes(userID, tenantID, page, pageSize)
 				if err != nil {
-					c.JSON(500, gin.H{"error": err.Error()})
+					pkg.RespondError(c, err)
 					return
 				}
 				c.JSON(200, result)
@@ -466,7 +464,7 @@ func (p *NotePlugin) GetRoutes() []core.Route {
 
 				result, err := p.getNote(userID, tenantID, id)
 				if err != nil {
-					c.JSON(404, gin.H{"error": err.Error()})
+					pkg.RespondError(c, err)
 					return
 				}
 				c.JSON(200, result)
@@ -490,13 +488,13 @@ func (p *NotePlugin) GetRoutes() []core.Route {
 					Content string `json:"content" binding:"required,min=1"`
 				}
 				if err := c.ShouldBindJSON(&request); err != nil {
-					c.JSON(400, gin.H{"error": err.Error()})
+					pkg.RespondError(c, pkg.NewBindingError(err))
 					return
 				}
 
 				result, err := p.createNote(userID, tenantID, request.Title, request.Content)
 				if err != nil {
-					c.JSON(500, gin.H{"error": err.Error()})
+					pkg.RespondError(c, err)
 					return
 				}
 				c.JSON(201, result)
@@ -518,13 +516,13 @@ func (p *NotePlugin) GetRoutes() []core.Route {
 					Content string `json:"content" binding:"required,min=1"`
 				}
 				if err := c.ShouldBindJSON(&request); err != nil {
-					c.JSON(400, gin.H{"error": err.Error()})
+					pkg.RespondError(c, pkg.NewBindingError(err))
 					return
 				}
 
 				result, err := p.updateNote(userID, tenantID, id, request.Title, request.Content)
 				if err != nil {
-					c.JSON(404, gin.H{"error": err.Error()})
+					pkg.RespondError(c, err)
 					return
 				}
 				c.JSON(200, result)
@@ -543,7 +541,7 @@ func (p *NotePlugin) GetRoutes() []core.Route {
 
 				result, err := p.deleteNoteHandler(userID, tenantID, id)
 				if err != nil {
-					c.JSON(404, gin.H{"error": err.Error()})
+					pkg.RespondError(c, err)
 					return
 				}
 				c.JSON(200, result)
@@ -564,7 +562,7 @@ func (p *NotePlugin) GetRoutes() []core.Route {
 
 				result, err := p.searchNotes(userID, tenantID, keyword, page, pageSize)
 				if err != nil {
-					c.JSON(500, gin.H{"error": err.Error()})
+					pkg.RespondError(c, err)
 					return
 				}
 				c.JSON(200, result)
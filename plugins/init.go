@@ -2,6 +2,8 @@ package plugins
 
 import (
 	"fmt"
+	"time"
+
 	"weave/config"
 	"weave/pkg"
 	"weave/plugins/core"
@@ -68,6 +70,12 @@ func InitPluginSystem() error {
 		// 设置插件监控器
 		PluginManager.SetPluginWatcher(pw)
 
+		// 配置热加载时调整扫描间隔
+		config.Subscribe("plugin_watcher", func(_, c *config.AppConfig) {
+			pw.SetScanInterval(time.Duration(c.Plugins.ScanInterval) * time.Second)
+			pkg.Info("插件扫描间隔已更新", zap.Int("seconds", c.Plugins.ScanInterval))
+		}, "Plugins.ScanInterval")
+
 		// 启动插件监控器
 		if err := pw.Start(); err != nil {
 			return err
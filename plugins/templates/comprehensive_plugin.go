@@ -317,7 +317,7 @@ func (p *ComprehensivePlugin) handleEcho(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
 
@@ -367,8 +367,7 @@ func (p *ComprehensivePlugin) validateEchoRequest(c *gin.Context) {
 		}
 
 		if err := c.ShouldBindJSON(&request); err != nil {
-			c.JSON(400, gin.H{"error": "消息不能为空"})
-			c.Abort()
+			pkg.RespondError(c, pkg.NewBindingError(err))
 			return
 		}
 
@@ -43,6 +43,7 @@ type PluginWatcher struct {
 	running      bool
 	stopChan     chan struct{}
 	processChan  chan string
+	intervalChan chan struct{} // 扫描间隔变更通知
 }
 
 // NewPluginWatcher 创建插件监控器
@@ -73,6 +74,7 @@ func NewPluginWatcher(pluginDir string, manager PluginManager, logger *zap.Logge
 		running:      false,
 		stopChan:     make(chan struct{}),
 		processChan:  make(chan string, 100),
+		intervalChan: make(chan struct{}, 1),
 	}
 
 	// 确保插件目录存在
@@ -177,9 +179,31 @@ func (pw *PluginWatcher) watchLoop() {
 	}
 }
 
+// SetScanInterval 运行时调整定期扫描间隔
+func (pw *PluginWatcher) SetScanInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	pw.mu.Lock()
+	pw.scanInterval = interval
+	pw.mu.Unlock()
+
+	select {
+	case pw.intervalChan <- struct{}{}:
+	default:
+	}
+}
+
+// ScanInterval 返回当前扫描间隔
+func (pw *PluginWatcher) ScanInterval() time.Duration {
+	pw.mu.RLock()
+	defer pw.mu.RUnlock()
+	return pw.scanInterval
+}
+
 // scanLoop 定期扫描插件目录
 func (pw *PluginWatcher) scanLoop() {
-	ticker := time.NewTicker(pw.scanInterval)
+	ticker := time.NewTicker(pw.ScanInterval())
 	defer ticker.Stop()
 
 	for {
@@ -187,6 +211,9 @@ func (pw *PluginWatcher) scanLoop() {
 		case <-ticker.C:
 			pw.scanPluginDir()
 
+		case <-pw.intervalChan:
+			ticker.Reset(pw.ScanInterval())
+
 		case <-pw.stopChan:
 			return
 		}
@@ -279,7 +306,7 @@ func (pw *PluginWatcher) handlePluginChange(path string) {
 	// 检查插件是否已注册
 	if _, exists := pw.manager.GetPlugin(pluginName); exists {
 		// 重新加载现有插件
-		if config.Config.Plugins.HotReload {
+		if config.Current().Plugins.HotReload {
 			if err := pw.manager.ReloadPlugin(pluginName); err != nil {
 				pw.logger.Error("重新加载插件失败",
 					zap.String("pluginName", pluginName),
@@ -294,7 +321,7 @@ func (pw *PluginWatcher) handlePluginChange(path string) {
 		}
 	} else {
 		// 注册新插件
-		if config.Config.Plugins.HotReload {
+		if config.Current().Plugins.HotReload {
 			pw.logger.Debug("发现新插件，尝试动态加载", zap.String("pluginName", pluginName))
 			pw.tryLoadNewPlugin(pluginName)
 		} else {
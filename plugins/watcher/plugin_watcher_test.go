@@ -467,3 +467,25 @@ func TestScanPluginDir_EmptyDir(t *testing.T) {
 		t.Fatalf("expected no registered plugins, got %#v", manager.registered)
 	}
 }
+
+func TestSetScanIntervalAtRuntime(t *testing.T) {
+	dir := t.TempDir()
+	pw, err := NewPluginWatcher(dir, newStubManager(), pkg.GetLogger())
+	if err != nil {
+		t.Fatalf("new watcher error: %v", err)
+	}
+	if err := pw.Start(); err != nil {
+		t.Fatalf("start watcher error: %v", err)
+	}
+	defer pw.Stop()
+
+	pw.SetScanInterval(2 * time.Second)
+	if got := pw.ScanInterval(); got != 2*time.Second {
+		t.Fatalf("expected scan interval 2s, got %v", got)
+	}
+	// non-positive values are ignored
+	pw.SetScanInterval(0)
+	if got := pw.ScanInterval(); got != 2*time.Second {
+		t.Fatalf("expected scan interval to stay 2s, got %v", got)
+	}
+}
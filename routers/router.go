@@ -1,16 +1,19 @@
 package routers
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"time"
+	"weave/config"
 	"weave/controllers"
 	"weave/middleware"
 	"weave/pkg"
+	"weave/pkg/lifecycle"
 	"weave/pkg/metrics"
+	"weave/pkg/slo"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // SetupRouter 配置路由
@@ -33,43 +36,37 @@ func SetupRouter(userCtrl *controllers.UserController,
 	mm := metrics.NewMetricsManager()
 
 	// 添加基本中间件
-	router.Use(gin.Recovery()) // 恢复中间件，处理panic
-	router.Use(gin.Logger())   // 使用gin内置的日志中间件
+	// 恢复中间件，处理panic并返回统一格式的错误响应
+	router.Use(gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		pkg.RespondError(c, pkg.NewInternalError("", fmt.Errorf("panic: %v", recovered)))
+	}))
+	router.Use(middleware.RequestIDMiddleware())           // 请求ID及请求级日志记录器
+	router.Use(middleware.NewErrorHandler().HandlerFunc()) // 错误日志，以及通过 c.Error 返回的错误响应
+	// 结构化访问日志（替代gin内置日志，支持采样）
+	router.Use(middleware.AccessLogMiddleware(middleware.DefaultAccessLogConfig()))
 	router.Use(middleware.CORSMiddleware())
 
 	// 注册Prometheus指标导出路由
 	mm.RegisterMetricsRouter(router)
 
-	// 注册插件特定指标路由
-	router.GET("/metrics/plugins/:name", func(c *gin.Context) {
-		_ = c.Param("name")
-		// 创建一个自定义的registry，只包含特定插件的指标
-		registry := prometheus.NewRegistry()
-
-		// 注册插件执行计数指标
-		registry.MustRegister(metrics.PluginExecutionCount)
-		// 注册插件执行时间指标
-		registry.MustRegister(metrics.PluginExecutionDuration)
-		// 注册插件方法调用指标
-		registry.MustRegister(metrics.PluginMethodCalls)
-		// 注册插件错误指标
-		registry.MustRegister(metrics.PluginErrors)
-		// 注册插件内存使用指标
-		registry.MustRegister(metrics.PluginMemoryUsage)
-		// 注册插件重载指标
-		registry.MustRegister(metrics.PluginReloads)
-
-		// 使用自定义registry创建handler
-		handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{
-			EnableOpenMetrics: true,
-		})
+	// 注册插件特定指标路由（只输出该插件的指标序列）
+	router.GET("/metrics/plugins/:name", pluginCtrl.GetPluginMetrics)
 
-		// 输出指标
-		handler.ServeHTTP(c.Writer, c.Request)
-	})
+	// SLO控制器，使用全局SLO跟踪器（随指标更新器定期采样）
+	sloCtrl := controllers.NewSLOController(slo.DefaultTracker)
+	dbCtrl := controllers.NewDatabaseController()
+	configCtrl := controllers.NewConfigController()
 
-	// 启动指标更新器，每30秒更新一次系统指标
+	// 启动指标更新器，每30秒更新一次系统指标，优雅关闭时停止
 	mm.StartMetricsUpdater(30 * time.Second)
+	lifecycle.Register(lifecycle.Hook{
+		Name:  "metrics_updater",
+		Order: lifecycle.OrderBackground,
+		Stop: func(context.Context) error {
+			mm.StopMetricsUpdater()
+			return nil
+		},
+	})
 
 	// 创建一个应用组，为所有其他路由应用完整的中间件链
 	appGroup := router.Group("")
@@ -79,6 +76,8 @@ func SetupRouter(userCtrl *controllers.UserController,
 		appGroup.Use(middleware.CSRFMiddleware())
 		appGroup.Use(mm.HTTPMonitoringMiddleware()) // 添加HTTP请求监控中间件
 		appGroup.Use(pkg.AuditLogMiddleware())      // 添加安全审计日志中间件
+		// 配置文件中的路由超时和重试策略，覆盖所有应用路由（包括未单独添加超时保护的SLO、运维等路由）
+		appGroup.Use(middleware.RoutePolicyMiddleware())
 
 		// 认证相关路由
 		auth := appGroup.Group("/auth")
@@ -87,8 +86,10 @@ func SetupRouter(userCtrl *controllers.UserController,
 			auth.Use(middleware.RetryMiddleware(middleware.DefaultRetryConfig()))
 			auth.Use(middleware.TimeoutMiddleware(middleware.DefaultTimeoutConfig()))
 
-			// 限流保护，为认证接口添加限流：每秒允许10个请求，突发容量20
-			auth.Use(middleware.RateLimiter(10, 20))
+			// 限流保护，为认证接口添加限流：按IP每秒允许10个请求，突发容量20（可通过配置中的auth策略覆盖）
+			auth.Use(middleware.RateLimitMiddleware(config.RateLimitPolicy{
+				Name: "auth", KeyBy: config.RateLimitKeyIP, Rate: 10, Burst: 20,
+			}))
 			auth.POST("/register", userCtrl.Register)
 			auth.POST("/login", userCtrl.Login)
 			auth.POST("/refresh-token", userCtrl.RefreshToken)
@@ -102,8 +103,10 @@ func SetupRouter(userCtrl *controllers.UserController,
 		{
 			// 使用认证中间件
 			api.Use(middleware.AuthMiddleware())
-			// 为API接口添加限流：每秒允许20个请求，突发容量50
-			api.Use(middleware.RateLimiter(20, 50))
+			// 为API接口添加限流：按用户每秒允许20个请求，突发容量50（可通过配置中的api策略或路由组策略覆盖）
+			api.Use(middleware.RateLimitMiddleware(config.RateLimitPolicy{
+				Name: "api", KeyBy: config.RateLimitKeyUser, Rate: 20, Burst: 50,
+			}))
 
 			// 用户相关路由
 			users := api.Group("/users")
@@ -158,19 +161,17 @@ func SetupRouter(userCtrl *controllers.UserController,
 			{
 				// 为工具服务添加重试和超时保护
 				tools.Use(middleware.RetryMiddleware(middleware.DefaultRetryConfig()))
-				tools.Use(middleware.TimeoutMiddleware(middleware.DefaultTimeoutConfig()))
+				// 工具执行接口使用更长的超时时间（按路由模板匹配）
+				toolTimeout := middleware.DefaultTimeoutConfig()
+				toolTimeout.PathTimeouts["/api/v1/tools/:id/execute"] = 60 * time.Second
+				tools.Use(middleware.TimeoutMiddleware(toolTimeout))
 
 				tools.GET("/", toolCtrl.GetTools)
 				tools.GET("/:id", toolCtrl.GetTool)
 				tools.POST("/", toolCtrl.CreateTool)
 				tools.PUT("/:id", toolCtrl.UpdateTool)
 				tools.DELETE("/:id", toolCtrl.DeleteTool)
-				// 工具执行接口使用更严格的超时配置
-				tools.POST("/:id/execute",
-					middleware.TimeoutMiddleware(middleware.TimeoutConfig{
-						DefaultTimeout: 60 * time.Second, // 工具执行使用60秒超时
-					}),
-					toolCtrl.ExecuteTool)
+				tools.POST("/:id/execute", toolCtrl.ExecuteTool)
 			}
 
 			// 插件相关路由
@@ -194,6 +195,24 @@ func SetupRouter(userCtrl *controllers.UserController,
 				plugins.GET("/dependency-graph", pluginCtrl.GetDependencyGraph)
 			}
 
+			// SLO相关路由
+			sloGroup := api.Group("/slo")
+			{
+				sloGroup.GET("", sloCtrl.GetSLOStatus)      // 获取错误预算状态
+				sloGroup.GET("/rules", sloCtrl.GetSLORules) // 获取生成的Prometheus规则
+			}
+
+			// 运维相关路由，仅限运维用户或携带运维令牌的请求
+			admin := api.Group("/admin")
+			{
+				admin.Use(middleware.AdminMiddleware())
+
+				admin.GET("/slow-queries", dbCtrl.GetSlowQueries)      // 获取慢查询指纹排行
+				admin.DELETE("/slow-queries", dbCtrl.ResetSlowQueries) // 清空慢查询统计
+				admin.POST("/config/reload", configCtrl.ReloadConfig)  // 重新加载配置
+				admin.GET("/config/diff", configCtrl.DiffConfig)       // 预览配置变更
+			}
+
 		}
 	}
 
@@ -212,6 +231,10 @@ func SetupRouter(userCtrl *controllers.UserController,
 	appGroup.GET("/health", healthCtrl.GetHealth)
 	// 插件健康检查API
 	appGroup.GET("/health/plugins/:name", healthCtrl.PluginHealthCheck)
+	// 存活、就绪和启动探针，?verbose 列出每项检查
+	appGroup.GET("/livez", healthCtrl.Livez)
+	appGroup.GET("/readyz", healthCtrl.Readyz)
+	appGroup.GET("/startupz", healthCtrl.Startupz)
 
 	return router
-}
\ No newline at end of file
+}
@@ -1,11 +1,24 @@
 // 配置
 const config = {
     apiBaseURL: 'http://localhost:8080',
-    userId: 'default_user',
+    // Weave 登录后获得的访问令牌，聊天接口根据令牌识别用户
+    accessToken: localStorage.getItem('access_token') || '',
     streaming: true,
     maxImagesPerRequest: 5
 };
 
+// 带认证头的请求头
+function authHeaders(headers = {}) {
+    return { ...headers, 'Authorization': `Bearer ${config.accessToken}` };
+}
+
+// 打开聊天 WebSocket 连接
+// 浏览器无法为 WebSocket 设置认证头，访问令牌通过 weave-token. 子协议传递，服务端选用 weave-chat 子协议
+function openChatSocket() {
+    const url = `${config.apiBaseURL.replace(/^http/, 'ws')}/api/chat/ws`;
+    return new WebSocket(url, ['weave-chat', `weave-token.${config.accessToken}`]);
+}
+
 // DOM元素
 const chatMessages = document.getElementById('chatMessages');
 const messageInput = document.getElementById('messageInput');
@@ -25,6 +38,10 @@ let isLoading = false;
 let isConnected = false;
 let isStreaming = false;
 let selectedImages = [];
+// 当前对话 ID，由服务端在首次回复时返回
+let conversationId = '';
+// 当前流式生成 ID，控制操作只作用于该生成
+let streamId = '';
 
 // 初始化
 document.addEventListener('DOMContentLoaded', async () => {
@@ -75,7 +92,7 @@ async function loadHistory() {
     }
 
     try {
-        const response = await fetch(`${config.apiBaseURL}/api/chat/history?user_id=${config.userId}`);
+        const response = await fetch(`${config.apiBaseURL}/api/chat/history`, { headers: authHeaders() });
         if (!response.ok) throw new Error('加载历史记录失败');
         
         const data = await response.json();
@@ -108,7 +125,7 @@ async function loadHistoryToModal() {
     historyMessages.innerHTML = '<div style="text-align: center; padding: 20px;">加载中...</div>';
 
     try {
-        const response = await fetch(`${config.apiBaseURL}/api/chat/history?user_id=${config.userId}`);
+        const response = await fetch(`${config.apiBaseURL}/api/chat/history`, { headers: authHeaders() });
         if (!response.ok) {
             throw new Error('获取历史记录失败');
         }
@@ -305,7 +322,7 @@ async function loadHistory() {
     historyMessages.innerHTML = '<div style="text-align: center; padding: 20px;">加载中...</div>';
 
     try {
-        const response = await fetch(`${config.apiBaseURL}/api/chat/history?user_id=${config.userId}`);
+        const response = await fetch(`${config.apiBaseURL}/api/chat/history`, { headers: authHeaders() });
         if (!response.ok) {
             throw new Error('获取历史记录失败');
         }
@@ -396,12 +413,12 @@ async function sendNonStreamMessage(message) {
 
     const response = await fetch(`${config.apiBaseURL}/api/chat`, {
         method: 'POST',
-        headers: {
+        headers: authHeaders({
             'Content-Type': 'application/json'
-        },
+        }),
         body: JSON.stringify({
             user_input: message,
-            user_id: config.userId,
+            conversation_id: conversationId,
             base64_images: base64Images,
             image_urls: []
         })
@@ -410,13 +427,14 @@ async function sendNonStreamMessage(message) {
     if (!response.ok) {
         try {
             const errorData = await response.json();
-            throw new Error(errorData.error || 'API请求失败');
+            throw new Error(errorData.detail || 'API请求失败');
         } catch (e) {
             throw new Error('API请求失败');
         }
     }
     
     const data = await response.json();
+    conversationId = data.conversation_id || conversationId;
     addMessage('assistant', data.content);
 }
 
@@ -432,12 +450,12 @@ async function sendStreamMessage(message) {
 
     const response = await fetch(`${config.apiBaseURL}/api/chat/stream`, {
         method: 'POST',
-        headers: {
+        headers: authHeaders({
             'Content-Type': 'application/json'
-        },
+        }),
         body: JSON.stringify({
             user_input: message,
-            user_id: config.userId,
+            conversation_id: conversationId,
             base64_images: base64Images,
             image_urls: []
         })
@@ -446,7 +464,7 @@ async function sendStreamMessage(message) {
     if (!response.ok) {
         try {
             const errorData = await response.json();
-            throw new Error(errorData.error || 'API请求失败');
+            throw new Error(errorData.detail || 'API请求失败');
         } catch (e) {
             throw new Error('API请求失败');
         }
@@ -469,6 +487,12 @@ async function sendStreamMessage(message) {
                     if (data) {
                         try {
                             const parsed = JSON.parse(data);
+                            if (parsed.conversation_id) {
+                                conversationId = parsed.conversation_id;
+                            }
+                            if (parsed.stream_id) {
+                                streamId = parsed.stream_id;
+                            }
                             if (parsed.error) {
                                 updateMessage(loadingMessage, `错误: ${parsed.error}`);
                                 scrollToBottom();
@@ -507,19 +531,19 @@ async function controlChat(action) {
     try {
         const response = await fetch(`${config.apiBaseURL}/api/chat/control`, {
             method: 'POST',
-            headers: {
+            headers: authHeaders({
                 'Content-Type': 'application/json'
-            },
+            }),
             body: JSON.stringify({
-                user_id: config.userId,
-                action: action
+                    action: action,
+                    stream_id: streamId
             })
         });
 
         if (!response.ok) {
             try {
                 const errorData = await response.json();
-                throw new Error(errorData.error || '控制请求失败');
+                throw new Error(errorData.detail || '控制请求失败');
             } catch (e) {
                 throw new Error('控制请求失败');
             }
@@ -604,13 +628,15 @@ async function clearHistory() {
             await controlChat('stop');
         }
 
-        const response = await fetch(`${config.apiBaseURL}/api/chat/history?user_id=${config.userId}`, {
-            method: 'DELETE'
+        const response = await fetch(`${config.apiBaseURL}/api/chat/history`, {
+            method: 'DELETE',
+            headers: authHeaders()
         });
 
         if (!response.ok) throw new Error('清除历史记录失败');
 
-        // 清空主聊天界面
+        // 清空主聊天界面，下一条消息开始新对话
+        conversationId = '';
         chatMessages.innerHTML = '';
         addMessage('assistant', '聊天历史已清除，有什么可以帮助你的吗？');
         clearSelectedImages();
@@ -644,7 +670,7 @@ async function loadHistory() {
     historyMessages.innerHTML = '<div style="text-align: center; padding: 20px;">加载中...</div>';
 
     try {
-        const response = await fetch(`${config.apiBaseURL}/api/chat/history?user_id=${config.userId}`);
+        const response = await fetch(`${config.apiBaseURL}/api/chat/history`, { headers: authHeaders() });
         if (!response.ok) {
             throw new Error('获取历史记录失败');
         }
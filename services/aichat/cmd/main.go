@@ -183,7 +183,7 @@ func main() {
 		}()
 
 		// 使用服务层处理用户输入
-		_, err := chatService.ProcessUserInputStream(ctx, userInput, userID, streamCallback, controlCallback)
+		_, err := chatService.ProcessUserInputStream(ctx, userInput, userID, "", streamCallback, controlCallback)
 		if err != nil {
 			logger.Error("生成回复失败", zap.Error(err), zap.String("user_id", userID))
 			fmt.Println("抱歉，生成回复失败，请稍后重试。")
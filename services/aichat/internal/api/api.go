@@ -21,44 +21,66 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"strings"
-	"sync"
 	"time"
 
+	"weave/config"
 	"weave/middleware"
 	"weave/pkg"
+	"weave/pkg/healthcheck"
+	"weave/pkg/metrics"
+	"weave/pkg/resilience"
 	"weave/services/aichat/internal/model"
+	"weave/services/aichat/internal/prompts"
+	"weave/services/aichat/internal/resumable"
 	"weave/services/aichat/internal/service/agent"
 	"weave/services/aichat/internal/service/chat"
+	"weave/services/aichat/internal/structured"
 
 	"github.com/cloudwego/eino/schema"
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
 // API Server 结构体
 type APIServer struct {
-	chatService         chat.ChatService
-	router              *gin.Engine
-	addr                string
-	logger              *zap.Logger
-	sessionControlCache *SessionControlCache
+	chatService chat.ChatService
+	router      *gin.Engine
+	addr        string
+	logger      *zap.Logger
+	streams     resumable.Store // 流式生成的状态和事件，客户端断线后可以继续读取
+	auth        AuthConfig
+	startedAt   time.Time // 服务启动时间，作为模型列表中的创建时间
 }
 
 // Request/Response 结构体定义
 
+// responseSchemaName 聊天接口要求结构化输出时传给模型提供方的模式名称
+const responseSchemaName = "chat_response"
+
 // ChatRequest 聊天请求结构
 type ChatRequest struct {
-	UserInput    string   `json:"user_input" binding:"required"`
-	UserID       string   `json:"user_id" binding:"required"`
-	ImageURLs    []string `json:"image_urls"`    // 图片 URL 列表
-	Base64Images []string `json:"base64_images"` // Base64 编码的图片列表
+	UserInput      string   `json:"user_input" binding:"required"`
+	ConversationID string   `json:"conversation_id"`                                            // 对话 ID，为空时沿用最近的对话或创建新对话
+	ImageURLs      []string `json:"image_urls"`                                                 // 图片 URL 列表
+	Base64Images   []string `json:"base64_images"`                                              // Base64 编码的图片列表
+	Model          string   `json:"model"`                                                      // 指定的模型，为空时按路由规则选择
+	Preference     string   `json:"preference" binding:"omitempty,oneof=priority cost latency"` // 覆盖默认的路由偏好
+	// 要求回复符合的 JSON Schema，回复不符合时要求模型修正，修正后仍不符合时返回 422
+	ResponseSchema json.RawMessage `json:"response_schema"`
 }
 
 // ChatResponse 聊天响应结构
 type ChatResponse struct {
-	Content string `json:"content"`
-	Status  string `json:"status"`
+	Content        string `json:"content"`
+	Status         string `json:"status"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	Model          string `json:"model,omitempty"`           // 实际响应请求的模型
+	PromptTemplate string `json:"prompt_template,omitempty"` // 生成回复使用的提示词模板版本
+	// 请求指定 response_schema 时为校验通过的 JSON，与 content 相同
+	Data json.RawMessage `json:"data,omitempty"`
+	// 流式生成的 ID，用于断线重连和控制
+	StreamID string `json:"stream_id,omitempty"`
 }
 
 // ChatHistoryResponse 聊天历史响应结构
@@ -67,114 +89,17 @@ type ChatHistoryResponse struct {
 	Count    int               `json:"count"`
 }
 
-// ErrorResponse 错误响应结构
-type ErrorResponse struct {
-	Error  string `json:"error"`
-	Status string `json:"status"`
-}
-
 // ChatControlRequest 聊天控制请求结构
 type ChatControlRequest struct {
-	UserID string `json:"user_id" binding:"required"`
-	Action string `json:"action" binding:"required,oneof=pause resume continue stop"` // action: pause, resume, continue, stop
+	Action   string `json:"action" binding:"required,oneof=pause resume continue stop"` // action: pause, resume, continue, stop
+	StreamID string `json:"stream_id"`                                                  // 要控制的生成，为空时控制最近发起的生成
 }
 
 // ChatControlResponse 聊天控制响应结构
 type ChatControlResponse struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
-}
-
-// SessionControl 会话控制状态
-type SessionControl struct {
-	IsPaused   bool
-	IsStopped  bool
-	LastActive time.Time
-	Mutex      sync.Mutex
-}
-
-// SessionControlCache 会话控制缓存
-type SessionControlCache struct {
-	cache         map[string]*SessionControl
-	mutex         sync.Mutex
-	cleanupTicker *time.Ticker
-	cleanupDone   chan struct{}
-}
-
-// NewSessionControlCache 创建新的会话控制缓存
-func NewSessionControlCache() *SessionControlCache {
-	cache := &SessionControlCache{
-		cache:         make(map[string]*SessionControl),
-		cleanupTicker: time.NewTicker(5 * time.Minute),
-		cleanupDone:   make(chan struct{}),
-	}
-
-	// 启动清理过期会话的goroutine
-	go cache.cleanupExpiredSessions()
-
-	return cache
-}
-
-// cleanupExpiredSessions 清理过期会话（超过1小时不活跃）
-func (c *SessionControlCache) cleanupExpiredSessions() {
-	for {
-		select {
-		case <-c.cleanupTicker.C:
-			c.mutex.Lock()
-			for userID, control := range c.cache {
-				if time.Since(control.LastActive) > 1*time.Hour {
-					delete(c.cache, userID)
-				}
-			}
-			c.mutex.Unlock()
-		case <-c.cleanupDone:
-			return
-		}
-	}
-}
-
-// GetSessionControl 获取用户会话控制
-func (c *SessionControlCache) GetSessionControl(userID string) *SessionControl {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	control, exists := c.cache[userID]
-	if !exists {
-		control = &SessionControl{
-			LastActive: time.Now(),
-		}
-		c.cache[userID] = control
-	}
-
-	return control
-}
-
-// UpdateStatus 更新会话状态
-func (c *SessionControlCache) UpdateStatus(userID, action string) error {
-	control := c.GetSessionControl(userID)
-	control.Mutex.Lock()
-	defer control.Mutex.Unlock()
-	control.LastActive = time.Now()
-
-	switch action {
-	case "pause":
-		control.IsPaused = true
-	case "resume", "continue":
-		control.IsPaused = false
-	case "stop":
-		control.IsStopped = true
-		control.IsPaused = false
-	default:
-		return errors.New("无效的操作")
-	}
-
-	return nil
-}
-
-// Close 关闭会话控制缓存
-func (c *SessionControlCache) Close() {
-	c.cleanupTicker.Stop()
-	close(c.cleanupDone)
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Stream  *resumable.Info `json:"stream,omitempty"` // 控制后的生成状态
 }
 
 // NewAPIServer 创建API服务器
@@ -183,29 +108,69 @@ func NewAPIServer(chatService chat.ChatService, addr string) *APIServer {
 	gin.SetMode(gin.ReleaseMode)
 
 	server := &APIServer{
-		chatService:         chatService,
-		sessionControlCache: NewSessionControlCache(),
-		router:              gin.Default(),
-		addr:                addr,
-		logger:              pkg.GetLogger(),
+		chatService: chatService,
+		router:      gin.Default(),
+		addr:        addr,
+		logger:      pkg.GetLogger(),
+		startedAt:   time.Now(),
 	}
 
-	// 添加CORS中间件
-	server.router.Use(middleware.CORSMiddleware())
+	// 生成事件存储：CACHE_TYPE 为 redis 时保存在 Redis 中，其他实例也可以继续读取和控制生成
+	streams, err := resumable.NewStore(context.Background(), nil, resumable.TTLFromConfig())
+	if err != nil {
+		server.logger.Warn("连接生成事件存储失败，使用内存存储", zap.Error(err))
+		streams = resumable.NewMemoryStore(resumable.TTLFromConfig())
+	}
+	server.streams = streams
+
+	// 加载认证配置：未配置JWT密钥时只能通过服务令牌访问聊天接口
+	auth, err := LoadAuthConfig()
+	if err != nil {
+		server.logger.Error("加载aichat认证配置失败，聊天接口将拒绝所有请求", zap.Error(err))
+	} else if auth.JWTSecret == "" {
+		server.logger.Warn("未配置 AICHAT_JWT_SECRET 或 JWT_SECRET，无法验证 Weave 访问令牌")
+	}
+	server.auth = auth
+
+	// 添加请求ID和CORS中间件，请求ID同时用于错误响应
+	server.router.Use(middleware.RequestIDMiddleware(), middleware.CORSMiddleware())
 
 	// 注册路由
 	server.registerRoutes()
+	registerHealthChecks()
 
 	return server
 }
 
+// registerHealthChecks 注册上游模型服务的健康检查
+// 上游由所有实例共享，熔断时只标记为degraded，避免所有实例同时从负载均衡中摘除
+// 注册表中每个模型使用单独的熔断器，所有模型都熔断时聊天模型不可用
+func registerHealthChecks() {
+	healthcheck.Register(healthcheck.Check{
+		Name: "llm_upstream",
+		Check: func(ctx context.Context) error {
+			registry, err := model.DefaultRegistry(ctx)
+			if err != nil {
+				return err
+			}
+			return registry.CheckBreakers(ctx)
+		},
+		CacheTTL: -1,
+	})
+	healthcheck.Register(healthcheck.Check{
+		Name:     "embedding_upstream",
+		Check:    resilience.BreakerCheck(config.DependencyEmbedding),
+		CacheTTL: -1,
+	})
+}
+
 // registerRoutes 注册API路由
 func (s *APIServer) registerRoutes() {
 	// API 分组
 	api := s.router.Group("/api")
 
 	// 聊天相关路由
-	chat := api.Group("/chat").Use(middleware.RateLimiter(20, 30))
+	chat := api.Group("/chat").Use(AuthMiddleware(s.auth), middleware.RateLimitMiddleware(chatRateLimitPolicy()))
 	{
 		// 非流式聊天接口
 		chat.POST("", s.handleChat)
@@ -219,6 +184,58 @@ func (s *APIServer) registerRoutes() {
 
 		// 聊天控制接口
 		chat.POST("/control", s.handleChatControl)
+
+		// 生成状态、断线重连和控制接口
+		chat.GET("/streams/:id", s.handleGetStream)
+		chat.GET("/streams/:id/events", s.handleStreamEvents)
+		chat.POST("/streams/:id/control", s.handleStreamControl)
+
+		// WebSocket 接口，同一连接上可以发起多个生成并控制
+		chat.GET("/ws", s.handleChatWebSocket)
+
+		// 令牌用量和费用
+		chat.GET("/usage", s.handleGetUsage)
+	}
+
+	// 对话管理路由
+	conversations := api.Group("/conversations").Use(AuthMiddleware(s.auth), middleware.RateLimitMiddleware(chatRateLimitPolicy()))
+	{
+		conversations.POST("", s.handleCreateConversation)
+		conversations.GET("", s.handleListConversations)
+		conversations.GET("/search", s.handleSearchConversations)
+		conversations.GET("/:id", s.handleGetConversation)
+		conversations.PATCH("/:id", s.handleUpdateConversation)
+		conversations.DELETE("/:id", s.handleDeleteConversation)
+		conversations.POST("/:id/fork", s.handleForkConversation)
+		conversations.GET("/:id/prompt", s.handlePreviewPrompt)
+	}
+
+	// 提示词模板和人设路由，修改只允许服务令牌
+	promptRoutes := api.Group("/prompts").Use(AuthMiddleware(s.auth), middleware.RateLimitMiddleware(chatRateLimitPolicy()))
+	{
+		promptRoutes.GET("/templates", s.handleListTemplates)
+		promptRoutes.POST("/templates", s.handleCreateTemplate)
+		promptRoutes.PATCH("/templates/:name/versions/:version", s.handleUpdateTemplateVersion)
+		promptRoutes.GET("/personas", s.handleListPersonas)
+		promptRoutes.PUT("/personas/:name", s.handleSavePersona)
+		promptRoutes.DELETE("/personas/:name", s.handleDeletePersona)
+	}
+
+	// 长期记忆路由，记忆只对同一租户下的同一用户可见
+	memories := api.Group("/memories").Use(AuthMiddleware(s.auth), middleware.RateLimitMiddleware(chatRateLimitPolicy()))
+	{
+		memories.GET("", s.handleListMemories)
+		memories.POST("", s.handleCreateMemory)
+		memories.DELETE("", s.handleClearMemories)
+		memories.PATCH("/:id", s.handleUpdateMemory)
+		memories.DELETE("/:id", s.handleDeleteMemory)
+	}
+
+	// OpenAI 兼容路由：历史消息由调用方维护，经过同样的认证和限流
+	openai := s.router.Group("/v1").Use(AuthMiddleware(s.auth), middleware.RateLimitMiddleware(chatRateLimitPolicy()))
+	{
+		openai.POST("/chat/completions", s.handleChatCompletions)
+		openai.GET("/models", s.handleListModels)
 	}
 
 	// 工具健康检查路由
@@ -229,79 +246,112 @@ func (s *APIServer) registerRoutes() {
 	}
 
 	// 健康检查
+	// Prometheus 指标
+	metrics.NewMetricsManager().RegisterMetricsRouter(s.router)
+
 	s.router.GET("/health", s.handleHealthCheck)
+	s.router.GET("/livez", s.handleProbe(healthcheck.Liveness))
+	s.router.GET("/readyz", s.handleProbe(healthcheck.Readiness))
+	s.router.GET("/startupz", s.handleProbe(healthcheck.Startup))
 
 	// Agent
 	s.router.GET("/agent/health", s.handleAgentHealthCheck)
 }
 
-// handleChatControl 处理聊天控制请求
+// chatRateLimitPolicy 聊天接口按用户限流的策略
+// 速率和容量可通过 AICHAT_RATE_LIMIT_RATE（每秒令牌数）和 AICHAT_RATE_LIMIT_BURST 配置
+func chatRateLimitPolicy() config.RateLimitPolicy {
+	rate := viper.GetFloat64("AICHAT_RATE_LIMIT_RATE")
+	if rate <= 0 {
+		rate = 20
+	}
+	burst := viper.GetInt("AICHAT_RATE_LIMIT_BURST")
+	if burst <= 0 {
+		burst = 30
+	}
+	return config.RateLimitPolicy{
+		Name:      "aichat_chat",
+		KeyBy:     config.RateLimitKeyUser,
+		Algorithm: config.RateLimitTokenBucket,
+		Rate:      rate,
+		Burst:     burst,
+	}
+}
+
+// handleChatControl 处理聊天控制请求，未指定 stream_id 时控制最近发起的生成
 func (s *APIServer) handleChatControl(c *gin.Context) {
 	var req ChatControlRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:  "请求参数无效: " + err.Error(),
-			Status: "error",
-		})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
+	sessionKey := principalFrom(c).Key()
 
-	// 更新会话状态
-	err := s.sessionControlCache.UpdateStatus(req.UserID, req.Action)
-	if err != nil {
-		s.logger.Error("更新会话状态失败", zap.Error(err), zap.String("user_id", req.UserID))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:  "更新会话状态失败: " + err.Error(),
-			Status: "error",
-		})
+	if req.StreamID != "" {
+		info, appErr := s.ownedStream(c.Request.Context(), sessionKey, req.StreamID)
+		if appErr != nil {
+			pkg.RespondError(c, appErr)
+			return
+		}
+		s.respondControl(c, sessionKey, info, req.Action)
 		return
 	}
-
-	// 返回成功响应
-	response := ChatControlResponse{
-		Status:  "success",
-		Message: "已成功执行 " + req.Action + " 操作",
+	info, err := s.streams.Latest(c.Request.Context(), sessionKey)
+	if err != nil {
+		pkg.RespondError(c, streamError(err, "获取生成失败"))
+		return
 	}
-	c.JSON(http.StatusOK, response)
+	s.respondControl(c, sessionKey, info, req.Action)
 }
 
 // handleChat 处理非流式聊天请求
 func (s *APIServer) handleChat(c *gin.Context) {
 	var req ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:  "请求参数无效: " + err.Error(),
-			Status: "error",
-		})
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	sessionKey := principalFrom(c).Key()
+	route, appErr := s.newRoute(req.Model, req.Preference, req.ResponseSchema)
+	if appErr != nil {
+		pkg.RespondError(c, appErr)
+		return
+	}
+	var template prompts.Selection
+	ctx := prompts.WithSelection(model.WithRoute(c.Request.Context(), route), &template)
+
+	conv, err := s.chatService.ResolveConversation(ctx, sessionKey, req.ConversationID)
+	if err != nil {
+		pkg.RespondError(c, conversationError(err, "获取对话失败"))
 		return
 	}
 
 	// 调用服务层处理
 	var content string
-	var err error
 
 	// 检查是否包含图片
 	if len(req.ImageURLs) > 0 || len(req.Base64Images) > 0 {
 		// 处理包含图片的请求
-		content, err = s.chatService.ProcessUserInputWithImages(c.Request.Context(), req.UserInput, req.UserID, req.ImageURLs, req.Base64Images)
+		content, err = s.chatService.ProcessUserInputWithImages(ctx, req.UserInput, sessionKey, conv.ID, req.ImageURLs, req.Base64Images)
 	} else {
 		// 处理纯文本请求
-		content, err = s.chatService.ProcessUserInput(c.Request.Context(), req.UserInput, req.UserID)
+		content, err = s.chatService.ProcessUserInput(ctx, req.UserInput, sessionKey, conv.ID)
 	}
 
 	if err != nil {
-		s.logger.Error("处理聊天请求失败", zap.Error(err), zap.String("user_id", req.UserID))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:  "处理请求失败: " + err.Error(),
-			Status: "error",
-		})
+		s.logger.Error("处理聊天请求失败", zap.Error(err), zap.String("user_id", sessionKey))
+		pkg.RespondError(c, modelError(err, "处理请求失败"))
 		return
 	}
 
 	// 返回响应
 	c.JSON(http.StatusOK, ChatResponse{
-		Content: content,
-		Status:  "success",
+		Content:        content,
+		Status:         "success",
+		ConversationID: conv.ID,
+		Model:          route.Served(),
+		PromptTemplate: template.String(),
+		Data:           structuredData(route, content),
 	})
 }
 
@@ -309,129 +359,87 @@ func (s *APIServer) handleChat(c *gin.Context) {
 func (s *APIServer) handleChatStream(c *gin.Context) {
 	var req ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:  "请求参数无效: " + err.Error(),
-			Status: "error",
-		})
+		pkg.RespondError(c, pkg.NewBindingError(err))
 		return
 	}
+	sessionKey := principalFrom(c).Key()
 
-	// 设置响应头
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	origin := c.Request.Header.Get("Origin")
-	if origin != "" {
-		c.Header("Access-Control-Allow-Origin", origin)
-		c.Header("Vary", "Origin")
-	}
-
-	// 创建上下文
-	ctx, cancel := context.WithCancel(c.Request.Context())
-	defer cancel()
-
-	// 获取用户会话控制
-	control := s.sessionControlCache.GetSessionControl(req.UserID)
-
-	// 重置会话状态
-	control.Mutex.Lock()
-	control.IsPaused = false
-	control.IsStopped = false
-	control.LastActive = time.Now()
-	control.Mutex.Unlock()
-
-	// 控制回调函数
-	controlCallback := func() (bool, bool) {
-		control.Mutex.Lock()
-		defer control.Mutex.Unlock()
-		control.LastActive = time.Now()
-		return control.IsPaused, control.IsStopped
-	}
-
-	// 流式回调函数
-	streamCallback := func(content string, isToolCall bool) error {
-		// 将内容包装为SSE格式
-		response := ChatResponse{
-			Content: content,
-			Status:  "streaming",
-		}
-
-		data, err := json.Marshal(response)
-		if err != nil {
-			return err
-		}
-
-		// 发送SSE消息
-		if _, err := c.Writer.WriteString("data: " + string(data) + "\n\n"); err != nil {
-			// 如果写入失败，标记会话为停止
-			control.Mutex.Lock()
-			control.IsStopped = true
-			control.Mutex.Unlock()
-			return err
-		}
-
-		// 刷新响应
-		c.Writer.Flush()
-
-		return nil
+	// 在发送响应头之前校验模型并确定对话，出错时仍可以返回普通的错误响应
+	route, appErr := s.newRoute(req.Model, req.Preference, req.ResponseSchema)
+	if appErr != nil {
+		pkg.RespondError(c, appErr)
+		return
+	}
+	conv, err := s.chatService.ResolveConversation(c.Request.Context(), sessionKey, req.ConversationID)
+	if err != nil {
+		pkg.RespondError(c, conversationError(err, "获取对话失败"))
+		return
 	}
 
-	// 使用服务层处理用户输入
-	var fullContent string
-	var err error
-
-	// 检查是否包含图片
-	if len(req.ImageURLs) > 0 || len(req.Base64Images) > 0 {
-		// 处理包含图片的请求
-		fullContent, err = s.chatService.ProcessUserInputStreamWithImages(ctx, req.UserInput, req.UserID, req.ImageURLs, req.Base64Images, streamCallback, controlCallback)
-	} else {
-		// 处理纯文本请求
-		fullContent, err = s.chatService.ProcessUserInputStream(ctx, req.UserInput, req.UserID, streamCallback, controlCallback)
+	// 生成在后台进行，客户端断开后仍会继续，可以通过 Last-Event-ID 继续读取
+	info, err := s.startStream(c.Request.Context(), newStreamRequest(c, req, conv.ID, route))
+	if err != nil {
+		s.logger.Error("创建生成失败", zap.Error(err), zap.String("user_id", sessionKey))
+		pkg.RespondError(c, pkg.NewInternalError("创建生成失败", err))
+		return
 	}
+	s.serveStreamEvents(c, info, 0)
+}
 
-	if err != nil && !strings.Contains(err.Error(), "context canceled") {
-		s.logger.Error("流式处理请求失败", zap.Error(err), zap.String("user_id", req.UserID))
-		response := ErrorResponse{
-			Error:  "流式处理失败: " + err.Error(),
-			Status: "error",
+// newRoute 创建请求的模型路由选项，指定的模型不存在或 JSON Schema 无效时返回错误
+func (s *APIServer) newRoute(modelName, preference string, responseSchema json.RawMessage) (*model.Route, *pkg.AppError) {
+	if modelName != "" {
+		found := false
+		for _, info := range s.chatService.Models() {
+			if info.ID == modelName {
+				found = true
+				break
+			}
 		}
-
-		data, _ := json.Marshal(response)
-		c.Writer.WriteString("data: " + string(data) + "\n\n")
-		c.Writer.Flush()
-		return
+		if !found {
+			return nil, pkg.NewNotFound("模型 '%s' 不存在", model.ErrModelNotFound).WithArgs(modelName)
+		}
+	}
+	route := &model.Route{Model: modelName, Preference: preference}
+	// 未指定和显式指定为 null 都表示不要求结构化输出
+	if len(responseSchema) > 0 && string(responseSchema) != "null" {
+		if _, err := structured.Compile(responseSchema); err != nil {
+			return nil, pkg.NewValidationError("JSON Schema 无效", err)
+		}
+		route.ResponseFormat = &model.ResponseFormat{Name: responseSchemaName, Schema: responseSchema}
 	}
+	return route, nil
+}
 
-	// 发送结束消息
-	finalResponse := ChatResponse{
-		Content: fullContent,
-		Status:  "completed",
+// structuredData 请求要求结构化输出时返回校验通过的回复内容
+func structuredData(route *model.Route, content string) json.RawMessage {
+	if route.ResponseFormat == nil || content == "" {
+		return nil
 	}
+	return json.RawMessage(content)
+}
 
-	data, _ := json.Marshal(finalResponse)
-	c.Writer.WriteString("data: " + string(data) + "\n\n")
-	c.Writer.Flush()
+// modelError 将模型调用错误转换为应用错误，没有可用模型时返回服务不可用
+func modelError(err error, fallback string) *pkg.AppError {
+	if errors.Is(err, model.ErrNoModelAvailable) {
+		return pkg.NewServiceUnavailable("没有满足请求的可用模型", err)
+	}
+	var structuredErr *chat.StructuredOutputError
+	if errors.As(err, &structuredErr) {
+		return pkg.NewUnprocessableEntity("模型输出不符合 JSON Schema", err).WithDetails(structuredErr.Errors)
+	}
+	return pkg.NewInternalError(fallback, err)
 }
 
-// handleGetChatHistory 处理获取聊天历史请求
+// handleGetChatHistory 处理获取聊天历史请求，可通过 conversation_id 参数指定对话
 func (s *APIServer) handleGetChatHistory(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:  "缺少 user_id 参数",
-			Status: "error",
-		})
-		return
-	}
+	sessionKey := principalFrom(c).Key()
 
 	// 获取聊天历史
-	messages, err := s.chatService.GetChatHistory(c.Request.Context(), userID)
+	messages, err := s.chatService.GetChatHistory(c.Request.Context(), sessionKey, c.Query("conversation_id"))
 	if err != nil {
-		s.logger.Error("获取聊天历史失败", zap.Error(err), zap.String("user_id", userID))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:  "获取聊天历史失败: " + err.Error(),
-			Status: "error",
-		})
+		s.logger.Error("获取聊天历史失败", zap.Error(err), zap.String("user_id", sessionKey))
+		pkg.RespondError(c, conversationError(err, "获取聊天历史失败"))
 		return
 	}
 
@@ -444,23 +452,13 @@ func (s *APIServer) handleGetChatHistory(c *gin.Context) {
 
 // handleClearChatHistory 处理清除聊天历史请求
 func (s *APIServer) handleClearChatHistory(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:  "缺少 user_id 参数",
-			Status: "error",
-		})
-		return
-	}
+	sessionKey := principalFrom(c).Key()
 
 	// 清除聊天历史
-	err := s.chatService.ClearChatHistory(c.Request.Context(), userID)
+	err := s.chatService.ClearChatHistory(c.Request.Context(), sessionKey)
 	if err != nil {
-		s.logger.Error("清除聊天历史失败", zap.Error(err), zap.String("user_id", userID))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:  "清除聊天历史失败: " + err.Error(),
-			Status: "error",
-		})
+		s.logger.Error("清除聊天历史失败", zap.Error(err), zap.String("user_id", sessionKey))
+		pkg.RespondError(c, pkg.NewInternalError("清除聊天历史失败", err))
 		return
 	}
 
@@ -473,12 +471,38 @@ func (s *APIServer) handleClearChatHistory(c *gin.Context) {
 
 // handleHealthCheck 处理健康检查请求
 func (s *APIServer) handleHealthCheck(c *gin.Context) {
+	// 外部依赖（LLM、Embedding、MCP、Redis）熔断时报告为degraded
+	status := "ok"
+	dependencies := resilience.Snapshots()
+	for _, d := range dependencies {
+		if d.State != resilience.StateClosed.String() {
+			status = "degraded"
+			break
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "ok",
-		"service": "aichat",
+		"status":       status,
+		"service":      "aichat",
+		"dependencies": dependencies,
 	})
 }
 
+// handleProbe 返回探针处理函数，带 ?verbose 参数时列出每项检查的结果
+func (s *APIServer) handleProbe(probe healthcheck.Probe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := healthcheck.Default.Run(c.Request.Context(), probe)
+		if v, ok := c.GetQuery("verbose"); !ok || v == "false" || v == "0" {
+			report.Checks = nil
+		}
+		statusCode := http.StatusOK
+		if !report.Healthy() {
+			statusCode = http.StatusServiceUnavailable
+		}
+		c.JSON(statusCode, report)
+	}
+}
+
 // handleAgentHealthCheck 处理Agent健康检查请求
 func (s *APIServer) handleAgentHealthCheck(c *gin.Context) {
 	logger := pkg.GetLogger()
@@ -500,10 +524,7 @@ func (s *APIServer) handleToolHealthCheck(c *gin.Context) {
 
 	// 检查工具健康监控器是否初始化
 	if model.ToolHealthMonitor == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status":  "error",
-			"message": "工具健康监控器未初始化",
-		})
+		pkg.RespondError(c, pkg.NewServiceUnavailable("工具健康监控器未初始化", nil))
 		return
 	}
 
@@ -536,7 +557,7 @@ func (s *APIServer) Start() error {
 
 // Close 关闭API服务器
 func (s *APIServer) Close() {
-	if s.sessionControlCache != nil {
-		s.sessionControlCache.Close()
+	if s.streams != nil {
+		s.streams.Close()
 	}
 }
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"weave/config"
+	"weave/pkg"
+	"weave/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// 服务间调用时声明代表的用户和租户的请求头，仅在使用服务令牌认证时读取
+const (
+	UserIDHeader   = "X-Weave-User-ID"
+	TenantIDHeader = "X-Weave-Tenant-ID"
+)
+
+// WebSocket 子协议：浏览器无法为 WebSocket 握手设置认证头，
+// 客户端同时提供 WebSocketProtocol 和以 WebSocketTokenProtocolPrefix 开头、后接访问令牌的子协议，服务端只选用 WebSocketProtocol
+const (
+	WebSocketProtocol            = "weave-chat"
+	WebSocketTokenProtocolPrefix = "weave-token."
+)
+
+// principalKey 认证主体在gin上下文中的键名
+const principalKey = "aichat_principal"
+
+// Principal 经过认证的调用方
+type Principal struct {
+	UserID   uint
+	TenantID uint
+	// 是否通过服务令牌认证（服务间调用）
+	Service bool
+}
+
+// Key 返回按租户隔离的会话键，用于会话控制、对话缓存和聊天历史
+func (p Principal) Key() string {
+	return fmt.Sprintf("tenant:%d:user:%d", p.TenantID, p.UserID)
+}
+
+// AuthConfig aichat 认证配置
+type AuthConfig struct {
+	// 验证 Weave 访问令牌的密钥，与 Weave 的 JWT_SECRET 一致
+	JWTSecret string
+	// 服务间调用使用的令牌，为空时不接受服务令牌
+	ServiceToken string
+}
+
+// LoadAuthConfig 从环境变量加载认证配置
+// JWT密钥优先读取 AICHAT_JWT_SECRET，未配置时使用 Weave 的 JWT_SECRET；两者均支持密钥引用
+func LoadAuthConfig() (AuthConfig, error) {
+	secret := viper.GetString("AICHAT_JWT_SECRET")
+	if secret == "" {
+		secret = viper.GetString("JWT_SECRET")
+	}
+	secret, err := config.ResolveSecretRefs(secret)
+	if err != nil {
+		return AuthConfig{}, fmt.Errorf("AICHAT_JWT_SECRET: %w", err)
+	}
+	serviceToken, err := config.ResolveSecretRefs(viper.GetString("AICHAT_SERVICE_TOKEN"))
+	if err != nil {
+		return AuthConfig{}, fmt.Errorf("AICHAT_SERVICE_TOKEN: %w", err)
+	}
+	return AuthConfig{JWTSecret: secret, ServiceToken: serviceToken}, nil
+}
+
+// AuthMiddleware aichat 认证中间件
+// 接受 Weave 访问令牌，或服务令牌加 X-Weave-User-ID/X-Weave-Tenant-ID 请求头；
+// WebSocket 升级请求没有 Authorization 请求头时从 Sec-WebSocket-Protocol 读取令牌；
+// 认证通过后将用户和租户写入上下文（user_id、tenant_id），供按用户限流和处理函数使用
+func AuthMiddleware(cfg AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, appErr := requestToken(c)
+		if appErr != nil {
+			pkg.RespondError(c, appErr)
+			return
+		}
+
+		var principal Principal
+		if cfg.ServiceToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.ServiceToken)) == 1 {
+			p, err := servicePrincipal(c)
+			if err != nil {
+				pkg.RespondError(c, err)
+				return
+			}
+			principal = p
+		} else {
+			// 未配置密钥时拒绝所有访问令牌，避免接受以空密钥签名的令牌
+			if cfg.JWTSecret == "" {
+				pkg.RespondError(c, pkg.NewAuthInvalidTokenError("令牌无效或已过期", nil))
+				return
+			}
+			userID, tokenType, tenantID, err := utils.VerifyTokenWithSecret(token, cfg.JWTSecret)
+			if err != nil || tokenType != "access" {
+				pkg.RespondError(c, pkg.NewAuthInvalidTokenError("令牌无效或已过期", err))
+				return
+			}
+			principal = Principal{UserID: userID, TenantID: tenantID}
+		}
+
+		c.Set(principalKey, principal)
+		c.Set("user_id", principal.UserID)
+		c.Set("tenant_id", principal.TenantID)
+		pkg.AppendLoggerFields(c, zap.Uint("user_id", principal.UserID), zap.Uint("tenant_id", principal.TenantID))
+
+		c.Next()
+	}
+}
+
+// requestToken 读取请求携带的令牌
+func requestToken(c *gin.Context) (string, *pkg.AppError) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		if token := webSocketToken(c); token != "" {
+			return token, nil
+		}
+		return "", pkg.NewUnauthorized("缺少 Authorization 请求头", nil)
+	}
+	token, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok || token == "" {
+		return "", pkg.NewUnauthorized("Authorization 请求头格式必须为 Bearer {token}", nil)
+	}
+	return token, nil
+}
+
+// webSocketToken 返回 WebSocket 升级请求在子协议中携带的令牌，不是升级请求或没有令牌时返回空
+func webSocketToken(c *gin.Context) string {
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		return ""
+	}
+	for _, header := range c.Request.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if token, ok := strings.CutPrefix(strings.TrimSpace(protocol), WebSocketTokenProtocolPrefix); ok && token != "" {
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+// servicePrincipal 从请求头读取服务间调用代表的用户和租户，缺少用户时拒绝请求
+func servicePrincipal(c *gin.Context) (Principal, *pkg.AppError) {
+	userID, err := strconv.ParseUint(c.GetHeader(UserIDHeader), 10, 64)
+	if err != nil || userID == 0 {
+		return Principal{}, pkg.NewValidationRequiredError("服务令牌调用必须通过 %s 请求头指定用户", err).WithArgs(UserIDHeader)
+	}
+	var tenantID uint64
+	if v := c.GetHeader(TenantIDHeader); v != "" {
+		if tenantID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return Principal{}, pkg.NewValidationFormatError("%s 请求头必须是整数", err).WithArgs(TenantIDHeader)
+		}
+	}
+	return Principal{UserID: uint(userID), TenantID: uint(tenantID), Service: true}, nil
+}
+
+// principalFrom 返回认证中间件写入的调用方
+func principalFrom(c *gin.Context) Principal {
+	if v, ok := c.Get(principalKey); ok {
+		if p, ok := v.(Principal); ok {
+			return p
+		}
+	}
+	return Principal{}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"weave/config"
+	"weave/middleware"
+	"weave/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newAuthTestRouter(cfg AuthConfig, policy config.RateLimitPolicy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(AuthMiddleware(cfg), middleware.RateLimitMiddleware(policy))
+	r.GET("/whoami", func(c *gin.Context) {
+		p := principalFrom(c)
+		c.JSON(http.StatusOK, gin.H{"key": p.Key(), "service": p.Service})
+	})
+	return r
+}
+
+func doAuthRequest(r *gin.Engine, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, "/whoami", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthMiddlewareAccessToken(t *testing.T) {
+	config.Config.JWT.Secret = "aichat-test-secret"
+	config.Config.JWT.AccessTokenExpiry = 5
+	config.Config.JWT.RefreshTokenExpiry = 1
+	defer func() { config.Config.JWT.Secret = "" }()
+
+	r := newAuthTestRouter(AuthConfig{JWTSecret: "aichat-test-secret"}, config.RateLimitPolicy{Name: "auth_test", Rate: 100, Burst: 100})
+
+	if w := doAuthRequest(r, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", w.Code)
+	}
+
+	access, _ := utils.GenerateToken(7, 2)
+	w := doAuthRequest(r, map[string]string{"Authorization": "Bearer " + access})
+	if w.Code != http.StatusOK || w.Body.String() != `{"key":"tenant:2:user:7","service":false}` {
+		t.Fatalf("expected tenant scoped principal, got %d %s", w.Code, w.Body.String())
+	}
+
+	// 刷新令牌和其他密钥签发的令牌不能访问聊天接口
+	refresh, _ := utils.GenerateRefreshToken(7, 2)
+	if w := doAuthRequest(r, map[string]string{"Authorization": "Bearer " + refresh}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected refresh token to be rejected, got %d", w.Code)
+	}
+	config.Config.JWT.Secret = "another-secret"
+	forged, _ := utils.GenerateToken(7, 2)
+	if w := doAuthRequest(r, map[string]string{"Authorization": "Bearer " + forged}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected token signed with another secret to be rejected, got %d", w.Code)
+	}
+}
+
+func TestAuthMiddlewareServiceToken(t *testing.T) {
+	r := newAuthTestRouter(AuthConfig{ServiceToken: "svc-token"}, config.RateLimitPolicy{Name: "auth_test_svc", Rate: 100, Burst: 100})
+
+	w := doAuthRequest(r, map[string]string{"Authorization": "Bearer svc-token", UserIDHeader: "9", TenantIDHeader: "3"})
+	if w.Code != http.StatusOK || w.Body.String() != `{"key":"tenant:3:user:9","service":true}` {
+		t.Fatalf("expected service principal, got %d %s", w.Code, w.Body.String())
+	}
+	if w := doAuthRequest(r, map[string]string{"Authorization": "Bearer svc-token"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected service call without user header to fail, got %d", w.Code)
+	}
+	if w := doAuthRequest(r, map[string]string{"Authorization": "Bearer wrong", UserIDHeader: "9"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected wrong service token to be rejected, got %d", w.Code)
+	}
+}
+
+func TestChatRateLimitIsPerUser(t *testing.T) {
+	policy := chatRateLimitPolicy()
+	policy.Name = "auth_test_per_user"
+	policy.Rate = 0.001
+	policy.Burst = 1
+	r := newAuthTestRouter(AuthConfig{ServiceToken: "svc-token"}, policy)
+
+	alice := map[string]string{"Authorization": "Bearer svc-token", UserIDHeader: "1"}
+	bob := map[string]string{"Authorization": "Bearer svc-token", UserIDHeader: "2"}
+	if w := doAuthRequest(r, alice); w.Code != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", w.Code)
+	}
+	if w := doAuthRequest(r, alice); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected second request from the same user to be limited, got %d", w.Code)
+	}
+	if w := doAuthRequest(r, bob); w.Code != http.StatusOK {
+		t.Fatalf("expected another user from the same IP to pass, got %d", w.Code)
+	}
+}
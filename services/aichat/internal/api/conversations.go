@@ -0,0 +1,279 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"weave/pkg"
+	"weave/services/aichat/internal/conversation"
+	"weave/services/aichat/internal/model"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CreateConversationRequest 创建对话请求，标题为空时根据首条用户消息生成
+type CreateConversationRequest struct {
+	Title   string `json:"title" binding:"omitempty,max=100"`
+	Persona string `json:"persona" binding:"omitempty,max=64"` // 对话使用的人设，为空时使用 default 模板
+}
+
+// UpdateConversationRequest 修改对话请求，至少包含一个字段
+type UpdateConversationRequest struct {
+	Title    *string `json:"title" binding:"omitempty,min=1,max=100"`
+	Archived *bool   `json:"archived"`
+	Persona  *string `json:"persona" binding:"omitempty,max=64"` // 空字符串表示清除人设
+}
+
+// ForkConversationRequest 分叉对话请求，新对话包含来源对话中到 message_index（含）为止的消息
+type ForkConversationRequest struct {
+	MessageIndex *int `json:"message_index" binding:"required,min=0"`
+}
+
+// ListConversationsQuery 对话列表查询参数，不指定 archived 时列出全部对话
+type ListConversationsQuery struct {
+	Archived *bool `form:"archived"`
+	Limit    int   `form:"limit,default=20" binding:"min=1,max=100"`
+	Offset   int   `form:"offset" binding:"min=0"`
+}
+
+// SearchConversationsQuery 对话搜索查询参数
+type SearchConversationsQuery struct {
+	Query string `form:"q" binding:"required"`
+	Limit int    `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+// ConversationResponse 对话响应结构，列表和搜索结果中不包含消息
+type ConversationResponse struct {
+	ID        string            `json:"id"`
+	Title     string            `json:"title"`
+	Summary   string            `json:"summary"`
+	Archived  bool              `json:"archived"`
+	ParentID  string            `json:"parent_id,omitempty"`
+	ForkIndex *int              `json:"fork_index,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Messages  []*schema.Message `json:"messages,omitempty"`
+}
+
+// ConversationListResponse 对话列表响应结构
+type ConversationListResponse struct {
+	Conversations []ConversationResponse `json:"conversations"`
+	Total         int64                  `json:"total"`
+	Limit         int                    `json:"limit"`
+	Offset        int                    `json:"offset"`
+}
+
+// ConversationSearchHit 对话搜索结果，message_index 为 -1 表示标题或摘要命中
+type ConversationSearchHit struct {
+	Conversation ConversationResponse `json:"conversation"`
+	MessageIndex int                  `json:"message_index"`
+	Snippet      string               `json:"snippet"`
+}
+
+// newConversationResponse 将对话转换为响应结构
+func newConversationResponse(conv *model.Conversation, withMessages bool) ConversationResponse {
+	resp := ConversationResponse{
+		ID:        conv.ID,
+		Title:     conv.Title,
+		Summary:   conv.Summary,
+		Archived:  conv.Archived,
+		ParentID:  conv.ParentID,
+		Metadata:  conv.Metadata,
+		CreatedAt: conv.StartTime,
+		UpdatedAt: conv.EndTime,
+	}
+	if conv.ParentID != "" {
+		forkIndex := conv.ForkIndex
+		resp.ForkIndex = &forkIndex
+	}
+	if withMessages {
+		resp.Messages = conv.Messages
+		if resp.Messages == nil {
+			resp.Messages = []*schema.Message{}
+		}
+	}
+	return resp
+}
+
+// conversationError 将对话管理器返回的错误转换为 AppError，其他错误使用 fallback 作为内部错误信息
+func conversationError(err error, fallback string) *pkg.AppError {
+	switch {
+	case errors.Is(err, model.ErrConversationNotFound):
+		return pkg.NewNotFound("对话不存在", err)
+	case errors.Is(err, conversation.ErrInvalidMessageIndex):
+		return pkg.NewValidationRangeError("消息序号超出对话消息范围", err)
+	default:
+		return pkg.NewInternalError(fallback, err)
+	}
+}
+
+// handleCreateConversation 创建对话
+func (s *APIServer) handleCreateConversation(c *gin.Context) {
+	var req CreateConversationRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			pkg.RespondError(c, pkg.NewBindingError(err))
+			return
+		}
+	}
+	sessionKey := principalFrom(c).Key()
+	if req.Persona != "" {
+		if _, err := s.chatService.Prompts().Persona(c.Request.Context(), tenantOf(principalFrom(c)), req.Persona); err != nil {
+			pkg.RespondError(c, promptError(err, "获取人设失败"))
+			return
+		}
+	}
+
+	conv, err := s.chatService.Conversations().CreateConversation(c.Request.Context(), sessionKey, req.Title)
+	if err == nil && req.Persona != "" {
+		conv, err = s.setConversationPersona(c, conv, req.Persona)
+	}
+	if err != nil {
+		s.logger.Error("创建对话失败", zap.Error(err), zap.String("user_id", sessionKey))
+		pkg.RespondError(c, conversationError(err, "创建对话失败"))
+		return
+	}
+	c.JSON(http.StatusCreated, newConversationResponse(conv, true))
+}
+
+// handleListConversations 按最后活动时间倒序分页列出对话
+func (s *APIServer) handleListConversations(c *gin.Context) {
+	var query ListConversationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	sessionKey := principalFrom(c).Key()
+
+	convs, total, err := s.chatService.Conversations().ListConversations(c.Request.Context(), sessionKey, model.ConversationFilter{
+		Archived: query.Archived,
+		Limit:    query.Limit,
+		Offset:   query.Offset,
+	})
+	if err != nil {
+		s.logger.Error("获取对话列表失败", zap.Error(err), zap.String("user_id", sessionKey))
+		pkg.RespondError(c, conversationError(err, "获取对话列表失败"))
+		return
+	}
+
+	resp := ConversationListResponse{
+		Conversations: make([]ConversationResponse, 0, len(convs)),
+		Total:         total,
+		Limit:         query.Limit,
+		Offset:        query.Offset,
+	}
+	for _, conv := range convs {
+		resp.Conversations = append(resp.Conversations, newConversationResponse(conv, false))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleSearchConversations 在对话标题、摘要和消息中搜索
+func (s *APIServer) handleSearchConversations(c *gin.Context) {
+	var query SearchConversationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	sessionKey := principalFrom(c).Key()
+
+	results, err := s.chatService.Conversations().SearchConversations(c.Request.Context(), sessionKey, query.Query, query.Limit)
+	if err != nil {
+		s.logger.Error("搜索对话失败", zap.Error(err), zap.String("user_id", sessionKey))
+		pkg.RespondError(c, conversationError(err, "搜索对话失败"))
+		return
+	}
+
+	hits := make([]ConversationSearchHit, 0, len(results))
+	for _, result := range results {
+		hits = append(hits, ConversationSearchHit{
+			Conversation: newConversationResponse(result.Conversation, false),
+			MessageIndex: result.MessageIndex,
+			Snippet:      result.Snippet,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"results": hits, "count": len(hits)})
+}
+
+// handleGetConversation 获取对话及其消息
+func (s *APIServer) handleGetConversation(c *gin.Context) {
+	sessionKey := principalFrom(c).Key()
+
+	conv, err := s.chatService.Conversations().GetConversation(c.Request.Context(), sessionKey, c.Param("id"))
+	if err != nil {
+		pkg.RespondError(c, conversationError(err, "获取对话失败"))
+		return
+	}
+	c.JSON(http.StatusOK, newConversationResponse(conv, true))
+}
+
+// handleUpdateConversation 修改对话标题、归档状态或人设
+func (s *APIServer) handleUpdateConversation(c *gin.Context) {
+	var req UpdateConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	if req.Title == nil && req.Archived == nil && req.Persona == nil {
+		pkg.RespondError(c, pkg.NewValidationRequiredError("至少需要指定 title、archived 或 persona", nil))
+		return
+	}
+	sessionKey := principalFrom(c).Key()
+	manager := s.chatService.Conversations()
+
+	var (
+		conv *model.Conversation
+		err  error
+	)
+	if req.Title != nil {
+		conv, err = manager.RenameConversation(c.Request.Context(), sessionKey, c.Param("id"), *req.Title)
+	}
+	if err == nil && req.Archived != nil {
+		conv, err = manager.ArchiveConversation(c.Request.Context(), sessionKey, c.Param("id"), *req.Archived)
+	}
+	if err == nil && req.Persona != nil {
+		if conv == nil {
+			conv, err = manager.GetConversation(c.Request.Context(), sessionKey, c.Param("id"))
+		}
+		if err == nil {
+			conv, err = s.setConversationPersona(c, conv, *req.Persona)
+		}
+	}
+	if err != nil {
+		pkg.RespondError(c, promptError(err, "修改对话失败"))
+		return
+	}
+	c.JSON(http.StatusOK, newConversationResponse(conv, false))
+}
+
+// handleDeleteConversation 删除对话
+func (s *APIServer) handleDeleteConversation(c *gin.Context) {
+	sessionKey := principalFrom(c).Key()
+
+	if err := s.chatService.Conversations().DeleteConversation(c.Request.Context(), sessionKey, c.Param("id")); err != nil {
+		pkg.RespondError(c, conversationError(err, "删除对话失败"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handleForkConversation 从指定消息处分叉出新对话
+func (s *APIServer) handleForkConversation(c *gin.Context) {
+	var req ForkConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	sessionKey := principalFrom(c).Key()
+
+	conv, err := s.chatService.Conversations().ForkConversation(c.Request.Context(), sessionKey, c.Param("id"), *req.MessageIndex)
+	if err != nil {
+		pkg.RespondError(c, conversationError(err, "分叉对话失败"))
+		return
+	}
+	c.JSON(http.StatusCreated, newConversationResponse(conv, true))
+}
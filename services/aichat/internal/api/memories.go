@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"weave/pkg"
+	"weave/services/aichat/internal/memory"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SaveMemoryRequest 添加或修改长期记忆请求
+type SaveMemoryRequest struct {
+	Content string `json:"content" binding:"required,max=2000"`
+}
+
+// memoryError 将记忆管理器返回的错误转换为 AppError
+func memoryError(err error, fallback string) *pkg.AppError {
+	switch {
+	case errors.Is(err, memory.ErrMemoryNotFound):
+		return pkg.NewNotFound("记忆不存在", err)
+	case errors.Is(err, memory.ErrInvalidMemory):
+		return pkg.NewValidationError("记忆内容无效", err)
+	default:
+		return pkg.NewInternalError(fallback, err)
+	}
+}
+
+// memoryID 解析路径中的记忆 ID，无效时写入错误响应
+func memoryID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		pkg.RespondError(c, pkg.NewValidationFormatError("无效的记忆 ID", err))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// handleListMemories 按创建顺序列出当前用户的长期记忆
+func (s *APIServer) handleListMemories(c *gin.Context) {
+	sessionKey := principalFrom(c).Key()
+	memories, err := s.chatService.Memories().List(c.Request.Context(), sessionKey)
+	if err != nil {
+		s.logger.Error("获取长期记忆失败", zap.Error(err), zap.String("user_id", sessionKey))
+		pkg.RespondError(c, memoryError(err, "获取长期记忆失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"memories": memories})
+}
+
+// handleCreateMemory 手动添加一条长期记忆
+func (s *APIServer) handleCreateMemory(c *gin.Context) {
+	var req SaveMemoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	sessionKey := principalFrom(c).Key()
+	created, err := s.chatService.Memories().Add(c.Request.Context(), sessionKey, req.Content)
+	if err != nil {
+		pkg.RespondError(c, memoryError(err, "保存长期记忆失败"))
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// handleUpdateMemory 修改一条长期记忆的内容
+func (s *APIServer) handleUpdateMemory(c *gin.Context) {
+	id, ok := memoryID(c)
+	if !ok {
+		return
+	}
+	var req SaveMemoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	sessionKey := principalFrom(c).Key()
+	updated, err := s.chatService.Memories().Update(c.Request.Context(), sessionKey, id, req.Content)
+	if err != nil {
+		pkg.RespondError(c, memoryError(err, "修改长期记忆失败"))
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// handleDeleteMemory 删除一条长期记忆
+func (s *APIServer) handleDeleteMemory(c *gin.Context) {
+	id, ok := memoryID(c)
+	if !ok {
+		return
+	}
+	if err := s.chatService.Memories().Delete(c.Request.Context(), principalFrom(c).Key(), id); err != nil {
+		pkg.RespondError(c, memoryError(err, "删除长期记忆失败"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handleClearMemories 删除当前用户的所有长期记忆
+func (s *APIServer) handleClearMemories(c *gin.Context) {
+	sessionKey := principalFrom(c).Key()
+	deleted, err := s.chatService.Memories().Clear(c.Request.Context(), sessionKey)
+	if err != nil {
+		s.logger.Error("清空长期记忆失败", zap.Error(err), zap.String("user_id", sessionKey))
+		pkg.RespondError(c, memoryError(err, "清空长期记忆失败"))
+		return
+	}
+	s.logger.Info("已清空长期记忆", zap.String("user_id", sessionKey), zap.Int64("deleted", deleted))
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
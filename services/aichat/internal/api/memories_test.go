@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"weave/services/aichat/internal/memory"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/viper"
+)
+
+// listMemories 列出用户的长期记忆
+func listMemories(t *testing.T, s *APIServer, userID string) []memory.Memory {
+	t.Helper()
+	w := doUsageRequest(s, http.MethodGet, "/api/memories", "", userID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list memories: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Memories []memory.Memory `json:"memories"`
+	}
+	decodeJSON(t, w.Body.Bytes(), &resp)
+	return resp.Memories
+}
+
+// waitMemories 等待后台提取完成，直到用户有 n 条记忆
+// 直接读取记忆管理器，轮询不占用接口的限流额度
+func waitMemories(t *testing.T, s *APIServer, userID uint, n int) []memory.Memory {
+	t.Helper()
+	key := Principal{TenantID: 3, UserID: userID}.Key()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		memories, err := s.chatService.Memories().List(context.Background(), key)
+		if err != nil {
+			t.Fatalf("list memories: %v", err)
+		}
+		if len(memories) == n {
+			return memories
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d memories, got %+v", n, memories)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// input 返回模型收到的第 i 次输入，后台提取记忆时模型可能同时被调用
+func (m *fakeChatModel) input(i int) []*schema.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inputs[i]
+}
+
+// promptContains 模型收到的某次输入是否包含指定内容
+func promptContains(input []*schema.Message, text string) bool {
+	for _, msg := range input {
+		if strings.Contains(msg.Content, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMemoryExtractionAndRecall(t *testing.T) {
+	viper.Set("AICHAT_MEMORY_EXTRACTION", true)
+	t.Cleanup(func() { viper.Set("AICHAT_MEMORY_EXTRACTION", false) })
+	llm := &fakeChatModel{replies: replies(
+		"好的，记住了",
+		`["用户在 Weave 团队工作"]`,
+		"没问题",
+		"好的：\n```json\n[\"用户在Weave团队工作。\", \"用户希望示例代码使用 Go\"]\n```",
+		"这是 Go 的示例",
+		`[]`,
+	)}
+	s := newOpenAITestServer(t, llm)
+
+	w := doUsageRequest(s, http.MethodPost, "/api/chat", `{"user_input":"我在 Weave 团队工作"}`, "15")
+	if w.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", w.Code, w.Body.String())
+	}
+	var first ChatResponse
+	decodeJSON(t, w.Body.Bytes(), &first)
+	memories := waitMemories(t, s, 15, 1)
+	if m := memories[0]; m.Content != "用户在 Weave 团队工作" || m.Source != memory.SourceExtracted ||
+		m.ConversationID != first.ConversationID || m.MessageIndex != 0 {
+		t.Fatalf("unexpected extracted memory: %+v", m)
+	}
+
+	// 与已有记忆只差空白和标点的条目被跳过
+	w = doUsageRequest(s, http.MethodPost, "/api/chat", `{"user_input":"以后示例请用 Go"}`, "15")
+	if w.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", w.Code, w.Body.String())
+	}
+	if input := llm.input(2); !promptContains(input, "用户在 Weave 团队工作") {
+		t.Fatalf("expected the memory in the second prompt, got %+v", input)
+	}
+	memories = waitMemories(t, s, 15, 2)
+	if m := memories[1]; m.Content != "用户希望示例代码使用 Go" || m.MessageIndex != 2 {
+		t.Fatalf("unexpected extracted memory: %+v", m)
+	}
+
+	// 记忆在新对话中同样生效
+	w = doUsageRequest(s, http.MethodPost, "/api/conversations", `{"title":"新对话"}`, "15")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create conversation: %d %s", w.Code, w.Body.String())
+	}
+	var conv ConversationResponse
+	decodeJSON(t, w.Body.Bytes(), &conv)
+	w = doUsageRequest(s, http.MethodPost, "/api/chat", fmt.Sprintf(`{"user_input":"写个 HTTP 服务","conversation_id":%q}`, conv.ID), "15")
+	if w.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", w.Code, w.Body.String())
+	}
+	if input := llm.input(4); !promptContains(input, "用户希望示例代码使用 Go") || promptContains(input, "我在 Weave 团队工作") {
+		t.Fatalf("expected only memories from the first conversation, got %+v", input)
+	}
+
+	// 其他用户看不到也不能修改
+	if others := listMemories(t, s, "16"); len(others) != 0 {
+		t.Fatalf("expected no memories for another user, got %+v", others)
+	}
+	if w = doUsageRequest(s, http.MethodDelete, fmt.Sprintf("/api/memories/%d", memories[0].ID), "", "16"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's memory, got %d", w.Code)
+	}
+}
+
+func TestCloseWaitsForMemoryExtraction(t *testing.T) {
+	viper.Set("AICHAT_MEMORY_EXTRACTION", true)
+	t.Cleanup(func() { viper.Set("AICHAT_MEMORY_EXTRACTION", false) })
+	llm := &fakeChatModel{replies: replies("好的，记住了", `["用户在 Weave 团队工作"]`), delay: 100 * time.Millisecond}
+	s := newOpenAITestServer(t, llm)
+
+	// 回复返回时提取仍在后台进行
+	w := doUsageRequest(s, http.MethodPost, "/api/chat", `{"user_input":"我在 Weave 团队工作"}`, "17")
+	if w.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", w.Code, w.Body.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.chatService.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	key := Principal{TenantID: 3, UserID: 17}.Key()
+	memories, err := s.chatService.Memories().List(context.Background(), key)
+	if err != nil || len(memories) != 1 {
+		t.Fatalf("expected extraction to finish before Close returned, got %+v, %v", memories, err)
+	}
+}
+
+func TestMemoryCRUD(t *testing.T) {
+	s := newOpenAITestServer(t, &fakeChatModel{})
+
+	w := doUsageRequest(s, http.MethodPost, "/api/memories", `{"content":"  用户使用 macOS  "}`, "16")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create memory: %d %s", w.Code, w.Body.String())
+	}
+	var created memory.Memory
+	decodeJSON(t, w.Body.Bytes(), &created)
+	if created.Content != "用户使用 macOS" || created.Source != memory.SourceManual {
+		t.Fatalf("unexpected memory: %+v", created)
+	}
+
+	path := fmt.Sprintf("/api/memories/%d", created.ID)
+	w = doUsageRequest(s, http.MethodPatch, path, `{"content":"用户使用 Linux"}`, "16")
+	if w.Code != http.StatusOK {
+		t.Fatalf("update memory: %d %s", w.Code, w.Body.String())
+	}
+	if w = doUsageRequest(s, http.MethodPatch, path, `{"content":"   "}`, "16"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for blank content, got %d %s", w.Code, w.Body.String())
+	}
+	if w = doUsageRequest(s, http.MethodPatch, "/api/memories/abc", `{"content":"x"}`, "16"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid id, got %d", w.Code)
+	}
+	if memories := listMemories(t, s, "16"); len(memories) != 1 || memories[0].Content != "用户使用 Linux" {
+		t.Fatalf("unexpected memories: %+v", memories)
+	}
+
+	if w = doUsageRequest(s, http.MethodDelete, path, "", "16"); w.Code != http.StatusNoContent {
+		t.Fatalf("delete memory: %d %s", w.Code, w.Body.String())
+	}
+	if w = doUsageRequest(s, http.MethodDelete, path, "", "16"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deleted memory, got %d", w.Code)
+	}
+
+	for _, content := range []string{"用户在上海", "用户是后端工程师"} {
+		if w = doUsageRequest(s, http.MethodPost, "/api/memories", fmt.Sprintf(`{"content":%q}`, content), "16"); w.Code != http.StatusCreated {
+			t.Fatalf("create memory: %d %s", w.Code, w.Body.String())
+		}
+	}
+	w = doUsageRequest(s, http.MethodDelete, "/api/memories", "", "16")
+	var cleared struct {
+		Deleted int64 `json:"deleted"`
+	}
+	decodeJSON(t, w.Body.Bytes(), &cleared)
+	if w.Code != http.StatusOK || cleared.Deleted != 2 || len(listMemories(t, s, "16")) != 0 {
+		t.Fatalf("expected 2 memories cleared, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,648 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"weave/pkg"
+	"weave/services/aichat/internal/model"
+	"weave/services/aichat/internal/security"
+	chatservice "weave/services/aichat/internal/service/chat"
+	"weave/services/aichat/internal/structured"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// OpenAI 兼容接口的错误类型
+const (
+	openAIInvalidRequest = "invalid_request_error"
+	openAIRateLimit      = "rate_limit_error"
+	openAIServerError    = "server_error"
+)
+
+// OpenAIChatCompletionRequest OpenAI 兼容的对话补全请求
+// 不支持的参数（n > 1、logprobs 等）会被拒绝或忽略
+type OpenAIChatCompletionRequest struct {
+	Model         string               `json:"model"`
+	Messages      []OpenAIMessage      `json:"messages" binding:"required,min=1,dive"`
+	Stream        bool                 `json:"stream"`
+	StreamOptions *OpenAIStreamOptions `json:"stream_options"`
+	Temperature   *float32             `json:"temperature" binding:"omitempty,min=0,max=2"`
+	TopP          *float32             `json:"top_p" binding:"omitempty,min=0,max=1"`
+	MaxTokens     *int                 `json:"max_tokens" binding:"omitempty,min=1"`
+	MaxCompletion *int                 `json:"max_completion_tokens" binding:"omitempty,min=1"`
+	Stop          OpenAIStop           `json:"stop"`
+	N             *int                 `json:"n"`
+	Tools         []OpenAITool         `json:"tools" binding:"dive"`
+	ToolChoice    json.RawMessage      `json:"tool_choice"`
+	User          string               `json:"user"`
+	// 回复格式，json_object 和 json_schema 时回复内容为校验通过的 JSON
+	ResponseFormat *OpenAIResponseFormat `json:"response_format"`
+}
+
+// OpenAIResponseFormat 回复格式：text、json_object 或 json_schema
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type" binding:"required,oneof=text json_object json_schema"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema"`
+}
+
+// OpenAIJSONSchema json_schema 回复格式的模式，strict 由是否支持结构化输出的模型决定，这里忽略
+type OpenAIJSONSchema struct {
+	Name        string          `json:"name" binding:"required,max=64"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+	Strict      *bool           `json:"strict"`
+}
+
+// OpenAIStreamOptions 流式输出选项
+type OpenAIStreamOptions struct {
+	// 为 true 时在 [DONE] 之前额外发送一个只包含用量的分块
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenAIStop 停止词，可以是字符串或字符串数组
+type OpenAIStop []string
+
+// UnmarshalJSON 同时接受字符串和字符串数组
+func (s *OpenAIStop) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*s = nil
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = OpenAIStop{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return errors.New("stop must be a string or an array of strings")
+	}
+	*s = multiple
+	return nil
+}
+
+// OpenAIMessage OpenAI 格式的消息，content 可以是字符串、内容片段数组或 null
+type OpenAIMessage struct {
+	Role       string           `json:"role" binding:"required,oneof=system developer user assistant tool"`
+	Content    json.RawMessage  `json:"content"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// OpenAIContentPart 消息内容片段
+type OpenAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+// OpenAIImageURL 图片片段，url 可以是 http(s) 链接或 data URL
+type OpenAIImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// OpenAIToolCall 助手消息中的工具调用；流式分块中带有 index
+type OpenAIToolCall struct {
+	Index    *int               `json:"index,omitempty"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+// OpenAIFunctionCall 工具调用的函数名和 JSON 参数
+type OpenAIFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// OpenAITool 调用方声明的工具，目前只支持 function 类型
+type OpenAITool struct {
+	Type     string             `json:"type" binding:"required,eq=function"`
+	Function OpenAIFunctionSpec `json:"function"`
+}
+
+// OpenAIFunctionSpec 函数名、说明和 JSON Schema 参数
+type OpenAIFunctionSpec struct {
+	Name        string             `json:"name" binding:"required"`
+	Description string             `json:"description,omitempty"`
+	Parameters  *jsonschema.Schema `json:"parameters,omitempty"`
+}
+
+// OpenAIChatCompletion 非流式对话补全响应
+type OpenAIChatCompletion struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   *OpenAIUsage   `json:"usage,omitempty"`
+}
+
+// OpenAIChoice 补全结果；流式分块使用 Delta，非流式响应使用 Message
+type OpenAIChoice struct {
+	Index        int                    `json:"index"`
+	Message      *OpenAIResponseMessage `json:"message,omitempty"`
+	Delta        *OpenAIResponseMessage `json:"delta,omitempty"`
+	FinishReason *string                `json:"finish_reason"`
+}
+
+// OpenAIResponseMessage 响应中的助手消息，只有工具调用时 content 为 null
+type OpenAIResponseMessage struct {
+	Role      string           `json:"role,omitempty"`
+	Content   *string          `json:"content,omitempty"`
+	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
+}
+
+// OpenAIUsage 令牌用量
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// OpenAIModel 模型列表中的模型
+type OpenAIModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// openAIError OpenAI 格式的错误
+type openAIError struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+// respondOpenAIError 以 OpenAI 的错误格式响应，兼容 OpenAI SDK 的错误解析
+func respondOpenAIError(c *gin.Context, status int, errType, code, param, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"error": newOpenAIError(errType, code, param, message)})
+}
+
+func newOpenAIError(errType, code, param, message string) openAIError {
+	e := openAIError{Message: message, Type: errType}
+	if code != "" {
+		e.Code = &code
+	}
+	if param != "" {
+		e.Param = &param
+	}
+	return e
+}
+
+// handleListModels 列出可用的模型
+func (s *APIServer) handleListModels(c *gin.Context) {
+	models := s.chatService.Models()
+	data := make([]OpenAIModel, 0, len(models))
+	for _, m := range models {
+		data = append(data, OpenAIModel{ID: m.ID, Object: "model", Created: s.startedAt.Unix(), OwnedBy: ownedBy(m)})
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data})
+}
+
+// ownedBy 返回模型的提供方，未知时为 weave
+func ownedBy(m chatservice.ModelInfo) string {
+	if m.Provider == "" {
+		return "weave"
+	}
+	return m.Provider
+}
+
+// handleChatCompletions 处理 OpenAI 兼容的对话补全请求
+// 历史消息由调用方在 messages 中提供，不读写 Weave 的对话；敏感内容过滤、历史筛选和 MCP 工具照常生效
+func (s *APIServer) handleChatCompletions(c *gin.Context) {
+	var req OpenAIChatCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, openAIInvalidRequest, "", "", err.Error())
+		return
+	}
+	if req.N != nil && *req.N != 1 {
+		respondOpenAIError(c, http.StatusBadRequest, openAIInvalidRequest, "", "n", "only n=1 is supported")
+		return
+	}
+
+	modelID, ok := s.resolveModel(req.Model)
+	if !ok {
+		respondOpenAIError(c, http.StatusNotFound, openAIInvalidRequest, "model_not_found", "model",
+			fmt.Sprintf("The model '%s' does not exist", req.Model))
+		return
+	}
+
+	completionReq, param, err := buildCompletionRequest(&req)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, openAIInvalidRequest, "", param, err.Error())
+		return
+	}
+	completionReq.UserID = principalFrom(c).Key()
+	format, err := responseFormatOf(req.ResponseFormat)
+	if err != nil {
+		respondOpenAIError(c, http.StatusBadRequest, openAIInvalidRequest, "", "response_format", err.Error())
+		return
+	}
+
+	// 未指定模型时按路由规则选择，响应中的模型为实际响应请求的模型
+	route := &model.Route{Model: req.Model, ResponseFormat: format}
+	ctx := model.WithRoute(c.Request.Context(), route)
+
+	id := "chatcmpl-" + pkg.RandomString(24)
+	created := time.Now().Unix()
+	if req.Stream {
+		s.streamChatCompletion(ctx, c, &req, completionReq, id, created, route, modelID)
+		return
+	}
+
+	result, err := s.chatService.Complete(ctx, completionReq)
+	if err != nil {
+		s.respondCompletionError(c, completionReq.UserID, err)
+		return
+	}
+
+	message := newResponseMessage(result.Message)
+	message.Role = string(schema.Assistant)
+	if message.Content == nil && len(message.ToolCalls) == 0 {
+		empty := ""
+		message.Content = &empty
+	}
+	finishReason := result.FinishReason
+	c.JSON(http.StatusOK, OpenAIChatCompletion{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   servedModel(route, modelID),
+		Choices: []OpenAIChoice{{Index: 0, Message: message, FinishReason: &finishReason}},
+		Usage:   newOpenAIUsage(result.Usage),
+	})
+}
+
+// streamChatCompletion 以 SSE 分块返回对话补全，以 data: [DONE] 结束
+// 第一个分块之前出错时返回普通的错误响应，之后的错误以 error 事件写入流中
+func (s *APIServer) streamChatCompletion(ctx context.Context, c *gin.Context, req *OpenAIChatCompletionRequest, completionReq *chatservice.CompletionRequest, id string, created int64, route *model.Route, modelID string) {
+	started := false
+	writeChunk := func(choices []OpenAIChoice, usage *OpenAIUsage) error {
+		data, err := json.Marshal(OpenAIChatCompletion{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   servedModel(route, modelID),
+			Choices: choices,
+			Usage:   usage,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := c.Writer.WriteString("data: " + string(data) + "\n\n"); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Status(http.StatusOK)
+		empty := ""
+		return writeChunk([]OpenAIChoice{{Delta: &OpenAIResponseMessage{Role: string(schema.Assistant), Content: &empty}}}, nil)
+	}
+
+	result, err := s.chatService.CompleteStream(ctx, completionReq, func(chunk *schema.Message) error {
+		if err := start(); err != nil {
+			return err
+		}
+		return writeChunk([]OpenAIChoice{{Delta: newResponseMessage(chunk)}}, nil)
+	})
+	if err != nil {
+		if !started {
+			s.respondCompletionError(c, completionReq.UserID, err)
+			return
+		}
+		if c.Request.Context().Err() == nil {
+			s.logger.Error("流式对话补全失败", zap.Error(err), zap.String("user_id", completionReq.UserID))
+			data, _ := json.Marshal(gin.H{"error": newOpenAIError(openAIServerError, "", "", "The model failed to generate a response")})
+			c.Writer.WriteString("data: " + string(data) + "\n\n")
+			c.Writer.WriteString("data: [DONE]\n\n")
+			c.Writer.Flush()
+		}
+		return
+	}
+
+	if err := start(); err != nil {
+		return
+	}
+	finishReason := result.FinishReason
+	if err := writeChunk([]OpenAIChoice{{Delta: &OpenAIResponseMessage{}, FinishReason: &finishReason}}, nil); err != nil {
+		return
+	}
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		if err := writeChunk([]OpenAIChoice{}, newOpenAIUsage(result.Usage)); err != nil {
+			return
+		}
+	}
+	c.Writer.WriteString("data: [DONE]\n\n")
+	c.Writer.Flush()
+}
+
+// respondCompletionError 将补全错误转换为 OpenAI 格式的错误响应
+func (s *APIServer) respondCompletionError(c *gin.Context, userID string, err error) {
+	switch {
+	case errors.Is(err, chatservice.ErrEmptyMessages):
+		respondOpenAIError(c, http.StatusBadRequest, openAIInvalidRequest, "", "messages", err.Error())
+	case errors.Is(err, chatservice.ErrToolsUnsupported), errors.Is(err, model.ErrToolsUnsupported):
+		respondOpenAIError(c, http.StatusBadRequest, openAIInvalidRequest, "", "tools", err.Error())
+	case errors.Is(err, chatservice.ErrImageLimitExceeded):
+		respondOpenAIError(c, http.StatusTooManyRequests, openAIRateLimit, "rate_limit_exceeded", "", err.Error())
+	case errors.Is(err, model.ErrNoModelAvailable):
+		respondOpenAIError(c, http.StatusServiceUnavailable, openAIServerError, "model_unavailable", "model", "No model is currently available for the request")
+	case errors.Is(err, chatservice.ErrStructuredOutput):
+		respondOpenAIError(c, http.StatusUnprocessableEntity, openAIServerError, "invalid_structured_output", "response_format", err.Error())
+	default:
+		s.logger.Error("对话补全失败", zap.Error(err), zap.String("user_id", userID))
+		respondOpenAIError(c, http.StatusInternalServerError, openAIServerError, "", "", "The model failed to generate a response")
+	}
+}
+
+// servedModel 返回实际响应请求的模型名称，尚未调用模型时使用请求解析出的模型
+func servedModel(route *model.Route, modelID string) string {
+	if served := route.Served(); served != "" {
+		return served
+	}
+	return modelID
+}
+
+// resolveModel 返回请求使用的模型名称，未指定时使用第一个可用模型
+func (s *APIServer) resolveModel(name string) (string, bool) {
+	models := s.chatService.Models()
+	if name == "" {
+		if len(models) == 0 {
+			return "", true
+		}
+		return models[0].ID, true
+	}
+	for _, m := range models {
+		if m.ID == name {
+			return m.ID, true
+		}
+	}
+	return "", false
+}
+
+// buildCompletionRequest 将 OpenAI 请求转换为补全请求，出错时返回出错的参数名
+func buildCompletionRequest(req *OpenAIChatCompletionRequest) (*chatservice.CompletionRequest, string, error) {
+	messages := make([]*schema.Message, 0, len(req.Messages))
+	for i, m := range req.Messages {
+		msg, err := toSchemaMessage(m)
+		if err != nil {
+			return nil, fmt.Sprintf("messages[%d]", i), err
+		}
+		messages = append(messages, msg)
+	}
+
+	completionReq := &chatservice.CompletionRequest{Messages: messages}
+	if req.Temperature != nil {
+		completionReq.ModelOptions = append(completionReq.ModelOptions, einomodel.WithTemperature(*req.Temperature))
+	}
+	if req.TopP != nil {
+		completionReq.ModelOptions = append(completionReq.ModelOptions, einomodel.WithTopP(*req.TopP))
+	}
+	if maxTokens := req.MaxCompletion; maxTokens != nil || req.MaxTokens != nil {
+		if maxTokens == nil {
+			maxTokens = req.MaxTokens
+		}
+		completionReq.ModelOptions = append(completionReq.ModelOptions, einomodel.WithMaxTokens(*maxTokens))
+	}
+	if len(req.Stop) > 0 {
+		completionReq.ModelOptions = append(completionReq.ModelOptions, einomodel.WithStop(req.Stop))
+	}
+
+	tools := make([]*schema.ToolInfo, 0, len(req.Tools))
+	for _, t := range req.Tools {
+		info := &schema.ToolInfo{Name: t.Function.Name, Desc: t.Function.Description}
+		if t.Function.Parameters != nil {
+			info.ParamsOneOf = schema.NewParamsOneOfByJSONSchema(t.Function.Parameters)
+		}
+		tools = append(tools, info)
+	}
+	tools, choice, err := applyToolChoice(tools, req.ToolChoice)
+	if err != nil {
+		return nil, "tool_choice", err
+	}
+	completionReq.Tools = tools
+	completionReq.ToolChoice = choice
+	return completionReq, "", nil
+}
+
+// jsonObjectSchema json_object 回复格式对应的 JSON Schema
+var jsonObjectSchema = json.RawMessage(`{"type":"object"}`)
+
+// responseFormatOf 将 response_format 转换为结构化输出的 JSON Schema，text 时返回 nil
+func responseFormatOf(format *OpenAIResponseFormat) (*model.ResponseFormat, error) {
+	if format == nil {
+		return nil, nil
+	}
+	switch format.Type {
+	case "json_object":
+		return &model.ResponseFormat{Name: "json_object", Schema: jsonObjectSchema}, nil
+	case "json_schema":
+		if format.JSONSchema == nil || len(format.JSONSchema.Schema) == 0 {
+			return nil, errors.New("response_format.json_schema.schema is required")
+		}
+		if _, err := structured.Compile(format.JSONSchema.Schema); err != nil {
+			return nil, err
+		}
+		return &model.ResponseFormat{Name: format.JSONSchema.Name, Schema: format.JSONSchema.Schema}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// applyToolChoice 解析 tool_choice：none、auto、required，或指定函数（只保留该函数并强制调用）
+func applyToolChoice(tools []*schema.ToolInfo, raw json.RawMessage) ([]*schema.ToolInfo, *schema.ToolChoice, error) {
+	if len(raw) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) || len(tools) == 0 {
+		return tools, nil, nil
+	}
+
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		var choice schema.ToolChoice
+		switch mode {
+		case "none":
+			choice = schema.ToolChoiceForbidden
+		case "auto":
+			choice = schema.ToolChoiceAllowed
+		case "required":
+			choice = schema.ToolChoiceForced
+		default:
+			return nil, nil, fmt.Errorf("unsupported tool_choice %q", mode)
+		}
+		return tools, &choice, nil
+	}
+
+	var named struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Type != "function" {
+		return nil, nil, errors.New("tool_choice must be none, auto, required or a function")
+	}
+	for _, t := range tools {
+		if t.Name == named.Function.Name {
+			choice := schema.ToolChoiceForced
+			return []*schema.ToolInfo{t}, &choice, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("tool_choice function %q is not in tools", named.Function.Name)
+}
+
+// toSchemaMessage 将 OpenAI 消息转换为 eino 消息，developer 消息按系统消息处理
+func toSchemaMessage(m OpenAIMessage) (*schema.Message, error) {
+	text, parts, err := decodeContent(m.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	switch m.Role {
+	case "system", "developer":
+		return schema.SystemMessage(text), nil
+	case "assistant":
+		toolCalls := make([]schema.ToolCall, 0, len(m.ToolCalls))
+		for _, tc := range m.ToolCalls {
+			toolCalls = append(toolCalls, schema.ToolCall{
+				ID:       tc.ID,
+				Type:     "function",
+				Function: schema.FunctionCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments},
+			})
+		}
+		return schema.AssistantMessage(text, toolCalls), nil
+	case "tool":
+		if m.ToolCallID == "" {
+			return nil, errors.New("tool messages must have a tool_call_id")
+		}
+		msg := schema.ToolMessage(text, m.ToolCallID)
+		msg.ToolName = m.Name
+		return msg, nil
+	default:
+		if len(parts) == 0 {
+			return schema.UserMessage(text), nil
+		}
+		return &schema.Message{Role: schema.User, UserInputMultiContent: parts, Name: m.Name}, nil
+	}
+}
+
+// decodeContent 解析消息内容：字符串直接作为文本；片段数组中只有文本时拼接为文本，包含图片时返回多模态片段
+func decodeContent(raw json.RawMessage) (string, []schema.MessageInputPart, error) {
+	if len(raw) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return "", nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil, nil
+	}
+
+	var contentParts []OpenAIContentPart
+	if err := json.Unmarshal(raw, &contentParts); err != nil {
+		return "", nil, errors.New("content must be a string or an array of content parts")
+	}
+	var texts []string
+	parts := make([]schema.MessageInputPart, 0, len(contentParts))
+	hasImages := false
+	for _, part := range contentParts {
+		switch part.Type {
+		case "text":
+			texts = append(texts, part.Text)
+			parts = append(parts, schema.MessageInputPart{Type: schema.ChatMessagePartTypeText, Text: part.Text})
+		case "image_url":
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return "", nil, errors.New("image_url parts must have a url")
+			}
+			if err := validateImageURL(part.ImageURL.URL); err != nil {
+				return "", nil, err
+			}
+			url := part.ImageURL.URL
+			parts = append(parts, schema.MessageInputPart{
+				Type: schema.ChatMessagePartTypeImageURL,
+				Image: &schema.MessageInputImage{
+					MessagePartCommon: schema.MessagePartCommon{URL: &url},
+					Detail:            schema.ImageURLDetail(part.ImageURL.Detail),
+				},
+			})
+			hasImages = true
+		default:
+			return "", nil, fmt.Errorf("unsupported content part type %q", part.Type)
+		}
+	}
+	if !hasImages {
+		return strings.Join(texts, "\n"), nil, nil
+	}
+	return "", parts, nil
+}
+
+// validateImageURL 校验图片地址：只接受 http(s) 链接和合法的 base64 图片 data URL
+func validateImageURL(url string) error {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return nil
+	}
+	header, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !strings.HasPrefix(url, "data:image/") || !ok || !strings.HasSuffix(header, ";base64") {
+		return errors.New("image_url must be an http(s) URL or a base64 image data URL")
+	}
+	if err := security.IsValidBase64Image(data); err != nil {
+		return fmt.Errorf("invalid base64 image: %w", err)
+	}
+	return nil
+}
+
+// newResponseMessage 将回复或流式分块转换为 OpenAI 格式的消息
+func newResponseMessage(msg *schema.Message) *OpenAIResponseMessage {
+	resp := &OpenAIResponseMessage{}
+	if msg.Content != "" || len(msg.ToolCalls) == 0 {
+		content := msg.Content
+		resp.Content = &content
+	}
+	for i, tc := range msg.ToolCalls {
+		index := i
+		if tc.Index != nil {
+			index = *tc.Index
+		}
+		toolType := tc.Type
+		if toolType == "" && tc.ID != "" {
+			toolType = "function"
+		}
+		resp.ToolCalls = append(resp.ToolCalls, OpenAIToolCall{
+			Index:    &index,
+			ID:       tc.ID,
+			Type:     toolType,
+			Function: OpenAIFunctionCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments},
+		})
+	}
+	return resp
+}
+
+// newOpenAIUsage 转换令牌用量
+func newOpenAIUsage(usage schema.TokenUsage) *OpenAIUsage {
+	return &OpenAIUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
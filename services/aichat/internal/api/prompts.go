@@ -0,0 +1,274 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"weave/pkg"
+	"weave/services/aichat/internal/model"
+	"weave/services/aichat/internal/prompts"
+	"weave/services/aichat/internal/service/chat"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 模板和人设的作用范围
+const (
+	promptScopeTenant = "tenant"
+	promptScopeGlobal = "global"
+)
+
+// defaultTemplateWeight 未指定权重时新版本的分流权重，此时新版本独占流量
+const defaultTemplateWeight = 100
+
+// PromptScopeQuery 模板和人设写操作的作用范围，global 为所有租户共享的全局模板
+type PromptScopeQuery struct {
+	Scope string `form:"scope,default=tenant" binding:"oneof=tenant global"`
+}
+
+// ListTemplatesQuery 模板列表查询参数
+type ListTemplatesQuery struct {
+	Name string `form:"name"`
+}
+
+// CreateTemplateRequest 创建模板版本请求
+// 不指定 weight 时新版本独占流量（其他版本权重置为 0），指定时与其他版本按权重分流
+type CreateTemplateRequest struct {
+	Name        string            `json:"name" binding:"required,max=64"`
+	Content     string            `json:"content" binding:"required"`
+	Variables   map[string]string `json:"variables"`
+	Description string            `json:"description" binding:"max=255"`
+	Weight      *int              `json:"weight" binding:"omitempty,min=0"`
+}
+
+// UpdateTemplateVersionRequest 修改模板版本的分流权重
+type UpdateTemplateVersionRequest struct {
+	Weight *int `json:"weight" binding:"required,min=0"`
+}
+
+// SavePersonaRequest 创建或覆盖人设请求，template 为空时使用 default 模板
+type SavePersonaRequest struct {
+	Description string            `json:"description" binding:"max=255"`
+	Template    string            `json:"template" binding:"max=64"`
+	Variables   map[string]string `json:"variables"`
+}
+
+// PromptPreviewQuery 提示词预览参数，input 为假设的下一条用户输入，persona 覆盖对话的人设
+type PromptPreviewQuery struct {
+	Input   string `form:"input"`
+	Persona string `form:"persona" binding:"max=64"`
+}
+
+// PromptPreviewResponse 提示词预览响应
+type PromptPreviewResponse struct {
+	ConversationID string `json:"conversation_id"`
+	PromptTemplate string `json:"prompt_template"`
+	*chat.PromptPreview
+}
+
+// promptError 将提示词管理器返回的错误转换为 AppError
+func promptError(err error, fallback string) *pkg.AppError {
+	switch {
+	case errors.Is(err, prompts.ErrTemplateNotFound):
+		return pkg.NewNotFound("提示词模板不存在", err)
+	case errors.Is(err, prompts.ErrPersonaNotFound):
+		return pkg.NewNotFound("人设不存在", err)
+	case errors.Is(err, prompts.ErrInvalidTemplate):
+		return pkg.NewValidationError("提示词模板无效", err)
+	default:
+		return conversationError(err, fallback)
+	}
+}
+
+// tenantOf 返回调用方的租户 ID，与会话键中的租户一致
+func tenantOf(principal Principal) string {
+	return strconv.FormatUint(uint64(principal.TenantID), 10)
+}
+
+// promptWriteTenant 校验模板和人设的写权限并返回写入的租户，全局范围返回空字符串
+// 修改提示词会影响租户内所有用户，只允许服务令牌（由网关校验管理员权限）调用
+func promptWriteTenant(c *gin.Context) (string, bool) {
+	var query PromptScopeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return "", false
+	}
+	principal := principalFrom(c)
+	if !principal.Service {
+		pkg.RespondError(c, pkg.NewForbidden("只有服务令牌可以修改提示词模板和人设", nil))
+		return "", false
+	}
+	if query.Scope == promptScopeGlobal {
+		return "", true
+	}
+	return tenantOf(principal), true
+}
+
+// handleListTemplates 列出租户可见的模板版本，租户版本在前，全局版本在后
+func (s *APIServer) handleListTemplates(c *gin.Context) {
+	var query ListTemplatesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+
+	templates, err := s.chatService.Prompts().Templates(c.Request.Context(), tenantOf(principalFrom(c)), query.Name)
+	if err != nil {
+		s.logger.Error("获取提示词模板失败", zap.Error(err))
+		pkg.RespondError(c, promptError(err, "获取提示词模板失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates, "builtin": prompts.Builtin()})
+}
+
+// handleCreateTemplate 保存模板的新版本
+func (s *APIServer) handleCreateTemplate(c *gin.Context) {
+	var req CreateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	tenant, ok := promptWriteTenant(c)
+	if !ok {
+		return
+	}
+
+	template := prompts.Template{
+		Name:        req.Name,
+		TenantID:    tenant,
+		Content:     req.Content,
+		Variables:   req.Variables,
+		Description: req.Description,
+		Weight:      defaultTemplateWeight,
+	}
+	if req.Weight != nil {
+		template.Weight = *req.Weight
+	}
+	created, err := s.chatService.Prompts().CreateTemplate(c.Request.Context(), template, req.Weight == nil)
+	if err != nil {
+		pkg.RespondError(c, promptError(err, "保存提示词模板失败"))
+		return
+	}
+	s.logger.Info("已保存提示词模板", zap.String("tenant_id", tenant), zap.String("template", created.Name), zap.Int("version", created.Version))
+	c.JSON(http.StatusCreated, created)
+}
+
+// handleUpdateTemplateVersion 修改模板版本的分流权重，用于调整 A/B 比例或回滚
+func (s *APIServer) handleUpdateTemplateVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		pkg.RespondError(c, pkg.NewValidationFormatError("无效的模板版本号", err))
+		return
+	}
+	var req UpdateTemplateVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	tenant, ok := promptWriteTenant(c)
+	if !ok {
+		return
+	}
+
+	template, err := s.chatService.Prompts().SetWeight(c.Request.Context(), tenant, c.Param("name"), version, *req.Weight)
+	if err != nil {
+		pkg.RespondError(c, promptError(err, "修改提示词模板失败"))
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// handleListPersonas 列出租户可见的人设
+func (s *APIServer) handleListPersonas(c *gin.Context) {
+	personas, err := s.chatService.Prompts().Personas(c.Request.Context(), tenantOf(principalFrom(c)))
+	if err != nil {
+		s.logger.Error("获取人设失败", zap.Error(err))
+		pkg.RespondError(c, promptError(err, "获取人设失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"personas": personas})
+}
+
+// handleSavePersona 创建或覆盖人设
+func (s *APIServer) handleSavePersona(c *gin.Context) {
+	var req SavePersonaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	tenant, ok := promptWriteTenant(c)
+	if !ok {
+		return
+	}
+
+	persona, err := s.chatService.Prompts().SavePersona(c.Request.Context(), prompts.Persona{
+		Name:        c.Param("name"),
+		TenantID:    tenant,
+		Description: req.Description,
+		Template:    req.Template,
+		Variables:   req.Variables,
+	})
+	if err != nil {
+		pkg.RespondError(c, promptError(err, "保存人设失败"))
+		return
+	}
+	c.JSON(http.StatusOK, persona)
+}
+
+// handleDeletePersona 删除人设，使用该人设的对话回退到 default 模板
+func (s *APIServer) handleDeletePersona(c *gin.Context) {
+	tenant, ok := promptWriteTenant(c)
+	if !ok {
+		return
+	}
+	if err := s.chatService.Prompts().DeletePersona(c.Request.Context(), tenant, c.Param("name")); err != nil {
+		pkg.RespondError(c, promptError(err, "删除人设失败"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handlePreviewPrompt 渲染对话下一轮请求将发送给模型的消息
+func (s *APIServer) handlePreviewPrompt(c *gin.Context) {
+	var query PromptPreviewQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	sessionKey := principalFrom(c).Key()
+
+	preview, err := s.chatService.PreviewPrompt(c.Request.Context(), sessionKey, c.Param("id"), query.Input, query.Persona)
+	if err != nil {
+		pkg.RespondError(c, promptError(err, "预览提示词失败"))
+		return
+	}
+	c.JSON(http.StatusOK, PromptPreviewResponse{
+		ConversationID: c.Param("id"),
+		PromptTemplate: preview.Selection.String(),
+		PromptPreview:  preview,
+	})
+}
+
+// setConversationPersona 设置对话的人设，persona 为空时清除，人设必须对调用方的租户可见
+func (s *APIServer) setConversationPersona(c *gin.Context, conv *model.Conversation, persona string) (*model.Conversation, error) {
+	ctx := c.Request.Context()
+	if persona != "" {
+		if _, err := s.chatService.Prompts().Persona(ctx, tenantOf(principalFrom(c)), persona); err != nil {
+			return nil, err
+		}
+	}
+	if conv.Metadata == nil {
+		conv.Metadata = make(map[string]string)
+	}
+	if persona == "" {
+		delete(conv.Metadata, prompts.PersonaMetadataKey)
+	} else {
+		conv.Metadata[prompts.PersonaMetadataKey] = persona
+	}
+	if err := s.chatService.Conversations().SaveConversation(ctx, conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
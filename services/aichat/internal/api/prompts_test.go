@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func decodeJSON(t *testing.T, body []byte, v any) {
+	t.Helper()
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+}
+
+// chatOnce 发送一轮聊天，返回响应和模型收到的系统提示词
+func chatOnce(t *testing.T, s *APIServer, llm *fakeChatModel, body string) (ChatResponse, string) {
+	t.Helper()
+	w := doUsageRequest(s, http.MethodPost, "/api/chat", body, "9")
+	if w.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", w.Code, w.Body.String())
+	}
+	var resp ChatResponse
+	decodeJSON(t, w.Body.Bytes(), &resp)
+	input := llm.inputs[len(llm.inputs)-1]
+	if len(input) == 0 || input[0].Role != schema.System {
+		t.Fatalf("expected a system prompt, got %+v", input)
+	}
+	return resp, input[0].Content
+}
+
+func TestPromptTemplatesAndPersonas(t *testing.T) {
+	llm := &fakeChatModel{}
+	s := newOpenAITestServer(t, llm)
+
+	resp, system := chatOnce(t, s, llm, `{"user_input":"你好"}`)
+	if resp.PromptTemplate != "default@builtin" || !strings.Contains(system, "PaiChat") {
+		t.Fatalf("expected the builtin prompt, got %q %q", resp.PromptTemplate, system)
+	}
+
+	// 全局 default 模板的新版本替代内置提示词
+	w := doUsageRequest(s, http.MethodPost, "/api/prompts/templates?scope=global",
+		`{"name":"default","content":"你是{role}，请用{style}的语气回答。{chat_history}","variables":{"role":"Weave 助手"}}`, "9")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create global template: %d %s", w.Code, w.Body.String())
+	}
+	resp, system = chatOnce(t, s, llm, `{"user_input":"还在吗"}`)
+	if resp.PromptTemplate != "default@v1" || !strings.HasPrefix(system, "你是Weave 助手，请用积极、温暖且专业的语气回答。") {
+		t.Fatalf("expected the global template, got %q %q", resp.PromptTemplate, system)
+	}
+
+	// 租户模板和人设
+	w = doUsageRequest(s, http.MethodPost, "/api/prompts/templates",
+		`{"name":"support","content":"你是{company}的客服{{工号 {agent_id}}}。历史：{chat_history}","variables":{"company":"Weave","agent_id":"001"}}`, "9")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create tenant template: %d %s", w.Code, w.Body.String())
+	}
+	w = doUsageRequest(s, http.MethodPut, "/api/prompts/personas/support-agent",
+		`{"template":"support","variables":{"company":"织布科技"}}`, "9")
+	if w.Code != http.StatusOK {
+		t.Fatalf("save persona: %d %s", w.Code, w.Body.String())
+	}
+	if w := doUsageRequest(s, http.MethodPut, "/api/prompts/personas/ghost", `{"template":"missing"}`, "9"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a persona with an unknown template, got %d", w.Code)
+	}
+	if w := doUsageRequest(s, http.MethodPost, "/api/prompts/templates", `{"name":"broken","content":"你好{"}`, "9"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid template, got %d %s", w.Code, w.Body.String())
+	}
+	if w := doUsageRequest(s, http.MethodPost, "/api/conversations", `{"persona":"nobody"}`, "9"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown persona, got %d", w.Code)
+	}
+
+	w = doUsageRequest(s, http.MethodPost, "/api/conversations", `{"title":"售后","persona":"support-agent"}`, "9")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create conversation: %d %s", w.Code, w.Body.String())
+	}
+	var conv ConversationResponse
+	decodeJSON(t, w.Body.Bytes(), &conv)
+	if conv.Metadata["persona"] != "support-agent" {
+		t.Fatalf("expected the persona in metadata, got %+v", conv.Metadata)
+	}
+
+	resp, system = chatOnce(t, s, llm, fmt.Sprintf(`{"user_input":"订单到哪了","conversation_id":%q}`, conv.ID))
+	if resp.PromptTemplate != "support@v1" || !strings.HasPrefix(system, "你是织布科技的客服{工号 001}。历史：") {
+		t.Fatalf("expected the persona template, got %q %q", resp.PromptTemplate, system)
+	}
+
+	// 回复中记录了模板版本
+	w = doUsageRequest(s, http.MethodGet, "/api/conversations/"+conv.ID, "", "9")
+	decodeJSON(t, w.Body.Bytes(), &conv)
+	if last := conv.Messages[len(conv.Messages)-1]; last.Extra["prompt_template"] != "support@v1" {
+		t.Fatalf("expected the template version on the reply, got %+v", last.Extra)
+	}
+
+	// 预览下一轮请求的消息
+	w = doUsageRequest(s, http.MethodGet, "/api/conversations/"+conv.ID+"/prompt?input=还要多久", "", "9")
+	if w.Code != http.StatusOK {
+		t.Fatalf("preview: %d %s", w.Code, w.Body.String())
+	}
+	var preview PromptPreviewResponse
+	decodeJSON(t, w.Body.Bytes(), &preview)
+	messages := preview.Messages
+	if preview.PromptTemplate != "support@v1" || len(messages) != 2 || messages[1].Content != "还要多久" ||
+		!strings.Contains(messages[0].Content, "订单到哪了") || preview.Tokens <= 0 || preview.Tokens > preview.Budget {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+	w = doUsageRequest(s, http.MethodGet, "/api/conversations/"+conv.ID+"/prompt?persona=nobody", "", "9")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 when previewing an unknown persona, got %d", w.Code)
+	}
+
+	// 用量按模板版本汇总
+	usage := decodeUsage(t, doUsageRequest(s, http.MethodGet, "/api/chat/usage", "", "9"))
+	got := make(map[string]int64)
+	for _, totals := range usage.Templates {
+		got[totals.PromptTemplate] = totals.Requests
+	}
+	if got["default@builtin"] != 1 || got["default@v1"] != 1 || got["support@v1"] != 1 {
+		t.Fatalf("unexpected template usage %+v", usage.Templates)
+	}
+}
+
+func TestPromptTemplateABAssignment(t *testing.T) {
+	s := newOpenAITestServer(t, &fakeChatModel{})
+	for _, body := range []string{
+		`{"name":"default","content":"A 版本 {chat_history}"}`,
+		`{"name":"default","content":"B 版本 {chat_history}","weight":100}`,
+	} {
+		if w := doUsageRequest(s, http.MethodPost, "/api/prompts/templates", body, "9"); w.Code != http.StatusCreated {
+			t.Fatalf("create template: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	ctx := context.Background()
+	manager := s.chatService.Prompts()
+	counts := make(map[int]int)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("conversation-%d", i)
+		sel, err := manager.Select(ctx, "3", "", key)
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		again, _ := manager.Select(ctx, "3", "", key)
+		if again.Version != sel.Version {
+			t.Fatalf("expected %s to stay on version %d, got %d", key, sel.Version, again.Version)
+		}
+		counts[sel.Version]++
+	}
+	if counts[1] < 60 || counts[2] < 60 {
+		t.Fatalf("expected an even split between versions, got %v", counts)
+	}
+
+	// 其他租户不受该租户模板影响
+	if sel, _ := manager.Select(ctx, "4", "", "conversation-1"); sel.String() != "default@builtin" {
+		t.Fatalf("expected other tenants to use the builtin prompt, got %s", sel)
+	}
+
+	// 关闭 A 版本后全部流量切到 B 版本
+	if w := doUsageRequest(s, http.MethodPatch, "/api/prompts/templates/default/versions/1", `{"weight":0}`, "9"); w.Code != http.StatusOK {
+		t.Fatalf("update weight: %d %s", w.Code, w.Body.String())
+	}
+	for i := 0; i < 20; i++ {
+		if sel, _ := manager.Select(ctx, "3", "", fmt.Sprintf("conversation-%d", i)); sel.Version != 2 {
+			t.Fatalf("expected version 2 after disabling version 1, got %d", sel.Version)
+		}
+	}
+	if w := doUsageRequest(s, http.MethodPatch, "/api/prompts/templates/default/versions/9", `{"weight":0}`, "9"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown version, got %d", w.Code)
+	}
+}
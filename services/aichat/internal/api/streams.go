@@ -0,0 +1,354 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"weave/pkg"
+	"weave/pkg/i18n"
+	"weave/services/aichat/internal/model"
+	"weave/services/aichat/internal/prompts"
+	"weave/services/aichat/internal/resumable"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// streamGenerationTimeout 一次生成的最长时间，客户端断开后生成仍会继续，直到结束或超时
+	streamGenerationTimeout = 10 * time.Minute
+	// streamReadWait 没有新事件时等待的时长，超时后 SSE 发送保活注释
+	streamReadWait = 15 * time.Second
+	// streamControlInterval 生成过程中读取控制状态的间隔，暂停时按该间隔等待
+	streamControlInterval = 200 * time.Millisecond
+)
+
+// StreamControlRequest 生成控制请求结构
+type StreamControlRequest struct {
+	Action string `json:"action" binding:"required,oneof=pause resume continue stop"`
+}
+
+// streamRequest 发起生成所需的请求信息
+// 生成在后台进行，客户端断开后请求的 gin.Context 不再可用，需要的信息在发起时取出
+type streamRequest struct {
+	chat           ChatRequest
+	sessionKey     string
+	conversationID string
+	route          *model.Route
+	language       string // Accept-Language，用于本地化错误事件
+	requestID      string
+	path           string
+}
+
+// newStreamRequest 从请求中取出发起生成所需的信息
+func newStreamRequest(c *gin.Context, req ChatRequest, conversationID string, route *model.Route) streamRequest {
+	return streamRequest{
+		chat:           req,
+		sessionKey:     principalFrom(c).Key(),
+		conversationID: conversationID,
+		route:          route,
+		language:       c.GetHeader("Accept-Language"),
+		requestID:      c.GetString(pkg.RequestIDHeader),
+		path:           c.Request.URL.Path,
+	}
+}
+
+// startStream 创建生成并在后台执行，事件写入事件存储，客户端可以断线后从任意事件之后继续读取
+func (s *APIServer) startStream(ctx context.Context, req streamRequest) (*resumable.Info, error) {
+	info := &resumable.Info{ID: resumable.NewID(), Owner: req.sessionKey, ConversationID: req.conversationID}
+	if err := s.streams.Create(ctx, info); err != nil {
+		return nil, err
+	}
+	genCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), streamGenerationTimeout)
+	go func() {
+		defer cancel()
+		s.runStream(genCtx, info, req)
+	}()
+	return info, nil
+}
+
+// runStream 执行生成，把回复分块、工具调用和最终结果依次追加为事件，最后一个事件和结束状态一起写入
+func (s *APIServer) runStream(ctx context.Context, info *resumable.Info, req streamRequest) {
+	var template prompts.Selection
+	ctx = prompts.WithSelection(model.WithRoute(ctx, req.route), &template)
+
+	streamCallback := func(content string, isToolCall bool) error {
+		eventType := resumable.EventChunk
+		if isToolCall {
+			eventType = resumable.EventToolCall
+		}
+		data, err := json.Marshal(ChatResponse{
+			Content:        content,
+			Status:         "streaming",
+			ConversationID: req.conversationID,
+			StreamID:       info.ID,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = s.streams.Append(ctx, info.ID, eventType, data)
+		return err
+	}
+	control := &streamControl{store: s.streams, id: info.ID, status: resumable.StatusRunning}
+	controlCallback := func() (bool, bool) {
+		return control.check(ctx)
+	}
+
+	var fullContent string
+	var err error
+	chatReq := req.chat
+	if len(chatReq.ImageURLs) > 0 || len(chatReq.Base64Images) > 0 {
+		fullContent, err = s.chatService.ProcessUserInputStreamWithImages(ctx, chatReq.UserInput, req.sessionKey, req.conversationID, chatReq.ImageURLs, chatReq.Base64Images, streamCallback, controlCallback)
+	} else {
+		fullContent, err = s.chatService.ProcessUserInputStream(ctx, chatReq.UserInput, req.sessionKey, req.conversationID, streamCallback, controlCallback)
+	}
+
+	status, eventType := resumable.StatusCompleted, resumable.EventCompleted
+	var final any
+	if err != nil {
+		s.logger.Error("流式处理请求失败", zap.Error(err), zap.String("user_id", req.sessionKey), zap.String("stream_id", info.ID))
+		// 错误以 problem 事件的形式写入事件流
+		appErr := modelError(err, "流式处理失败").WithRequestID(req.requestID).WithPath(req.path)
+		status, eventType = resumable.StatusFailed, resumable.EventError
+		final = appErr.Problem(i18n.Negotiate(req.language))
+	} else {
+		if control.stopped() {
+			status = resumable.StatusStopped
+		}
+		final = ChatResponse{
+			Content:        fullContent,
+			Status:         "completed",
+			ConversationID: req.conversationID,
+			Model:          req.route.Served(),
+			PromptTemplate: template.String(),
+			Data:           structuredData(req.route, fullContent),
+			StreamID:       info.ID,
+		}
+	}
+	data, err := json.Marshal(final)
+	if err == nil {
+		_, err = s.streams.Finish(ctx, info.ID, status, eventType, data)
+	}
+	if err != nil {
+		s.logger.Error("写入生成结果失败", zap.Error(err), zap.String("stream_id", info.ID))
+	}
+}
+
+// streamControl 生成过程中读取控制状态
+// 控制请求可能由其他实例处理，状态保存在事件存储中，按 streamControlInterval 节流读取
+type streamControl struct {
+	store   resumable.Store
+	id      string
+	status  string
+	checked time.Time
+}
+
+// check 返回生成是否暂停、是否停止，暂停时等待一个间隔再返回，避免生成循环空转
+func (c *streamControl) check(ctx context.Context) (bool, bool) {
+	if time.Since(c.checked) >= streamControlInterval {
+		if info, err := c.store.Get(ctx, c.id); err == nil {
+			c.status = info.Status
+		}
+		c.checked = time.Now()
+	}
+	if c.status == resumable.StatusPaused {
+		time.Sleep(streamControlInterval)
+	}
+	return c.status == resumable.StatusPaused, c.stopped()
+}
+
+func (c *streamControl) stopped() bool {
+	return c.status == resumable.StatusStopping
+}
+
+// controlStatus 返回控制操作对应的生成状态
+func controlStatus(action string) string {
+	switch action {
+	case "pause":
+		return resumable.StatusPaused
+	case "stop":
+		return resumable.StatusStopping
+	default:
+		return resumable.StatusRunning
+	}
+}
+
+// controlStream 暂停、恢复或停止生成，并追加 control 事件通知正在读取的客户端
+func (s *APIServer) controlStream(ctx context.Context, info *resumable.Info, action string) (*resumable.Info, error) {
+	updated, err := s.streams.SetStatus(ctx, info.ID, controlStatus(action))
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(ChatResponse{Status: updated.Status, ConversationID: info.ConversationID, StreamID: info.ID})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.streams.Append(ctx, info.ID, resumable.EventControl, data); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// ownedStream 返回会话键发起的生成，其他用户的生成按不存在处理
+func (s *APIServer) ownedStream(ctx context.Context, sessionKey, id string) (*resumable.Info, *pkg.AppError) {
+	info, err := s.streams.Get(ctx, id)
+	if errors.Is(err, resumable.ErrStreamNotFound) || (err == nil && info.Owner != sessionKey) {
+		return nil, pkg.NewNotFound("生成不存在或已过期", resumable.ErrStreamNotFound)
+	}
+	if err != nil {
+		return nil, pkg.NewInternalError("获取生成失败", err)
+	}
+	return info, nil
+}
+
+// streamError 将生成控制错误转换为应用错误
+func streamError(err error, fallback string) *pkg.AppError {
+	switch {
+	case errors.Is(err, resumable.ErrStreamNotFound):
+		return pkg.NewNotFound("生成不存在或已过期", err)
+	case errors.Is(err, resumable.ErrStreamFinished):
+		return pkg.NewConflict("生成已结束", err)
+	default:
+		return pkg.NewInternalError(fallback, err)
+	}
+}
+
+// followStream 依次把 ID 大于 after 的事件交给 emit，直到最后一个事件、生成结束或 ctx 取消
+// 等待 streamReadWait 仍没有新事件时调用 idle
+func (s *APIServer) followStream(ctx context.Context, id string, after int64, emit func(resumable.Event) error, idle func() error) error {
+	for {
+		events, err := s.streams.Read(ctx, id, after, streamReadWait)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			info, err := s.streams.Get(ctx, id)
+			if err != nil {
+				return err
+			}
+			// 已结束的生成没有新事件时不会再有事件
+			if info.Finished() {
+				return nil
+			}
+			if idle != nil {
+				if err := idle(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		for _, event := range events {
+			if err := emit(event); err != nil {
+				return err
+			}
+			after = event.ID
+			if resumable.Terminal(event.Type) {
+				return nil
+			}
+		}
+	}
+}
+
+// serveStreamEvents 以 SSE 发送生成中 ID 大于 after 的事件，事件 ID 写入 id 字段，客户端断线后可以通过 Last-Event-ID 继续读取
+func (s *APIServer) serveStreamEvents(c *gin.Context, info *resumable.Info, after int64) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Stream-ID", info.ID)
+	origin := c.Request.Header.Get("Origin")
+	if origin != "" {
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Vary", "Origin")
+	}
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	write := func(text string) error {
+		if _, err := c.Writer.WriteString(text); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+	err := s.followStream(ctx, info.ID, after, func(event resumable.Event) error {
+		return write(fmt.Sprintf("id: %d\ndata: %s\n\n", event.ID, event.Data))
+	}, func() error {
+		return write(": keep-alive\n\n")
+	})
+	if err != nil && ctx.Err() == nil {
+		s.logger.Warn("发送生成事件失败", zap.Error(err), zap.String("stream_id", info.ID))
+	}
+}
+
+// handleGetStream 处理获取生成状态请求
+func (s *APIServer) handleGetStream(c *gin.Context) {
+	info, appErr := s.ownedStream(c.Request.Context(), principalFrom(c).Key(), c.Param("id"))
+	if appErr != nil {
+		pkg.RespondError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// handleStreamEvents 处理断线重连请求，从 Last-Event-ID 头或 last_event_id 参数指定的事件之后继续发送
+func (s *APIServer) handleStreamEvents(c *gin.Context) {
+	info, appErr := s.ownedStream(c.Request.Context(), principalFrom(c).Key(), c.Param("id"))
+	if appErr != nil {
+		pkg.RespondError(c, appErr)
+		return
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var after int64
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || id < 0 {
+			pkg.RespondError(c, pkg.NewValidationError("无效的事件 ID", err))
+			return
+		}
+		after = id
+	}
+	s.serveStreamEvents(c, info, after)
+}
+
+// handleStreamControl 处理生成控制请求
+func (s *APIServer) handleStreamControl(c *gin.Context) {
+	var req StreamControlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	sessionKey := principalFrom(c).Key()
+	info, appErr := s.ownedStream(c.Request.Context(), sessionKey, c.Param("id"))
+	if appErr != nil {
+		pkg.RespondError(c, appErr)
+		return
+	}
+	s.respondControl(c, sessionKey, info, req.Action)
+}
+
+// respondControl 执行控制操作并返回控制响应
+func (s *APIServer) respondControl(c *gin.Context, sessionKey string, info *resumable.Info, action string) {
+	updated, err := s.controlStream(c.Request.Context(), info, action)
+	if err != nil {
+		appErr := streamError(err, "更新生成状态失败")
+		if pkg.GetHTTPStatus(appErr) >= http.StatusInternalServerError {
+			s.logger.Error("更新生成状态失败", zap.Error(err), zap.String("user_id", sessionKey), zap.String("stream_id", info.ID))
+		}
+		pkg.RespondError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, ChatControlResponse{
+		Status:  "success",
+		Message: "已成功执行 " + action + " 操作",
+		Stream:  updated,
+	})
+}
@@ -0,0 +1,325 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"weave/config"
+	"weave/services/aichat/internal/resumable"
+	"weave/utils"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"golang.org/x/net/websocket"
+)
+
+// sseEvent 带 ID 的 SSE 事件
+type sseEvent struct {
+	ID   int64
+	Data string
+}
+
+func sseEventsWithID(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var id int64
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "id: "); ok {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				t.Fatalf("invalid event id %q", value)
+			}
+			id = n
+		} else if data, ok := strings.CutPrefix(line, "data: "); ok {
+			events = append(events, sseEvent{ID: id, Data: data})
+		}
+	}
+	return events
+}
+
+func chunkReplies(chunks ...string) [][]*schema.Message {
+	reply := make([]*schema.Message, len(chunks))
+	for i, chunk := range chunks {
+		reply[i] = schema.AssistantMessage(chunk, nil)
+	}
+	return [][]*schema.Message{reply}
+}
+
+func TestStreamReconnect(t *testing.T) {
+	stores := map[string]func(t *testing.T) resumable.Store{
+		"memory": func(t *testing.T) resumable.Store { return resumable.NewMemoryStore(time.Hour) },
+		"redis": func(t *testing.T) resumable.Store {
+			mr := miniredis.RunT(t)
+			return resumable.NewRedisStoreWithClient(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Hour)
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newOpenAITestServer(t, &fakeChatModel{replies: chunkReplies("你好，", "我是", "助手。")})
+			s.streams.Close()
+			s.streams = newStore(t)
+
+			w := doUsageRequest(s, http.MethodPost, "/api/chat/stream", `{"user_input":"你好"}`, "13")
+			streamID := w.Header().Get("X-Stream-ID")
+			events := sseEventsWithID(t, w.Body.String())
+			if w.Code != http.StatusOK || streamID == "" || len(events) != 4 {
+				t.Fatalf("stream: %d %q %s", w.Code, streamID, w.Body.String())
+			}
+			for i, event := range events {
+				if event.ID != int64(i+1) {
+					t.Fatalf("expected sequential event ids, got %+v", events)
+				}
+			}
+			var final ChatResponse
+			decodeJSON(t, []byte(events[3].Data), &final)
+			if final.Status != "completed" || final.Content != "你好，我是助手。" || final.StreamID != streamID {
+				t.Fatalf("unexpected final event %+v", final)
+			}
+
+			// 断线后从 Last-Event-ID 之后继续读取
+			req, _ := http.NewRequest(http.MethodGet, "/api/chat/streams/"+streamID+"/events", nil)
+			req.Header.Set("Authorization", "Bearer openai-test-token")
+			req.Header.Set(UserIDHeader, "13")
+			req.Header.Set(TenantIDHeader, "3")
+			req.Header.Set("Last-Event-ID", "2")
+			w = httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			replayed := sseEventsWithID(t, w.Body.String())
+			if len(replayed) != 2 || replayed[0].ID != 3 || replayed[0].Data != events[2].Data || replayed[1].ID != 4 {
+				t.Fatalf("expected events 3 and 4 after reconnecting, got %+v", replayed)
+			}
+
+			w = doUsageRequest(s, http.MethodGet, "/api/chat/streams/"+streamID, "", "13")
+			var info resumable.Info
+			decodeJSON(t, w.Body.Bytes(), &info)
+			if info.Status != resumable.StatusCompleted || info.LastEventID != 4 {
+				t.Fatalf("unexpected stream info %+v", info)
+			}
+
+			// 已结束的生成不能再控制，其他用户的生成按不存在处理
+			if w := doUsageRequest(s, http.MethodPost, "/api/chat/streams/"+streamID+"/control", `{"action":"pause"}`, "13"); w.Code != http.StatusConflict {
+				t.Fatalf("expected 409 for a finished stream, got %d", w.Code)
+			}
+			if w := doUsageRequest(s, http.MethodPost, "/api/chat/control", `{"action":"stop"}`, "13"); w.Code != http.StatusConflict {
+				t.Fatalf("expected 409 for the latest finished stream, got %d", w.Code)
+			}
+			if w := doUsageRequest(s, http.MethodGet, "/api/chat/streams/"+streamID+"/events", "", "14"); w.Code != http.StatusNotFound {
+				t.Fatalf("expected 404 for another user's stream, got %d", w.Code)
+			}
+			if w := doUsageRequest(s, http.MethodGet, "/api/chat/streams/"+streamID+"/events?last_event_id=x", "", "13"); w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400 for an invalid event id, got %d", w.Code)
+			}
+		})
+	}
+}
+
+// gatedChatModel 输出第一个分块后等待 release 再输出其余分块，用于在生成过程中发送控制请求
+type gatedChatModel struct {
+	fakeChatModel
+	release chan struct{}
+}
+
+func (m *gatedChatModel) Stream(_ context.Context, input []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	reply := m.next(input)
+	sr, sw := schema.Pipe[*schema.Message](len(reply))
+	go func() {
+		defer sw.Close()
+		for i, msg := range reply {
+			if i == 1 {
+				<-m.release
+			}
+			sw.Send(msg, nil)
+		}
+	}()
+	return sr, nil
+}
+
+func (m *gatedChatModel) WithTools([]*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func TestChatWebSocketControl(t *testing.T) {
+	llm := &gatedChatModel{fakeChatModel: fakeChatModel{replies: chunkReplies("第一段", "第二段", "第三段")}, release: make(chan struct{})}
+	s := newOpenAITestServer(t, llm)
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/api/chat/ws", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Header.Set("Authorization", "Bearer openai-test-token")
+	config.Header.Set(UserIDHeader, "13")
+	config.Header.Set(TenantIDHeader, "3")
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	receive := func(wantType string) WSServerMessage {
+		t.Helper()
+		var msg WSServerMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			t.Fatalf("receive %s: %v", wantType, err)
+		}
+		if msg.Type != wantType {
+			t.Fatalf("expected a %s message, got %+v", wantType, msg)
+		}
+		return msg
+	}
+
+	websocket.JSON.Send(conn, WSClientMessage{Type: "unknown", RequestID: "r0"})
+	if msg := receive(wsMessageError); msg.RequestID != "r0" {
+		t.Fatalf("expected the request id in the error, got %+v", msg)
+	}
+
+	websocket.JSON.Send(conn, WSClientMessage{Type: wsMessageChat, RequestID: "r1", ChatRequest: ChatRequest{UserInput: "讲三段"}})
+	started := receive(wsMessageStarted)
+	if started.RequestID != "r1" || started.StreamID == "" {
+		t.Fatalf("unexpected started message %+v", started)
+	}
+	if chunk := receive(resumable.EventChunk); chunk.ID != 1 || !strings.Contains(string(chunk.Data), "第一段") {
+		t.Fatalf("unexpected first chunk %+v", chunk)
+	}
+
+	// 停止在生成读取到下一个分块后生效，等待超过控制状态的读取间隔再放行
+	websocket.JSON.Send(conn, WSClientMessage{Type: wsMessageControl, RequestID: "r2", StreamID: started.StreamID, Action: "stop"})
+	var ack WSServerMessage
+	for ack.Type != wsMessageAck {
+		ack = WSServerMessage{}
+		if err := websocket.JSON.Receive(conn, &ack); err != nil {
+			t.Fatalf("receive ack: %v", err)
+		}
+	}
+	if ack.RequestID != "r2" || !strings.Contains(string(ack.Data), `"status":"stopping"`) {
+		t.Fatalf("unexpected ack %+v", ack)
+	}
+	time.Sleep(2 * streamControlInterval)
+	close(llm.release)
+
+	var completed ChatResponse
+	for {
+		var msg WSServerMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+		if msg.Type == resumable.EventCompleted {
+			decodeJSON(t, msg.Data, &completed)
+			break
+		}
+	}
+	if completed.Content != "第一段第二段" {
+		t.Fatalf("expected the stream to stop before the third chunk, got %q", completed.Content)
+	}
+
+	// 生成的最终状态在最后一个事件之后写入
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := s.streams.Get(context.Background(), started.StreamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Status == resumable.StatusStopped {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the stream to be stopped, got %s", info.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 重新读取已结束的生成会从头发送所有事件
+	websocket.JSON.Send(conn, WSClientMessage{Type: wsMessageResume, StreamID: started.StreamID, LastEventID: 1})
+	var replayed []string
+	for {
+		var msg WSServerMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+		replayed = append(replayed, msg.Type)
+		if msg.Type == resumable.EventCompleted {
+			break
+		}
+	}
+	if strings.Join(replayed, ",") != "control,chunk,completed" {
+		t.Fatalf("unexpected replayed events %v", replayed)
+	}
+}
+
+func TestChatWebSocketTokenProtocolAndMessageRateLimit(t *testing.T) {
+	config.Config.JWT.Secret = "aichat-ws-secret"
+	config.Config.JWT.AccessTokenExpiry = 5
+	defer func() { config.Config.JWT.Secret = "" }()
+	// 升级请求和第一条生成请求各占一个额度
+	viper.Set("AICHAT_RATE_LIMIT_RATE", 0.001)
+	viper.Set("AICHAT_RATE_LIMIT_BURST", 2)
+	t.Cleanup(func() {
+		viper.Set("AICHAT_RATE_LIMIT_RATE", 0)
+		viper.Set("AICHAT_RATE_LIMIT_BURST", 0)
+	})
+
+	s := newOpenAITestServer(t, &fakeChatModel{replies: replies("你好")})
+	s.router = gin.New()
+	s.auth = AuthConfig{JWTSecret: "aichat-ws-secret"}
+	s.registerRoutes()
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/chat/ws"
+
+	// 没有认证头也没有令牌子协议时拒绝升级
+	noToken, _ := websocket.NewConfig(url, server.URL)
+	noToken.Protocol = []string{WebSocketProtocol}
+	if _, err := websocket.DialConfig(noToken); err == nil {
+		t.Fatal("expected the upgrade without a token to be rejected")
+	}
+
+	// 浏览器通过子协议传递访问令牌，服务端只回传 weave-chat
+	access, _ := utils.GenerateToken(21, 3)
+	cfg, _ := websocket.NewConfig(url, server.URL)
+	cfg.Protocol = []string{WebSocketProtocol, WebSocketTokenProtocolPrefix + access}
+	conn, err := websocket.DialConfig(cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if protocols := conn.Config().Protocol; len(protocols) != 1 || protocols[0] != WebSocketProtocol {
+		t.Fatalf("expected the server to select %s, got %v", WebSocketProtocol, protocols)
+	}
+
+	websocket.JSON.Send(conn, WSClientMessage{Type: wsMessageChat, RequestID: "r1", ChatRequest: ChatRequest{UserInput: "你好"}})
+	websocket.JSON.Send(conn, WSClientMessage{Type: wsMessageChat, RequestID: "r2", ChatRequest: ChatRequest{UserInput: "再来"}})
+	var started bool
+	for {
+		var msg WSServerMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+		if msg.Type == wsMessageStarted && msg.RequestID == "r1" {
+			started = true
+		}
+		if msg.Type == wsMessageError {
+			if msg.RequestID != "r2" || !strings.Contains(string(msg.Data), `"status":429`) {
+				t.Fatalf("expected the second chat message to be rate limited, got %+v %s", msg, msg.Data)
+			}
+			break
+		}
+	}
+	if !started {
+		t.Fatal("expected the first chat message to start a stream")
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const personSchema = `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer","minimum":0}},"required":["name","age"],"additionalProperties":false}`
+
+func replies(contents ...string) [][]*schema.Message {
+	out := make([][]*schema.Message, len(contents))
+	for i, content := range contents {
+		out[i] = []*schema.Message{schema.AssistantMessage(content, nil)}
+	}
+	return out
+}
+
+func TestChatStructuredOutputRepair(t *testing.T) {
+	llm := &fakeChatModel{replies: replies("```json\n{\"name\":\"张三\"}\n```", `{"name":"张三","age":30}`)}
+	s := newOpenAITestServer(t, llm)
+
+	w := doUsageRequest(s, http.MethodPost, "/api/chat", `{"user_input":"张三今年三十岁","response_schema":`+personSchema+`}`, "11")
+	if w.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", w.Code, w.Body.String())
+	}
+	var resp ChatResponse
+	decodeJSON(t, w.Body.Bytes(), &resp)
+	if string(resp.Data) != `{"name":"张三","age":30}` || resp.Content != string(resp.Data) {
+		t.Fatalf("expected the repaired JSON, got %+v", resp)
+	}
+
+	// 第一次请求带有模式要求，第二次请求带有校验错误
+	if len(llm.inputs) != 2 {
+		t.Fatalf("expected one repair call, got %d calls", len(llm.inputs))
+	}
+	first, repair := llm.inputs[0], llm.inputs[1]
+	if instructions := first[len(first)-2]; instructions.Role != schema.System || !strings.Contains(instructions.Content, `"required":["name","age"]`) {
+		t.Fatalf("expected schema instructions before the question, got %+v", instructions)
+	}
+	if last := repair[len(repair)-1]; last.Role != schema.User || !strings.Contains(last.Content, "$: 缺少必需的属性 age") {
+		t.Fatalf("expected a repair prompt with validation errors, got %+v", last)
+	}
+
+	// 回复保存为校验通过的 JSON
+	w = doUsageRequest(s, http.MethodGet, "/api/conversations/"+resp.ConversationID, "", "11")
+	var conv ConversationResponse
+	decodeJSON(t, w.Body.Bytes(), &conv)
+	if last := conv.Messages[len(conv.Messages)-1]; last.Content != `{"name":"张三","age":30}` {
+		t.Fatalf("expected the validated reply in history, got %q", last.Content)
+	}
+
+	// 修正次数用完后返回 422 和最后一次的校验错误
+	llm.replies = replies(`{"name":"李四","age":-1}`, `{"name":"李四","age":"十"}`, `{"name":"李四","age":1.5,"city":"北京"}`)
+	w = doUsageRequest(s, http.MethodPost, "/api/chat", `{"user_input":"李四呢","response_schema":`+personSchema+`}`, "11")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 after exhausting repairs, got %d %s", w.Code, w.Body.String())
+	}
+	var problem struct {
+		Details []struct{ Path, Message string } `json:"details"`
+	}
+	decodeJSON(t, w.Body.Bytes(), &problem)
+	if len(problem.Details) != 2 || problem.Details[0].Path != "$.age" || problem.Details[1].Path != "$.city" {
+		t.Fatalf("unexpected validation errors %s", w.Body.String())
+	}
+
+	if w := doUsageRequest(s, http.MethodPost, "/api/chat", `{"user_input":"你好","response_schema":{"type":"objekt"}}`, "11"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid schema, got %d", w.Code)
+	}
+	if w := doUsageRequest(s, http.MethodPost, "/api/chat", `{"user_input":"你好","response_schema":{"type":"object","patternProperties":{"^x":{"type":"string"}}}}`, "11"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unsupported keyword, got %d", w.Code)
+	}
+}
+
+func TestStructuredOutputStreams(t *testing.T) {
+	llm := &fakeChatModel{replies: replies(`{"name":"王五"`, `{"name":"王五","age":41}`, `好的，结果是 {"name":"赵六","age":52}。`)}
+	s := newOpenAITestServer(t, llm)
+
+	// 流式接口只发送校验通过的 JSON，不发送需要修正的输出
+	w := doUsageRequest(s, http.MethodPost, "/v1/chat/completions", `{
+		"stream": true,
+		"messages": [{"role": "user", "content": "王五四十一岁"}],
+		"response_format": {"type": "json_schema", "json_schema": {"name": "person", "schema": `+personSchema+`}}
+	}`, "11")
+	if w.Code != http.StatusOK {
+		t.Fatalf("stream: %d %s", w.Code, w.Body.String())
+	}
+	var content strings.Builder
+	for _, event := range sseEvents(t, w.Body.String()) {
+		if event == "[DONE]" {
+			continue
+		}
+		var chunk OpenAIChatCompletion
+		decodeJSON(t, []byte(event), &chunk)
+		for _, choice := range chunk.Choices {
+			if choice.Delta != nil && choice.Delta.Content != nil {
+				content.WriteString(*choice.Delta.Content)
+			}
+		}
+	}
+	if content.String() != `{"name":"王五","age":41}` {
+		t.Fatalf("expected only the validated JSON in the stream, got %q", content.String())
+	}
+
+	w = doUsageRequest(s, http.MethodPost, "/api/chat/stream", `{"user_input":"赵六呢","response_schema":`+personSchema+`}`, "11")
+	events := sseEvents(t, w.Body.String())
+	var final ChatResponse
+	decodeJSON(t, []byte(events[len(events)-1]), &final)
+	if final.Status != "completed" || string(final.Data) != `{"name":"赵六","age":52}` {
+		t.Fatalf("expected the extracted JSON in the final event, got %+v", final)
+	}
+	if len(llm.inputs) != 3 {
+		t.Fatalf("expected no repair for a reply wrapped in prose, got %d calls", len(llm.inputs))
+	}
+
+	if w := doUsageRequest(s, http.MethodPost, "/v1/chat/completions", `{"messages":[{"role":"user","content":"hi"}],"response_format":{"type":"json_schema","json_schema":{"name":"x"}}}`, "11"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a schema, got %d", w.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"weave/services/aichat/internal/model"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/viper"
+)
+
+func TestLLMSummaryFoldsAfterSaveAndRecordsUsage(t *testing.T) {
+	viper.Set("AICHAT_SUMMARY_GENERATOR", "llm")
+	t.Cleanup(func() { viper.Set("AICHAT_SUMMARY_GENERATOR", "") })
+	llm := &fakeChatModel{replies: [][]*schema.Message{
+		{withUsage(schema.AssistantMessage("一", nil), 10, 1)},
+		{withUsage(schema.AssistantMessage("二", nil), 10, 1)},
+		{withUsage(schema.AssistantMessage("三", nil), 10, 1)},
+		{withUsage(schema.AssistantMessage("四", nil), 10, 1)},
+		{withUsage(schema.AssistantMessage("五", nil), 10, 1)},
+		{withUsage(schema.AssistantMessage(`{"summary":"用户在数数。","entities":["数字"],"decisions":[]}`, nil), 200, 20)},
+	}}
+	s := newOpenAITestServer(t, llm)
+
+	var conversationID string
+	for i := 1; i <= 5; i++ {
+		body := fmt.Sprintf(`{"user_input":"第%d轮","conversation_id":%q}`, i, conversationID)
+		w := doUsageRequest(s, http.MethodPost, "/api/chat", body, "18")
+		if w.Code != http.StatusOK {
+			t.Fatalf("chat: %d %s", w.Code, w.Body.String())
+		}
+		var resp ChatResponse
+		decodeJSON(t, w.Body.Bytes(), &resp)
+		conversationID = resp.ConversationID
+	}
+
+	// 摘要在保存对话之后于后台折叠，单独保存
+	var conv ConversationResponse
+	deadline := time.Now().Add(5 * time.Second)
+	for conv.Summary == "" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the folded summary to be saved, got %+v", conv)
+		}
+		time.Sleep(20 * time.Millisecond)
+		w := doUsageRequest(s, http.MethodGet, "/api/conversations/"+conversationID, "", "18")
+		if w.Code != http.StatusOK {
+			t.Fatalf("get conversation: %d %s", w.Code, w.Body.String())
+		}
+		decodeJSON(t, w.Body.Bytes(), &conv)
+	}
+	if conv.Summary != "用户在数数。" || conv.Metadata[model.MetadataSummarizedMessages] != "10" || len(conv.Messages) != 10 {
+		t.Fatalf("unexpected summary %q %+v with %d messages", conv.Summary, conv.Metadata, len(conv.Messages))
+	}
+
+	// 摘要调用的用量计入用户
+	resp := decodeUsage(t, doUsageRequest(s, http.MethodGet, "/api/chat/usage", "", "18"))
+	if resp.Requests != 6 || resp.PromptTokens != 250 || resp.CompletionTokens != 25 {
+		t.Fatalf("expected the summary call in the user's usage, got %+v", resp.Totals)
+	}
+}
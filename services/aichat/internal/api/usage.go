@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"weave/pkg"
+	"weave/services/aichat/internal/usage"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 用量查询范围
+const (
+	usageScopeUser   = "user"
+	usageScopeTenant = "tenant"
+)
+
+// UsageQuery 用量查询参数，时间为 RFC 3339 格式，不指定时不限制
+type UsageQuery struct {
+	Scope string    `form:"scope,default=user" binding:"oneof=user tenant"`
+	From  time.Time `form:"from"`
+	To    time.Time `form:"to" binding:"omitempty,gtfield=From"`
+}
+
+// UsageResponse 用量查询响应
+type UsageResponse struct {
+	Scope string     `json:"scope"`
+	From  *time.Time `json:"from,omitempty"`
+	To    *time.Time `json:"to,omitempty"`
+	usage.Summary
+}
+
+// handleGetUsage 查询令牌用量和费用：默认统计当前用户，scope=tenant 统计当前租户（仅限服务令牌）
+func (s *APIServer) handleGetUsage(c *gin.Context) {
+	var query UsageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RespondError(c, pkg.NewBindingError(err))
+		return
+	}
+	principal := principalFrom(c)
+
+	filter := usage.Filter{From: query.From, To: query.To}
+	if query.Scope == usageScopeTenant {
+		if !principal.Service {
+			pkg.RespondError(c, pkg.NewForbidden("只有服务令牌可以查询租户用量", nil))
+			return
+		}
+		filter.TenantID = strconv.FormatUint(uint64(principal.TenantID), 10)
+	} else {
+		filter.UserID = principal.Key()
+	}
+
+	summary, err := s.chatService.Usage(c.Request.Context(), filter)
+	if err != nil {
+		s.logger.Error("查询用量失败", zap.Error(err), zap.String("user_id", principal.Key()))
+		pkg.RespondError(c, pkg.NewInternalError("查询用量失败", err))
+		return
+	}
+
+	resp := UsageResponse{Scope: query.Scope, Summary: *summary}
+	if !query.From.IsZero() {
+		resp.From = &query.From
+	}
+	if !query.To.IsZero() {
+		resp.To = &query.To
+	}
+	c.JSON(http.StatusOK, resp)
+}
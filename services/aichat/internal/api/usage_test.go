@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"weave/config"
+	"weave/services/aichat/internal/model"
+	aichatpkg "weave/services/aichat/pkg"
+	"weave/utils"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// doUsageRequest 以服务令牌代表指定用户发送请求
+func doUsageRequest(s *APIServer, method, path, body, userID string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer openai-test-token")
+	req.Header.Set(UserIDHeader, userID)
+	req.Header.Set(TenantIDHeader, "3")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func decodeUsage(t *testing.T, w *httptest.ResponseRecorder) UsageResponse {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var resp UsageResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode usage: %v", err)
+	}
+	return resp
+}
+
+func TestChatUsageIsRecordedPerUserAndTenant(t *testing.T) {
+	llm := &fakeChatModel{replies: [][]*schema.Message{
+		{schema.AssistantMessage("你好，", nil), withUsage(schema.AssistantMessage("我是 Weave。", nil), 120, 30)},
+		{schema.AssistantMessage("hello there", nil)},
+		{withUsage(schema.AssistantMessage("ok", nil), 40, 10)},
+		{withUsage(schema.AssistantMessage("other user", nil), 5, 5)},
+	}}
+	s := newOpenAITestServer(t, llm)
+
+	if w := doUsageRequest(s, http.MethodPost, "/api/chat", `{"user_input":"你好"}`, "7"); w.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", w.Code, w.Body.String())
+	}
+	if w := doUsageRequest(s, http.MethodPost, "/api/chat", `{"user_input":"hi"}`, "7"); w.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", w.Code, w.Body.String())
+	}
+	if w := doUsageRequest(s, http.MethodPost, "/v1/chat/completions", `{"messages":[{"role":"user","content":"ping"}]}`, "7"); w.Code != http.StatusOK {
+		t.Fatalf("completion: %d %s", w.Code, w.Body.String())
+	}
+	if w := doUsageRequest(s, http.MethodPost, "/api/chat", `{"user_input":"hey"}`, "8"); w.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", w.Code, w.Body.String())
+	}
+
+	// 第二次请求模型未返回用量，按分词器估算
+	resp := decodeUsage(t, doUsageRequest(s, http.MethodGet, "/api/chat/usage", "", "7"))
+	if resp.Scope != "user" || resp.Requests != 3 || len(resp.Models) != 1 || resp.Models[0].Model != "weave-test" {
+		t.Fatalf("unexpected user usage %+v", resp)
+	}
+	if resp.PromptTokens <= 160 || resp.CompletionTokens <= 40 || resp.TotalTokens != resp.PromptTokens+resp.CompletionTokens {
+		t.Fatalf("expected reported usage plus an estimate, got %+v", resp.Totals)
+	}
+
+	other := decodeUsage(t, doUsageRequest(s, http.MethodGet, "/api/chat/usage", "", "8"))
+	if other.Requests != 1 || other.PromptTokens != 5 || other.CompletionTokens != 5 {
+		t.Fatalf("unexpected usage for user 8 %+v", other.Totals)
+	}
+
+	tenant := decodeUsage(t, doUsageRequest(s, http.MethodGet, "/api/chat/usage?scope=tenant", "", "8"))
+	if tenant.Scope != "tenant" || tenant.Requests != 4 || tenant.PromptTokens != resp.PromptTokens+5 {
+		t.Fatalf("unexpected tenant usage %+v", tenant.Totals)
+	}
+
+	future := decodeUsage(t, doUsageRequest(s, http.MethodGet, "/api/chat/usage?from=2999-01-01T00:00:00Z", "", "7"))
+	if future.Requests != 0 || future.From == nil || len(future.Models) != 0 {
+		t.Fatalf("expected no usage in the future window, got %+v", future)
+	}
+	w := doUsageRequest(s, http.MethodGet, "/api/chat/usage?from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z", "", "7")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for to before from, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTenantUsageRequiresServiceToken(t *testing.T) {
+	config.Config.JWT.Secret = "aichat-usage-secret"
+	config.Config.JWT.AccessTokenExpiry = 5
+	defer func() { config.Config.JWT.Secret = "" }()
+
+	s := newOpenAITestServer(t, &fakeChatModel{})
+	s.router = gin.New()
+	s.auth = AuthConfig{JWTSecret: "aichat-usage-secret", ServiceToken: "openai-test-token"}
+	s.registerRoutes()
+
+	access, _ := utils.GenerateToken(7, 3)
+	for path, want := range map[string]int{
+		"/api/chat/usage":              http.StatusOK,
+		"/api/chat/usage?scope=tenant": http.StatusForbidden,
+		"/api/chat/usage?scope=global": http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+access)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("%s: expected %d, got %d %s", path, want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestChatPromptFitsTokenBudget(t *testing.T) {
+	filler := strings.Repeat("测", 1000)
+	tokenizer := model.TokenizerFor("weave-test")
+	question := "第4轮问题" + filler
+	fixed, err := aichatpkg.FormatMessage(context.Background(), "PaiChat", "积极、温暖且专业", "", question)
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	// 预算可以容纳固定部分和两条半历史消息
+	messageTokens := tokenizer.CountMessages([]*schema.Message{schema.UserMessage(question)})
+	viper.Set("AICHAT_PROMPT_TOKEN_BUDGET", tokenizer.CountMessages(fixed)+messageTokens*5/2)
+	t.Cleanup(func() { viper.Set("AICHAT_PROMPT_TOKEN_BUDGET", 8192) })
+
+	var replies [][]*schema.Message
+	for i := 1; i <= 4; i++ {
+		replies = append(replies, []*schema.Message{schema.AssistantMessage(fmt.Sprintf("第%d轮回答%s", i, filler), nil)})
+	}
+	llm := &fakeChatModel{replies: replies}
+	s := newOpenAITestServer(t, llm)
+
+	for i := 1; i <= 4; i++ {
+		body := fmt.Sprintf(`{"user_input":"第%d轮问题%s"}`, i, filler)
+		if w := doUsageRequest(s, http.MethodPost, "/api/chat", body, "7"); w.Code != http.StatusOK {
+			t.Fatalf("turn %d: %d %s", i, w.Code, w.Body.String())
+		}
+	}
+
+	last := llm.inputs[len(llm.inputs)-1]
+	history := last[0].Content
+	if !strings.Contains(history, "第3轮问题") || !strings.Contains(history, "第3轮回答") {
+		t.Fatalf("expected the most recent turn to fit the budget")
+	}
+	if strings.Contains(history, "第1轮问题") || strings.Contains(history, "第2轮问题") {
+		t.Fatalf("expected older turns to be dropped to fit the budget")
+	}
+	if got := tokenizer.CountMessages(last); got > viper.GetInt("AICHAT_PROMPT_TOKEN_BUDGET") {
+		t.Fatalf("prompt of %d tokens exceeds the budget", got)
+	}
+}
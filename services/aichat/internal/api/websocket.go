@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+
+	"weave/middleware"
+	"weave/pkg"
+	"weave/pkg/i18n"
+	"weave/services/aichat/internal/resumable"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// maxConnectionStreams 每个 WebSocket 连接同时读取的生成数上限
+const maxConnectionStreams = 4
+
+// WebSocket 消息类型，服务端发送的生成事件使用事件类型（chunk、tool_call、control、completed、error）
+const (
+	wsMessageChat    = "chat"    // 客户端发起生成
+	wsMessageControl = "control" // 客户端暂停、恢复或停止生成
+	wsMessageResume  = "resume"  // 客户端从指定事件之后继续读取生成
+	wsMessageStarted = "started" // 服务端确认生成已创建
+	wsMessageAck     = "ack"     // 服务端确认控制操作已执行
+	wsMessageError   = "error"   // 服务端返回请求错误
+)
+
+// WSClientMessage WebSocket 客户端消息，type 为 chat 时其余字段与 ChatRequest 相同
+type WSClientMessage struct {
+	Type        string `json:"type"`
+	RequestID   string `json:"request_id,omitempty"` // 客户端指定的请求 ID，服务端在对应的 started、ack 和 error 消息中原样返回
+	StreamID    string `json:"stream_id,omitempty"`
+	Action      string `json:"action,omitempty"`
+	LastEventID int64  `json:"last_event_id,omitempty"`
+	ChatRequest
+}
+
+// WSServerMessage WebSocket 服务端消息，生成事件的 id 和 data 与 SSE 事件相同
+type WSServerMessage struct {
+	Type      string          `json:"type"`
+	RequestID string          `json:"request_id,omitempty"`
+	StreamID  string          `json:"stream_id,omitempty"`
+	ID        int64           `json:"id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// wsSession 一个 WebSocket 连接，连接上的多个生成共用同一个连接写入
+type wsSession struct {
+	server  *APIServer
+	conn    *websocket.Conn
+	request streamRequest // 连接的认证信息和请求信息，发起生成时复用
+	// 按聊天限流策略检查一条生成请求，与 HTTP 聊天接口共用用户的限流额度
+	rateLimit func(ctx context.Context) *pkg.AppError
+
+	ctx     context.Context
+	writeMu sync.Mutex
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	active  map[string]bool // 正在读取的生成
+}
+
+// handleChatWebSocket 处理 WebSocket 连接，认证在升级请求上完成；
+// 升级请求和连接上的每条生成请求都按聊天限流策略限流
+func (s *APIServer) handleChatWebSocket(c *gin.Context) {
+	request := newStreamRequest(c, ChatRequest{}, "", nil)
+	rateLimit := middleware.RateLimitCheck(c, chatRateLimitPolicy())
+	server := websocket.Server{Handshake: selectWebSocketProtocol, Handler: func(conn *websocket.Conn) {
+		ctx, cancel := context.WithCancel(context.Background())
+		session := &wsSession{server: s, conn: conn, request: request, rateLimit: rateLimit, ctx: ctx, active: make(map[string]bool)}
+		session.serve()
+		cancel()
+		session.wg.Wait()
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// selectWebSocketProtocol 客户端提供了 WebSocketProtocol 时选用它，携带令牌的子协议不回传给客户端
+func selectWebSocketProtocol(config *websocket.Config, _ *http.Request) error {
+	offered := config.Protocol
+	config.Protocol = nil
+	if slices.Contains(offered, WebSocketProtocol) {
+		config.Protocol = []string{WebSocketProtocol}
+	}
+	return nil
+}
+
+// serve 读取客户端消息直到连接关闭
+func (ws *wsSession) serve() {
+	for {
+		var msg WSClientMessage
+		if err := websocket.JSON.Receive(ws.conn, &msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case wsMessageChat:
+			ws.handleChat(msg)
+		case wsMessageControl:
+			ws.handleControl(msg)
+		case wsMessageResume:
+			ws.handleResume(msg)
+		default:
+			ws.sendError(msg, pkg.NewBadRequest("无效的消息类型", nil))
+		}
+	}
+}
+
+func (ws *wsSession) handleChat(msg WSClientMessage) {
+	s := ws.server
+	if appErr := ws.rateLimit(ws.ctx); appErr != nil {
+		ws.sendError(msg, appErr)
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&msg.ChatRequest); err != nil {
+		ws.sendError(msg, pkg.NewBindingError(err))
+		return
+	}
+	route, appErr := s.newRoute(msg.Model, msg.Preference, msg.ResponseSchema)
+	if appErr != nil {
+		ws.sendError(msg, appErr)
+		return
+	}
+	conv, err := s.chatService.ResolveConversation(ws.ctx, ws.request.sessionKey, msg.ConversationID)
+	if err != nil {
+		ws.sendError(msg, conversationError(err, "获取对话失败"))
+		return
+	}
+	// 消息按顺序处理，检查名额和占用名额之间不会有其他生成占用名额
+	if ws.full() {
+		ws.sendError(msg, pkg.NewTooManyRequests("同时进行的生成过多", nil))
+		return
+	}
+
+	req := ws.request
+	req.chat, req.conversationID, req.route = msg.ChatRequest, conv.ID, route
+	info, err := s.startStream(ws.ctx, req)
+	if err != nil {
+		s.logger.Error("创建生成失败", zap.Error(err), zap.String("user_id", req.sessionKey))
+		ws.sendError(msg, pkg.NewInternalError("创建生成失败", err))
+		return
+	}
+	data, _ := json.Marshal(info)
+	ws.send(WSServerMessage{Type: wsMessageStarted, RequestID: msg.RequestID, StreamID: info.ID, Data: data})
+	ws.reserve(info.ID)
+	ws.follow(info.ID, 0)
+}
+
+func (ws *wsSession) handleControl(msg WSClientMessage) {
+	s := ws.server
+	info, appErr := s.ownedStream(ws.ctx, ws.request.sessionKey, msg.StreamID)
+	if appErr != nil {
+		ws.sendError(msg, appErr)
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&StreamControlRequest{Action: msg.Action}); err != nil {
+		ws.sendError(msg, pkg.NewBindingError(err))
+		return
+	}
+	updated, err := s.controlStream(ws.ctx, info, msg.Action)
+	if err != nil {
+		ws.sendError(msg, streamError(err, "更新生成状态失败"))
+		return
+	}
+	data, _ := json.Marshal(updated)
+	ws.send(WSServerMessage{Type: wsMessageAck, RequestID: msg.RequestID, StreamID: info.ID, Data: data})
+}
+
+func (ws *wsSession) handleResume(msg WSClientMessage) {
+	info, appErr := ws.server.ownedStream(ws.ctx, ws.request.sessionKey, msg.StreamID)
+	if appErr != nil {
+		ws.sendError(msg, appErr)
+		return
+	}
+	if ws.full() {
+		ws.sendError(msg, pkg.NewTooManyRequests("同时进行的生成过多", nil))
+		return
+	}
+	// 已在读取的生成不重复发送
+	if !ws.reserve(info.ID) {
+		ws.sendError(msg, pkg.NewConflict("该生成已在读取", nil))
+		return
+	}
+	ws.follow(info.ID, max(msg.LastEventID, 0))
+}
+
+// full 读取的生成数是否已达上限
+func (ws *wsSession) full() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return len(ws.active) >= maxConnectionStreams
+}
+
+// reserve 占用一个读取名额，已达上限或已在读取该生成时返回 false
+func (ws *wsSession) reserve(id string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if len(ws.active) >= maxConnectionStreams || ws.active[id] {
+		return false
+	}
+	ws.active[id] = true
+	return true
+}
+
+func (ws *wsSession) release(id string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	delete(ws.active, id)
+}
+
+// follow 在后台把生成中 ID 大于 after 的事件发送给客户端，连接关闭后停止读取，生成本身不受影响
+func (ws *wsSession) follow(id string, after int64) {
+	ws.wg.Add(1)
+	go func() {
+		defer ws.wg.Done()
+		defer ws.release(id)
+		err := ws.server.followStream(ws.ctx, id, after, func(event resumable.Event) error {
+			return ws.send(WSServerMessage{Type: event.Type, StreamID: id, ID: event.ID, Data: event.Data})
+		}, nil)
+		if err != nil && ws.ctx.Err() == nil {
+			ws.server.logger.Warn("发送生成事件失败", zap.Error(err), zap.String("stream_id", id))
+			ws.sendError(WSClientMessage{StreamID: id}, streamError(err, "读取生成事件失败"))
+		}
+	}()
+}
+
+// send 发送一条消息，多个生成的事件通过互斥锁串行写入
+func (ws *wsSession) send(msg WSServerMessage) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	return websocket.JSON.Send(ws.conn, msg)
+}
+
+// sendError 以 problem 格式发送请求错误
+func (ws *wsSession) sendError(msg WSClientMessage, appErr *pkg.AppError) {
+	appErr = appErr.WithRequestID(ws.request.requestID).WithPath(ws.request.path)
+	data, _ := json.Marshal(appErr.Problem(i18n.Negotiate(ws.request.language)))
+	ws.send(WSServerMessage{Type: wsMessageError, RequestID: msg.RequestID, StreamID: msg.StreamID, Data: data})
+}
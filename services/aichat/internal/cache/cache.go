@@ -34,6 +34,9 @@ type Cache interface {
 	// LoadUserConversations 加载用户的所有对话
 	LoadUserConversations(ctx context.Context, userID string) ([]interface{}, error)
 
+	// DeleteConversation 删除对话及其与用户的关联，对话不存在时不返回错误
+	DeleteConversation(ctx context.Context, conversationID, userID string) error
+
 	// Close 关闭缓存连接
 	Close() error
 }
@@ -8,8 +8,6 @@ import (
 	"fmt"
 	"time"
 
-	"weave/middleware"
-
 	"github.com/redis/go-redis/v9"
 )
 
@@ -27,21 +25,14 @@ type EmbeddingCache interface {
 type RedisEmbeddingCache struct {
 	client     *redis.Client
 	expiration time.Duration
-	retryer    *middleware.Retryer
 }
 
 // NewRedisEmbeddingCache 创建Redis嵌入缓存
+// 重试和熔断由客户端上注册的Redis依赖钩子负责
 func NewRedisEmbeddingCache(ctx context.Context, client *redis.Client) *RedisEmbeddingCache {
-	// 重试器配置
-	retryConfig := middleware.DefaultRetryConfig()
-	retryConfig.MaxRetries = 3
-	retryConfig.InitialDelay = 50 * time.Millisecond
-	retryConfig.MaxDelay = 2 * time.Second
-
 	return &RedisEmbeddingCache{
 		client:     client,
 		expiration: 24 * time.Hour, // 嵌入缓存24小时
-		retryer:    middleware.NewRetryer(retryConfig),
 	}
 }
 
@@ -57,13 +48,7 @@ func getEmbeddingKey(text string) string {
 func (c *RedisEmbeddingCache) Get(ctx context.Context, text string) ([][]float64, error) {
 	key := getEmbeddingKey(text)
 
-	var data []byte
-	err := c.retryer.Do(ctx, func() error {
-		var err error
-		data, err = c.client.Get(ctx, key).Bytes()
-		return err
-	})
-
+	data, err := c.client.Get(ctx, key).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil // 缓存未命中
@@ -89,10 +74,7 @@ func (c *RedisEmbeddingCache) Set(ctx context.Context, text string, embedding []
 		return fmt.Errorf("failed to marshal embedding: %w", err)
 	}
 
-	err = c.retryer.Do(ctx, func() error {
-		return c.client.Set(ctx, key, data, c.expiration).Err()
-	})
-
+	err = c.client.Set(ctx, key, data, c.expiration).Err()
 	if err != nil {
 		return fmt.Errorf("failed to set embedding in redis: %w", err)
 	}
@@ -205,6 +205,33 @@ func (mc *InMemoryCache) LoadUserConversations(ctx context.Context, userID strin
 	return conversations, nil
 }
 
+// DeleteConversation 删除结构化对话及其与用户的关联
+func (mc *InMemoryCache) DeleteConversation(ctx context.Context, conversationID, userID string) error {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	delete(mc.conversations, conversationID)
+	convIDs, exists := mc.userConversations[userID]
+	if !exists {
+		return nil
+	}
+	remaining := convIDs[:0]
+	for _, id := range convIDs {
+		if id != conversationID {
+			remaining = append(remaining, id)
+		}
+	}
+	if len(remaining) == 0 {
+		// 用户没有剩余对话时一并清理访问记录，避免占用用户数配额
+		delete(mc.userConversations, userID)
+		delete(mc.userAccess, userID)
+		delete(mc.userExpiry, userID)
+		return nil
+	}
+	mc.userConversations[userID] = remaining
+	return nil
+}
+
 // Close 关闭内存缓存（清理资源）
 func (mc *InMemoryCache) Close() error {
 	// 停止定期清理
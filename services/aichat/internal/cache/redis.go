@@ -26,8 +26,9 @@ import (
 	"strings"
 	"time"
 
-	"weave/middleware"
+	weaveconfig "weave/config"
 	"weave/pkg"
+	"weave/pkg/resilience"
 
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
@@ -37,11 +38,10 @@ import (
 type RedisClient struct {
 	client        *redis.Client
 	maxMessages   int
-	maxMemoryMB   int                 // 最大内存使用限制（MB）
-	cleanupFreq   time.Duration       // 定期清理频率
-	cleanupTicker *time.Ticker        // 定期清理定时器
-	stopChan      chan struct{}       // 停止定期清理的通道
-	retryer       *middleware.Retryer // 重试器
+	maxMemoryMB   int           // 最大内存使用限制（MB）
+	cleanupFreq   time.Duration // 定期清理频率
+	cleanupTicker *time.Ticker  // 定期清理定时器
+	stopChan      chan struct{} // 停止定期清理的通道
 }
 
 // NewRedisClient 创建Redis客户端实例
@@ -61,12 +61,14 @@ func NewRedisClientWithConfig(ctx context.Context, config *CacheConfig) (*RedisC
 	redisPassword := config.RedisPassword
 	redisDB := config.RedisDB
 
-	// 创建Redis客户端
+	// 创建Redis客户端，命令通过Redis依赖的重试、熔断和并发隔离执行
 	client := redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: redisPassword,
-		DB:       redisDB,
+		Addr:       redisAddr,
+		Password:   redisPassword,
+		DB:         redisDB,
+		MaxRetries: -1, // 由依赖策略统一重试
 	})
+	client.AddHook(resilience.NewRedisHook(weaveconfig.DependencyRedis))
 
 	// 测试连接
 	_, err := client.Ping(ctx).Result()
@@ -79,12 +81,6 @@ func NewRedisClientWithConfig(ctx context.Context, config *CacheConfig) (*RedisC
 	maxMemoryMB := 100             // 默认最大内存使用限制为100MB
 	cleanupFreq := 5 * time.Minute // 每5分钟检查一次内存使用
 
-	// 重试器配置
-	retryConfig := middleware.DefaultRetryConfig()
-	retryConfig.MaxRetries = 3
-	retryConfig.InitialDelay = 50 * time.Millisecond
-	retryConfig.MaxDelay = 2 * time.Second
-
 	pkg.Info("Redis connection established", zap.String("address", redisAddr))
 	cache := &RedisClient{
 		client:      client,
@@ -92,7 +88,6 @@ func NewRedisClientWithConfig(ctx context.Context, config *CacheConfig) (*RedisC
 		maxMemoryMB: maxMemoryMB,
 		cleanupFreq: cleanupFreq,
 		stopChan:    make(chan struct{}),
-		retryer:     middleware.NewRetryer(retryConfig),
 	}
 
 	// 启动定期内存检查
@@ -135,34 +130,20 @@ func (rc *RedisClient) SaveConversation(ctx context.Context, conversation interf
 	userID := userIDField.String()
 
 	// 保存对话
-	err = rc.retryer.Do(ctx, func() error {
-		return rc.client.Set(ctx, GetConversationKey(convID), data, 7*24*time.Hour).Err()
-	})
+	err = rc.client.Set(ctx, GetConversationKey(convID), data, 7*24*time.Hour).Err()
 	if err != nil {
 		return fmt.Errorf("failed to save conversation to redis: %w", err)
 	}
 
 	// 关联用户和对话
-	err = rc.retryer.Do(ctx, func() error {
-		// 检查对话是否已存在
-		exists, retryErr := rc.client.SIsMember(ctx, GetUserConversationsKey(userID), convID).Result()
-		if retryErr != nil {
-			return retryErr
-		}
-		// 如果不存在，添加到集合
-		if !exists {
-			return rc.client.SAdd(ctx, GetUserConversationsKey(userID), convID).Err()
-		}
-		return nil
-	})
+	// SADD本身是幂等的，已存在的成员不会重复添加
+	err = rc.client.SAdd(ctx, GetUserConversationsKey(userID), convID).Err()
 	if err != nil {
 		return fmt.Errorf("failed to associate conversation with user: %w", err)
 	}
 
 	// 设置用户对话关联的过期时间
-	err = rc.retryer.Do(ctx, func() error {
-		return rc.client.Expire(ctx, GetUserConversationsKey(userID), 7*24*time.Hour).Err()
-	})
+	err = rc.client.Expire(ctx, GetUserConversationsKey(userID), 7*24*time.Hour).Err()
 	if err != nil {
 		return fmt.Errorf("failed to set expiry for user conversations: %w", err)
 	}
@@ -172,12 +153,7 @@ func (rc *RedisClient) SaveConversation(ctx context.Context, conversation interf
 
 // LoadConversation 从Redis加载结构化对话
 func (rc *RedisClient) LoadConversation(ctx context.Context, conversationID string) (interface{}, error) {
-	var data []byte
-	err := rc.retryer.Do(ctx, func() error {
-		var err error
-		data, err = rc.client.Get(ctx, GetConversationKey(conversationID)).Bytes()
-		return err
-	})
+	data, err := rc.client.Get(ctx, GetConversationKey(conversationID)).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, fmt.Errorf("conversation not found")
@@ -198,12 +174,7 @@ func (rc *RedisClient) LoadConversation(ctx context.Context, conversationID stri
 // LoadUserConversations 从Redis加载用户的所有结构化对话
 func (rc *RedisClient) LoadUserConversations(ctx context.Context, userID string) ([]interface{}, error) {
 	// 获取用户的所有对话ID
-	var convIDs []string
-	err := rc.retryer.Do(ctx, func() error {
-		var err error
-		convIDs, err = rc.client.SMembers(ctx, GetUserConversationsKey(userID)).Result()
-		return err
-	})
+	convIDs, err := rc.client.SMembers(ctx, GetUserConversationsKey(userID)).Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user conversations: %w", err)
 	}
@@ -220,6 +191,17 @@ func (rc *RedisClient) LoadUserConversations(ctx context.Context, userID string)
 	return conversations, nil
 }
 
+// DeleteConversation 从Redis删除结构化对话及其与用户的关联
+func (rc *RedisClient) DeleteConversation(ctx context.Context, conversationID, userID string) error {
+	if err := rc.client.Del(ctx, GetConversationKey(conversationID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete conversation from redis: %w", err)
+	}
+	if err := rc.client.SRem(ctx, GetUserConversationsKey(userID), conversationID).Err(); err != nil {
+		return fmt.Errorf("failed to disassociate conversation from user: %w", err)
+	}
+	return nil
+}
+
 // startMemoryMonitor 启动Redis内存使用监控
 func (rc *RedisClient) startMemoryMonitor() {
 	rc.cleanupTicker = time.NewTicker(rc.cleanupFreq)
@@ -240,12 +222,7 @@ func (rc *RedisClient) checkMemoryUsage() {
 	ctx := context.Background()
 
 	// 获取Redis内存使用情况
-	var info string
-	err := rc.retryer.Do(ctx, func() error {
-		var err error
-		info, err = rc.client.Info(ctx, "memory").Result()
-		return err
-	})
+	info, err := rc.client.Info(ctx, "memory").Result()
 	if err != nil {
 		pkg.Warn("Failed to get Redis memory info", zap.Error(err))
 		return
@@ -282,12 +259,7 @@ func (rc *RedisClient) checkMemoryUsage() {
 // cleanupOldConversations 清理最旧对话
 func (rc *RedisClient) cleanupOldConversations(ctx context.Context) {
 	// 获取所有结构化对话键
-	var keys []string
-	err := rc.retryer.Do(ctx, func() error {
-		var err error
-		keys, err = rc.client.Keys(ctx, "chat:conversation:*").Result()
-		return err
-	})
+	keys, err := rc.client.Keys(ctx, "chat:conversation:*").Result()
 	if err != nil {
 		pkg.Warn("Failed to get conversation keys", zap.Error(err))
 		return
@@ -307,12 +279,7 @@ func (rc *RedisClient) cleanupOldConversations(ctx context.Context) {
 	keyTimes := make([]keyWithTime, 0, len(keys))
 
 	for _, key := range keys {
-		var ttl time.Duration
-		if err := rc.retryer.Do(ctx, func() error {
-			var err error
-			ttl, err = rc.client.TTL(ctx, key).Result()
-			return err
-		}); err == nil {
+		if ttl, err := rc.client.TTL(ctx, key).Result(); err == nil {
 			// 计算过期时间
 			expiry := time.Now().Add(ttl)
 			keyTimes = append(keyTimes, keyWithTime{key: key, time: expiry})
@@ -331,9 +298,7 @@ func (rc *RedisClient) cleanupOldConversations(ctx context.Context) {
 	}
 
 	for i := 0; i < cleanupCount && i < len(keyTimes); i++ {
-		if err := rc.retryer.Do(ctx, func() error {
-			return rc.client.Del(ctx, keyTimes[i].key).Err()
-		}); err != nil {
+		if err := rc.client.Del(ctx, keyTimes[i].key).Err(); err != nil {
 			pkg.Warn("Failed to delete old conversation", zap.String("key", keyTimes[i].key), zap.Error(err))
 		} else {
 			pkg.Info("Deleted old conversation to free memory", zap.String("key", keyTimes[i].key))
@@ -0,0 +1,66 @@
+package chat
+
+import (
+	"weave/services/aichat/internal/model"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// PromptParts 组成提示词的各部分，按保留的优先级排列
+type PromptParts struct {
+	// 系统提示词（不含历史）和当前用户消息，总是保留
+	Fixed []*schema.Message
+	// 对话摘要，预算不足时整体丢弃
+	Summary *schema.Message
+	// 检索到的上下文（关键词、知识库片段等），按相关度从高到低排列，预算不足时从后往前丢弃
+	Context []*schema.Message
+	// 筛选后的历史消息，按时间顺序排列，预算不足时从最早的消息开始丢弃
+	History []*schema.Message
+}
+
+// PromptPlan 按预算裁剪后的提示词
+type PromptPlan struct {
+	Summary *schema.Message
+	Context []*schema.Message
+	History []*schema.Message
+	// 裁剪后提示词的估算令牌数
+	Tokens int
+	// 因超出预算被丢弃的摘要、上下文和历史消息数
+	Dropped int
+}
+
+// FitPrompt 将提示词裁剪到令牌预算之内
+// 固定部分总是保留（即使超出预算），其余部分按摘要、上下文、历史的顺序填充剩余预算，
+// 历史消息从最近的开始保留；budget <= 0 表示不限制
+func FitPrompt(tokenizer model.Tokenizer, budget int, parts PromptParts) PromptPlan {
+	plan := PromptPlan{Tokens: tokenizer.CountMessages(parts.Fixed)}
+	fits := func(msg *schema.Message) bool {
+		cost := tokenizer.CountMessages([]*schema.Message{msg})
+		if budget > 0 && plan.Tokens+cost > budget {
+			plan.Dropped++
+			return false
+		}
+		plan.Tokens += cost
+		return true
+	}
+
+	if parts.Summary != nil && fits(parts.Summary) {
+		plan.Summary = parts.Summary
+	}
+	for _, msg := range parts.Context {
+		if fits(msg) {
+			plan.Context = append(plan.Context, msg)
+		}
+	}
+
+	// 从最近的消息往前填充，遇到放不下的消息即停止，保证保留的历史是连续的
+	start := len(parts.History)
+	for start > 0 && fits(parts.History[start-1]) {
+		start--
+	}
+	if start > 0 {
+		plan.Dropped += start - 1
+	}
+	plan.History = parts.History[start:]
+	return plan
+}
@@ -221,7 +221,7 @@ func FilterRelevantHistory(ctx context.Context, embedder embedding.Embedder, cha
 		msg := chatHistory[msgIndex]
 
 		// 计算余弦相似度
-		similarity := cosineSimilarity(questionVector, embedding)
+		similarity := CosineSimilarity(questionVector, embedding)
 
 		// 即使相似度较低，也为最近的消息赋予基础分数
 		baseScore := 0.0
@@ -366,8 +366,8 @@ func filterRelevantHistoryByKeywords(chatHistory []*schema.Message, currentQuest
 	return selectAndOrderMessages(scoredMessages, maxHistory, chatHistory, startIndex)
 }
 
-// cosineSimilarity 计算两个向量的余弦相似度
-func cosineSimilarity(a, b []float64) float64 {
+// CosineSimilarity 计算两个向量的余弦相似度
+func CosineSimilarity(a, b []float64) float64 {
 	if len(a) != len(b) {
 		return 0.0
 	}
@@ -521,7 +521,7 @@ func recallWithEmbedding(ctx context.Context, embedder embedding.Embedder, chatH
 	scores := make([]docScore, 0, len(historyEmbeddings))
 
 	for i, emb := range historyEmbeddings {
-		similarity := cosineSimilarity(questionVector, emb)
+		similarity := CosineSimilarity(questionVector, emb)
 		if similarity > 0 {
 			scores = append(scores, docScore{id: indices[i], score: similarity})
 		}
@@ -546,27 +546,9 @@ func recallWithEmbedding(ctx context.Context, embedder embedding.Embedder, chatH
 	return ranking
 }
 
-// FilterRelevantHistoryHybrid 多路召回+加权RFF排序+LLM重排
-// A路：BM25关键词召回  B路：Embedding语义召回
-func FilterRelevantHistoryHybrid(ctx context.Context, embedder embedding.Embedder, calculator *aichatpkg.BleveBM25Calculator, reranker *LLMReranker, chatHistory []*schema.Message, currentQuestion string, maxHistory int) []*schema.Message {
-	if len(chatHistory) == 0 || maxHistory <= 0 {
-		return []*schema.Message{}
-	}
-
-	if maxHistory > len(chatHistory) {
-		maxHistory = len(chatHistory)
-	}
-
-	// 空问题直接返回最近消息
-	if currentQuestion == "" {
-		start := len(chatHistory) - maxHistory
-		if start < 0 {
-			start = 0
-		}
-		return chatHistory[start:]
-	}
-
-	// 两路并行召回
+// HybridRanking 两路并行召回后加权RFF融合，返回与问题最相关的最多 limit 条消息的下标，按相关度从高到低排列
+// A路：BM25关键词召回  B路：Embedding语义召回；两路都没有召回结果时返回 nil
+func HybridRanking(ctx context.Context, embedder embedding.Embedder, calculator *aichatpkg.BleveBM25Calculator, messages []*schema.Message, question string, limit int) []int {
 	var rankingA, rankingB []int
 	var wg sync.WaitGroup
 
@@ -574,14 +556,14 @@ func FilterRelevantHistoryHybrid(ctx context.Context, embedder embedding.Embedde
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		rankingA = recallWithBM25(chatHistory, currentQuestion, calculator, maxHistory)
+		rankingA = recallWithBM25(messages, question, calculator, limit)
 	}()
 
 	// B路：Embedding召回
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		rankingB = recallWithEmbedding(ctx, embedder, chatHistory, currentQuestion, maxHistory)
+		rankingB = recallWithEmbedding(ctx, embedder, messages, question, limit)
 	}()
 
 	wg.Wait()
@@ -597,9 +579,27 @@ func FilterRelevantHistoryHybrid(ctx context.Context, embedder embedding.Embedde
 		rankings = append(rankings, rankingB)
 		weights = append(weights, RFFWeightEmbedding)
 	}
-
-	// 如果没有召回结果，返回最近消息
 	if len(rankings) == 0 {
+		return nil
+	}
+
+	// 加权RFF融合排序
+	return weightedRFFFusion(rankings, weights, RFFK, limit)
+}
+
+// FilterRelevantHistoryHybrid 多路召回+加权RFF排序+LLM重排
+// A路：BM25关键词召回  B路：Embedding语义召回
+func FilterRelevantHistoryHybrid(ctx context.Context, embedder embedding.Embedder, calculator *aichatpkg.BleveBM25Calculator, reranker *LLMReranker, chatHistory []*schema.Message, currentQuestion string, maxHistory int) []*schema.Message {
+	if len(chatHistory) == 0 || maxHistory <= 0 {
+		return []*schema.Message{}
+	}
+
+	if maxHistory > len(chatHistory) {
+		maxHistory = len(chatHistory)
+	}
+
+	// 空问题直接返回最近消息
+	if currentQuestion == "" {
 		start := len(chatHistory) - maxHistory
 		if start < 0 {
 			start = 0
@@ -607,8 +607,16 @@ func FilterRelevantHistoryHybrid(ctx context.Context, embedder embedding.Embedde
 		return chatHistory[start:]
 	}
 
-	// 加权RFF融合排序
-	finalRanking := weightedRFFFusion(rankings, weights, RFFK, maxHistory)
+	finalRanking := HybridRanking(ctx, embedder, calculator, chatHistory, currentQuestion, maxHistory)
+
+	// 如果没有召回结果，返回最近消息
+	if len(finalRanking) == 0 {
+		start := len(chatHistory) - maxHistory
+		if start < 0 {
+			start = 0
+		}
+		return chatHistory[start:]
+	}
 
 	// 按最终排序提取消息
 	candidates := make([]*schema.Message, 0, len(finalRanking))
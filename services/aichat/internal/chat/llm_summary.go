@@ -0,0 +1,271 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"weave/pkg"
+	"weave/services/aichat/internal/model"
+	"weave/services/aichat/internal/structured"
+	aichatpkg "weave/services/aichat/pkg"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"go.uber.org/zap"
+)
+
+// 摘要生成器类型，由 AICHAT_SUMMARY_GENERATOR 选择
+const (
+	SummaryGeneratorBM25 = "bm25"
+	SummaryGeneratorLLM  = "llm"
+)
+
+// RollingSummary 滚动摘要：摘要正文和从对话中提炼的关键实体、已做出的决定
+type RollingSummary struct {
+	Summary   string   `json:"summary"`
+	Entities  []string `json:"entities"`
+	Decisions []string `json:"decisions"`
+}
+
+// ConversationSummarizer 基于对话上次摘要的位置增量更新摘要的生成器
+type ConversationSummarizer interface {
+	// SummarizeConversation 将对话中尚未摘要的消息折叠进摘要，更新对话的摘要和元数据
+	SummarizeConversation(ctx context.Context, conversation *model.Conversation) (string, error)
+}
+
+// LLMSummaryConfig LLM摘要配置
+type LLMSummaryConfig struct {
+	MaxTokens         int // 摘要正文的令牌上限
+	MaxItems          int // 实体和决定各自最多保留的条数
+	MaxMessageLength  int // 折叠时单条消息的最大字符数
+	MaxFoldedMessages int // 单次折叠的最大消息数，更早的未摘要消息直接丢弃
+}
+
+// DefaultLLMSummaryConfig 默认配置
+func DefaultLLMSummaryConfig() *LLMSummaryConfig {
+	return &LLMSummaryConfig{
+		MaxTokens:         300, // 摘要令牌上限
+		MaxItems:          20,  // 实体和决定条数
+		MaxMessageLength:  800, // 单条消息长度
+		MaxFoldedMessages: 40,  // 单次折叠消息数
+	}
+}
+
+// LLMSummaryGenerator LLM摘要生成器
+// 每次只把新增的对话轮次折叠进已有摘要；模型调用失败或输出无法解析时回退到BM25摘要生成器，
+// 关键词提取和BM25召回始终由BM25摘要生成器提供
+type LLMSummaryGenerator struct {
+	llm      einomodel.BaseChatModel
+	fallback *SimpleSummaryGenerator
+	config   *LLMSummaryConfig
+}
+
+// NewLLMSummaryGenerator 创建LLM摘要生成器
+func NewLLMSummaryGenerator(llm einomodel.BaseChatModel, fallback *SimpleSummaryGenerator, config *LLMSummaryConfig) *LLMSummaryGenerator {
+	if config == nil {
+		config = DefaultLLMSummaryConfig()
+	}
+	if fallback == nil {
+		fallback = NewBM25SummaryGenerator([]string{})
+	}
+	return &LLMSummaryGenerator{llm: llm, fallback: fallback, config: config}
+}
+
+// GenerateSummary 使用LLM生成对话摘要，失败时回退到BM25摘要
+func (g *LLMSummaryGenerator) GenerateSummary(ctx context.Context, messages []*schema.Message) (string, error) {
+	rolling, err := g.Fold(ctx, RollingSummary{}, messages)
+	if err != nil {
+		pkg.GetLogger().Warn("LLM生成摘要失败，回退到BM25摘要", zap.Error(err))
+		return g.fallback.GenerateSummary(ctx, messages)
+	}
+	return rolling.Summary, nil
+}
+
+// UpdateSummary 将新消息折叠进已有摘要，失败时回退到BM25摘要
+func (g *LLMSummaryGenerator) UpdateSummary(ctx context.Context, existingSummary string, newMessages []*schema.Message) (string, error) {
+	if len(newMessages) == 0 {
+		return existingSummary, nil
+	}
+	rolling, err := g.Fold(ctx, RollingSummary{Summary: existingSummary}, newMessages)
+	if err != nil {
+		pkg.GetLogger().Warn("LLM更新摘要失败，回退到BM25摘要", zap.Error(err))
+		return g.fallback.UpdateSummary(ctx, existingSummary, newMessages)
+	}
+	return rolling.Summary, nil
+}
+
+// ExtractKeywords 提取关键词
+func (g *LLMSummaryGenerator) ExtractKeywords(text string, topN int) []string {
+	return g.fallback.ExtractKeywords(text, topN)
+}
+
+// GetBM25Calculator 获取BM25计算器（用于多路召回）
+func (g *LLMSummaryGenerator) GetBM25Calculator() *aichatpkg.BleveBM25Calculator {
+	return g.fallback.GetBM25Calculator()
+}
+
+// SummarizeConversation 将对话中上次摘要之后的消息折叠进摘要
+// 实体、决定和已摘要的消息数保存在对话元数据中；失败时用BM25摘要替代摘要正文，保留元数据，下次从同一位置重新折叠
+func (g *LLMSummaryGenerator) SummarizeConversation(ctx context.Context, conversation *model.Conversation) (string, error) {
+	previous, done := LoadRollingSummary(conversation)
+	if done >= len(conversation.Messages) {
+		return conversation.Summary, nil
+	}
+	rolling, err := g.Fold(ctx, previous, conversation.Messages[done:])
+	if err != nil {
+		pkg.GetLogger().Warn("LLM折叠摘要失败，回退到BM25摘要", zap.Error(err), zap.String("conversation_id", conversation.ID))
+		return conversation.GenerateSummary(g.fallback)
+	}
+	if err := StoreRollingSummary(conversation, rolling, len(conversation.Messages)); err != nil {
+		return "", err
+	}
+	return rolling.Summary, nil
+}
+
+// Fold 调用模型将新消息折叠进已有的滚动摘要，返回更新后的摘要
+func (g *LLMSummaryGenerator) Fold(ctx context.Context, previous RollingSummary, newMessages []*schema.Message) (*RollingSummary, error) {
+	if g.llm == nil {
+		return nil, fmt.Errorf("summary model is not configured")
+	}
+	dialogue := g.renderDialogue(newMessages)
+	if dialogue == "" {
+		return &previous, nil
+	}
+
+	resp, err := g.llm.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(fmt.Sprintf(summaryPrompt, g.config.MaxTokens, g.config.MaxItems)),
+		schema.UserMessage(g.renderPrevious(previous) + "\n\n新增对话：\n" + dialogue),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rolling RollingSummary
+	if err := json.Unmarshal([]byte(structured.ExtractJSON(resp.Content)), &rolling); err != nil {
+		return nil, fmt.Errorf("parse summary: %w", err)
+	}
+	rolling.Summary = truncateToTokens(strings.TrimSpace(rolling.Summary), g.config.MaxTokens)
+	if rolling.Summary == "" {
+		return nil, fmt.Errorf("summary model returned an empty summary")
+	}
+	rolling.Entities = normalizeItems(rolling.Entities, g.config.MaxItems)
+	rolling.Decisions = normalizeItems(rolling.Decisions, g.config.MaxItems)
+	return &rolling, nil
+}
+
+// summaryPrompt 折叠摘要的系统提示词，参数依次为摘要令牌上限和条目上限
+const summaryPrompt = `你负责维护一段对话的滚动摘要。把新增的对话内容合并进已有摘要，输出合并后的完整摘要，而不是只描述新增部分。
+摘要要概括用户的目标、讨论过的问题和结论，保留具体的名称、数字和约束，删去寒暄和重复内容，不超过 %d 个令牌。
+entities 列出对话涉及的关键实体（人、项目、系统、文件、术语等），decisions 列出已经确定的决定或结论，各不超过 %d 条，在已有条目的基础上增删，已被推翻的决定要删除。
+只输出 JSON 对象，例如 {"summary": "...", "entities": ["..."], "decisions": ["..."]}。`
+
+// renderPrevious 将已有的滚动摘要渲染为模型输入
+func (g *LLMSummaryGenerator) renderPrevious(previous RollingSummary) string {
+	data, _ := json.Marshal(previous)
+	return "已有摘要：\n" + string(data)
+}
+
+// renderDialogue 将新消息渲染为对话文本，跳过系统消息和空消息，过长的消息截断
+func (g *LLMSummaryGenerator) renderDialogue(messages []*schema.Message) string {
+	if len(messages) > g.config.MaxFoldedMessages {
+		messages = messages[len(messages)-g.config.MaxFoldedMessages:]
+	}
+	var b strings.Builder
+	for _, msg := range messages {
+		content := strings.TrimSpace(msg.Content)
+		if content == "" {
+			continue
+		}
+		var speaker string
+		switch msg.Role {
+		case schema.User:
+			speaker = "用户"
+		case schema.Assistant:
+			speaker = "助手"
+		default:
+			continue
+		}
+		if utf8.RuneCountInString(content) > g.config.MaxMessageLength {
+			content = string([]rune(content)[:g.config.MaxMessageLength]) + "……"
+		}
+		b.WriteString(speaker + "：" + content + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// LoadRollingSummary 从对话读取滚动摘要和已摘要的消息数，元数据缺失或损坏时从头开始
+func LoadRollingSummary(conversation *model.Conversation) (RollingSummary, int) {
+	rolling := RollingSummary{Summary: conversation.Summary}
+	done, err := strconv.Atoi(conversation.Metadata[model.MetadataSummarizedMessages])
+	if err != nil || done < 0 || done > len(conversation.Messages) {
+		return RollingSummary{}, 0
+	}
+	if v := conversation.Metadata[model.MetadataSummaryEntities]; v != "" {
+		_ = json.Unmarshal([]byte(v), &rolling.Entities)
+	}
+	if v := conversation.Metadata[model.MetadataSummaryDecisions]; v != "" {
+		_ = json.Unmarshal([]byte(v), &rolling.Decisions)
+	}
+	return rolling, done
+}
+
+// StoreRollingSummary 将滚动摘要写入对话，实体和决定以 JSON 数组保存在元数据中
+func StoreRollingSummary(conversation *model.Conversation, rolling *RollingSummary, summarized int) error {
+	entities, err := json.Marshal(rolling.Entities)
+	if err != nil {
+		return err
+	}
+	decisions, err := json.Marshal(rolling.Decisions)
+	if err != nil {
+		return err
+	}
+	if conversation.Metadata == nil {
+		conversation.Metadata = make(map[string]string)
+	}
+	conversation.SetSummary(rolling.Summary)
+	conversation.UpdateMetadata(model.MetadataSummaryEntities, string(entities))
+	conversation.UpdateMetadata(model.MetadataSummaryDecisions, string(decisions))
+	conversation.UpdateMetadata(model.MetadataSummarizedMessages, strconv.Itoa(summarized))
+	return nil
+}
+
+// normalizeItems 去掉空白和重复的条目，最多保留最后 limit 条
+func normalizeItems(items []string, limit int) []string {
+	seen := make(map[string]bool, len(items))
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		out = append(out, item)
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// truncateToTokens 将文本截断到令牌上限之内，maxTokens <= 0 表示不限制
+func truncateToTokens(text string, maxTokens int) string {
+	if maxTokens <= 0 || model.EstimateTokens(text) <= maxTokens {
+		return text
+	}
+	runes := []rune(text)
+	// 二分查找令牌数不超过上限的最长前缀
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if model.EstimateTokens(string(runes[:mid])+"……") <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo]) + "……"
+}
@@ -0,0 +1,127 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"weave/services/aichat/internal/model"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// scriptedModel 按顺序返回预设回复的聊天模型，回复为 error 时调用失败
+type scriptedModel struct {
+	mu      sync.Mutex
+	replies []any
+	inputs  [][]*schema.Message
+}
+
+func (m *scriptedModel) Generate(_ context.Context, input []*schema.Message, _ ...einomodel.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs = append(m.inputs, input)
+	if len(m.replies) == 0 {
+		return nil, errors.New("no scripted reply")
+	}
+	reply := m.replies[0]
+	m.replies = m.replies[1:]
+	if err, ok := reply.(error); ok {
+		return nil, err
+	}
+	return schema.AssistantMessage(reply.(string), nil), nil
+}
+
+func (m *scriptedModel) Stream(context.Context, []*schema.Message, ...einomodel.Option) (*schema.StreamReader[*schema.Message], error) {
+	return nil, errors.New("not implemented")
+}
+
+func rounds(contents ...string) []*schema.Message {
+	messages := make([]*schema.Message, 0, len(contents))
+	for i, content := range contents {
+		if i%2 == 0 {
+			messages = append(messages, schema.UserMessage(content))
+		} else {
+			messages = append(messages, schema.AssistantMessage(content, nil))
+		}
+	}
+	return messages
+}
+
+func TestLLMSummaryFoldsIncrementally(t *testing.T) {
+	llm := &scriptedModel{replies: []any{
+		`{"summary":"用户在迁移 Weave 的网关到 Go。","entities":["Weave","网关"],"decisions":["使用 Go 重写"]}`,
+		"```json\n{\"summary\":\"用户在迁移 Weave 网关到 Go，决定使用 gin。\",\"entities\":[\"Weave\",\"网关\",\" gin \",\"Weave\"],\"decisions\":[\"使用 Go 重写\",\"使用 gin\"]}\n```",
+	}}
+	g := NewLLMSummaryGenerator(llm, nil, nil)
+	conv := model.NewConversation("u1")
+	conv.Messages = rounds("我们要把网关迁到 Go", "好的", "还需要选个 Web 框架", "可以考虑 gin")
+
+	summary, err := g.SummarizeConversation(context.Background(), conv)
+	if err != nil || summary != "用户在迁移 Weave 的网关到 Go。" {
+		t.Fatalf("unexpected summary %q: %v", summary, err)
+	}
+	if conv.Summary != summary || conv.Metadata[model.MetadataSummarizedMessages] != "4" ||
+		conv.Metadata[model.MetadataSummaryDecisions] != `["使用 Go 重写"]` {
+		t.Fatalf("unexpected conversation state: %q %+v", conv.Summary, conv.Metadata)
+	}
+
+	// 第二次只折叠新增的消息，并带上已有的摘要、实体和决定
+	conv.Messages = append(conv.Messages, rounds("那就用 gin", "已记录")...)
+	if _, err := g.SummarizeConversation(context.Background(), conv); err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	input := llm.inputs[1][1].Content
+	if strings.Contains(input, "我们要把网关迁到 Go") || !strings.Contains(input, "用户：那就用 gin") || !strings.Contains(input, `"decisions":["使用 Go 重写"]`) {
+		t.Fatalf("expected only new messages folded into the previous summary, got %q", input)
+	}
+	previous, done := LoadRollingSummary(conv)
+	if done != 6 || strings.Join(previous.Entities, "|") != "Weave|网关|gin" || len(previous.Decisions) != 2 {
+		t.Fatalf("unexpected rolling summary: %d %+v", done, previous)
+	}
+
+	// 没有新消息时不调用模型
+	if _, err := g.SummarizeConversation(context.Background(), conv); err != nil || len(llm.inputs) != 2 {
+		t.Fatalf("expected no model call without new messages, got %d calls: %v", len(llm.inputs), err)
+	}
+}
+
+func TestLLMSummaryCapsTokens(t *testing.T) {
+	long := strings.Repeat("用户讨论了很多细节。", 200)
+	llm := &scriptedModel{replies: []any{`{"summary":"` + long + `","entities":[],"decisions":[]}`}}
+	g := NewLLMSummaryGenerator(llm, nil, &LLMSummaryConfig{MaxTokens: 50, MaxItems: 5, MaxMessageLength: 100, MaxFoldedMessages: 10})
+
+	summary, err := g.GenerateSummary(context.Background(), rounds("问题", "回答"))
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if tokens := model.EstimateTokens(summary); tokens > 50 || !strings.HasSuffix(summary, "……") {
+		t.Fatalf("expected the summary truncated to 50 tokens, got %d tokens: %q", tokens, summary)
+	}
+}
+
+func TestLLMSummaryFallsBackToBM25(t *testing.T) {
+	messages := rounds("如何配置 Redis 缓存", "设置 CACHE_TYPE=redis", "连接池怎么设置", "调整 REDIS_POOL_SIZE")
+	for name, reply := range map[string]any{
+		"model error":   errors.New("upstream unavailable"),
+		"invalid reply": "抱歉，我无法总结",
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewLLMSummaryGenerator(&scriptedModel{replies: []any{reply}}, nil, nil)
+			conv := model.NewConversation("u1")
+			conv.Messages = messages
+
+			summary, err := g.SummarizeConversation(context.Background(), conv)
+			if err != nil || !strings.HasPrefix(summary, "对话摘要：") || conv.Summary != summary {
+				t.Fatalf("expected the BM25 summary, got %q: %v", summary, err)
+			}
+			// 元数据不变，下次从头重新折叠
+			if _, ok := conv.Metadata[model.MetadataSummarizedMessages]; ok {
+				t.Fatalf("expected no rolling state after a fallback, got %+v", conv.Metadata)
+			}
+		})
+	}
+}
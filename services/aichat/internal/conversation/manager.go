@@ -0,0 +1,325 @@
+// Package conversation 管理用户的多个命名对话
+//
+// 对话写入时同时保存到对话缓存（内存或Redis）和持久化存储，读取时优先使用缓存，
+// 缓存过期或被淘汰后从存储恢复并重新写入缓存。未配置存储时只使用缓存。
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"weave/pkg"
+	"weave/services/aichat/internal/cache"
+	"weave/services/aichat/internal/model"
+
+	"github.com/cloudwego/eino/schema"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxTitleLength 对话标题的最大字符数
+	MaxTitleLength = 100
+	// autoTitleLength 根据首条用户消息生成标题时保留的字符数
+	autoTitleLength = 30
+	// defaultSearchLimit 搜索结果的默认数量和最大数量
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// ErrInvalidMessageIndex 分叉的消息序号超出对话消息范围
+var ErrInvalidMessageIndex = errors.New("message index out of range")
+
+// managerImpl 对话管理器实现
+type managerImpl struct {
+	cache cache.Cache
+	store Store
+}
+
+// NewManager 创建对话管理器，store 为 nil 时只使用缓存
+// 缓存由调用方管理，Close 只关闭存储
+func NewManager(c cache.Cache, store Store) model.ConversationManager {
+	return &managerImpl{cache: c, store: store}
+}
+
+// CreateConversation 创建新对话
+func (m *managerImpl) CreateConversation(ctx context.Context, userID, title string) (*model.Conversation, error) {
+	conv := model.NewConversation(userID)
+	conv.Title = NormalizeTitle(title)
+	if err := m.SaveConversation(ctx, conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// GetConversation 获取用户的对话，返回的是副本，修改后需要调用 SaveConversation 保存
+func (m *managerImpl) GetConversation(ctx context.Context, userID, conversationID string) (*model.Conversation, error) {
+	if v, err := m.cache.LoadConversation(ctx, conversationID); err == nil {
+		if conv := asConversation(v); conv != nil {
+			if conv.UserID != userID {
+				return nil, model.ErrConversationNotFound
+			}
+			return clone(conv), nil
+		}
+	}
+	if m.store == nil {
+		return nil, model.ErrConversationNotFound
+	}
+
+	conv, err := m.store.Load(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	// 缓存已过期或被淘汰，从存储恢复后重新写入缓存
+	if err := m.cache.SaveConversation(ctx, clone(conv)); err != nil {
+		pkg.Warn("回填对话缓存失败", zap.String("conversation_id", conversationID), zap.Error(err))
+	}
+	return conv, nil
+}
+
+// ListConversations 按最后活动时间倒序列出用户的对话，不包含消息
+func (m *managerImpl) ListConversations(ctx context.Context, userID string, filter model.ConversationFilter) ([]*model.Conversation, int64, error) {
+	if m.store != nil {
+		return m.store.List(ctx, userID, filter)
+	}
+
+	values, err := m.cache.LoadUserConversations(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	convs := make([]*model.Conversation, 0, len(values))
+	for _, v := range values {
+		conv := asConversation(v)
+		if conv == nil || (filter.Archived != nil && conv.Archived != *filter.Archived) {
+			continue
+		}
+		header := clone(conv)
+		header.Messages = nil
+		convs = append(convs, header)
+	}
+	sort.SliceStable(convs, func(i, j int) bool { return convs[i].EndTime.After(convs[j].EndTime) })
+
+	total := int64(len(convs))
+	start := min(filter.Offset, len(convs))
+	end := len(convs)
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, end)
+	}
+	return convs[start:end], total, nil
+}
+
+// SaveConversation 保存对话到缓存和存储，未设置标题时根据首条用户消息生成
+// 配置了存储时以存储为准，缓存写入失败只记录警告
+func (m *managerImpl) SaveConversation(ctx context.Context, conv *model.Conversation) error {
+	if conv.Title == "" {
+		conv.Title = GenerateTitle(conv.Messages)
+	}
+
+	cacheErr := m.cache.SaveConversation(ctx, clone(conv))
+	if m.store == nil {
+		return cacheErr
+	}
+	if cacheErr != nil {
+		pkg.Warn("保存对话到缓存失败", zap.String("conversation_id", conv.ID), zap.Error(cacheErr))
+	}
+	return m.store.Save(ctx, conv)
+}
+
+// RenameConversation 修改对话标题
+func (m *managerImpl) RenameConversation(ctx context.Context, userID, conversationID, title string) (*model.Conversation, error) {
+	conv, err := m.GetConversation(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	conv.Title = NormalizeTitle(title)
+	if err := m.SaveConversation(ctx, conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// ArchiveConversation 归档或取消归档对话
+func (m *managerImpl) ArchiveConversation(ctx context.Context, userID, conversationID string, archived bool) (*model.Conversation, error) {
+	conv, err := m.GetConversation(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	conv.Archived = archived
+	if err := m.SaveConversation(ctx, conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// DeleteConversation 从缓存和存储中删除对话
+func (m *managerImpl) DeleteConversation(ctx context.Context, userID, conversationID string) error {
+	if _, err := m.GetConversation(ctx, userID, conversationID); err != nil {
+		return err
+	}
+	if err := m.cache.DeleteConversation(ctx, conversationID, userID); err != nil {
+		if m.store == nil {
+			return err
+		}
+		pkg.Warn("从缓存删除对话失败", zap.String("conversation_id", conversationID), zap.Error(err))
+	}
+	if m.store != nil {
+		return m.store.Delete(ctx, userID, conversationID)
+	}
+	return nil
+}
+
+// ForkConversation 复制对话中到 messageIndex（含）为止的消息，创建一个新对话
+// 新对话记录来源对话和分叉点，摘要在后续消息中重新生成
+func (m *managerImpl) ForkConversation(ctx context.Context, userID, conversationID string, messageIndex int) (*model.Conversation, error) {
+	source, err := m.GetConversation(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if messageIndex < 0 || messageIndex >= len(source.Messages) {
+		return nil, ErrInvalidMessageIndex
+	}
+
+	fork := model.NewConversation(userID)
+	fork.Messages = append(fork.Messages, source.Messages[:messageIndex+1]...)
+	for k, v := range source.Metadata {
+		fork.Metadata[k] = v
+	}
+	// 摘要不随分叉复制，滚动摘要的状态也要从头开始
+	delete(fork.Metadata, model.MetadataSummaryEntities)
+	delete(fork.Metadata, model.MetadataSummaryDecisions)
+	delete(fork.Metadata, model.MetadataSummarizedMessages)
+	fork.ParentID = source.ID
+	fork.ForkIndex = messageIndex
+	if source.Title != "" {
+		fork.Title = NormalizeTitle(source.Title + "（分支）")
+	}
+	if err := m.SaveConversation(ctx, fork); err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
+
+// SearchConversations 在用户对话的标题、摘要和消息中搜索，不区分大小写
+func (m *managerImpl) SearchConversations(ctx context.Context, userID, query string, limit int) ([]model.ConversationSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []model.ConversationSearchResult{}, nil
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	if m.store != nil {
+		return m.store.Search(ctx, userID, query, limit)
+	}
+
+	values, err := m.cache.LoadUserConversations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]model.ConversationSearchResult, 0)
+	for _, v := range values {
+		conv := asConversation(v)
+		if conv == nil {
+			continue
+		}
+		if result, ok := searchConversation(conv, query); ok {
+			results = append(results, result)
+		}
+	}
+	sortByLastActive(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Close 关闭存储
+func (m *managerImpl) Close() error {
+	if m.store != nil {
+		return m.store.Close()
+	}
+	return nil
+}
+
+// searchConversation 在单个对话中查找第一处命中，标题和摘要优先
+func searchConversation(conv *model.Conversation, query string) (model.ConversationSearchResult, bool) {
+	header := clone(conv)
+	header.Messages = nil
+	for _, text := range []string{conv.Title, conv.Summary} {
+		if snippet := Snippet(text, query); snippet != "" {
+			return model.ConversationSearchResult{Conversation: header, MessageIndex: -1, Snippet: snippet}, true
+		}
+	}
+	for i, msg := range conv.Messages {
+		if snippet := Snippet(MessageText(msg), query); snippet != "" {
+			return model.ConversationSearchResult{Conversation: header, MessageIndex: i, Snippet: snippet}, true
+		}
+	}
+	return model.ConversationSearchResult{}, false
+}
+
+// GenerateTitle 根据首条用户消息生成对话标题，没有用户消息时返回空
+func GenerateTitle(messages []*schema.Message) string {
+	for _, msg := range messages {
+		if msg == nil || msg.Role != schema.User {
+			continue
+		}
+		text := strings.Join(strings.Fields(MessageText(msg)), " ")
+		if text == "" {
+			continue
+		}
+		if utf8.RuneCountInString(text) > autoTitleLength {
+			text = string([]rune(text)[:autoTitleLength]) + "…"
+		}
+		return text
+	}
+	return ""
+}
+
+// NormalizeTitle 去掉标题首尾空白并截断到 MaxTitleLength 个字符
+func NormalizeTitle(title string) string {
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > MaxTitleLength {
+		title = string([]rune(title)[:MaxTitleLength])
+	}
+	return title
+}
+
+// asConversation 将缓存中的对话转换为结构化对话
+// 内存缓存保存的是指针，Redis缓存反序列化为map，需要重新解码
+func asConversation(v interface{}) *model.Conversation {
+	switch conv := v.(type) {
+	case *model.Conversation:
+		return conv
+	case model.Conversation:
+		return &conv
+	case map[string]interface{}:
+		data, err := json.Marshal(conv)
+		if err != nil {
+			return nil
+		}
+		var decoded model.Conversation
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID == "" {
+			return nil
+		}
+		return &decoded
+	default:
+		return nil
+	}
+}
+
+// clone 复制对话，避免调用方修改缓存中共享的对话
+func clone(conv *model.Conversation) *model.Conversation {
+	c := *conv
+	c.Messages = append([]*schema.Message(nil), conv.Messages...)
+	c.Metadata = make(map[string]string, len(conv.Metadata))
+	for k, v := range conv.Metadata {
+		c.Metadata[k] = v
+	}
+	return &c
+}
@@ -0,0 +1,195 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"weave/pkg"
+	"weave/services/aichat/internal/cache"
+	"weave/services/aichat/internal/model"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestStore(t *testing.T) Store {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "aichat.db")
+	db, err := gorm.Open(sqlite.Open(pkg.SQLiteDSN(path, 5000)), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	store, err := NewStore(db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func newTestCache(t *testing.T) *cache.InMemoryCache {
+	t.Helper()
+	c := cache.NewInMemoryCacheWithConfig(&cache.CacheConfig{MaxUsers: 100, MaxMemoryMB: 1 << 20, TTL: time.Hour})
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// chatTurn 模拟一轮对话：追加用户消息和回复后保存
+func chatTurn(t *testing.T, m model.ConversationManager, conv *model.Conversation, question, answer string) {
+	t.Helper()
+	conv.AddMessage(schema.UserMessage(question))
+	conv.AddMessage(schema.AssistantMessage(answer, nil))
+	if err := m.SaveConversation(context.Background(), conv); err != nil {
+		t.Fatalf("save conversation: %v", err)
+	}
+}
+
+func TestManagerConversationLifecycle(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(newTestCache(t), newTestStore(t))
+	const alice, bob = "tenant:1:user:1", "tenant:1:user:2"
+
+	trip, err := m.CreateConversation(ctx, alice, "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	chatTurn(t, m, trip, "  帮我规划一次   去京都的三天旅行，预算大概一万元左右，喜欢寺庙和美食  ", "好的，第一天可以去清水寺……")
+	if trip.Title != "帮我规划一次 去京都的三天旅行，预算大概一万元左右，喜欢寺庙…" {
+		t.Fatalf("unexpected auto title %q", trip.Title)
+	}
+
+	work, _ := m.CreateConversation(ctx, alice, "周报")
+	chatTurn(t, m, work, "总结本周 Redis 迁移进度", "迁移已完成 80%")
+
+	convs, total, err := m.ListConversations(ctx, alice, model.ConversationFilter{Limit: 10})
+	if err != nil || total != 2 || len(convs) != 2 || convs[0].ID != work.ID || convs[0].Messages != nil {
+		t.Fatalf("expected two conversations, most recent first without messages, got %d %v %v", total, convs, err)
+	}
+
+	// 其他用户无法访问、修改或删除
+	if _, err := m.GetConversation(ctx, bob, trip.ID); !errors.Is(err, model.ErrConversationNotFound) {
+		t.Fatalf("expected not found for another user, got %v", err)
+	}
+	if err := m.DeleteConversation(ctx, bob, trip.ID); !errors.Is(err, model.ErrConversationNotFound) {
+		t.Fatalf("expected delete by another user to fail, got %v", err)
+	}
+
+	renamed, err := m.RenameConversation(ctx, alice, trip.ID, "  京都之旅 ")
+	if err != nil || renamed.Title != "京都之旅" {
+		t.Fatalf("rename: %v %+v", err, renamed)
+	}
+	if _, err := m.ArchiveConversation(ctx, alice, work.ID, true); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	active := false
+	convs, total, _ = m.ListConversations(ctx, alice, model.ConversationFilter{Archived: &active})
+	if total != 1 || convs[0].ID != trip.ID || convs[0].Title != "京都之旅" {
+		t.Fatalf("expected only the unarchived conversation, got %d %+v", total, convs)
+	}
+
+	fork, err := m.ForkConversation(ctx, alice, trip.ID, 0)
+	if err != nil {
+		t.Fatalf("fork: %v", err)
+	}
+	if fork.ParentID != trip.ID || fork.ForkIndex != 0 || len(fork.Messages) != 1 || fork.Title != "京都之旅（分支）" {
+		t.Fatalf("unexpected fork %+v", fork)
+	}
+	if _, err := m.ForkConversation(ctx, alice, trip.ID, 2); !errors.Is(err, ErrInvalidMessageIndex) {
+		t.Fatalf("expected invalid message index, got %v", err)
+	}
+
+	results, err := m.SearchConversations(ctx, alice, "redis", 10)
+	if err != nil || len(results) != 1 || results[0].Conversation.ID != work.ID || results[0].MessageIndex != 0 {
+		t.Fatalf("expected case-insensitive message hit, got %+v %v", results, err)
+	}
+	if results[0].Snippet != "总结本周 Redis 迁移进度" {
+		t.Fatalf("unexpected snippet %q", results[0].Snippet)
+	}
+	results, _ = m.SearchConversations(ctx, alice, "京都", 10)
+	if len(results) != 2 || results[0].Conversation.ID != fork.ID || results[0].MessageIndex != -1 {
+		t.Fatalf("expected title hits ordered by activity, got %+v", results)
+	}
+	if results, _ = m.SearchConversations(ctx, bob, "京都", 10); len(results) != 0 {
+		t.Fatalf("search must not return other users' conversations, got %+v", results)
+	}
+	if results, _ = m.SearchConversations(ctx, alice, "_", 10); len(results) != 0 {
+		t.Fatalf("LIKE wildcards must be escaped, got %+v", results)
+	}
+
+	if err := m.DeleteConversation(ctx, alice, fork.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := m.GetConversation(ctx, alice, fork.ID); !errors.Is(err, model.ErrConversationNotFound) {
+		t.Fatalf("expected deleted conversation to be gone, got %v", err)
+	}
+}
+
+func TestManagerRestoresFromStoreAfterCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	const alice = "tenant:1:user:1"
+
+	conv, _ := NewManager(newTestCache(t), store).CreateConversation(ctx, alice, "")
+	m := NewManager(newTestCache(t), store)
+	conv.AddMessage(&schema.Message{Role: schema.User, UserInputMultiContent: []schema.MessageInputPart{
+		{Type: schema.ChatMessagePartTypeText, Text: "这张图片里是什么"},
+	}})
+	conv.AddMessage(schema.AssistantMessage("是一只猫", nil))
+	if err := m.SaveConversation(ctx, conv); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	// 新的缓存模拟缓存过期或被淘汰
+	emptyCache := newTestCache(t)
+	restored, err := NewManager(emptyCache, store).GetConversation(ctx, alice, conv.ID)
+	if err != nil {
+		t.Fatalf("restore from store: %v", err)
+	}
+	if len(restored.Messages) != 2 || restored.Title != "这张图片里是什么" ||
+		restored.Messages[0].UserInputMultiContent[0].Text != "这张图片里是什么" || restored.Messages[1].Content != "是一只猫" {
+		t.Fatalf("unexpected restored conversation %+v", restored)
+	}
+	if _, err := emptyCache.LoadConversation(ctx, conv.ID); err != nil {
+		t.Fatalf("expected restored conversation to be written back to cache: %v", err)
+	}
+
+	// 追加消息只写入新消息
+	restored.AddMessage(schema.UserMessage("它是什么品种"))
+	if err := m.SaveConversation(ctx, restored); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	reloaded, _ := store.Load(ctx, alice, conv.ID)
+	if len(reloaded.Messages) != 3 || reloaded.Messages[2].Content != "它是什么品种" {
+		t.Fatalf("expected appended message to be persisted, got %+v", reloaded.Messages)
+	}
+}
+
+func TestManagerWithoutStore(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(newTestCache(t), nil)
+	const alice = "tenant:0:user:1"
+
+	first, _ := m.CreateConversation(ctx, alice, "first")
+	second, _ := m.CreateConversation(ctx, alice, "")
+	chatTurn(t, m, second, "Hello World", "hi")
+
+	convs, total, err := m.ListConversations(ctx, alice, model.ConversationFilter{Limit: 1, Offset: 1})
+	if err != nil || total != 2 || len(convs) != 1 || convs[0].ID != first.ID {
+		t.Fatalf("expected paginated cache listing, got %d %+v %v", total, convs, err)
+	}
+	results, _ := m.SearchConversations(ctx, alice, "WORLD", 0)
+	if len(results) != 1 || results[0].Conversation.ID != second.ID {
+		t.Fatalf("expected cache search hit, got %+v", results)
+	}
+	if err := m.DeleteConversation(ctx, alice, first.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, total, _ = m.ListConversations(ctx, alice, model.ConversationFilter{}); total != 1 {
+		t.Fatalf("expected one conversation after delete, got %d", total)
+	}
+}
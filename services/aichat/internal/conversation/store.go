@@ -0,0 +1,420 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"weave/pkg"
+	"weave/services/aichat/internal/model"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/glebarez/sqlite"
+	"github.com/spf13/viper"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// Store 对话的持久化存储，缓存过期或被淘汰后从这里恢复对话
+type Store interface {
+	// Save 保存对话及其消息，消息按序号追加，已保存的消息不会重复写入
+	Save(ctx context.Context, conv *model.Conversation) error
+	// Load 加载用户的对话（包含消息）
+	Load(ctx context.Context, userID, conversationID string) (*model.Conversation, error)
+	// List 按最后活动时间倒序列出用户的对话（不包含消息）
+	List(ctx context.Context, userID string, filter model.ConversationFilter) ([]*model.Conversation, int64, error)
+	// Delete 删除用户的对话及其消息
+	Delete(ctx context.Context, userID, conversationID string) error
+	// Search 在用户对话的标题、摘要和消息内容中搜索
+	Search(ctx context.Context, userID, query string, limit int) ([]model.ConversationSearchResult, error)
+	// Close 关闭存储
+	Close() error
+}
+
+// conversationRecord 对话表记录
+type conversationRecord struct {
+	ID           string `gorm:"primaryKey;size:64"`
+	UserID       string `gorm:"size:128;index:idx_aichat_conversations_user_active,priority:1;not null"`
+	Title        string `gorm:"size:255"`
+	Summary      string `gorm:"type:text"`
+	Metadata     string `gorm:"type:text"`
+	Archived     bool   `gorm:"not null;default:false"`
+	ParentID     string `gorm:"size:64"`
+	ForkIndex    int
+	MessageCount int
+	StartTime    time.Time
+	LastActiveAt time.Time `gorm:"index:idx_aichat_conversations_user_active,priority:2"`
+}
+
+func (conversationRecord) TableName() string { return "aichat_conversations" }
+
+// messageRecord 消息表记录，Content 保存消息的纯文本用于搜索，Payload 保存完整消息
+type messageRecord struct {
+	ID             uint   `gorm:"primaryKey"`
+	ConversationID string `gorm:"size:64;uniqueIndex:idx_aichat_messages_conversation_seq,priority:1;not null"`
+	Seq            int    `gorm:"uniqueIndex:idx_aichat_messages_conversation_seq,priority:2;not null"`
+	Role           string `gorm:"size:32"`
+	Content        string `gorm:"type:text"`
+	Payload        string `gorm:"type:text"`
+	CreatedAt      time.Time
+}
+
+func (messageRecord) TableName() string { return "aichat_messages" }
+
+// gormStore 基于GORM的对话存储，支持 SQLite、MySQL 和 PostgreSQL
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewStore 基于已有的数据库连接创建对话存储，并自动迁移表结构
+func NewStore(db *gorm.DB) (Store, error) {
+	if err := db.AutoMigrate(&conversationRecord{}, &messageRecord{}); err != nil {
+		return nil, fmt.Errorf("migrate aichat conversation tables: %w", err)
+	}
+	return &gormStore{db: db}, nil
+}
+
+// OpenStore 按环境变量打开对话存储
+func OpenStore() (Store, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(db)
+}
+
+// OpenDB 按环境变量打开 aichat 数据库，对话和用量记录保存在同一个库中
+// AICHAT_DB_DRIVER 可选 sqlite（默认）、mysql、postgres；AICHAT_DB_DSN 为连接字符串，
+// SQLite 时为数据库文件路径，默认 ./data/aichat.db
+func OpenDB() (*gorm.DB, error) {
+	return openDB(strings.ToLower(viper.GetString("AICHAT_DB_DRIVER")), viper.GetString("AICHAT_DB_DSN"))
+}
+
+// OpenMemoryDB 打开 SQLite 内存数据库，数据库不可用或测试时使用，进程重启后数据丢失
+func OpenMemoryDB() (*gorm.DB, error) {
+	return openDB("sqlite", ":memory:")
+}
+
+func openDB(driver, dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch driver {
+	case "", "sqlite":
+		if dsn == "" {
+			dsn = "./data/aichat.db"
+		}
+		if dsn != ":memory:" {
+			if err := os.MkdirAll(filepath.Dir(dsn), 0o755); err != nil {
+				return nil, fmt.Errorf("create aichat database directory: %w", err)
+			}
+		}
+		dialector = sqlite.Open(pkg.SQLiteDSN(dsn, 5000))
+	case "mysql":
+		dialector = mysql.Open(dsn)
+	case "postgres", "postgresql":
+		dialector = postgres.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported AICHAT_DB_DRIVER %q", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Error)})
+	if err != nil {
+		return nil, fmt.Errorf("open aichat database: %w", err)
+	}
+	if dsn == ":memory:" {
+		// 内存数据库每个连接都是独立的库，只能使用单个连接
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.SetMaxOpenConns(1)
+		}
+	}
+	return db, nil
+}
+
+// Save 保存对话及其消息
+// 消息只追加：已保存的消息不会重写，消息数量少于已保存数量时删除多余的消息
+func (s *gormStore) Save(ctx context.Context, conv *model.Conversation) error {
+	record, err := toRecord(conv)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored int64
+		if err := tx.Model(&messageRecord{}).Where("conversation_id = ?", conv.ID).Count(&stored).Error; err != nil {
+			return err
+		}
+		if int(stored) > len(conv.Messages) {
+			if err := tx.Where("conversation_id = ? AND seq >= ?", conv.ID, len(conv.Messages)).Delete(&messageRecord{}).Error; err != nil {
+				return err
+			}
+			stored = int64(len(conv.Messages))
+		}
+		if pending := conv.Messages[stored:]; len(pending) > 0 {
+			rows := make([]messageRecord, 0, len(pending))
+			for i, msg := range pending {
+				payload, err := json.Marshal(msg)
+				if err != nil {
+					return fmt.Errorf("marshal message: %w", err)
+				}
+				rows = append(rows, messageRecord{
+					ConversationID: conv.ID,
+					Seq:            int(stored) + i,
+					Role:           string(msg.Role),
+					Content:        MessageText(msg),
+					Payload:        string(payload),
+				})
+			}
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(record).Error
+	})
+}
+
+// Load 加载用户的对话（包含消息）
+func (s *gormStore) Load(ctx context.Context, userID, conversationID string) (*model.Conversation, error) {
+	var record conversationRecord
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", conversationID, userID).Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, model.ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []messageRecord
+	if err := s.db.WithContext(ctx).Where("conversation_id = ?", conversationID).Order("seq").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	conv := fromRecord(record)
+	conv.Messages = make([]*schema.Message, 0, len(rows))
+	for _, row := range rows {
+		var msg schema.Message
+		if err := json.Unmarshal([]byte(row.Payload), &msg); err != nil {
+			return nil, fmt.Errorf("unmarshal message %d of %s: %w", row.Seq, conversationID, err)
+		}
+		conv.Messages = append(conv.Messages, &msg)
+	}
+	return conv, nil
+}
+
+// List 按最后活动时间倒序列出用户的对话
+func (s *gormStore) List(ctx context.Context, userID string, filter model.ConversationFilter) ([]*model.Conversation, int64, error) {
+	query := s.db.WithContext(ctx).Model(&conversationRecord{}).Where("user_id = ?", userID)
+	if filter.Archived != nil {
+		query = query.Where("archived = ?", *filter.Archived)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("last_active_at DESC").Offset(filter.Offset)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	var records []conversationRecord
+	if err := query.Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	convs := make([]*model.Conversation, 0, len(records))
+	for _, record := range records {
+		convs = append(convs, fromRecord(record))
+	}
+	return convs, total, nil
+}
+
+// Delete 删除用户的对话及其消息
+func (s *gormStore) Delete(ctx context.Context, userID, conversationID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", conversationID, userID).Delete(&conversationRecord{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrConversationNotFound
+		}
+		return tx.Where("conversation_id = ?", conversationID).Delete(&messageRecord{}).Error
+	})
+}
+
+// Search 在用户对话的标题、摘要和消息内容中搜索，不区分大小写
+// 每个对话只返回第一处命中，标题或摘要命中优先于消息命中，结果按最后活动时间倒序
+func (s *gormStore) Search(ctx context.Context, userID, query string, limit int) ([]model.ConversationSearchResult, error) {
+	pattern := "%" + escapeLike(strings.ToLower(query)) + "%"
+	db := s.db.WithContext(ctx)
+
+	var titleHits []conversationRecord
+	if err := db.Where("user_id = ?", userID).
+		Where("(LOWER(title) LIKE ? ESCAPE '!' OR LOWER(summary) LIKE ? ESCAPE '!')", pattern, pattern).
+		Order("last_active_at DESC").Limit(limit).Find(&titleHits).Error; err != nil {
+		return nil, err
+	}
+
+	type messageHit struct {
+		ConversationID string
+		Seq            int
+	}
+	var messageHits []messageHit
+	if err := db.Table("aichat_messages AS m").
+		Select("m.conversation_id, MIN(m.seq) AS seq").
+		Joins("JOIN aichat_conversations AS c ON c.id = m.conversation_id").
+		Where("c.user_id = ? AND LOWER(m.content) LIKE ? ESCAPE '!'", userID, pattern).
+		Group("m.conversation_id").
+		Order("MAX(c.last_active_at) DESC").
+		Limit(limit).
+		Scan(&messageHits).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]model.ConversationSearchResult, 0, len(titleHits)+len(messageHits))
+	seen := make(map[string]bool, len(titleHits))
+	for _, record := range titleHits {
+		seen[record.ID] = true
+		snippet := Snippet(record.Title, query)
+		if snippet == "" {
+			snippet = Snippet(record.Summary, query)
+		}
+		results = append(results, model.ConversationSearchResult{Conversation: fromRecord(record), MessageIndex: -1, Snippet: snippet})
+	}
+	for _, hit := range messageHits {
+		if seen[hit.ConversationID] {
+			continue
+		}
+		var record conversationRecord
+		if err := db.Where("id = ?", hit.ConversationID).Take(&record).Error; err != nil {
+			return nil, err
+		}
+		var msg messageRecord
+		if err := db.Where("conversation_id = ? AND seq = ?", hit.ConversationID, hit.Seq).Take(&msg).Error; err != nil {
+			return nil, err
+		}
+		results = append(results, model.ConversationSearchResult{Conversation: fromRecord(record), MessageIndex: hit.Seq, Snippet: Snippet(msg.Content, query)})
+	}
+
+	sortByLastActive(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Close 关闭数据库连接
+func (s *gormStore) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// toRecord 将对话转换为对话表记录
+func toRecord(conv *model.Conversation) (*conversationRecord, error) {
+	metadata, err := json.Marshal(conv.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("marshal conversation metadata: %w", err)
+	}
+	return &conversationRecord{
+		ID:           conv.ID,
+		UserID:       conv.UserID,
+		Title:        conv.Title,
+		Summary:      conv.Summary,
+		Metadata:     string(metadata),
+		Archived:     conv.Archived,
+		ParentID:     conv.ParentID,
+		ForkIndex:    conv.ForkIndex,
+		MessageCount: len(conv.Messages),
+		StartTime:    conv.StartTime,
+		LastActiveAt: conv.EndTime,
+	}, nil
+}
+
+// fromRecord 将对话表记录转换为不含消息的对话
+func fromRecord(record conversationRecord) *model.Conversation {
+	metadata := make(map[string]string)
+	if record.Metadata != "" {
+		_ = json.Unmarshal([]byte(record.Metadata), &metadata)
+	}
+	return &model.Conversation{
+		ID:        record.ID,
+		UserID:    record.UserID,
+		Title:     record.Title,
+		StartTime: record.StartTime,
+		EndTime:   record.LastActiveAt,
+		Metadata:  metadata,
+		Summary:   record.Summary,
+		Archived:  record.Archived,
+		ParentID:  record.ParentID,
+		ForkIndex: record.ForkIndex,
+	}
+}
+
+// sortByLastActive 按对话最后活动时间倒序排列搜索结果
+func sortByLastActive(results []model.ConversationSearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Conversation.EndTime.After(results[j].Conversation.EndTime)
+	})
+}
+
+// escapeLike 转义 LIKE 模式中的通配符，转义字符为 !（MySQL 字符串中的反斜杠需要二次转义，不便跨数据库使用）
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// MessageText 返回消息的纯文本，多模态消息只取其中的文本部分
+func MessageText(msg *schema.Message) string {
+	if msg == nil {
+		return ""
+	}
+	if msg.Content != "" || len(msg.UserInputMultiContent) == 0 {
+		return msg.Content
+	}
+	var texts []string
+	for _, part := range msg.UserInputMultiContent {
+		if part.Type == schema.ChatMessagePartTypeText && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// snippetRadius 搜索片段在命中位置前后保留的字符数
+const snippetRadius = 30
+
+// Snippet 返回文本中第一处命中位置附近的片段，不区分大小写，未命中时返回空
+func Snippet(text, query string) string {
+	runes := []rune(text)
+	needle := []rune(strings.ToLower(query))
+	if len(needle) == 0 {
+		return ""
+	}
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	for i := 0; i+len(needle) <= len(lower); i++ {
+		if string(lower[i:i+len(needle)]) != string(needle) {
+			continue
+		}
+		start, end := max(i-snippetRadius, 0), min(i+len(needle)+snippetRadius, len(runes))
+		snippet := strings.Join(strings.Fields(string(runes[start:end])), " ")
+		if start > 0 {
+			snippet = "…" + snippet
+		}
+		if end < len(runes) {
+			snippet += "…"
+		}
+		return snippet
+	}
+	return ""
+}
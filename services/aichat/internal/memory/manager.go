@@ -0,0 +1,205 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"weave/services/aichat/internal/chat"
+	aichatpkg "weave/services/aichat/pkg"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 记忆管理的默认配置
+const (
+	DefaultDedupeThreshold = 0.9
+	DefaultRecallLimit     = 5
+	DefaultMaxPerUser      = 200
+)
+
+// Config 记忆管理配置
+type Config struct {
+	// DedupeThreshold 新记忆与已有记忆的向量余弦相似度不低于该值时视为重复
+	DedupeThreshold float64
+	// RecallLimit 每轮对话最多加入上下文的记忆数
+	RecallLimit int
+	// MaxPerUser 每个用户最多保存的自动提取记忆数，超出时删除最早的自动提取记忆
+	MaxPerUser int
+}
+
+// Manager 长期记忆的管理：手动维护、去重保存和按问题召回
+type Manager struct {
+	store      Store
+	embedder   embedding.Embedder
+	calculator *aichatpkg.BleveBM25Calculator
+	config     Config
+
+	mu sync.Mutex // 串行化去重和保存，避免同一用户并发的两轮对话保存相同的记忆
+}
+
+// NewManager 创建记忆管理器，embedder 为 nil 时只按文本去重，召回只使用 BM25
+func NewManager(store Store, embedder embedding.Embedder, calculator *aichatpkg.BleveBM25Calculator, config Config) *Manager {
+	if config.DedupeThreshold <= 0 || config.DedupeThreshold > 1 {
+		config.DedupeThreshold = DefaultDedupeThreshold
+	}
+	if config.RecallLimit <= 0 {
+		config.RecallLimit = DefaultRecallLimit
+	}
+	if config.MaxPerUser <= 0 {
+		config.MaxPerUser = DefaultMaxPerUser
+	}
+	return &Manager{store: store, embedder: embedder, calculator: calculator, config: config}
+}
+
+// List 按创建顺序列出用户的记忆
+func (m *Manager) List(ctx context.Context, userID string) ([]Memory, error) {
+	return m.store.List(ctx, userID)
+}
+
+// Add 手动添加一条记忆
+func (m *Manager) Add(ctx context.Context, userID, content string) (*Memory, error) {
+	content, err := validateContent(content)
+	if err != nil {
+		return nil, err
+	}
+	memory := &Memory{UserID: userID, Content: content, Source: SourceManual, Embedding: m.embed(ctx, content)}
+	if err := m.store.Create(ctx, memory); err != nil {
+		return nil, err
+	}
+	return memory, nil
+}
+
+// Update 修改记忆内容，修改后的记忆视为用户手动维护，不会因超出数量上限被删除
+func (m *Manager) Update(ctx context.Context, userID string, id uint, content string) (*Memory, error) {
+	content, err := validateContent(content)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := m.store.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	memory.Content, memory.Source, memory.Embedding = content, SourceManual, m.embed(ctx, content)
+	if err := m.store.Update(ctx, memory); err != nil {
+		return nil, err
+	}
+	return memory, nil
+}
+
+// Delete 删除用户的一条记忆
+func (m *Manager) Delete(ctx context.Context, userID string, id uint) error {
+	return m.store.Delete(ctx, userID, id)
+}
+
+// Clear 删除用户的所有记忆
+func (m *Manager) Clear(ctx context.Context, userID string) (int64, error) {
+	return m.store.Clear(ctx, userID)
+}
+
+// Remember 保存从一轮对话中提取的记忆，跳过与已有记忆重复的条目，返回新保存的记忆
+// 有向量时按余弦相似度去重，否则按忽略标点和大小写后的文本去重
+func (m *Manager) Remember(ctx context.Context, turn Turn, candidates []string) ([]Memory, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	var vectors [][]float64
+	if m.embedder != nil {
+		if embedded, err := m.embedder.EmbedStrings(ctx, candidates); err == nil && len(embedded) == len(candidates) {
+			vectors = embedded
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, err := m.store.List(ctx, turn.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []Memory
+	for i, content := range candidates {
+		memory := Memory{
+			UserID:         turn.UserID,
+			Content:        content,
+			Source:         SourceExtracted,
+			ConversationID: turn.ConversationID,
+			MessageIndex:   turn.MessageIndex,
+		}
+		if vectors != nil {
+			memory.Embedding = vectors[i]
+		}
+		if m.duplicate(memory, existing) {
+			continue
+		}
+		if err := m.store.Create(ctx, &memory); err != nil {
+			return saved, err
+		}
+		existing = append(existing, memory)
+		saved = append(saved, memory)
+	}
+	if len(saved) > 0 {
+		err = m.prune(ctx, turn.UserID, existing)
+	}
+	return saved, err
+}
+
+// duplicate 记忆是否与已有记忆重复
+func (m *Manager) duplicate(memory Memory, existing []Memory) bool {
+	key := normalize(memory.Content)
+	for _, other := range existing {
+		if normalize(other.Content) == key {
+			return true
+		}
+		if len(memory.Embedding) > 0 && len(memory.Embedding) == len(other.Embedding) &&
+			chat.CosineSimilarity(memory.Embedding, other.Embedding) >= m.config.DedupeThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// prune 自动提取的记忆超过上限时删除最早的记忆，手动添加和修改过的记忆不受影响
+func (m *Manager) prune(ctx context.Context, userID string, memories []Memory) error {
+	var extracted []uint
+	for _, memory := range memories {
+		if memory.Source == SourceExtracted {
+			extracted = append(extracted, memory.ID)
+		}
+	}
+	if excess := len(extracted) - m.config.MaxPerUser; excess > 0 {
+		return m.store.Delete(ctx, userID, extracted[:excess]...)
+	}
+	return nil
+}
+
+// Recall 召回与问题相关的记忆，按相关度从高到低排列
+// 记忆不超过 RecallLimit 条时全部返回，否则与历史消息筛选相同，使用 BM25 和向量两路召回后加权RFF融合
+func (m *Manager) Recall(ctx context.Context, userID, question string) ([]Memory, error) {
+	memories, err := m.store.List(ctx, userID)
+	if err != nil || len(memories) <= m.config.RecallLimit {
+		return memories, err
+	}
+	messages := make([]*schema.Message, len(memories))
+	for i, memory := range memories {
+		messages[i] = schema.UserMessage(memory.Content)
+	}
+	ranking := chat.HybridRanking(ctx, m.embedder, m.calculator, messages, question, m.config.RecallLimit)
+	recalled := make([]Memory, 0, len(ranking))
+	for _, idx := range ranking {
+		recalled = append(recalled, memories[idx])
+	}
+	return recalled, nil
+}
+
+// embed 返回内容的向量，嵌入器不可用或出错时返回 nil
+func (m *Manager) embed(ctx context.Context, content string) []float64 {
+	if m.embedder == nil {
+		return nil
+	}
+	vectors, err := m.embedder.EmbedStrings(ctx, []string{content})
+	if err != nil || len(vectors) != 1 {
+		return nil
+	}
+	return vectors[0]
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"weave/services/aichat/internal/conversation"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// topicEmbedder 按内容包含的主题词生成向量，主题相同的内容向量相同
+type topicEmbedder struct{ topics []string }
+
+func (e topicEmbedder) EmbedStrings(_ context.Context, texts []string, _ ...embedding.Option) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float64, len(e.topics)+1)
+		vectors[i][len(e.topics)] = 0.01
+		for j, topic := range e.topics {
+			if strings.Contains(text, topic) {
+				vectors[i][j] = 1
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func newTestManager(t *testing.T, config Config) *Manager {
+	t.Helper()
+	db, err := conversation.OpenMemoryDB()
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	store, err := NewStore(db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	return NewManager(store, topicEmbedder{topics: []string{"Go", "团队", "上海", "猫"}}, nil, config)
+}
+
+func contents(memories []Memory) []string {
+	out := make([]string, len(memories))
+	for i, m := range memories {
+		out[i] = m.Content
+	}
+	return out
+}
+
+func TestParseCandidates(t *testing.T) {
+	candidates, err := ParseCandidates("提取结果：\n```json\n[\" 用户喜欢 Go \", \"用户喜欢Go。\", \"\", \"用户住在上海\"]\n```")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := strings.Join(candidates, "|"); got != "用户喜欢 Go|用户住在上海" {
+		t.Fatalf("unexpected candidates: %q", got)
+	}
+	if _, err := ParseCandidates("没有需要记住的内容"); err == nil {
+		t.Fatal("expected an error for a reply without JSON")
+	}
+}
+
+func TestRememberDedupesByEmbedding(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, Config{})
+	turn := Turn{UserID: "u1", ConversationID: "c1", MessageIndex: 4}
+
+	saved, err := m.Remember(ctx, turn, []string{"用户在 Weave 团队工作", "用户喜欢 Go 示例"})
+	if err != nil || len(saved) != 2 {
+		t.Fatalf("remember: %v %+v", err, saved)
+	}
+	if saved[0].ConversationID != "c1" || saved[0].MessageIndex != 4 || saved[0].Source != SourceExtracted {
+		t.Fatalf("expected provenance on extracted memories, got %+v", saved[0])
+	}
+
+	// 措辞不同但向量相同的条目视为重复
+	saved, err = m.Remember(ctx, turn, []string{"用户所在的团队是 Weave", "用户养了一只猫"})
+	if err != nil || len(saved) != 1 || saved[0].Content != "用户养了一只猫" {
+		t.Fatalf("expected only the new fact saved, got %v %+v", err, saved)
+	}
+
+	// 其他用户的记忆互不影响
+	if saved, err = m.Remember(ctx, Turn{UserID: "u2"}, []string{"用户喜欢 Go 示例"}); err != nil || len(saved) != 1 {
+		t.Fatalf("expected memories to be per user, got %v %+v", err, saved)
+	}
+	if _, err := m.Update(ctx, "u2", 1, "改写别人的记忆"); !errors.Is(err, ErrMemoryNotFound) {
+		t.Fatalf("expected ErrMemoryNotFound, got %v", err)
+	}
+}
+
+func TestRememberPrunesOldestExtracted(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, Config{MaxPerUser: 2})
+	if _, err := m.Add(ctx, "u1", "用户住在上海"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	for _, content := range []string{"用户喜欢 Go", "用户在 Weave 团队", "用户养了一只猫"} {
+		if _, err := m.Remember(ctx, Turn{UserID: "u1"}, []string{content}); err != nil {
+			t.Fatalf("remember: %v", err)
+		}
+	}
+	memories, err := m.List(ctx, "u1")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if got := strings.Join(contents(memories), "|"); got != "用户住在上海|用户在 Weave 团队|用户养了一只猫" {
+		t.Fatalf("expected the oldest extracted memory pruned and the manual one kept, got %q", got)
+	}
+}
+
+func TestRecallRanksRelevantMemories(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, Config{RecallLimit: 1})
+	for _, content := range []string{"用户住在上海", "用户喜欢 Go 示例"} {
+		if _, err := m.Add(ctx, "u1", content); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	recalled, err := m.Recall(ctx, "u1", "给我一个 Go 的例子")
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if got := contents(recalled); len(got) != 1 || got[0] != "用户喜欢 Go 示例" {
+		t.Fatalf("expected the Go memory recalled, got %q", got)
+	}
+}
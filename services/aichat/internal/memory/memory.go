@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"weave/services/aichat/internal/structured"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 记忆的来源
+const (
+	SourceExtracted = "extracted" // 每轮对话后由模型从用户的发言中提取
+	SourceManual    = "manual"    // 用户通过接口添加或修改
+)
+
+// maxContentLength 单条记忆的最大字符数
+const maxContentLength = 500
+
+// maxCandidates 每轮对话最多提取的记忆数
+const maxCandidates = 5
+
+var (
+	// ErrMemoryNotFound 记忆不存在或不属于该用户
+	ErrMemoryNotFound = errors.New("memory not found")
+	// ErrInvalidMemory 记忆内容为空或过长
+	ErrInvalidMemory = errors.New("invalid memory")
+)
+
+// Memory 用户的一条长期记忆，跨对话保留，直到用户删除
+type Memory struct {
+	ID      uint   `json:"id"`
+	UserID  string `json:"-"` // 会话键，记忆只对同一租户下的同一用户可见
+	Content string `json:"content"`
+	Source  string `json:"source"`
+	// 提取记忆的对话和用户消息在对话中的下标，手动添加的记忆没有出处
+	ConversationID string    `json:"conversation_id,omitempty"`
+	MessageIndex   int       `json:"message_index"`
+	Embedding      []float64 `json:"-"` // 内容的向量，用于去重，嵌入器不可用时为空
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Turn 一轮对话，提取的记忆记录该轮对话作为出处
+type Turn struct {
+	UserID         string
+	ConversationID string
+	MessageIndex   int // 用户消息在对话中的下标
+	UserInput      string
+	Reply          string
+}
+
+// extractionPrompt 提取记忆的系统提示词
+const extractionPrompt = `你负责维护用户的长期记忆。阅读下面的一轮对话，找出用户透露的关于自己的、以后的对话仍然有用的事实或偏好，例如身份、所在团队、负责的项目、使用的技术栈、回答风格偏好。
+只提取用户本人明确表达的信息，不要提取一次性的问题、助手的回答或推测的内容。
+每条记忆用一句完整的话描述，以"用户"开头，不超过 100 字。
+只输出 JSON 字符串数组，例如 ["用户在 X 团队工作", "用户希望示例代码使用 Go"]；没有值得记住的信息时输出 []。`
+
+// maxReplyLength 提取记忆时附带的回复的最大字符数，回复只用于理解用户的发言
+const maxReplyLength = 1000
+
+// ExtractionMessages 返回从一轮对话中提取记忆的模型输入
+func ExtractionMessages(turn Turn) []*schema.Message {
+	reply := turn.Reply
+	if utf8.RuneCountInString(reply) > maxReplyLength {
+		reply = string([]rune(reply)[:maxReplyLength]) + "……"
+	}
+	return []*schema.Message{
+		schema.SystemMessage(extractionPrompt),
+		schema.UserMessage("用户：" + turn.UserInput + "\n助手：" + reply),
+	}
+}
+
+// ParseCandidates 解析模型提取的记忆，去掉空白、重复和过长的条目，最多返回 maxCandidates 条
+func ParseCandidates(reply string) ([]string, error) {
+	var items []string
+	if err := json.Unmarshal([]byte(structured.ExtractJSON(reply)), &items); err != nil {
+		return nil, fmt.Errorf("parse extracted memories: %w", err)
+	}
+	seen := make(map[string]bool, len(items))
+	candidates := make([]string, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		key := normalize(item)
+		if key == "" || seen[key] || utf8.RuneCountInString(item) > maxContentLength {
+			continue
+		}
+		seen[key] = true
+		candidates = append(candidates, item)
+		if len(candidates) == maxCandidates {
+			break
+		}
+	}
+	return candidates, nil
+}
+
+// validateContent 校验并返回去掉首尾空白的记忆内容
+func validateContent(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", fmt.Errorf("%w: content is empty", ErrInvalidMemory)
+	}
+	if utf8.RuneCountInString(content) > maxContentLength {
+		return "", fmt.Errorf("%w: content exceeds %d characters", ErrInvalidMemory, maxContentLength)
+	}
+	return content, nil
+}
+
+// normalize 返回用于判断两条记忆文本是否相同的键：忽略大小写、空白和标点
+func normalize(content string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(content) {
+		if strings.ContainsRune(" \t\r\n。，、；：！？,.;:!?\"'“”‘’（）()", r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Store 长期记忆的存储
+type Store interface {
+	// List 按创建顺序列出用户的记忆
+	List(ctx context.Context, userID string) ([]Memory, error)
+	// Get 获取用户的一条记忆
+	Get(ctx context.Context, userID string, id uint) (*Memory, error)
+	// Create 保存新记忆并设置 ID
+	Create(ctx context.Context, memory *Memory) error
+	// Update 修改记忆的内容、来源和向量
+	Update(ctx context.Context, memory *Memory) error
+	// Delete 删除用户的记忆
+	Delete(ctx context.Context, userID string, ids ...uint) error
+	// Clear 删除用户的所有记忆，返回删除的数量
+	Clear(ctx context.Context, userID string) (int64, error)
+}
+
+// memoryRecord 记忆表记录，Embedding 以 JSON 数组保存
+type memoryRecord struct {
+	ID             uint   `gorm:"primaryKey"`
+	UserID         string `gorm:"size:128;index;not null"`
+	Content        string `gorm:"type:text"`
+	Source         string `gorm:"size:16"`
+	ConversationID string `gorm:"size:64"`
+	MessageIndex   int
+	Embedding      string `gorm:"type:text"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (memoryRecord) TableName() string { return "aichat_memories" }
+
+// gormStore 基于GORM的记忆存储，与对话使用同一个数据库
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewStore 基于已有的数据库连接创建记忆存储，并自动迁移表结构
+func NewStore(db *gorm.DB) (Store, error) {
+	if err := db.AutoMigrate(&memoryRecord{}); err != nil {
+		return nil, fmt.Errorf("migrate aichat memory tables: %w", err)
+	}
+	return &gormStore{db: db}, nil
+}
+
+// List 按创建顺序列出用户的记忆
+func (s *gormStore) List(ctx context.Context, userID string) ([]Memory, error) {
+	var records []memoryRecord
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	memories := make([]Memory, 0, len(records))
+	for _, record := range records {
+		memories = append(memories, fromRecord(record))
+	}
+	return memories, nil
+}
+
+// Get 获取用户的一条记忆
+func (s *gormStore) Get(ctx context.Context, userID string, id uint) (*Memory, error) {
+	var record memoryRecord
+	err := s.db.WithContext(ctx).Where("user_id = ? AND id = ?", userID, id).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMemoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	memory := fromRecord(record)
+	return &memory, nil
+}
+
+// Create 保存新记忆
+func (s *gormStore) Create(ctx context.Context, memory *Memory) error {
+	record, err := toRecord(memory)
+	if err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Create(&record).Error; err != nil {
+		return err
+	}
+	memory.ID, memory.CreatedAt, memory.UpdatedAt = record.ID, record.CreatedAt, record.UpdatedAt
+	return nil
+}
+
+// Update 修改记忆的内容、来源和向量
+func (s *gormStore) Update(ctx context.Context, memory *Memory) error {
+	record, err := toRecord(memory)
+	if err != nil {
+		return err
+	}
+	record.UpdatedAt = time.Now()
+	result := s.db.WithContext(ctx).Model(&memoryRecord{}).
+		Where("user_id = ? AND id = ?", memory.UserID, memory.ID).
+		Updates(map[string]any{
+			"content":    record.Content,
+			"source":     record.Source,
+			"embedding":  record.Embedding,
+			"updated_at": record.UpdatedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMemoryNotFound
+	}
+	memory.UpdatedAt = record.UpdatedAt
+	return nil
+}
+
+// Delete 删除用户的记忆，指定的记忆都不存在时返回 ErrMemoryNotFound
+func (s *gormStore) Delete(ctx context.Context, userID string, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	result := s.db.WithContext(ctx).Where("user_id = ? AND id IN ?", userID, ids).Delete(&memoryRecord{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMemoryNotFound
+	}
+	return nil
+}
+
+// Clear 删除用户的所有记忆
+func (s *gormStore) Clear(ctx context.Context, userID string) (int64, error) {
+	result := s.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&memoryRecord{})
+	return result.RowsAffected, result.Error
+}
+
+func toRecord(memory *Memory) (memoryRecord, error) {
+	record := memoryRecord{
+		ID:             memory.ID,
+		UserID:         memory.UserID,
+		Content:        memory.Content,
+		Source:         memory.Source,
+		ConversationID: memory.ConversationID,
+		MessageIndex:   memory.MessageIndex,
+		CreatedAt:      memory.CreatedAt,
+		UpdatedAt:      memory.UpdatedAt,
+	}
+	if len(memory.Embedding) > 0 {
+		data, err := json.Marshal(memory.Embedding)
+		if err != nil {
+			return memoryRecord{}, err
+		}
+		record.Embedding = string(data)
+	}
+	return record, nil
+}
+
+func fromRecord(record memoryRecord) Memory {
+	memory := Memory{
+		ID:             record.ID,
+		UserID:         record.UserID,
+		Content:        record.Content,
+		Source:         record.Source,
+		ConversationID: record.ConversationID,
+		MessageIndex:   record.MessageIndex,
+		CreatedAt:      record.CreatedAt,
+		UpdatedAt:      record.UpdatedAt,
+	}
+	if record.Embedding != "" {
+		// 向量损坏时按没有向量处理，去重退化为文本比较
+		_ = json.Unmarshal([]byte(record.Embedding), &memory.Embedding)
+	}
+	return memory
+}
@@ -24,7 +24,9 @@ import (
 	"strings"
 	"time"
 
+	"weave/config"
 	"weave/pkg"
+	"weave/pkg/resilience"
 	"weave/services/aichat/internal/tool"
 	"weave/services/aichat/internal/tool/mcp/pool"
 
@@ -45,57 +47,9 @@ var mcpConnectionPool *pool.MCPConnectionPool
 // 全局工具健康监控器
 var ToolHealthMonitor *tool.ToolHealthMonitor
 
-// 模型支持工具调用的配置映射
-var (
-	supportedToolCallModels   map[string]bool
-	unsupportedToolCallModels map[string]bool
-)
-
-// 初始化模型支持配置
-func initModelSupportConfig() {
-	// 加载工具调用模型列表
-	supportedModelList := viper.GetString("AICHAT_SUPPORTED_TOOL_CALL_MODELS")
-	unsupportedModelList := viper.GetString("AICHAT_UNSUPPORTED_TOOL_CALL_MODELS")
-
-	// 初始化映射
-	supportedToolCallModels = make(map[string]bool)
-	unsupportedToolCallModels = make(map[string]bool)
-
-	// 解析支持的模型列表
-	if supportedModelList != "" {
-		for _, model := range SplitString(supportedModelList, ",") {
-			if model = TrimSpace(model); model != "" {
-				supportedToolCallModels[model] = true
-			}
-		}
-	}
-
-	// 解析不支持的模型列表
-	if unsupportedModelList != "" {
-		for _, model := range SplitString(unsupportedModelList, ",") {
-			if model = TrimSpace(model); model != "" {
-				unsupportedToolCallModels[model] = true
-			}
-		}
-	}
-}
-
-// SplitString 分割字符串
-func SplitString(s, sep string) []string {
-	if s == "" {
-		return []string{}
-	}
-	return strings.Split(s, sep)
-}
-
-// TrimSpace 去除字符串两端空白
-func TrimSpace(s string) string {
-	return strings.TrimSpace(s)
-}
-
-// createAgent 内部函数：创建并初始化一个React Agent
-// useVisionModel: 是否使用视觉模型
-func createAgent(ctx context.Context, useVisionModel bool) (*react.Agent, error) {
+// createAgent 内部函数：使用模型注册表创建并初始化一个React Agent
+// 注册表按请求的能力要求（图片、工具）和路由规则选择模型，调用失败时自动回退到下一个模型
+func createAgent(ctx context.Context) (*react.Agent, error) {
 	viper.SetConfigFile("../.env")
 	viper.SetConfigType("env")
 	viper.AutomaticEnv()
@@ -106,81 +60,44 @@ func createAgent(ctx context.Context, useVisionModel bool) (*react.Agent, error)
 		logger.Warn("未找到 .env 文件或读取失败，将使用环境变量或默认值", zap.Error(err))
 	}
 
-	// 初始化模型支持配置
-	initModelSupportConfig()
-
 	// 初始化工具健康监控器
 	if ToolHealthMonitor == nil {
 		initToolHealthMonitor()
 	}
 
-	// 初始化模型
-	var llm einomodel.ToolCallingChatModel
-	var err error
-	var modelName string
-
-	// 根据配置类型选择模型
-	modelType := viper.GetString("AICHAT_MODEL_TYPE")
-	if useVisionModel {
-		llm, err = CreateVisionChatModel(ctx, modelType)
-		if modelType == "modelscope" {
-			modelName = viper.GetString("AICHAT_MODELSCOPE_VISUAL_MODEL_NAME")
-			if modelName == "" {
-				modelName = GetModelNameByType(modelType)
-			}
-		} else {
-			modelName = GetModelNameByType(modelType)
-		}
-	} else {
-		llm, err = CreateChatModel(ctx, modelType)
-		modelName = GetModelNameByType(modelType)
-	}
-
+	// 加载模型注册表
+	registry, err := DefaultRegistry(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// 检查模型是否支持工具调用
+	// 有模型支持工具调用时加载工具，不支持的模型被选中时以普通对话模式调用
 	var tools []einotool.BaseTool
-	if isModelSupportToolCall(modelName) {
+	if registry.SupportsToolCalling() {
 		tools = loadTools(ctx)
-		if useVisionModel {
-			logger.Info("视觉模型支持工具调用", zap.String("model_name", modelName), zap.Int("tool_count", len(tools)))
-		} else {
-			logger.Info("当前模型支持工具调用", zap.String("model_name", modelName), zap.Int("tool_count", len(tools)))
-		}
+		logger.Info("模型注册表中有支持工具调用的模型", zap.Int("tool_count", len(tools)))
 	} else {
 		tools = []einotool.BaseTool{}
-		if useVisionModel {
-
-		} else {
-			logger.Info("当前模型不支持工具调用，将以普通对话模式运行", zap.String("model_name", modelName))
-		}
+		logger.Info("模型注册表中没有支持工具调用的模型，将以普通对话模式运行")
 	}
 
 	// 创建React Agent
-	agent, err := react.NewAgent(ctx, &react.AgentConfig{
+	return NewAgent(ctx, registry.ChatModel(), tools)
+}
+
+// NewAgent 使用指定的聊天模型和工具创建React Agent
+func NewAgent(ctx context.Context, llm einomodel.ToolCallingChatModel, tools []einotool.BaseTool) (*react.Agent, error) {
+	return react.NewAgent(ctx, &react.AgentConfig{
 		ToolCallingModel: llm,
 		ToolsConfig: compose.ToolsNodeConfig{
 			Tools: tools,
 		},
 	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return agent, nil
 }
 
 // CreateAgent 创建并初始化一个React Agent
 func CreateAgent(ctx context.Context) (*react.Agent, error) {
-	return createAgent(ctx, false)
-}
-
-// CreateModelScopeVisionAgent 创建并初始化一个支持图像分析的React Agent
-func CreateModelScopeVisionAgent(ctx context.Context) (*react.Agent, error) {
-	return createAgent(ctx, true)
+	return createAgent(ctx)
 }
 
 // loadTools 加载所有可用的工具
@@ -229,7 +146,7 @@ func loadMCPTools(ctx context.Context) []einotool.BaseTool {
 				continue
 			}
 			toolNameSet[name] = struct{}{}
-			allMcpTools = append(allMcpTools, t)
+			allMcpTools = append(allMcpTools, pool.WrapTool(mcpDependency(id), t))
 			count++
 		}
 		if count > 0 {
@@ -277,7 +194,7 @@ func loadMCPTools(ctx context.Context) []einotool.BaseTool {
 					timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
 					defer cancel()
 
-					conn, err := mcpConnectionPool.GetConnection(timeoutCtx, mcpPathAbs)
+					conn, err := getMCPConnection(timeoutCtx, serviceName, mcpPathAbs)
 					if err != nil {
 						logger.Error("获取MCP连接失败", zap.String("service", serviceName), zap.Error(err))
 						continue
@@ -322,7 +239,7 @@ func loadMCPTools(ctx context.Context) []einotool.BaseTool {
 				initMCPConnectionPool()
 			}
 
-			conn, err := mcpConnectionPool.GetConnection(timeoutCtx, url)
+			conn, err := getMCPConnection(timeoutCtx, name, url)
 			if err != nil {
 				logger.Error("获取HTTP MCP连接失败", zap.String("name", name), zap.String("url", url), zap.Error(err))
 				continue
@@ -359,7 +276,7 @@ func loadMCPTools(ctx context.Context) []einotool.BaseTool {
 				initMCPConnectionPool()
 			}
 
-			conn, err := mcpConnectionPool.GetConnection(timeoutCtx, url)
+			conn, err := getMCPConnection(timeoutCtx, name, url)
 			if err != nil {
 				logger.Error("获取SSE MCP连接失败", zap.String("name", name), zap.String("url", url), zap.Error(err))
 				continue
@@ -405,6 +322,18 @@ func loadMCPToolsFromClient(ctx context.Context, cli *client.Client, initRequest
 	return tools, nil
 }
 
+// mcpDependency 返回MCP服务对应的依赖，每个服务独立熔断
+func mcpDependency(name string) *resilience.Dependency {
+	return resilience.Get(config.DependencyMCP + ":" + name)
+}
+
+// getMCPConnection 通过MCP服务依赖的容错策略获取连接
+func getMCPConnection(ctx context.Context, name, serverURL string) (*pool.MCPConnection, error) {
+	return resilience.Call(ctx, mcpDependency(name), func(ctx context.Context) (*pool.MCPConnection, error) {
+		return mcpConnectionPool.GetConnection(ctx, serverURL)
+	})
+}
+
 // initMCPConnectionPool 初始化MCP连接池
 func initMCPConnectionPool() {
 	logger := pkg.GetLogger()
@@ -443,16 +372,3 @@ func initToolHealthMonitor() {
 		zap.Duration("check_interval", checkInterval))
 }
 
-// isModelSupportToolCall 检查模型是否支持工具调用
-func isModelSupportToolCall(modelName string) bool {
-	// 检索列表
-	if unsupportedToolCallModels != nil && unsupportedToolCallModels[modelName] {
-		return false
-	}
-	// 再检查支持的列表
-	if supportedToolCallModels != nil && supportedToolCallModels[modelName] {
-		return true
-	}
-	// 默认不支持
-	return false
-}
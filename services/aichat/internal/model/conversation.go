@@ -18,27 +18,45 @@ package model
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"weave/pkg"
+
 	"github.com/cloudwego/eino/schema"
 )
 
+// ErrConversationNotFound 对话不存在或不属于当前用户
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// 滚动摘要保存在对话元数据中的键，实体和决定为 JSON 字符串数组
+const (
+	MetadataSummaryEntities    = "summary_entities"
+	MetadataSummaryDecisions   = "summary_decisions"
+	MetadataSummarizedMessages = "summary_messages" // 已折叠进摘要的消息数
+)
+
 // Conversation 对话结构体，用于结构化管理对话历史
+// JSON字段名与旧版本（无标签）缓存数据大小写不敏感匹配，旧数据可以直接读取
 type Conversation struct {
-	ID        string            // 对话 ID
-	UserID    string            // 用户 ID
-	StartTime time.Time         // 开始时间
-	EndTime   time.Time         // 结束时间
-	Messages  []*schema.Message // 消息列表
-	Metadata  map[string]string // 元数据（如意图、标签、核心实体）
-	Summary   string            // 对话摘要
+	ID        string            `json:"id"`                   // 对话 ID
+	UserID    string            `json:"user_id"`              // 用户 ID（按租户隔离的会话键）
+	Title     string            `json:"title"`                // 对话标题，未设置时根据首条用户消息生成
+	StartTime time.Time         `json:"start_time"`           // 开始时间
+	EndTime   time.Time         `json:"end_time"`             // 结束时间（最后活动时间）
+	Messages  []*schema.Message `json:"messages"`             // 消息列表
+	Metadata  map[string]string `json:"metadata"`             // 元数据（如意图、标签、核心实体）
+	Summary   string            `json:"summary"`              // 对话摘要
+	Archived  bool              `json:"archived"`             // 是否已归档
+	ParentID  string            `json:"parent_id,omitempty"`  // 分叉来源对话 ID
+	ForkIndex int               `json:"fork_index,omitempty"` // 分叉点：来源对话中保留到的消息序号（含）
 }
 
 // NewConversation 创建新的对话实例
 func NewConversation(userID string) *Conversation {
 	now := time.Now()
 	return &Conversation{
-		ID:        userID + "_" + now.Format("20060102150405"),
+		ID:        "conv_" + now.Format("20060102150405") + "_" + pkg.RandomString(8),
 		UserID:    userID,
 		StartTime: now,
 		EndTime:   now,
@@ -96,18 +114,45 @@ func (c *Conversation) SetSummary(summary string) {
 	c.Summary = summary
 }
 
+// ConversationFilter 对话列表的筛选条件
+type ConversationFilter struct {
+	// 是否只列出归档对话；为 nil 时列出全部
+	Archived *bool
+	// 分页参数，Limit 为 0 时不限制
+	Limit  int
+	Offset int
+}
+
+// ConversationSearchResult 对话搜索结果
+type ConversationSearchResult struct {
+	Conversation *Conversation `json:"conversation"`
+	// 命中的消息序号，标题或摘要命中时为 -1
+	MessageIndex int `json:"message_index"`
+	// 命中位置附近的文本片段
+	Snippet string `json:"snippet"`
+}
+
 // ConversationManager 对话管理器接口
+// 所有按 ID 访问的操作都校验对话属于 userID，不属于时与不存在一样返回 ErrConversationNotFound
 type ConversationManager interface {
-	// GetConversations 获取用户的所有对话
-	GetConversations(ctx interface{}, userID string) ([]*Conversation, error)
-	// GetConversation 获取指定对话
-	GetConversation(ctx interface{}, conversationID string) (*Conversation, error)
-	// CreateConversation 创建新对话
-	CreateConversation(ctx interface{}, userID string) (*Conversation, error)
+	// CreateConversation 创建新对话，title 为空时在首条消息后自动生成
+	CreateConversation(ctx context.Context, userID, title string) (*Conversation, error)
+	// GetConversation 获取指定对话（包含消息）
+	GetConversation(ctx context.Context, userID, conversationID string) (*Conversation, error)
+	// ListConversations 按最后活动时间倒序列出用户的对话（不包含消息）
+	ListConversations(ctx context.Context, userID string, filter ConversationFilter) ([]*Conversation, int64, error)
 	// SaveConversation 保存对话
-	SaveConversation(ctx interface{}, conversation *Conversation) error
-	// AddMessageToConversation 添加消息到对话
-	AddMessageToConversation(ctx interface{}, conversationID string, message *schema.Message) error
+	SaveConversation(ctx context.Context, conversation *Conversation) error
+	// RenameConversation 修改对话标题
+	RenameConversation(ctx context.Context, userID, conversationID, title string) (*Conversation, error)
+	// ArchiveConversation 归档或取消归档对话
+	ArchiveConversation(ctx context.Context, userID, conversationID string, archived bool) (*Conversation, error)
+	// DeleteConversation 删除对话
+	DeleteConversation(ctx context.Context, userID, conversationID string) error
+	// ForkConversation 从指定消息（含）处分叉出新对话
+	ForkConversation(ctx context.Context, userID, conversationID string, messageIndex int) (*Conversation, error)
+	// SearchConversations 在用户的对话标题、摘要和消息中搜索
+	SearchConversations(ctx context.Context, userID, query string, limit int) ([]ConversationSearchResult, error)
 	// Close 关闭管理器
 	Close() error
 }
@@ -8,6 +8,9 @@ import (
 	"io"
 	"net/http"
 
+	"weave/config"
+	"weave/pkg/resilience"
+
 	"github.com/cloudwego/eino/components/embedding"
 	"github.com/spf13/viper"
 )
@@ -31,7 +34,11 @@ type ModelScopeEmbedResponse struct {
 }
 
 func NewModelScopeEmbedder(ctx context.Context) (embedding.Embedder, error) {
-	apiKey := viper.GetString("AICHAT_MODELSCOPE_API_KEY")
+	// API Key 支持 ${env:X}、${file:path}、${enc:...} 等密钥引用
+	apiKey, err := config.ResolveSecretRefs(viper.GetString("AICHAT_MODELSCOPE_API_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("AICHAT_MODELSCOPE_API_KEY: %w", err)
+	}
 	embedModel := viper.GetString("AICHAT_MODELSCOPE_EMBED_MODEL_NAME")
 
 	if apiKey == "" {
@@ -41,7 +48,7 @@ func NewModelScopeEmbedder(ctx context.Context) (embedding.Embedder, error) {
 	return &ModelScopeEmbedder{
 		apiKey:     apiKey,
 		embedModel: embedModel,
-		client:     &http.Client{},
+		client:     resilience.NewHTTPClient(config.DependencyEmbedding),
 	}, nil
 }
 
@@ -8,6 +8,9 @@ import (
 	"io"
 	"net/http"
 
+	"weave/config"
+	"weave/pkg/resilience"
+
 	"github.com/cloudwego/eino/components/embedding"
 	"github.com/spf13/viper"
 )
@@ -38,7 +41,7 @@ func NewOllamaEmbedder(ctx context.Context) (embedding.Embedder, error) {
 	return &OllamaEmbedder{
 		baseURL:    baseURL,
 		embedModel: embedModel,
-		client:     &http.Client{},
+		client:     resilience.NewHTTPClient(config.DependencyEmbedding),
 	}, nil
 }
 
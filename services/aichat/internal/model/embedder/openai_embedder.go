@@ -8,6 +8,9 @@ import (
 	"io"
 	"net/http"
 
+	"weave/config"
+	"weave/pkg/resilience"
+
 	"github.com/cloudwego/eino/components/embedding"
 	"github.com/spf13/viper"
 )
@@ -30,7 +33,11 @@ type OpenAIEmbedResponse struct {
 }
 
 func NewOpenAIEmbedder(ctx context.Context) (embedding.Embedder, error) {
-	apiKey := viper.GetString("AICHAT_OPENAI_API_KEY")
+	// API Key 支持 ${env:X}、${file:path}、${enc:...} 等密钥引用
+	apiKey, err := config.ResolveSecretRefs(viper.GetString("AICHAT_OPENAI_API_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("AICHAT_OPENAI_API_KEY: %w", err)
+	}
 	embedModel := viper.GetString("AICHAT_OPENAI_EMBED_MODEL")
 
 	if apiKey == "" {
@@ -40,7 +47,7 @@ func NewOpenAIEmbedder(ctx context.Context) (embedding.Embedder, error) {
 	return &OpenAIEmbedder{
 		apiKey:     apiKey,
 		embedModel: embedModel,
-		client:     &http.Client{},
+		client:     resilience.NewHTTPClient(config.DependencyEmbedding),
 	}, nil
 }
 
@@ -20,13 +20,20 @@ import (
 	"context"
 	"fmt"
 
+	"weave/config"
+	"weave/pkg/resilience"
+
 	"github.com/cloudwego/eino-ext/components/model/openai"
 	einomodel "github.com/cloudwego/eino/components/model"
 	"github.com/spf13/viper"
 )
 
 func createModelScopeChatModel(ctx context.Context, useVisionModel bool) (einomodel.ToolCallingChatModel, error) {
-	apiKey := viper.GetString("AICHAT_MODELSCOPE_API_KEY")
+	// API Key 支持 ${env:X}、${file:path}、${enc:...} 等密钥引用
+	apiKey, err := config.ResolveSecretRefs(viper.GetString("AICHAT_MODELSCOPE_API_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("AICHAT_MODELSCOPE_API_KEY: %w", err)
+	}
 	var modelName string
 
 	rerankModelName := viper.GetString("AICHAT_RERANK_MODELSCOPE_MODEL_NAME")
@@ -53,6 +60,8 @@ func createModelScopeChatModel(ctx context.Context, useVisionModel bool) (einomo
 		BaseURL: baseURL,
 		Model:   modelName,
 		APIKey:  apiKey,
+		// 通过LLM依赖的重试、熔断和并发隔离发送请求
+		HTTPClient: resilience.NewHTTPClient(config.DependencyLLM),
 	})
 	if err != nil {
 		return nil, err
@@ -20,6 +20,9 @@ import (
 	"context"
 	"fmt"
 
+	"weave/config"
+	"weave/pkg/resilience"
+
 	"github.com/cloudwego/eino-ext/components/model/ollama"
 	einomodel "github.com/cloudwego/eino/components/model"
 	"github.com/spf13/viper"
@@ -36,6 +39,8 @@ func CreateOllamaChatModel(ctx context.Context) (einomodel.ToolCallingChatModel,
 	chatModel, err := ollama.NewChatModel(ctx, &ollama.ChatModelConfig{
 		BaseURL: baseURL,
 		Model:   modelName,
+		// 通过LLM依赖的重试、熔断和并发隔离发送请求
+		HTTPClient: resilience.NewHTTPClient(config.DependencyLLM),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create ollama chat model failed: %w", err)
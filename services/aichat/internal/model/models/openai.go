@@ -20,13 +20,20 @@ import (
 	"context"
 	"fmt"
 
+	"weave/config"
+	"weave/pkg/resilience"
+
 	"github.com/cloudwego/eino-ext/components/model/openai"
 	einomodel "github.com/cloudwego/eino/components/model"
 	"github.com/spf13/viper"
 )
 
 func CreateOpenAIChatModel(ctx context.Context) (einomodel.ToolCallingChatModel, error) {
-	key := viper.GetString("AICHAT_OPENAI_API_KEY")
+	// API Key 支持 ${env:X}、${file:path}、${enc:...} 等密钥引用
+	key, err := config.ResolveSecretRefs(viper.GetString("AICHAT_OPENAI_API_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("AICHAT_OPENAI_API_KEY: %w", err)
+	}
 	modelName := viper.GetString("AICHAT_OPENAI_MODEL_NAME")
 	baseURL := viper.GetString("AICHAT_OPENAI_BASE_URL")
 
@@ -38,6 +45,8 @@ func CreateOpenAIChatModel(ctx context.Context) (einomodel.ToolCallingChatModel,
 		BaseURL: baseURL,
 		Model:   modelName,
 		APIKey:  key,
+		// 通过LLM依赖的重试、熔断和并发隔离发送请求
+		HTTPClient: resilience.NewHTTPClient(config.DependencyLLM),
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"weave/pkg/resilience"
+
+	"github.com/cloudwego/eino-ext/components/model/ollama"
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	einomodel "github.com/cloudwego/eino/components/model"
+)
+
+// 支持的模型提供方
+const (
+	ProviderOpenAI     = "openai"
+	ProviderModelScope = "modelscope"
+	ProviderOllama     = "ollama"
+)
+
+// ProviderConfig 创建单个聊天模型的配置，由模型注册表提供
+type ProviderConfig struct {
+	// 提供方：openai、modelscope（OpenAI 兼容接口）或 ollama
+	Provider string
+	BaseURL  string
+	// 提供方的模型名称
+	Model  string
+	APIKey string
+	// 请求经过的容错依赖名称，每个模型单独熔断
+	Dependency string
+}
+
+// NewChatModel 按提供方创建聊天模型
+func NewChatModel(ctx context.Context, cfg ProviderConfig) (einomodel.ToolCallingChatModel, error) {
+	if cfg.BaseURL == "" || cfg.Model == "" {
+		return nil, fmt.Errorf("模型 %s 缺少 baseURL 或 model 配置", cfg.Model)
+	}
+	switch cfg.Provider {
+	case ProviderOpenAI, ProviderModelScope:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("模型 %s 缺少 apiKey 配置", cfg.Model)
+		}
+		return openai.NewChatModel(ctx, &openai.ChatModelConfig{
+			BaseURL:    cfg.BaseURL,
+			Model:      cfg.Model,
+			APIKey:     cfg.APIKey,
+			HTTPClient: resilience.NewHTTPClient(cfg.Dependency),
+		})
+	case ProviderOllama:
+		chatModel, err := ollama.NewChatModel(ctx, &ollama.ChatModelConfig{
+			BaseURL:    cfg.BaseURL,
+			Model:      cfg.Model,
+			HTTPClient: resilience.NewHTTPClient(cfg.Dependency),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create ollama chat model failed: %w", err)
+		}
+		return chatModel, nil
+	default:
+		return nil, fmt.Errorf("不支持的模型类型: %s", cfg.Provider)
+	}
+}
+
+// ResponseFormatOption 返回要求模型按 JSON Schema 输出的请求选项
+// 只有 OpenAI 兼容接口支持按请求指定 response_format，其他提供方返回 false，由调用方通过提示词约束输出
+func ResponseFormatOption(provider, name string, schema json.RawMessage) (einomodel.Option, bool) {
+	switch provider {
+	case ProviderOpenAI, ProviderModelScope:
+		return openai.WithExtraFields(map[string]any{
+			"response_format": map[string]any{
+				"type": "json_schema",
+				"json_schema": map[string]any{
+					"name":   name,
+					"schema": schema,
+				},
+			},
+		}), true
+	default:
+		return einomodel.Option{}, false
+	}
+}
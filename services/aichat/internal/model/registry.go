@@ -0,0 +1,388 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"weave/config"
+	"weave/pkg"
+	"weave/pkg/resilience"
+	"weave/services/aichat/internal/model/models"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// 路由偏好
+const (
+	// PreferencePriority 按注册表中的顺序选择模型（默认）
+	PreferencePriority = "priority"
+	// PreferenceCost 优先选择每千令牌价格最低的模型
+	PreferenceCost = "cost"
+	// PreferenceLatency 优先选择观测延迟（没有观测数据时为配置的预估延迟）最低的模型
+	PreferenceLatency = "latency"
+)
+
+// ErrModelNotFound 请求指定的模型不在注册表中
+var ErrModelNotFound = errors.New("model not found in registry")
+
+// ModelSpec 注册表中的一个模型及其能力
+type ModelSpec struct {
+	// 请求中使用的模型名称，在注册表中唯一
+	Name string
+	// 提供方：openai、modelscope 或 ollama
+	Provider string
+	// 提供方的模型名称，为空时使用 Name
+	Model   string
+	BaseURL string
+	// 支持 ${env:X}、${file:path}、${enc:...} 等密钥引用
+	APIKey string
+	// 是否能处理图片输入
+	Vision bool
+	// 是否支持工具调用，不支持时 Agent 以普通对话模式调用该模型
+	ToolCalling bool
+	// 是否支持按 JSON Schema 约束输出（response_format），仅对 openai 和 modelscope 提供方有效；
+	// 不支持时结构化输出请求只通过提示词约束格式
+	StructuredOutput bool
+	// 上下文窗口的令牌数，0 表示不限制；预估的输入超过窗口时跳过该模型
+	ContextWindow int
+	// 每千个输入/输出令牌的价格，用于成本优先路由和用量费用统计
+	InputCostPer1K  float64
+	OutputCostPer1K float64
+	// 预估延迟（毫秒），在还没有观测数据时用于延迟优先路由
+	LatencyMs int
+	// 是否停用，停用的模型不参与路由
+	Disabled bool
+}
+
+// RoutingConfig 路由规则
+type RoutingConfig struct {
+	// 路由偏好：priority（默认）、cost 或 latency
+	Preference string
+	// 首选模型失败后最多再尝试几个模型，0 表示尝试所有候选模型
+	MaxFallbacks int
+}
+
+// RegistryConfig 模型注册表配置
+type RegistryConfig struct {
+	Models  []ModelSpec
+	Routing RoutingConfig
+}
+
+// Validate 校验注册表配置
+func (c RegistryConfig) Validate() error {
+	if len(c.Models) == 0 {
+		return errors.New("模型注册表至少需要一个模型")
+	}
+	seen := make(map[string]bool, len(c.Models))
+	for _, m := range c.Models {
+		if m.Name == "" {
+			return errors.New("模型必须指定名称")
+		}
+		if seen[m.Name] {
+			return fmt.Errorf("模型名称重复: %s", m.Name)
+		}
+		seen[m.Name] = true
+		switch m.Provider {
+		case models.ProviderOpenAI, models.ProviderModelScope, models.ProviderOllama:
+		default:
+			return fmt.Errorf("模型 '%s' 的提供方无效: %s", m.Name, m.Provider)
+		}
+		if m.ContextWindow < 0 || m.LatencyMs < 0 || m.InputCostPer1K < 0 || m.OutputCostPer1K < 0 {
+			return fmt.Errorf("模型 '%s' 的上下文窗口、延迟和价格不能为负数", m.Name)
+		}
+	}
+	switch c.Routing.Preference {
+	case "", PreferencePriority, PreferenceCost, PreferenceLatency:
+	default:
+		return fmt.Errorf("无效的路由偏好: %s", c.Routing.Preference)
+	}
+	if c.Routing.MaxFallbacks < 0 {
+		return fmt.Errorf("最大回退次数不能为负数: %d", c.Routing.MaxFallbacks)
+	}
+	return nil
+}
+
+// ChatModelFactory 根据模型配置创建聊天模型
+type ChatModelFactory func(ctx context.Context, spec ModelSpec) (einomodel.ToolCallingChatModel, error)
+
+// entry 注册表中已创建的模型
+type entry struct {
+	spec       ModelSpec
+	llm        einomodel.ToolCallingChatModel
+	dependency string
+	stats      *latencyStats
+}
+
+// Registry 模型注册表：保存可用模型及其能力，按路由规则选择模型，配置文件变更时自动重新加载
+type Registry struct {
+	mu      sync.RWMutex
+	entries []*entry
+	byName  map[string]*entry
+	routing RoutingConfig
+	factory ChatModelFactory
+	logger  *zap.Logger
+}
+
+// NewRegistry 使用指定的工厂创建模型注册表，factory 为 nil 时按提供方创建模型
+// 单个模型创建失败时跳过该模型，所有模型都创建失败时返回错误
+func NewRegistry(ctx context.Context, cfg RegistryConfig, factory ChatModelFactory) (*Registry, error) {
+	if factory == nil {
+		factory = newProviderChatModel
+	}
+	r := &Registry{factory: factory, logger: pkg.GetLogger()}
+	if err := r.Reload(ctx, cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 按新配置重建注册表，配置无效或没有可用模型时保留原注册表
+// 同名模型保留延迟观测数据
+func (r *Registry) Reload(ctx context.Context, cfg RegistryConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	previous := r.byName
+	r.mu.RUnlock()
+
+	entries := make([]*entry, 0, len(cfg.Models))
+	byName := make(map[string]*entry, len(cfg.Models))
+	for _, spec := range cfg.Models {
+		if spec.Model == "" {
+			spec.Model = spec.Name
+		}
+		llm, err := r.factory(ctx, spec)
+		if err != nil {
+			r.logger.Warn("创建模型失败，已从注册表中跳过", zap.String("model", spec.Name), zap.Error(err))
+			continue
+		}
+		e := &entry{spec: spec, llm: llm, dependency: config.DependencyLLM + ":" + spec.Name, stats: &latencyStats{}}
+		if old, ok := previous[spec.Name]; ok {
+			e.stats = old.stats
+		}
+		entries = append(entries, e)
+		byName[spec.Name] = e
+	}
+	if len(entries) == 0 {
+		return errors.New("模型注册表中没有可用的模型")
+	}
+
+	r.mu.Lock()
+	r.entries = entries
+	r.byName = byName
+	r.routing = cfg.Routing
+	r.mu.Unlock()
+	r.logger.Info("模型注册表已加载", zap.Int("model_count", len(entries)), zap.String("preference", cfg.Routing.Preference))
+	return nil
+}
+
+// Models 按注册表顺序返回可用模型
+func (r *Registry) Models() []ModelSpec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	specs := make([]ModelSpec, 0, len(r.entries))
+	for _, e := range r.entries {
+		if !e.spec.Disabled {
+			specs = append(specs, e.spec)
+		}
+	}
+	return specs
+}
+
+// Get 返回指定名称的模型
+func (r *Registry) Get(name string) (ModelSpec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.byName[name]
+	if !ok || e.spec.Disabled {
+		return ModelSpec{}, false
+	}
+	return e.spec, true
+}
+
+// SupportsToolCalling 是否有模型支持工具调用，没有时 Agent 不加载工具
+func (r *Registry) SupportsToolCalling() bool {
+	for _, spec := range r.Models() {
+		if spec.ToolCalling {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckBreakers 所有可用模型的熔断器都打开时返回错误，用于上游模型服务的健康检查
+func (r *Registry) CheckBreakers(context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	open := make([]string, 0, len(r.entries))
+	for _, e := range r.entries {
+		if e.spec.Disabled {
+			continue
+		}
+		if resilience.Get(e.dependency).Breaker().State() != resilience.StateOpen {
+			return nil
+		}
+		open = append(open, e.spec.Name)
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", strings.Join(open, ", "), resilience.ErrCircuitOpen)
+}
+
+// ChatModel 返回按路由规则选择模型并自动回退的聊天模型
+func (r *Registry) ChatModel() einomodel.ToolCallingChatModel {
+	return &routedChatModel{registry: r}
+}
+
+// Watch 监听注册表配置文件，变更后重新加载；返回的函数用于停止处理后续变更
+func (r *Registry) Watch(ctx context.Context, path string) (func(), error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("读取模型注册表配置失败: %w", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		timer   *time.Timer
+		stopped bool
+	)
+	// 编辑器保存文件时可能触发多次事件，合并为一次重新加载
+	v.OnConfigChange(func(fsnotify.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(500*time.Millisecond, func() {
+			cfg, err := ReadRegistryFile(path)
+			if err == nil {
+				err = r.Reload(ctx, cfg)
+			}
+			if err != nil {
+				r.logger.Error("重新加载模型注册表失败，继续使用原配置", zap.String("path", path), zap.Error(err))
+			}
+		})
+	})
+	v.WatchConfig()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		if timer != nil {
+			timer.Stop()
+		}
+	}, nil
+}
+
+// ReadRegistryFile 读取模型注册表配置文件（YAML 或 JSON）
+func ReadRegistryFile(path string) (RegistryConfig, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return RegistryConfig{}, fmt.Errorf("读取模型注册表配置失败: %w", err)
+	}
+	var cfg RegistryConfig
+	if err := v.UnmarshalKey("models", &cfg.Models); err != nil {
+		return RegistryConfig{}, fmt.Errorf("解析模型列表失败: %w", err)
+	}
+	if err := v.UnmarshalKey("routing", &cfg.Routing); err != nil {
+		return RegistryConfig{}, fmt.Errorf("解析路由规则失败: %w", err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// RegistryConfigFromEnv 根据 AICHAT_MODEL_TYPE 等单模型配置生成注册表配置
+// 工具调用能力取自 AICHAT_MODEL_TOOL_CALLING 和 AICHAT_MODELSCOPE_VISUAL_MODEL_TOOL_CALLING；未配置视觉模型时主模型同时处理图片
+func RegistryConfigFromEnv() RegistryConfig {
+	modelType := viper.GetString("AICHAT_MODEL_TYPE")
+	spec := ModelSpec{Name: GetModelNameByType(modelType), Provider: modelType, Vision: true}
+	switch modelType {
+	case models.ProviderOpenAI:
+		spec.BaseURL = viper.GetString("AICHAT_OPENAI_BASE_URL")
+		spec.APIKey = viper.GetString("AICHAT_OPENAI_API_KEY")
+	case models.ProviderModelScope:
+		spec.BaseURL = viper.GetString("AICHAT_MODELSCOPE_BASE_URL")
+		spec.APIKey = viper.GetString("AICHAT_MODELSCOPE_API_KEY")
+	case models.ProviderOllama:
+		spec.BaseURL = viper.GetString("AICHAT_OLLAMA_BASE_URL")
+	}
+	spec.ToolCalling = viper.GetBool("AICHAT_MODEL_TOOL_CALLING")
+	spec.StructuredOutput = modelType == models.ProviderOpenAI
+
+	cfg := RegistryConfig{Models: []ModelSpec{spec}}
+	if visionName := viper.GetString("AICHAT_MODELSCOPE_VISUAL_MODEL_NAME"); modelType == models.ProviderModelScope && visionName != "" && visionName != spec.Name {
+		cfg.Models[0].Vision = false
+		vision := spec
+		vision.Name = visionName
+		vision.Vision = true
+		vision.ToolCalling = viper.GetBool("AICHAT_MODELSCOPE_VISUAL_MODEL_TOOL_CALLING")
+		cfg.Models = append(cfg.Models, vision)
+	}
+	return cfg
+}
+
+// newProviderChatModel 按模型配置中的提供方创建聊天模型，每个模型使用单独的容错依赖
+func newProviderChatModel(ctx context.Context, spec ModelSpec) (einomodel.ToolCallingChatModel, error) {
+	key, err := config.ResolveSecretRefs(spec.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("模型 %s 的 apiKey: %w", spec.Name, err)
+	}
+	return models.NewChatModel(ctx, models.ProviderConfig{
+		Provider:   spec.Provider,
+		BaseURL:    spec.BaseURL,
+		Model:      spec.Model,
+		APIKey:     key,
+		Dependency: config.DependencyLLM + ":" + spec.Name,
+	})
+}
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryErr  error
+	defaultRegistryOnce sync.Once
+)
+
+// DefaultRegistry 返回全局模型注册表，首次调用时加载
+// 配置了 AICHAT_MODELS_FILE 时从该文件加载并监听变更，否则根据 AICHAT_MODEL_TYPE 等单模型配置生成
+func DefaultRegistry(ctx context.Context) (*Registry, error) {
+	defaultRegistryOnce.Do(func() {
+		path := viper.GetString("AICHAT_MODELS_FILE")
+		if path == "" {
+			defaultRegistry, defaultRegistryErr = NewRegistry(ctx, RegistryConfigFromEnv(), nil)
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			defaultRegistryErr = fmt.Errorf("模型注册表配置文件不可用: %w", err)
+			return
+		}
+		cfg, err := ReadRegistryFile(path)
+		if err != nil {
+			defaultRegistryErr = err
+			return
+		}
+		if defaultRegistry, defaultRegistryErr = NewRegistry(ctx, cfg, nil); defaultRegistryErr != nil {
+			return
+		}
+		// 注册表在进程生命周期内持续监听，不需要停止
+		if _, err := defaultRegistry.Watch(context.Background(), path); err != nil {
+			defaultRegistry.logger.Warn("监听模型注册表配置失败，修改后需要重启", zap.Error(err))
+		}
+	})
+	return defaultRegistry, defaultRegistryErr
+}
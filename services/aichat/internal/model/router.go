@@ -0,0 +1,373 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"weave/pkg/resilience"
+	"weave/services/aichat/internal/model/models"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"go.uber.org/zap"
+)
+
+// ErrNoModelAvailable 没有满足能力要求且熔断器未打开的模型
+var ErrNoModelAvailable = errors.New("no model available for the request")
+
+// ErrToolsUnsupported 请求绑定了工具，但注册表中没有支持工具调用的模型
+var ErrToolsUnsupported = fmt.Errorf("%w: no model supports tool calling", ErrNoModelAvailable)
+
+// latencyAlpha 延迟指数移动平均的平滑系数
+const latencyAlpha = 0.3
+
+// Route 单次请求的模型路由选项，通过 WithRoute 放入上下文
+type Route struct {
+	// 指定的模型名称，为空时按路由规则选择；指定的模型失败时仍会回退到其他模型
+	Model string
+	// 覆盖注册表的路由偏好：priority、cost 或 latency
+	Preference string
+	// 要求按 JSON Schema 输出，支持结构化输出的模型通过 response_format 约束输出并优先被选中
+	ResponseFormat *ResponseFormat
+
+	mu     sync.Mutex
+	served string
+}
+
+// Served 返回最近一次实际响应请求的模型名称
+func (r *Route) Served() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.served
+}
+
+func (r *Route) setServed(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.served = name
+}
+
+// ResponseFormat 结构化输出的 JSON Schema
+type ResponseFormat struct {
+	// 模式名称，提供方要求由字母、数字、下划线和连字符组成
+	Name   string
+	Schema json.RawMessage
+}
+
+type routeKey struct{}
+
+// WithRoute 将路由选项放入上下文，注册表的聊天模型按该选项选择模型
+func WithRoute(ctx context.Context, route *Route) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// RouteFromContext 返回上下文中的路由选项，没有时返回 nil
+func RouteFromContext(ctx context.Context) *Route {
+	route, _ := ctx.Value(routeKey{}).(*Route)
+	return route
+}
+
+// latencyStats 模型调用延迟的指数移动平均，流式调用记录首个分块的延迟
+type latencyStats struct {
+	mu   sync.Mutex
+	ewma float64
+}
+
+func (s *latencyStats) observe(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := float64(d) / float64(time.Millisecond)
+	if s.ewma == 0 {
+		s.ewma = ms
+		return
+	}
+	s.ewma = latencyAlpha*ms + (1-latencyAlpha)*s.ewma
+}
+
+func (s *latencyStats) value() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ewma
+}
+
+// requirements 请求对模型能力的要求
+type requirements struct {
+	vision bool
+	tools  bool
+	input  []*schema.Message
+}
+
+// requirementsOf 根据输入消息和绑定的工具推断能力要求
+func requirementsOf(input []*schema.Message, tools []*schema.ToolInfo) requirements {
+	req := requirements{tools: len(tools) > 0, input: input}
+	for _, msg := range input {
+		for _, part := range msg.UserInputMultiContent {
+			if part.Type == schema.ChatMessagePartTypeImageURL {
+				req.vision = true
+			}
+		}
+	}
+	return req
+}
+
+// candidates 按路由规则返回候选模型：
+// 先过滤掉停用、缺少视觉或工具调用能力、上下文窗口不足和熔断器打开的模型，再按偏好排序；
+// 要求结构化输出时支持 response_format 的模型排在前面，请求指定的模型排在最前
+func (r *Registry) candidates(route *Route, req requirements) ([]*entry, error) {
+	r.mu.RLock()
+	entries := append([]*entry(nil), r.entries...)
+	routing := r.routing
+	r.mu.RUnlock()
+
+	if route != nil && route.Model != "" {
+		if _, ok := r.Get(route.Model); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrModelNotFound, route.Model)
+		}
+	}
+
+	eligible := make([]*entry, 0, len(entries))
+	toolCapable := false
+	for _, e := range entries {
+		if !e.spec.Disabled && e.spec.ToolCalling {
+			toolCapable = true
+		}
+		switch {
+		case e.spec.Disabled:
+		case req.tools && !e.spec.ToolCalling:
+		case req.vision && !e.spec.Vision:
+		case e.spec.ContextWindow > 0 && TokenizerFor(e.spec.Model).CountMessages(req.input) > e.spec.ContextWindow:
+		case resilience.Get(e.dependency).Breaker().State() == resilience.StateOpen:
+		default:
+			eligible = append(eligible, e)
+		}
+	}
+	if len(eligible) == 0 {
+		if req.tools && !toolCapable {
+			return nil, ErrToolsUnsupported
+		}
+		return nil, ErrNoModelAvailable
+	}
+
+	preference := routing.Preference
+	if route != nil && route.Preference != "" {
+		preference = route.Preference
+	}
+	switch preference {
+	case PreferenceCost:
+		sort.SliceStable(eligible, func(i, j int) bool { return cost(eligible[i].spec) < cost(eligible[j].spec) })
+	case PreferenceLatency:
+		sort.SliceStable(eligible, func(i, j int) bool { return latency(eligible[i]) < latency(eligible[j]) })
+	}
+	if route != nil && route.ResponseFormat != nil {
+		sort.SliceStable(eligible, func(i, j int) bool { return eligible[i].spec.StructuredOutput && !eligible[j].spec.StructuredOutput })
+	}
+	if route != nil && route.Model != "" {
+		sort.SliceStable(eligible, func(i, j int) bool {
+			return eligible[i].spec.Name == route.Model && eligible[j].spec.Name != route.Model
+		})
+	}
+
+	if routing.MaxFallbacks > 0 && len(eligible) > routing.MaxFallbacks+1 {
+		eligible = eligible[:routing.MaxFallbacks+1]
+	}
+	return eligible, nil
+}
+
+// cost 每千令牌的输入和输出价格之和
+func cost(spec ModelSpec) float64 {
+	return spec.InputCostPer1K + spec.OutputCostPer1K
+}
+
+// latency 观测延迟，没有观测数据时使用配置的预估延迟，两者都没有时排在最后
+func latency(e *entry) float64 {
+	if v := e.stats.value(); v > 0 {
+		return v
+	}
+	if e.spec.LatencyMs > 0 {
+		return float64(e.spec.LatencyMs)
+	}
+	return math.MaxFloat64
+}
+
+// routedChatModel 按路由规则选择模型的聊天模型，调用失败时依次回退到下一个候选模型
+// 流式调用在收到首个分块之前失败才会回退
+type routedChatModel struct {
+	registry *Registry
+	tools    []*schema.ToolInfo
+}
+
+// WithTools 返回绑定了工具的聊天模型，调用时只在支持工具调用的模型之间路由和回退
+func (m *routedChatModel) WithTools(tools []*schema.ToolInfo) (einomodel.ToolCallingChatModel, error) {
+	return &routedChatModel{registry: m.registry, tools: tools}, nil
+}
+
+// IsCallbacksEnabled 回调由实际调用的模型触发，避免编排框架和模型重复触发
+func (m *routedChatModel) IsCallbacksEnabled() bool {
+	return true
+}
+
+// GetType 组件类型
+func (m *routedChatModel) GetType() string {
+	return "RoutedChatModel"
+}
+
+// Generate 依次调用候选模型，直到有模型成功
+func (m *routedChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...einomodel.Option) (*schema.Message, error) {
+	route := RouteFromContext(ctx)
+	candidates, err := m.registry.candidates(route, requirementsOf(input, m.tools))
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, e := range candidates {
+		llm, err := m.bind(e)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		callCtx := ctx
+		if !components.IsCallbacksEnabled(llm) {
+			callCtx = callbacks.OnStart(ctx, &einomodel.CallbackInput{Messages: input, Tools: m.tools})
+		}
+
+		start := time.Now()
+		out, err := llm.Generate(callCtx, input, callOptions(e, route, opts)...)
+		if err != nil {
+			if !components.IsCallbacksEnabled(llm) {
+				callbacks.OnError(callCtx, err)
+			}
+			if lastErr = err; ctx.Err() != nil {
+				return nil, err
+			}
+			m.registry.logger.Warn("模型调用失败，回退到下一个模型", zap.String("model", e.spec.Name), zap.Error(err))
+			continue
+		}
+		if !components.IsCallbacksEnabled(llm) {
+			callbacks.OnEnd(callCtx, &einomodel.CallbackOutput{Message: out})
+		}
+		e.stats.observe(time.Since(start))
+		if route != nil {
+			route.setServed(e.spec.Name)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("所有候选模型调用失败: %w", lastErr)
+}
+
+// Stream 依次调用候选模型，直到有模型成功返回首个分块
+func (m *routedChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...einomodel.Option) (*schema.StreamReader[*schema.Message], error) {
+	route := RouteFromContext(ctx)
+	candidates, err := m.registry.candidates(route, requirementsOf(input, m.tools))
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, e := range candidates {
+		llm, err := m.bind(e)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		callCtx := ctx
+		if !components.IsCallbacksEnabled(llm) {
+			callCtx = callbacks.OnStart(ctx, &einomodel.CallbackInput{Messages: input, Tools: m.tools})
+		}
+
+		start := time.Now()
+		stream, first, err := streamFirstChunk(callCtx, llm, input, callOptions(e, route, opts)...)
+		if err != nil {
+			if !components.IsCallbacksEnabled(llm) {
+				callbacks.OnError(callCtx, err)
+			}
+			if lastErr = err; ctx.Err() != nil {
+				return nil, err
+			}
+			m.registry.logger.Warn("模型流式调用失败，回退到下一个模型", zap.String("model", e.spec.Name), zap.Error(err))
+			continue
+		}
+		e.stats.observe(time.Since(start))
+		if route != nil {
+			route.setServed(e.spec.Name)
+		}
+
+		stream = prepend(first, stream)
+		if !components.IsCallbacksEnabled(llm) {
+			_, stream = callbacks.OnEndWithStreamOutput(callCtx, stream)
+		}
+		return stream, nil
+	}
+	return nil, fmt.Errorf("所有候选模型调用失败: %w", lastErr)
+}
+
+// bind 为候选模型绑定工具，候选模型已按工具调用能力过滤
+func (m *routedChatModel) bind(e *entry) (einomodel.BaseChatModel, error) {
+	if len(m.tools) == 0 {
+		return e.llm, nil
+	}
+	return e.llm.WithTools(m.tools)
+}
+
+// callOptions 为候选模型追加结构化输出选项，模型不支持时由提示词约束输出
+func callOptions(e *entry, route *Route, opts []einomodel.Option) []einomodel.Option {
+	if route == nil || route.ResponseFormat == nil || !e.spec.StructuredOutput {
+		return opts
+	}
+	format, ok := models.ResponseFormatOption(e.spec.Provider, route.ResponseFormat.Name, route.ResponseFormat.Schema)
+	if !ok {
+		return opts
+	}
+	return append(append([]einomodel.Option(nil), opts...), format)
+}
+
+// streamFirstChunk 发起流式调用并读取首个分块，流为空时 first 为 nil
+func streamFirstChunk(ctx context.Context, llm einomodel.BaseChatModel, input []*schema.Message, opts ...einomodel.Option) (*schema.StreamReader[*schema.Message], *schema.Message, error) {
+	stream, err := llm.Stream(ctx, input, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return stream, nil, nil
+	}
+	if err != nil {
+		stream.Close()
+		return nil, nil, err
+	}
+	return stream, first, nil
+}
+
+// prepend 返回先输出 first 再输出 stream 剩余分块的流
+func prepend(first *schema.Message, stream *schema.StreamReader[*schema.Message]) *schema.StreamReader[*schema.Message] {
+	if first == nil {
+		return stream
+	}
+	reader, writer := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer writer.Close()
+		defer stream.Close()
+		if writer.Send(first, nil) {
+			return
+		}
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if writer.Send(chunk, err) || err != nil {
+				return
+			}
+		}
+	}()
+	return reader
+}
@@ -0,0 +1,338 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"weave/pkg/resilience"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// fakeChatModel 以模型名称作为回复的聊天模型，err 不为空时 Generate 失败，streamErr 不为空时流的首个分块失败
+type fakeChatModel struct {
+	name      string
+	err       error
+	streamErr error
+
+	mu    *sync.Mutex
+	calls *int
+	tools []*schema.ToolInfo
+}
+
+func newFakeChatModel(name string) *fakeChatModel {
+	return &fakeChatModel{name: name, mu: &sync.Mutex{}, calls: new(int)}
+}
+
+func (m *fakeChatModel) called() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.calls
+}
+
+func (m *fakeChatModel) Generate(_ context.Context, _ []*schema.Message, _ ...einomodel.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	*m.calls++
+	m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	msg := schema.AssistantMessage(m.name, nil)
+	if len(m.tools) > 0 {
+		msg.Content += " with tools"
+	}
+	return msg, nil
+}
+
+func (m *fakeChatModel) Stream(_ context.Context, _ []*schema.Message, _ ...einomodel.Option) (*schema.StreamReader[*schema.Message], error) {
+	m.mu.Lock()
+	*m.calls++
+	m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	reader, writer := schema.Pipe[*schema.Message](2)
+	go func() {
+		defer writer.Close()
+		if m.streamErr != nil {
+			writer.Send(nil, m.streamErr)
+			return
+		}
+		writer.Send(schema.AssistantMessage(m.name, nil), nil)
+		writer.Send(schema.AssistantMessage(" done", nil), nil)
+	}()
+	return reader, nil
+}
+
+func (m *fakeChatModel) WithTools(tools []*schema.ToolInfo) (einomodel.ToolCallingChatModel, error) {
+	bound := *m
+	bound.tools = tools
+	return &bound, nil
+}
+
+// newTestRegistry 使用假模型创建注册表，返回按名称索引的假模型
+func newTestRegistry(t *testing.T, cfg RegistryConfig, configure func(m *fakeChatModel)) (*Registry, map[string]*fakeChatModel) {
+	t.Helper()
+	fakes := make(map[string]*fakeChatModel)
+	registry, err := NewRegistry(context.Background(), cfg, func(_ context.Context, spec ModelSpec) (einomodel.ToolCallingChatModel, error) {
+		m := newFakeChatModel(spec.Name)
+		if configure != nil {
+			configure(m)
+		}
+		fakes[spec.Name] = m
+		return m, nil
+	})
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	return registry, fakes
+}
+
+// generate 按路由选项调用注册表的聊天模型，返回回复内容和实际响应的模型
+func generate(t *testing.T, r *Registry, route *Route, input ...*schema.Message) (string, string) {
+	t.Helper()
+	if route == nil {
+		route = &Route{}
+	}
+	if len(input) == 0 {
+		input = []*schema.Message{schema.UserMessage("hello")}
+	}
+	out, err := r.ChatModel().Generate(WithRoute(context.Background(), route), input)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	return out.Content, route.Served()
+}
+
+// openBreaker 连续记录失败直到依赖的熔断器打开
+func openBreaker(t *testing.T, dependency string) {
+	t.Helper()
+	breaker := resilience.Get(dependency).Breaker()
+	for i := 0; i < 100 && breaker.State() != resilience.StateOpen; i++ {
+		done, err := breaker.Allow()
+		if err != nil {
+			break
+		}
+		done(errors.New("provider unavailable"))
+	}
+	if breaker.State() != resilience.StateOpen {
+		t.Fatalf("breaker for %s did not open", dependency)
+	}
+}
+
+func TestRegistryRoutesByPreference(t *testing.T) {
+	registry, _ := newTestRegistry(t, RegistryConfig{Models: []ModelSpec{
+		{Name: "pref-premium", Provider: "openai", InputCostPer1K: 0.01, OutputCostPer1K: 0.03, LatencyMs: 900},
+		{Name: "pref-budget", Provider: "openai", InputCostPer1K: 0.0005, OutputCostPer1K: 0.0015, LatencyMs: 2000},
+		{Name: "pref-local", Provider: "ollama", LatencyMs: 300},
+	}}, nil)
+
+	if _, served := generate(t, registry, nil); served != "pref-premium" {
+		t.Fatalf("priority routing served %q, want pref-premium", served)
+	}
+	if _, served := generate(t, registry, &Route{Preference: PreferenceCost}); served != "pref-local" {
+		t.Fatalf("cost routing served %q, want the free local model", served)
+	}
+	if _, served := generate(t, registry, &Route{Preference: PreferenceLatency}); served != "pref-local" {
+		t.Fatalf("latency routing served %q, want pref-local", served)
+	}
+	if _, served := generate(t, registry, &Route{Model: "pref-budget", Preference: PreferenceLatency}); served != "pref-budget" {
+		t.Fatalf("explicit model served %q, want pref-budget", served)
+	}
+
+	_, err := registry.ChatModel().Generate(WithRoute(context.Background(), &Route{Model: "missing"}), []*schema.Message{schema.UserMessage("hi")})
+	if !errors.Is(err, ErrModelNotFound) {
+		t.Fatalf("unknown model error = %v, want ErrModelNotFound", err)
+	}
+}
+
+func TestRegistryRoutesByCapability(t *testing.T) {
+	registry, _ := newTestRegistry(t, RegistryConfig{Models: []ModelSpec{
+		{Name: "cap-small", Provider: "ollama", ContextWindow: 50},
+		{Name: "cap-text", Provider: "openai", ContextWindow: 4096},
+		{Name: "cap-vision", Provider: "modelscope", Vision: true, ToolCalling: true},
+	}}, nil)
+
+	image := &schema.Message{Role: schema.User, UserInputMultiContent: []schema.MessageInputPart{
+		{Type: schema.ChatMessagePartTypeText, Text: "what is this?"},
+		{Type: schema.ChatMessagePartTypeImageURL, Image: &schema.MessageInputImage{MessagePartCommon: schema.MessagePartCommon{URL: ptr("https://example.com/cat.png")}}},
+	}}
+	if _, served := generate(t, registry, nil, image); served != "cap-vision" {
+		t.Fatalf("image request served %q, want cap-vision", served)
+	}
+
+	long := schema.UserMessage(strings.Repeat("long prompt ", 100))
+	if _, served := generate(t, registry, nil, long); served != "cap-text" {
+		t.Fatalf("long prompt served %q, want the model with a large enough context window", served)
+	}
+
+	llm, err := registry.ChatModel().WithTools([]*schema.ToolInfo{{Name: "get_weather"}})
+	if err != nil {
+		t.Fatalf("with tools: %v", err)
+	}
+	route := &Route{}
+	out, err := llm.Generate(WithRoute(context.Background(), route), []*schema.Message{schema.UserMessage("weather?")})
+	if err != nil {
+		t.Fatalf("generate with tools: %v", err)
+	}
+	if route.Served() != "cap-vision" || out.Content != "cap-vision with tools" {
+		t.Fatalf("tool request served %q with %q, want cap-vision with tools bound", route.Served(), out.Content)
+	}
+
+	// 指定的模型不支持工具调用时回退到支持的模型，不会丢弃工具
+	route = &Route{Model: "cap-small"}
+	out, err = llm.Generate(WithRoute(context.Background(), route), []*schema.Message{schema.UserMessage("weather?")})
+	if err != nil {
+		t.Fatalf("generate on explicit model: %v", err)
+	}
+	if route.Served() != "cap-vision" || out.Content != "cap-vision with tools" {
+		t.Fatalf("explicit model without tool calling served %q with %q, want cap-vision with tools bound", route.Served(), out.Content)
+	}
+
+	textOnly, _ := newTestRegistry(t, RegistryConfig{Models: []ModelSpec{{Name: "cap-plain", Provider: "openai"}}}, nil)
+	llm, _ = textOnly.ChatModel().WithTools([]*schema.ToolInfo{{Name: "get_weather"}})
+	if _, err := llm.Generate(context.Background(), []*schema.Message{schema.UserMessage("weather?")}); !errors.Is(err, ErrToolsUnsupported) {
+		t.Fatalf("tool request without tool-calling models error = %v, want ErrToolsUnsupported", err)
+	}
+
+	vision, ok := registry.Get("cap-vision")
+	if !ok || !vision.Vision || !vision.ToolCalling || vision.Model != "cap-vision" {
+		t.Fatalf("registry spec = %+v, %v", vision, ok)
+	}
+	if !registry.SupportsToolCalling() {
+		t.Fatal("registry with a tool-calling model should support tool calling")
+	}
+}
+
+func TestRegistryFallsBack(t *testing.T) {
+	failure := errors.New("provider returned 502")
+	registry, fakes := newTestRegistry(t, RegistryConfig{Models: []ModelSpec{
+		{Name: "fb-primary", Provider: "openai"},
+		{Name: "fb-secondary", Provider: "modelscope"},
+		{Name: "fb-tertiary", Provider: "ollama"},
+	}, Routing: RoutingConfig{MaxFallbacks: 1}}, func(m *fakeChatModel) {
+		switch m.name {
+		case "fb-primary":
+			m.err = failure
+		case "fb-secondary":
+			m.streamErr = failure
+		}
+	})
+
+	content, served := generate(t, registry, nil)
+	if served != "fb-secondary" || content != "fb-secondary" {
+		t.Fatalf("generate fell back to %q (%q), want fb-secondary", served, content)
+	}
+
+	// 首个分块失败的流式调用也会回退，但最多再尝试一个模型
+	route := &Route{}
+	_, err := registry.ChatModel().Stream(WithRoute(context.Background(), route), []*schema.Message{schema.UserMessage("hi")})
+	if !errors.Is(err, failure) {
+		t.Fatalf("stream with max fallbacks 1 error = %v, want the provider error", err)
+	}
+	if fakes["fb-tertiary"].called() != 0 {
+		t.Fatal("models beyond maxFallbacks should not be called")
+	}
+
+	route = &Route{Model: "fb-tertiary"}
+	stream, err := registry.ChatModel().Stream(WithRoute(context.Background(), route), []*schema.Message{schema.UserMessage("hi")})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	var text strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("recv: %v", err)
+		}
+		text.WriteString(chunk.Content)
+	}
+	if route.Served() != "fb-tertiary" || text.String() != "fb-tertiary done" {
+		t.Fatalf("stream served %q with %q, want the full fb-tertiary reply", route.Served(), text.String())
+	}
+
+	// 熔断器打开的模型不参与路由
+	openBreaker(t, "llm:fb-primary")
+	before := fakes["fb-primary"].called()
+	if _, served := generate(t, registry, nil); served != "fb-secondary" {
+		t.Fatalf("served %q after primary breaker opened, want fb-secondary", served)
+	}
+	if fakes["fb-primary"].called() != before {
+		t.Fatal("model with an open breaker should be skipped")
+	}
+
+	if err := registry.CheckBreakers(context.Background()); err != nil {
+		t.Fatalf("health check with available models: %v", err)
+	}
+
+	openBreaker(t, "llm:fb-secondary")
+	openBreaker(t, "llm:fb-tertiary")
+	_, err = registry.ChatModel().Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+	if !errors.Is(err, ErrNoModelAvailable) {
+		t.Fatalf("all breakers open error = %v, want ErrNoModelAvailable", err)
+	}
+	if err := registry.CheckBreakers(context.Background()); !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Fatalf("health check with all breakers open = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestRegistryReload(t *testing.T) {
+	registry, _ := newTestRegistry(t, RegistryConfig{Models: []ModelSpec{
+		{Name: "reload-a", Provider: "openai"},
+		{Name: "reload-b", Provider: "ollama"},
+	}}, nil)
+	generate(t, registry, &Route{Model: "reload-a"})
+
+	err := registry.Reload(context.Background(), RegistryConfig{Models: []ModelSpec{
+		{Name: "reload-a", Provider: "openai"},
+		{Name: "reload-c", Provider: "modelscope", Vision: true},
+	}, Routing: RoutingConfig{Preference: PreferenceLatency}})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	names := make([]string, 0)
+	for _, spec := range registry.Models() {
+		names = append(names, spec.Name)
+	}
+	if strings.Join(names, ",") != "reload-a,reload-c" {
+		t.Fatalf("models after reload = %v", names)
+	}
+	// reload-a 保留了延迟观测数据，reload-c 没有观测数据也没有预估延迟，延迟优先时排在后面
+	if _, served := generate(t, registry, nil); served != "reload-a" {
+		t.Fatalf("latency routing after reload served %q, want reload-a", served)
+	}
+
+	err = registry.Reload(context.Background(), RegistryConfig{Models: []ModelSpec{{Name: "bad", Provider: "unknown"}}})
+	if err == nil {
+		t.Fatal("reload with an invalid provider should fail")
+	}
+	if _, ok := registry.Get("reload-c"); !ok {
+		t.Fatal("invalid reload should keep the previous registry")
+	}
+
+	err = registry.Reload(context.Background(), RegistryConfig{Models: []ModelSpec{
+		{Name: "reload-a", Provider: "openai", Disabled: true},
+		{Name: "reload-c", Provider: "modelscope"},
+	}})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, ok := registry.Get("reload-a"); ok {
+		t.Fatal("disabled model should not be returned")
+	}
+	if _, served := generate(t, registry, nil); served != "reload-c" {
+		t.Fatalf("served %q, want reload-c while reload-a is disabled", served)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// Tokenizer 令牌计数器，用于提示词预算、上下文窗口检查和模型未返回用量时的估算
+type Tokenizer interface {
+	// CountTokens 返回文本的令牌数
+	CountTokens(text string) int
+	// CountMessages 返回消息列表作为提示词时的令牌数，包含每条消息的角色和分隔符开销
+	CountMessages(messages []*schema.Message) int
+}
+
+// imageTokens 每张图片按固定令牌数计算，接近主流视觉模型一张中等分辨率图片的开销
+const imageTokens = 765
+
+// estimator 按字符类别估算令牌数：中日韩字符和其他字符的分词效率差别很大，分别按各自的比例折算
+type estimator struct {
+	// 每个令牌平均对应的中日韩字符数
+	cjkPerToken float64
+	// 每个令牌平均对应的其他字符字节数
+	bytesPerToken float64
+	// 每条消息的角色和分隔符开销
+	messageOverhead int
+}
+
+// CountTokens 估算文本的令牌数
+func (e estimator) CountTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	cjk, other := 0, 0
+	for _, r := range text {
+		if isCJK(r) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+	return int(math.Ceil(float64(cjk)/e.cjkPerToken + float64(other)/e.bytesPerToken))
+}
+
+// CountMessages 估算消息列表的令牌数
+func (e estimator) CountMessages(messages []*schema.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += e.messageOverhead + e.CountTokens(msg.Content)
+		for _, part := range msg.UserInputMultiContent {
+			switch part.Type {
+			case schema.ChatMessagePartTypeText:
+				total += e.CountTokens(part.Text)
+			case schema.ChatMessagePartTypeImageURL:
+				total += imageTokens
+			}
+		}
+		for _, tc := range msg.ToolCalls {
+			total += e.CountTokens(tc.Function.Name) + e.CountTokens(tc.Function.Arguments)
+		}
+	}
+	return total
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// defaultTokenizer 未匹配到模型族时使用：中日韩字符每字一个令牌，其他字符每四个字节一个令牌
+var defaultTokenizer Tokenizer = estimator{cjkPerToken: 1, bytesPerToken: 4, messageOverhead: 4}
+
+var (
+	tokenizersMu sync.RWMutex
+	// tokenizers 按模型名称前缀匹配的分词器，比例取自各模型族词表在中英文语料上的实测平均值
+	tokenizers = map[string]Tokenizer{
+		"gpt-4o":   estimator{cjkPerToken: 1.4, bytesPerToken: 4, messageOverhead: 4},
+		"gpt-4.1":  estimator{cjkPerToken: 1.4, bytesPerToken: 4, messageOverhead: 4},
+		"o1":       estimator{cjkPerToken: 1.4, bytesPerToken: 4, messageOverhead: 4},
+		"o3":       estimator{cjkPerToken: 1.4, bytesPerToken: 4, messageOverhead: 4},
+		"gpt-":     estimator{cjkPerToken: 1, bytesPerToken: 4, messageOverhead: 4},
+		"qwen":     estimator{cjkPerToken: 1.5, bytesPerToken: 4, messageOverhead: 5},
+		"deepseek": estimator{cjkPerToken: 1.5, bytesPerToken: 4, messageOverhead: 5},
+		"glm":      estimator{cjkPerToken: 1.6, bytesPerToken: 4, messageOverhead: 5},
+		"moonshot": estimator{cjkPerToken: 1.5, bytesPerToken: 4, messageOverhead: 5},
+		"llama2":   estimator{cjkPerToken: 0.7, bytesPerToken: 3.5, messageOverhead: 6},
+		"llama":    estimator{cjkPerToken: 1, bytesPerToken: 4, messageOverhead: 6},
+		"mistral":  estimator{cjkPerToken: 0.8, bytesPerToken: 3.5, messageOverhead: 6},
+		"mixtral":  estimator{cjkPerToken: 0.8, bytesPerToken: 3.5, messageOverhead: 6},
+		"gemma":    estimator{cjkPerToken: 1.2, bytesPerToken: 4, messageOverhead: 5},
+		"claude":   estimator{cjkPerToken: 1, bytesPerToken: 3.5, messageOverhead: 5},
+	}
+)
+
+// RegisterTokenizer 为名称以 prefix 开头的模型注册分词器，覆盖内置的估算规则
+func RegisterTokenizer(prefix string, tokenizer Tokenizer) {
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+	tokenizers[strings.ToLower(prefix)] = tokenizer
+}
+
+// TokenizerFor 返回模型使用的分词器：去掉组织前缀（如 Qwen/）后按最长的名称前缀匹配，没有匹配时使用默认估算
+func TokenizerFor(modelName string) Tokenizer {
+	name := strings.ToLower(modelName)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	tokenizersMu.RLock()
+	defer tokenizersMu.RUnlock()
+	prefixes := make([]string, 0, len(tokenizers))
+	for prefix := range tokenizers {
+		if strings.HasPrefix(name, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return defaultTokenizer
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	return tokenizers[prefixes[0]]
+}
+
+// EstimateMessagesTokens 使用默认分词器估算消息列表的令牌数
+func EstimateMessagesTokens(messages []*schema.Message) int {
+	return defaultTokenizer.CountMessages(messages)
+}
+
+// EstimateTokens 使用默认分词器估算文本的令牌数
+func EstimateTokens(text string) int {
+	return defaultTokenizer.CountTokens(text)
+}
@@ -0,0 +1,288 @@
+package prompts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strings"
+
+	aichatpkg "weave/services/aichat/pkg"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// namePattern 模板和人设名称只能包含字母、数字、下划线和连字符
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Manager 提示词模板和人设的管理：校验、租户覆盖、A/B 分流和渲染
+type Manager struct {
+	store Store
+}
+
+// NewManager 创建提示词管理器
+func NewManager(store Store) *Manager {
+	return &Manager{store: store}
+}
+
+// Builtin 返回内置提示词对应的模板，没有保存任何版本的 default 模板时使用
+func Builtin() Template {
+	return Template{
+		Name:    DefaultTemplate,
+		Content: aichatpkg.DefaultSystemPrompt,
+		Variables: map[string]string{
+			"role":  aichatpkg.DefaultRole,
+			"style": aichatpkg.DefaultStyle,
+		},
+	}
+}
+
+// Templates 列出租户可见的模板版本：租户自己的版本在前，全局版本在后，name 为空时列出所有模板
+func (m *Manager) Templates(ctx context.Context, tenantID, name string) ([]Template, error) {
+	templates, err := m.store.ListTemplates(ctx, tenantID, name)
+	if err != nil || tenantID == "" {
+		return templates, err
+	}
+	global, err := m.store.ListTemplates(ctx, "", name)
+	if err != nil {
+		return nil, err
+	}
+	return append(templates, global...), nil
+}
+
+// CreateTemplate 校验并保存模板的新版本
+func (m *Manager) CreateTemplate(ctx context.Context, template Template, exclusive bool) (*Template, error) {
+	if !namePattern.MatchString(template.Name) {
+		return nil, fmt.Errorf("%w: name must match %s", ErrInvalidTemplate, namePattern)
+	}
+	if strings.TrimSpace(template.Content) == "" {
+		return nil, fmt.Errorf("%w: content is empty", ErrInvalidTemplate)
+	}
+	if _, err := Placeholders(template.Content); err != nil {
+		return nil, err
+	}
+	if err := checkVariables(template.Variables); err != nil {
+		return nil, err
+	}
+	if template.Weight < 0 {
+		return nil, fmt.Errorf("%w: weight must not be negative", ErrInvalidTemplate)
+	}
+	if err := m.store.CreateTemplate(ctx, &template, exclusive); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// SetWeight 修改租户模板版本的分流权重，权重为 0 时该版本不再被选中
+func (m *Manager) SetWeight(ctx context.Context, tenantID, name string, version, weight int) (*Template, error) {
+	if weight < 0 {
+		return nil, fmt.Errorf("%w: weight must not be negative", ErrInvalidTemplate)
+	}
+	template, err := m.store.UpdateWeight(ctx, tenantID, name, version, weight)
+	if err != nil {
+		return nil, err
+	}
+	template.Weight = weight
+	return template, nil
+}
+
+// Personas 列出租户可见的人设，租户人设覆盖同名的全局人设
+func (m *Manager) Personas(ctx context.Context, tenantID string) ([]Persona, error) {
+	personas, err := m.store.ListPersonas(ctx, tenantID)
+	if err != nil || tenantID == "" {
+		return personas, err
+	}
+	global, err := m.store.ListPersonas(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(personas))
+	for _, persona := range personas {
+		names[persona.Name] = true
+	}
+	for _, persona := range global {
+		if !names[persona.Name] {
+			personas = append(personas, persona)
+		}
+	}
+	sort.SliceStable(personas, func(i, j int) bool { return personas[i].Name < personas[j].Name })
+	return personas, nil
+}
+
+// Persona 返回租户可见的人设，优先使用租户自己的人设
+func (m *Manager) Persona(ctx context.Context, tenantID, name string) (*Persona, error) {
+	persona, err := m.store.GetPersona(ctx, tenantID, name)
+	if errors.Is(err, ErrPersonaNotFound) && tenantID != "" {
+		return m.store.GetPersona(ctx, "", name)
+	}
+	return persona, err
+}
+
+// SavePersona 校验并保存人设，人设使用的模板必须对人设所属租户可见
+func (m *Manager) SavePersona(ctx context.Context, persona Persona) (*Persona, error) {
+	if !namePattern.MatchString(persona.Name) {
+		return nil, fmt.Errorf("%w: name must match %s", ErrInvalidTemplate, namePattern)
+	}
+	if persona.Template == "" {
+		persona.Template = DefaultTemplate
+	}
+	if err := checkVariables(persona.Variables); err != nil {
+		return nil, err
+	}
+	if persona.Template != DefaultTemplate {
+		templates, err := m.Templates(ctx, persona.TenantID, persona.Template)
+		if err != nil {
+			return nil, err
+		}
+		if len(templates) == 0 {
+			return nil, ErrTemplateNotFound
+		}
+	}
+	if err := m.store.SavePersona(ctx, &persona); err != nil {
+		return nil, err
+	}
+	return &persona, nil
+}
+
+// DeletePersona 删除租户的人设
+func (m *Manager) DeletePersona(ctx context.Context, tenantID, name string) error {
+	return m.store.DeletePersona(ctx, tenantID, name)
+}
+
+// Select 为请求选择模板版本
+// persona 为空时使用 default 模板；租户有参与分流的版本时使用租户版本，否则使用全局版本；
+// 多个版本参与分流时按权重选择，同一个 assignKey（通常为对话 ID）总是分到同一个版本
+func (m *Manager) Select(ctx context.Context, tenantID, persona, assignKey string) (*Selection, error) {
+	name, variables := DefaultTemplate, map[string]string(nil)
+	if persona != "" {
+		p, err := m.Persona(ctx, tenantID, persona)
+		if err != nil {
+			return nil, err
+		}
+		name, variables = p.Template, p.Variables
+	}
+
+	template, err := m.pick(ctx, tenantID, name, assignKey)
+	if err != nil {
+		return nil, err
+	}
+	return newSelection(template, persona, variables), nil
+}
+
+// DefaultSelection 返回使用内置提示词的选择结果，模板存储不可用时使用
+func DefaultSelection() *Selection {
+	return newSelection(Builtin(), "", nil)
+}
+
+// newSelection 创建选择结果，变量按内置默认值、模板默认值、人设变量的顺序覆盖
+func newSelection(template Template, persona string, variables map[string]string) *Selection {
+	return &Selection{
+		Template:  template.Name,
+		Version:   template.Version,
+		TenantID:  template.TenantID,
+		Persona:   persona,
+		content:   template.Content,
+		variables: mergeVariables(Builtin().Variables, template.Variables, variables),
+	}
+}
+
+// pick 按租户覆盖和权重选出模板版本，没有保存任何版本时使用内置提示词
+func (m *Manager) pick(ctx context.Context, tenantID, name, assignKey string) (Template, error) {
+	scopes := []string{""}
+	if tenantID != "" {
+		scopes = []string{tenantID, ""}
+	}
+	var latest *Template
+	for _, scope := range scopes {
+		versions, err := m.store.ListTemplates(ctx, scope, name)
+		if err != nil {
+			return Template{}, err
+		}
+		if len(versions) == 0 {
+			continue
+		}
+		if template, ok := weighted(versions, assignKey); ok {
+			return template, nil
+		}
+		if latest == nil {
+			latest = &versions[len(versions)-1]
+		}
+	}
+	// 所有版本的权重都为 0 时使用最新版本
+	if latest != nil {
+		return *latest, nil
+	}
+	return Builtin(), nil
+}
+
+// weighted 在权重大于 0 的版本中按 assignKey 的哈希值选择版本
+func weighted(versions []Template, assignKey string) (Template, bool) {
+	total := 0
+	for _, v := range versions {
+		total += v.Weight
+	}
+	if total == 0 {
+		return Template{}, false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(versions[0].TenantID + "/" + versions[0].Name + "/" + assignKey))
+	point := int(h.Sum32() % uint32(total))
+	for _, v := range versions {
+		if point < v.Weight {
+			return v, true
+		}
+		point -= v.Weight
+	}
+	return versions[len(versions)-1], true
+}
+
+// Render 渲染发送给模型的系统消息和用户消息，模板中未提供值的变量渲染为空字符串
+func (s *Selection) Render(chatHistory, question string) []*schema.Message {
+	return []*schema.Message{
+		schema.SystemMessage(s.SystemPrompt(chatHistory, question)),
+		schema.UserMessage(question),
+	}
+}
+
+// SystemPrompt 渲染系统提示词
+func (s *Selection) SystemPrompt(chatHistory, question string) string {
+	variables := mergeVariables(s.variables, map[string]string{VarChatHistory: chatHistory, VarQuestion: question})
+	return placeholderPattern.ReplaceAllStringFunc(s.content, func(token string) string {
+		switch {
+		case token == "{{":
+			return "{"
+		case token == "}}":
+			return "}"
+		case len(token) > 2:
+			return variables[token[1:len(token)-1]]
+		default:
+			return token
+		}
+	})
+}
+
+// checkVariables 变量名必须合法，且不能覆盖聊天服务提供的变量
+func checkVariables(variables map[string]string) error {
+	for name := range variables {
+		if !namePattern.MatchString(name) || strings.Contains(name, "-") {
+			return fmt.Errorf("%w: invalid variable name %q", ErrInvalidTemplate, name)
+		}
+		if name == VarChatHistory || name == VarQuestion {
+			return fmt.Errorf("%w: variable %q is provided by the chat service", ErrInvalidTemplate, name)
+		}
+	}
+	return nil
+}
+
+// mergeVariables 按顺序合并变量，后面的覆盖前面的
+func mergeVariables(layers ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, layer := range layers {
+		for k, v := range layer {
+			merged[k] = v
+		}
+	}
+	return merged
+}
@@ -0,0 +1,113 @@
+package prompts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DefaultTemplate 未指定人设时使用的模板名称，没有保存任何版本时使用内置提示词
+const DefaultTemplate = "default"
+
+// PersonaMetadataKey 对话元数据中保存人设名称的键
+const PersonaMetadataKey = "persona"
+
+// 渲染时由聊天服务提供的变量，模板和人设不能覆盖
+const (
+	VarChatHistory = "chat_history"
+	VarQuestion    = "question"
+)
+
+var (
+	// ErrTemplateNotFound 模板或模板版本不存在
+	ErrTemplateNotFound = errors.New("prompt template not found")
+	// ErrPersonaNotFound 人设不存在
+	ErrPersonaNotFound = errors.New("persona not found")
+	// ErrInvalidTemplate 模板内容无效
+	ErrInvalidTemplate = errors.New("invalid prompt template")
+)
+
+// Template 提示词模板的一个版本
+// 同名模板的多个版本中权重大于 0 的版本参与 A/B 分流，租户模板覆盖同名的全局模板
+type Template struct {
+	Name        string            `json:"name"`
+	TenantID    string            `json:"tenant_id,omitempty"`   // 为空表示全局模板
+	Version     int               `json:"version"`               // 版本号，从 1 开始递增
+	Content     string            `json:"content"`               // 系统提示词，{变量名} 为占位符，{{ 和 }} 表示花括号本身
+	Variables   map[string]string `json:"variables,omitempty"`   // 变量默认值
+	Weight      int               `json:"weight"`                // A/B 分流权重，0 表示不参与分流
+	Description string            `json:"description,omitempty"` // 版本说明
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// Persona 人设：选择使用的模板并为其提供变量，对话通过元数据中的 persona 选择人设
+type Persona struct {
+	Name        string            `json:"name"`
+	TenantID    string            `json:"tenant_id,omitempty"` // 为空表示全局人设
+	Description string            `json:"description,omitempty"`
+	Template    string            `json:"template"`            // 使用的模板名称
+	Variables   map[string]string `json:"variables,omitempty"` // 覆盖模板默认值的变量
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// Selection 一次请求选中的模板版本和人设
+type Selection struct {
+	Template string `json:"template"`
+	Version  int    `json:"version"`             // 0 表示内置提示词
+	TenantID string `json:"tenant_id,omitempty"` // 模板所属租户，全局模板为空
+	Persona  string `json:"persona,omitempty"`
+
+	content   string
+	variables map[string]string
+}
+
+// String 返回记录到响应和用量中的模板标识，格式为 名称@v版本，内置提示词为 名称@builtin，未选择模板时为空
+func (s *Selection) String() string {
+	if s == nil || s.Template == "" {
+		return ""
+	}
+	if s.Version == 0 {
+		return s.Template + "@builtin"
+	}
+	return fmt.Sprintf("%s@v%d", s.Template, s.Version)
+}
+
+type selectionKey struct{}
+
+// WithSelection 在上下文中放入接收选择结果的 Selection，聊天服务选定模板版本后写入，调用方据此在响应中返回模板版本
+func WithSelection(ctx context.Context, sel *Selection) context.Context {
+	return context.WithValue(ctx, selectionKey{}, sel)
+}
+
+// Record 将选择结果写入上下文中的 Selection，上下文中没有时不做任何事
+func Record(ctx context.Context, chosen *Selection) {
+	if sel, ok := ctx.Value(selectionKey{}).(*Selection); ok && sel != nil && chosen != nil {
+		*sel = *chosen
+	}
+}
+
+// placeholderPattern 匹配 {变量名} 占位符，{{ 和 }} 为转义的花括号
+var placeholderPattern = regexp.MustCompile(`\{\{|\}\}|\{([A-Za-z_][A-Za-z0-9_]*)\}|[{}]`)
+
+// Placeholders 解析模板内容中的变量名，按首次出现的顺序返回
+func Placeholders(content string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatchIndex(content, -1) {
+		token := content[match[0]:match[1]]
+		switch {
+		case token == "{{" || token == "}}":
+		case match[2] < 0:
+			return nil, fmt.Errorf("%w: unmatched %q at offset %d", ErrInvalidTemplate, token, match[0])
+		default:
+			name := content[match[2]:match[3]]
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
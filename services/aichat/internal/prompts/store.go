@@ -0,0 +1,256 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Store 提示词模板和人设的存储，tenantID 为空表示全局
+type Store interface {
+	// ListTemplates 按名称和版本顺序列出租户的模板版本，name 为空时列出所有模板
+	ListTemplates(ctx context.Context, tenantID, name string) ([]Template, error)
+	// CreateTemplate 保存模板的新版本并设置版本号，exclusive 为 true 时其他版本不再参与分流
+	CreateTemplate(ctx context.Context, template *Template, exclusive bool) error
+	// UpdateWeight 修改模板版本的分流权重
+	UpdateWeight(ctx context.Context, tenantID, name string, version, weight int) (*Template, error)
+	// ListPersonas 按名称顺序列出租户的人设
+	ListPersonas(ctx context.Context, tenantID string) ([]Persona, error)
+	// GetPersona 获取租户的人设
+	GetPersona(ctx context.Context, tenantID, name string) (*Persona, error)
+	// SavePersona 创建或覆盖人设
+	SavePersona(ctx context.Context, persona *Persona) error
+	// DeletePersona 删除人设
+	DeletePersona(ctx context.Context, tenantID, name string) error
+}
+
+// templateRecord 模板版本表记录
+type templateRecord struct {
+	ID          uint   `gorm:"primaryKey"`
+	TenantID    string `gorm:"size:64;uniqueIndex:idx_aichat_prompt_templates_version,priority:1;not null;default:''"`
+	Name        string `gorm:"size:64;uniqueIndex:idx_aichat_prompt_templates_version,priority:2;not null"`
+	Version     int    `gorm:"uniqueIndex:idx_aichat_prompt_templates_version,priority:3;not null"`
+	Content     string `gorm:"type:text"`
+	Variables   string `gorm:"type:text"`
+	Weight      int    `gorm:"not null;default:0"`
+	Description string `gorm:"size:255"`
+	CreatedAt   time.Time
+}
+
+func (templateRecord) TableName() string { return "aichat_prompt_templates" }
+
+// personaRecord 人设表记录
+type personaRecord struct {
+	ID          uint   `gorm:"primaryKey"`
+	TenantID    string `gorm:"size:64;uniqueIndex:idx_aichat_personas_name,priority:1;not null;default:''"`
+	Name        string `gorm:"size:64;uniqueIndex:idx_aichat_personas_name,priority:2;not null"`
+	Description string `gorm:"size:255"`
+	Template    string `gorm:"size:64"`
+	Variables   string `gorm:"type:text"`
+	UpdatedAt   time.Time
+}
+
+func (personaRecord) TableName() string { return "aichat_personas" }
+
+// gormStore 基于GORM的提示词存储，与对话使用同一个数据库
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewStore 基于已有的数据库连接创建提示词存储，并自动迁移表结构
+func NewStore(db *gorm.DB) (Store, error) {
+	if err := db.AutoMigrate(&templateRecord{}, &personaRecord{}); err != nil {
+		return nil, fmt.Errorf("migrate aichat prompt tables: %w", err)
+	}
+	return &gormStore{db: db}, nil
+}
+
+// ListTemplates 按名称和版本顺序列出租户的模板版本
+func (s *gormStore) ListTemplates(ctx context.Context, tenantID, name string) ([]Template, error) {
+	query := s.db.WithContext(ctx).Where("tenant_id = ?", tenantID)
+	if name != "" {
+		query = query.Where("name = ?", name)
+	}
+	var records []templateRecord
+	if err := query.Order("name, version").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	templates := make([]Template, 0, len(records))
+	for _, record := range records {
+		templates = append(templates, fromTemplateRecord(record))
+	}
+	return templates, nil
+}
+
+// CreateTemplate 在事务中分配下一个版本号并保存模板
+func (s *gormStore) CreateTemplate(ctx context.Context, template *Template, exclusive bool) error {
+	variables, err := marshalVariables(template.Variables)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var latest int
+		err := tx.Model(&templateRecord{}).
+			Where("tenant_id = ? AND name = ?", template.TenantID, template.Name).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+		if exclusive {
+			err = tx.Model(&templateRecord{}).
+				Where("tenant_id = ? AND name = ?", template.TenantID, template.Name).
+				Update("weight", 0).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		record := templateRecord{
+			TenantID:    template.TenantID,
+			Name:        template.Name,
+			Version:     latest + 1,
+			Content:     template.Content,
+			Variables:   variables,
+			Weight:      template.Weight,
+			Description: template.Description,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		template.Version, template.CreatedAt = record.Version, record.CreatedAt
+		return nil
+	})
+}
+
+// UpdateWeight 修改模板版本的分流权重
+func (s *gormStore) UpdateWeight(ctx context.Context, tenantID, name string, version, weight int) (*Template, error) {
+	db := s.db.WithContext(ctx)
+	var record templateRecord
+	err := db.Where("tenant_id = ? AND name = ? AND version = ?", tenantID, name, version).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Model(&record).Update("weight", weight).Error; err != nil {
+		return nil, err
+	}
+	template := fromTemplateRecord(record)
+	return &template, nil
+}
+
+// ListPersonas 按名称顺序列出租户的人设
+func (s *gormStore) ListPersonas(ctx context.Context, tenantID string) ([]Persona, error) {
+	var records []personaRecord
+	if err := s.db.WithContext(ctx).Where("tenant_id = ?", tenantID).Order("name").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	personas := make([]Persona, 0, len(records))
+	for _, record := range records {
+		personas = append(personas, fromPersonaRecord(record))
+	}
+	return personas, nil
+}
+
+// GetPersona 获取租户的人设
+func (s *gormStore) GetPersona(ctx context.Context, tenantID, name string) (*Persona, error) {
+	var record personaRecord
+	err := s.db.WithContext(ctx).Where("tenant_id = ? AND name = ?", tenantID, name).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPersonaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	persona := fromPersonaRecord(record)
+	return &persona, nil
+}
+
+// SavePersona 创建或覆盖人设
+func (s *gormStore) SavePersona(ctx context.Context, persona *Persona) error {
+	variables, err := marshalVariables(persona.Variables)
+	if err != nil {
+		return err
+	}
+	record := personaRecord{
+		TenantID:    persona.TenantID,
+		Name:        persona.Name,
+		Description: persona.Description,
+		Template:    persona.Template,
+		Variables:   variables,
+		UpdatedAt:   time.Now(),
+	}
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"description", "template", "variables", "updated_at"}),
+	}).Create(&record).Error
+	if err != nil {
+		return err
+	}
+	persona.UpdatedAt = record.UpdatedAt
+	return nil
+}
+
+// DeletePersona 删除人设
+func (s *gormStore) DeletePersona(ctx context.Context, tenantID, name string) error {
+	result := s.db.WithContext(ctx).Where("tenant_id = ? AND name = ?", tenantID, name).Delete(&personaRecord{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPersonaNotFound
+	}
+	return nil
+}
+
+func fromTemplateRecord(record templateRecord) Template {
+	return Template{
+		Name:        record.Name,
+		TenantID:    record.TenantID,
+		Version:     record.Version,
+		Content:     record.Content,
+		Variables:   unmarshalVariables(record.Variables),
+		Weight:      record.Weight,
+		Description: record.Description,
+		CreatedAt:   record.CreatedAt,
+	}
+}
+
+func fromPersonaRecord(record personaRecord) Persona {
+	return Persona{
+		Name:        record.Name,
+		TenantID:    record.TenantID,
+		Description: record.Description,
+		Template:    record.Template,
+		Variables:   unmarshalVariables(record.Variables),
+		UpdatedAt:   record.UpdatedAt,
+	}
+}
+
+func marshalVariables(variables map[string]string) (string, error) {
+	if len(variables) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(variables)
+	if err != nil {
+		return "", fmt.Errorf("marshal prompt variables: %w", err)
+	}
+	return string(data), nil
+}
+
+func unmarshalVariables(data string) map[string]string {
+	if data == "" {
+		return nil
+	}
+	var variables map[string]string
+	if err := json.Unmarshal([]byte(data), &variables); err != nil {
+		return nil
+	}
+	return variables
+}
@@ -0,0 +1,206 @@
+package resumable
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// memoryStream 内存中的一次生成
+type memoryStream struct {
+	info   Info
+	events []Event
+	notify chan struct{} // 追加事件或修改状态时关闭并替换，唤醒等待的读取
+}
+
+// MemoryStore 内存事件存储，只适用于单实例部署
+type MemoryStore struct {
+	mu      sync.Mutex
+	streams map[string]*memoryStream
+	latest  map[string]string // 会话键到最近一次生成的 ID
+	ttl     time.Duration
+	ticker  *time.Ticker
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewMemoryStore 创建内存事件存储，超过 ttl 无更新的生成被定期清理
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	s := &MemoryStore{
+		streams: make(map[string]*memoryStream),
+		latest:  make(map[string]string),
+		ttl:     ttl,
+		ticker:  time.NewTicker(min(ttl, time.Minute)),
+		done:    make(chan struct{}),
+	}
+	go s.cleanup()
+	return s
+}
+
+func (s *MemoryStore) cleanup() {
+	for {
+		select {
+		case <-s.ticker.C:
+			s.mu.Lock()
+			for id, st := range s.streams {
+				if time.Since(st.info.UpdatedAt) > s.ttl {
+					delete(s.streams, id)
+					if s.latest[st.info.Owner] == id {
+						delete(s.latest, st.info.Owner)
+					}
+				}
+			}
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Create 创建生成
+func (s *MemoryStore) Create(_ context.Context, info *Info) error {
+	now := time.Now()
+	info.CreatedAt, info.UpdatedAt = now, now
+	if info.Status == "" {
+		info.Status = StatusRunning
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams[info.ID] = &memoryStream{info: *info, notify: make(chan struct{})}
+	s.latest[info.Owner] = info.ID
+	return nil
+}
+
+// Get 返回生成的元数据
+func (s *MemoryStore) Get(_ context.Context, id string) (*Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.streams[id]
+	if !ok {
+		return nil, ErrStreamNotFound
+	}
+	info := st.info
+	return &info, nil
+}
+
+// Latest 返回会话键最近发起的生成
+func (s *MemoryStore) Latest(ctx context.Context, owner string) (*Info, error) {
+	s.mu.Lock()
+	id, ok := s.latest[owner]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrStreamNotFound
+	}
+	return s.Get(ctx, id)
+}
+
+// SetStatus 修改生成状态
+func (s *MemoryStore) SetStatus(_ context.Context, id, status string) (*Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.streams[id]
+	if !ok {
+		return nil, ErrStreamNotFound
+	}
+	if st.info.Finished() {
+		return nil, ErrStreamFinished
+	}
+	st.info.Status = status
+	st.info.UpdatedAt = time.Now()
+	st.wake()
+	info := st.info
+	return &info, nil
+}
+
+// Append 追加事件
+func (s *MemoryStore) Append(_ context.Context, id, eventType string, data json.RawMessage) (Event, error) {
+	return s.append(id, "", eventType, data)
+}
+
+// Finish 追加最后一个事件并修改状态
+func (s *MemoryStore) Finish(_ context.Context, id, status, eventType string, data json.RawMessage) (Event, error) {
+	return s.append(id, status, eventType, data)
+}
+
+// append 追加事件，status 不为空时同时修改状态
+func (s *MemoryStore) append(id, status, eventType string, data json.RawMessage) (Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.streams[id]
+	if !ok {
+		return Event{}, ErrStreamNotFound
+	}
+	if st.info.Finished() {
+		return Event{}, ErrStreamFinished
+	}
+	event := Event{ID: st.info.LastEventID + 1, Type: eventType, Data: data}
+	st.events = append(st.events, event)
+	st.info.LastEventID = event.ID
+	st.info.UpdatedAt = time.Now()
+	if status != "" {
+		st.info.Status = status
+	}
+	st.wake()
+	return event, nil
+}
+
+// Read 返回 ID 大于 after 的事件，没有新事件且生成未结束时最多等待 wait
+func (s *MemoryStore) Read(ctx context.Context, id string, after int64, wait time.Duration) ([]Event, error) {
+	var timer <-chan time.Time
+	for {
+		s.mu.Lock()
+		st, ok := s.streams[id]
+		if !ok {
+			s.mu.Unlock()
+			return nil, ErrStreamNotFound
+		}
+		if events := st.after(after); len(events) > 0 || wait <= 0 || st.info.Finished() {
+			s.mu.Unlock()
+			return events, nil
+		}
+		notify := st.notify
+		s.mu.Unlock()
+
+		if timer == nil {
+			t := time.NewTimer(wait)
+			defer t.Stop()
+			timer = t.C
+		}
+		select {
+		case <-notify:
+		case <-timer:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Close 停止定期清理
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() {
+		s.ticker.Stop()
+		close(s.done)
+	})
+	return nil
+}
+
+// after 返回 ID 大于 after 的事件，事件 ID 与下标一一对应
+func (st *memoryStream) after(after int64) []Event {
+	if after < 0 {
+		after = 0
+	}
+	if after >= int64(len(st.events)) {
+		return nil
+	}
+	return append([]Event(nil), st.events[after:]...)
+}
+
+func (st *memoryStream) wake() {
+	close(st.notify)
+	st.notify = make(chan struct{})
+}
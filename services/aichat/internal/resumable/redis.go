@@ -0,0 +1,274 @@
+package resumable
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	weaveconfig "weave/config"
+	"weave/pkg/resilience"
+	"weave/services/aichat/internal/cache"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore Redis 事件存储：元数据保存为 JSON 字符串，事件保存在 Redis Stream 中，条目 ID 为 0-事件ID
+type RedisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisStore 基于缓存配置创建 Redis 事件存储
+func NewRedisStore(ctx context.Context, config *cache.CacheConfig, ttl time.Duration) (*RedisStore, error) {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	addr := config.RedisAddr
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:       addr,
+		Password:   config.RedisPassword,
+		DB:         config.RedisDB,
+		MaxRetries: -1, // 由依赖策略统一重试
+	})
+	client.AddHook(resilience.NewRedisHook(weaveconfig.DependencyRedis))
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return &RedisStore{client: client, ttl: ttl}, nil
+}
+
+// NewRedisStoreWithClient 使用已有的 Redis 客户端创建事件存储
+func NewRedisStoreWithClient(client *redis.Client, ttl time.Duration) *RedisStore {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &RedisStore{client: client, ttl: ttl}
+}
+
+func infoKey(id string) string {
+	return "aichat:stream:" + id
+}
+
+func eventsKey(id string) string {
+	return "aichat:stream:" + id + ":events"
+}
+
+func ownerKey(owner string) string {
+	return "aichat:stream:owner:" + owner
+}
+
+// Create 创建生成
+func (s *RedisStore) Create(ctx context.Context, info *Info) error {
+	now := time.Now()
+	info.CreatedAt, info.UpdatedAt = now, now
+	if info.Status == "" {
+		info.Status = StatusRunning
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	// Owner 不序列化到 API 响应，单独保存
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, infoKey(info.ID), data, s.ttl)
+	pipe.Set(ctx, infoKey(info.ID)+":owner", info.Owner, s.ttl)
+	pipe.Set(ctx, ownerKey(info.Owner), info.ID, s.ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Get 返回生成的元数据
+func (s *RedisStore) Get(ctx context.Context, id string) (*Info, error) {
+	values, err := s.client.MGet(ctx, infoKey(id), infoKey(id)+":owner").Result()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := values[0].(string)
+	if !ok {
+		return nil, ErrStreamNotFound
+	}
+	var info Info
+	if err := json.Unmarshal([]byte(data), &info); err != nil {
+		return nil, err
+	}
+	info.Owner, _ = values[1].(string)
+	return &info, nil
+}
+
+// Latest 返回会话键最近发起的生成
+func (s *RedisStore) Latest(ctx context.Context, owner string) (*Info, error) {
+	id, err := s.client.Get(ctx, ownerKey(owner)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrStreamNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// SetStatus 修改生成状态
+// 状态由生成所在实例和控制请求所在实例共同修改，通过 WATCH 保证已结束的生成不会被改回进行中
+func (s *RedisStore) SetStatus(ctx context.Context, id, status string) (*Info, error) {
+	var updated *Info
+	err := s.watch(ctx, id, func(tx *redis.Tx) error {
+		info, err := s.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if info.Finished() {
+			return ErrStreamFinished
+		}
+		info.Status = status
+		info.UpdatedAt = time.Now()
+		data, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, infoKey(id), data, s.ttl)
+			return nil
+		})
+		updated = info
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Append 追加事件，事件 ID 由元数据中的 last_event_id 递增得到
+func (s *RedisStore) Append(ctx context.Context, id, eventType string, data json.RawMessage) (Event, error) {
+	return s.append(ctx, id, "", eventType, data)
+}
+
+// Finish 在同一事务中追加最后一个事件并修改状态
+func (s *RedisStore) Finish(ctx context.Context, id, status, eventType string, data json.RawMessage) (Event, error) {
+	return s.append(ctx, id, status, eventType, data)
+}
+
+// append 追加事件，status 不为空时同时修改状态
+// 控制请求可能同时修改状态，通过 WATCH 避免互相覆盖
+func (s *RedisStore) append(ctx context.Context, id, status, eventType string, data json.RawMessage) (Event, error) {
+	var event Event
+	err := s.watch(ctx, id, func(tx *redis.Tx) error {
+		info, err := s.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if info.Finished() {
+			return ErrStreamFinished
+		}
+		info.LastEventID++
+		info.UpdatedAt = time.Now()
+		if status != "" {
+			info.Status = status
+		}
+		encoded, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		event = Event{ID: info.LastEventID, Type: eventType, Data: data}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: eventsKey(id),
+				ID:     "0-" + strconv.FormatInt(event.ID, 10),
+				Values: map[string]any{"type": eventType, "data": string(data)},
+			})
+			pipe.Expire(ctx, eventsKey(id), s.ttl)
+			pipe.Set(ctx, infoKey(id), encoded, s.ttl)
+			pipe.Expire(ctx, infoKey(id)+":owner", s.ttl)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// maxWatchRetries 元数据被并发修改时的最大重试次数
+const maxWatchRetries = 10
+
+// watch 在 WATCH 生成元数据的事务中执行 fn，元数据被并发修改时随机退避后重试
+func (s *RedisStore) watch(ctx context.Context, id string, fn func(tx *redis.Tx) error) error {
+	var err error
+	for attempt := range maxWatchRetries {
+		if err = s.client.Watch(ctx, fn, infoKey(id)); !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+		// 随机退避，避免并发写入方同时重试再次冲突
+		select {
+		case <-time.After(time.Duration(rand.Int64N(int64(attempt+1) * int64(2*time.Millisecond)))):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// Read 返回 ID 大于 after 的事件，没有新事件且生成未结束时通过 XREAD BLOCK 最多等待 wait
+func (s *RedisStore) Read(ctx context.Context, id string, after int64, wait time.Duration) ([]Event, error) {
+	info, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	start := "0-" + strconv.FormatInt(max(after, 0)+1, 10)
+	messages, err := s.client.XRange(ctx, eventsKey(id), start, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) > 0 || wait <= 0 || info.Finished() {
+		return toEvents(messages)
+	}
+
+	// 阻塞时间不足 1 毫秒时 Redis 会一直阻塞
+	streams, err := s.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{eventsKey(id), "0-" + strconv.FormatInt(max(after, 0), 10)},
+		Block:   max(wait, time.Millisecond),
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 {
+		return nil, nil
+	}
+	return toEvents(streams[0].Messages)
+}
+
+// Close 关闭 Redis 连接
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+func toEvents(messages []redis.XMessage) ([]Event, error) {
+	events := make([]Event, 0, len(messages))
+	for _, msg := range messages {
+		_, seq, ok := strings.Cut(msg.ID, "-")
+		if !ok {
+			return nil, fmt.Errorf("unexpected stream entry id %q", msg.ID)
+		}
+		id, err := strconv.ParseInt(seq, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected stream entry id %q", msg.ID)
+		}
+		eventType, _ := msg.Values["type"].(string)
+		data, _ := msg.Values["data"].(string)
+		events = append(events, Event{ID: id, Type: eventType, Data: json.RawMessage(data)})
+	}
+	return events, nil
+}
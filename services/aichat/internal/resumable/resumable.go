@@ -0,0 +1,127 @@
+package resumable
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"weave/pkg"
+	"weave/services/aichat/internal/cache"
+
+	"github.com/spf13/viper"
+)
+
+// 生成状态
+const (
+	StatusRunning = "running"
+	StatusPaused  = "paused"
+	// StatusStopping 已请求停止，生成所在实例停止生成后改为 stopped
+	StatusStopping  = "stopping"
+	StatusStopped   = "stopped"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// 事件类型
+const (
+	// EventChunk 回复的文本分块
+	EventChunk = "chunk"
+	// EventToolCall Agent 调用了工具
+	EventToolCall = "tool_call"
+	// EventControl 生成被暂停、恢复或停止
+	EventControl = "control"
+	// EventCompleted 生成结束，之后不再有事件
+	EventCompleted = "completed"
+	// EventError 生成失败，之后不再有事件
+	EventError = "error"
+)
+
+// defaultTTL 生成结束或无活动后事件保留的默认时长
+const defaultTTL = 30 * time.Minute
+
+var (
+	// ErrStreamNotFound 生成不存在或已过期
+	ErrStreamNotFound = errors.New("stream not found")
+	// ErrStreamFinished 生成已经结束，不能再追加事件或修改状态
+	ErrStreamFinished = errors.New("stream already finished")
+)
+
+// Info 一次生成的元数据
+type Info struct {
+	ID             string    `json:"id"`
+	Owner          string    `json:"-"` // 发起生成的会话键，只有发起者可以读取和控制
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Status         string    `json:"status"`
+	LastEventID    int64     `json:"last_event_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Finished 生成是否已经结束
+func (i *Info) Finished() bool {
+	return Finished(i.Status)
+}
+
+// Finished 状态是否为结束状态，只有生成所在实例会把状态改为结束状态
+func Finished(status string) bool {
+	return status == StatusStopped || status == StatusCompleted || status == StatusFailed
+}
+
+// Terminal 事件是否为生成的最后一个事件
+func Terminal(eventType string) bool {
+	return eventType == EventCompleted || eventType == EventError
+}
+
+// Event 生成过程中的一个事件，ID 在同一生成内从 1 开始递增，用作 SSE 的 id 字段
+type Event struct {
+	ID   int64           `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Store 保存生成的状态和事件，客户端断线后可以从任意事件之后继续读取
+// 多实例部署时使用 Redis 实现，生成和读取可以在不同实例上进行
+type Store interface {
+	// Create 创建生成
+	Create(ctx context.Context, info *Info) error
+	// Get 返回生成的元数据
+	Get(ctx context.Context, id string) (*Info, error)
+	// Latest 返回会话键最近发起的生成，没有时返回 ErrStreamNotFound
+	Latest(ctx context.Context, owner string) (*Info, error)
+	// SetStatus 修改生成状态，生成已结束时返回 ErrStreamFinished
+	SetStatus(ctx context.Context, id, status string) (*Info, error)
+	// Append 追加事件并返回带 ID 的事件，生成已结束时返回 ErrStreamFinished
+	Append(ctx context.Context, id, eventType string, data json.RawMessage) (Event, error)
+	// Finish 追加最后一个事件并把状态改为结束状态，读到最后一个事件的客户端查询状态时生成已经结束
+	Finish(ctx context.Context, id, status, eventType string, data json.RawMessage) (Event, error)
+	// Read 返回 ID 大于 after 的事件，没有新事件时最多等待 wait
+	Read(ctx context.Context, id string, after int64, wait time.Duration) ([]Event, error)
+	// Close 释放资源
+	Close() error
+}
+
+// NewID 生成新的生成 ID
+func NewID() string {
+	return "strm_" + pkg.RandomString(24)
+}
+
+// TTLFromConfig 读取 AICHAT_STREAM_TTL_MINUTES，生成结束或无活动后事件保留的时长
+func TTLFromConfig() time.Duration {
+	if minutes := viper.GetInt("AICHAT_STREAM_TTL_MINUTES"); minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultTTL
+}
+
+// NewStore 按缓存配置创建事件存储：CACHE_TYPE 为 redis 时使用 Redis，否则使用内存
+func NewStore(ctx context.Context, config *cache.CacheConfig, ttl time.Duration) (Store, error) {
+	if config == nil {
+		defaults := cache.GetDefaultCacheConfig()
+		config = &defaults
+	}
+	if config.CacheType == "redis" {
+		return NewRedisStore(ctx, config, ttl)
+	}
+	return NewMemoryStore(ttl), nil
+}
@@ -0,0 +1,309 @@
+package resumable
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testStore 测试用的事件存储，expire 使存储中的生成全部过期
+type testStore struct {
+	Store
+	expire func(t *testing.T)
+}
+
+// testStores 返回内存和 Redis 两种实现，两者应满足同样的约定
+func testStores(ttl time.Duration) map[string]func(t *testing.T) testStore {
+	return map[string]func(t *testing.T) testStore{
+		"memory": func(t *testing.T) testStore {
+			s := NewMemoryStore(ttl)
+			t.Cleanup(func() { s.Close() })
+			return testStore{Store: s, expire: func(t *testing.T) {
+				// 定期清理的间隔不超过 ttl
+				time.Sleep(3 * ttl)
+			}}
+		},
+		"redis": func(t *testing.T) testStore {
+			s, mr := newTestRedisStore(t, ttl)
+			return testStore{Store: s, expire: func(t *testing.T) { mr.FastForward(ttl + time.Second) }}
+		},
+	}
+}
+
+func newTestRedisStore(t *testing.T, ttl time.Duration) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	s := NewRedisStoreWithClient(redis.NewClient(&redis.Options{Addr: mr.Addr()}), ttl)
+	t.Cleanup(func() { s.Close() })
+	return s, mr
+}
+
+func createStream(t *testing.T, s Store, id, owner string) {
+	t.Helper()
+	if err := s.Create(context.Background(), &Info{ID: id, Owner: owner, ConversationID: "conv"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+}
+
+func chunk(text string) json.RawMessage {
+	data, _ := json.Marshal(map[string]string{"content": text})
+	return data
+}
+
+func TestStoreAppendReadAndFinish(t *testing.T) {
+	ctx := context.Background()
+	for name, newStore := range testStores(time.Hour) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			if _, err := s.Get(ctx, "strm_missing"); !errors.Is(err, ErrStreamNotFound) {
+				t.Fatalf("get missing: %v", err)
+			}
+			if _, err := s.Latest(ctx, "user-1"); !errors.Is(err, ErrStreamNotFound) {
+				t.Fatalf("latest missing: %v", err)
+			}
+
+			createStream(t, s, "strm_a", "user-1")
+			for i, text := range []string{"你好，", "我是", "助手。"} {
+				event, err := s.Append(ctx, "strm_a", EventChunk, chunk(text))
+				if err != nil || event.ID != int64(i+1) {
+					t.Fatalf("append %d: %+v %v", i, event, err)
+				}
+			}
+
+			events, err := s.Read(ctx, "strm_a", 0, 0)
+			if err != nil || len(events) != 3 {
+				t.Fatalf("read all: %+v %v", events, err)
+			}
+			if events[2].ID != 3 || events[2].Type != EventChunk || string(events[2].Data) != string(chunk("助手。")) {
+				t.Fatalf("unexpected event: %+v", events[2])
+			}
+			// 从中间的游标继续读取
+			events, err = s.Read(ctx, "strm_a", 2, 0)
+			if err != nil || len(events) != 1 || events[0].ID != 3 {
+				t.Fatalf("read after 2: %+v %v", events, err)
+			}
+
+			info, err := s.SetStatus(ctx, "strm_a", StatusPaused)
+			if err != nil || info.Status != StatusPaused || info.LastEventID != 3 {
+				t.Fatalf("pause: %+v %v", info, err)
+			}
+			event, err := s.Finish(ctx, "strm_a", StatusCompleted, EventCompleted, json.RawMessage(`{}`))
+			if err != nil || event.ID != 4 {
+				t.Fatalf("finish: %+v %v", event, err)
+			}
+
+			// 读到最后一个事件时生成已经结束
+			info, err = s.Latest(ctx, "user-1")
+			if err != nil || info.ID != "strm_a" || info.Owner != "user-1" || !info.Finished() || info.LastEventID != 4 {
+				t.Fatalf("latest after finish: %+v %v", info, err)
+			}
+			events, err = s.Read(ctx, "strm_a", 3, time.Second)
+			if err != nil || len(events) != 1 || !Terminal(events[0].Type) {
+				t.Fatalf("read finish marker: %+v %v", events, err)
+			}
+			// 已结束的生成不等待新事件
+			start := time.Now()
+			if events, err := s.Read(ctx, "strm_a", 4, time.Second); err != nil || len(events) != 0 || time.Since(start) > 500*time.Millisecond {
+				t.Fatalf("read after finish: %+v %v %v", events, err, time.Since(start))
+			}
+
+			if _, err := s.Append(ctx, "strm_a", EventChunk, chunk("多余")); !errors.Is(err, ErrStreamFinished) {
+				t.Fatalf("append after finish: %v", err)
+			}
+			if _, err := s.SetStatus(ctx, "strm_a", StatusRunning); !errors.Is(err, ErrStreamFinished) {
+				t.Fatalf("set status after finish: %v", err)
+			}
+			if _, err := s.Finish(ctx, "strm_a", StatusFailed, EventError, json.RawMessage(`{}`)); !errors.Is(err, ErrStreamFinished) {
+				t.Fatalf("finish twice: %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreReadWaitsFromCursor(t *testing.T) {
+	ctx := context.Background()
+	for name, newStore := range testStores(time.Hour) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			createStream(t, s, "strm_b", "user-1")
+			for _, text := range []string{"一", "二"} {
+				if _, err := s.Append(ctx, "strm_b", EventChunk, chunk(text)); err != nil {
+					t.Fatalf("append: %v", err)
+				}
+			}
+
+			// 没有新事件时等待超时返回空
+			if events, err := s.Read(ctx, "strm_b", 2, 50*time.Millisecond); err != nil || len(events) != 0 {
+				t.Fatalf("read timeout: %+v %v", events, err)
+			}
+
+			type result struct {
+				events []Event
+				err    error
+			}
+			done := make(chan result, 1)
+			go func() {
+				events, err := s.Read(ctx, "strm_b", 2, 5*time.Second)
+				done <- result{events, err}
+			}()
+			time.Sleep(100 * time.Millisecond)
+			if _, err := s.Append(ctx, "strm_b", EventChunk, chunk("三")); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+
+			select {
+			case r := <-done:
+				if r.err != nil || len(r.events) != 1 || r.events[0].ID != 3 || string(r.events[0].Data) != string(chunk("三")) {
+					t.Fatalf("blocked read: %+v %v", r.events, r.err)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("blocked read was not woken by append")
+			}
+		})
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	for name, newStore := range testStores(100 * time.Millisecond) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			createStream(t, s, "strm_c", "user-1")
+			if _, err := s.Append(ctx, "strm_c", EventChunk, chunk("一")); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+
+			s.expire(t)
+			if _, err := s.Get(ctx, "strm_c"); !errors.Is(err, ErrStreamNotFound) {
+				t.Fatalf("get after expiry: %v", err)
+			}
+			if _, err := s.Latest(ctx, "user-1"); !errors.Is(err, ErrStreamNotFound) {
+				t.Fatalf("latest after expiry: %v", err)
+			}
+			if _, err := s.Read(ctx, "strm_c", 0, 0); !errors.Is(err, ErrStreamNotFound) {
+				t.Fatalf("read after expiry: %v", err)
+			}
+			if _, err := s.Append(ctx, "strm_c", EventChunk, chunk("二")); !errors.Is(err, ErrStreamNotFound) {
+				t.Fatalf("append after expiry: %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreConcurrentAppends(t *testing.T) {
+	ctx := context.Background()
+	const writers, perWriter = 3, 20
+	for name, newStore := range testStores(time.Hour) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			createStream(t, s, "strm_d", "user-1")
+
+			var wg sync.WaitGroup
+			errs := make(chan error, writers*perWriter)
+			for w := range writers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range perWriter {
+						if _, err := s.Append(ctx, "strm_d", EventChunk, chunk(fmt.Sprintf("%d-%d", w, i))); err != nil {
+							errs <- err
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Fatalf("concurrent append: %v", err)
+			}
+
+			// 事件 ID 连续且不重复，元数据与事件一致
+			events, err := s.Read(ctx, "strm_d", 0, 0)
+			if err != nil || len(events) != writers*perWriter {
+				t.Fatalf("read: %d events, %v", len(events), err)
+			}
+			seen := make(map[string]bool, len(events))
+			for i, event := range events {
+				if event.ID != int64(i+1) || seen[string(event.Data)] {
+					t.Fatalf("event %d: %+v", i, event)
+				}
+				seen[string(event.Data)] = true
+			}
+			info, err := s.Get(ctx, "strm_d")
+			if err != nil || info.LastEventID != writers*perWriter {
+				t.Fatalf("info: %+v %v", info, err)
+			}
+		})
+	}
+}
+
+func TestStoreFinishWinsOverConcurrentStatusChange(t *testing.T) {
+	ctx := context.Background()
+	for name, newStore := range testStores(time.Hour) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			for i := range 20 {
+				id := fmt.Sprintf("strm_e%d", i)
+				createStream(t, s, id, "user-1")
+
+				var wg sync.WaitGroup
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					s.SetStatus(ctx, id, StatusPaused)
+				}()
+				go func() {
+					defer wg.Done()
+					if _, err := s.Finish(ctx, id, StatusStopped, EventControl, json.RawMessage(`{}`)); err != nil {
+						t.Errorf("finish: %v", err)
+					}
+				}()
+				wg.Wait()
+
+				// 控制请求晚于 Finish 时不能把已结束的生成改回未结束状态
+				info, err := s.Get(ctx, id)
+				if err != nil || info.Status != StatusStopped {
+					t.Fatalf("status after race: %+v %v", info, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisStoreLayout(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestRedisStore(t, 10*time.Minute)
+	createStream(t, s, "strm_f", "user-1")
+	for _, text := range []string{"一", "二"} {
+		if _, err := s.Append(ctx, "strm_f", EventChunk, chunk(text)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	// 条目 ID 为 0-事件ID，其他实例可以直接按事件 ID 读取
+	entries, err := mr.Stream(eventsKey("strm_f"))
+	if err != nil || len(entries) != 2 || entries[0].ID != "0-1" || entries[1].ID != "0-2" {
+		t.Fatalf("stream entries: %+v %v", entries, err)
+	}
+	if owner, err := mr.Get(ownerKey("user-1")); err != nil || owner != "strm_f" {
+		t.Fatalf("owner key: %q %v", owner, err)
+	}
+
+	// 每次追加刷新所有键的过期时间
+	mr.FastForward(5 * time.Minute)
+	if _, err := s.Append(ctx, "strm_f", EventChunk, chunk("三")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	for _, key := range []string{infoKey("strm_f"), infoKey("strm_f") + ":owner", eventsKey("strm_f")} {
+		if ttl := mr.TTL(key); ttl != 10*time.Minute {
+			t.Fatalf("ttl of %s = %v", key, ttl)
+		}
+	}
+}
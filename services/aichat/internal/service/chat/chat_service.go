@@ -3,6 +3,11 @@ package chat
 import (
 	"context"
 
+	"weave/services/aichat/internal/memory"
+	"weave/services/aichat/internal/model"
+	"weave/services/aichat/internal/prompts"
+	"weave/services/aichat/internal/usage"
+
 	"github.com/cloudwego/eino/schema"
 )
 
@@ -12,27 +17,56 @@ type ChatService interface {
 	Initialize(ctx context.Context) error
 
 	// ProcessUserInput 处理用户输入并生成回复
-	ProcessUserInput(ctx context.Context, userInput string, userID string) (string, error)
+	// conversationID 为空时使用 ResolveConversation 选择的对话，下同
+	ProcessUserInput(ctx context.Context, userInput string, userID string, conversationID string) (string, error)
 
 	// ProcessUserInputWithImages 处理用户输入（包含图片）并生成回复
-	ProcessUserInputWithImages(ctx context.Context, userInput string, userID string, imageURLs []string, base64Images []string) (string, error)
+	ProcessUserInputWithImages(ctx context.Context, userInput string, userID string, conversationID string, imageURLs []string, base64Images []string) (string, error)
 
 	// ProcessUserInputStream 流式处理用户输入并生成回复
-	ProcessUserInputStream(ctx context.Context, userInput string, userID string,
+	ProcessUserInputStream(ctx context.Context, userInput string, userID string, conversationID string,
 		streamCallback func(content string, isToolCall bool) error,
 		controlCallback func() (bool, bool)) (string, error)
 
 	// ProcessUserInputStreamWithImages 流式处理用户输入（包含图片）并生成回复
-	ProcessUserInputStreamWithImages(ctx context.Context, userInput string, userID string, imageURLs []string, base64Images []string,
+	ProcessUserInputStreamWithImages(ctx context.Context, userInput string, userID string, conversationID string, imageURLs []string, base64Images []string,
 		streamCallback func(content string, isToolCall bool) error,
 		controlCallback func() (bool, bool)) (string, error)
 
-	// GetChatHistory 获取用户对话历史
-	GetChatHistory(ctx context.Context, userID string) ([]*schema.Message, error)
+	// ResolveConversation 返回本次对话使用的对话：指定 conversationID 时加载该对话，
+	// 否则沿用最近的未归档对话，超过6小时无活动或没有对话时创建新对话
+	ResolveConversation(ctx context.Context, userID string, conversationID string) (*model.Conversation, error)
+
+	// Conversations 返回对话管理器，用于对话的增删改查、分叉和搜索
+	Conversations() model.ConversationManager
+
+	// GetChatHistory 获取对话历史，conversationID 为空时返回最近的未归档对话
+	GetChatHistory(ctx context.Context, userID string, conversationID string) ([]*schema.Message, error)
 
-	// ClearChatHistory 清除用户对话历史
+	// ClearChatHistory 删除用户的所有对话
 	ClearChatHistory(ctx context.Context, userID string) error
 
+	// Complete 执行无状态的对话补全，历史消息由调用方维护，用于 OpenAI 兼容接口
+	Complete(ctx context.Context, req *CompletionRequest) (*CompletionResult, error)
+
+	// CompleteStream 流式执行对话补全，chunkCallback 接收每个增量消息
+	CompleteStream(ctx context.Context, req *CompletionRequest, chunkCallback func(chunk *schema.Message) error) (*CompletionResult, error)
+
+	// Models 返回可用的聊天模型
+	Models() []ModelInfo
+
+	// Usage 按用户或租户汇总令牌用量和费用
+	Usage(ctx context.Context, filter usage.Filter) (*usage.Summary, error)
+
+	// Prompts 返回提示词模板和人设管理器
+	Prompts() *prompts.Manager
+
+	// Memories 返回用户长期记忆管理器
+	Memories() *memory.Manager
+
+	// PreviewPrompt 渲染对话下一轮请求将发送给模型的消息，input 为假设的用户输入，persona 非空时覆盖对话的人设
+	PreviewPrompt(ctx context.Context, userID, conversationID, input, persona string) (*PromptPreview, error)
+
 	// Close 关闭服务资源
 	Close(ctx context.Context) error
 }
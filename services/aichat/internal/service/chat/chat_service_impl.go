@@ -3,18 +3,28 @@ package chat
 import (
 	"context"
 	"strings"
+	"sync"
 	"time"
 
 	"weave/pkg"
+	"weave/pkg/lifecycle"
 	"weave/services/aichat/internal/cache"
 	"weave/services/aichat/internal/chat"
+	convmanager "weave/services/aichat/internal/conversation"
+	"weave/services/aichat/internal/memory"
 	"weave/services/aichat/internal/model"
 	"weave/services/aichat/internal/model/embedder"
+	"weave/services/aichat/internal/prompts"
 	"weave/services/aichat/internal/security"
+	"weave/services/aichat/internal/usage"
 	aichatpkg "weave/services/aichat/pkg"
 
 	"github.com/cloudwego/eino/components/embedding"
+	einomodel "github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/prompt"
+	einotool "github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/flow/agent"
 	"github.com/cloudwego/eino/flow/agent/react"
 	"github.com/cloudwego/eino/schema"
 	"github.com/spf13/viper"
@@ -23,25 +33,83 @@ import (
 
 // chatServiceImpl 聊天服务实现
 type chatServiceImpl struct {
-	agent               *react.Agent
-	visionAgent         *react.Agent
-	chatCache           cache.Cache
-	embedder            embedding.Embedder
-	chatTemplate        prompt.ChatTemplate
-	logger              *zap.Logger
-	filter              *chat.SensitiveFilter
-	modelType           string
-	rateLimiter         *security.ImageRateLimiter
-	activeConversations map[string]*model.Conversation // 当前活跃对话
-	summaryGenerator    *chat.SimpleSummaryGenerator   // 摘要生成器
-	reranker            *chat.LLMReranker              // LLM重排器
+	agent            *react.Agent
+	visionAgent      *react.Agent
+	chatCache        cache.Cache
+	embedder         embedding.Embedder
+	chatTemplate     prompt.ChatTemplate
+	logger           *zap.Logger
+	filter           *chat.SensitiveFilter
+	modelType        string
+	rateLimiter      *security.ImageRateLimiter
+	conversations    model.ConversationManager      // 对话管理器
+	summaryGenerator *chat.SimpleSummaryGenerator   // BM25摘要生成器，提供关键词提取和BM25召回
+	summarizer       chat.SummaryGenerator          // 生成对话摘要的生成器
+	reranker         *chat.LLMReranker              // LLM重排器
+	chatModel        einomodel.ToolCallingChatModel // 聊天模型，调用方声明工具时直接调用
+	models           []ModelInfo                    // 可用的聊天模型，未使用模型注册表时有效
+	registry         *model.Registry                // 模型注册表
+	usage            *usage.Tracker                 // 令牌用量记录器
+	prompts          *prompts.Manager               // 提示词模板和人设管理器
+	memories         *memory.Manager                // 用户长期记忆管理器
+
+	memoryExtraction bool   // 每轮对话后是否自动提取长期记忆
+	memoryModel      string // 提取记忆使用的模型，为空时按路由规则选择
+	summaryModel     string // LLM摘要使用的模型，为空时按路由规则选择
+
+	structuredRepairs int // 结构化输出未通过校验时最多修正的次数
+
+	background lifecycle.Tracker // 不阻塞响应的后台任务（如提取长期记忆），关闭时等待完成
+	closeOnce  sync.Once
+
+	promptTokenBudget    int // 提示词的令牌预算
+	reservedOutputTokens int // 在模型上下文窗口中为回复预留的令牌数
 }
 
+// conversationIdleTimeout 未指定对话时，最近对话超过该时长无活动则创建新对话
+const conversationIdleTimeout = 6 * time.Hour
+
 // NewChatService 创建聊天服务实例
 func NewChatService() ChatService {
 	return &chatServiceImpl{}
 }
 
+// NewChatServiceWithModel 使用指定的聊天模型和工具创建已初始化的聊天服务
+// 对话只保存在内存缓存中，不加载嵌入器和重排器，用于测试和嵌入式场景
+func NewChatServiceWithModel(ctx context.Context, llm einomodel.ToolCallingChatModel, modelName string, tools []einotool.BaseTool) (ChatService, error) {
+	agent, err := model.NewAgent(ctx, llm, tools)
+	if err != nil {
+		return nil, err
+	}
+	promptManager, err := newPromptManager(nil)
+	if err != nil {
+		return nil, err
+	}
+	chatCache := cache.NewInMemoryCache()
+	s := &chatServiceImpl{
+		agent:            agent,
+		visionAgent:      agent,
+		chatCache:        chatCache,
+		chatTemplate:     aichatpkg.GetTemplate(),
+		logger:           pkg.GetLogger(),
+		filter:           chat.NewSensitiveFilter(),
+		rateLimiter:      security.NewImageRateLimiter(chatCache),
+		conversations:    convmanager.NewManager(chatCache, nil),
+		summaryGenerator: chat.NewBM25SummaryGenerator([]string{}),
+		chatModel:        llm,
+		models:           []ModelInfo{{ID: modelName}},
+		usage:            usage.NewTracker(usage.NewMemoryStore(), nil),
+		prompts:          promptManager,
+	}
+	s.loadBudgetConfig()
+	s.loadStructuredConfig()
+	s.loadSummaryConfig()
+	if s.memories, err = s.newMemoryManager(nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
 // Initialize 初始化服务
 func (s *chatServiceImpl) Initialize(ctx context.Context) error {
 	// 初始化日志
@@ -70,19 +138,16 @@ func (s *chatServiceImpl) Initialize(ctx context.Context) error {
 	}
 	s.logger.Info("创建普通agent成功")
 
-	// 创建视觉agent（仅当使用modelscope时）
-	if s.modelType == "modelscope" {
-		s.visionAgent, err = model.CreateModelScopeVisionAgent(ctx)
-		if err != nil {
-			s.logger.Warn("创建视觉agent失败，将使用普通agent处理图像", zap.Error(err))
-			s.visionAgent = s.agent // 失败时回退到普通agent
-		} else {
-			s.logger.Info("创建视觉agent成功")
-		}
-	} else {
-		// 非modelscope时，使用普通agent处理所有请求
-		s.visionAgent = s.agent
+	// 含图片的请求由模型注册表路由到视觉模型，不再需要单独的视觉agent
+	s.visionAgent = s.agent
+
+	// 聊天模型用于兼容接口中由调用方声明工具的请求，与agent共用模型注册表的路由和回退
+	s.registry, err = model.DefaultRegistry(ctx)
+	if err != nil {
+		s.logger.Error("加载模型注册表失败", zap.Error(err))
+		return err
 	}
+	s.chatModel = s.registry.ChatModel()
 
 	// 初始化缓存
 	s.chatCache, err = cache.NewRedisClient(ctx)
@@ -118,13 +183,66 @@ func (s *chatServiceImpl) Initialize(ctx context.Context) error {
 	s.rateLimiter = security.NewImageRateLimiter(s.chatCache)
 	s.logger.Info("图片上传速率限制器初始化完成")
 
-	// 初始化活跃对话映射
-	s.activeConversations = make(map[string]*model.Conversation)
-	s.logger.Info("活跃对话管理器初始化完成")
+	// 初始化对话管理器：对话同时保存到缓存和数据库，数据库不可用时只保存在缓存中
+	var store convmanager.Store
+	var usageStore usage.Store
+	db, err := convmanager.OpenDB()
+	if err == nil {
+		store, err = convmanager.NewStore(db)
+	}
+	if err != nil {
+		s.logger.Warn("打开对话数据库失败，对话只保存在缓存中", zap.Error(err))
+		store = nil
+	}
+	s.conversations = convmanager.NewManager(s.chatCache, store)
+	s.logger.Info("对话管理器初始化完成")
+
+	// 初始化用量记录：与对话使用同一个数据库，数据库不可用时只保存在内存中
+	if db != nil {
+		if usageStore, err = usage.NewStore(db); err != nil {
+			s.logger.Warn("初始化用量表失败，用量只保存在内存中", zap.Error(err))
+		}
+	}
+	if usageStore == nil {
+		usageStore = usage.NewMemoryStore()
+	}
+	s.usage = usage.NewTracker(usageStore, s.price)
+	s.loadBudgetConfig()
+	s.loadStructuredConfig()
+	s.logger.Info("用量记录初始化完成", zap.Int("prompt_token_budget", s.promptTokenBudget))
+
+	// 初始化提示词模板：与对话使用同一个数据库，数据库不可用时只保存在内存中
+	if db != nil {
+		if s.prompts, err = newPromptManager(db); err != nil {
+			s.logger.Warn("初始化提示词模板表失败，模板只保存在内存中", zap.Error(err))
+		}
+	}
+	if s.prompts == nil {
+		if s.prompts, err = newPromptManager(nil); err != nil {
+			s.logger.Error("初始化提示词模板失败", zap.Error(err))
+			return err
+		}
+	}
+	s.logger.Info("提示词模板初始化完成")
 
 	// 初始化摘要生成器
 	s.summaryGenerator = chat.NewBM25SummaryGenerator([]string{})
-	s.logger.Info("摘要生成器初始化完成")
+	s.loadSummaryConfig()
+	s.logger.Info("摘要生成器初始化完成", zap.String("generator", viper.GetString("AICHAT_SUMMARY_GENERATOR")))
+
+	// 初始化长期记忆：与对话使用同一个数据库，数据库不可用时只保存在内存中
+	if db != nil {
+		if s.memories, err = s.newMemoryManager(db); err != nil {
+			s.logger.Warn("初始化长期记忆表失败，记忆只保存在内存中", zap.Error(err))
+		}
+	}
+	if s.memories == nil {
+		if s.memories, err = s.newMemoryManager(nil); err != nil {
+			s.logger.Error("初始化长期记忆失败", zap.Error(err))
+			return err
+		}
+	}
+	s.logger.Info("长期记忆初始化完成", zap.Bool("extraction", s.memoryExtraction))
 
 	// 初始化LLM重排器
 	if viper.GetBool("AICHAT_ENABLE_RERANK") {
@@ -142,8 +260,8 @@ func (s *chatServiceImpl) Initialize(ctx context.Context) error {
 }
 
 // ProcessUserInput 处理用户输入并生成回复
-func (s *chatServiceImpl) ProcessUserInput(ctx context.Context, userInput string, userID string) (string, error) {
-	return s.processUserInputWithImages(ctx, userInput, userID, nil, nil)
+func (s *chatServiceImpl) ProcessUserInput(ctx context.Context, userInput string, userID string, conversationID string) (string, error) {
+	return s.processUserInputWithImages(ctx, userInput, userID, conversationID, nil, nil)
 }
 
 // updateSummaryGenerator 更新BM25摘要生成器（增量学习）
@@ -159,8 +277,8 @@ func (s *chatServiceImpl) updateSummaryGenerator(conversation *model.Conversatio
 }
 
 // ProcessUserInputWithImages 处理用户输入（包含图片）并生成回复
-func (s *chatServiceImpl) ProcessUserInputWithImages(ctx context.Context, userInput string, userID string, imageURLs []string, base64Images []string) (string, error) {
-	return s.processUserInputWithImages(ctx, userInput, userID, imageURLs, base64Images)
+func (s *chatServiceImpl) ProcessUserInputWithImages(ctx context.Context, userInput string, userID string, conversationID string, imageURLs []string, base64Images []string) (string, error) {
+	return s.processUserInputWithImages(ctx, userInput, userID, conversationID, imageURLs, base64Images)
 }
 
 // buildImageMessage 构造包含图片的多模态消息
@@ -207,8 +325,8 @@ func (s *chatServiceImpl) buildImageMessage(ctx context.Context, userID, text st
 	return &schema.Message{Role: schema.User, UserInputMultiContent: parts}, nil
 }
 
-// prepareMessages 准备发送给模型的消息
-func (s *chatServiceImpl) prepareMessages(ctx context.Context, filteredHistory []*schema.Message, userMessage *schema.Message, filteredInput string, hasImages bool) ([]*schema.Message, error) {
+// prepareMessages 准备发送给模型的消息，使用选中的模板版本渲染系统提示词，包含图片时不使用系统提示词
+func (s *chatServiceImpl) prepareMessages(ctx context.Context, sel *prompts.Selection, filteredHistory []*schema.Message, userMessage *schema.Message, filteredInput string, hasImages bool) ([]*schema.Message, error) {
 	if hasImages {
 		messages := make([]*schema.Message, len(filteredHistory)+1)
 		copy(messages, filteredHistory)
@@ -225,18 +343,13 @@ func (s *chatServiceImpl) prepareMessages(ctx context.Context, filteredHistory [
 		}
 	}
 
-	messages, err := aichatpkg.FormatMessage(ctx, "PaiChat", "积极、温暖且专业", chatHistoryStr.String(), filteredInput)
-	if err != nil {
-		s.logger.Error("模板格式化失败", zap.Error(err))
-		messages = make([]*schema.Message, len(filteredHistory)+1)
-		copy(messages, filteredHistory)
-		messages[len(filteredHistory)] = userMessage
-	}
-	return messages, nil
+	return sel.Render(chatHistoryStr.String(), filteredInput), nil
 }
 
 // processUserInputWithImages 内部方法：处理用户输入（包含图片）并生成回复
-func (s *chatServiceImpl) processUserInputWithImages(ctx context.Context, userInput string, userID string, imageURLs []string, base64Images []string) (string, error) {
+func (s *chatServiceImpl) processUserInputWithImages(ctx context.Context, userInput string, userID string, conversationID string, imageURLs []string, base64Images []string) (string, error) {
+	ctx, route := withServedRoute(ctx)
+
 	// 验证用户输入
 	isValid, errMsg := s.filter.ValidateInput(userInput)
 	if !isValid {
@@ -250,48 +363,12 @@ func (s *chatServiceImpl) processUserInputWithImages(ctx context.Context, userIn
 		s.logger.Info("已过滤用户输入中的敏感内容", zap.String("user_id", userID), zap.String("original_input", userInput), zap.String("filtered_input", filteredInput))
 	}
 
-	// 提取关键词
-	var keywords []string
-	if s.summaryGenerator != nil {
-		keywords = s.summaryGenerator.ExtractKeywords(filteredInput, 5)
-		if len(keywords) > 0 {
-			s.logger.Info("提取到关键词", zap.String("user_id", userID), zap.Strings("keywords", keywords))
-		}
-	}
-
-	// 获取或创建用户的活跃对话
-	conversation := s.getOrCreateConversation(userID)
-
-	// 从结构化对话中获取消息历史
-	chatHistory := conversation.Messages
-
-	// 过滤与当前问题相关的对话历史
-	var filteredHistory []*schema.Message
-
-	// 如果有摘要且历史消息较长，使用摘要替代部分历史消息
-	if conversation.Summary != "" && len(chatHistory) > 30 {
-		summaryMsg := &schema.Message{Role: schema.System, Content: "对话摘要：" + conversation.Summary}
-		filteredHistory = append(filteredHistory, summaryMsg)
-
-		// 只添加最近的10条消息
-		startIdx := len(chatHistory) - 10
-		if startIdx < 0 {
-			startIdx = 0
-		}
-		filteredHistory = append(filteredHistory, chatHistory[startIdx:]...)
-		s.logger.Info("使用摘要作为上下文", zap.String("user_id", userID), zap.Int("message_count", len(chatHistory)))
-	} else {
-		// 多路召回+加权RFF排序+LLM重排 (A路: BM25关键词召回, B路: Embedding语义召回)
-		bm25Calc := s.summaryGenerator.GetBM25Calculator()
-		filteredHistory = chat.FilterRelevantHistoryHybrid(ctx, s.embedder, bm25Calc, s.reranker, chatHistory, filteredInput, 50)
-	}
-
-	// 添加关键词上下文
-	if len(keywords) > 0 {
-		keywordContext := "关键词: " + strings.Join(keywords, ", ")
-		filteredHistory = append(filteredHistory, &schema.Message{Role: schema.System, Content: keywordContext})
-		s.logger.Info("添加关键词上下文", zap.String("user_id", userID))
+	// 获取本次请求使用的对话
+	conversation, err := s.ResolveConversation(ctx, userID, conversationID)
+	if err != nil {
+		return "", err
 	}
+	filteredHistory := s.relevantHistory(ctx, userID, conversation, filteredInput)
 
 	userMessage, err := s.buildImageMessage(ctx, userID, filteredInput, imageURLs, base64Images)
 	if err != nil {
@@ -299,10 +376,20 @@ func (s *chatServiceImpl) processUserInputWithImages(ctx context.Context, userIn
 	}
 
 	hasImages := len(imageURLs) > 0 || len(base64Images) > 0
-	messages, err := s.prepareMessages(ctx, filteredHistory, userMessage, filteredInput, hasImages)
+	var sel *prompts.Selection
+	if !hasImages {
+		sel = s.selectPrompt(ctx, userID, conversation)
+	}
+	filteredHistory = s.fitHistory(ctx, sel, userID, filteredHistory, userMessage, filteredInput, hasImages)
+	messages, err := s.prepareMessages(ctx, sel, filteredHistory, userMessage, filteredInput, hasImages)
+	if err != nil {
+		return "", err
+	}
+	jsonSchema, err := responseSchema(ctx)
 	if err != nil {
 		return "", err
 	}
+	messages = withSchemaInstructions(messages, jsonSchema)
 
 	targetAgent := s.agent
 	if hasImages && s.visionAgent != nil {
@@ -310,7 +397,8 @@ func (s *chatServiceImpl) processUserInputWithImages(ctx context.Context, userIn
 		s.logger.Info("使用视觉agent处理包含图片的请求", zap.String("user_id", userID))
 	}
 
-	streamReader, err := targetAgent.Stream(ctx, messages)
+	collector := &usageCollector{}
+	streamReader, err := targetAgent.Stream(ctx, messages, agent.WithComposeOptions(compose.WithCallbacks(collector.handler())))
 	if err != nil {
 		s.logger.Error("生成回复失败", zap.Error(err), zap.String("user_id", userID))
 		return "", err
@@ -323,55 +411,58 @@ func (s *chatServiceImpl) processUserInputWithImages(ctx context.Context, userIn
 		if recvErr != nil {
 			break
 		}
+		collector.addMessage(message)
 		fullContent.WriteString(message.Content)
 	}
 
 	resultContent := fullContent.String()
-	assistantMessage := schema.AssistantMessage(resultContent, nil)
+	if jsonSchema != nil {
+		if resultContent, err = s.ensureStructured(ctx, userID, jsonSchema, messages, resultContent, agentGenerator(targetAgent, collector)); err != nil {
+			return "", err
+		}
+	}
+	assistantMessage := withPromptTemplate(schema.AssistantMessage(resultContent, nil), sel)
+	s.recordUsage(ctx, route, sel, userID, conversation.ID, messages, assistantMessage, collector)
+	s.rememberTurn(memory.Turn{UserID: userID, ConversationID: conversation.ID, MessageIndex: len(conversation.Messages), UserInput: filteredInput, Reply: resultContent})
 	conversation.AddMessage(userMessage)
 	conversation.AddMessage(assistantMessage)
 
 	// 生成或更新摘要
-	if s.summaryGenerator != nil {
-		// 每5条消息更新一次摘要
-		if len(conversation.Messages)%5 == 0 {
-			summary, summaryErr := conversation.GenerateSummary(s.summaryGenerator)
-			if summaryErr != nil {
-				s.logger.Warn("生成摘要失败", zap.Error(summaryErr), zap.String("user_id", userID))
-			} else if summary != "" {
-				s.logger.Info("生成对话摘要成功", zap.String("user_id", userID), zap.Int("message_count", len(conversation.Messages)))
-			}
-		}
+	s.summarizeConversation(userID, conversation)
 
-		// 增量更新BM25词汇表
+	// 增量更新BM25词汇表
+	if s.summaryGenerator != nil {
 		s.updateSummaryGenerator(conversation)
 	}
 
-	if err := s.chatCache.SaveConversation(ctx, conversation); err != nil {
-		s.logger.Warn("保存结构化对话失败", zap.Error(err), zap.String("user_id", userID))
+	if err := s.conversations.SaveConversation(ctx, conversation); err != nil {
+		s.logger.Warn("保存结构化对话失败", zap.Error(err), zap.String("user_id", userID), zap.String("conversation_id", conversation.ID))
+	} else {
+		s.foldSummary(ctx, userID, conversation)
 	}
 
 	return resultContent, nil
 }
 
 // ProcessUserInputStream 流式处理用户输入并生成回复
-func (s *chatServiceImpl) ProcessUserInputStream(ctx context.Context, userInput string, userID string,
+func (s *chatServiceImpl) ProcessUserInputStream(ctx context.Context, userInput string, userID string, conversationID string,
 	streamCallback func(content string, isToolCall bool) error,
 	controlCallback func() (bool, bool)) (string, error) {
-	return s.processUserInputStreamWithImages(ctx, userInput, userID, nil, nil, streamCallback, controlCallback)
+	return s.processUserInputStreamWithImages(ctx, userInput, userID, conversationID, nil, nil, streamCallback, controlCallback)
 }
 
 // ProcessUserInputStreamWithImages 流式处理用户输入（包含图片）并生成回复
-func (s *chatServiceImpl) ProcessUserInputStreamWithImages(ctx context.Context, userInput string, userID string, imageURLs []string, base64Images []string,
+func (s *chatServiceImpl) ProcessUserInputStreamWithImages(ctx context.Context, userInput string, userID string, conversationID string, imageURLs []string, base64Images []string,
 	streamCallback func(content string, isToolCall bool) error,
 	controlCallback func() (bool, bool)) (string, error) {
-	return s.processUserInputStreamWithImages(ctx, userInput, userID, imageURLs, base64Images, streamCallback, controlCallback)
+	return s.processUserInputStreamWithImages(ctx, userInput, userID, conversationID, imageURLs, base64Images, streamCallback, controlCallback)
 }
 
 // processUserInputStreamWithImages 内部方法：流式处理用户输入（包含图片）并生成回复
-func (s *chatServiceImpl) processUserInputStreamWithImages(ctx context.Context, userInput string, userID string, imageURLs []string, base64Images []string,
+func (s *chatServiceImpl) processUserInputStreamWithImages(ctx context.Context, userInput string, userID string, conversationID string, imageURLs []string, base64Images []string,
 	streamCallback func(content string, isToolCall bool) error,
 	controlCallback func() (bool, bool)) (string, error) {
+	ctx, route := withServedRoute(ctx)
 
 	isValid, errMsg := s.filter.ValidateInput(userInput)
 	if !isValid {
@@ -393,12 +484,19 @@ func (s *chatServiceImpl) processUserInputStreamWithImages(ctx context.Context,
 		}
 	}
 
-	conversation := s.getOrCreateConversation(userID)
+	conversation, err := s.ResolveConversation(ctx, userID, conversationID)
+	if err != nil {
+		return "", err
+	}
 	chatHistory := conversation.Messages
 
 	bm25Calc := s.summaryGenerator.GetBM25Calculator()
 	filteredHistory := chat.FilterRelevantHistoryHybrid(ctx, s.embedder, bm25Calc, s.reranker, chatHistory, filteredInput, 50)
 
+	if memoryMsg := s.memoryContext(ctx, userID, filteredInput); memoryMsg != nil {
+		filteredHistory = append(filteredHistory, memoryMsg)
+	}
+
 	if len(keywords) > 0 {
 		keywordContext := "关键词: " + strings.Join(keywords, ", ")
 		filteredHistory = append(filteredHistory, &schema.Message{Role: schema.System, Content: keywordContext})
@@ -411,10 +509,20 @@ func (s *chatServiceImpl) processUserInputStreamWithImages(ctx context.Context,
 	}
 
 	hasImages := len(imageURLs) > 0 || len(base64Images) > 0
-	messages, err := s.prepareMessages(ctx, filteredHistory, userMessage, filteredInput, hasImages)
+	var sel *prompts.Selection
+	if !hasImages {
+		sel = s.selectPrompt(ctx, userID, conversation)
+	}
+	filteredHistory = s.fitHistory(ctx, sel, userID, filteredHistory, userMessage, filteredInput, hasImages)
+	messages, err := s.prepareMessages(ctx, sel, filteredHistory, userMessage, filteredInput, hasImages)
 	if err != nil {
 		return "", err
 	}
+	jsonSchema, err := responseSchema(ctx)
+	if err != nil {
+		return "", err
+	}
+	messages = withSchemaInstructions(messages, jsonSchema)
 
 	targetAgent := s.agent
 	if hasImages && s.visionAgent != nil {
@@ -424,7 +532,8 @@ func (s *chatServiceImpl) processUserInputStreamWithImages(ctx context.Context,
 
 	// 生成回复（使用流式输出）
 	s.logger.Info("开始生成流式回复", zap.String("user_id", userID))
-	streamReader, err := targetAgent.Stream(ctx, messages)
+	collector := &usageCollector{}
+	streamReader, err := targetAgent.Stream(ctx, messages, agent.WithComposeOptions(compose.WithCallbacks(collector.handler())))
 	if err != nil {
 		s.logger.Error("生成回复失败", zap.Error(err), zap.String("user_id", userID))
 		return "", err
@@ -433,11 +542,14 @@ func (s *chatServiceImpl) processUserInputStreamWithImages(ctx context.Context,
 
 	// 实时处理流式输出
 	var fullContent strings.Builder
+	var answer strings.Builder // 不含工具调用提示的回复内容，用于结构化输出校验
+	stopped := false
 
 	for {
 		// 检查控制信号
 		isPaused, isStopped := controlCallback()
 		if isStopped {
+			stopped = true
 			break
 		}
 
@@ -446,6 +558,7 @@ func (s *chatServiceImpl) processUserInputStreamWithImages(ctx context.Context,
 			if recvErr != nil {
 				break
 			}
+			collector.addMessage(message)
 
 			// 检查是否有工具调用
 			isToolCall := len(message.ToolCalls) > 0
@@ -476,119 +589,144 @@ func (s *chatServiceImpl) processUserInputStreamWithImages(ctx context.Context,
 
 			// 处理消息内容（包括工具执行结果）
 			if message.Content != "" {
-				if callbackErr := streamCallback(message.Content, false); callbackErr != nil {
-					return "", callbackErr
+				// 结构化输出在校验通过后一次性发送
+				if jsonSchema == nil {
+					if callbackErr := streamCallback(message.Content, false); callbackErr != nil {
+						return "", callbackErr
+					}
 				}
 				fullContent.WriteString(message.Content)
+				answer.WriteString(message.Content)
 			}
 		}
 	}
 
 	// 更新结构化对话
 	resultContent := fullContent.String()
-	assistantMessage := schema.AssistantMessage(resultContent, nil)
+	if jsonSchema != nil && !stopped {
+		if resultContent, err = s.ensureStructured(ctx, userID, jsonSchema, messages, answer.String(), agentGenerator(targetAgent, collector)); err != nil {
+			return "", err
+		}
+		if callbackErr := streamCallback(resultContent, false); callbackErr != nil {
+			return "", callbackErr
+		}
+	}
+	assistantMessage := withPromptTemplate(schema.AssistantMessage(resultContent, nil), sel)
+	if stopped {
+		// 中途停止时模型可能仍在输出，等回调结束后再记录用量，不阻塞响应
+		go s.recordUsage(context.WithoutCancel(ctx), route, sel, userID, conversation.ID, messages, assistantMessage, collector)
+	} else {
+		s.recordUsage(ctx, route, sel, userID, conversation.ID, messages, assistantMessage, collector)
+	}
+	if !stopped {
+		s.rememberTurn(memory.Turn{UserID: userID, ConversationID: conversation.ID, MessageIndex: len(conversation.Messages), UserInput: filteredInput, Reply: resultContent})
+	}
 	conversation.AddMessage(userMessage)
 	conversation.AddMessage(assistantMessage)
 
 	// 生成或更新摘要
-	if s.summaryGenerator != nil {
-		// 每5条消息更新一次摘要
-		if len(conversation.Messages)%5 == 0 {
-			summary, summaryErr := conversation.GenerateSummary(s.summaryGenerator)
-			if summaryErr != nil {
-				s.logger.Warn("生成摘要失败", zap.Error(summaryErr), zap.String("user_id", userID))
-			} else if summary != "" {
-				s.logger.Info("生成对话摘要成功", zap.String("user_id", userID), zap.Int("message_count", len(conversation.Messages)))
-			}
-		}
-	}
+	s.summarizeConversation(userID, conversation)
 
-	// 保存结构化对话到缓存
-	err = s.chatCache.SaveConversation(ctx, conversation)
+	// 保存结构化对话到缓存和数据库
+	err = s.conversations.SaveConversation(ctx, conversation)
 	if err != nil {
-		s.logger.Warn("保存结构化对话失败", zap.Error(err), zap.String("user_id", userID))
+		s.logger.Warn("保存结构化对话失败", zap.Error(err), zap.String("user_id", userID), zap.String("conversation_id", conversation.ID))
 		// 保存失败不影响返回结果
+	} else {
+		s.foldSummary(ctx, userID, conversation)
 	}
 
 	return resultContent, nil
 }
 
-// GetChatHistory 获取用户对话历史
-func (s *chatServiceImpl) GetChatHistory(ctx context.Context, userID string) ([]*schema.Message, error) {
-	// 从活跃对话中获取消息历史
-	if conv, exists := s.activeConversations[userID]; exists {
-		return conv.Messages, nil
+// GetChatHistory 获取对话历史，conversationID 为空时返回最近的未归档对话
+func (s *chatServiceImpl) GetChatHistory(ctx context.Context, userID string, conversationID string) ([]*schema.Message, error) {
+	if conversationID == "" {
+		latest, err := s.latestConversation(ctx, userID)
+		if err != nil {
+			s.logger.Warn("加载用户对话失败", zap.Error(err), zap.String("user_id", userID))
+			return nil, err
+		}
+		if latest == nil {
+			return []*schema.Message{}, nil
+		}
+		return latest.Messages, nil
 	}
 
-	// 如果没有活跃对话，尝试从缓存加载
-	conversations, err := s.chatCache.LoadUserConversations(ctx, userID)
+	conv, err := s.conversations.GetConversation(ctx, userID, conversationID)
 	if err != nil {
-		s.logger.Warn("加载用户对话失败", zap.Error(err), zap.String("user_id", userID))
-		return []*schema.Message{}, nil
+		return nil, err
 	}
+	return conv.Messages, nil
+}
 
-	// 如果有对话，返回最近的对话消息
-	if len(conversations) > 0 {
-		if conv, ok := conversations[0].(*model.Conversation); ok {
-			return conv.Messages, nil
-		}
+// ResolveConversation 返回本次对话使用的对话
+func (s *chatServiceImpl) ResolveConversation(ctx context.Context, userID string, conversationID string) (*model.Conversation, error) {
+	if conversationID != "" {
+		return s.conversations.GetConversation(ctx, userID, conversationID)
 	}
 
-	return []*schema.Message{}, nil
+	// 未指定对话时沿用最近的未归档对话，超过6小时无活动则创建新对话
+	latest, err := s.latestConversation(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && time.Since(latest.EndTime) <= conversationIdleTimeout {
+		return latest, nil
+	}
+	return s.conversations.CreateConversation(ctx, userID, "")
 }
 
-// getOrCreateConversation 获取或创建用户的结构化对话
-func (s *chatServiceImpl) getOrCreateConversation(userID string) *model.Conversation {
-	// 检查是否已有活跃对话
-	if conv, exists := s.activeConversations[userID]; exists {
-		// 检查最后活动时间，超过6小时无活动则创建新对话
-		if time.Since(conv.EndTime) > 6*time.Hour {
-			// 创建新对话
-			newConv := model.NewConversation(userID)
-			s.activeConversations[userID] = newConv
-			return newConv
-		}
-		return conv
+// latestConversation 返回用户最近活动的未归档对话，没有时返回 nil
+func (s *chatServiceImpl) latestConversation(ctx context.Context, userID string) (*model.Conversation, error) {
+	archived := false
+	convs, _, err := s.conversations.ListConversations(ctx, userID, model.ConversationFilter{Archived: &archived, Limit: 1})
+	if err != nil || len(convs) == 0 {
+		return nil, err
 	}
+	return s.conversations.GetConversation(ctx, userID, convs[0].ID)
+}
 
-	// 创建新对话
-	conv := model.NewConversation(userID)
-	s.activeConversations[userID] = conv
-
-	return conv
+// Conversations 返回对话管理器
+func (s *chatServiceImpl) Conversations() model.ConversationManager {
+	return s.conversations
 }
 
-// ClearChatHistory 清除用户对话历史
+// ClearChatHistory 删除用户的所有对话
 func (s *chatServiceImpl) ClearChatHistory(ctx context.Context, userID string) error {
-	// 清除用户的活跃对话
-	delete(s.activeConversations, userID)
-
-	// 清除缓存中的结构化对话
-	conversations, err := s.chatCache.LoadUserConversations(ctx, userID)
+	convs, _, err := s.conversations.ListConversations(ctx, userID, model.ConversationFilter{})
 	if err != nil {
 		s.logger.Warn("加载用户对话失败", zap.Error(err), zap.String("user_id", userID))
 		return err
 	}
 
-	// 删除每个对话
-	for _, conv := range conversations {
-		if _, ok := conv.(*model.Conversation); ok {
-			// 可以添加删除单个对话的方法到缓存接口
-			// 目前只清除活跃对话，保留缓存中的对话
+	for _, conv := range convs {
+		if err := s.conversations.DeleteConversation(ctx, userID, conv.ID); err != nil {
+			s.logger.Warn("删除对话失败", zap.Error(err), zap.String("user_id", userID), zap.String("conversation_id", conv.ID))
+			return err
 		}
 	}
 
-	s.logger.Info("已清除用户对话历史", zap.String("user_id", userID))
+	s.logger.Info("已清除用户对话历史", zap.String("user_id", userID), zap.Int("count", len(convs)))
 	return nil
 }
 
-// Close 关闭服务资源
+// Close 关闭服务资源，先等待后台任务完成，ctx结束时不再等待并返回其错误；可以重复调用
 func (s *chatServiceImpl) Close(ctx context.Context) error {
-	if s.rateLimiter != nil {
-		s.rateLimiter.Stop()
+	waitErr := s.background.Wait(ctx)
+	if waitErr != nil {
+		s.logger.Warn("等待后台任务完成超时", zap.Error(waitErr), zap.Int("active", s.background.Active()))
 	}
-	if s.chatCache != nil {
-		s.chatCache.Close()
-	}
-	return nil
+	s.closeOnce.Do(func() {
+		if s.rateLimiter != nil {
+			s.rateLimiter.Stop()
+		}
+		if s.conversations != nil {
+			s.conversations.Close()
+		}
+		if s.chatCache != nil {
+			s.chatCache.Close()
+		}
+	})
+	return waitErr
 }
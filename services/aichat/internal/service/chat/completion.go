@@ -0,0 +1,449 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"weave/services/aichat/internal/chat"
+	"weave/services/aichat/internal/model"
+	"weave/services/aichat/internal/prompts"
+	"weave/services/aichat/internal/usage"
+
+	"github.com/cloudwego/eino/callbacks"
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/flow/agent"
+	"github.com/cloudwego/eino/flow/agent/react"
+	"github.com/cloudwego/eino/schema"
+	callbackutils "github.com/cloudwego/eino/utils/callbacks"
+	"go.uber.org/zap"
+)
+
+// 补全结束原因，与 OpenAI 接口的 finish_reason 一致
+const (
+	FinishReasonStop          = "stop"
+	FinishReasonLength        = "length"
+	FinishReasonToolCalls     = "tool_calls"
+	FinishReasonContentFilter = "content_filter"
+)
+
+// rejectedInputReply 用户输入未通过安全校验时的回复
+const rejectedInputReply = "抱歉，您的输入包含不适当的内容，请重新输入。"
+
+// ErrEmptyMessages 补全请求没有消息，或最后一条消息不是用户或工具消息
+var ErrEmptyMessages = errors.New("messages must end with a user or tool message")
+
+// ErrToolsUnsupported 当前模型不支持客户端声明的工具
+var ErrToolsUnsupported = errors.New("chat model does not support client tools")
+
+// ErrImageLimitExceeded 图片数量或上传频率超过限制
+var ErrImageLimitExceeded = errors.New("image limit exceeded")
+
+// ModelInfo 可用的聊天模型
+type ModelInfo struct {
+	// 模型名称，对应请求中的 model
+	ID string
+	// 模型提供方（openai、ollama、modelscope）
+	Provider string
+	// 是否支持图片输入
+	Vision bool
+	// 是否支持工具调用
+	ToolCalling bool
+	// 上下文窗口（令牌数），0 表示未配置
+	ContextWindow int
+}
+
+// CompletionRequest 无状态的对话补全请求，历史消息由调用方维护
+type CompletionRequest struct {
+	// 调用方的会话键，用于图片限流和日志
+	UserID string
+	// 完整的消息列表，最后一条为用户消息或工具结果
+	Messages []*schema.Message
+	// 调用方声明的工具：非空时直接调用模型并把工具调用返回给调用方执行，不使用 Weave 的工具
+	Tools []*schema.ToolInfo
+	// 调用方声明工具时的工具选择策略
+	ToolChoice *schema.ToolChoice
+	// 模型参数（温度、最大输出长度等）
+	ModelOptions []einomodel.Option
+}
+
+// CompletionResult 对话补全结果
+type CompletionResult struct {
+	// 助手回复，调用方声明工具时可能只包含工具调用
+	Message *schema.Message
+	// 结束原因：stop、length、tool_calls 或 content_filter
+	FinishReason string
+	// 本次请求所有模型调用的令牌用量，模型未返回用量时为估算值
+	Usage schema.TokenUsage
+}
+
+// Models 返回可用的聊天模型
+func (s *chatServiceImpl) Models() []ModelInfo {
+	if s.registry == nil {
+		return s.models
+	}
+	specs := s.registry.Models()
+	models := make([]ModelInfo, 0, len(specs))
+	for _, spec := range specs {
+		models = append(models, ModelInfo{
+			ID:            spec.Name,
+			Provider:      spec.Provider,
+			Vision:        spec.Vision,
+			ToolCalling:   spec.ToolCalling,
+			ContextWindow: spec.ContextWindow,
+		})
+	}
+	return models
+}
+
+// Complete 执行对话补全
+func (s *chatServiceImpl) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResult, error) {
+	return s.complete(ctx, req, nil)
+}
+
+// CompleteStream 流式执行对话补全，每个增量消息（文本或工具调用片段）调用一次 chunkCallback
+func (s *chatServiceImpl) CompleteStream(ctx context.Context, req *CompletionRequest, chunkCallback func(chunk *schema.Message) error) (*CompletionResult, error) {
+	return s.complete(ctx, req, chunkCallback)
+}
+
+// complete 对话补全的内部实现
+// 最后一条用户消息经过敏感内容校验和过滤；历史消息不含工具调用时按相关性筛选；
+// 调用方未声明工具时通过 Agent 生成回复，可以使用 Weave 的工具
+func (s *chatServiceImpl) complete(ctx context.Context, req *CompletionRequest, chunkCallback func(chunk *schema.Message) error) (*CompletionResult, error) {
+	if len(req.Messages) == 0 {
+		return nil, ErrEmptyMessages
+	}
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role != schema.User && last.Role != schema.Tool {
+		return nil, ErrEmptyMessages
+	}
+
+	ctx, route := withServedRoute(ctx)
+	messages := req.Messages
+	hasImages := false
+	if last.Role == schema.User {
+		input := messageText(last)
+		if isValid, reason := s.filter.ValidateInput(input); !isValid {
+			s.logger.Warn("用户输入包含敏感或恶意内容", zap.String("user_id", req.UserID), zap.String("reason", reason))
+			reply := schema.AssistantMessage(rejectedInputReply, nil)
+			if chunkCallback != nil {
+				if err := chunkCallback(reply); err != nil {
+					return nil, err
+				}
+			}
+			usage := estimateUsage(s.tokenizerFor(s.servedModel(route)), req.Messages, reply)
+			return &CompletionResult{Message: reply, FinishReason: FinishReasonContentFilter, Usage: usage}, nil
+		}
+
+		userMessage, err := s.filterUserMessage(ctx, req.UserID, last)
+		if err != nil {
+			return nil, err
+		}
+		hasImages = len(userMessage.UserInputMultiContent) > 0
+		messages = s.selectHistory(ctx, req.Messages[:len(req.Messages)-1], input)
+		messages = append(messages, userMessage)
+	}
+	var sel *prompts.Selection
+	if len(req.Tools) == 0 && !hasImages {
+		messages, sel = s.withSystemPrompt(ctx, req.UserID, messages)
+	}
+	jsonSchema, err := responseSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	messages = withSchemaInstructions(messages, jsonSchema)
+
+	usage := &usageCollector{}
+	var (
+		stream   *schema.StreamReader[*schema.Message]
+		generate generateFunc
+	)
+	if len(req.Tools) > 0 {
+		stream, err = s.streamWithClientTools(ctx, req, messages, usage)
+		generate = s.modelGenerator(req, usage)
+	} else {
+		targetAgent := s.agent
+		if hasImages && s.visionAgent != nil {
+			targetAgent = s.visionAgent
+		}
+		var modelOpts []agent.AgentOption
+		if len(req.ModelOptions) > 0 {
+			modelOpts = append(modelOpts, react.WithChatModelOptions(req.ModelOptions...))
+		}
+		opts := append([]agent.AgentOption{agent.WithComposeOptions(compose.WithCallbacks(usage.handler()))}, modelOpts...)
+		stream, err = targetAgent.Stream(ctx, messages, opts...)
+		generate = agentGenerator(targetAgent, usage, modelOpts...)
+	}
+	if err != nil {
+		s.logger.Error("生成回复失败", zap.Error(err), zap.String("user_id", req.UserID))
+		return nil, err
+	}
+	defer stream.Close()
+
+	var chunks []*schema.Message
+	for {
+		chunk, recvErr := stream.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if recvErr != nil {
+			return nil, recvErr
+		}
+		chunks = append(chunks, chunk)
+		usage.addMessage(chunk)
+		// 结构化输出在校验通过后一次性发送
+		if chunkCallback != nil && jsonSchema == nil && (chunk.Content != "" || len(chunk.ToolCalls) > 0) {
+			if err := chunkCallback(chunk); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	reply := schema.AssistantMessage("", nil)
+	if len(chunks) > 0 {
+		if reply, err = schema.ConcatMessages(chunks); err != nil {
+			return nil, err
+		}
+	}
+	if jsonSchema != nil {
+		if err := s.completeStructured(ctx, req, jsonSchema, messages, reply, chunks, generate, chunkCallback); err != nil {
+			return nil, err
+		}
+	}
+
+	return &CompletionResult{
+		Message:      reply,
+		FinishReason: finishReason(reply),
+		Usage:        s.recordUsage(ctx, route, sel, req.UserID, "", messages, reply, usage),
+	}, nil
+}
+
+// filterUserMessage 过滤用户消息中的敏感内容，包含图片时按图片数量限流
+func (s *chatServiceImpl) filterUserMessage(ctx context.Context, userID string, msg *schema.Message) (*schema.Message, error) {
+	if len(msg.UserInputMultiContent) == 0 {
+		filtered := *msg
+		filtered.Content = s.filter.FilterSensitiveContent(msg.Content)
+		return &filtered, nil
+	}
+
+	images := 0
+	parts := make([]schema.MessageInputPart, 0, len(msg.UserInputMultiContent))
+	for _, part := range msg.UserInputMultiContent {
+		switch part.Type {
+		case schema.ChatMessagePartTypeText:
+			part.Text = s.filter.FilterSensitiveContent(part.Text)
+		case schema.ChatMessagePartTypeImageURL:
+			images++
+		}
+		parts = append(parts, part)
+	}
+	if images > 0 && s.rateLimiter != nil {
+		if err := s.rateLimiter.CheckRequestLimit(images); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrImageLimitExceeded, err)
+		}
+		if err := s.rateLimiter.CheckRateLimit(ctx, userID, images); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrImageLimitExceeded, err)
+		}
+	}
+	filtered := *msg
+	filtered.UserInputMultiContent = parts
+	return &filtered, nil
+}
+
+// selectHistory 保留调用方的系统消息，并按相关性筛选其余历史消息
+// 历史中包含工具调用时不做筛选，避免拆开工具调用和工具结果
+func (s *chatServiceImpl) selectHistory(ctx context.Context, history []*schema.Message, question string) []*schema.Message {
+	var system, dialogue []*schema.Message
+	for _, msg := range history {
+		if msg.Role == schema.Tool || len(msg.ToolCalls) > 0 {
+			return append([]*schema.Message(nil), history...)
+		}
+		if msg.Role == schema.System {
+			system = append(system, msg)
+		} else {
+			dialogue = append(dialogue, msg)
+		}
+	}
+
+	if s.summaryGenerator != nil && len(dialogue) > 0 {
+		dialogue = chat.FilterRelevantHistoryHybrid(ctx, s.embedder, s.summaryGenerator.GetBM25Calculator(), s.reranker, dialogue, question, 50)
+	}
+	return append(system, dialogue...)
+}
+
+// withSystemPrompt 调用方没有提供系统消息时，在消息前加上租户 default 模板渲染的系统提示词
+// 无状态请求没有对话，A/B 分流按用户固定版本
+func (s *chatServiceImpl) withSystemPrompt(ctx context.Context, userID string, messages []*schema.Message) ([]*schema.Message, *prompts.Selection) {
+	for _, msg := range messages {
+		if msg.Role == schema.System {
+			return messages, nil
+		}
+	}
+	sel, err := s.prompts.Select(ctx, usage.TenantOf(userID), "", userID)
+	if err != nil {
+		s.logger.Warn("选择提示词模板失败，使用内置提示词", zap.Error(err), zap.String("user_id", userID))
+		sel = prompts.DefaultSelection()
+	}
+	prompts.Record(ctx, sel)
+	return append([]*schema.Message{schema.SystemMessage(sel.SystemPrompt("", ""))}, messages...), sel
+}
+
+// streamWithClientTools 绑定调用方声明的工具后直接调用聊天模型，工具调用由调用方执行
+func (s *chatServiceImpl) streamWithClientTools(ctx context.Context, req *CompletionRequest, messages []*schema.Message, usage *usageCollector) (*schema.StreamReader[*schema.Message], error) {
+	if s.chatModel == nil {
+		return nil, ErrToolsUnsupported
+	}
+	llm, err := s.chatModel.WithTools(req.Tools)
+	if err != nil {
+		return nil, err
+	}
+	opts := req.ModelOptions
+	if req.ToolChoice != nil {
+		opts = append(opts, einomodel.WithToolChoice(*req.ToolChoice))
+	}
+	ctx = callbacks.InitCallbacks(ctx, &callbacks.RunInfo{Component: "ChatModel"}, usage.handler())
+	return llm.Stream(ctx, messages, opts...)
+}
+
+// finishReason 返回回复的结束原因，模型未返回时根据是否包含工具调用推断
+func finishReason(reply *schema.Message) string {
+	if len(reply.ToolCalls) > 0 {
+		return FinishReasonToolCalls
+	}
+	if reply.ResponseMeta != nil && reply.ResponseMeta.FinishReason != "" {
+		switch reason := reply.ResponseMeta.FinishReason; reason {
+		case FinishReasonLength, FinishReasonContentFilter, FinishReasonToolCalls:
+			return reason
+		}
+	}
+	return FinishReasonStop
+}
+
+// messageText 返回消息的纯文本，多模态消息只取其中的文本部分
+func messageText(msg *schema.Message) string {
+	if msg.Content != "" || len(msg.UserInputMultiContent) == 0 {
+		return msg.Content
+	}
+	var texts []string
+	for _, part := range msg.UserInputMultiContent {
+		if part.Type == schema.ChatMessagePartTypeText {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// usageCollector 汇总一次请求中所有模型调用的令牌用量
+// Agent 在工具调用时会多次调用模型，用量通过模型回调收集；流式输出中也可能直接携带用量
+type usageCollector struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	usage    schema.TokenUsage
+	reported bool
+	// 回调中是否已收到用量，收到后不再从输出消息中重复累计
+	fromCallback bool
+	fromStream   *schema.TokenUsage
+}
+
+// handler 返回收集聊天模型用量的回调
+func (u *usageCollector) handler() callbacks.Handler {
+	return callbackutils.NewHandlerHelper().ChatModel(&callbackutils.ModelCallbackHandler{
+		OnEnd: func(ctx context.Context, _ *callbacks.RunInfo, output *einomodel.CallbackOutput) context.Context {
+			u.add(callbackUsage(output), true)
+			return ctx
+		},
+		OnEndWithStreamOutput: func(ctx context.Context, _ *callbacks.RunInfo, output *schema.StreamReader[*einomodel.CallbackOutput]) context.Context {
+			u.wg.Add(1)
+			go func() {
+				defer u.wg.Done()
+				defer output.Close()
+				var last *schema.TokenUsage
+				for {
+					chunk, err := output.Recv()
+					if err != nil {
+						break
+					}
+					if usage := callbackUsage(chunk); usage != nil {
+						last = usage
+					}
+				}
+				u.add(last, true)
+			}()
+			return ctx
+		},
+	}).Handler()
+}
+
+// addMessage 记录输出消息中携带的用量，只在回调没有提供用量时使用
+func (u *usageCollector) addMessage(msg *schema.Message) {
+	if msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usage := *msg.ResponseMeta.Usage
+	u.fromStream = &usage
+}
+
+// add 累计一次模型调用的用量
+func (u *usageCollector) add(usage *schema.TokenUsage, fromCallback bool) {
+	if usage == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.usage.PromptTokens += usage.PromptTokens
+	u.usage.CompletionTokens += usage.CompletionTokens
+	u.usage.TotalTokens += usage.TotalTokens
+	u.reported = true
+	u.fromCallback = u.fromCallback || fromCallback
+}
+
+// total 等待流式回调结束后返回累计用量，模型没有返回任何用量时 ok 为 false
+func (u *usageCollector) total() (schema.TokenUsage, bool) {
+	u.wg.Wait()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.fromCallback && u.fromStream != nil {
+		return *u.fromStream, true
+	}
+	usage := u.usage
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return usage, u.reported
+}
+
+// callbackUsage 返回模型回调输出中的用量：模型实现触发的回调使用 TokenUsage，
+// 由编排框架注入的回调只有输出消息，用量在消息的 ResponseMeta 中
+func callbackUsage(output *einomodel.CallbackOutput) *schema.TokenUsage {
+	if output == nil {
+		return nil
+	}
+	if output.TokenUsage != nil {
+		return &schema.TokenUsage{
+			PromptTokens:     output.TokenUsage.PromptTokens,
+			CompletionTokens: output.TokenUsage.CompletionTokens,
+			TotalTokens:      output.TokenUsage.TotalTokens,
+		}
+	}
+	if output.Message != nil && output.Message.ResponseMeta != nil {
+		return output.Message.ResponseMeta.Usage
+	}
+	return nil
+}
+
+// estimateUsage 模型未返回用量时估算令牌数
+func estimateUsage(tokenizer model.Tokenizer, prompt []*schema.Message, reply *schema.Message) schema.TokenUsage {
+	promptTokens := tokenizer.CountMessages(prompt)
+	completionTokens := tokenizer.CountTokens(reply.Content)
+	return schema.TokenUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
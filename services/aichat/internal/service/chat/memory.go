@@ -0,0 +1,122 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	convmanager "weave/services/aichat/internal/conversation"
+	"weave/services/aichat/internal/memory"
+	"weave/services/aichat/internal/model"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// memoryPrefix 召回的长期记忆作为系统消息加入上下文时的前缀
+const memoryPrefix = "关于用户的长期记忆：\n"
+
+// memoryExtractionTimeout 每轮对话后提取记忆的超时时间
+const memoryExtractionTimeout = time.Minute
+
+// newMemoryManager 基于数据库创建长期记忆管理器，db 为 nil 时使用内存数据库，记忆在重启后丢失
+func (s *chatServiceImpl) newMemoryManager(db *gorm.DB) (*memory.Manager, error) {
+	if db == nil {
+		var err error
+		if db, err = convmanager.OpenMemoryDB(); err != nil {
+			return nil, err
+		}
+	}
+	store, err := memory.NewStore(db)
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewManager(store, s.embedder, s.summaryGenerator.GetBM25Calculator(), s.loadMemoryConfig()), nil
+}
+
+// loadMemoryConfig 读取长期记忆配置
+// AICHAT_MEMORY_EXTRACTION 为 true 时每轮对话后额外调用一次模型提取记忆，AICHAT_MEMORY_MODEL 为提取使用的模型，为空时按路由规则选择；
+// AICHAT_MEMORY_DEDUPE_THRESHOLD 为判定重复的向量相似度，AICHAT_MEMORY_RECALL_LIMIT 为每轮加入上下文的记忆数，
+// AICHAT_MEMORY_MAX_PER_USER 为每个用户保存的自动提取记忆数上限
+func (s *chatServiceImpl) loadMemoryConfig() memory.Config {
+	viper.SetDefault("AICHAT_MEMORY_DEDUPE_THRESHOLD", memory.DefaultDedupeThreshold)
+	viper.SetDefault("AICHAT_MEMORY_RECALL_LIMIT", memory.DefaultRecallLimit)
+	viper.SetDefault("AICHAT_MEMORY_MAX_PER_USER", memory.DefaultMaxPerUser)
+	s.memoryExtraction = viper.GetBool("AICHAT_MEMORY_EXTRACTION")
+	s.memoryModel = viper.GetString("AICHAT_MEMORY_MODEL")
+	return memory.Config{
+		DedupeThreshold: viper.GetFloat64("AICHAT_MEMORY_DEDUPE_THRESHOLD"),
+		RecallLimit:     viper.GetInt("AICHAT_MEMORY_RECALL_LIMIT"),
+		MaxPerUser:      viper.GetInt("AICHAT_MEMORY_MAX_PER_USER"),
+	}
+}
+
+// Memories 返回长期记忆管理器
+func (s *chatServiceImpl) Memories() *memory.Manager {
+	return s.memories
+}
+
+// memoryContext 召回与当前问题相关的长期记忆，组成加入上下文的系统消息，没有相关记忆时返回 nil
+func (s *chatServiceImpl) memoryContext(ctx context.Context, userID, input string) *schema.Message {
+	if s.memories == nil {
+		return nil
+	}
+	memories, err := s.memories.Recall(ctx, userID, input)
+	if err != nil {
+		s.logger.Warn("召回长期记忆失败", zap.Error(err), zap.String("user_id", userID))
+		return nil
+	}
+	if len(memories) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString(memoryPrefix)
+	for _, m := range memories {
+		b.WriteString("- " + m.Content + "\n")
+	}
+	s.logger.Info("添加长期记忆上下文", zap.String("user_id", userID), zap.Int("memories", len(memories)))
+	return &schema.Message{Role: schema.System, Content: strings.TrimSuffix(b.String(), "\n")}
+}
+
+// rememberTurn 在后台从一轮对话中提取长期记忆，不阻塞响应；未开启自动提取时不做任何事
+// 提取任务由服务跟踪，关闭服务时等待其完成
+func (s *chatServiceImpl) rememberTurn(turn memory.Turn) {
+	if !s.memoryExtraction || s.memories == nil || s.chatModel == nil {
+		return
+	}
+	s.background.Go(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), memoryExtractionTimeout)
+		defer cancel()
+		saved, err := s.extractMemories(ctx, turn)
+		if err != nil {
+			s.logger.Warn("提取长期记忆失败", zap.Error(err), zap.String("user_id", turn.UserID), zap.String("conversation_id", turn.ConversationID))
+			return
+		}
+		if len(saved) > 0 {
+			s.logger.Info("保存长期记忆", zap.String("user_id", turn.UserID), zap.Int("memories", len(saved)))
+		}
+	})
+}
+
+// extractMemories 调用模型从一轮对话中提取记忆并去重保存，提取的用量计入用户
+// 使用新的路由选项，不沿用请求的模型和结构化输出要求
+func (s *chatServiceImpl) extractMemories(ctx context.Context, turn memory.Turn) ([]memory.Memory, error) {
+	route := &model.Route{Model: s.memoryModel}
+	ctx = model.WithRoute(ctx, route)
+	collector := &usageCollector{}
+	messages := memory.ExtractionMessages(turn)
+	reply, err := s.chatModel.Generate(callbacks.InitCallbacks(ctx, &callbacks.RunInfo{Component: "ChatModel"}, collector.handler()), messages)
+	if err != nil {
+		return nil, err
+	}
+	s.recordUsage(ctx, route, nil, turn.UserID, turn.ConversationID, messages, reply, collector)
+
+	candidates, err := memory.ParseCandidates(reply.Content)
+	if err != nil {
+		return nil, err
+	}
+	return s.memories.Remember(ctx, turn, candidates)
+}
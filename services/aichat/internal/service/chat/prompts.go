@@ -0,0 +1,158 @@
+package chat
+
+import (
+	"context"
+	"strings"
+
+	"weave/services/aichat/internal/chat"
+	convmanager "weave/services/aichat/internal/conversation"
+	"weave/services/aichat/internal/model"
+	"weave/services/aichat/internal/prompts"
+	"weave/services/aichat/internal/usage"
+
+	"github.com/cloudwego/eino/schema"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// promptTemplateExtra 助手消息的 Extra 中记录生成该回复所用模板版本的键
+const promptTemplateExtra = "prompt_template"
+
+// PromptPreview 对话下一轮请求将发送给模型的消息
+type PromptPreview struct {
+	Selection *prompts.Selection `json:"selection"`
+	Messages  []*schema.Message  `json:"messages"`
+	Tokens    int                `json:"tokens"` // 按分词器估算的提示词令牌数
+	Budget    int                `json:"budget"` // 提示词令牌预算
+}
+
+// newPromptManager 基于数据库创建提示词管理器，db 为 nil 时使用内存数据库，模板在重启后丢失
+func newPromptManager(db *gorm.DB) (*prompts.Manager, error) {
+	if db == nil {
+		var err error
+		if db, err = convmanager.OpenMemoryDB(); err != nil {
+			return nil, err
+		}
+	}
+	store, err := prompts.NewStore(db)
+	if err != nil {
+		return nil, err
+	}
+	return prompts.NewManager(store), nil
+}
+
+// Prompts 返回提示词模板和人设管理器
+func (s *chatServiceImpl) Prompts() *prompts.Manager {
+	return s.prompts
+}
+
+// selectPrompt 按对话的人设选择模板版本，同一对话总是分到同一个 A/B 版本
+// 人设不存在或模板存储出错时使用 default 模板，再出错时使用内置提示词
+func (s *chatServiceImpl) selectPrompt(ctx context.Context, userID string, conversation *model.Conversation) *prompts.Selection {
+	tenant := usage.TenantOf(userID)
+	persona := conversation.Metadata[prompts.PersonaMetadataKey]
+	sel, err := s.prompts.Select(ctx, tenant, persona, conversation.ID)
+	if err != nil && persona != "" {
+		s.logger.Warn("选择人设模板失败，使用默认模板", zap.Error(err), zap.String("user_id", userID), zap.String("persona", persona))
+		sel, err = s.prompts.Select(ctx, tenant, "", conversation.ID)
+	}
+	if err != nil {
+		s.logger.Warn("选择提示词模板失败，使用内置提示词", zap.Error(err), zap.String("user_id", userID))
+		sel = prompts.DefaultSelection()
+	}
+	prompts.Record(ctx, sel)
+	return sel
+}
+
+// relevantHistory 选出与当前问题相关的历史消息，并加入对话摘要和关键词上下文
+func (s *chatServiceImpl) relevantHistory(ctx context.Context, userID string, conversation *model.Conversation, filteredInput string) []*schema.Message {
+	// 提取关键词
+	var keywords []string
+	if s.summaryGenerator != nil {
+		keywords = s.summaryGenerator.ExtractKeywords(filteredInput, 5)
+		if len(keywords) > 0 {
+			s.logger.Info("提取到关键词", zap.String("user_id", userID), zap.Strings("keywords", keywords))
+		}
+	}
+
+	// 从结构化对话中获取消息历史
+	chatHistory := conversation.Messages
+
+	// 过滤与当前问题相关的对话历史
+	var filteredHistory []*schema.Message
+
+	// 如果有摘要且历史消息较长，使用摘要替代部分历史消息
+	if conversation.Summary != "" && len(chatHistory) > 30 {
+		summaryMsg := &schema.Message{Role: schema.System, Content: summaryPrefix + conversation.Summary}
+		filteredHistory = append(filteredHistory, summaryMsg)
+
+		// 只添加最近的10条消息
+		startIdx := len(chatHistory) - 10
+		if startIdx < 0 {
+			startIdx = 0
+		}
+		filteredHistory = append(filteredHistory, chatHistory[startIdx:]...)
+		s.logger.Info("使用摘要作为上下文", zap.String("user_id", userID), zap.Int("message_count", len(chatHistory)))
+	} else {
+		// 多路召回+加权RFF排序+LLM重排 (A路: BM25关键词召回, B路: Embedding语义召回)
+		bm25Calc := s.summaryGenerator.GetBM25Calculator()
+		filteredHistory = chat.FilterRelevantHistoryHybrid(ctx, s.embedder, bm25Calc, s.reranker, chatHistory, filteredInput, 50)
+	}
+
+	// 添加与问题相关的长期记忆
+	if memoryMsg := s.memoryContext(ctx, userID, filteredInput); memoryMsg != nil {
+		filteredHistory = append(filteredHistory, memoryMsg)
+	}
+
+	// 添加关键词上下文
+	if len(keywords) > 0 {
+		keywordContext := "关键词: " + strings.Join(keywords, ", ")
+		filteredHistory = append(filteredHistory, &schema.Message{Role: schema.System, Content: keywordContext})
+		s.logger.Info("添加关键词上下文", zap.String("user_id", userID))
+	}
+	return filteredHistory
+}
+
+// PreviewPrompt 渲染对话下一轮请求将发送给模型的消息，input 为假设的用户输入，persona 非空时覆盖对话的人设
+func (s *chatServiceImpl) PreviewPrompt(ctx context.Context, userID, conversationID, input, persona string) (*PromptPreview, error) {
+	conversation, err := s.conversations.GetConversation(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if persona == "" {
+		persona = conversation.Metadata[prompts.PersonaMetadataKey]
+	}
+	sel, err := s.prompts.Select(ctx, usage.TenantOf(userID), persona, conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	filteredInput := s.filter.FilterSensitiveContent(input)
+	userMessage := schema.UserMessage(filteredInput)
+	history := s.relevantHistory(ctx, userID, conversation, filteredInput)
+	history = s.fitHistory(ctx, sel, userID, history, userMessage, filteredInput, false)
+	messages, err := s.prepareMessages(ctx, sel, history, userMessage, filteredInput, false)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenizer, budget := s.promptBudget(ctx)
+	return &PromptPreview{
+		Selection: sel,
+		Messages:  messages,
+		Tokens:    tokenizer.CountMessages(messages),
+		Budget:    budget,
+	}, nil
+}
+
+// withPromptTemplate 在助手消息中记录生成该回复所用的模板版本
+func withPromptTemplate(msg *schema.Message, sel *prompts.Selection) *schema.Message {
+	if sel == nil {
+		return msg
+	}
+	if msg.Extra == nil {
+		msg.Extra = make(map[string]any)
+	}
+	msg.Extra[promptTemplateExtra] = sel.String()
+	return msg
+}
@@ -0,0 +1,142 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"weave/services/aichat/internal/model"
+	"weave/services/aichat/internal/structured"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/flow/agent"
+	"github.com/cloudwego/eino/flow/agent/react"
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// defaultStructuredRepairs 结构化输出校验失败后默认最多修复的次数
+const defaultStructuredRepairs = 2
+
+// ErrStructuredOutput 多次修复后模型输出仍不符合 JSON Schema
+var ErrStructuredOutput = errors.New("model output does not match the json schema")
+
+// StructuredOutputError 模型输出未通过 JSON Schema 校验，Errors 为最后一次输出的校验错误
+type StructuredOutputError struct {
+	Attempts int
+	Errors   []structured.ValidationError
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("%s after %d attempts: %d validation errors", ErrStructuredOutput, e.Attempts, len(e.Errors))
+}
+
+func (e *StructuredOutputError) Unwrap() error {
+	return ErrStructuredOutput
+}
+
+// generateFunc 调用模型生成一条回复，修复结构化输出时使用
+type generateFunc func(ctx context.Context, messages []*schema.Message) (*schema.Message, error)
+
+// loadStructuredConfig 读取结构化输出配置
+// AICHAT_STRUCTURED_OUTPUT_MAX_REPAIRS 为模型输出未通过校验时最多要求模型修正的次数，0 表示不修复
+func (s *chatServiceImpl) loadStructuredConfig() {
+	viper.SetDefault("AICHAT_STRUCTURED_OUTPUT_MAX_REPAIRS", defaultStructuredRepairs)
+	s.structuredRepairs = max(viper.GetInt("AICHAT_STRUCTURED_OUTPUT_MAX_REPAIRS"), 0)
+}
+
+// responseSchema 返回请求要求的 JSON Schema，请求没有要求结构化输出时返回 nil
+func responseSchema(ctx context.Context) (*structured.Schema, error) {
+	route := model.RouteFromContext(ctx)
+	if route == nil || route.ResponseFormat == nil {
+		return nil, nil
+	}
+	return structured.Compile(route.ResponseFormat.Schema)
+}
+
+// withSchemaInstructions 在最后一条消息之前加入按 JSON Schema 输出的要求
+// 支持 response_format 的模型由提供方约束输出，其他模型只能依靠该提示
+func withSchemaInstructions(messages []*schema.Message, s *structured.Schema) []*schema.Message {
+	if s == nil || len(messages) == 0 {
+		return messages
+	}
+	last := len(messages) - 1
+	out := make([]*schema.Message, 0, len(messages)+1)
+	out = append(out, messages[:last]...)
+	out = append(out, schema.SystemMessage(structured.Instructions(s)), messages[last])
+	return out
+}
+
+// ensureStructured 校验模型回复是否符合 JSON Schema，不符合时把校验错误发给模型要求修正，最多修正 structuredRepairs 次
+// 返回去掉代码块等多余内容后的 JSON 文本
+func (s *chatServiceImpl) ensureStructured(ctx context.Context, userID string, jsonSchema *structured.Schema, messages []*schema.Message, reply string, generate generateFunc) (string, error) {
+	conversation := append([]*schema.Message(nil), messages...)
+	for attempt := 1; ; attempt++ {
+		text := structured.ExtractJSON(reply)
+		errs := jsonSchema.Validate([]byte(text))
+		if len(errs) == 0 {
+			return text, nil
+		}
+		if attempt > s.structuredRepairs {
+			s.logger.Warn("模型输出不符合 JSON Schema", zap.String("user_id", userID), zap.Int("attempts", attempt), zap.Int("errors", len(errs)))
+			return "", &StructuredOutputError{Attempts: attempt, Errors: errs}
+		}
+
+		s.logger.Info("模型输出不符合 JSON Schema，要求模型修正", zap.String("user_id", userID), zap.Int("attempt", attempt), zap.Int("errors", len(errs)))
+		conversation = append(conversation, schema.AssistantMessage(reply, nil), schema.UserMessage(structured.RepairPrompt(errs)))
+		msg, err := generate(ctx, conversation)
+		if err != nil {
+			return "", err
+		}
+		reply = msg.Content
+	}
+}
+
+// agentGenerator 返回通过 Agent 生成回复的函数，模型用量计入 collector
+func agentGenerator(targetAgent *react.Agent, collector *usageCollector, opts ...agent.AgentOption) generateFunc {
+	return func(ctx context.Context, messages []*schema.Message) (*schema.Message, error) {
+		opts := append([]agent.AgentOption{agent.WithComposeOptions(compose.WithCallbacks(collector.handler()))}, opts...)
+		return targetAgent.Generate(ctx, messages, opts...)
+	}
+}
+
+// modelGenerator 返回直接调用聊天模型生成回复的函数，用于调用方声明工具的请求
+// 修复时不绑定工具，避免 tool_choice 强制模型再次调用工具
+func (s *chatServiceImpl) modelGenerator(req *CompletionRequest, collector *usageCollector) generateFunc {
+	return func(ctx context.Context, messages []*schema.Message) (*schema.Message, error) {
+		ctx = callbacks.InitCallbacks(ctx, &callbacks.RunInfo{Component: "ChatModel"}, collector.handler())
+		return s.chatModel.Generate(ctx, messages, req.ModelOptions...)
+	}
+}
+
+// completeStructured 校验补全回复并把回复内容替换为校验通过的 JSON，流式请求在校验通过后一次性发送
+// 回复只包含工具调用时调用方需要先执行工具，原样发送缓存的分块，等最终回复再校验
+func (s *chatServiceImpl) completeStructured(ctx context.Context, req *CompletionRequest, jsonSchema *structured.Schema, messages []*schema.Message, reply *schema.Message,
+	chunks []*schema.Message, generate generateFunc, chunkCallback func(chunk *schema.Message) error) error {
+	if len(reply.ToolCalls) > 0 {
+		if chunkCallback == nil {
+			return nil
+		}
+		for _, chunk := range chunks {
+			if chunk.Content == "" && len(chunk.ToolCalls) == 0 {
+				continue
+			}
+			if err := chunkCallback(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	content, err := s.ensureStructured(ctx, req.UserID, jsonSchema, messages, reply.Content, generate)
+	if err != nil {
+		return err
+	}
+	reply.Content = content
+	if chunkCallback != nil {
+		return chunkCallback(schema.AssistantMessage(content, nil))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package chat
+
+import (
+	"context"
+	"time"
+
+	"weave/services/aichat/internal/chat"
+	"weave/services/aichat/internal/model"
+
+	"github.com/cloudwego/eino/callbacks"
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// summaryInterval 每新增多少条消息更新一次对话摘要
+const summaryInterval = 5
+
+// summaryTimeout 使用LLM更新摘要的超时时间
+const summaryTimeout = time.Minute
+
+// loadSummaryConfig 读取摘要配置并创建生成对话摘要的生成器，需在BM25摘要生成器创建之后调用
+// AICHAT_SUMMARY_GENERATOR 为 llm 时由模型增量折叠摘要，失败时回退到BM25摘要，默认 bm25；
+// AICHAT_SUMMARY_MODEL 为LLM摘要使用的模型，为空时按路由规则选择；AICHAT_SUMMARY_MAX_TOKENS 为摘要的令牌上限
+func (s *chatServiceImpl) loadSummaryConfig() {
+	viper.SetDefault("AICHAT_SUMMARY_GENERATOR", chat.SummaryGeneratorBM25)
+	config := chat.DefaultLLMSummaryConfig()
+	viper.SetDefault("AICHAT_SUMMARY_MAX_TOKENS", config.MaxTokens)
+
+	s.summarizer = s.summaryGenerator
+	switch generator := viper.GetString("AICHAT_SUMMARY_GENERATOR"); generator {
+	case chat.SummaryGeneratorLLM:
+		if s.chatModel == nil {
+			s.logger.Warn("聊天模型不可用，使用BM25摘要")
+			return
+		}
+		if tokens := viper.GetInt("AICHAT_SUMMARY_MAX_TOKENS"); tokens > 0 {
+			config.MaxTokens = tokens
+		}
+		s.summaryModel = viper.GetString("AICHAT_SUMMARY_MODEL")
+		s.summarizer = chat.NewLLMSummaryGenerator(&summaryChatModel{service: s}, s.summaryGenerator, config)
+	case chat.SummaryGeneratorBM25:
+	default:
+		s.logger.Warn("未知的摘要生成器，使用BM25摘要", zap.String("generator", generator))
+	}
+}
+
+// summarizeConversation 每新增 summaryInterval 条消息更新一次对话摘要，摘要失败不影响本轮回复
+// 只处理基于全部消息重新生成的生成器，随对话一起保存；支持增量折叠的生成器在保存后由 foldSummary 在后台处理
+func (s *chatServiceImpl) summarizeConversation(userID string, conversation *model.Conversation) {
+	if s.summarizer == nil || len(conversation.Messages)%summaryInterval != 0 {
+		return
+	}
+	if _, ok := s.summarizer.(chat.ConversationSummarizer); ok {
+		return
+	}
+	summary, err := conversation.GenerateSummary(s.summarizer)
+	if err != nil {
+		s.logger.Warn("生成摘要失败", zap.Error(err), zap.String("user_id", userID))
+	} else if summary != "" {
+		s.logger.Info("生成对话摘要成功", zap.String("user_id", userID), zap.Int("message_count", len(conversation.Messages)))
+	}
+}
+
+// foldSummary 在对话保存之后于后台将新消息折叠进摘要，再单独保存摘要和元数据，不阻塞本轮回复
+// 折叠任务由服务跟踪，关闭服务时等待其完成；折叠的用量计入用户
+func (s *chatServiceImpl) foldSummary(ctx context.Context, userID string, conversation *model.Conversation) {
+	summarizer, ok := s.summarizer.(chat.ConversationSummarizer)
+	if !ok || len(conversation.Messages)%summaryInterval != 0 {
+		return
+	}
+	// 在快照上折叠，不修改调用方持有的对话
+	snapshot := *conversation
+	snapshot.Messages = append([]*schema.Message(nil), conversation.Messages...)
+	snapshot.Metadata = make(map[string]string, len(conversation.Metadata))
+	for k, v := range conversation.Metadata {
+		snapshot.Metadata[k] = v
+	}
+
+	s.background.Go(func() {
+		// 使用新的路由选项，不沿用请求的模型和结构化输出要求；请求结束后仍完成摘要
+		ctx := context.WithValue(context.WithoutCancel(ctx), summaryOwnerKey{}, summaryOwner{userID: userID, conversationID: snapshot.ID})
+		ctx, cancel := context.WithTimeout(model.WithRoute(ctx, &model.Route{Model: s.summaryModel}), summaryTimeout)
+		defer cancel()
+
+		_, before := chat.LoadRollingSummary(&snapshot)
+		summary, err := summarizer.SummarizeConversation(ctx, &snapshot)
+		if err != nil {
+			s.logger.Warn("生成摘要失败", zap.Error(err), zap.String("user_id", userID), zap.String("conversation_id", snapshot.ID))
+			return
+		}
+		if err := s.saveSummary(ctx, userID, &snapshot, before); err != nil {
+			s.logger.Warn("保存对话摘要失败", zap.Error(err), zap.String("user_id", userID), zap.String("conversation_id", snapshot.ID))
+			return
+		}
+		if summary != "" {
+			s.logger.Info("生成对话摘要成功", zap.String("user_id", userID), zap.Int("message_count", len(snapshot.Messages)))
+		}
+	})
+}
+
+// saveSummary 将折叠后的摘要和元数据写入最新保存的对话，期间有新消息时保留新消息
+// 其他折叠任务已从更靠后的位置完成摘要时放弃本次结果
+func (s *chatServiceImpl) saveSummary(ctx context.Context, userID string, folded *model.Conversation, before int) error {
+	latest, err := s.conversations.GetConversation(ctx, userID, folded.ID)
+	if err != nil {
+		return err
+	}
+	if _, done := chat.LoadRollingSummary(latest); done > before {
+		return nil
+	}
+	if latest.Metadata == nil {
+		latest.Metadata = make(map[string]string)
+	}
+	latest.SetSummary(folded.Summary)
+	for _, key := range []string{model.MetadataSummarizedMessages, model.MetadataSummaryEntities, model.MetadataSummaryDecisions} {
+		if v, ok := folded.Metadata[key]; ok {
+			latest.UpdateMetadata(key, v)
+		}
+	}
+	return s.conversations.SaveConversation(ctx, latest)
+}
+
+// summaryOwnerKey 摘要调用所属用户和对话在上下文中的键
+type summaryOwnerKey struct{}
+
+// summaryOwner 摘要调用的用量计入的用户和对话
+type summaryOwner struct {
+	userID         string
+	conversationID string
+}
+
+// summaryChatModel 供LLM摘要生成器使用的聊天模型，按上下文中的用户和对话记录每次调用的用量
+type summaryChatModel struct {
+	service *chatServiceImpl
+}
+
+// Generate 调用聊天模型并记录用量
+func (m *summaryChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...einomodel.Option) (*schema.Message, error) {
+	collector := &usageCollector{}
+	reply, err := m.service.chatModel.Generate(callbacks.InitCallbacks(ctx, &callbacks.RunInfo{Component: "ChatModel"}, collector.handler()), input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	collector.addMessage(reply)
+	owner, _ := ctx.Value(summaryOwnerKey{}).(summaryOwner)
+	m.service.recordUsage(ctx, model.RouteFromContext(ctx), nil, owner.userID, owner.conversationID, input, reply, collector)
+	return reply, nil
+}
+
+// Stream 摘要生成器只使用 Generate，流式调用直接交给聊天模型
+func (m *summaryChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...einomodel.Option) (*schema.StreamReader[*schema.Message], error) {
+	return m.service.chatModel.Stream(ctx, input, opts...)
+}
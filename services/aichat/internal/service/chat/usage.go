@@ -0,0 +1,167 @@
+package chat
+
+import (
+	"context"
+	"strings"
+
+	"weave/services/aichat/internal/chat"
+	"weave/services/aichat/internal/model"
+	"weave/services/aichat/internal/prompts"
+	"weave/services/aichat/internal/usage"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// summaryPrefix 对话摘要作为系统消息加入上下文时的前缀
+const summaryPrefix = "对话摘要："
+
+// 提示词令牌预算的默认值
+const (
+	defaultPromptTokenBudget    = 8192
+	defaultReservedOutputTokens = 1024
+)
+
+// loadBudgetConfig 读取提示词令牌预算配置
+// AICHAT_PROMPT_TOKEN_BUDGET 为提示词的令牌上限，AICHAT_RESERVED_OUTPUT_TOKENS 为在模型上下文窗口中为回复预留的令牌数
+func (s *chatServiceImpl) loadBudgetConfig() {
+	viper.SetDefault("AICHAT_PROMPT_TOKEN_BUDGET", defaultPromptTokenBudget)
+	viper.SetDefault("AICHAT_RESERVED_OUTPUT_TOKENS", defaultReservedOutputTokens)
+	s.promptTokenBudget = viper.GetInt("AICHAT_PROMPT_TOKEN_BUDGET")
+	s.reservedOutputTokens = viper.GetInt("AICHAT_RESERVED_OUTPUT_TOKENS")
+}
+
+// withServedRoute 确保上下文中有路由选项，用于获取实际响应请求的模型
+func withServedRoute(ctx context.Context) (context.Context, *model.Route) {
+	if route := model.RouteFromContext(ctx); route != nil {
+		return ctx, route
+	}
+	route := &model.Route{}
+	return model.WithRoute(ctx, route), route
+}
+
+// promptBudget 返回本次请求的提示词令牌预算和估算使用的分词器
+// 指定模型时受该模型的上下文窗口限制，否则受注册表中最大的上下文窗口限制，路由时会跳过窗口不足的模型
+func (s *chatServiceImpl) promptBudget(ctx context.Context) (model.Tokenizer, int) {
+	models := s.Models()
+	budget := s.promptTokenBudget
+	if len(models) == 0 {
+		return model.TokenizerFor(""), budget
+	}
+
+	target, window := models[0], 0
+	for _, info := range models {
+		if window < info.ContextWindow {
+			target, window = info, info.ContextWindow
+		}
+	}
+	if route := model.RouteFromContext(ctx); route != nil && route.Model != "" {
+		for _, info := range models {
+			if info.ID == route.Model {
+				target, window = info, info.ContextWindow
+			}
+		}
+	}
+	if window > 0 {
+		if limit := window - s.reservedOutputTokens; budget <= 0 || limit < budget {
+			budget = limit
+		}
+	}
+	return s.tokenizerFor(target.ID), budget
+}
+
+// tokenizerFor 返回模型使用的分词器，注册表中的模型按提供方的模型名称匹配
+func (s *chatServiceImpl) tokenizerFor(name string) model.Tokenizer {
+	if s.registry != nil {
+		if spec, ok := s.registry.Get(name); ok {
+			return model.TokenizerFor(spec.Model)
+		}
+	}
+	return model.TokenizerFor(name)
+}
+
+// fitHistory 按令牌预算裁剪历史消息
+// 系统提示词和当前输入总是保留，其次是对话摘要和关键词等上下文，剩余预算从最近的历史消息开始填充
+func (s *chatServiceImpl) fitHistory(ctx context.Context, sel *prompts.Selection, userID string, history []*schema.Message, userMessage *schema.Message, filteredInput string, hasImages bool) []*schema.Message {
+	fixed, err := s.prepareMessages(ctx, sel, nil, userMessage, filteredInput, hasImages)
+	if err != nil {
+		fixed = []*schema.Message{userMessage}
+	}
+
+	var parts chat.PromptParts
+	parts.Fixed = fixed
+	for _, msg := range history {
+		switch {
+		case msg.Role == schema.System && strings.HasPrefix(msg.Content, summaryPrefix):
+			parts.Summary = msg
+		case msg.Role == schema.System:
+			parts.Context = append(parts.Context, msg)
+		default:
+			parts.History = append(parts.History, msg)
+		}
+	}
+
+	tokenizer, budget := s.promptBudget(ctx)
+	plan := chat.FitPrompt(tokenizer, budget, parts)
+	if plan.Dropped > 0 {
+		s.logger.Info("提示词超出令牌预算，已裁剪上下文",
+			zap.String("user_id", userID), zap.Int("budget", budget), zap.Int("tokens", plan.Tokens), zap.Int("dropped", plan.Dropped))
+	}
+
+	fitted := make([]*schema.Message, 0, len(plan.History)+len(plan.Context)+1)
+	if plan.Summary != nil {
+		fitted = append(fitted, plan.Summary)
+	}
+	fitted = append(fitted, plan.History...)
+	return append(fitted, plan.Context...)
+}
+
+// servedModel 返回实际响应请求的模型，模型未经过注册表路由时使用第一个可用模型
+func (s *chatServiceImpl) servedModel(route *model.Route) string {
+	if served := route.Served(); served != "" {
+		return served
+	}
+	if models := s.Models(); len(models) > 0 {
+		return models[0].ID
+	}
+	return ""
+}
+
+// price 返回模型每千个输入/输出令牌的价格
+func (s *chatServiceImpl) price(name string) (float64, float64) {
+	if s.registry == nil {
+		return 0, 0
+	}
+	spec, ok := s.registry.Get(name)
+	if !ok {
+		return 0, 0
+	}
+	return spec.InputCostPer1K, spec.OutputCostPer1K
+}
+
+// recordUsage 记录一次请求的令牌用量和使用的模板版本，模型未返回用量时按实际模型的分词器估算
+func (s *chatServiceImpl) recordUsage(ctx context.Context, route *model.Route, sel *prompts.Selection, userID, conversationID string, prompt []*schema.Message, reply *schema.Message, collector *usageCollector) schema.TokenUsage {
+	modelName := s.servedModel(route)
+	total, reported := collector.total()
+	if !reported {
+		total = estimateUsage(s.tokenizerFor(modelName), prompt, reply)
+	}
+	if s.usage != nil {
+		s.usage.Record(ctx, usage.Record{
+			UserID:           userID,
+			ConversationID:   conversationID,
+			Model:            modelName,
+			PromptTemplate:   sel.String(),
+			PromptTokens:     total.PromptTokens,
+			CompletionTokens: total.CompletionTokens,
+			Estimated:        !reported,
+		})
+	}
+	return total
+}
+
+// Usage 汇总令牌用量和费用
+func (s *chatServiceImpl) Usage(ctx context.Context, filter usage.Filter) (*usage.Summary, error) {
+	return s.usage.Summarize(ctx, filter)
+}
@@ -0,0 +1,57 @@
+package structured
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxReportedErrors 修复提示中最多列出的校验错误数量
+const maxReportedErrors = 20
+
+// ExtractJSON 从模型回复中取出 JSON 文本
+// 模型常把 JSON 包在 ```json 代码块中或在前后附加说明，这里去掉代码块并截取第一个 { 或 [ 到最后一个 } 或 ] 之间的内容
+func ExtractJSON(reply string) string {
+	text := strings.TrimSpace(reply)
+	if start := strings.Index(text, "```"); start >= 0 {
+		body := text[start+3:]
+		if end := strings.Index(body, "```"); end >= 0 {
+			body = body[:end]
+		}
+		// 去掉代码块语言标记，如 ```json
+		if newline := strings.IndexByte(body, '\n'); newline >= 0 && !strings.ContainsAny(body[:newline], "{[") {
+			body = body[newline+1:]
+		}
+		text = strings.TrimSpace(body)
+	}
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start {
+		return text[start : end+1]
+	}
+	return text
+}
+
+// Instructions 返回要求模型按 JSON Schema 输出的系统提示
+// 不支持原生结构化输出的模型只能依靠该提示约束输出格式
+func Instructions(s *Schema) string {
+	return "请只输出一个符合以下 JSON Schema 的 JSON 值，不要输出代码块标记、解释或其他任何内容。\nJSON Schema：\n" + string(s.Raw())
+}
+
+// RepairPrompt 返回要求模型修正输出的提示，列出上一次输出的校验错误
+func RepairPrompt(errs []ValidationError) string {
+	var b strings.Builder
+	b.WriteString("你上一次的输出不符合要求的 JSON Schema，校验错误如下：\n")
+	for i, e := range errs {
+		if i == maxReportedErrors {
+			fmt.Fprintf(&b, "- 另有 %d 处错误未列出\n", len(errs)-maxReportedErrors)
+			break
+		}
+		b.WriteString("- " + e.String() + "\n")
+	}
+	b.WriteString("请修正这些错误，只输出修正后的完整 JSON，不要输出其他任何内容。")
+	return b.String()
+}
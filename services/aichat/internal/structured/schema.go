@@ -0,0 +1,641 @@
+package structured
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalidSchema JSON Schema 无效或使用了不支持的写法
+var ErrInvalidSchema = errors.New("invalid json schema")
+
+// Schema 编译后的 JSON Schema
+// 支持 type、enum、const、properties、required、additionalProperties、items、
+// 长度/数量/数值范围、pattern、format、allOf/anyOf/oneOf/not 以及文档内的 $ref；
+// 使用其他校验关键字的模式编译失败，避免约束被静默忽略
+type Schema struct {
+	raw  json.RawMessage
+	root *node
+}
+
+// node 编译后的子模式
+type node struct {
+	always   *bool // true/false 模式
+	ref      string
+	resolved *node
+
+	types    []string
+	enum     []any
+	constant any
+	hasConst bool
+
+	properties           map[string]*node
+	required             []string
+	additionalProperties *node
+	minProperties        *int
+	maxProperties        *int
+
+	items       *node
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+	format    string
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*node
+	anyOf []*node
+	oneOf []*node
+	not   *node
+}
+
+// ValidationError 一处不符合模式的位置，Path 为 JSONPath 风格的位置，如 $.items[0].name
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) String() string {
+	return e.Path + ": " + e.Message
+}
+
+// Compile 解析并编译 JSON Schema
+func Compile(raw json.RawMessage) (*Schema, error) {
+	doc, err := decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	c := &compiler{doc: doc, targets: make(map[string]*node)}
+	root, err := c.compile(doc, "#")
+	if err != nil {
+		return nil, err
+	}
+	// 被引用的模式中可能还有引用，解析时追加到 c.refs，同一引用只编译一次，递归模式也能终止
+	for i := 0; i < len(c.refs); i++ {
+		n := c.refs[i]
+		target, ok := c.targets[n.ref]
+		if !ok {
+			if target, err = c.resolve(n.ref); err != nil {
+				return nil, err
+			}
+			c.targets[n.ref] = target
+		}
+		n.resolved = target
+	}
+	return &Schema{raw: append(json.RawMessage(nil), raw...), root: root}, nil
+}
+
+// Raw 返回原始的 JSON Schema
+func (s *Schema) Raw() json.RawMessage {
+	return s.raw
+}
+
+// Validate 校验 JSON 文本是否符合模式，文本不是合法 JSON 时返回一条位于 $ 的错误
+func (s *Schema) Validate(data []byte) []ValidationError {
+	value, err := decode(data)
+	if err != nil {
+		return []ValidationError{{Path: "$", Message: "不是合法的 JSON：" + err.Error()}}
+	}
+	var errs []ValidationError
+	s.root.validate(value, "$", &errs)
+	return errs
+}
+
+// decode 解析 JSON，数字保留为 json.Number，并要求只包含一个 JSON 值
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("JSON 值之后还有多余的内容")
+	}
+	return value, nil
+}
+
+type compiler struct {
+	doc     any
+	refs    []*node          // 待解析的引用
+	targets map[string]*node // 已解析的引用目标
+}
+
+func (c *compiler) compile(v any, at string) (*node, error) {
+	switch s := v.(type) {
+	case bool:
+		return &node{always: &s}, nil
+	case map[string]any:
+		return c.compileObject(s, at)
+	default:
+		return nil, fmt.Errorf("%w: %s must be an object or boolean", ErrInvalidSchema, at)
+	}
+}
+
+// unsupportedKeywords 未实现的校验关键字
+var unsupportedKeywords = []string{
+	"patternProperties", "propertyNames", "dependentRequired", "dependentSchemas", "dependencies",
+	"unevaluatedProperties", "prefixItems", "additionalItems", "contains", "minContains", "maxContains",
+	"unevaluatedItems", "if", "then", "else", "$dynamicRef", "$recursiveRef",
+}
+
+// formats 支持校验的 format 取值
+var formats = map[string]func(string) bool{
+	"date-time": func(s string) bool { _, err := time.Parse(time.RFC3339, s); return err == nil },
+	"date":      func(s string) bool { _, err := time.Parse(time.DateOnly, s); return err == nil },
+	"time":      func(s string) bool { _, err := time.Parse("15:04:05Z07:00", s); return err == nil },
+	"email": func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	},
+	"uri": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	},
+	"uuid": regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	"ipv4": func(s string) bool { addr, err := netip.ParseAddr(s); return err == nil && addr.Is4() },
+	"ipv6": func(s string) bool { addr, err := netip.ParseAddr(s); return err == nil && addr.Is6() },
+}
+
+func (c *compiler) compileObject(s map[string]any, at string) (*node, error) {
+	for _, key := range unsupportedKeywords {
+		if _, ok := s[key]; ok {
+			return nil, fmt.Errorf("%w: %s/%s is not supported", ErrInvalidSchema, at, key)
+		}
+	}
+
+	n := &node{}
+	if ref, ok := s["$ref"].(string); ok {
+		n.ref = ref
+		c.refs = append(c.refs, n)
+		return n, nil
+	}
+
+	var err error
+	switch t := s["type"].(type) {
+	case nil:
+	case string:
+		n.types = []string{t}
+	case []any:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s/type must be a string or an array of strings", ErrInvalidSchema, at)
+			}
+			n.types = append(n.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s/type must be a string or an array of strings", ErrInvalidSchema, at)
+	}
+	for _, t := range n.types {
+		switch t {
+		case "null", "boolean", "object", "array", "number", "integer", "string":
+		default:
+			return nil, fmt.Errorf("%w: %s/type has unknown type %q", ErrInvalidSchema, at, t)
+		}
+	}
+
+	if enum, ok := s["enum"]; ok {
+		if n.enum, ok = enum.([]any); !ok {
+			return nil, fmt.Errorf("%w: %s/enum must be an array", ErrInvalidSchema, at)
+		}
+	}
+	n.constant, n.hasConst = s["const"]
+
+	if props, ok := s["properties"]; ok {
+		obj, ok := props.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s/properties must be an object", ErrInvalidSchema, at)
+		}
+		n.properties = make(map[string]*node, len(obj))
+		for name, sub := range obj {
+			if n.properties[name], err = c.compile(sub, at+"/properties/"+name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if req, ok := s["required"]; ok {
+		list, ok := req.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s/required must be an array", ErrInvalidSchema, at)
+		}
+		for _, item := range list {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s/required must contain strings", ErrInvalidSchema, at)
+			}
+			n.required = append(n.required, name)
+		}
+	}
+	if sub, ok := s["additionalProperties"]; ok {
+		if n.additionalProperties, err = c.compile(sub, at+"/additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+	if sub, ok := s["items"]; ok {
+		if n.items, err = c.compile(sub, at+"/items"); err != nil {
+			return nil, err
+		}
+	}
+	if sub, ok := s["not"]; ok {
+		if n.not, err = c.compile(sub, at+"/not"); err != nil {
+			return nil, err
+		}
+	}
+	for key, target := range map[string]*[]*node{"allOf": &n.allOf, "anyOf": &n.anyOf, "oneOf": &n.oneOf} {
+		list, ok := s[key]
+		if !ok {
+			continue
+		}
+		subs, ok := list.([]any)
+		if !ok || len(subs) == 0 {
+			return nil, fmt.Errorf("%w: %s/%s must be a non-empty array", ErrInvalidSchema, at, key)
+		}
+		for i, sub := range subs {
+			compiled, err := c.compile(sub, fmt.Sprintf("%s/%s/%d", at, key, i))
+			if err != nil {
+				return nil, err
+			}
+			*target = append(*target, compiled)
+		}
+	}
+
+	for key, target := range map[string]**int{
+		"minProperties": &n.minProperties, "maxProperties": &n.maxProperties,
+		"minItems": &n.minItems, "maxItems": &n.maxItems,
+		"minLength": &n.minLength, "maxLength": &n.maxLength,
+	} {
+		if *target, err = intKeyword(s, key, at); err != nil {
+			return nil, err
+		}
+	}
+	for key, target := range map[string]**float64{
+		"minimum": &n.minimum, "maximum": &n.maximum,
+		"exclusiveMinimum": &n.exclusiveMinimum, "exclusiveMaximum": &n.exclusiveMaximum,
+		"multipleOf": &n.multipleOf,
+	} {
+		if *target, err = numberKeyword(s, key, at); err != nil {
+			return nil, err
+		}
+	}
+	if n.multipleOf != nil && *n.multipleOf <= 0 {
+		return nil, fmt.Errorf("%w: %s/multipleOf must be greater than 0", ErrInvalidSchema, at)
+	}
+	n.uniqueItems, _ = s["uniqueItems"].(bool)
+
+	if pattern, ok := s["pattern"]; ok {
+		str, ok := pattern.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s/pattern must be a string", ErrInvalidSchema, at)
+		}
+		if n.pattern, err = regexp.Compile(str); err != nil {
+			return nil, fmt.Errorf("%w: %s/pattern: %v", ErrInvalidSchema, at, err)
+		}
+	}
+	if format, ok := s["format"]; ok {
+		str, ok := format.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s/format must be a string", ErrInvalidSchema, at)
+		}
+		if _, ok := formats[str]; !ok {
+			return nil, fmt.Errorf("%w: %s/format %q is not supported", ErrInvalidSchema, at, str)
+		}
+		n.format = str
+	}
+
+	// $defs 和 definitions 中的子模式在被引用时编译，这里只检查其格式
+	for _, key := range []string{"$defs", "definitions"} {
+		if defs, ok := s[key]; ok {
+			if _, ok := defs.(map[string]any); !ok {
+				return nil, fmt.Errorf("%w: %s/%s must be an object", ErrInvalidSchema, at, key)
+			}
+		}
+	}
+	return n, nil
+}
+
+// resolve 解析文档内的引用，只支持 # 开头的 JSON Pointer
+func (c *compiler) resolve(ref string) (*node, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("%w: only local $ref is supported, got %q", ErrInvalidSchema, ref)
+	}
+	target := c.doc
+	if pointer != "" {
+		for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			obj, ok := target.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%w: cannot resolve $ref %q", ErrInvalidSchema, ref)
+			}
+			if target, ok = obj[token]; !ok {
+				return nil, fmt.Errorf("%w: cannot resolve $ref %q", ErrInvalidSchema, ref)
+			}
+		}
+	}
+	return c.compile(target, ref)
+}
+
+func intKeyword(s map[string]any, key, at string) (*int, error) {
+	v, ok := s[key]
+	if !ok {
+		return nil, nil
+	}
+	num, ok := v.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s must be a non-negative integer", ErrInvalidSchema, at, key)
+	}
+	i, err := strconv.Atoi(num.String())
+	if err != nil || i < 0 {
+		return nil, fmt.Errorf("%w: %s/%s must be a non-negative integer", ErrInvalidSchema, at, key)
+	}
+	return &i, nil
+}
+
+func numberKeyword(s map[string]any, key, at string) (*float64, error) {
+	v, ok := s[key]
+	if !ok {
+		return nil, nil
+	}
+	num, ok := v.(json.Number)
+	if !ok {
+		// draft-04 中 exclusiveMinimum/exclusiveMaximum 为布尔值，不支持
+		return nil, fmt.Errorf("%w: %s/%s must be a number", ErrInvalidSchema, at, key)
+	}
+	f, err := num.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s/%s must be a number", ErrInvalidSchema, at, key)
+	}
+	return &f, nil
+}
+
+func (n *node) validate(v any, path string, errs *[]ValidationError) {
+	add := func(format string, args ...any) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if n.always != nil {
+		if !*n.always {
+			add("此处不允许出现值")
+		}
+		return
+	}
+	if n.ref != "" {
+		n.resolved.validate(v, path, errs)
+		return
+	}
+
+	if len(n.types) > 0 && !matchesAny(v, n.types) {
+		add("类型应为 %s，实际为 %s", strings.Join(n.types, " 或 "), typeOf(v))
+		return
+	}
+	if n.enum != nil && !containsValue(n.enum, v) {
+		add("应为以下值之一：%s", formatValues(n.enum))
+	}
+	if n.hasConst && !equal(n.constant, v) {
+		add("应为 %s", formatValue(n.constant))
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		n.validateObject(val, path, errs)
+	case []any:
+		n.validateArray(val, path, errs)
+	case string:
+		length := utf8.RuneCountInString(val)
+		if n.minLength != nil && length < *n.minLength {
+			add("长度至少为 %d，实际为 %d", *n.minLength, length)
+		}
+		if n.maxLength != nil && length > *n.maxLength {
+			add("长度至多为 %d，实际为 %d", *n.maxLength, length)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(val) {
+			add("应匹配正则表达式 %s", n.pattern)
+		}
+		if n.format != "" && !formats[n.format](val) {
+			add("应为 %s 格式", n.format)
+		}
+	case json.Number:
+		n.validateNumber(val, add)
+	}
+
+	for _, sub := range n.allOf {
+		sub.validate(v, path, errs)
+	}
+	if len(n.anyOf) > 0 && countMatches(n.anyOf, v, path) == 0 {
+		add("不符合 anyOf 中的任何一个模式")
+	}
+	if len(n.oneOf) > 0 {
+		if matched := countMatches(n.oneOf, v, path); matched != 1 {
+			add("应恰好符合 oneOf 中的一个模式，实际符合 %d 个", matched)
+		}
+	}
+	if n.not != nil && countMatches([]*node{n.not}, v, path) == 1 {
+		add("不应符合 not 中的模式")
+	}
+}
+
+func (n *node) validateObject(obj map[string]any, path string, errs *[]ValidationError) {
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, ValidationError{Path: path, Message: "缺少必需的属性 " + name})
+		}
+	}
+	if n.minProperties != nil && len(obj) < *n.minProperties {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("属性数量至少为 %d", *n.minProperties)})
+	}
+	if n.maxProperties != nil && len(obj) > *n.maxProperties {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("属性数量至多为 %d", *n.maxProperties)})
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		childPath := path + "." + name
+		if sub, ok := n.properties[name]; ok {
+			sub.validate(obj[name], childPath, errs)
+		} else if n.additionalProperties != nil {
+			if n.additionalProperties.always != nil && !*n.additionalProperties.always {
+				*errs = append(*errs, ValidationError{Path: childPath, Message: "不允许出现未定义的属性"})
+				continue
+			}
+			n.additionalProperties.validate(obj[name], childPath, errs)
+		}
+	}
+}
+
+func (n *node) validateArray(arr []any, path string, errs *[]ValidationError) {
+	if n.minItems != nil && len(arr) < *n.minItems {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("元素数量至少为 %d，实际为 %d", *n.minItems, len(arr))})
+	}
+	if n.maxItems != nil && len(arr) > *n.maxItems {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf("元素数量至多为 %d，实际为 %d", *n.maxItems, len(arr))})
+	}
+	if n.uniqueItems {
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if equal(arr[i], arr[j]) {
+					*errs = append(*errs, ValidationError{Path: fmt.Sprintf("%s[%d]", path, i), Message: fmt.Sprintf("与第 %d 个元素重复", j)})
+				}
+			}
+		}
+	}
+	if n.items != nil {
+		for i, item := range arr {
+			n.items.validate(item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func (n *node) validateNumber(num json.Number, add func(string, ...any)) {
+	f, err := num.Float64()
+	if err != nil {
+		add("不是有效的数字")
+		return
+	}
+	if n.minimum != nil && f < *n.minimum {
+		add("应大于或等于 %v", *n.minimum)
+	}
+	if n.maximum != nil && f > *n.maximum {
+		add("应小于或等于 %v", *n.maximum)
+	}
+	if n.exclusiveMinimum != nil && f <= *n.exclusiveMinimum {
+		add("应大于 %v", *n.exclusiveMinimum)
+	}
+	if n.exclusiveMaximum != nil && f >= *n.exclusiveMaximum {
+		add("应小于 %v", *n.exclusiveMaximum)
+	}
+	if n.multipleOf != nil {
+		if q := f / *n.multipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+			add("应为 %v 的倍数", *n.multipleOf)
+		}
+	}
+}
+
+// countMatches 返回值符合的子模式数量
+func countMatches(nodes []*node, v any, path string) int {
+	matched := 0
+	for _, sub := range nodes {
+		var errs []ValidationError
+		sub.validate(v, path, &errs)
+		if len(errs) == 0 {
+			matched++
+		}
+	}
+	return matched
+}
+
+func matchesAny(v any, types []string) bool {
+	actual := typeOf(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf 返回 JSON 值的类型，没有小数部分的数字为 integer
+func typeOf(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		if f, err := val.Float64(); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func containsValue(values []any, v any) bool {
+	for _, candidate := range values {
+		if equal(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal 按 JSON 语义比较两个值，数字按数值比较
+func equal(a, b any) bool {
+	switch av := a.(type) {
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, err1 := av.Float64()
+		bf, err2 := bv.Float64()
+		return err1 == nil && err2 == nil && af == bf
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if other, ok := bv[k]; !ok || !equal(v, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func formatValue(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func formatValues(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = formatValue(v)
+	}
+	return strings.Join(parts, "、")
+}
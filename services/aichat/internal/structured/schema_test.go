@@ -0,0 +1,149 @@
+package structured
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustCompile(t *testing.T, raw string) *Schema {
+	t.Helper()
+	s, err := Compile(json.RawMessage(raw))
+	if err != nil {
+		t.Fatalf("compile %s: %v", raw, err)
+	}
+	return s
+}
+
+func paths(errs []ValidationError) []string {
+	out := make([]string, len(errs))
+	for i, e := range errs {
+		out[i] = e.Path
+	}
+	return out
+}
+
+func TestValidate(t *testing.T) {
+	s := mustCompile(t, `{
+		"type": "object",
+		"required": ["id", "tags", "status"],
+		"additionalProperties": false,
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"name": {"type": ["string", "null"], "minLength": 2, "maxLength": 4, "pattern": "^[a-z]+$"},
+			"price": {"type": "number", "exclusiveMinimum": 0, "multipleOf": 0.01},
+			"tags": {"type": "array", "minItems": 1, "uniqueItems": true, "items": {"$ref": "#/$defs/tag"}},
+			"status": {"enum": ["open", "closed"]},
+			"owner": {"oneOf": [{"type": "string"}, {"$ref": "#/$defs/user"}]}
+		},
+		"$defs": {
+			"tag": {"type": "string", "not": {"const": "spam"}},
+			"user": {"type": "object", "required": ["name"], "properties": {"manager": {"$ref": "#/$defs/user"}}}
+		}
+	}`)
+
+	valid := `{"id":1,"name":null,"price":9.99,"tags":["a","b"],"status":"open","owner":{"name":"x","manager":{"name":"y"}}}`
+	if errs := s.Validate([]byte(valid)); len(errs) != 0 {
+		t.Fatalf("expected %s to be valid, got %v", valid, errs)
+	}
+
+	tests := []struct {
+		data  string
+		paths []string
+	}{
+		{`{"id":0,"tags":["a"],"status":"open"}`, []string{"$.id"}},
+		{`{"id":1.5,"tags":["a"],"status":"open"}`, []string{"$.id"}},
+		{`{"id":1,"tags":[],"status":"done"}`, []string{"$.status", "$.tags"}},
+		{`{"id":1,"tags":["a","a","spam"],"status":"open"}`, []string{"$.tags[1]", "$.tags[2]"}},
+		{`{"id":1,"name":"ABCDE","tags":["a"],"status":"open"}`, []string{"$.name", "$.name"}},
+		{`{"id":1,"price":0,"tags":["a"],"status":"open","extra":true}`, []string{"$.extra", "$.price"}},
+		{`{"id":1,"tags":["a"],"status":"open","owner":{"manager":{}}}`, []string{"$.owner"}},
+		{`{"tags":["a"]}`, []string{"$", "$"}},
+		{`[1,2]`, []string{"$"}},
+		{`{"id":1} trailing`, []string{"$"}},
+	}
+	for _, tt := range tests {
+		if got := paths(s.Validate([]byte(tt.data))); !reflect.DeepEqual(got, tt.paths) {
+			t.Errorf("validate %s: expected errors at %v, got %v", tt.data, tt.paths, s.Validate([]byte(tt.data)))
+		}
+	}
+}
+
+func TestCompileRejectsInvalidSchemas(t *testing.T) {
+	for _, raw := range []string{
+		`not json`,
+		`{"type": "objekt"}`,
+		`{"type": "string", "pattern": "("}`,
+		`{"minLength": -1}`,
+		`{"anyOf": []}`,
+		`{"$ref": "#/$defs/missing"}`,
+		`{"$ref": "https://example.com/schema.json"}`,
+		`{"exclusiveMinimum": true}`,
+		// 未实现的校验关键字不能被静默忽略
+		`{"type": "object", "patternProperties": {"^x": {"type": "string"}}}`,
+		`{"type": "object", "propertyNames": {"maxLength": 3}}`,
+		`{"type": "object", "dependentRequired": {"a": ["b"]}}`,
+		`{"type": "array", "prefixItems": [{"type": "string"}]}`,
+		`{"type": "array", "contains": {"const": 1}}`,
+		`{"if": {"type": "string"}, "then": {"minLength": 1}, "else": {"type": "number"}}`,
+		`{"properties": {"a": {"$ref": "#/$defs/a", "contains": {}}}, "$defs": {"a": {}}}`,
+		`{"items": {"type": "string", "format": "hostname"}}`,
+		`{"format": 1}`,
+	} {
+		if _, err := Compile(json.RawMessage(raw)); !errors.Is(err, ErrInvalidSchema) {
+			t.Errorf("expected %s to be rejected, got %v", raw, err)
+		}
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	s := mustCompile(t, `{
+		"type": "object",
+		"properties": {
+			"at": {"type": "string", "format": "date-time"},
+			"day": {"format": "date"},
+			"email": {"format": "email"},
+			"site": {"format": "uri"},
+			"id": {"format": "uuid"},
+			"ip": {"format": "ipv4"}
+		}
+	}`)
+
+	valid := `{"at":"2025-01-02T03:04:05Z","day":"2025-01-02","email":"a@example.com","site":"https://example.com","id":"123e4567-e89b-12d3-a456-426614174000","ip":"10.0.0.1","other":1}`
+	if errs := s.Validate([]byte(valid)); len(errs) != 0 {
+		t.Fatalf("expected %s to be valid, got %v", valid, errs)
+	}
+	invalid := `{"at":"2025-01-02","day":"01/02/2025","email":"Alice <a@example.com>","site":"example.com","id":"123","ip":"::1"}`
+	want := []string{"$.at", "$.day", "$.email", "$.id", "$.ip", "$.site"}
+	if got := paths(s.Validate([]byte(invalid))); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected errors at %v, got %v", want, s.Validate([]byte(invalid)))
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := map[string]string{
+		`{"a":1}`:                 `{"a":1}`,
+		"```json\n{\"a\":1}\n```": `{"a":1}`,
+		"结果如下：\n```\n[1,2]\n```\n希望有帮助": `[1,2]`,
+		`好的，{"a":{"b":2}}。`:             `{"a":{"b":2}}`,
+		`没有 JSON`:                       `没有 JSON`,
+	}
+	for reply, want := range tests {
+		if got := ExtractJSON(reply); got != want {
+			t.Errorf("ExtractJSON(%q) = %q, want %q", reply, got, want)
+		}
+	}
+}
+
+func TestRepairPromptListsErrors(t *testing.T) {
+	errs := make([]ValidationError, maxReportedErrors+3)
+	for i := range errs {
+		errs[i] = ValidationError{Path: "$.items", Message: "缺少必需的属性 id"}
+	}
+	prompt := RepairPrompt(errs)
+	if strings.Count(prompt, "$.items: 缺少必需的属性 id") != maxReportedErrors || !strings.Contains(prompt, "另有 3 处错误未列出") {
+		t.Fatalf("unexpected repair prompt %q", prompt)
+	}
+}
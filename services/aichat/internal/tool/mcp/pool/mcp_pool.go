@@ -97,6 +97,7 @@ func (p *MCPConnectionPool) GetConnection(ctx context.Context, serverURL string)
 
 	if _, err := cli.Initialize(ctx, initRequest); err != nil {
 		p.logger.Error("初始化MCP连接失败", zap.String("server_url", serverURL), zap.Error(err))
+		cli.Close()
 		return nil, err
 	}
 
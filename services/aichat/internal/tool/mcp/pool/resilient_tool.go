@@ -0,0 +1,32 @@
+package pool
+
+import (
+	"context"
+
+	"weave/pkg/resilience"
+
+	einotool "github.com/cloudwego/eino/components/tool"
+)
+
+// resilientTool 经过MCP服务依赖的熔断和并发隔离调用的工具
+type resilientTool struct {
+	einotool.InvokableTool
+	dependency *resilience.Dependency
+}
+
+// WrapTool 让MCP工具调用经过依赖的熔断和并发隔离
+// 工具调用不一定幂等，因此不重试；非InvokableTool原样返回
+func WrapTool(dependency *resilience.Dependency, t einotool.BaseTool) einotool.BaseTool {
+	invokable, ok := t.(einotool.InvokableTool)
+	if !ok {
+		return t
+	}
+	return &resilientTool{InvokableTool: invokable, dependency: dependency}
+}
+
+// InvokableRun 执行工具调用
+func (t *resilientTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...einotool.Option) (string, error) {
+	return resilience.Call(resilience.WithoutRetry(ctx), t.dependency, func(ctx context.Context) (string, error) {
+		return t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+	})
+}
@@ -0,0 +1,218 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errEmptyFilter 查询条件未指定用户或租户
+var errEmptyFilter = errors.New("usage filter requires a user or tenant")
+
+// usageRecord 用量表记录
+type usageRecord struct {
+	ID               uint      `gorm:"primaryKey"`
+	UserID           string    `gorm:"size:128;index:idx_aichat_usage_user_time,priority:1;not null"`
+	TenantID         string    `gorm:"size:64;index:idx_aichat_usage_tenant_time,priority:1"`
+	ConversationID   string    `gorm:"size:64"`
+	Model            string    `gorm:"size:128"`
+	PromptTemplate   string    `gorm:"size:128"`
+	PromptTokens     int       `gorm:"not null;default:0"`
+	CompletionTokens int       `gorm:"not null;default:0"`
+	Cost             float64   `gorm:"not null;default:0"`
+	Estimated        bool      `gorm:"not null;default:false"`
+	CreatedAt        time.Time `gorm:"index:idx_aichat_usage_user_time,priority:2;index:idx_aichat_usage_tenant_time,priority:2"`
+}
+
+func (usageRecord) TableName() string { return "aichat_usage_records" }
+
+// gormStore 基于GORM的用量存储，与对话使用同一个数据库
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewStore 基于已有的数据库连接创建用量存储，并自动迁移表结构
+func NewStore(db *gorm.DB) (Store, error) {
+	if err := db.AutoMigrate(&usageRecord{}); err != nil {
+		return nil, fmt.Errorf("migrate aichat usage table: %w", err)
+	}
+	return &gormStore{db: db}, nil
+}
+
+// Add 保存一条用量记录
+func (s *gormStore) Add(ctx context.Context, record *Record) error {
+	return s.db.WithContext(ctx).Create(&usageRecord{
+		UserID:           record.UserID,
+		TenantID:         record.TenantID,
+		ConversationID:   record.ConversationID,
+		Model:            record.Model,
+		PromptTemplate:   record.PromptTemplate,
+		PromptTokens:     record.PromptTokens,
+		CompletionTokens: record.CompletionTokens,
+		Cost:             record.Cost,
+		Estimated:        record.Estimated,
+		CreatedAt:        record.CreatedAt,
+	}).Error
+}
+
+// Summarize 按模型和提示词模板版本分组汇总用量
+func (s *gormStore) Summarize(ctx context.Context, filter Filter) (*Summary, error) {
+	if filter.UserID == "" && filter.TenantID == "" {
+		return nil, errEmptyFilter
+	}
+	query := s.db.WithContext(ctx).Model(&usageRecord{})
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.TenantID != "" {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	type totalsRow struct {
+		GroupKey         string
+		Requests         int64
+		PromptTokens     int64
+		CompletionTokens int64
+		Cost             float64
+	}
+	const totalsColumns = "COUNT(*) AS requests, SUM(prompt_tokens) AS prompt_tokens, " +
+		"SUM(completion_tokens) AS completion_tokens, SUM(cost) AS cost"
+	toTotals := func(row totalsRow) Totals {
+		return Totals{
+			Requests:         row.Requests,
+			PromptTokens:     row.PromptTokens,
+			CompletionTokens: row.CompletionTokens,
+			Cost:             row.Cost,
+		}
+	}
+
+	var rows []totalsRow
+	if err := query.Session(&gorm.Session{}).Select("model AS group_key, " + totalsColumns).Group("model").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	models := make([]ModelTotals, 0, len(rows))
+	for _, row := range rows {
+		models = append(models, ModelTotals{Model: row.GroupKey, Totals: toTotals(row)})
+	}
+
+	rows = nil
+	err := query.Session(&gorm.Session{}).Where("prompt_template <> ''").
+		Select("prompt_template AS group_key, " + totalsColumns).Group("prompt_template").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]TemplateTotals, 0, len(rows))
+	for _, row := range rows {
+		templates = append(templates, TemplateTotals{PromptTemplate: row.GroupKey, Totals: toTotals(row)})
+	}
+	return summarize(models, templates), nil
+}
+
+// memoryStore 内存中的用量存储，数据库不可用时使用，进程重启后数据丢失
+type memoryStore struct {
+	mu      sync.RWMutex
+	records []Record
+}
+
+// NewMemoryStore 创建内存用量存储
+func NewMemoryStore() Store {
+	return &memoryStore{}
+}
+
+// Add 保存一条用量记录
+func (s *memoryStore) Add(_ context.Context, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, *record)
+	return nil
+}
+
+// Summarize 按模型和提示词模板版本分组汇总用量
+func (s *memoryStore) Summarize(_ context.Context, filter Filter) (*Summary, error) {
+	if filter.UserID == "" && filter.TenantID == "" {
+		return nil, errEmptyFilter
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byModel := make(map[string]*Totals)
+	byTemplate := make(map[string]*Totals)
+	add := func(groups map[string]*Totals, key string, r Record) {
+		totals, ok := groups[key]
+		if !ok {
+			totals = &Totals{}
+			groups[key] = totals
+		}
+		totals.Requests++
+		totals.PromptTokens += int64(r.PromptTokens)
+		totals.CompletionTokens += int64(r.CompletionTokens)
+		totals.Cost += r.Cost
+	}
+	for _, r := range s.records {
+		switch {
+		case filter.UserID != "" && r.UserID != filter.UserID:
+		case filter.TenantID != "" && r.TenantID != filter.TenantID:
+		case !filter.From.IsZero() && r.CreatedAt.Before(filter.From):
+		case !filter.To.IsZero() && !r.CreatedAt.Before(filter.To):
+		default:
+			add(byModel, r.Model, r)
+			if r.PromptTemplate != "" {
+				add(byTemplate, r.PromptTemplate, r)
+			}
+		}
+	}
+
+	models := make([]ModelTotals, 0, len(byModel))
+	for name, totals := range byModel {
+		models = append(models, ModelTotals{Model: name, Totals: *totals})
+	}
+	templates := make([]TemplateTotals, 0, len(byTemplate))
+	for name, totals := range byTemplate {
+		templates = append(templates, TemplateTotals{PromptTemplate: name, Totals: *totals})
+	}
+	return summarize(models, templates), nil
+}
+
+// summarize 计算总令牌数和合计，按费用、令牌数倒序排列模型，按模板标识排列模板版本
+func summarize(models []ModelTotals, templates []TemplateTotals) *Summary {
+	summary := &Summary{Models: models, Templates: templates}
+	for i := range models {
+		models[i].TotalTokens = models[i].PromptTokens + models[i].CompletionTokens
+		summary.Requests += models[i].Requests
+		summary.PromptTokens += models[i].PromptTokens
+		summary.CompletionTokens += models[i].CompletionTokens
+		summary.TotalTokens += models[i].TotalTokens
+		summary.Cost += models[i].Cost
+	}
+	sort.SliceStable(models, func(i, j int) bool {
+		if models[i].Cost != models[j].Cost {
+			return models[i].Cost > models[j].Cost
+		}
+		if models[i].TotalTokens != models[j].TotalTokens {
+			return models[i].TotalTokens > models[j].TotalTokens
+		}
+		return models[i].Model < models[j].Model
+	})
+	for i := range templates {
+		templates[i].TotalTokens = templates[i].PromptTokens + templates[i].CompletionTokens
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].PromptTemplate < templates[j].PromptTemplate })
+	if summary.Models == nil {
+		summary.Models = []ModelTotals{}
+	}
+	if summary.Templates == nil {
+		summary.Templates = []TemplateTotals{}
+	}
+	return summary
+}
@@ -0,0 +1,123 @@
+package usage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"weave/pkg"
+	"weave/pkg/metrics"
+
+	"go.uber.org/zap"
+)
+
+// Record 一次聊天请求的令牌用量
+type Record struct {
+	UserID           string    `json:"user_id"`                   // 按租户隔离的会话键
+	TenantID         string    `json:"tenant_id"`                 // 租户 ID，从会话键中解析
+	ConversationID   string    `json:"conversation_id,omitempty"` // 对话 ID，兼容接口的无状态请求为空
+	Model            string    `json:"model"`                     // 实际响应请求的模型
+	PromptTemplate   string    `json:"prompt_template,omitempty"` // 使用的提示词模板版本，如 default@v2
+	PromptTokens     int       `json:"prompt_tokens"`             // 输入令牌数
+	CompletionTokens int       `json:"completion_tokens"`         // 输出令牌数
+	Cost             float64   `json:"cost"`                      // 按模型价格表计算的费用
+	Estimated        bool      `json:"estimated"`                 // 模型未返回用量时为估算值
+	CreatedAt        time.Time `json:"created_at"`                // 记录时间
+}
+
+// Filter 用量查询条件，UserID 和 TenantID 至少指定一个
+type Filter struct {
+	UserID   string    // 只统计该用户的用量
+	TenantID string    // 只统计该租户的用量
+	From     time.Time // 起始时间（含），为零值时不限制
+	To       time.Time // 结束时间（不含），为零值时不限制
+}
+
+// Totals 用量合计
+type Totals struct {
+	Requests         int64   `json:"requests"`          // 请求数
+	PromptTokens     int64   `json:"prompt_tokens"`     // 输入令牌数
+	CompletionTokens int64   `json:"completion_tokens"` // 输出令牌数
+	TotalTokens      int64   `json:"total_tokens"`      // 总令牌数
+	Cost             float64 `json:"cost"`              // 费用
+}
+
+// ModelTotals 单个模型的用量合计
+type ModelTotals struct {
+	Model string `json:"model"`
+	Totals
+}
+
+// TemplateTotals 单个提示词模板版本的用量合计，用于比较 A/B 分流的版本
+type TemplateTotals struct {
+	PromptTemplate string `json:"prompt_template"`
+	Totals
+}
+
+// Summary 用量汇总
+type Summary struct {
+	Totals
+	Models    []ModelTotals    `json:"models"`    // 按模型的用量，按费用和令牌数倒序
+	Templates []TemplateTotals `json:"templates"` // 按提示词模板版本的用量，按模板标识排序，不含未使用模板的请求
+}
+
+// Store 用量记录的存储
+type Store interface {
+	// Add 保存一条用量记录
+	Add(ctx context.Context, record *Record) error
+	// Summarize 按条件汇总用量
+	Summarize(ctx context.Context, filter Filter) (*Summary, error)
+}
+
+// PriceFunc 返回模型每千个输入/输出令牌的价格，未知模型返回 0
+type PriceFunc func(model string) (inputPer1K, outputPer1K float64)
+
+// Tracker 记录每次请求的用量：计算费用、更新 Prometheus 指标并保存到存储
+type Tracker struct {
+	store  Store
+	prices PriceFunc
+	logger *zap.Logger
+}
+
+// NewTracker 创建用量记录器，prices 为 nil 时费用为 0
+func NewTracker(store Store, prices PriceFunc) *Tracker {
+	if prices == nil {
+		prices = func(string) (float64, float64) { return 0, 0 }
+	}
+	return &Tracker{store: store, prices: prices, logger: pkg.GetLogger()}
+}
+
+// Record 记录一次请求的用量，保存失败只记录日志，不影响请求
+func (t *Tracker) Record(ctx context.Context, record Record) {
+	if record.TenantID == "" {
+		record.TenantID = TenantOf(record.UserID)
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	inputPer1K, outputPer1K := t.prices(record.Model)
+	record.Cost = (float64(record.PromptTokens)*inputPer1K + float64(record.CompletionTokens)*outputPer1K) / 1000
+
+	metrics.RecordLLMUsage(record.TenantID, record.Model, record.PromptTokens, record.CompletionTokens, record.Cost)
+	if err := t.store.Add(ctx, &record); err != nil {
+		t.logger.Warn("保存用量记录失败", zap.Error(err), zap.String("user_id", record.UserID), zap.String("model", record.Model))
+	}
+}
+
+// Summarize 按条件汇总用量
+func (t *Tracker) Summarize(ctx context.Context, filter Filter) (*Summary, error) {
+	return t.store.Summarize(ctx, filter)
+}
+
+// TenantOf 从 tenant:<租户ID>:user:<用户ID> 格式的会话键中解析租户 ID，格式不符时返回空字符串
+func TenantOf(userID string) string {
+	rest, ok := strings.CutPrefix(userID, "tenant:")
+	if !ok {
+		return ""
+	}
+	tenant, _, ok := strings.Cut(rest, ":")
+	if !ok {
+		return ""
+	}
+	return tenant
+}
@@ -3,6 +3,9 @@ package health
 import (
 	"context"
 	"time"
+
+	"weave/pkg"
+	"weave/pkg/healthcheck"
 )
 
 // DBHealthResult 数据库健康检查结果
@@ -15,6 +18,10 @@ type DBHealthResult struct {
 // HealthService 健康检查服务接口
 type HealthService interface {
 	CheckDatabase(ctx context.Context) DBHealthResult
+	// CheckReplicas 检查只读副本的连通性和复制延迟，未配置副本时返回空
+	CheckReplicas(ctx context.Context) []pkg.ReplicaStatus
+	// RunProbe 执行参与指定探针的已注册检查（数据库、迁移、Redis、SMTP、插件等）
+	RunProbe(ctx context.Context, probe healthcheck.Probe) *healthcheck.Report
 }
 
 // PluginHealthResult 插件健康检查结果
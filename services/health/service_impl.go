@@ -2,18 +2,63 @@ package health
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"weave/config"
+	"weave/pkg"
+	"weave/pkg/healthcheck"
+	"weave/pkg/resilience"
+	"weave/plugins"
+
 	"gorm.io/gorm"
 )
 
 type healthServiceImpl struct {
-	db *gorm.DB
+	db       *gorm.DB
+	registry *healthcheck.Registry
 }
 
-// NewHealthService 创建健康检查服务实例
+// NewHealthService 创建健康检查服务实例，并在默认注册表上注册数据库、SMTP和插件检查
 func NewHealthService(db *gorm.DB) HealthService {
-	return &healthServiceImpl{db: db}
+	return NewHealthServiceWithRegistry(db, healthcheck.Default)
+}
+
+// NewHealthServiceWithRegistry 使用指定的检查注册表创建健康检查服务实例
+func NewHealthServiceWithRegistry(db *gorm.DB, registry *healthcheck.Registry) HealthService {
+	s := &healthServiceImpl{db: db, registry: registry}
+	s.registerChecks()
+	return s
+}
+
+// registerChecks 注册服务自身负责的检查，其他组件（迁移、Redis）在初始化时自行注册
+func (s *healthServiceImpl) registerChecks() {
+	s.registry.Register(healthcheck.Check{
+		Name:     "database",
+		Check:    s.checkDatabase,
+		Critical: true,
+	})
+	// 邮件只影响注册和通知，SMTP熔断时标记为degraded
+	if config.Config.Email.Username != "" {
+		s.registry.Register(healthcheck.Check{
+			Name:     "smtp",
+			Check:    resilience.BreakerCheck(config.DependencySMTP),
+			CacheTTL: -1,
+		})
+	}
+	s.registry.AddSource("plugins", plugins.PluginManager.HealthChecks)
+}
+
+// checkDatabase database 检查
+func (s *healthServiceImpl) checkDatabase(ctx context.Context) error {
+	if res := s.CheckDatabase(ctx); !res.Healthy {
+		return fmt.Errorf("database ping failed: %s", res.Error)
+	}
+	return nil
+}
+
+func (s *healthServiceImpl) RunProbe(ctx context.Context, probe healthcheck.Probe) *healthcheck.Report {
+	return s.registry.Run(ctx, probe)
 }
 
 func (s *healthServiceImpl) CheckDatabase(ctx context.Context) DBHealthResult {
@@ -34,4 +79,7 @@ func (s *healthServiceImpl) CheckDatabase(ctx context.Context) DBHealthResult {
 		Healthy:      true,
 		ResponseTime: duration,
 	}
-}
\ No newline at end of file
+}
+func (s *healthServiceImpl) CheckReplicas(ctx context.Context) []pkg.ReplicaStatus {
+	return pkg.CheckReplicas(ctx)
+}
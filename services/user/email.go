@@ -1,17 +1,29 @@
 package user
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"html/template"
 	"math/big"
+	"net"
 	"net/smtp"
+	"net/textproto"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
+
+	"weave/config"
+	"weave/pkg/resilience"
 )
 
+// sendEmailTimeout 单封邮件发送（含重试）的最长时间
+const sendEmailTimeout = 30 * time.Second
+
 // EmailConfig 邮件服务器配置
 type EmailConfig struct {
 	SMTPServer string
@@ -53,7 +65,7 @@ func (e *emailer) generateVerificationCode() (string, error) {
 // sendVerificationCode 发送验证码到指定邮箱
 func (e *emailer) sendVerificationCode(email, code string) error {
 	if !isValidEmail(email) {
-		return fmt.Errorf("invalid email address format")
+		return fmt.Errorf("邮箱地址格式无效")
 	}
 
 	subject := "Weave 验证码"
@@ -71,7 +83,7 @@ func loadEmailTemplate(code string) string {
 	return strings.Replace(emailTemplate, "{{.Code}}", code, -1)
 }
 
-// sendEmail 发送邮件
+// sendEmail 发送邮件，经过SMTP依赖的重试、熔断和并发隔离
 func (e *emailer) sendEmail(to, subject, body string) error {
 	header := make(map[string]string)
 	header["From"] = e.config.From
@@ -87,9 +99,67 @@ func (e *emailer) sendEmail(to, subject, body string) error {
 	message += "\r\n" + body
 
 	auth := smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.SMTPServer)
-	serverAddr := fmt.Sprintf("%s:%d", e.config.SMTPServer, e.config.SMTPPort)
 
-	return smtp.SendMail(serverAddr, auth, e.config.From, []string{to}, []byte(message))
+	ctx, cancel := context.WithTimeout(context.Background(), sendEmailTimeout)
+	defer cancel()
+	return resilience.Get(config.DependencySMTP).Execute(ctx, func(ctx context.Context) error {
+		err := e.sendMail(ctx, auth, to, []byte(message))
+		// SMTP 5xx为永久错误（如收件人不存在），不重试也不计入熔断
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+			return resilience.Permanent(err)
+		}
+		return err
+	})
+}
+
+// sendMail 与 smtp.SendMail 相同，但连接受ctx控制：ctx结束时关闭连接，正在进行的读写立即返回
+// 发送DATA之后服务器可能已经接收邮件，之后的错误标记为永久错误，避免重试导致重复发送
+func (e *emailer) sendMail(ctx context.Context, auth smtp.Auth, to string, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(e.config.SMTPServer, strconv.Itoa(e.config.SMTPPort)))
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, e.config.SMTPServer)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: e.config.SMTPServer}); err != nil {
+			return err
+		}
+	}
+	if ok, _ := c.Extension("AUTH"); ok && auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.config.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return resilience.Permanent(err)
+	}
+	if err := w.Close(); err != nil {
+		return resilience.Permanent(err)
+	}
+	// 邮件已被服务器接收，QUIT失败不影响结果
+	c.Quit()
+	return nil
 }
 
 // emailTemplate 内嵌的邮件HTML模板
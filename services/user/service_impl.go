@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"weave/models"
+	"weave/pkg"
 	"weave/utils"
 
 	"gorm.io/gorm"
@@ -22,14 +23,14 @@ func NewUserService(db *gorm.DB, emailCfg EmailConfig) UserService {
 }
 
 func (s *userServiceImpl) Register(ctx context.Context, req RegisterRequest) (*models.User, error) {
-	// 检查用户名是否已存在
+	// 检查用户名是否已存在（读主库，避免副本延迟导致重复注册）
 	var existingUser models.User
-	if err := s.db.WithContext(ctx).Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
+	if err := pkg.UsePrimary(s.db).WithContext(ctx).Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
 		return nil, fmt.Errorf("用户名已存在")
 	}
 
 	// 检查邮箱是否已存在
-	if err := s.db.WithContext(ctx).Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if err := pkg.UsePrimary(s.db).WithContext(ctx).Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
 		return nil, fmt.Errorf("邮箱已注册")
 	}
 
@@ -53,8 +54,9 @@ func (s *userServiceImpl) Register(ctx context.Context, req RegisterRequest) (*m
 }
 
 func (s *userServiceImpl) Login(ctx context.Context, tenantID uint, req LoginRequest) (*models.User, error) {
+	// 注册后立即登录的场景需要读主库
 	var user models.User
-	if err := s.db.WithContext(ctx).Where("username = ? AND tenant_id = ?", req.Username, tenantID).First(&user).Error; err != nil {
+	if err := pkg.UsePrimary(s.db).WithContext(ctx).Where("username = ? AND tenant_id = ?", req.Username, tenantID).First(&user).Error; err != nil {
 		return nil, err
 	}
 
@@ -78,7 +80,7 @@ func (s *userServiceImpl) LoginWithCode(ctx context.Context, email, code string,
 	}
 
 	var user models.User
-	if err := s.db.WithContext(ctx).Where("email = ? AND tenant_id = ?", email, tenantID).First(&user).Error; err != nil {
+	if err := pkg.UsePrimary(s.db).WithContext(ctx).Where("email = ? AND tenant_id = ?", email, tenantID).First(&user).Error; err != nil {
 		return nil, err
 	}
 
@@ -87,7 +89,7 @@ func (s *userServiceImpl) LoginWithCode(ctx context.Context, email, code string,
 
 func (s *userServiceImpl) SendVerificationCode(ctx context.Context, username string, tenantID uint) (*models.User, error) {
 	var user models.User
-	if err := s.db.WithContext(ctx).Where("username = ? AND tenant_id = ?", username, tenantID).First(&user).Error; err != nil {
+	if err := pkg.UsePrimary(s.db).WithContext(ctx).Where("username = ? AND tenant_id = ?", username, tenantID).First(&user).Error; err != nil {
 		return nil, err
 	}
 
@@ -110,12 +112,12 @@ func (s *userServiceImpl) SendVerificationCode(ctx context.Context, username str
 		return nil, err
 	}
 
-	// 异步发送邮件
-	go func() {
+	// 异步发送邮件，优雅关闭时等待发送完成
+	pkg.RunBackground(func() {
 		if err := s.emailer.sendVerificationCode(userEmail, originalCode); err != nil {
 			fmt.Printf("Failed to send verification email to %s: %v\n", userEmail, err)
 		}
-	}()
+	})
 
 	return &user, nil
 }
@@ -127,7 +129,7 @@ func (s *userServiceImpl) RefreshToken(ctx context.Context, refreshToken string)
 	}
 
 	var user models.User
-	if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+	if err := pkg.UsePrimary(s.db).WithContext(ctx).First(&user, userID).Error; err != nil {
 		return nil, err
 	}
 
@@ -158,8 +160,9 @@ func (s *userServiceImpl) CreateUser(ctx context.Context, user *models.User) err
 }
 
 func (s *userServiceImpl) UpdateUser(ctx context.Context, id, tenantID uint, user *models.User) (*models.User, error) {
+	// 读改写流程从主库读取，避免基于过期数据覆盖
 	var oldUser models.User
-	if err := s.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&oldUser).Error; err != nil {
+	if err := pkg.UsePrimary(s.db).WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&oldUser).Error; err != nil {
 		return nil, err
 	}
 
@@ -179,7 +182,7 @@ func (s *userServiceImpl) UpdateUser(ctx context.Context, id, tenantID uint, use
 
 func (s *userServiceImpl) DeleteUser(ctx context.Context, id, tenantID uint) (*models.User, error) {
 	var user models.User
-	if err := s.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&user).Error; err != nil {
+	if err := pkg.UsePrimary(s.db).WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&user).Error; err != nil {
 		return nil, err
 	}
 
@@ -192,7 +195,7 @@ func (s *userServiceImpl) DeleteUser(ctx context.Context, id, tenantID uint) (*m
 
 func (s *userServiceImpl) ChangePassword(ctx context.Context, userID, tenantID uint, currentPassword, newPassword string) error {
 	var user models.User
-	if err := s.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", userID, tenantID).First(&user).Error; err != nil {
+	if err := pkg.UsePrimary(s.db).WithContext(ctx).Where("id = ? AND tenant_id = ?", userID, tenantID).First(&user).Error; err != nil {
 		return err
 	}
 
@@ -236,11 +239,11 @@ func (s *userServiceImpl) RecordLoginHistory(ctx context.Context, username, ipAd
 		LoginTime: time.Now(),
 	}
 
-	go func() {
+	pkg.RunBackground(func() {
 		if err := s.db.Create(&loginHistory).Error; err != nil {
 			fmt.Printf("Failed to record login history: %v\n", err)
 		}
-	}()
+	})
 }
 
 // ----- 验证码内部方法 -----
@@ -271,8 +274,9 @@ func (s *userServiceImpl) createVerificationCodeRecord(email string, tenantID ui
 
 // verifyEmailCode 验证邮箱验证码
 func (s *userServiceImpl) verifyEmailCode(email, code string, tenantID uint) (bool, error) {
+	// 验证码刚写入主库，必须从主库读取
 	var verificationCode models.EmailVerificationCode
-	result := s.db.Where("email = ? AND used = false AND expires_at > ? AND tenant_id = ?",
+	result := pkg.UsePrimary(s.db).Where("email = ? AND used = false AND expires_at > ? AND tenant_id = ?",
 		email, time.Now(), tenantID).Order("created_at DESC").First(&verificationCode)
 
 	if result.Error != nil {
@@ -280,7 +284,7 @@ func (s *userServiceImpl) verifyEmailCode(email, code string, tenantID uint) (bo
 	}
 
 	if !utils.CheckPasswordHash(code, verificationCode.Code) {
-		return false, fmt.Errorf("invalid verification code")
+		return false, fmt.Errorf("验证码无效")
 	}
 
 	verificationCode.Used = true
@@ -294,7 +298,7 @@ func (s *userServiceImpl) verifyEmailCode(email, code string, tenantID uint) (bo
 // getLastVerificationTime 获取用户最近一次获取验证码的时间
 func (s *userServiceImpl) getLastVerificationTime(email string, tenantID uint) (time.Time, error) {
 	var verificationCode models.EmailVerificationCode
-	result := s.db.Where("email = ? AND tenant_id = ?", email, tenantID).Order("created_at DESC").First(&verificationCode)
+	result := pkg.UsePrimary(s.db).Where("email = ? AND tenant_id = ?", email, tenantID).Order("created_at DESC").First(&verificationCode)
 
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
@@ -318,7 +322,7 @@ func (s *userServiceImpl) checkVerificationRateLimit(email string, tenantID uint
 	}
 
 	var count int64
-	s.db.Model(&models.EmailVerificationCode{}).
+	pkg.UsePrimary(s.db).Model(&models.EmailVerificationCode{}).
 		Where("email = ? AND tenant_id = ? AND created_at > ?",
 			email, tenantID, time.Now().Add(-24*time.Hour)).
 		Count(&count)
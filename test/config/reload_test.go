@@ -0,0 +1,197 @@
+package config_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"weave/config"
+)
+
+// writeReloadConfig 写入一份可以通过校验的配置文件
+func writeReloadConfig(t *testing.T, path, level string, scanInterval int, secret string) {
+	t.Helper()
+	content := fmt.Sprintf(`server:
+  port: 8090
+database:
+  driver: sqlite
+  path: %s
+jwt:
+  secret: %s
+logger:
+  level: %s
+plugins:
+  dir: %s
+  scanInterval: %d
+reload:
+  debounceMs: 20
+`, filepath.Join(filepath.Dir(path), "weave.db"), secret, level, filepath.Join(filepath.Dir(path), "plugins"), scanInterval)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+// setupReloadConfig 使用临时配置文件加载配置，测试结束后恢复原配置
+func setupReloadConfig(t *testing.T) string {
+	t.Helper()
+	saved := config.Config
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CONFIG_PATH", path)
+	writeReloadConfig(t, path, "info", 5, "secret-1")
+	if err := config.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	t.Cleanup(func() {
+		config.Unsubscribe("test_logger")
+		config.Unsubscribe("test_other")
+		config.Config = saved
+		config.ResetSnapshot()
+	})
+	return path
+}
+
+func TestReloadAppliesValidatedConfigAndNotifies(t *testing.T) {
+	path := setupReloadConfig(t)
+
+	var gotLevel atomic.Value
+	var otherCalls int32
+	config.Subscribe("test_logger", func(oldCfg, newCfg *config.AppConfig) {
+		gotLevel.Store(oldCfg.Logger.Level + "->" + newCfg.Logger.Level)
+	}, "Logger.Level")
+	config.Subscribe("test_other", func(_, _ *config.AppConfig) {
+		atomic.AddInt32(&otherCalls, 1)
+	}, "RateLimit.Policies")
+
+	writeReloadConfig(t, path, "debug", 5, "secret-2")
+	report, err := config.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if !report.Applied || config.Current().Logger.Level != "debug" {
+		t.Fatalf("expected new config to be applied, got %+v", report)
+	}
+	if config.Config.Logger.Level != "info" {
+		t.Errorf("reload must publish through Current and leave Config untouched, got %q", config.Config.Logger.Level)
+	}
+	if got, _ := gotLevel.Load().(string); got != "info->debug" {
+		t.Errorf("expected logger subscriber to receive info->debug, got %q", got)
+	}
+	if atomic.LoadInt32(&otherCalls) != 0 {
+		t.Errorf("subscriber of unchanged keys must not be notified")
+	}
+
+	changes := map[string]config.ConfigChange{}
+	for _, ch := range report.Changes {
+		changes[ch.Key] = ch
+	}
+	if ch, ok := changes["JWT.Secret"]; !ok || ch.Old != "***" || ch.New != "***" {
+		t.Errorf("expected masked secret change, got %+v", report.Changes)
+	}
+	if _, ok := changes["Logger.Level"]; !ok || len(report.Changes) != 2 {
+		t.Errorf("unexpected changes: %+v", report.Changes)
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	path := setupReloadConfig(t)
+
+	writeReloadConfig(t, path, "verbose", 5, "secret-1")
+	if _, err := config.Reload(); err == nil {
+		t.Fatal("expected invalid log level to be rejected")
+	}
+	if config.Current().Logger.Level != "info" {
+		t.Fatalf("rejected reload must keep current config, got %q", config.Current().Logger.Level)
+	}
+
+	report, err := config.PreviewReload()
+	if err != nil {
+		t.Fatalf("PreviewReload failed: %v", err)
+	}
+	if report.Applied || report.ValidationError == "" || len(report.Changes) != 1 {
+		t.Fatalf("expected preview with validation error, got %+v", report)
+	}
+}
+
+func TestReloadReportsRestartRequired(t *testing.T) {
+	path := setupReloadConfig(t)
+
+	content, _ := os.ReadFile(path)
+	content = append([]byte("server:\n  port: 9090\n"), content[len("server:\n  port: 8090\n"):]...)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := config.PreviewReload()
+	if err != nil {
+		t.Fatalf("PreviewReload failed: %v", err)
+	}
+	if len(report.RestartRequired) != 1 || report.RestartRequired[0] != "Server.Port" {
+		t.Fatalf("expected Server.Port to require restart, got %+v", report)
+	}
+	if config.Config.Server.Port != 8090 {
+		t.Fatalf("preview must not apply changes")
+	}
+}
+
+func TestReloadMasksEmailPassword(t *testing.T) {
+	path := setupReloadConfig(t)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString("email:\n  username: ops@example.com\n  password: smtp-pass-2\n")
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := config.PreviewReload()
+	if err != nil {
+		t.Fatalf("PreviewReload failed: %v", err)
+	}
+
+	var password *config.ConfigChange
+	for i, ch := range report.Changes {
+		if ch.Key == "Email.Password" {
+			password = &report.Changes[i]
+		}
+	}
+	if password == nil || password.Old != "***" || password.New != "***" {
+		t.Fatalf("expected masked Email.Password change, got %+v", report.Changes)
+	}
+	if strings.Contains(fmt.Sprintf("%+v", report), "smtp-pass-2") {
+		t.Fatalf("report leaks email password: %+v", report)
+	}
+	if !slices.Contains(report.RestartRequired, "Email.Password") || !slices.Contains(report.RestartRequired, "Email.Username") {
+		t.Fatalf("expected email changes to require restart, got %+v", report.RestartRequired)
+	}
+}
+
+func TestWatchReloadsOnFileChange(t *testing.T) {
+	path := setupReloadConfig(t)
+
+	reloaded := make(chan *config.ReloadReport, 4)
+	stop, err := config.Watch(func(report *config.ReloadReport, err error) {
+		if err == nil {
+			reloaded <- report
+		}
+	})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer stop()
+
+	writeReloadConfig(t, path, "info", 30, "secret-1")
+	select {
+	case report := <-reloaded:
+		if config.Current().Plugins.ScanInterval != 30 || len(report.Changes) != 1 || report.Changes[0].Key != "Plugins.ScanInterval" {
+			t.Fatalf("unexpected reload result: %+v", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for config file reload")
+	}
+}
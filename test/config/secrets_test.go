@@ -0,0 +1,145 @@
+package config_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"weave/config"
+)
+
+func TestResolveSecretRefsEnvAndFile(t *testing.T) {
+	t.Setenv("WEAVE_TEST_SECRET_USER", "svc-user")
+	path := filepath.Join(t.TempDir(), "db_password")
+	if err := os.WriteFile(path, []byte("s3cr3t-pass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := config.ResolveSecretRefs("${env:WEAVE_TEST_SECRET_USER}:${file:" + path + "}@tcp(db:3306)/weave")
+	if err != nil {
+		t.Fatalf("ResolveSecretRefs failed: %v", err)
+	}
+	if got != "svc-user:s3cr3t-pass@tcp(db:3306)/weave" {
+		t.Fatalf("unexpected resolved value %q", got)
+	}
+
+	if got, err := config.ResolveSecretRefs("plain-value"); err != nil || got != "plain-value" {
+		t.Fatalf("plain values must be returned unchanged, got %q, %v", got, err)
+	}
+	if _, err := config.ResolveSecretRefs("${vault:secret/db}"); err == nil {
+		t.Fatal("expected unknown provider to be rejected")
+	}
+	if _, err := config.ResolveSecretRefs("${env:WEAVE_TEST_SECRET_UNSET}"); err == nil {
+		t.Fatal("expected unset environment variable to be rejected")
+	}
+}
+
+func TestEncryptedSecretRoundTrip(t *testing.T) {
+	key, err := config.GenerateSecretKey()
+	if err != nil {
+		t.Fatalf("GenerateSecretKey failed: %v", err)
+	}
+	t.Setenv(config.SecretKeyEnv, key)
+
+	ref, err := config.EncryptSecret("top-secret")
+	if err != nil {
+		t.Fatalf("EncryptSecret failed: %v", err)
+	}
+	if !config.HasSecretRef(ref) || strings.Contains(ref, "top-secret") {
+		t.Fatalf("unexpected encrypted reference %q", ref)
+	}
+	if got, err := config.ResolveSecretRefs(ref); err != nil || got != "top-secret" {
+		t.Fatalf("expected decrypted value, got %q, %v", got, err)
+	}
+
+	other, _ := config.GenerateSecretKey()
+	t.Setenv(config.SecretKeyEnv, other)
+	if _, err := config.ResolveSecretRefs(ref); err == nil {
+		t.Fatal("expected decryption with a different key to fail")
+	}
+}
+
+type staticSecretProvider map[string]string
+
+func (staticSecretProvider) Name() string { return "static" }
+
+func (p staticSecretProvider) Resolve(ref string) (string, error) {
+	if v, ok := p[ref]; ok {
+		return v, nil
+	}
+	return "", errors.New("not found")
+}
+
+func TestRegisterSecretProvider(t *testing.T) {
+	config.RegisterSecretProvider(staticSecretProvider{"api/key": "sk-static"})
+
+	if got, err := config.ResolveSecretRefs("Bearer ${static:api/key}"); err != nil || got != "Bearer sk-static" {
+		t.Fatalf("expected custom provider to resolve, got %q, %v", got, err)
+	}
+	if _, err := config.ResolveSecretRefs("${static:missing}"); err == nil {
+		t.Fatal("expected provider error to be returned")
+	}
+}
+
+func TestLoadConfigResolvesAndMasksSecrets(t *testing.T) {
+	path := setupReloadConfig(t)
+	dir := filepath.Dir(path)
+	secretFile := filepath.Join(dir, "jwt_secret")
+	if err := os.WriteFile(secretFile, []byte("jwt-from-file-1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WEAVE_TEST_SECRET_USER", "svc-user")
+	writeReloadConfig(t, path, "info", 5, "${file:"+secretFile+"}")
+	content, _ := os.ReadFile(path)
+	content = append(content, "csrf:\n  cookieDomain: \"${env:WEAVE_TEST_SECRET_USER}.example.com\"\n"...)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := config.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.Config.JWT.Secret != "jwt-from-file-1" || config.Config.CSRF.CookieDomain != "svc-user.example.com" {
+		t.Fatalf("expected secrets to be resolved, got %q / %q", config.Config.JWT.Secret, config.Config.CSRF.CookieDomain)
+	}
+
+	out, _ := json.Marshal(config.SanitizeConfig())
+	if strings.Contains(string(out), "jwt-from-file-1") || strings.Contains(string(out), "svc-user") {
+		t.Fatalf("sanitized config leaks resolved secrets: %s", out)
+	}
+	if !strings.Contains(string(out), "***.example.com") {
+		t.Fatalf("expected embedded secret to be masked: %s", out)
+	}
+	if config.Config.CSRF.CookieDomain != "svc-user.example.com" {
+		t.Fatal("SanitizeConfig must not modify the live config")
+	}
+
+	// 引用不变、文件内容轮换后热重载会重新解析
+	if err := os.WriteFile(secretFile, []byte("jwt-from-file-2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	report, err := config.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if config.Current().JWT.Secret != "jwt-from-file-2" {
+		t.Fatalf("expected rotated secret to be loaded, got %q", config.Current().JWT.Secret)
+	}
+	if len(report.Changes) != 1 || report.Changes[0].Key != "JWT.Secret" || report.Changes[0].New != "***" {
+		t.Fatalf("expected masked JWT.Secret change, got %+v", report.Changes)
+	}
+
+	// 引用无法解析时拒绝重新加载
+	if err := os.Remove(secretFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Reload(); err == nil {
+		t.Fatal("expected reload with unresolved secret to fail")
+	}
+	if config.Current().JWT.Secret != "jwt-from-file-2" {
+		t.Fatal("failed reload must keep current config")
+	}
+}
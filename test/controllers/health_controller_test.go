@@ -85,7 +85,7 @@ func TestPluginHealthCheck_NotFound(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
 		t.Fatalf("json unmarshal error: %v", err)
 	}
-	if body["status"] != "error" || body["message"] != "插件不存在" {
+	if body["code"] != "PLUGIN_NOT_FOUND" || body["detail"] != "插件不存在" {
 		t.Fatalf("unexpected response: %#v", body)
 	}
 }
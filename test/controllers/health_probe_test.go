@@ -0,0 +1,111 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"weave/pkg/healthcheck"
+	"weave/pkg/lifecycle"
+	"weave/plugins"
+)
+
+// probePlugin 通过 HealthChecker 提供检查的测试插件
+type probePlugin struct {
+	hcTestPlugin
+	err error
+}
+
+func (p *probePlugin) Name() string { return "hc_probe" }
+func (p *probePlugin) HealthChecks() []healthcheck.Check {
+	return []healthcheck.Check{{
+		Name:     "cache",
+		CacheTTL: -1,
+		Check:    func(context.Context) error { return p.err },
+	}}
+}
+
+func probe(t *testing.T, r *gin.Engine, path string) (int, healthcheck.Report) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var report healthcheck.Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("json unmarshal error: %v", err)
+	}
+	return w.Code, report
+}
+
+func checkNames(report healthcheck.Report) map[string]healthcheck.Result {
+	names := make(map[string]healthcheck.Result, len(report.Checks))
+	for _, c := range report.Checks {
+		names[c.Name] = c
+	}
+	return names
+}
+
+func TestHealthProbes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMemoryDBForHealth(t)
+	lifecycle.Default.MarkReady()
+
+	plugin := &probePlugin{}
+	_ = plugins.PluginManager.Unregister("hc_probe")
+	if err := plugins.PluginManager.Register(plugin); err != nil {
+		t.Fatalf("register plugin error: %v", err)
+	}
+	defer func() { _ = plugins.PluginManager.Unregister("hc_probe") }()
+
+	hc := newTestHealthController(db)
+	r := gin.New()
+	r.GET("/livez", hc.Livez)
+	r.GET("/readyz", hc.Readyz)
+	r.GET("/startupz", hc.Startupz)
+
+	code, report := probe(t, r, "/readyz")
+	if code != http.StatusOK || report.Status != healthcheck.StatusOK || report.Checks != nil {
+		t.Fatalf("expected terse ready response, got %d %+v", code, report)
+	}
+
+	code, report = probe(t, r, "/readyz?verbose")
+	checks := checkNames(report)
+	if code != http.StatusOK || !checks["database"].Critical || checks["plugin:hc_probe:cache"].Status != healthcheck.StatusOK {
+		t.Fatalf("expected verbose checks for database and plugin, got %+v", report)
+	}
+	if _, ok := checks["plugin_manager"]; !ok {
+		t.Fatalf("expected plugin manager check, got %+v", report)
+	}
+
+	// 插件检查非关键：失败时就绪探针为degraded但仍然通过
+	plugin.err = errors.New("cache unavailable")
+	code, report = probe(t, r, "/readyz?verbose=1")
+	if code != http.StatusOK || report.Status != healthcheck.StatusDegraded ||
+		checkNames(report)["plugin:hc_probe:cache"].Error != "cache unavailable" {
+		t.Fatalf("expected degraded readiness, got %d %+v", code, report)
+	}
+
+	// 关键检查失败时就绪探针失败，存活探针不受影响
+	healthcheck.Register(healthcheck.Check{
+		Name:     "migrations",
+		Critical: true,
+		Probes:   []healthcheck.Probe{healthcheck.Readiness, healthcheck.Startup},
+		Check:    func(context.Context) error { return errors.New("startup migrations are still running") },
+	})
+	defer healthcheck.Unregister("migrations")
+
+	if code, report = probe(t, r, "/readyz"); code != http.StatusServiceUnavailable || report.Status != healthcheck.StatusFail {
+		t.Fatalf("expected readiness to fail, got %d %+v", code, report)
+	}
+	if code, _ = probe(t, r, "/startupz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected startup to fail until migrations finish, got %d", code)
+	}
+	if code, report = probe(t, r, "/livez?verbose"); code != http.StatusOK || len(report.Checks) != 0 {
+		t.Fatalf("liveness must not depend on readiness checks, got %d %+v", code, report)
+	}
+}
@@ -82,7 +82,8 @@ func TestGetPluginStatus_NotFound(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
 		t.Fatalf("json unmarshal error: %v", err)
 	}
-	if body["error"] != "插件不存在" || body["plugin"] != "ghost" {
+	details, _ := body["details"].(map[string]interface{})
+	if body["detail"] != "插件不存在" || details["plugin"] != "ghost" {
 		t.Fatalf("unexpected response: %#v", body)
 	}
 }
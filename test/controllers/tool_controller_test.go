@@ -61,6 +61,7 @@ func TestGetTool_NotFound(t *testing.T) {
 	r.GET("/tools/:id", tc.GetTool)
 
 	req, _ := http.NewRequest(http.MethodGet, "/tools/999", nil)
+	req.Header.Set("Accept-Language", "en")
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -71,8 +72,8 @@ func TestGetTool_NotFound(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
 		t.Fatalf("json unmarshal error: %v", err)
 	}
-	if body["message"] != "Tool not found" {
-		t.Fatalf("expected message 'Tool not found', got %#v", body["message"])
+	if body["detail"] != "Tool not found" {
+		t.Fatalf("expected detail 'Tool not found', got %#v", body["detail"])
 	}
 }
 
@@ -165,6 +165,7 @@ func TestGetUser_NotFound(t *testing.T) {
 	r.GET("/users/:id", func(c *gin.Context) { uc.GetUser(c) })
 
 	req, _ := http.NewRequest(http.MethodGet, "/users/999", nil)
+	req.Header.Set("Accept-Language", "en")
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusNotFound {
@@ -174,7 +175,7 @@ func TestGetUser_NotFound(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
 		t.Fatalf("json unmarshal error: %v", err)
 	}
-	if body["message"] != "User not found" {
-		t.Fatalf("expected message 'User not found', got %#v", body["message"])
+	if body["detail"] != "User not found" {
+		t.Fatalf("expected detail 'User not found', got %#v", body["detail"])
 	}
 }
\ No newline at end of file
@@ -0,0 +1,68 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"weave/config"
+	"weave/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newAdminRouter 创建一个模拟已认证用户的运维路由
+func newAdminRouter(userID uint) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	r.POST("/admin/config/reload", middleware.AdminMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := config.Config
+	t.Cleanup(func() {
+		config.Config = saved
+		config.ResetSnapshot()
+	})
+
+	tests := []struct {
+		name    string
+		userIDs []uint
+		token   string
+		userID  uint
+		header  string
+		want    int
+	}{
+		{name: "未配置时拒绝已认证用户", userID: 1, want: http.StatusForbidden},
+		{name: "运维用户放行", userIDs: []uint{1, 7}, userID: 7, want: http.StatusOK},
+		{name: "普通用户拒绝", userIDs: []uint{1}, userID: 2, want: http.StatusForbidden},
+		{name: "运维令牌放行", token: "ops-token", userID: 2, header: "ops-token", want: http.StatusOK},
+		{name: "错误令牌拒绝", token: "ops-token", userID: 2, header: "guess", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Config.Admin.UserIDs = tt.userIDs
+			config.Config.Admin.Token = tt.token
+			config.ResetSnapshot()
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil)
+			if tt.header != "" {
+				req.Header.Set(middleware.AdminTokenHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			newAdminRouter(tt.userID).ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"weave/config"
+	"weave/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeCSRFConfig 写入一份可以通过校验的配置文件，CSRF cookie名称与令牌长度成对变化
+func writeCSRFConfig(t *testing.T, path, cookieName string, tokenLength int) {
+	t.Helper()
+	dir := filepath.Dir(path)
+	content := fmt.Sprintf(`server:
+  port: 8090
+database:
+  driver: sqlite
+  path: %s
+jwt:
+  secret: csrf-reload-secret
+plugins:
+  dir: %s
+csrf:
+  enabled: true
+  cookieName: %s
+  tokenLength: %d
+`, filepath.Join(dir, "weave.db"), filepath.Join(dir, "plugins"), cookieName, tokenLength)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+// 使用 -race 运行时可检测热加载与请求读取配置之间的数据竞争
+func TestCSRFMiddlewareDuringReload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := config.Config
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CONFIG_PATH", path)
+	writeCSRFConfig(t, path, "XSRF-A", 16)
+	if err := config.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	t.Cleanup(func() {
+		config.Config = saved
+		config.ResetSnapshot()
+	})
+
+	// 每种cookie名称对应的令牌长度（十六进制编码后为两倍）
+	tokenLengths := map[string]int{"XSRF-A": 16, "XSRF-B": 24}
+
+	r := gin.New()
+	r.Use(middleware.CSRFMiddleware())
+	r.GET("/form", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	stop := make(chan struct{})
+	var reloads sync.WaitGroup
+	reloads.Add(1)
+	go func() {
+		defer reloads.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				writeCSRFConfig(t, path, "XSRF-B", 24)
+			} else {
+				writeCSRFConfig(t, path, "XSRF-A", 16)
+			}
+			if _, err := config.Reload(); err != nil {
+				t.Errorf("Reload failed: %v", err)
+				return
+			}
+		}
+	}()
+
+	var requests sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		requests.Add(1)
+		go func() {
+			defer requests.Done()
+			for i := 0; i < 200; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/form", nil)
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, req)
+
+				cookies := rec.Result().Cookies()
+				if rec.Code != http.StatusOK || len(cookies) != 1 {
+					t.Errorf("expected one CSRF cookie, got status %d and %d cookies", rec.Code, len(cookies))
+					return
+				}
+				// 同一请求内的cookie名称、令牌长度和响应头必须来自同一份配置
+				want, ok := tokenLengths[cookies[0].Name]
+				if !ok || len(cookies[0].Value) != want*2 {
+					t.Errorf("cookie %s has token of length %d, mixed config observed", cookies[0].Name, len(cookies[0].Value))
+					return
+				}
+				if rec.Header().Get("X-CSRF-Token") != cookies[0].Value {
+					t.Errorf("response header token does not match cookie")
+					return
+				}
+			}
+		}()
+	}
+	requests.Wait()
+	close(stop)
+	reloads.Wait()
+}
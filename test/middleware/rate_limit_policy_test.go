@@ -0,0 +1,198 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"weave/config"
+	"weave/middleware"
+	"weave/pkg"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// newPolicyRouter 创建一个使用指定策略限流的路由，userID非0时模拟已认证用户
+func newPolicyRouter(policy config.RateLimitPolicy) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if uid := c.GetHeader("X-Test-User"); uid != "" {
+			c.Set("user_id", uid)
+		}
+		c.Next()
+	})
+	r.Use(middleware.RateLimitMiddleware(policy))
+	r.GET("/limited", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	return r
+}
+
+func doLimited(r *gin.Engine, user string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/limited", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitHeadersAndRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetRateLimitStore(middleware.NewMemoryRateLimitStore())
+	middleware.SetRateLimitPolicies(nil)
+	r := newPolicyRouter(config.RateLimitPolicy{Name: "hdr", KeyBy: config.RateLimitKeyIP, Rate: 1, Burst: 2})
+
+	w1 := doLimited(r, "")
+	if w1.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w1.Code)
+	}
+	if w1.Header().Get("RateLimit-Limit") != "2" || w1.Header().Get("RateLimit-Remaining") != "1" {
+		t.Fatalf("unexpected RateLimit headers: limit=%q remaining=%q",
+			w1.Header().Get("RateLimit-Limit"), w1.Header().Get("RateLimit-Remaining"))
+	}
+
+	doLimited(r, "")
+	w3 := doLimited(r, "")
+	if w3.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w3.Code)
+	}
+	if w3.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected Retry-After 1, got %q", w3.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimitKeyedByUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetRateLimitStore(middleware.NewMemoryRateLimitStore())
+	middleware.SetRateLimitPolicies(nil)
+	r := newPolicyRouter(config.RateLimitPolicy{Name: "per-user", KeyBy: config.RateLimitKeyUser, Rate: 0.001, Burst: 1})
+
+	// 同一IP下的不同用户拥有独立配额
+	if w := doLimited(r, "1"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for user 1, got %d", w.Code)
+	}
+	if w := doLimited(r, "2"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for user 2, got %d", w.Code)
+	}
+	if w := doLimited(r, "1"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for user 1, got %d", w.Code)
+	}
+}
+
+func TestRateLimitCheckSharesMiddlewareQuota(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetRateLimitStore(middleware.NewMemoryRateLimitStore())
+	middleware.SetRateLimitPolicies(nil)
+	policy := config.RateLimitPolicy{Name: "per-message", KeyBy: config.RateLimitKeyUser, Rate: 0.001, Burst: 3}
+
+	// 长连接中每条消息再次限流，与连接建立时的请求共用同一用户的配额
+	var allowed, limited int
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", "7"); c.Next() })
+	r.Use(middleware.RateLimitMiddleware(policy))
+	r.GET("/limited", func(c *gin.Context) {
+		check := middleware.RateLimitCheck(c, policy)
+		for i := 0; i < 3; i++ {
+			if appErr := check(c.Request.Context()); appErr == nil {
+				allowed++
+			} else if pkg.GetHTTPStatus(appErr) == http.StatusTooManyRequests {
+				limited++
+			}
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	if w := doLimited(r, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if allowed != 2 || limited != 1 {
+		t.Fatalf("expected 2 allowed and 1 limited message, got %d and %d", allowed, limited)
+	}
+}
+
+func TestRateLimitPolicyReloadResizesExistingBuckets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetRateLimitStore(middleware.NewMemoryRateLimitStore())
+	middleware.SetRateLimitPolicies(nil)
+	defer middleware.SetRateLimitPolicies(nil)
+	r := newPolicyRouter(config.RateLimitPolicy{Name: "reloaded", KeyBy: config.RateLimitKeyIP, Rate: 0.001, Burst: 3})
+
+	doLimited(r, "")
+	if w := doLimited(r, ""); w.Header().Get("RateLimit-Remaining") != "1" {
+		t.Fatalf("expected 1 remaining, got %q", w.Header().Get("RateLimit-Remaining"))
+	}
+
+	// 收紧策略后，已有的桶立即按新容量限流
+	middleware.SetRateLimitPolicies([]config.RateLimitPolicy{{Name: "reloaded", KeyBy: config.RateLimitKeyIP, Rate: 0.001, Burst: 1}})
+	w := doLimited(r, "")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("expected the last token under the new limit, got %d limit=%q", w.Code, w.Header().Get("RateLimit-Limit"))
+	}
+	if w := doLimited(r, ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 under the tightened policy, got %d", w.Code)
+	}
+
+	// 放宽策略后，增加的容量立即可用
+	middleware.SetRateLimitPolicies([]config.RateLimitPolicy{{Name: "reloaded", KeyBy: config.RateLimitKeyIP, Rate: 0.001, Burst: 3}})
+	for i := 0; i < 2; i++ {
+		if w := doLimited(r, ""); w.Code != http.StatusOK {
+			t.Fatalf("expected request %d to pass under the relaxed policy, got %d", i, w.Code)
+		}
+	}
+	if w := doLimited(r, ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the added capacity is used, got %d", w.Code)
+	}
+}
+
+func TestRateLimitPolicyFromConfigOverridesDefault(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetRateLimitStore(middleware.NewMemoryRateLimitStore())
+	middleware.SetRateLimitPolicies([]config.RateLimitPolicy{
+		{Name: "limited-group", RouteGroup: "/limited", KeyBy: config.RateLimitKeyIP, Rate: 0.001, Burst: 1},
+	})
+	defer middleware.SetRateLimitPolicies(nil)
+	r := newPolicyRouter(config.RateLimitPolicy{Name: "default", KeyBy: config.RateLimitKeyIP, Rate: 100, Burst: 100})
+
+	doLimited(r, "")
+	if w := doLimited(r, ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected route group policy to apply, got %d", w.Code)
+	}
+}
+
+func TestRedisRateLimitSharedAcrossInstances(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	middleware.SetRateLimitStore(middleware.NewRedisRateLimitStore(client, "test"))
+	middleware.SetRateLimitPolicies(nil)
+	defer middleware.SetRateLimitStore(middleware.NewMemoryRateLimitStore())
+
+	for _, policy := range []config.RateLimitPolicy{
+		{Name: "redis-bucket", KeyBy: config.RateLimitKeyIP, Rate: 0.001, Burst: 2},
+		{Name: "redis-window", KeyBy: config.RateLimitKeyIP, Algorithm: config.RateLimitSlidingWindow, Burst: 2, WindowSeconds: 60},
+	} {
+		// 两个路由模拟两个实例，共享同一个Redis配额
+		instanceA := newPolicyRouter(policy)
+		instanceB := newPolicyRouter(policy)
+
+		if w := doLimited(instanceA, ""); w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", policy.Name, w.Code)
+		}
+		if w := doLimited(instanceB, ""); w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", policy.Name, w.Code)
+		}
+		w := doLimited(instanceA, "")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("%s: expected 429 after shared quota is used, got %d", policy.Name, w.Code)
+		}
+		if w.Header().Get("Retry-After") == "" || w.Header().Get("RateLimit-Remaining") != "0" {
+			t.Fatalf("%s: expected Retry-After and zero remaining, got %q / %q",
+				policy.Name, w.Header().Get("Retry-After"), w.Header().Get("RateLimit-Remaining"))
+		}
+	}
+}
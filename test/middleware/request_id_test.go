@@ -0,0 +1,139 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"weave/middleware"
+	"weave/pkg"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestIDEchoedAndGenerated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestIDMiddleware())
+	var seen string
+	r.GET("/rid", func(c *gin.Context) {
+		seen = pkg.RequestIDFromContext(c.Request.Context())
+		c.String(http.StatusOK, "ok")
+	})
+
+	// 客户端传入的请求ID应原样返回
+	req, _ := http.NewRequest("GET", "/rid", nil)
+	req.Header.Set("X-Request-ID", "client-abc_123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Request-ID"); got != "client-abc_123" {
+		t.Fatalf("expected echoed request id, got %q", got)
+	}
+	if seen != "client-abc_123" {
+		t.Fatalf("expected request id in context, got %q", seen)
+	}
+
+	// 非法请求ID应被替换为新生成的ID
+	req2, _ := http.NewRequest("GET", "/rid", nil)
+	req2.Header.Set("X-Request-ID", "bad id\n")
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
+	got := w2.Header().Get("X-Request-ID")
+	if got == "" || got == "bad id\n" {
+		t.Fatalf("expected generated request id, got %q", got)
+	}
+}
+
+func TestAccessLogCarriesRequestFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.DebugLevel)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		// 用观察者日志记录器替换请求级日志记录器的根
+		c.Request = c.Request.WithContext(pkg.ContextWithLogger(c.Request.Context(), zap.New(core)))
+		c.Next()
+	})
+	r.Use(middleware.AccessLogMiddleware(middleware.AccessLogConfig{SampleRate: 1}))
+	r.Use(middleware.PluginContextMiddleware("demo"))
+	r.GET("/plugins/demo/items/:id", func(c *gin.Context) {
+		pkg.AppendLoggerFields(c, zap.Uint("user_id", 7))
+		c.String(http.StatusOK, "ok")
+	})
+
+	req, _ := http.NewRequest("GET", "/plugins/demo/items/1", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterMessage("access").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 access log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["plugin"] != "demo" {
+		t.Fatalf("expected plugin field, got %v", fields["plugin"])
+	}
+	if fields["user_id"] != uint64(7) {
+		t.Fatalf("expected user_id field, got %v", fields["user_id"])
+	}
+	if fields["status"] != int64(http.StatusOK) {
+		t.Fatalf("expected status 200, got %v", fields["status"])
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.DebugLevel)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(pkg.ContextWithLogger(c.Request.Context(), zap.New(core)))
+		c.Next()
+	})
+	// 采样率为0时只记录错误请求
+	r.Use(middleware.AccessLogMiddleware(middleware.AccessLogConfig{SampleRate: 0}))
+	r.GET("/ok", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/fail", func(c *gin.Context) { c.String(http.StatusInternalServerError, "fail") })
+
+	for _, path := range []string{"/ok", "/ok", "/fail"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if n := logs.FilterMessage("access").Len(); n != 1 {
+		t.Fatalf("expected only the failed request to be logged, got %d", n)
+	}
+}
+
+func TestErrorHandlerUsesRequestIDFromContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.NewErrorHandler().HandlerFunc())
+	r.GET("/fail", func(c *gin.Context) {
+		_ = c.Error(pkg.NewBadRequest("bad input", nil))
+	})
+
+	for _, clientID := range []string{"client-abc_123", ""} {
+		req, _ := http.NewRequest("GET", "/fail", nil)
+		if clientID != "" {
+			req.Header.Set("X-Request-ID", clientID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		headerID := w.Header().Get("X-Request-ID")
+		if headerID == "" || (clientID != "" && headerID != clientID) {
+			t.Fatalf("unexpected response request id %q", headerID)
+		}
+		var problem pkg.Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("decode problem: %v", err)
+		}
+		// 错误响应中的请求ID与响应头一致
+		if problem.RequestID != headerID {
+			t.Fatalf("problem request id %q, header %q", problem.RequestID, headerID)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"weave/config"
+	"weave/middleware"
+	"weave/pkg/resilience"
+
+	"github.com/gin-gonic/gin"
+)
+
+// slowHandler 等待请求上下文结束或指定时间后返回，记录是否收到取消
+func slowHandler(wait time.Duration, cancelled *bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			*cancelled = true
+			c.JSON(http.StatusInternalServerError, gin.H{"error": c.Request.Context().Err().Error()})
+		case <-time.After(wait):
+			c.String(http.StatusOK, "done")
+		}
+	}
+}
+
+func serve(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestTimeoutMiddlewareCooperativeCancellation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetRoutePolicies(nil)
+
+	var cancelled bool
+	r := gin.New()
+	r.Use(middleware.TimeoutMiddleware(middleware.TimeoutConfig{DefaultTimeout: 20 * time.Millisecond}))
+	r.GET("/slow", slowHandler(time.Second, &cancelled))
+
+	w := serve(r, "GET", "/slow")
+	if !cancelled {
+		t.Fatal("expected handler to observe request context cancellation")
+	}
+	// 处理函数在超时后写入的错误响应被丢弃，由中间件返回超时响应
+	if w.Code != http.StatusRequestTimeout || w.Body.String() == "" || w.Body.String()[0] != '{' {
+		t.Fatalf("expected timeout response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutMiddlewareMatchesRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetRoutePolicies(nil)
+
+	var cancelled bool
+	cfg := middleware.TimeoutConfig{
+		DefaultTimeout: 20 * time.Millisecond,
+		PathTimeouts:   map[string]time.Duration{"/items/:id/run": time.Second},
+	}
+	r := gin.New()
+	r.Use(middleware.TimeoutMiddleware(cfg))
+	r.POST("/items/:id/run", slowHandler(50*time.Millisecond, &cancelled))
+
+	if w := serve(r, "POST", "/items/42/run"); w.Code != http.StatusOK || cancelled {
+		t.Fatalf("expected route template timeout to apply, got %d", w.Code)
+	}
+}
+
+func TestRoutePolicyOverridesTimeoutAndRetry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetRoutePolicies([]config.RoutePolicy{
+		{Route: "/api", TimeoutMs: 20},
+		{Route: "/api/jobs/:id", Method: "POST", TimeoutMs: -1, MaxRetries: -1},
+		{Route: "/api/jobs/:id", TimeoutMs: 1000, MaxRetries: 5, InitialDelayMs: 10},
+	})
+	t.Cleanup(func() { middleware.SetRoutePolicies(nil) })
+
+	var (
+		cancelled bool
+		retry     resilience.RetryPolicy
+	)
+	r := gin.New()
+	r.Use(middleware.RetryMiddleware(middleware.DefaultRetryConfig()))
+	r.Use(middleware.TimeoutMiddleware(middleware.TimeoutConfig{DefaultTimeout: time.Second}))
+	jobs := func(c *gin.Context) {
+		retry, _ = resilience.RetryPolicyFromContext(c.Request.Context())
+		slowHandler(50*time.Millisecond, &cancelled)(c)
+	}
+	r.GET("/api/jobs/:id", jobs)
+	r.POST("/api/jobs/:id", jobs)
+	r.GET("/api/other", slowHandler(50*time.Millisecond, &cancelled))
+
+	if w := serve(r, "GET", "/api/jobs/1"); w.Code != http.StatusOK {
+		t.Fatalf("expected longest route policy to apply, got %d", w.Code)
+	}
+	if retry.MaxRetries != 5 || retry.InitialDelay != 10*time.Millisecond {
+		t.Fatalf("expected route retry policy in context, got %+v", retry)
+	}
+
+	if w := serve(r, "POST", "/api/jobs/1"); w.Code != http.StatusOK {
+		t.Fatalf("expected method policy without timeout, got %d", w.Code)
+	}
+	if retry.MaxRetries != 0 {
+		t.Fatalf("expected retries to be disabled for POST, got %+v", retry)
+	}
+
+	if w := serve(r, "GET", "/api/other"); w.Code != http.StatusRequestTimeout || !cancelled {
+		t.Fatalf("expected prefix policy timeout, got %d", w.Code)
+	}
+}
+
+func TestPluginRoutePolicyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetRoutePolicies([]config.RoutePolicy{{Plugin: "demo", TimeoutMs: 20}})
+	t.Cleanup(func() { middleware.SetRoutePolicies(nil) })
+
+	var cancelled bool
+	r := gin.New()
+	r.GET("/plugins/demo/slow", middleware.PluginRoutePolicyMiddleware("demo"), slowHandler(time.Second, &cancelled))
+	r.GET("/plugins/other/slow", middleware.PluginRoutePolicyMiddleware("other"), slowHandler(10*time.Millisecond, &cancelled))
+
+	if w := serve(r, "GET", "/plugins/demo/slow"); w.Code != http.StatusRequestTimeout || !cancelled {
+		t.Fatalf("expected plugin timeout, got %d", w.Code)
+	}
+	cancelled = false
+	if w := serve(r, "GET", "/plugins/other/slow"); w.Code != http.StatusOK || cancelled {
+		t.Fatalf("plugins without a policy must not be limited, got %d", w.Code)
+	}
+}
+
+func TestRoutePolicyMiddlewareCoversAllRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.SetRoutePolicies([]config.RoutePolicy{
+		{Route: "/api/v1/slo", TimeoutMs: 20},
+		{Route: "/api/v1/users", TimeoutMs: 1000, MaxRetries: 2},
+	})
+	t.Cleanup(func() { middleware.SetRoutePolicies(nil) })
+
+	var (
+		cancelled bool
+		retry     resilience.RetryPolicy
+	)
+	r := gin.New()
+	app := r.Group("")
+	app.Use(middleware.RoutePolicyMiddleware())
+	// 没有单独添加超时保护的路由组也受策略约束
+	app.GET("/api/v1/slo", slowHandler(time.Second, &cancelled))
+	// 组内的超时中间件不再叠加更短的默认超时
+	users := app.Group("/api/v1/users")
+	users.Use(middleware.RetryMiddleware(middleware.DefaultRetryConfig()))
+	users.Use(middleware.TimeoutMiddleware(middleware.TimeoutConfig{DefaultTimeout: 20 * time.Millisecond}))
+	users.GET("", func(c *gin.Context) {
+		retry, _ = resilience.RetryPolicyFromContext(c.Request.Context())
+		slowHandler(50*time.Millisecond, &cancelled)(c)
+	})
+
+	if w := serve(r, "GET", "/api/v1/slo"); w.Code != http.StatusRequestTimeout || !cancelled {
+		t.Fatalf("expected policy timeout on route without timeout middleware, got %d", w.Code)
+	}
+	cancelled = false
+	if w := serve(r, "GET", "/api/v1/users"); w.Code != http.StatusOK || cancelled {
+		t.Fatalf("expected group timeout to defer to the applied policy, got %d", w.Code)
+	}
+	if retry.MaxRetries != 2 {
+		t.Fatalf("expected applied route retry policy to be kept, got %+v", retry)
+	}
+}
+
+func TestValidateRoutePolicy(t *testing.T) {
+	invalid := []config.RoutePolicy{
+		{TimeoutMs: 100},
+		{Route: "api/v1", TimeoutMs: 100},
+		{Route: "/api", Method: "FETCH"},
+		{Route: "/api", TimeoutMs: -2},
+		{Route: "/api", InitialDelayMs: 500, MaxDelayMs: 100},
+	}
+	for _, p := range invalid {
+		if err := config.ValidateRoutePolicy(p); err == nil {
+			t.Errorf("expected %+v to be rejected", p)
+		}
+	}
+	if err := config.ValidateRoutePolicy(config.RoutePolicy{Route: "/api/v1/tools/:id/execute", Method: "post", TimeoutMs: -1}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package pkg_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"weave/pkg"
+
+	"github.com/glebarez/sqlite"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type metricsWidget struct {
+	ID   uint
+	Name string
+}
+
+// queryCount 读取 db_queries_total 中指定操作和表的计数
+func queryCount(t *testing.T, operation, table string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != "db_queries_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["operation"] == operation && labels["table"] == table {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func openMetricsDB(t *testing.T, threshold time.Duration) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "metrics.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.Use(pkg.NewQueryMetricsPlugin(threshold)); err != nil {
+		t.Fatalf("register plugin: %v", err)
+	}
+	if err := db.AutoMigrate(&metricsWidget{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestQueryMetricsPluginRecordsOperations(t *testing.T) {
+	db := openMetricsDB(t, 0)
+	before := map[string]float64{}
+	for _, op := range []string{"create", "query", "update", "delete"} {
+		before[op] = queryCount(t, op, "metrics_widgets")
+	}
+
+	w := metricsWidget{Name: "a"}
+	db.Create(&w)
+	db.First(&metricsWidget{}, w.ID)
+	db.Model(&w).Update("name", "b")
+	db.Delete(&w)
+
+	for _, op := range []string{"create", "query", "update", "delete"} {
+		if got := queryCount(t, op, "metrics_widgets"); got != before[op]+1 {
+			t.Errorf("expected one %s sample for metrics_widgets, got %v (before %v)", op, got, before[op])
+		}
+	}
+}
+
+func TestQueryMetricsPluginCapturesSlowQueries(t *testing.T) {
+	pkg.ResetSlowQueries()
+	defer pkg.ResetSlowQueries()
+	db := openMetricsDB(t, time.Nanosecond)
+
+	ctx := pkg.ContextWithRequestID(context.Background(), "req-slow-1")
+	for _, name := range []string{"alice@example.com", "bob@example.com"} {
+		db.WithContext(ctx).Raw("SELECT * FROM metrics_widgets WHERE name = '" + name + "' AND id IN (1, 2, 3)").Scan(&[]metricsWidget{})
+	}
+
+	var found *pkg.SlowQueryStat
+	for _, s := range pkg.TopSlowQueries(0) {
+		if strings.Contains(s.Fingerprint, "FROM metrics_widgets WHERE name") {
+			s := s
+			found = &s
+		}
+	}
+	if found == nil {
+		t.Fatalf("expected slow query fingerprint to be captured, got %+v", pkg.TopSlowQueries(0))
+	}
+	if strings.Contains(found.Fingerprint, "example.com") {
+		t.Fatalf("fingerprint leaks parameters: %s", found.Fingerprint)
+	}
+	if found.Count != 2 || found.LastRequestID != "req-slow-1" {
+		t.Fatalf("expected both queries grouped under one fingerprint with request id, got %+v", found)
+	}
+
+	if top := pkg.TopSlowQueries(1); len(top) != 1 {
+		t.Fatalf("expected limit to be applied, got %d entries", len(top))
+	}
+}
+
+func TestFingerprintSQL(t *testing.T) {
+	tests := map[string]string{
+		"SELECT * FROM users WHERE id = 42":                         "SELECT * FROM users WHERE id = ?",
+		"SELECT *\n  FROM users WHERE email = 'a''b@x.io'":          "SELECT * FROM users WHERE email = ?",
+		"SELECT * FROM notes WHERE id IN (?,?, ?) AND user_id = $1": "SELECT * FROM notes WHERE id IN (?) AND user_id = ?",
+		"UPDATE t1 SET score = 3.5 WHERE name = 'x'":                "UPDATE t1 SET score = ? WHERE name = ?",
+	}
+	for in, want := range tests {
+		if got := pkg.FingerprintSQL(in); got != want {
+			t.Errorf("FingerprintSQL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package pkg_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"weave/pkg/healthcheck"
+)
+
+func TestHealthcheckCriticality(t *testing.T) {
+	r := healthcheck.NewRegistry()
+	r.Register(healthcheck.Check{Name: "db", Critical: true, Check: func(context.Context) error { return nil }})
+	r.Register(healthcheck.Check{Name: "smtp", Check: func(context.Context) error { return errors.New("open") }})
+	r.Register(healthcheck.Check{Name: "self", Probes: []healthcheck.Probe{healthcheck.Liveness}})
+
+	report := r.Run(context.Background(), healthcheck.Readiness)
+	if report.Status != healthcheck.StatusDegraded || !report.Healthy() {
+		t.Fatalf("non-critical failure must only degrade readiness, got %+v", report)
+	}
+	if len(report.Checks) != 2 || report.Checks[0].Name != "db" || report.Checks[1].Name != "smtp" {
+		t.Fatalf("expected readiness checks sorted by name, got %+v", report.Checks)
+	}
+
+	r.Register(healthcheck.Check{Name: "db", Critical: true, Check: func(context.Context) error { return errors.New("down") }})
+	if report := r.Run(context.Background(), healthcheck.Readiness); report.Healthy() {
+		t.Fatalf("critical failure must fail readiness, got %+v", report)
+	}
+
+	live := r.Run(context.Background(), healthcheck.Liveness)
+	if live.Status != healthcheck.StatusOK || len(live.Checks) != 1 || live.Checks[0].Name != "self" {
+		t.Fatalf("liveness must only run liveness checks, got %+v", live)
+	}
+}
+
+func TestHealthcheckCachesResults(t *testing.T) {
+	var calls atomic.Int32
+	r := healthcheck.NewRegistry()
+	r.Register(healthcheck.Check{
+		Name:     "redis",
+		CacheTTL: time.Hour,
+		Check:    func(context.Context) error { calls.Add(1); return nil },
+	})
+	r.Register(healthcheck.Check{
+		Name:     "breaker",
+		CacheTTL: -1,
+		Check:    func(context.Context) error { calls.Add(100); return nil },
+	})
+
+	r.Run(context.Background(), healthcheck.Readiness)
+	report := r.Run(context.Background(), healthcheck.Readiness)
+	if got := calls.Load(); got != 201 {
+		t.Fatalf("expected cached check to run once and uncached check twice, got %d", got)
+	}
+	if !report.Checks[1].Cached || report.Checks[0].Cached {
+		t.Fatalf("unexpected cache flags: %+v", report.Checks)
+	}
+}
+
+func TestHealthcheckTimeoutAndPanic(t *testing.T) {
+	r := healthcheck.NewRegistry()
+	r.Register(healthcheck.Check{
+		Name:     "slow",
+		Critical: true,
+		Timeout:  20 * time.Millisecond,
+		Check:    func(context.Context) error { time.Sleep(time.Second); return nil },
+	})
+	r.Register(healthcheck.Check{Name: "broken", Check: func(context.Context) error { panic("boom") }})
+
+	start := time.Now()
+	report := r.Run(context.Background(), healthcheck.Readiness)
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("probe must not wait for a check past its timeout")
+	}
+	if report.Healthy() || len(report.Failed()) != 2 {
+		t.Fatalf("expected timeout and panic to fail, got %+v", report)
+	}
+}
+
+func TestHealthcheckSources(t *testing.T) {
+	checks := []healthcheck.Check{
+		{Name: "plugin:demo:cache", Check: func(context.Context) error { return errors.New("cold") }},
+		{Name: "plugin:demo:boot", Probes: []healthcheck.Probe{healthcheck.Startup}},
+	}
+	r := healthcheck.NewRegistry()
+	r.AddSource("plugins", func() []healthcheck.Check { return checks })
+
+	report := r.Run(context.Background(), healthcheck.Readiness)
+	if report.Status != healthcheck.StatusDegraded || len(report.Checks) != 1 || report.Checks[0].Name != "plugin:demo:cache" {
+		t.Fatalf("expected source checks to be included, got %+v", report)
+	}
+
+	// 来源不再提供的检查（如插件被禁用）不再执行
+	checks = nil
+	if report := r.Run(context.Background(), healthcheck.Readiness); report.Status != healthcheck.StatusOK || len(report.Checks) != 0 {
+		t.Fatalf("expected removed source checks to disappear, got %+v", report)
+	}
+}
@@ -0,0 +1,144 @@
+package pkg_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"weave/pkg"
+	"weave/pkg/lifecycle"
+)
+
+// recordingHook 创建记录启动和停止顺序的钩子
+func recordingHook(name string, order int, log *[]string, mu *sync.Mutex) lifecycle.Hook {
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		*log = append(*log, event+":"+name)
+	}
+	return lifecycle.Hook{
+		Name:  name,
+		Order: order,
+		Start: func(context.Context) error { record("start"); return nil },
+		Stop:  func(context.Context) error { record("stop"); return nil },
+	}
+}
+
+func TestLifecycleOrderAndReadiness(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		log []string
+	)
+	m := lifecycle.NewManager()
+	m.Register(recordingHook("server", lifecycle.OrderServer, &log, &mu))
+	m.Register(recordingHook("db", lifecycle.OrderStorage, &log, &mu))
+	m.Register(recordingHook("writers", lifecycle.OrderWriters, &log, &mu))
+
+	if m.Ready() {
+		t.Fatal("manager must not be ready before Start")
+	}
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if !m.Ready() {
+		t.Fatal("expected manager to be ready after Start")
+	}
+
+	// 排空等待期间就绪状态已经失败
+	m.SetDrainDelay(50 * time.Millisecond)
+	done := make(chan *lifecycle.ShutdownReport)
+	go func() { done <- m.Shutdown(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	if m.Ready() || !m.Draining() {
+		t.Fatal("readiness must fail while draining")
+	}
+	report := <-done
+
+	want := "start:db,start:writers,start:server,stop:server,stop:writers,stop:db"
+	if got := strings.Join(log, ","); got != want {
+		t.Fatalf("unexpected order:\n got %s\nwant %s", got, want)
+	}
+	if len(report.Hooks) != 3 || len(report.Failed()) != 0 || report.DrainDelay != 50*time.Millisecond {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestLifecycleStopHookDeadline(t *testing.T) {
+	m := lifecycle.NewManager()
+	var dbStopped bool
+	m.Register(lifecycle.Hook{
+		Name:  "db",
+		Order: lifecycle.OrderStorage,
+		Stop:  func(context.Context) error { dbStopped = true; return nil },
+	})
+	m.Register(lifecycle.Hook{
+		Name:        "stuck",
+		Order:       lifecycle.OrderBackground,
+		Stop:        func(context.Context) error { time.Sleep(time.Second); return nil },
+		StopTimeout: 20 * time.Millisecond,
+	})
+	m.Register(lifecycle.Hook{
+		Name:  "broken",
+		Order: lifecycle.OrderPlugins,
+		Stop:  func(context.Context) error { return errors.New("boom") },
+	})
+	m.MarkReady()
+
+	start := time.Now()
+	report := m.Shutdown(context.Background())
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("shutdown must not wait for a hook past its deadline")
+	}
+	if !dbStopped {
+		t.Fatal("expected later hooks to run after a timed out hook")
+	}
+	failed := report.Failed()
+	if len(failed) != 2 || failed[0].Name != "broken" || failed[1].Name != "stuck" || !failed[1].TimedOut {
+		t.Fatalf("unexpected failures: %+v", failed)
+	}
+}
+
+func TestLifecycleStartFailureStopsStarted(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		log []string
+	)
+	m := lifecycle.NewManager()
+	m.Register(recordingHook("db", lifecycle.OrderStorage, &log, &mu))
+	m.Register(lifecycle.Hook{
+		Name:  "server",
+		Order: lifecycle.OrderServer,
+		Start: func(context.Context) error { return errors.New("address in use") },
+	})
+
+	err := m.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "server") {
+		t.Fatalf("expected start error for server, got %v", err)
+	}
+	if got := strings.Join(log, ","); got != "start:db,stop:db" || m.Ready() {
+		t.Fatalf("expected started hooks to be stopped, got %s", got)
+	}
+}
+
+func TestFlushBackgroundWaitsForWrites(t *testing.T) {
+	release := make(chan struct{})
+	var finished bool
+	pkg.RunBackground(func() {
+		<-release
+		finished = true
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pkg.FlushBackground(ctx); err == nil || pkg.PendingBackground() < 1 {
+		t.Fatal("expected flush to time out while a write is pending")
+	}
+
+	close(release)
+	if err := pkg.FlushBackground(context.Background()); err != nil || !finished {
+		t.Fatalf("expected pending write to be flushed, err=%v", err)
+	}
+}
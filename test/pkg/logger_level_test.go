@@ -0,0 +1,30 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"weave/pkg"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSetLevelAtRuntime(t *testing.T) {
+	prev := pkg.Level()
+	defer pkg.SetLevel(prev)
+
+	if err := pkg.SetLevel("debug"); err != nil {
+		t.Fatalf("SetLevel failed: %v", err)
+	}
+	if !pkg.GetLogger().Core().Enabled(zapcore.DebugLevel) || pkg.Level() != "debug" {
+		t.Fatalf("expected debug logging to be enabled, level=%s", pkg.Level())
+	}
+	if err := pkg.SetLevel("error"); err != nil {
+		t.Fatalf("SetLevel failed: %v", err)
+	}
+	if pkg.GetLogger().Core().Enabled(zapcore.WarnLevel) {
+		t.Fatal("expected warn logging to be disabled at error level")
+	}
+	if err := pkg.SetLevel("verbose"); err == nil {
+		t.Fatal("expected invalid level to be rejected")
+	}
+}
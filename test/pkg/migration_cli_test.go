@@ -0,0 +1,266 @@
+package pkg_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"weave/pkg"
+	"weave/pkg/migrate/migration"
+)
+
+// writeMigrations 在临时目录中创建三个简单的迁移
+func writeMigrations(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"001_widgets.up.sql":        "CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT);",
+		"001_widgets.down.sql":      "DROP TABLE widgets;",
+		"002_gadgets.up.sql":        "CREATE TABLE gadgets (id INTEGER PRIMARY KEY);",
+		"002_gadgets.down.sql":      "DROP TABLE gadgets;",
+		"003_widget_color.up.sql":   "ALTER TABLE widgets ADD COLUMN color TEXT;",
+		"003_widget_color.down.sql": "ALTER TABLE widgets DROP COLUMN color;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// runMigrate 执行迁移命令并返回输出
+func runMigrate(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := migration.RunCLI(append([]string{"-dir", dir}, args...), &out)
+	return out.String(), err
+}
+
+func setupMigrationDB(t *testing.T) string {
+	t.Helper()
+	useSQLite(t)
+	if err := pkg.InitDatabase(); err != nil {
+		t.Fatalf("InitDatabase failed: %v", err)
+	}
+	return writeMigrations(t)
+}
+
+func statusReport(t *testing.T, dir string) *migration.StatusReport {
+	t.Helper()
+	mm := migration.NewMigrationManagerWithDir(dir, "sqlite")
+	if err := mm.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	report, err := mm.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	return report
+}
+
+func TestMigrateCLIStepsAndGoto(t *testing.T) {
+	dir := setupMigrationDB(t)
+
+	if _, err := runMigrate(t, dir, "up", "1"); err != nil {
+		t.Fatalf("up 1 failed: %v", err)
+	}
+	if r := statusReport(t, dir); r.Version != 1 || r.Pending() != 2 {
+		t.Fatalf("expected version 1 with 2 pending, got %d with %d pending", r.Version, r.Pending())
+	}
+
+	if _, err := runMigrate(t, dir, "goto", "3"); err != nil {
+		t.Fatalf("goto 3 failed: %v", err)
+	}
+	if !pkg.DB.Migrator().HasColumn("widgets", "color") {
+		t.Fatal("expected migration 3 to add widgets.color")
+	}
+
+	if _, err := runMigrate(t, dir, "down", "2"); err != nil {
+		t.Fatalf("down 2 failed: %v", err)
+	}
+	r := statusReport(t, dir)
+	if r.Version != 1 || pkg.DB.Migrator().HasTable("gadgets") {
+		t.Fatalf("expected rollback to version 1, got %d", r.Version)
+	}
+
+	// down 默认只回滚一个迁移
+	if _, err := runMigrate(t, dir, "up"); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if _, err := runMigrate(t, dir, "down"); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	if r := statusReport(t, dir); r.Version != 2 {
+		t.Fatalf("expected down without N to roll back one migration, got version %d", r.Version)
+	}
+}
+
+func TestMigrateRejectsNegativeSteps(t *testing.T) {
+	dir := setupMigrationDB(t)
+	mm := migration.NewMigrationManagerWithDir(dir, "sqlite")
+	if err := mm.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if err := mm.UpSteps(-1); err == nil {
+		t.Fatal("expected up with negative steps to fail")
+	}
+	if r := statusReport(t, dir); r.HasVersion {
+		t.Fatalf("expected no migrations to be applied, got version %d", r.Version)
+	}
+	if err := mm.UpSteps(0); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := mm.DownSteps(-2); err == nil {
+		t.Fatal("expected down with negative steps to fail")
+	}
+	if r := statusReport(t, dir); r.Version != 3 {
+		t.Fatalf("expected no migrations to be rolled back, got version %d", r.Version)
+	}
+	for _, command := range []string{"up", "down", "goto"} {
+		if _, err := mm.Plan(command, -1); err == nil {
+			t.Fatalf("expected %s plan with a negative argument to fail", command)
+		}
+	}
+}
+
+func TestMigrateCLIDryRun(t *testing.T) {
+	dir := setupMigrationDB(t)
+
+	out, err := runMigrate(t, dir, "--dry-run", "up", "2")
+	if err != nil {
+		t.Fatalf("dry-run up failed: %v", err)
+	}
+	if !strings.Contains(out, "CREATE TABLE widgets") || !strings.Contains(out, "CREATE TABLE gadgets") || strings.Contains(out, "ADD COLUMN") {
+		t.Fatalf("unexpected dry-run output:\n%s", out)
+	}
+	if r := statusReport(t, dir); r.HasVersion || pkg.DB.Migrator().HasTable("widgets") {
+		t.Fatal("dry-run must not apply migrations")
+	}
+
+	if _, err := runMigrate(t, dir, "up"); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	out, err = runMigrate(t, dir, "goto", "1", "--dry-run")
+	if err != nil {
+		t.Fatalf("dry-run goto failed: %v", err)
+	}
+	if !strings.Contains(out, "DROP COLUMN color") || !strings.Contains(out, "DROP TABLE gadgets") {
+		t.Fatalf("expected down SQL in dry-run output:\n%s", out)
+	}
+	if r := statusReport(t, dir); r.Version != 3 {
+		t.Fatalf("dry-run must not change version, got %d", r.Version)
+	}
+}
+
+func TestMigrateCLIStatusChecksums(t *testing.T) {
+	dir := setupMigrationDB(t)
+
+	if _, err := runMigrate(t, dir, "up", "2"); err != nil {
+		t.Fatalf("up 2 failed: %v", err)
+	}
+	// 修改已应用的迁移文件
+	if err := os.WriteFile(filepath.Join(dir, "001_widgets.up.sql"), []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := statusReport(t, dir)
+	if len(r.Migrations) != 3 {
+		t.Fatalf("expected 3 migrations, got %d", len(r.Migrations))
+	}
+	first, third := r.Migrations[0], r.Migrations[2]
+	if !first.Applied || !first.Modified || first.AppliedChecksum == "" || first.Checksum == first.AppliedChecksum {
+		t.Fatalf("expected modified applied migration to be reported, got %+v", first)
+	}
+	if r.Migrations[1].Modified || third.Applied || third.Checksum == "" {
+		t.Fatalf("unexpected status: %+v", r.Migrations)
+	}
+
+	out, err := runMigrate(t, dir, "status")
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if !strings.Contains(out, "modified") || !strings.Contains(out, "2 applied, 1 pending") {
+		t.Fatalf("unexpected status output:\n%s", out)
+	}
+}
+
+func TestMigrateCLIBaselineAndForce(t *testing.T) {
+	dir := setupMigrationDB(t)
+
+	// 已有表结构的数据库
+	if err := pkg.DB.Exec("CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runMigrate(t, dir, "baseline", "1"); err != nil {
+		t.Fatalf("baseline failed: %v", err)
+	}
+	if r := statusReport(t, dir); r.Version != 1 || !r.Migrations[0].Applied || r.Migrations[0].AppliedChecksum == "" {
+		t.Fatalf("expected baseline at version 1, got %+v", r)
+	}
+	if _, err := runMigrate(t, dir, "baseline"); err == nil {
+		t.Fatal("expected baseline to refuse a versioned database")
+	}
+
+	if _, err := runMigrate(t, dir, "up"); err != nil {
+		t.Fatalf("up after baseline failed: %v", err)
+	}
+
+	if _, err := runMigrate(t, dir, "force", "2"); err != nil {
+		t.Fatalf("force failed: %v", err)
+	}
+	r := statusReport(t, dir)
+	if r.Version != 2 || r.Dirty || r.Migrations[2].Applied {
+		t.Fatalf("expected forced version 2, got %+v", r)
+	}
+	if !pkg.DB.Migrator().HasColumn("widgets", "color") {
+		t.Fatal("force must not run migrations")
+	}
+}
+
+type driftWidget struct {
+	ID    uint
+	Name  string
+	Color string
+}
+
+func (driftWidget) TableName() string { return "widgets" }
+
+type driftMissing struct {
+	ID uint
+}
+
+func TestDetectDrift(t *testing.T) {
+	dir := setupMigrationDB(t)
+	if _, err := runMigrate(t, dir, "up", "1"); err != nil {
+		t.Fatalf("up 1 failed: %v", err)
+	}
+
+	report, err := migration.DetectDrift(pkg.DB, []interface{}{&driftWidget{}, &driftMissing{}})
+	if err != nil {
+		t.Fatalf("DetectDrift failed: %v", err)
+	}
+	if !report.HasDrift() {
+		t.Fatal("expected drift")
+	}
+	if len(report.MissingTables) != 1 || !strings.HasPrefix(report.MissingTables[0], "drift_missing") {
+		t.Errorf("unexpected missing tables: %v", report.MissingTables)
+	}
+	if len(report.MissingColumns) != 1 || report.MissingColumns[0].Column != "color" {
+		t.Errorf("unexpected missing columns: %v", report.MissingColumns)
+	}
+
+	if _, err := runMigrate(t, dir, "up"); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	report, err = migration.DetectDrift(pkg.DB, []interface{}{&driftWidget{}})
+	if err != nil {
+		t.Fatalf("DetectDrift failed: %v", err)
+	}
+	if report.HasDrift() {
+		t.Fatalf("expected no drift after migrations, got:\n%s", report)
+	}
+}
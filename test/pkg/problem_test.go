@@ -0,0 +1,169 @@
+package pkg_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"weave/pkg"
+	"weave/pkg/i18n"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":                            i18n.DefaultLanguage,
+		"en":                          "en",
+		"en-GB,en;q=0.8":              "en",
+		"fr-FR, en;q=0.5":             "en",
+		"zh":                          "zh-CN",
+		"zh-cn":                       "zh-CN",
+		"de, *;q=0.1":                 i18n.DefaultLanguage,
+		"en;q=0.2, zh-CN;q=0.9":       "zh-CN",
+		"en;q=0, ja":                  i18n.DefaultLanguage,
+		"en-US;q=0.7, zh-TW;q=0.8, *": "zh-CN",
+	}
+	for header, want := range cases {
+		if got := i18n.Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+type problemRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+func serveProblem(t *testing.T, handler gin.HandlerFunc, body, lang string) (*httptest.ResponseRecorder, pkg.Problem) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(pkg.RequestIDHeader, "req-1"); c.Next() })
+	r.POST("/problem", handler)
+
+	req, _ := http.NewRequest(http.MethodPost, "/problem", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if lang != "" {
+		req.Header.Set("Accept-Language", lang)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var problem pkg.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("json unmarshal error: %v", err)
+	}
+	return w, problem
+}
+
+func TestRespondErrorProblem(t *testing.T) {
+	notFound := func(c *gin.Context) {
+		pkg.RespondError(c, pkg.NewPluginNotFoundError("插件 '%s' 不存在", nil).WithArgs("demo"))
+	}
+
+	w, problem := serveProblem(t, notFound, "", "")
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != pkg.ProblemContentType {
+		t.Fatalf("expected 404 problem response, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if problem.Type != "/problems/plugin-not-found" || problem.Code != pkg.ErrPluginNotFound ||
+		problem.Title != "插件不存在" || problem.Detail != "插件 'demo' 不存在" {
+		t.Fatalf("unexpected default language problem: %+v", problem)
+	}
+	if problem.RequestID != "req-1" || problem.Instance != "/problem" || problem.Status != http.StatusNotFound {
+		t.Fatalf("expected request ID and instance, got %+v", problem)
+	}
+
+	w, problem = serveProblem(t, notFound, "", "en-US,en;q=0.9")
+	if w.Header().Get("Content-Language") != "en" || problem.Title != "Plugin not found" || problem.Detail != "Plugin 'demo' not found" {
+		t.Fatalf("expected english problem, got %q %+v", w.Header().Get("Content-Language"), problem)
+	}
+
+	// 非AppError不向客户端暴露原始错误信息
+	_, problem = serveProblem(t, func(c *gin.Context) { pkg.RespondError(c, http.ErrBodyNotAllowed) }, "", "en")
+	if problem.Code != pkg.ErrInternalError || problem.Detail != "Internal server error" {
+		t.Fatalf("expected generic internal error, got %+v", problem)
+	}
+}
+
+func TestBindingProblem(t *testing.T) {
+	bind := func(c *gin.Context) {
+		var req problemRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			pkg.RespondError(c, pkg.NewBindingError(err))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+
+	w, problem := serveProblem(t, bind, `{"email":"not-an-email","password":"123"}`, "en")
+	if w.Code != http.StatusBadRequest || problem.Code != pkg.ErrValidationFailed || len(problem.Errors) != 2 {
+		t.Fatalf("expected two field errors, got %d %+v", w.Code, problem)
+	}
+	email, password := problem.Errors[0], problem.Errors[1]
+	if email.Field != "email" || email.Rule != "email" || email.Message != "email must be a valid email address" {
+		t.Fatalf("unexpected email field error: %+v", email)
+	}
+	if password.Field != "password" || password.Rule != "min" || password.Param != "6" || password.Message != "password must be at least 6" {
+		t.Fatalf("unexpected password field error: %+v", password)
+	}
+
+	_, problem = serveProblem(t, bind, `{"email":"a@b.c"}`, "zh-CN")
+	if len(problem.Errors) != 1 || problem.Errors[0].Message != "password 为必填项" {
+		t.Fatalf("expected localized required error, got %+v", problem.Errors)
+	}
+
+	_, problem = serveProblem(t, bind, `{"email":42}`, "en")
+	if problem.Code != pkg.ErrValidationFormat || len(problem.Errors) != 1 || problem.Errors[0].Field != "email" {
+		t.Fatalf("expected type error on email, got %+v", problem)
+	}
+
+	_, problem = serveProblem(t, bind, `{"email":`, "en")
+	if problem.Code != pkg.ErrBadRequest || problem.Detail != "Request body is not valid JSON" {
+		t.Fatalf("expected malformed JSON error, got %+v", problem)
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/legacy", nil)
+	req.Header.Set("Accept-Language", "en")
+	w := httptest.NewRecorder()
+	pkg.WriteProblem(w, req, pkg.NewRequestTimeout("", nil))
+
+	var problem pkg.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("json unmarshal error: %v", err)
+	}
+	if w.Code != http.StatusRequestTimeout || problem.Title != "Request timeout" || problem.Instance != "/legacy" {
+		t.Fatalf("unexpected problem: %d %+v", w.Code, problem)
+	}
+}
+
+// 错误信息统一以简体中文为源语言，zh-CN 目录只包含约定的消息键，其他语言的目录以中文源文本为键
+func TestLocaleCataloguesUseChineseSource(t *testing.T) {
+	data, err := os.ReadFile("../../pkg/i18n/locales/zh-CN.json")
+	if err != nil {
+		t.Fatalf("read zh-CN catalogue: %v", err)
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		t.Fatalf("parse zh-CN catalogue: %v", err)
+	}
+	for key := range messages {
+		if !strings.HasPrefix(key, "validation.") && !strings.HasPrefix(key, "title.") {
+			t.Errorf("zh-CN catalogue should only contain message IDs, got %q", key)
+		}
+	}
+
+	forbidden := func(c *gin.Context) { pkg.RespondError(c, pkg.NewForbidden("需要运维权限", nil)) }
+	if _, problem := serveProblem(t, forbidden, "", ""); problem.Detail != "需要运维权限" {
+		t.Fatalf("expected source language detail, got %+v", problem)
+	}
+	if _, problem := serveProblem(t, forbidden, "", "en"); problem.Detail != "Admin access required" {
+		t.Fatalf("expected english detail, got %+v", problem)
+	}
+}
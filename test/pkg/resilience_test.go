@@ -0,0 +1,345 @@
+package pkg_test
+
+import (
+	"context"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"weave/config"
+	"weave/pkg/resilience"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func fastRetry(maxRetries int) resilience.RetryPolicy {
+	return resilience.RetryPolicy{MaxRetries: maxRetries, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Multiplier: 2, Jitter: 0.5}
+}
+
+func TestCircuitBreakerHalfOpenProbe(t *testing.T) {
+	breaker := resilience.NewCircuitBreaker(resilience.BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      20 * time.Millisecond,
+		HalfOpenProbes:   1,
+		SuccessThreshold: 1,
+	}, nil)
+	failure := errors.New("boom")
+
+	for i := 0; i < 2; i++ {
+		done, err := breaker.Allow()
+		if err != nil {
+			t.Fatalf("expected call %d to be allowed, got %v", i, err)
+		}
+		done(failure)
+	}
+	if _, err := breaker.Allow(); !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Fatalf("expected breaker to be open, got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if breaker.State() != resilience.StateHalfOpen {
+		t.Fatalf("expected half-open after timeout, got %s", breaker.State())
+	}
+
+	// 半开状态只放行一个探测请求
+	probe, err := breaker.Allow()
+	if err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	if _, err := breaker.Allow(); !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Fatalf("expected second probe to be rejected, got %v", err)
+	}
+	probe(nil)
+	if breaker.State() != resilience.StateClosed {
+		t.Fatalf("expected breaker to close after successful probe, got %s", breaker.State())
+	}
+}
+
+func TestDependencyRetriesAndOpensBreaker(t *testing.T) {
+	dep := resilience.NewDependency("test-retry", resilience.Policy{
+		Retry:   fastRetry(2),
+		Breaker: resilience.BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Minute},
+	})
+
+	var calls int32
+	transient := &resilience.StatusError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}
+	err := dep.Execute(context.Background(), func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return transient
+	})
+	if !errors.As(err, new(*resilience.StatusError)) || calls != 3 {
+		t.Fatalf("expected 3 attempts ending in status error, got %d attempts, err=%v", calls, err)
+	}
+
+	// 三次失败后熔断，后续调用不再到达依赖
+	err = dep.Execute(context.Background(), func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	if !errors.Is(err, resilience.ErrCircuitOpen) || calls != 3 {
+		t.Fatalf("expected short-circuit without calling dependency, got calls=%d err=%v", calls, err)
+	}
+	if snap := dep.Snapshot(); snap.State != "open" || snap.LastError == "" {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+}
+
+func TestDependencyPermanentErrorNotRetried(t *testing.T) {
+	dep := resilience.NewDependency("test-permanent", resilience.Policy{
+		Retry:   fastRetry(3),
+		Breaker: resilience.BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute},
+	})
+
+	var calls int32
+	err := dep.Execute(context.Background(), func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return resilience.Permanent(errors.New("invalid recipient"))
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected a single attempt, got %d (err=%v)", calls, err)
+	}
+	if dep.Breaker().State() != resilience.StateClosed {
+		t.Fatalf("permanent errors must not open the breaker")
+	}
+
+	// 上下文中的策略覆盖依赖的默认重试策略
+	calls = 0
+	ctx := resilience.WithoutRetry(context.Background())
+	_ = dep.Execute(ctx, func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return &resilience.StatusError{StatusCode: http.StatusServiceUnavailable}
+	})
+	if calls != 1 {
+		t.Fatalf("expected retry to be disabled by context, got %d attempts", calls)
+	}
+}
+
+func TestBulkheadRejectsWhenFull(t *testing.T) {
+	dep := resilience.NewDependency("test-bulkhead", resilience.Policy{
+		Retry:         fastRetry(0),
+		MaxConcurrent: 1,
+		MaxWait:       10 * time.Millisecond,
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go dep.Execute(context.Background(), func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	defer close(release)
+
+	err := dep.Execute(context.Background(), func(ctx context.Context) error { return nil })
+	if !errors.Is(err, resilience.ErrBulkheadFull) {
+		t.Fatalf("expected bulkhead rejection, got %v", err)
+	}
+	if snap := dep.Snapshot(); snap.InFlight != 1 || snap.MaxConcurrent != 1 {
+		t.Fatalf("unexpected bulkhead snapshot: %+v", snap)
+	}
+}
+
+func TestTransportRetriesReplayableRequests(t *testing.T) {
+	resilience.Configure([]config.DependencyPolicy{
+		{Name: "test-http", MaxRetries: 2, InitialDelayMs: 1, MaxDelayMs: 2, FailureThreshold: 10},
+	})
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := resilience.NewHTTPClient("test-http")
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"q":1}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 3 {
+		t.Fatalf("expected success on third attempt, got status %d after %d calls", resp.StatusCode, calls)
+	}
+
+	// 重试耗尽后返回最后一次响应，由调用方处理状态码
+	atomic.StoreInt32(&calls, -10)
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected final response instead of error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls != -7 {
+		t.Fatalf("expected 503 after 3 attempts, got %d (calls=%d)", resp.StatusCode, calls)
+	}
+}
+
+func TestTransportHoldsSlotUntilBodyClosed(t *testing.T) {
+	resilience.Configure([]config.DependencyPolicy{
+		{Name: "test-http-stream", MaxRetries: -1, MaxConcurrent: 1},
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: chunk\n\n"))
+	}))
+	defer server.Close()
+
+	// 流式响应在响应体读取期间占用并发槽位
+	client := resilience.NewHTTPClient("test-http-stream")
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if snap := resilience.Get("test-http-stream").Snapshot(); snap.InFlight != 1 {
+		t.Fatalf("expected the slot to be held while the body is open, got %+v", snap)
+	}
+	if _, err := client.Get(server.URL); !errors.Is(err, resilience.ErrBulkheadFull) {
+		t.Fatalf("expected bulkhead rejection while the first body is open, got %v", err)
+	}
+
+	resp.Body.Close()
+	if snap := resilience.Get("test-http-stream").Snapshot(); snap.InFlight != 0 {
+		t.Fatalf("expected the slot to be released after closing the body, got %+v", snap)
+	}
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the request to pass after the body was closed, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestRedisHookTreatsNilAsSuccess(t *testing.T) {
+	resilience.Configure([]config.DependencyPolicy{
+		{Name: "test-redis", MaxRetries: -1, FailureThreshold: 1, OpenTimeoutSeconds: 60},
+	})
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+	client.AddHook(resilience.NewRedisHook("test-redis"))
+	ctx := context.Background()
+
+	if err := client.Get(ctx, "missing").Err(); !errors.Is(err, redis.Nil) {
+		t.Fatalf("expected redis.Nil, got %v", err)
+	}
+	if state := resilience.Get("test-redis").Breaker().State(); state != resilience.StateClosed {
+		t.Fatalf("cache miss must not count as failure, breaker is %s", state)
+	}
+
+	// Redis不可用时熔断，后续命令快速失败
+	mr.Close()
+	if err := client.Set(ctx, "k", "v", 0).Err(); err == nil {
+		t.Fatalf("expected error when redis is down")
+	}
+	if err := client.Set(ctx, "k", "v", 0).Err(); !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Fatalf("expected short-circuit error, got %v", err)
+	}
+}
+
+// dropAfterProxy 转发到Redis的代理，命令中包含 drop 时转发后不返回回复并断开连接，模拟命令已执行但客户端超时
+type dropAfterProxy struct {
+	listener net.Listener
+	drop     []byte
+	seen     atomic.Int32
+}
+
+func newDropAfterProxy(t *testing.T, target, drop string) *dropAfterProxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &dropAfterProxy{listener: ln, drop: []byte(drop)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			client, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(client, target)
+		}
+	}()
+	return p
+}
+
+func (p *dropAfterProxy) serve(client net.Conn, target string) {
+	defer client.Close()
+	server, err := net.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer server.Close()
+	var dropping atomic.Bool
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := server.Read(buf)
+			if err != nil || dropping.Load() {
+				return
+			}
+			client.Write(buf[:n])
+		}
+	}()
+	buf := make([]byte, 4096)
+	for {
+		n, err := client.Read(buf)
+		if err != nil {
+			return
+		}
+		chunk := buf[:n]
+		if bytes.Contains(bytes.ToLower(chunk), p.drop) {
+			p.seen.Add(1)
+			dropping.Store(true)
+			server.Write(chunk)
+			time.Sleep(20 * time.Millisecond)
+			return
+		}
+		server.Write(chunk)
+	}
+}
+
+func TestRedisHookDoesNotReplayWrites(t *testing.T) {
+	resilience.Configure([]config.DependencyPolicy{
+		{Name: "test-redis-writes", MaxRetries: 3, InitialDelayMs: 1, MaxDelayMs: 2, FailureThreshold: 100},
+	})
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	// 执行后连接中断的 INCR 已经生效，不能重放
+	incr := newDropAfterProxy(t, mr.Addr(), "incr")
+	client := redis.NewClient(&redis.Options{Addr: incr.listener.Addr().String(), MaxRetries: -1})
+	defer client.Close()
+	client.AddHook(resilience.NewRedisHook("test-redis-writes"))
+	if err := client.Incr(ctx, "counter").Err(); err == nil {
+		t.Fatal("expected the interrupted INCR to fail")
+	}
+	if got := incr.seen.Load(); got != 1 {
+		t.Fatalf("expected INCR to be sent once, got %d", got)
+	}
+	if v, _ := mr.Get("counter"); v != "1" {
+		t.Fatalf("expected counter to be incremented once, got %q", v)
+	}
+
+	// 只读命令仍然重试
+	mr.Set("key", "value")
+	get := newDropAfterProxy(t, mr.Addr(), "get")
+	reader := redis.NewClient(&redis.Options{Addr: get.listener.Addr().String(), MaxRetries: -1})
+	defer reader.Close()
+	reader.AddHook(resilience.NewRedisHook("test-redis-writes"))
+	if err := reader.Get(ctx, "key").Err(); err == nil {
+		t.Fatal("expected every GET attempt to be interrupted")
+	}
+	if got := get.seen.Load(); got != 4 {
+		t.Fatalf("expected GET to be retried 3 times, got %d attempts", got)
+	}
+}
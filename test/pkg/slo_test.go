@@ -0,0 +1,90 @@
+package pkg_test
+
+import (
+	"strings"
+	"testing"
+
+	"weave/config"
+	"weave/pkg/slo"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGenerateSLORules(t *testing.T) {
+	objectives := []config.SLOObjective{
+		{Name: "users-availability", Type: config.SLOTypeAvailability, RouteGroup: "/api/v1/users", Objective: 99.9},
+		{Name: "note-latency", Type: config.SLOTypeLatency, Plugin: "note", Objective: 99, LatencyThreshold: 0.5},
+	}
+
+	data, err := slo.RenderRules(objectives)
+	if err != nil {
+		t.Fatalf("render rules error: %v", err)
+	}
+
+	var file slo.RuleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		t.Fatalf("generated rules are not valid yaml: %v", err)
+	}
+	if len(file.Groups) != 2 {
+		t.Fatalf("expected 2 rule groups, got %d", len(file.Groups))
+	}
+
+	alerts := 0
+	for _, r := range file.Groups[0].Rules {
+		if r.Alert != "" {
+			alerts++
+			if r.Labels["slo"] != "users-availability" || r.Labels["severity"] == "" {
+				t.Fatalf("unexpected alert labels: %v", r.Labels)
+			}
+		}
+	}
+	if alerts != 4 {
+		t.Fatalf("expected 4 multi-window burn-rate alerts, got %d", alerts)
+	}
+
+	text := string(data)
+	if !strings.Contains(text, `http_requests_total{endpoint=~"/api/v1/users.*",status=~"5.."}`) {
+		t.Fatalf("expected availability expression on http_requests_total, got:\n%s", text)
+	}
+	if !strings.Contains(text, `plugin_execution_duration_seconds_bucket{plugin_name="note",le="0.5"}`) {
+		t.Fatalf("expected latency expression on plugin histogram, got:\n%s", text)
+	}
+	if !strings.Contains(text, "(14.4 * 0.001)") {
+		t.Fatalf("expected fast burn threshold for 99.9%% objective, got:\n%s", text)
+	}
+}
+
+func TestSLOTrackerStatus(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "http_requests_total"}, []string{"method", "endpoint", "status"})
+	registry.MustRegister(requests)
+
+	requests.WithLabelValues("GET", "/api/v1/users/", "200").Add(998)
+	requests.WithLabelValues("GET", "/api/v1/users/:id", "500").Add(2)
+	// 其他路由组的错误不应计入
+	requests.WithLabelValues("GET", "/api/v1/teams/", "500").Add(50)
+
+	tracker := slo.NewTracker(registry, []config.SLOObjective{
+		{Name: "users", Type: config.SLOTypeAvailability, RouteGroup: "/api/v1/users", Objective: 99.9},
+	})
+	statuses, err := tracker.Status()
+	if err != nil {
+		t.Fatalf("status error: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("expected 1 status, got %d", len(statuses))
+	}
+
+	st := statuses[0]
+	if st.TotalEvents != 1000 || st.BadEvents != 2 {
+		t.Fatalf("expected 1000 total / 2 bad events, got %v / %v", st.TotalEvents, st.BadEvents)
+	}
+	// 错误率0.2%，预算0.1%，预算已消耗200%
+	if st.BudgetConsumed != 2 || st.Status != slo.BudgetExhausted {
+		t.Fatalf("expected exhausted budget (consumed 2), got %v (%s)", st.BudgetConsumed, st.Status)
+	}
+	if st.BurnRates["1h"] != 2 {
+		t.Fatalf("expected burn rate 2, got %v", st.BurnRates["1h"])
+	}
+}
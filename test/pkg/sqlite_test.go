@@ -0,0 +1,141 @@
+package pkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"weave/config"
+	"weave/pkg"
+	"weave/pkg/migrate/migration"
+)
+
+// useSQLite 切换到临时目录中的SQLite数据库，测试结束后恢复原配置
+func useSQLite(t *testing.T) string {
+	t.Helper()
+	saved := config.Config.Database
+	path := filepath.Join(t.TempDir(), "data", "weave.db")
+	config.Config.Database.Driver = "sqlite"
+	config.Config.Database.Path = path
+	config.Config.Database.BusyTimeoutMs = 3000
+	t.Cleanup(func() {
+		pkg.CloseDatabase()
+		config.Config.Database = saved
+	})
+	return path
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	dsn := pkg.SQLiteDSN("/var/lib/weave/weave.db", 5000)
+	for _, want := range []string{"busy_timeout%285000%29", "journal_mode%28WAL%29", "_txlock=immediate"} {
+		if !strings.Contains(dsn, want) {
+			t.Errorf("dsn %q missing %q", dsn, want)
+		}
+	}
+
+	// 内存数据库不使用WAL
+	if dsn := pkg.SQLiteDSN(":memory:", 0); strings.Contains(dsn, "journal_mode") {
+		t.Errorf("memory dsn must not enable WAL: %q", dsn)
+	}
+}
+
+func TestInitDatabaseSQLite(t *testing.T) {
+	path := useSQLite(t)
+	if err := pkg.InitDatabase(); err != nil {
+		t.Fatalf("InitDatabase with sqlite failed: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected database file to be created: %v", err)
+	}
+
+	var journalMode string
+	if err := pkg.DB.Raw("PRAGMA journal_mode").Scan(&journalMode).Error; err != nil {
+		t.Fatalf("query journal_mode failed: %v", err)
+	}
+	if journalMode != "wal" {
+		t.Errorf("expected WAL journal mode, got %q", journalMode)
+	}
+
+	var busyTimeout int
+	if err := pkg.DB.Raw("PRAGMA busy_timeout").Scan(&busyTimeout).Error; err != nil {
+		t.Fatalf("query busy_timeout failed: %v", err)
+	}
+	if busyTimeout != 3000 {
+		t.Errorf("expected busy_timeout 3000, got %d", busyTimeout)
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	useSQLite(t)
+	if err := pkg.InitDatabase(); err != nil {
+		t.Fatalf("InitDatabase with sqlite failed: %v", err)
+	}
+
+	// 执行仓库自带的SQLite迁移文件
+	mm := migration.NewMigrationManagerWithDir(filepath.Join("..", "..", "pkg", "migrate", "data_sql", "sqlite"), "sqlite")
+	if err := mm.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := mm.Up(); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	status, err := mm.GetStatus()
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if !strings.Contains(status, "1") {
+		t.Errorf("expected version 1 in status, got %q", status)
+	}
+
+	var tables int64
+	pkg.DB.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('users', 'notes', 'team_member', 'audit_logs')").Scan(&tables)
+	if tables != 4 {
+		t.Fatalf("expected migrated tables to exist, found %d", tables)
+	}
+
+	// 更新时间由触发器维护
+	if err := pkg.DB.Exec("INSERT INTO users (username, password, updated_at) VALUES ('alice', 'x', '2000-01-01 00:00:00')").Error; err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if err := pkg.DB.Exec("UPDATE users SET password = 'y' WHERE username = 'alice'").Error; err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	var updatedAt string
+	pkg.DB.Raw("SELECT updated_at FROM users WHERE username = 'alice'").Scan(&updatedAt)
+	if strings.HasPrefix(updatedAt, "2000") {
+		t.Errorf("expected trigger to refresh updated_at, got %q", updatedAt)
+	}
+
+	if err := mm.Down(); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+}
+
+func TestGenerateInitialMigrationsSQLite(t *testing.T) {
+	useSQLite(t)
+	if err := pkg.InitDatabase(); err != nil {
+		t.Fatalf("InitDatabase with sqlite failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	mm := migration.NewMigrationManagerWithDir(dir, "sqlite")
+	if err := mm.GenerateInitialMigrations(); err != nil {
+		t.Fatalf("GenerateInitialMigrations failed: %v", err)
+	}
+	up, err := os.ReadFile(filepath.Join(dir, "001_initial_schema.up.sql"))
+	if err != nil {
+		t.Fatalf("read generated migration: %v", err)
+	}
+	if !strings.Contains(string(up), "AUTOINCREMENT") || strings.Contains(string(up), "ENGINE=InnoDB") {
+		t.Fatalf("expected SQLite dialect, got:\n%s", up)
+	}
+
+	// 生成的SQL可以直接在SQLite上执行
+	if err := mm.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := mm.Up(); err != nil {
+		t.Fatalf("Up with generated migration failed: %v", err)
+	}
+}
@@ -122,8 +122,8 @@ func TestPluginHealth_NotFound(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
 		t.Fatalf("json unmarshal error: %v", err)
 	}
-	if body["message"] != "插件不存在" {
-		t.Fatalf("expected message '插件不存在', got %#v", body["message"])
+	if body["detail"] != "插件不存在" {
+		t.Fatalf("expected detail '插件不存在', got %#v", body["detail"])
 	}
 }
 
@@ -133,6 +133,7 @@ func TestPluginsList_Unauthorized(t *testing.T) {
 	router := routers.SetupRouter(newControllersForTest(db))
 
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/plugins/", nil)
+	req.Header.Set("Accept-Language", "en")
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -144,8 +145,8 @@ func TestPluginsList_Unauthorized(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
 		t.Fatalf("json unmarshal error: %v", err)
 	}
-	if body["error"] != "Authorization header is required" {
-		t.Fatalf("expected error 'Authorization header is required', got %#v", body["error"])
+	if body["detail"] != "Authorization header is required" {
+		t.Fatalf("expected detail 'Authorization header is required', got %#v", body["detail"])
 	}
 }
 
@@ -190,4 +191,33 @@ func TestMetricsEndpoint_OK(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", w.Code)
 	}
-}
\ No newline at end of file
+}
+func TestAdminRoutes_RequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_ = config.LoadConfig()
+	saved := config.Config
+	t.Cleanup(func() {
+		config.Config = saved
+		config.ResetSnapshot()
+	})
+	config.Config.Admin.UserIDs = []uint{1}
+	config.ResetSnapshot()
+
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{NamingStrategy: schema.NamingStrategy{SingularTable: true}})
+	router := routers.SetupRouter(newControllersForTest(db))
+
+	for userID, want := range map[uint]int{1: http.StatusOK, 2: http.StatusForbidden} {
+		accessToken, err := utils.GenerateToken(userID, 1)
+		if err != nil {
+			t.Fatalf("generate token error: %v", err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/admin/slow-queries", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Fatalf("user %d: expected %d, got %d", userID, want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package services_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"weave/config"
+	"weave/models"
+	"weave/pkg"
+	"weave/pkg/resilience"
+	"weave/services/user"
+)
+
+// fakeSMTPServer 最小的SMTP服务器，记录连接数和收到的DATA次数
+type fakeSMTPServer struct {
+	ln         net.Listener
+	conns      atomic.Int32
+	data       atomic.Int32
+	dropFirst  bool // 第一个连接在 MAIL 命令后断开（发送DATA之前的临时故障）
+	dropOnData bool // 收到邮件内容后断开，不返回结果
+	wg         sync.WaitGroup
+}
+
+func newFakeSMTPServer(t *testing.T, dropFirst, dropOnData bool) *fakeSMTPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTPServer{ln: ln, dropFirst: dropFirst, dropOnData: dropOnData}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn, s.conns.Add(1))
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn, n int32) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(cmd, "MAIL"):
+			if s.dropFirst && n == 1 {
+				return
+			}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT"):
+			reply("250 OK")
+		case cmd == "DATA":
+			s.data.Add(1)
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			for {
+				body, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if body == ".\r\n" {
+					break
+				}
+			}
+			if s.dropOnData {
+				return
+			}
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// sendCode 注册用户并发送验证码，等待后台发送完成
+func sendCode(t *testing.T, server *fakeSMTPServer) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{NamingStrategy: schema.NamingStrategy{SingularTable: true}})
+	if err != nil {
+		t.Fatalf("gorm open error: %v", err)
+	}
+	if err := models.MigrateTables(db); err != nil {
+		t.Fatalf("migrate tables error: %v", err)
+	}
+
+	addr := server.ln.Addr().(*net.TCPAddr)
+	svc := user.NewUserService(db, user.EmailConfig{SMTPServer: "127.0.0.1", SMTPPort: addr.Port, From: "noreply@example.com"})
+	ctx := context.Background()
+	if err := db.Create(&models.User{Username: "alice", Email: "alice@example.com"}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := svc.SendVerificationCode(ctx, "alice", 0); err != nil {
+		t.Fatalf("send verification code: %v", err)
+	}
+	flushCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := pkg.FlushBackground(flushCtx); err != nil {
+		t.Fatalf("flush background: %v", err)
+	}
+}
+
+func configureSMTPRetries(t *testing.T) {
+	t.Helper()
+	resilience.Configure([]config.DependencyPolicy{{Name: config.DependencySMTP, MaxRetries: 3, InitialDelayMs: 1, MaxDelayMs: 5}})
+	t.Cleanup(func() { resilience.Configure(nil) })
+}
+
+func TestSendEmailRetriesBeforeData(t *testing.T) {
+	configureSMTPRetries(t)
+	server := newFakeSMTPServer(t, true, false)
+	sendCode(t, server)
+
+	if got := server.conns.Load(); got != 2 {
+		t.Fatalf("expected one retry after the dropped connection, got %d connections", got)
+	}
+	if got := server.data.Load(); got != 1 {
+		t.Fatalf("expected the message to be sent once, got %d", got)
+	}
+}
+
+func TestSendEmailDoesNotRetryAfterData(t *testing.T) {
+	configureSMTPRetries(t)
+	server := newFakeSMTPServer(t, false, true)
+	sendCode(t, server)
+
+	// 服务器可能已经接收了邮件，重试会导致用户收到多封验证码
+	if got := server.data.Load(); got != 1 {
+		t.Fatalf("expected DATA to be sent once, got %d", got)
+	}
+	if got := server.conns.Load(); got != 1 {
+		t.Fatalf("expected no reconnect after DATA, got %d connections", got)
+	}
+}
@@ -264,7 +264,7 @@ func generatePluginCode(info PluginInfo) string {
 	code.WriteString("	\t\t\t// 这里是处理逻辑\n")
 	code.WriteString("	\t\t\tresult, err := p.GetResources()\n")
 	code.WriteString("	\t\t\tif err != nil {\n")
-	code.WriteString("	\t\t\t\tc.JSON(500, gin.H{\"error\": err.Error()})\n")
+	code.WriteString("	\t\t\t\tpkg.RespondError(c, pkg.NewPluginExecutionError(\"获取资源列表失败\", err))\n")
 	code.WriteString("	\t\t\t\treturn\n")
 	code.WriteString("	\t\t\t}\n")
 	code.WriteString("	\t\t\tc.JSON(200, result)\n")
@@ -286,12 +286,12 @@ func generatePluginCode(info PluginInfo) string {
 	code.WriteString("	\t\t\t\tValue string `json:\"value\"`\n")
 	code.WriteString("	\t\t\t}\n\n")
 	code.WriteString("	\t\t\tif err := c.ShouldBindJSON(&request); err != nil {\n")
-	code.WriteString("	\t\t\t\tc.JSON(400, gin.H{\"error\": err.Error()})\n")
+	code.WriteString("	\t\t\t\tpkg.RespondError(c, pkg.NewBindingError(err))\n")
 	code.WriteString("	\t\t\t\treturn\n")
 	code.WriteString("	\t\t\t}\n\n")
 	code.WriteString("	\t\t\tresult, err := p.CreateResource(request.Name, request.Value)\n")
 	code.WriteString("	\t\t\tif err != nil {\n")
-	code.WriteString("	\t\t\t\tc.JSON(500, gin.H{\"error\": err.Error()})\n")
+	code.WriteString("	\t\t\t\tpkg.RespondError(c, pkg.NewPluginExecutionError(\"创建资源失败\", err))\n")
 	code.WriteString("	\t\t\t\treturn\n")
 	code.WriteString("	\t\t\t}\n")
 	code.WriteString("	\t\t\tc.JSON(201, result)\n")
@@ -29,14 +29,14 @@ func GenerateToken(userID uint, tenantID uint) (string, error) {
 		"user_id":   userID,
 		"tenant_id": tenantID,
 		"type":      "access",
-		"exp":       time.Now().Add(time.Minute * time.Duration(config.Config.JWT.AccessTokenExpiry)).Unix(),
+		"exp":       time.Now().Add(time.Minute * time.Duration(config.Current().JWT.AccessTokenExpiry)).Unix(),
 		"iat":       time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// 签名并获取完整的编码后的字符串token
-	tokenString, err := token.SignedString([]byte(config.Config.JWT.Secret))
+	tokenString, err := token.SignedString([]byte(config.Current().JWT.Secret))
 	if err != nil {
 		return "", err
 	}
@@ -51,14 +51,14 @@ func GenerateRefreshToken(userID uint, tenantID uint) (string, error) {
 		"user_id":   userID,
 		"tenant_id": tenantID,
 		"type":      "refresh",
-		"exp":       time.Now().Add(time.Hour * time.Duration(config.Config.JWT.RefreshTokenExpiry)).Unix(),
+		"exp":       time.Now().Add(time.Hour * time.Duration(config.Current().JWT.RefreshTokenExpiry)).Unix(),
 		"iat":       time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// 签名并获取完整的编码后的字符串token
-	tokenString, err := token.SignedString([]byte(config.Config.JWT.Secret))
+	tokenString, err := token.SignedString([]byte(config.Current().JWT.Secret))
 	if err != nil {
 		return "", err
 	}
@@ -68,13 +68,18 @@ func GenerateRefreshToken(userID uint, tenantID uint) (string, error) {
 
 // VerifyToken 验证JWT令牌，返回userID、token类型与tenantID
 func VerifyToken(tokenString string) (uint, string, uint, error) {
+	return VerifyTokenWithSecret(tokenString, config.Current().JWT.Secret)
+}
+
+// VerifyTokenWithSecret 使用指定密钥验证JWT令牌，供不加载完整配置的独立服务（如aichat）使用
+func VerifyTokenWithSecret(tokenString, secret string) (uint, string, uint, error) {
 	// 解析token
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// 验证签名算法
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
-		return []byte(config.Config.JWT.Secret), nil
+		return []byte(secret), nil
 	})
 
 	if err != nil {
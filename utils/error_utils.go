@@ -4,45 +4,32 @@ import (
 	"encoding/json"
 	"net/http"
 	"weave/pkg"
+	"weave/pkg/i18n"
 
 	"go.uber.org/zap"
 )
 
-// JSONErrorResponse 发送JSON格式的错误响应
+// JSONErrorResponse 以 application/problem+json 格式发送错误响应，使用源语言的错误信息
+// 能取得请求时应使用 pkg.WriteProblem，以便按 Accept-Language 本地化并带上请求ID
 func JSONErrorResponse(w http.ResponseWriter, err error, statusCode int) {
 	// 确保状态码是4xx或5xx
 	if statusCode < 400 {
 		statusCode = http.StatusInternalServerError
 	}
 
+	problem := pkg.ToAppError(err).Problem(i18n.DefaultLanguage)
+	problem.Status = statusCode
+
 	// 设置响应头
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", pkg.ProblemContentType)
 	w.WriteHeader(statusCode)
 
-	// 准备错误响应数据
-	var errResponse struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
-	}
-
-	// 根据错误类型设置响应数据
-	if appErr, ok := err.(*pkg.AppError); ok {
-		errResponse.Code = string(appErr.Code)
-		errResponse.Message = appErr.Message
-	} else {
-		errResponse.Code = "INTERNAL_ERROR"
-		errResponse.Message = "Internal server error"
-	}
-
 	// 写入响应
-	json.NewEncoder(w).Encode(errResponse)
+	json.NewEncoder(w).Encode(problem)
 }
 
 // HandleAPIError 处理API错误并返回标准响应
 func HandleAPIError(w http.ResponseWriter, r *http.Request, err error, defaultMessage string) {
-	// 获取HTTP状态码
-	statusCode := pkg.GetHTTPStatus(err)
-
 	// 记录错误日志
 	pkg.With(
 		zap.Error(err),
@@ -51,14 +38,14 @@ func HandleAPIError(w http.ResponseWriter, r *http.Request, err error, defaultMe
 		zap.String("remote_addr", r.RemoteAddr),
 	).Error(defaultMessage)
 
-	// 返回JSON错误响应
-	JSONErrorResponse(w, err, statusCode)
+	// 返回RFC 7807错误响应
+	pkg.WriteProblem(w, r, err)
 }
 
 // HandleDatabaseError 处理数据库错误
 func HandleDatabaseError(w http.ResponseWriter, r *http.Request, err error, operation string) {
 	// 包装数据库错误
-	appErr := pkg.NewDatabaseError(operation+" failed", err)
+	appErr := pkg.NewDatabaseError("%s 失败", err).WithArgs(operation)
 
 	// 处理API错误
 	HandleAPIError(w, r, appErr, "Database operation failed")
@@ -67,7 +54,7 @@ func HandleDatabaseError(w http.ResponseWriter, r *http.Request, err error, oper
 // HandleValidationError 处理参数验证错误
 func HandleValidationError(w http.ResponseWriter, r *http.Request, err error, field string) {
 	// 包装验证错误
-	appErr := pkg.NewBadRequest(field+" validation failed", err)
+	appErr := pkg.NewBadRequest("%s 校验失败", err).WithArgs(field)
 
 	// 处理API错误
 	HandleAPIError(w, r, appErr, "Validation failed")
@@ -76,7 +63,7 @@ func HandleValidationError(w http.ResponseWriter, r *http.Request, err error, fi
 // HandleNotFoundError 处理资源未找到错误
 func HandleNotFoundError(w http.ResponseWriter, r *http.Request, resource string, id string) {
 	// 创建未找到错误
-	appErr := pkg.NewNotFound(resource+" with id '"+id+"' not found", nil)
+	appErr := pkg.NewNotFound("ID 为 '%[2]s' 的 %[1]s 不存在", nil).WithArgs(resource, id)
 
 	// 处理API错误
 	HandleAPIError(w, r, appErr, "Resource not found")
@@ -85,7 +72,7 @@ func HandleNotFoundError(w http.ResponseWriter, r *http.Request, resource string
 // HandleUnauthorizedError 处理未授权错误
 func HandleUnauthorizedError(w http.ResponseWriter, r *http.Request, err error) {
 	// 包装未授权错误
-	appErr := pkg.NewUnauthorized("Unauthorized access", err)
+	appErr := pkg.NewUnauthorized("未授权访问", err)
 
 	// 处理API错误
 	HandleAPIError(w, r, appErr, "Unauthorized access")
@@ -94,7 +81,7 @@ func HandleUnauthorizedError(w http.ResponseWriter, r *http.Request, err error)
 // HandlePluginError 处理插件错误
 func HandlePluginError(w http.ResponseWriter, r *http.Request, err error, pluginName string) {
 	// 包装插件错误
-	appErr := pkg.NewPluginError("Plugin error in "+pluginName, err)
+	appErr := pkg.NewPluginError("插件 %s 出错", err).WithArgs(pluginName)
 
 	// 处理API错误
 	HandleAPIError(w, r, appErr, "Plugin execution failed")